@@ -1,43 +1,24 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	app "infrahub-ops/src/internal/app"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 // validateBackendFlags checks for invalid flag combinations related to the --backend flag.
 func validateBackendFlags(iops *app.InfrahubOps) error {
-	cfg := iops.Config()
-
-	// Validate backend value
-	switch cfg.Backend {
-	case app.BackendTarball, app.BackendPlakar:
-		// valid
-	default:
-		return fmt.Errorf("unknown backend: %s, expected 'tarball' or 'plakar'", cfg.Backend)
-	}
-
-	if cfg.Backend == app.BackendPlakar {
-		// --repo is required for plakar backend
-		if cfg.Plakar.RepoPath == "" {
-			return fmt.Errorf("--repo is required when using plakar backend")
-		}
-
-		// S3 flags conflict with plakar backend
-		if viper.GetBool("s3-upload") || cfg.S3.Bucket != "" || cfg.S3.Prefix != "" ||
-			cfg.S3.Endpoint != "" || (cfg.S3.Region != "" && cfg.S3.Region != "us-east-1") {
-			return fmt.Errorf("--s3-upload and related S3 flags cannot be used with plakar backend; use --repo s3://... instead")
-		}
-	}
-
-	return nil
+	return iops.ValidateBackendFlags(iops.Settings().GetBool("s3-upload"))
 }
 
 // version is set via ldflags at build time
@@ -46,17 +27,25 @@ var version string
 func main() {
 	app.SetVersion(version)
 	iops := app.NewInfrahubOps()
+	var machine bool
 	rootCmd := &cobra.Command{
 		Use:   "infrahub-backup",
 		Short: "Create and restore Infrahub backups",
 		Long:  "Create and restore backups of Infrahub infrastructure components.",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if machine {
+				cmd.SilenceUsage = true
+				return iops.RunMachine(os.Stdin, os.Stdout, app.BackupMachineOperations)
+			}
 			return cmd.Help()
 		},
 	}
+	rootCmd.Flags().BoolVar(&machine, "machine", false, "Read one JSON operation from stdin and write a JSON result to stdout, without prompting")
 
 	app.ConfigureRootCommand(rootCmd, iops)
 	app.AttachEnvironmentCommands(rootCmd, iops)
+	app.AttachConfigCommands(rootCmd, iops)
+	settings := iops.Settings()
 
 	var force bool
 	var redact bool
@@ -67,7 +56,18 @@ func main() {
 	var restoreExcludeTaskManagerDB bool
 	var restoreMigrateFormat bool
 	var restoreResetDeploymentID bool
+	var restoreMinimizeDowntime bool
+	var restoreCredentialMappings []string
+	var restoreCredentialMappingFile string
+	var restoreTargets []string
 	var restoreDecryptKey string
+	var restoreRehearse bool
+	var restoreFromNeo4jDir string
+	var restoreBootstrapCompose string
+	var restoreComposeTemplate string
+	var restoreHelmBootstrap app.HelmBootstrapOptions
+	var restoreFromPrefectDump string
+	var rehearsalOpts app.RehearsalOptions
 	var s3Upload bool
 	var s3KeepLocal bool
 	var sleepDuration time.Duration
@@ -80,6 +80,8 @@ func main() {
 	var infrahubVersion string
 	var fromFilesEncrypt bool
 	var fromFilesEncryptKey string
+	var artifactsInclude []string
+	var artifactsExclude []string
 
 	createCmd := &cobra.Command{
 		Use:          "create",
@@ -89,17 +91,49 @@ func main() {
 			if err := validateBackendFlags(iops); err != nil {
 				return err
 			}
-			return iops.CreateBackup(
-				viper.GetBool("force"),
-				viper.GetString("neo4jmetadata"),
-				viper.GetBool("exclude-taskmanager"),
-				viper.GetBool("s3-upload"),
-				viper.GetBool("s3-keep-local"),
-				viper.GetDuration("sleep"),
-				viper.GetBool("redact"),
-				viper.GetBool("encrypt"),
-				viper.GetString("encrypt-key"),
-			)
+			iops.Config().ArtifactsInclude = settings.GetStringSlice("artifacts-include")
+			iops.Config().ArtifactsExclude = settings.GetStringSlice("artifacts-exclude")
+			iops.Config().ImpactWebhook = settings.GetString("impact-webhook")
+			iops.Config().PauseWorkPools = settings.GetBool("pause-work-pools")
+			iops.Config().WorkPools = settings.GetStringSlice("work-pools")
+			iops.Config().PgExcludeTableData = settings.GetStringSlice("pg-exclude-table-data")
+			iops.Config().BackupWindows = app.WindowSpecs(settings.Get("backup-window"))
+			iops.Config().BlackoutPeriods = app.WindowSpecs(settings.Get("blackout"))
+			iops.Config().OverrideWindow = settings.GetBool("override-window")
+			iops.Config().VerifyRestore = settings.GetBool("verify-restore")
+			iops.Config().VerifyDecryptKey = settings.GetString("verify-decrypt-key")
+			iops.Config().SplitSize = settings.GetString("split-size")
+			iops.Config().StreamBackup = settings.GetBool("stream")
+			iops.Config().HealthWatch = settings.GetDuration("health-watch")
+			iops.Config().HealthWatchRetries = settings.GetInt("health-watch-retries")
+			iops.Config().RecordTo = settings.GetString("record-to")
+			iops.Config().RecordSignKey = settings.GetString("record-sign-key")
+			iops.Config().RecordOperator = settings.GetString("record-operator")
+			iops.Config().RecordRetentionDays = settings.GetInt("record-retention-days")
+			iops.Config().RegisterKind = settings.GetString("register-kind")
+			iops.Config().LabelFromGit = settings.GetBool("label-from-git")
+			iops.Config().RetentionClass = settings.GetString("retention-class")
+			iops.Config().ChecksumAlgorithm = settings.GetString("checksum-algorithm")
+			iops.Config().FastChecksum = settings.GetBool("fast-checksum")
+			createBackup := func(target *app.InfrahubOps) error {
+				return target.CreateBackup(
+					settings.GetBool("force"),
+					settings.GetString("neo4jmetadata"),
+					settings.GetBool("exclude-taskmanager"),
+					settings.GetBool("s3-upload"),
+					settings.GetBool("s3-keep-local"),
+					settings.GetDuration("sleep"),
+					settings.GetBool("redact"),
+					settings.GetBool("encrypt"),
+					settings.GetString("encrypt-key"),
+				)
+			}
+			return iops.RunWithReport("backup", func() error {
+				if group := settings.GetString("target-group"); group != "" {
+					return iops.RunTargetGroup("backup", group, settings.GetInt("target-concurrency"), createBackup)
+				}
+				return createBackup(iops)
+			})
 		},
 	}
 	createCmd.Flags().BoolVar(&force, "force", false, "Force backup creation even if there are running tasks")
@@ -111,17 +145,69 @@ func main() {
 	createCmd.Flags().DurationVar(&sleepDuration, "sleep", 0, "Sleep duration after backup creation (e.g., 5m, 300s) for manual file transfer")
 	createCmd.Flags().BoolVar(&encrypt, "encrypt", false, "Encrypt the backup archive (uses built-in OpsMill key unless --encrypt-key is set)")
 	createCmd.Flags().StringVar(&encryptKey, "encrypt-key", "", "Path to custom public key file for encryption (implies --encrypt)")
+	createCmd.Flags().StringSliceVar(&artifactsInclude, "artifacts-include", nil, "Only back up object store files matching these glob patterns (e.g., 'infrahub-storage/*')")
+	createCmd.Flags().StringSliceVar(&artifactsExclude, "artifacts-exclude", nil, "Skip object store files matching these glob patterns (e.g., '*.iso'); excluded files are listed in the metadata")
+	createCmd.Flags().String("impact-webhook", "", "URL to POST the flow runs and proposed changes a Community Edition backup interrupts to, as JSON")
+	createCmd.Flags().Bool("pause-work-pools", false, "Pause Prefect work pools during the backup so no new tasks start while running ones drain")
+	createCmd.Flags().StringSlice("work-pools", nil, "Work pools or pool/queue names to pause with --pause-work-pools (default: every work pool)")
+	createCmd.Flags().StringSlice("pg-exclude-table-data", nil, "Task manager tables to back up without their data, keeping the schema (e.g., log,events)")
+	createCmd.Flags().StringArray("backup-window", nil, "Cron-like window backups may run in, e.g. '0 22 * * mon-fri 8h' (repeatable)")
+	createCmd.Flags().StringArray("blackout", nil, "Cron-like period backups must not run in, e.g. '* 8-17 * * mon-fri' (repeatable)")
+	createCmd.Flags().Bool("override-window", false, "Run outside the backup windows with a warning instead of refusing")
+	createCmd.Flags().Bool("verify-restore", false, "Rehearse a restore of the new archive in throwaway containers and mark it verified in the catalog")
+	createCmd.Flags().String("verify-decrypt-key", "", "Private key used by --verify-restore to read an encrypted archive")
+	createCmd.Flags().String("split-size", "", "Split the archive into parts of at most this size (e.g., 4G, 700M) with a manifest, for size-capped destinations")
+	createCmd.Flags().Bool("stream", false, "Read the database dumps straight into the archive instead of staging them locally; with --s3-upload and without --s3-keep-local, the archive is streamed to S3 and never written locally")
+	createCmd.Flags().Duration("health-watch", 0, "Watch services restarted after a Community Edition backup for this long, starting any that stops again (0 disables)")
+	createCmd.Flags().Int("health-watch-retries", 3, "How often --health-watch starts a service that stops again before reporting it degraded")
+	createCmd.Flags().String("record-to", "", "Write a record of the backup (ID, checksums, operator, target) to an Object Lock bucket (s3://bucket/prefix) or POST it to an http(s) URL")
+	createCmd.Flags().String("record-sign-key", "", "Private key file (from keygen) signing the backup record")
+	createCmd.Flags().String("record-operator", "", "Operator named in the backup record (default: the local user)")
+	createCmd.Flags().Bool("label-from-git", false, "Record the commit and branch of the Git repository in the working directory in the backup metadata")
+	createCmd.Flags().String("register-kind", "", "Upsert each new backup (ID, location, size, status) as a node of this Infrahub schema kind, e.g. InfraBackup")
+	createCmd.Flags().String("target-group", "", "Back up every target of this group from the targets of the --config file ('all' for every target)")
+	createCmd.Flags().Int("target-concurrency", 4, "Targets of --target-group backed up at the same time")
+	createCmd.Flags().Int("record-retention-days", 0, "Object Lock compliance retention of the S3 backup record in days (0 uses the bucket default)")
+	createCmd.Flags().String("retention-class", "", "Retention class of the backup, e.g. daily or monthly, choosing its --s3-storage-class and tagged on the uploaded object")
+	createCmd.Flags().String("checksum-algorithm", "sha256", "Algorithm of the checksums recorded for the backup files: sha256, blake3 or xxh64 (faster, integrity only)")
+	createCmd.Flags().Bool("fast-checksum", false, "Record BLAKE3 checksums, several times faster to compute than SHA256 on large backups")
 
 	// Bind create flags to Viper for environment variable support (INFRAHUB_<FLAG_NAME>)
-	viper.BindPFlag("force", createCmd.Flags().Lookup("force"))
-	viper.BindPFlag("redact", createCmd.Flags().Lookup("redact"))
-	viper.BindPFlag("neo4jmetadata", createCmd.Flags().Lookup("neo4jmetadata"))
-	viper.BindPFlag("exclude-taskmanager", createCmd.Flags().Lookup("exclude-taskmanager"))
-	viper.BindPFlag("s3-upload", createCmd.Flags().Lookup("s3-upload"))
-	viper.BindPFlag("s3-keep-local", createCmd.Flags().Lookup("s3-keep-local"))
-	viper.BindPFlag("sleep", createCmd.Flags().Lookup("sleep"))
-	viper.BindPFlag("encrypt", createCmd.Flags().Lookup("encrypt"))
-	viper.BindPFlag("encrypt-key", createCmd.Flags().Lookup("encrypt-key"))
+	settings.BindPFlag("force", createCmd.Flags().Lookup("force"))
+	settings.BindPFlag("redact", createCmd.Flags().Lookup("redact"))
+	settings.BindPFlag("neo4jmetadata", createCmd.Flags().Lookup("neo4jmetadata"))
+	settings.BindPFlag("exclude-taskmanager", createCmd.Flags().Lookup("exclude-taskmanager"))
+	settings.BindPFlag("s3-upload", createCmd.Flags().Lookup("s3-upload"))
+	settings.BindPFlag("s3-keep-local", createCmd.Flags().Lookup("s3-keep-local"))
+	settings.BindPFlag("sleep", createCmd.Flags().Lookup("sleep"))
+	settings.BindPFlag("encrypt", createCmd.Flags().Lookup("encrypt"))
+	settings.BindPFlag("encrypt-key", createCmd.Flags().Lookup("encrypt-key"))
+	settings.BindPFlag("artifacts-include", createCmd.Flags().Lookup("artifacts-include"))
+	settings.BindPFlag("artifacts-exclude", createCmd.Flags().Lookup("artifacts-exclude"))
+	settings.BindPFlag("impact-webhook", createCmd.Flags().Lookup("impact-webhook"))
+	settings.BindPFlag("pause-work-pools", createCmd.Flags().Lookup("pause-work-pools"))
+	settings.BindPFlag("work-pools", createCmd.Flags().Lookup("work-pools"))
+	settings.BindPFlag("pg-exclude-table-data", createCmd.Flags().Lookup("pg-exclude-table-data"))
+	settings.BindPFlag("backup-window", createCmd.Flags().Lookup("backup-window"))
+	settings.BindPFlag("blackout", createCmd.Flags().Lookup("blackout"))
+	settings.BindPFlag("override-window", createCmd.Flags().Lookup("override-window"))
+	settings.BindPFlag("verify-restore", createCmd.Flags().Lookup("verify-restore"))
+	settings.BindPFlag("verify-decrypt-key", createCmd.Flags().Lookup("verify-decrypt-key"))
+	settings.BindPFlag("split-size", createCmd.Flags().Lookup("split-size"))
+	settings.BindPFlag("stream", createCmd.Flags().Lookup("stream"))
+	settings.BindPFlag("health-watch", createCmd.Flags().Lookup("health-watch"))
+	settings.BindPFlag("health-watch-retries", createCmd.Flags().Lookup("health-watch-retries"))
+	settings.BindPFlag("record-to", createCmd.Flags().Lookup("record-to"))
+	settings.BindPFlag("record-sign-key", createCmd.Flags().Lookup("record-sign-key"))
+	settings.BindPFlag("record-operator", createCmd.Flags().Lookup("record-operator"))
+	settings.BindPFlag("register-kind", createCmd.Flags().Lookup("register-kind"))
+	settings.BindPFlag("label-from-git", createCmd.Flags().Lookup("label-from-git"))
+	settings.BindPFlag("record-retention-days", createCmd.Flags().Lookup("record-retention-days"))
+	settings.BindPFlag("retention-class", createCmd.Flags().Lookup("retention-class"))
+	settings.BindPFlag("checksum-algorithm", createCmd.Flags().Lookup("checksum-algorithm"))
+	settings.BindPFlag("fast-checksum", createCmd.Flags().Lookup("fast-checksum"))
+	settings.BindPFlag("target-group", createCmd.Flags().Lookup("target-group"))
+	settings.BindPFlag("target-concurrency", createCmd.Flags().Lookup("target-concurrency"))
 
 	// Undocumented subcommand: create from-files
 	fromFilesCmd := &cobra.Command{
@@ -143,6 +229,52 @@ func main() {
 
 	createCmd.AddCommand(fromFilesCmd)
 
+	createPlanCmd := &cobra.Command{
+		Use:          "plan",
+		Short:        "Print the operations a backup would perform",
+		Long:         "Print the ordered operations create would perform with the same flags: services stopped and started, commands run in each container with passwords masked, files copied, and the duration of each phase expected from the run history. Nothing is stopped or backed up.",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateBackendFlags(iops); err != nil {
+				return err
+			}
+			iops.Config().PauseWorkPools = settings.GetBool("pause-work-pools")
+			iops.Config().VerifyRestore = settings.GetBool("verify-restore")
+			iops.Config().SplitSize = settings.GetString("split-size")
+			iops.Config().StreamBackup = settings.GetBool("stream")
+			iops.Config().ChecksumAlgorithm = settings.GetString("checksum-algorithm")
+			iops.Config().FastChecksum = settings.GetBool("fast-checksum")
+			flags := cmd.Flags()
+			planForce, _ := flags.GetBool("force")
+			planMetadata, _ := flags.GetString("neo4jmetadata")
+			planExcludeTaskManager, _ := flags.GetBool("exclude-taskmanager")
+			planS3Upload, _ := flags.GetBool("s3-upload")
+			planS3KeepLocal, _ := flags.GetBool("s3-keep-local")
+			planRedact, _ := flags.GetBool("redact")
+			planEncrypt, _ := flags.GetBool("encrypt")
+			planEncryptKey, _ := flags.GetString("encrypt-key")
+			format, _ := flags.GetString("format")
+			if err := app.ValidatePlanFormat(format); err != nil {
+				return err
+			}
+			plan, err := iops.PlanBackup(planForce, planMetadata, planExcludeTaskManager, planS3Upload, planS3KeepLocal, planRedact, planEncrypt, planEncryptKey)
+			if err != nil {
+				return err
+			}
+			return app.WriteOperationPlan(os.Stdout, plan, format)
+		},
+	}
+	createPlanCmd.Flags().Bool("force", false, "Plan the backup without waiting for running tasks")
+	createPlanCmd.Flags().Bool("redact", false, "Plan a backup with --redact (requires --force)")
+	createPlanCmd.Flags().String("neo4jmetadata", "all", "Whether to backup neo4j metadata or not (all, none, users, roles)")
+	createPlanCmd.Flags().Bool("exclude-taskmanager", false, "Plan a backup without the task manager database")
+	createPlanCmd.Flags().Bool("s3-upload", false, "Plan the upload to S3")
+	createPlanCmd.Flags().Bool("s3-keep-local", false, "Keep the local backup file after the S3 upload")
+	createPlanCmd.Flags().Bool("encrypt", false, "Plan an encrypted backup")
+	createPlanCmd.Flags().String("encrypt-key", "", "Path to custom public key file for encryption (implies --encrypt)")
+	createPlanCmd.Flags().String("format", app.PlanFormatYAML, "Output format: yaml or json")
+	createCmd.AddCommand(createPlanCmd)
+
 	restoreCmd := &cobra.Command{
 		Use:          "restore [backup-file]",
 		Short:        "Restore Infrahub from a backup archive",
@@ -151,6 +283,12 @@ func main() {
 			if iops.Config().Backend == app.BackendPlakar {
 				return nil // positional arg not required for plakar
 			}
+			if restoreFromNeo4jDir != "" {
+				if len(args) != 0 {
+					return fmt.Errorf("--from-neo4j-dir cannot be combined with a backup file")
+				}
+				return nil
+			}
 			if len(args) != 1 {
 				return fmt.Errorf("requires exactly 1 arg(s), only received %d", len(args))
 			}
@@ -161,10 +299,106 @@ func main() {
 				return err
 			}
 			forceRestore, _ := cmd.Flags().GetBool("force")
-			if iops.Config().Backend == app.BackendPlakar {
-				return iops.RestoreBackup("", restoreExcludeTaskManagerDB, restoreMigrateFormat, restoreSleepDuration, restoreDecryptKey, forceRestore, restoreResetDeploymentID)
+			iops.Config().SkipMQDefinitions, _ = cmd.Flags().GetBool("skip-mq-definitions")
+			iops.Config().AcceptSchemaDiff, _ = cmd.Flags().GetBool("accept-schema-diff")
+			iops.Config().RunPrefectMigrations, _ = cmd.Flags().GetBool("run-prefect-migrations")
+			replayIndexes, _ := cmd.Flags().GetString("replay-indexes")
+			switch replayIndexes {
+			case app.IndexReplayAuto, app.IndexReplayAlways, app.IndexReplayNever:
+				iops.Config().Neo4jIndexReplay = replayIndexes
+			default:
+				return fmt.Errorf("--replay-indexes must be auto, always or never, got %q", replayIndexes)
+			}
+			iops.Config().Anonymize = app.AnonymizeConfig{
+				MaskSecrets:   settings.GetBool("mask-secrets"),
+				CypherScripts: settings.GetStringSlice("anonymize-cypher"),
+				SQLScripts:    settings.GetStringSlice("anonymize-sql"),
+			}
+			if err := iops.Config().Anonymize.Validate(); err != nil {
+				return err
+			}
+			if iops.Config().Anonymize.Enabled() && restoreRehearse {
+				return fmt.Errorf("--mask-secrets, --anonymize-cypher and --anonymize-sql cannot be combined with --rehearse")
+			}
+			credentialMap, err := app.ParseCredentialMappings(restoreCredentialMappings, restoreCredentialMappingFile)
+			if err != nil {
+				return err
+			}
+			iops.Config().CredentialMap = credentialMap
+			credentialMap.LogSummary()
+			if restoreFromPrefectDump != "" && restoreFromNeo4jDir == "" {
+				return fmt.Errorf("--from-prefect-dump requires --from-neo4j-dir")
+			}
+			if restoreComposeTemplate != "" && restoreBootstrapCompose == "" {
+				return fmt.Errorf("--compose-template requires --bootstrap-compose")
+			}
+			helmOnlyFlags := restoreHelmBootstrap.ValuesFile != "" || restoreHelmBootstrap.Chart != "" || restoreHelmBootstrap.ChartVersion != ""
+			if helmOnlyFlags && restoreHelmBootstrap.Namespace == "" {
+				return fmt.Errorf("--helm-values, --helm-chart and --helm-chart-version require --bootstrap-helm")
+			}
+			if restoreHelmBootstrap.Namespace != "" {
+				if iops.Config().Backend == app.BackendPlakar || restoreBootstrapCompose != "" || restoreFromNeo4jDir != "" || restoreRehearse || len(restoreTargets) > 0 {
+					return fmt.Errorf("--bootstrap-helm cannot be combined with the plakar backend, --bootstrap-compose, --from-neo4j-dir, --rehearse or --target")
+				}
+				if cmd.Flags().Changed("project") || cmd.Flags().Changed("k8s-namespace") {
+					return fmt.Errorf("--bootstrap-helm takes the target namespace; it cannot be combined with --project or --k8s-namespace")
+				}
+				return iops.RunWithReport("restore", func() error {
+					return iops.RestoreIntoNewHelmRelease(restoreHelmBootstrap, args[0], restoreDecryptKey, func() error {
+						return iops.RestoreBackup(args[0], restoreExcludeTaskManagerDB, restoreMigrateFormat, restoreSleepDuration, restoreDecryptKey, forceRestore, restoreResetDeploymentID, restoreMinimizeDowntime)
+					})
+				})
+			}
+			if restoreBootstrapCompose != "" {
+				if iops.Config().Backend == app.BackendPlakar || restoreFromNeo4jDir != "" || restoreRehearse || len(restoreTargets) > 0 {
+					return fmt.Errorf("--bootstrap-compose cannot be combined with the plakar backend, --from-neo4j-dir, --rehearse or --target")
+				}
+				if cmd.Flags().Changed("project") || cmd.Flags().Changed("k8s-namespace") {
+					return fmt.Errorf("--bootstrap-compose names the project after its directory; it cannot be combined with --project or --k8s-namespace")
+				}
+				return iops.RunWithReport("restore", func() error {
+					return iops.RestoreIntoNewComposeProject(restoreBootstrapCompose, restoreComposeTemplate, args[0], restoreDecryptKey, func() error {
+						return iops.RestoreBackup(args[0], restoreExcludeTaskManagerDB, restoreMigrateFormat, restoreSleepDuration, restoreDecryptKey, forceRestore, restoreResetDeploymentID, restoreMinimizeDowntime)
+					})
+				})
+			}
+			if restoreFromNeo4jDir != "" {
+				if restoreRehearse || len(restoreTargets) > 0 || restoreDecryptKey != "" {
+					return fmt.Errorf("--from-neo4j-dir cannot be combined with --rehearse, --target or --decrypt-key")
+				}
+				return iops.RunWithReport("restore", func() error {
+					return iops.RestoreFromRawArtifacts(restoreFromNeo4jDir, restoreFromPrefectDump, restoreExcludeTaskManagerDB, restoreMigrateFormat, restoreResetDeploymentID, restoreMinimizeDowntime)
+				})
 			}
-			return iops.RestoreBackup(args[0], restoreExcludeTaskManagerDB, restoreMigrateFormat, restoreSleepDuration, restoreDecryptKey, forceRestore, restoreResetDeploymentID)
+			if restoreRehearse {
+				if iops.Config().Backend == app.BackendPlakar {
+					return fmt.Errorf("--rehearse is not supported with the plakar backend")
+				}
+				if len(restoreTargets) > 0 {
+					return fmt.Errorf("--rehearse cannot be combined with --target")
+				}
+				return iops.RunWithReport("rehearsal", func() error {
+					return iops.RehearseRestore(args[0], restoreDecryptKey, restoreExcludeTaskManagerDB, rehearsalOpts)
+				})
+			}
+			if len(restoreTargets) > 0 {
+				if iops.Config().Backend == app.BackendPlakar {
+					return fmt.Errorf("--target is not supported with the plakar backend")
+				}
+				if cmd.Flags().Changed("project") || cmd.Flags().Changed("k8s-namespace") {
+					return fmt.Errorf("--target cannot be combined with --project or --k8s-namespace")
+				}
+				return iops.RunWithReport("restore", func() error {
+					return iops.RestoreBackupToTargets(args[0], restoreTargets, restoreExcludeTaskManagerDB, restoreMigrateFormat, restoreSleepDuration, restoreDecryptKey, forceRestore, restoreResetDeploymentID, restoreMinimizeDowntime)
+				})
+			}
+			backupFile := ""
+			if iops.Config().Backend != app.BackendPlakar {
+				backupFile = args[0]
+			}
+			return iops.RunWithReport("restore", func() error {
+				return iops.RestoreBackup(backupFile, restoreExcludeTaskManagerDB, restoreMigrateFormat, restoreSleepDuration, restoreDecryptKey, forceRestore, restoreResetDeploymentID, restoreMinimizeDowntime)
+			})
 		},
 	}
 	restoreCmd.Flags().BoolVar(&restoreExcludeTaskManagerDB, "exclude-taskmanager", false, "Skip restoring the task manager database even if present in the archive")
@@ -172,13 +406,339 @@ func main() {
 	restoreCmd.Flags().DurationVar(&restoreSleepDuration, "sleep", 0, "Sleep duration before restore begins (e.g., 5m, 300s) for manual file transfer")
 	restoreCmd.Flags().StringVar(&restoreDecryptKey, "decrypt-key", "", "Path to private key PEM file for decrypting an encrypted backup")
 	restoreCmd.Flags().Bool("force", false, "Force restore of incomplete backup group")
+	restoreCmd.Flags().Bool("skip-mq-definitions", false, "Do not import RabbitMQ definitions after the message queue is wiped")
+	restoreCmd.Flags().Bool("accept-schema-diff", false, "Restore even when the backup schema has node kinds or attributes the target schema lacks, or the reverse")
+	restoreCmd.Flags().Bool("run-prefect-migrations", false, "Run the Prefect database migrations after the restore when the backup's task manager schema revision differs from the target's, instead of failing")
+	restoreCmd.Flags().String("replay-indexes", app.IndexReplayAuto, "Replay the backup's Neo4j index and constraint script after the restore: auto (across major versions or editions), always or never")
 	restoreCmd.Flags().BoolVar(&restoreResetDeploymentID, "reset-deployment-id", false, "Generate a new Root node UUID after restore to detach this instance from the source deployment ID")
-	viper.BindPFlag("decrypt-key", restoreCmd.Flags().Lookup("decrypt-key"))
-	viper.BindPFlag("reset-deployment-id", restoreCmd.Flags().Lookup("reset-deployment-id"))
+	restoreCmd.Flags().Bool("mask-secrets", false, "Replace the stored credential passwords and account API tokens with random values before the services start")
+	restoreCmd.Flags().StringSlice("anonymize-cypher", nil, "Cypher file run against the restored graph database before the services start; repeatable")
+	restoreCmd.Flags().StringSlice("anonymize-sql", nil, "SQL file run against the restored task manager database before the services start; repeatable")
+	restoreCmd.Flags().BoolVar(&restoreMinimizeDowntime, "minimize-downtime", false, "Keep infrahub-server serving reads while the task manager database is restored and the Neo4j backup is staged; stop it only for the final switch")
+	restoreCmd.Flags().StringSliceVar(&restoreCredentialMappings, "map-credentials", nil, "Map source names to target names as key=source:target (keys: neo4j-database, neo4j-user, postgres-database, postgres-role); repeatable")
+	restoreCmd.Flags().StringVar(&restoreCredentialMappingFile, "map-credentials-file", "", "File with one key=source:target credential mapping per line")
+	restoreCmd.Flags().StringArrayVar(&restoreTargets, "target", nil, "Restore into this deployment (docker:<project>, k8s:<namespace> or a bare name); repeat to restore several targets concurrently")
+	restoreCmd.Flags().BoolVar(&restoreRehearse, "rehearse", false, "Restore into throwaway Neo4j and PostgreSQL containers, check the data loads, then remove them; the live deployment is not touched")
+	restoreCmd.Flags().StringVar(&rehearsalOpts.Neo4jImage, "rehearse-neo4j-image", "", "Neo4j image for --rehearse (default: official image matching the backup's Neo4j version and edition)")
+	restoreCmd.Flags().StringVar(&restoreFromNeo4jDir, "from-neo4j-dir", "", "Restore directly from neo4j-admin backup output (a directory, or a Community .dump file) instead of an archive")
+	restoreCmd.Flags().StringVar(&restoreFromPrefectDump, "from-prefect-dump", "", "Task manager database pg_dump to restore together with --from-neo4j-dir")
+	restoreCmd.Flags().StringVar(&restoreBootstrapCompose, "bootstrap-compose", "", "Create a new docker compose project in this directory from the backup's compose manifest, start its data services, restore, then start the application")
+	restoreCmd.Flags().StringVar(&restoreComposeTemplate, "compose-template", "", "Compose file to use with --bootstrap-compose instead of the manifest captured in the backup")
+	restoreCmd.Flags().StringVar(&restoreHelmBootstrap.Namespace, "bootstrap-helm", "", "Install the Infrahub Helm chart into this namespace with the values stored in the backup, then restore into the new release")
+	restoreCmd.Flags().StringVar(&restoreHelmBootstrap.ValuesFile, "helm-values", "", "Values file to use with --bootstrap-helm instead of the values stored in the backup")
+	restoreCmd.Flags().StringVar(&restoreHelmBootstrap.Chart, "helm-chart", "", "Chart to install with --bootstrap-helm (default: "+app.DefaultHelmChart+")")
+	restoreCmd.Flags().StringVar(&restoreHelmBootstrap.ChartVersion, "helm-chart-version", "", "Chart version to install with --bootstrap-helm (default: the version recorded in the backup)")
+	restoreCmd.Flags().StringVar(&rehearsalOpts.PostgresImage, "rehearse-postgres-image", "", "PostgreSQL image for --rehearse (default: official image matching the backup's PostgreSQL major version)")
+	settings.BindPFlag("decrypt-key", restoreCmd.Flags().Lookup("decrypt-key"))
+	settings.BindPFlag("reset-deployment-id", restoreCmd.Flags().Lookup("reset-deployment-id"))
+	settings.BindPFlag("mask-secrets", restoreCmd.Flags().Lookup("mask-secrets"))
+	settings.BindPFlag("anonymize-cypher", restoreCmd.Flags().Lookup("anonymize-cypher"))
+	settings.BindPFlag("anonymize-sql", restoreCmd.Flags().Lookup("anonymize-sql"))
+
+	var restoreCheckDecryptKey string
+
+	restoreCheckCmd := &cobra.Command{
+		Use:          "check <backup-file|s3-uri>",
+		Short:        "Compare the schema of a backup with the target schema",
+		Long:         "List the node kinds and attributes that exist only in the backup's schema snapshot or only in the target's current schema. Fails when they differ, unless --accept-schema-diff is set. Nothing is stopped or restored.",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			iops.Config().AcceptSchemaDiff, _ = cmd.Flags().GetBool("accept-schema-diff")
+			return iops.CheckRestoreSchema(args[0], restoreCheckDecryptKey, os.Stdout)
+		},
+	}
+	restoreCheckCmd.Flags().StringVar(&restoreCheckDecryptKey, "decrypt-key", "", "Path to private key PEM file for reading an encrypted backup")
+	restoreCheckCmd.Flags().Bool("accept-schema-diff", false, "Report schema differences without failing")
+	restoreCmd.AddCommand(restoreCheckCmd)
+
+	restorePlanCmd := &cobra.Command{
+		Use:          "plan <backup-file|s3-uri>",
+		Short:        "Print the operations a restore would perform",
+		Long:         "Print the ordered operations restore would perform with the same flags: services stopped and started, commands run in each container with passwords masked, files copied, and the duration of each phase expected from the run history. Only the backup metadata is read; nothing is stopped or restored.",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateBackendFlags(iops); err != nil {
+				return err
+			}
+			flags := cmd.Flags()
+			planExcludeTaskManager, _ := flags.GetBool("exclude-taskmanager")
+			planMigrateFormat, _ := flags.GetBool("migrate-format")
+			planDecryptKey, _ := flags.GetString("decrypt-key")
+			planResetDeploymentID, _ := flags.GetBool("reset-deployment-id")
+			planMinimizeDowntime, _ := flags.GetBool("minimize-downtime")
+			iops.Config().RunPrefectMigrations, _ = flags.GetBool("run-prefect-migrations")
+			iops.Config().Anonymize.MaskSecrets, _ = flags.GetBool("mask-secrets")
+			iops.Config().Anonymize.CypherScripts, _ = flags.GetStringSlice("anonymize-cypher")
+			iops.Config().Anonymize.SQLScripts, _ = flags.GetStringSlice("anonymize-sql")
+			format, _ := flags.GetString("format")
+			if err := app.ValidatePlanFormat(format); err != nil {
+				return err
+			}
+			plan, err := iops.PlanRestore(args[0], planExcludeTaskManager, planMigrateFormat, planDecryptKey, planResetDeploymentID, planMinimizeDowntime)
+			if err != nil {
+				return err
+			}
+			return app.WriteOperationPlan(os.Stdout, plan, format)
+		},
+	}
+	restorePlanCmd.Flags().Bool("exclude-taskmanager", false, "Plan a restore without the task manager database")
+	restorePlanCmd.Flags().Bool("migrate-format", false, "Plan the migration of the Neo4j store to the block format")
+	restorePlanCmd.Flags().String("decrypt-key", "", "Path to private key PEM file for reading an encrypted backup")
+	restorePlanCmd.Flags().Bool("reset-deployment-id", false, "Plan a new deployment ID")
+	restorePlanCmd.Flags().Bool("minimize-downtime", false, "Plan the restore with --minimize-downtime")
+	restorePlanCmd.Flags().Bool("run-prefect-migrations", false, "Plan the Prefect database migrations")
+	restorePlanCmd.Flags().Bool("mask-secrets", false, "Plan the masking of stored secrets")
+	restorePlanCmd.Flags().StringSlice("anonymize-cypher", nil, "Plan running this Cypher file after the restore; repeatable")
+	restorePlanCmd.Flags().StringSlice("anonymize-sql", nil, "Plan running this SQL file after the restore; repeatable")
+	restorePlanCmd.Flags().String("format", app.PlanFormatYAML, "Output format: yaml or json")
+	restoreCmd.AddCommand(restorePlanCmd)
+
+	var subsetOpts app.SubsetRestoreOptions
+	var subsetDecryptKey string
+
+	restoreSubsetCmd := &cobra.Command{
+		Use:   "subset <backup-file|s3-uri>",
+		Short: "Restore selected objects into the running instance",
+		Long: "Copy the objects of the selected kinds or namespaces, as they were in the backup, into the running Infrahub without stopping or wiping it. " +
+			"The backup is loaded into a throwaway Neo4j container with docker run, and its objects are written back through the GraphQL API under their original IDs: " +
+			"deleted objects are recreated and the attributes and relationships of existing ones are reset.",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateBackendFlags(iops); err != nil {
+				return err
+			}
+			return iops.RunWithReport("restore-subset", func() error {
+				return iops.RestoreSubset(args[0], subsetDecryptKey, subsetOpts, os.Stdout)
+			})
+		},
+	}
+	restoreSubsetCmd.Flags().StringSliceVar(&subsetOpts.Kinds, "kind", nil, "Restore the objects of this node or generic kind, e.g. InfraDevice; repeatable")
+	restoreSubsetCmd.Flags().StringSliceVar(&subsetOpts.Namespaces, "namespace", nil, "Restore the objects of every kind of this schema namespace, e.g. Infra; repeatable")
+	restoreSubsetCmd.Flags().StringVar(&subsetOpts.Branch, "branch", "", "Branch read from the backup and written to (default: the default branch)")
+	restoreSubsetCmd.Flags().BoolVar(&subsetOpts.DryRun, "dry-run", false, "List the objects that would be restored without writing them")
+	restoreSubsetCmd.Flags().StringVar(&subsetOpts.Neo4jImage, "neo4j-image", "", "Neo4j image loading the backup (default: official image matching the backup's Neo4j version and edition)")
+	restoreSubsetCmd.Flags().StringVar(&subsetDecryptKey, "decrypt-key", "", "Path to private key PEM file for reading an encrypted backup")
+	restoreCmd.AddCommand(restoreSubsetCmd)
 
 	rootCmd.AddCommand(createCmd)
 	rootCmd.AddCommand(restoreCmd)
 
+	// Daemon mode runs scheduled backups and exposes health and metrics endpoints
+	var daemonOpts app.DaemonOptions
+	var daemonVerifyRehearse bool
+
+	daemonCmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "Run scheduled backups with /healthz and /metrics endpoints",
+		Long: "Run backups on a fixed interval and serve /healthz (scheduler liveness, last backup status and age) and /metrics (Prometheus) over HTTP. " +
+			"Backup options are read from the same INFRAHUB_* environment variables as the create command.",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateBackendFlags(iops); err != nil {
+				return err
+			}
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			iops.Config().ArtifactsInclude = settings.GetStringSlice("artifacts-include")
+			iops.Config().ArtifactsExclude = settings.GetStringSlice("artifacts-exclude")
+			iops.Config().ImpactWebhook = settings.GetString("impact-webhook")
+			iops.Config().PauseWorkPools = settings.GetBool("pause-work-pools")
+			iops.Config().WorkPools = settings.GetStringSlice("work-pools")
+			iops.Config().PgExcludeTableData = settings.GetStringSlice("pg-exclude-table-data")
+			iops.Config().BackupWindows = app.WindowSpecs(settings.Get("backup-window"))
+			iops.Config().BlackoutPeriods = app.WindowSpecs(settings.Get("blackout"))
+			iops.Config().OverrideWindow = settings.GetBool("override-window")
+			iops.Config().VerifyRestore = settings.GetBool("verify-restore")
+			iops.Config().VerifyDecryptKey = settings.GetString("verify-decrypt-key")
+			iops.Config().SplitSize = settings.GetString("split-size")
+			iops.Config().StreamBackup = settings.GetBool("stream")
+			iops.Config().HealthWatch = settings.GetDuration("health-watch")
+			iops.Config().HealthWatchRetries = settings.GetInt("health-watch-retries")
+			iops.Config().RecordTo = settings.GetString("record-to")
+			iops.Config().RecordSignKey = settings.GetString("record-sign-key")
+			iops.Config().RecordOperator = settings.GetString("record-operator")
+			iops.Config().RecordRetentionDays = settings.GetInt("record-retention-days")
+			iops.Config().RegisterKind = settings.GetString("register-kind")
+			iops.Config().LabelFromGit = settings.GetBool("label-from-git")
+			iops.Config().RetentionClass = settings.GetString("retention-class")
+			iops.Config().ChecksumAlgorithm = settings.GetString("checksum-algorithm")
+			iops.Config().FastChecksum = settings.GetBool("fast-checksum")
+			window, err := app.NewBackupWindow(iops.Config().BackupWindows, iops.Config().BlackoutPeriods)
+			if err != nil {
+				return err
+			}
+			daemonOpts.Window = window
+			daemonOpts.Override = iops.Config().OverrideWindow
+			if daemonVerifyRehearse && daemonOpts.VerifyInterval <= 0 {
+				return fmt.Errorf("--verify-rehearse requires --verify-interval")
+			}
+			daemonOpts.Verify = func() error {
+				return iops.VerifyRetainedBackup(daemonVerifyRehearse)
+			}
+			if daemonOpts.JobsToken == "" {
+				daemonOpts.JobsToken = settings.GetString("jobs-token")
+			}
+			targets, err := iops.ConfiguredTargets()
+			if err != nil {
+				return err
+			}
+			for _, target := range targets {
+				daemonOpts.Targets = append(daemonOpts.Targets, target.Name)
+			}
+			if daemonOpts.APITokens, err = iops.APITokens(); err != nil {
+				return err
+			}
+			daemonOpts.Restore = func(ctx context.Context, target, backup string, excludeTaskManager bool) error {
+				job, err := iops.ForJob(ctx, target)
+				if err != nil {
+					return err
+				}
+				backupFile, err := job.ResolveCatalogBackup(backup)
+				if err != nil {
+					return err
+				}
+				return job.RunWithReport("restore", func() error {
+					return job.RestoreBackup(backupFile, excludeTaskManager, false, 0, "", false, false, false)
+				})
+			}
+			daemonOpts.Catalog = func(target string) ([]app.CatalogEntry, error) {
+				job, err := iops.ForJob(context.Background(), target)
+				if err != nil {
+					return nil, err
+				}
+				return job.CatalogEntries()
+			}
+			return app.RunDaemon(ctx, daemonOpts, func(ctx context.Context, target string) error {
+				job, err := iops.ForJob(ctx, target)
+				if err != nil {
+					return err
+				}
+				return job.RunWithReport("backup", func() error {
+					return job.CreateBackup(
+						settings.GetBool("force"),
+						settings.GetString("neo4jmetadata"),
+						settings.GetBool("exclude-taskmanager"),
+						settings.GetBool("s3-upload"),
+						settings.GetBool("s3-keep-local"),
+						0,
+						settings.GetBool("redact"),
+						settings.GetBool("encrypt"),
+						settings.GetString("encrypt-key"),
+					)
+				})
+			})
+		},
+	}
+	daemonCmd.Flags().DurationVar(&daemonOpts.Interval, "interval", 24*time.Hour, "Time between scheduled backups")
+	daemonCmd.Flags().StringVar(&daemonOpts.ListenAddr, "listen", ":9100", "Bind address for the /healthz and /metrics endpoints")
+	daemonCmd.Flags().BoolVar(&daemonOpts.RunAtStart, "run-at-start", false, "Run a backup immediately on startup instead of after the first interval")
+	daemonCmd.Flags().DurationVar(&daemonOpts.VerifyInterval, "verify-interval", 0, "Verify the checksums of a random retained local backup this often (0 disables)")
+	daemonCmd.Flags().BoolVar(&daemonVerifyRehearse, "verify-rehearse", false, "Follow each background verification with a rehearsal restore in throwaway containers")
+	daemonCmd.Flags().IntVar(&daemonOpts.MaxJobs, "max-jobs", 1, "Jobs running at the same time")
+	daemonCmd.Flags().IntVar(&daemonOpts.MaxJobsPerTarget, "max-jobs-per-target", 1, "Jobs running at the same time on one target")
+	daemonCmd.Flags().StringVar(&daemonOpts.JobsToken, "jobs-token", "", "Bearer token of the /jobs API (default: INFRAHUB_JOBS_TOKEN; the API is disabled without one)")
+	rootCmd.AddCommand(daemonCmd)
+
+	// Jobs commands talk to the /jobs API of a running daemon
+	var daemonURL, jobsToken string
+	jobsClient := func() *app.JobsClient {
+		if jobsToken == "" {
+			jobsToken = settings.GetString("jobs-token")
+		}
+		client := app.NewJobsClient(daemonURL, jobsToken)
+		client.HTTP = iops.Config().HTTP
+		return client
+	}
+	jobsCmd := &cobra.Command{
+		Use:   "jobs",
+		Short: "List, submit and cancel the jobs of a running daemon",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+	jobsCmd.PersistentFlags().StringVar(&daemonURL, "daemon-url", app.DefaultDaemonURL, "URL of the daemon")
+	jobsCmd.PersistentFlags().StringVar(&jobsToken, "jobs-token", "", "Bearer token of the /jobs API (default: INFRAHUB_JOBS_TOKEN)")
+
+	jobsListCmd := &cobra.Command{
+		Use:          "list",
+		Short:        "List the queued, running and recent jobs of the daemon",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			jobs, err := jobsClient().List()
+			if err != nil {
+				return err
+			}
+			if settings.GetString("log-format") == "json" {
+				return json.NewEncoder(os.Stdout).Encode(jobs)
+			}
+			return app.WriteJobs(os.Stdout, jobs)
+		},
+	}
+	jobsSubmitCmd := &cobra.Command{
+		Use:          "submit [target]",
+		Short:        "Queue a backup of the daemon's deployment or of a configured target",
+		Args:         cobra.MaximumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			target := ""
+			if len(args) == 1 {
+				target = args[0]
+			}
+			job, err := jobsClient().Submit(target)
+			if err != nil {
+				return err
+			}
+			logrus.Infof("Queued job %s", job.ID)
+			fmt.Println(job.ID)
+			return nil
+		},
+	}
+	jobsCancelCmd := &cobra.Command{
+		Use:          "cancel <job-id>",
+		Short:        "Cancel a queued job, or stop a running one at its next phase",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			job, err := jobsClient().Cancel(args[0])
+			if err != nil {
+				return err
+			}
+			if job.State == app.JobRunning {
+				logrus.Infof("Job %s stops at its next phase", job.ID)
+			} else {
+				logrus.Infof("Job %s cancelled", job.ID)
+			}
+			return nil
+		},
+	}
+	var jobsRestoreExcludeTaskManager bool
+	jobsRestoreCmd := &cobra.Command{
+		Use:          "restore <backup> [target]",
+		Short:        "Queue a restore of a catalog backup on the daemon's deployment or on a configured target",
+		Args:         cobra.RangeArgs(1, 2),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			target := ""
+			if len(args) == 2 {
+				target = args[1]
+			}
+			job, err := jobsClient().Restore(args[0], target, jobsRestoreExcludeTaskManager)
+			if err != nil {
+				return err
+			}
+			logrus.Infof("Queued job %s", job.ID)
+			fmt.Println(job.ID)
+			return nil
+		},
+	}
+	jobsRestoreCmd.Flags().BoolVar(&jobsRestoreExcludeTaskManager, "exclude-taskmanager", false, "Skip restoring the task manager database")
+	jobsCmd.AddCommand(jobsListCmd, jobsSubmitCmd, jobsRestoreCmd, jobsCancelCmd)
+	rootCmd.AddCommand(jobsCmd)
+
 	// Key generation command
 	var keygenOutput string
 
@@ -222,6 +782,445 @@ func main() {
 
 	rootCmd.AddCommand(versionCmd)
 
+	// Hold/release protect individual archives from retention pruning
+	var holdReason string
+
+	holdCmd := &cobra.Command{
+		Use:          "hold <backup-id|file|s3-uri>",
+		Short:        "Protect a backup archive from pruning",
+		Long:         "Mark a backup archive as held in the backup catalog. Archives stored in S3 also get an Object Lock legal hold, or an infrahub-hold tag when the bucket has no Object Lock configuration.",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if iops.Config().Backend == app.BackendPlakar {
+				return fmt.Errorf("hold is not supported with the plakar backend")
+			}
+			return iops.HoldBackup(args[0], holdReason)
+		},
+	}
+	holdCmd.Flags().StringVar(&holdReason, "reason", "", "Reason for the hold, recorded in the backup catalog")
+	rootCmd.AddCommand(holdCmd)
+
+	releaseCmd := &cobra.Command{
+		Use:          "release <backup-id|file|s3-uri>",
+		Short:        "Release a hold placed on a backup archive",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if iops.Config().Backend == app.BackendPlakar {
+				return fmt.Errorf("release is not supported with the plakar backend")
+			}
+			return iops.ReleaseBackup(args[0])
+		},
+	}
+	rootCmd.AddCommand(releaseCmd)
+
+	// Package/receive move archives across air gaps with an integrity manifest
+	var packageForTransfer bool
+	var packageOutput string
+	var packageSignKey string
+
+	packageCmd := &cobra.Command{
+		Use:          "package <backup-id|file>",
+		Short:        "Bundle a backup archive for one-way transfer",
+		Long:         "Copy a local backup archive into a directory with SHA256SUMS, an optional signature and a human-readable MANIFEST.txt, ready for a data diode or removable media. Check and import it on the far side with receive.",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !packageForTransfer {
+				return fmt.Errorf("--for-transfer is required")
+			}
+			if iops.Config().Backend == app.BackendPlakar {
+				return fmt.Errorf("package is not supported with the plakar backend")
+			}
+			output := packageOutput
+			if output == "" {
+				output = iops.Config().BackupDir
+			}
+			_, err := iops.PackageForTransfer(args[0], output, packageSignKey)
+			return err
+		},
+	}
+	packageCmd.Flags().BoolVar(&packageForTransfer, "for-transfer", false, "Create an air-gapped transfer package")
+	packageCmd.Flags().StringVar(&packageOutput, "output", "", "Directory in which the package directory is created (default: backup directory)")
+	packageCmd.Flags().StringVar(&packageSignKey, "sign-key", "", "Private key PEM file (from keygen) used to sign the checksums")
+	rootCmd.AddCommand(packageCmd)
+
+	var receiveVerifyKey string
+
+	receiveCmd := &cobra.Command{
+		Use:          "receive <package-dir>",
+		Short:        "Verify a transfer package and add it to the backup catalog",
+		Long:         "Check the signature and checksums of a directory created by package --for-transfer, then copy its archive into the backup directory and record it in the catalog. Nothing is copied if any check fails.",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return iops.ReceiveTransfer(args[0], receiveVerifyKey)
+		},
+	}
+	receiveCmd.Flags().StringVar(&receiveVerifyKey, "verify-key", "", "Public key file (keygen .pub) the package must be signed with")
+	rootCmd.AddCommand(receiveCmd)
+
+	// GC reconciles the catalog with the stored archives
+	var gcFix bool
+
+	gcCmd := &cobra.Command{
+		Use:          "gc",
+		Short:        "Reconcile the backup catalog with the stored archives",
+		Long:         "Compare the backup catalog with the archives in the backup directory and, when --s3-bucket is set, in S3. Reports catalog entries whose archive vanished, archives missing from the catalog, and leftovers of interrupted writes and multipart uploads. Nothing is changed without --fix.",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if iops.Config().Backend == app.BackendPlakar {
+				return fmt.Errorf("gc is not supported with the plakar backend")
+			}
+			report, err := iops.GarbageCollect(gcFix)
+			if report != nil {
+				if settings.GetString("log-format") == "json" {
+					data, marshalErr := json.MarshalIndent(report, "", "  ")
+					if marshalErr != nil {
+						return fmt.Errorf("failed to marshal gc report: %w", marshalErr)
+					}
+					fmt.Println(string(data))
+				} else {
+					report.Write(os.Stdout)
+				}
+			}
+			return err
+		},
+	}
+	gcCmd.Flags().BoolVar(&gcFix, "fix", false, "Apply the reported actions to the catalog, local files and S3 uploads")
+	rootCmd.AddCommand(gcCmd)
+
+	// Report summarises recent backups for ops reviews
+	var reportPeriod, reportFormat, reportOutput string
+
+	reportCmd := &cobra.Command{
+		Use:   "report",
+		Short: "Generate reports on past backups",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+	reportGenerateCmd := &cobra.Command{
+		Use:          "generate",
+		Short:        "Summarise the backups, failures, verifications and retention actions of a period",
+		Long:         "Read the run history and the backup catalog of the backup directory and write a Markdown or HTML report of the backups taken during --period, with their sizes, durations and verification status, failed backups and restores, verifications, and the gc, hold and release actions taken.",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			period, err := app.ParseReportPeriod(reportPeriod)
+			if err != nil {
+				return err
+			}
+			if reportFormat != app.ReportMarkdown && reportFormat != app.ReportHTML {
+				return fmt.Errorf("invalid --format %q: expected markdown or html", reportFormat)
+			}
+			report, err := iops.GenerateBackupReport(period, time.Now())
+			if err != nil {
+				return err
+			}
+			if reportOutput == "" {
+				return report.Write(os.Stdout, reportFormat)
+			}
+			file, err := os.Create(reportOutput)
+			if err != nil {
+				return fmt.Errorf("failed to create report: %w", err)
+			}
+			if err := report.Write(file, reportFormat); err != nil {
+				file.Close()
+				return err
+			}
+			return file.Close()
+		},
+	}
+	reportGenerateCmd.Flags().StringVar(&reportPeriod, "period", "7d", "Period covered by the report, ending now (e.g. 7d, 2w, 36h)")
+	reportGenerateCmd.Flags().StringVar(&reportFormat, "format", app.ReportMarkdown, "Report format: markdown or html")
+	reportGenerateCmd.Flags().StringVarP(&reportOutput, "output", "o", "", "File the report is written to (default: stdout)")
+	reportCmd.AddCommand(reportGenerateCmd)
+	rootCmd.AddCommand(reportCmd)
+
+	// Cleanup removes temporary files left behind by crashed runs
+	var cleanupRemote, cleanupAll, cleanupDryRun bool
+
+	cleanupCmd := &cobra.Command{
+		Use:          "cleanup --remote",
+		Short:        "Remove temporary files left in the containers by crashed runs",
+		Long:         "Find the temporary files, watchdog binaries and dumps that backups and restores create in the database and task manager containers, and remove those unchanged for --remote-cleanup-age. create and restore do this on their own before they start; run cleanup after a crash, or with --all when no other run can be active.",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !cleanupRemote {
+				return fmt.Errorf("nothing to clean up: pass --remote")
+			}
+			report, err := iops.CleanupRemote(cleanupAll, cleanupDryRun)
+			if report != nil {
+				if settings.GetString("log-format") == "json" {
+					data, marshalErr := json.MarshalIndent(report, "", "  ")
+					if marshalErr != nil {
+						return fmt.Errorf("failed to marshal cleanup report: %w", marshalErr)
+					}
+					fmt.Println(string(data))
+				} else {
+					report.Write(os.Stdout)
+				}
+			}
+			return err
+		},
+	}
+	cleanupCmd.Flags().BoolVar(&cleanupRemote, "remote", false, "Clean up temporary files inside the deployment's containers")
+	cleanupCmd.Flags().BoolVar(&cleanupAll, "all", false, "Also remove files changed within --remote-cleanup-age, which may belong to a running backup")
+	cleanupCmd.Flags().BoolVar(&cleanupDryRun, "dry-run", false, "List the files without removing them")
+	rootCmd.AddCommand(cleanupCmd)
+
+	// Info prints the metadata of a backup
+	var infoDecryptKey string
+
+	infoCmd := &cobra.Command{
+		Use:          "info <backup-file|s3-uri>",
+		Short:        "Show the metadata of a backup without restoring it",
+		Long:         "Print when and where a backup was taken, its components, and the Infrahub branches that existed at the time. Archives on S3 are read in place with ranged reads when they are neither encrypted nor split. With --log-format json, the metadata is printed as JSON.",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			metadata, index, err := iops.BackupContents(args[0], infoDecryptKey)
+			if err != nil {
+				return err
+			}
+			if settings.GetString("log-format") == "json" {
+				data, err := json.MarshalIndent(struct {
+					*app.BackupMetadata
+					ArchiveIndex *app.ArchiveIndex `json:"archive_index,omitempty"`
+				}{metadata, index}, "", "  ")
+				if err != nil {
+					return fmt.Errorf("failed to marshal backup metadata: %w", err)
+				}
+				fmt.Println(string(data))
+				return nil
+			}
+			if err := app.WriteBackupInfo(os.Stdout, metadata); err != nil {
+				return err
+			}
+			if index == nil {
+				return nil
+			}
+			fmt.Println()
+			return app.WriteArchiveContents(os.Stdout, index)
+		},
+	}
+	infoCmd.Flags().StringVar(&infoDecryptKey, "decrypt-key", "", "Path to private key PEM file for reading an encrypted backup")
+	rootCmd.AddCommand(infoCmd)
+
+	var queryOpts app.QueryOptions
+	var queryDecryptKey string
+
+	queryCmd := &cobra.Command{
+		Use:          "query <backup-file|s3-uri>",
+		Short:        "Run a Cypher query against the graph of a backup",
+		Long:         "Load the Neo4j backup into a throwaway, read-only Neo4j container with docker run, run the --cypher query against it and print the result, then remove the container. Use it to inspect the state of objects at backup time, or their history, without a restore.",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateBackendFlags(iops); err != nil {
+				return err
+			}
+			return iops.QueryBackup(args[0], queryDecryptKey, queryOpts, os.Stdout)
+		},
+	}
+	queryCmd.Flags().StringVar(&queryOpts.Cypher, "cypher", "", "Cypher query to run; write queries are refused")
+	queryCmd.Flags().StringVar(&queryOpts.Format, "format", app.QueryFormatTable, "Output format: table or plain")
+	queryCmd.Flags().StringVar(&queryOpts.Neo4jImage, "neo4j-image", "", "Neo4j image loading the backup (default: official image matching the backup's Neo4j version and edition)")
+	queryCmd.Flags().StringVar(&queryDecryptKey, "decrypt-key", "", "Path to private key PEM file for reading an encrypted backup")
+	rootCmd.AddCommand(queryCmd)
+
+	// Verify-record checks a backup record written by --record-to
+	var verifyRecordKey, verifyRecordArchive string
+
+	verifyRecordCmd := &cobra.Command{
+		Use:          "verify-record <record-file>",
+		Short:        "Check the signature of a backup record and optionally an archive against it",
+		Long:         "Check the signature of a backup record written by create --record-to and print what it describes. With --archive, the archive files are hashed and compared with the record.",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := os.ReadFile(args[0])
+			if err != nil {
+				return fmt.Errorf("failed to read backup record: %w", err)
+			}
+			record, err := app.VerifyBackupRecord(data, verifyRecordKey)
+			if err != nil {
+				return err
+			}
+			if verifyRecordArchive != "" {
+				if err := app.CheckRecordArchive(record, verifyRecordArchive); err != nil {
+					return err
+				}
+				logrus.Infof("Archive %s matches the record", verifyRecordArchive)
+			}
+			out, err := json.MarshalIndent(record, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to marshal backup record: %w", err)
+			}
+			fmt.Println(string(out))
+			return nil
+		},
+	}
+	verifyRecordCmd.Flags().StringVar(&verifyRecordKey, "verify-key", "", "Public key file (from keygen) the record must be signed with")
+	verifyRecordCmd.Flags().StringVar(&verifyRecordArchive, "archive", "", "Archive (or split archive manifest) to compare with the record")
+	rootCmd.AddCommand(verifyRecordCmd)
+
+	// Schema snapshots stored in backups
+	schemaCmd := &cobra.Command{
+		Use:   "schema",
+		Short: "Inspect the Infrahub schema stored in backups",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+
+	var schemaDecryptKey string
+
+	schemaShowCmd := &cobra.Command{
+		Use:          "show <backup-file|s3-uri>",
+		Short:        "Print the schema snapshot of a backup without restoring it",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			snapshot, err := iops.BackupSchemaSnapshot(args[0], schemaDecryptKey)
+			if err != nil {
+				return err
+			}
+			_, err = os.Stdout.Write(append(snapshot, '\n'))
+			return err
+		},
+	}
+	schemaShowCmd.Flags().StringVar(&schemaDecryptKey, "decrypt-key", "", "Path to private key PEM file for reading an encrypted backup")
+	schemaCmd.AddCommand(schemaShowCmd)
+	rootCmd.AddCommand(schemaCmd)
+
+	// Quiesce/unquiesce bracket snapshots taken by external systems
+	var quiesceForce bool
+
+	quiesceCmd := &cobra.Command{
+		Use:          "quiesce",
+		Short:        "Stop writes so an external volume snapshot is application consistent",
+		Long:         "Stop the Infrahub application services, checkpoint Neo4j and the task manager database, and stop the Neo4j database (the database service on Community Edition). Take the SAN, VM or file system snapshot afterwards, then run unquiesce.",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return iops.Quiesce(quiesceForce)
+		},
+	}
+	quiesceCmd.Flags().BoolVar(&quiesceForce, "force", false, "Quiesce even if there are running tasks")
+	rootCmd.AddCommand(quiesceCmd)
+
+	unquiesceCmd := &cobra.Command{
+		Use:          "unquiesce",
+		Short:        "Restart what quiesce stopped",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return iops.Unquiesce()
+		},
+	}
+	rootCmd.AddCommand(unquiesceCmd)
+
+	// Audit inspects a running deployment without changing it
+	var auditDecryptKey string
+	var auditMinScore int
+
+	auditCmd := &cobra.Command{
+		Use:          "audit",
+		Short:        "Check a running deployment's backup readiness without modifying it",
+		Long:         "Inspect the Neo4j backup settings, the task manager database WAL settings, disk utilisation and the latest backup archive, and print a scored report. Nothing is stopped or written. Exits non-zero when the score is below --min-score.",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			report, err := iops.Audit(auditDecryptKey)
+			if err != nil {
+				return err
+			}
+			if settings.GetString("log-format") == "json" {
+				data, err := json.MarshalIndent(report, "", "  ")
+				if err != nil {
+					return fmt.Errorf("failed to marshal audit report: %w", err)
+				}
+				fmt.Println(string(data))
+			} else {
+				report.Write(os.Stdout)
+			}
+			if report.Score < auditMinScore {
+				return fmt.Errorf("audit score %d is below --min-score %d", report.Score, auditMinScore)
+			}
+			return nil
+		},
+	}
+	auditCmd.Flags().StringVar(&auditDecryptKey, "decrypt-key", "", "Path to the private key PEM file used to verify an encrypted latest backup")
+	auditCmd.Flags().IntVar(&auditMinScore, "min-score", 0, "Exit non-zero when the audit score is below this value (0-100)")
+	rootCmd.AddCommand(auditCmd)
+
+	// Rotate-credentials replaces a database password everywhere it is used
+	var rotateComponent, rotatePasswordFile, rotateEnvFile string
+	var rotateDryRun bool
+
+	rotateCredentialsCmd := &cobra.Command{
+		Use:          "rotate-credentials",
+		Short:        "Replace the Neo4j or PostgreSQL password of the running deployment",
+		Long:         "Generate a new password, change it in the database, update every compose env file variable or Kubernetes secret key holding the old one, and restart the services using it: infrahub-server then task-worker for neo4j, the task manager for postgres. If the settings cannot be written, the database password is changed back.",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			password := ""
+			if rotatePasswordFile != "" {
+				data, err := os.ReadFile(rotatePasswordFile)
+				if err != nil {
+					return fmt.Errorf("failed to read password file: %w", err)
+				}
+				password = strings.TrimSpace(string(data))
+				if password == "" {
+					return fmt.Errorf("password file %s is empty", rotatePasswordFile)
+				}
+			}
+			rotation, err := iops.RotateCredentials(rotateComponent, password, rotateEnvFile, rotateDryRun)
+			if rotation != nil {
+				if settings.GetString("log-format") == "json" {
+					data, marshalErr := json.MarshalIndent(rotation, "", "  ")
+					if marshalErr != nil {
+						return fmt.Errorf("failed to marshal rotation report: %w", marshalErr)
+					}
+					fmt.Println(string(data))
+				} else {
+					rotation.Write(os.Stdout)
+				}
+			}
+			return err
+		},
+	}
+	rotateCredentialsCmd.Flags().StringVar(&rotateComponent, "component", "", "Database whose password to rotate: neo4j or postgres")
+	rotateCredentialsCmd.Flags().StringVar(&rotatePasswordFile, "password-file", "", "Read the new password from this file instead of generating one")
+	rotateCredentialsCmd.Flags().StringVar(&rotateEnvFile, "env-file", "", "Compose env file holding the password (default: .env in the compose project directory)")
+	rotateCredentialsCmd.Flags().BoolVar(&rotateDryRun, "dry-run", false, "List the settings that would change without rotating")
+	rotateCredentialsCmd.MarkFlagRequired("component")
+	rootCmd.AddCommand(rotateCredentialsCmd)
+
+	// Watchdog subcommand
+	watchdogCmd := &cobra.Command{
+		Use:   "watchdog",
+		Short: "Inspect the Neo4j watchdog used by Community Edition backups",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+
+	watchdogSelfTestCmd := &cobra.Command{
+		Use:          "self-test",
+		Short:        "Check that the embedded watchdog runs in the database container",
+		Long:         "Copy the embedded watchdog binary for the database container's architecture (amd64 or arm64) into the container, run its self-test and remove it. Neo4j is not stopped.",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return iops.WatchdogSelfTest()
+		},
+	}
+
+	watchdogCmd.AddCommand(watchdogSelfTestCmd)
+	rootCmd.AddCommand(watchdogCmd)
+
 	// Snapshots subcommand
 	snapshotsCmd := &cobra.Command{
 		Use:   "snapshots",
@@ -239,7 +1238,7 @@ func main() {
 			if iops.Config().Plakar.RepoPath == "" {
 				return fmt.Errorf("--repo is required for snapshots list")
 			}
-			jsonOutput := viper.GetString("log-format") == "json"
+			jsonOutput := settings.GetString("log-format") == "json"
 			return iops.ListSnapshots(jsonOutput)
 		},
 	}
@@ -247,8 +1246,9 @@ func main() {
 	snapshotsCmd.AddCommand(snapshotsListCmd)
 	rootCmd.AddCommand(snapshotsCmd)
 
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.Execute()
+	if err != nil {
 		logrus.Errorf("Command failed: %v", err)
-		os.Exit(1)
 	}
+	os.Exit(iops.Finish(os.Stderr, err))
 }
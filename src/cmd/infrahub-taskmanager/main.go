@@ -1,6 +1,8 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
 	"strconv"
 
@@ -16,23 +18,38 @@ var version string
 func main() {
 	app.SetVersion(version)
 	iops := app.NewInfrahubOps()
+	var machine bool
 	rootCmd := &cobra.Command{
 		Use:   "infrahub-taskmanager",
 		Short: "Task manager (Prefect) maintenance operations",
 		Long:  "Maintenance operations for the task manager (Prefect) such as flushing old flow runs.",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if machine {
+				cmd.SilenceUsage = true
+				return iops.RunMachine(os.Stdin, os.Stdout, app.TaskManagerMachineOperations)
+			}
 			return cmd.Help()
 		},
 	}
+	rootCmd.Flags().BoolVar(&machine, "machine", false, "Read one JSON operation from stdin and write a JSON result to stdout, without prompting")
 
 	app.ConfigureRootCommand(rootCmd, iops)
 	app.AttachEnvironmentCommands(rootCmd, iops)
+	app.AttachConfigCommands(rootCmd, iops)
 
 	flushCmd := &cobra.Command{
 		Use:   "flush",
 		Short: "Flush / cleanup operations",
 		Long:  "Cleanup operations for Prefect resources.",
 	}
+	flushCmd.PersistentFlags().Bool("auto-backup", false, "Back up the task manager database before flushing, so the flush can be undone with infrahub-backup restore")
+	flushCmd.PersistentFlags().Int("auto-backup-keep", app.DefaultFlushAutoBackupKeep, "Pre-flush backups to keep; older ones are deleted")
+	iops.Settings().BindPFlag("auto-backup", flushCmd.PersistentFlags().Lookup("auto-backup"))
+	iops.Settings().BindPFlag("auto-backup-keep", flushCmd.PersistentFlags().Lookup("auto-backup-keep"))
+	applyFlushOptions := func() {
+		iops.Config().FlushAutoBackup = iops.Settings().GetBool("auto-backup")
+		iops.Config().FlushAutoBackupKeep = iops.Settings().GetInt("auto-backup-keep")
+	}
 
 	flowRunsCmd := &cobra.Command{
 		Use:          "flow-runs [days_to_keep] [batch_size]",
@@ -55,6 +72,7 @@ func main() {
 					return err
 				}
 			}
+			applyFlushOptions()
 			return iops.FlushFlowRuns(days, batch)
 		},
 	}
@@ -80,6 +98,7 @@ func main() {
 					return err
 				}
 			}
+			applyFlushOptions()
 			return iops.FlushStaleRuns(days, batch)
 		},
 	}
@@ -88,6 +107,64 @@ func main() {
 	flushCmd.AddCommand(staleRunsCmd)
 	rootCmd.AddCommand(flushCmd)
 
+	deploymentsCmd := &cobra.Command{
+		Use:   "deployments",
+		Short: "List, pause and resume task manager deployments",
+		Long:  "Scheduled Infrahub jobs such as Git synchronization and artifact generation run as task manager (Prefect) deployments. Pause them during maintenance and resume them afterwards.",
+	}
+
+	deploymentsListCmd := &cobra.Command{
+		Use:          "list",
+		Short:        "List deployments with their state and schedules",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			deployments, err := iops.ListDeployments()
+			if err != nil {
+				return err
+			}
+			if iops.Settings().GetString("log-format") == "json" {
+				data, err := json.MarshalIndent(deployments, "", "  ")
+				if err != nil {
+					return fmt.Errorf("failed to marshal deployments: %w", err)
+				}
+				fmt.Println(string(data))
+				return nil
+			}
+			return app.WriteDeployments(os.Stdout, deployments)
+		},
+	}
+
+	deploymentsPauseCmd := &cobra.Command{
+		Use:          "pause [flow/deployment...]",
+		Short:        "Pause deployments, or every deployment when none are named",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			paused, err := iops.PauseDeployments(args)
+			if err != nil {
+				return err
+			}
+			for _, name := range paused {
+				fmt.Println(name)
+			}
+			return nil
+		},
+	}
+
+	deploymentsResumeCmd := &cobra.Command{
+		Use:          "resume <flow/deployment>...",
+		Short:        "Resume paused deployments",
+		Args:         cobra.MinimumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, err := iops.ResumeDeployments(args)
+			return err
+		},
+	}
+
+	deploymentsCmd.AddCommand(deploymentsListCmd, deploymentsPauseCmd, deploymentsResumeCmd)
+	rootCmd.AddCommand(deploymentsCmd)
+
 	versionCmd := &cobra.Command{
 		Use:   "version",
 		Short: "Print Infrahub Ops CLI build information",
@@ -98,8 +175,9 @@ func main() {
 
 	rootCmd.AddCommand(versionCmd)
 
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.Execute()
+	if err != nil {
 		logrus.Errorf("Command failed: %v", err)
-		os.Exit(1)
 	}
+	os.Exit(iops.Finish(os.Stderr, err))
 }
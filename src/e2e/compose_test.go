@@ -0,0 +1,81 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// defaultComposeURL serves the Docker Compose file of the latest Infrahub
+// release; INFRAHUB_E2E_COMPOSE_URL points at another one.
+const defaultComposeURL = "https://infrahub.opsmill.io"
+
+// composeFile downloads the compose file of the Infrahub stack under test.
+func composeFile(t *testing.T) string {
+	t.Helper()
+	url := os.Getenv("INFRAHUB_E2E_COMPOSE_URL")
+	if url == "" {
+		url = defaultComposeURL
+		if os.Getenv("INFRAHUB_TESTING_ENTERPRISE") != "" {
+			url += "/enterprise"
+		}
+	}
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("failed to download the compose file: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("failed to download the compose file from %s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "docker-compose.yml")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestComposeBackupRestore restores a backup of a throwaway Docker Compose
+// stack.
+func TestComposeBackupRestore(t *testing.T) {
+	requireTools(t, "docker")
+
+	project := fmt.Sprintf("infrahub-e2e-%d", time.Now().Unix())
+	port := freePort(t)
+	env := []string{
+		fmt.Sprintf("INFRAHUB_SERVER_PORT=%d", port),
+		"INFRAHUB_INITIAL_ADMIN_TOKEN=" + adminToken,
+	}
+	file := composeFile(t)
+
+	t.Cleanup(func() {
+		if t.Failed() {
+			logs, _ := exec.Command("docker", "compose", "-p", project, "logs", "--tail=200").CombinedOutput()
+			t.Logf("docker compose logs:\n%s", logs)
+		}
+		down := exec.Command("docker", "compose", "-p", project, "-f", file, "down", "--volumes")
+		down.Env = append(os.Environ(), env...)
+		if output, err := down.CombinedOutput(); err != nil {
+			t.Logf("failed to remove project %s: %v\n%s", project, err, strings.TrimSpace(string(output)))
+		}
+	})
+	run(t, env, "docker", "compose", "-p", project, "-f", file, "up", "--detach")
+
+	url := fmt.Sprintf("http://localhost:%d", port)
+	backupAndRestore(t, nil, []string{"--project", project}, func() string {
+		waitForInfrahub(t, url, 10*time.Minute)
+		return url
+	})
+}
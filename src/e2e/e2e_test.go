@@ -0,0 +1,221 @@
+//go:build e2e
+
+// Package e2e runs infrahub-backup against throwaway Infrahub deployments: a
+// Docker Compose stack and a Helm release on a kind cluster. Each test seeds
+// data, backs it up, deletes the data, restores the backup and checks the data
+// is back. Run with:
+//
+//	go test -tags untested_go_version,e2e -timeout 60m ./src/e2e/...
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// adminToken is the initial admin API token of the throwaway deployments.
+const adminToken = "06438eb2-8019-4776-878c-0941b1f1d1ec"
+
+// backupBinary is the infrahub-backup binary built by TestMain.
+var backupBinary string
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "infrahub-backup-e2e")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	backupBinary = filepath.Join(dir, "infrahub-backup")
+	build := exec.Command("go", "build", "-tags", "untested_go_version", "-o", backupBinary, "../cmd/infrahub-backup")
+	build.Stdout, build.Stderr = os.Stdout, os.Stderr
+	if err := build.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to build infrahub-backup: %v\n", err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// projectRoot returns the repository root, where the e2e fixtures live.
+func projectRoot(t *testing.T) string {
+	t.Helper()
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..")
+}
+
+// requireTools skips the test when a command it needs is not installed.
+func requireTools(t *testing.T, tools ...string) {
+	t.Helper()
+	for _, tool := range tools {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s is not installed", tool)
+		}
+	}
+}
+
+// run runs a command with env added to the environment and returns its
+// combined output, failing the test when it exits with an error.
+func run(t *testing.T, env []string, name string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command(name, args...)
+	cmd.Env = append(os.Environ(), env...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%s %s failed: %v\n%s", name, strings.Join(args, " "), err, output)
+	}
+	return string(output)
+}
+
+// freePort returns a TCP port that is free on the loopback interface.
+func freePort(t *testing.T) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// waitForInfrahub waits until the API of the Infrahub server at url answers.
+func waitForInfrahub(t *testing.T, url string, timeout time.Duration) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		resp, err := http.Get(url + "/api/config")
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return
+			}
+		}
+		time.Sleep(5 * time.Second)
+	}
+	t.Fatalf("Infrahub at %s did not answer within %s", url, timeout)
+}
+
+// graphql runs query against the Infrahub server at url and decodes the data
+// of the response into out.
+func graphql(t *testing.T, url, query string, out any) {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"query": query})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url+"/graphql", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-INFRAHUB-KEY", adminToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GraphQL request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("failed to decode GraphQL response: %v", err)
+	}
+	if len(result.Errors) > 0 {
+		t.Fatalf("GraphQL query failed: %s", result.Errors[0].Message)
+	}
+	if err := json.Unmarshal(result.Data, out); err != nil {
+		t.Fatalf("failed to decode GraphQL data: %v", err)
+	}
+}
+
+// seedTag creates a tag with a unique name and returns its name and ID.
+func seedTag(t *testing.T, url string) (string, string) {
+	t.Helper()
+	name := fmt.Sprintf("e2e-backup-test-%d", time.Now().UnixNano())
+	var created struct {
+		BuiltinTagCreate struct {
+			Object struct {
+				ID string `json:"id"`
+			} `json:"object"`
+		} `json:"BuiltinTagCreate"`
+	}
+	graphql(t, url, fmt.Sprintf(`mutation { BuiltinTagCreate(data: {name: {value: %q}}) { ok object { id } } }`, name), &created)
+	return name, created.BuiltinTagCreate.Object.ID
+}
+
+// deleteTag deletes the tag with id.
+func deleteTag(t *testing.T, url, id string) {
+	t.Helper()
+	var deleted struct {
+		BuiltinTagDelete struct {
+			OK bool `json:"ok"`
+		} `json:"BuiltinTagDelete"`
+	}
+	graphql(t, url, fmt.Sprintf(`mutation { BuiltinTagDelete(data: {id: %q}) { ok } }`, id), &deleted)
+	if !deleted.BuiltinTagDelete.OK {
+		t.Fatalf("failed to delete tag %s", id)
+	}
+}
+
+// tagExists reports whether a tag named name exists.
+func tagExists(t *testing.T, url, name string) bool {
+	t.Helper()
+	var tags struct {
+		BuiltinTag struct {
+			Count int `json:"count"`
+		} `json:"BuiltinTag"`
+	}
+	graphql(t, url, fmt.Sprintf(`query { BuiltinTag(name__value: %q) { count } }`, name), &tags)
+	return tags.BuiltinTag.Count > 0
+}
+
+// latestBackup returns the newest archive in dir.
+func latestBackup(t *testing.T, dir string) string {
+	t.Helper()
+	archives, err := filepath.Glob(filepath.Join(dir, "infrahub_backup_*.tar.gz"))
+	if err != nil || len(archives) == 0 {
+		t.Fatalf("no backup archive found in %s", dir)
+	}
+	return archives[len(archives)-1]
+}
+
+// backupAndRestore seeds a tag, backs the deployment up, deletes the tag,
+// restores the backup and checks the tag is back. target holds the flags
+// selecting the deployment; connect returns the server URL and is called
+// again after the restore, which restarts the server.
+func backupAndRestore(t *testing.T, env, target []string, connect func() string) {
+	t.Helper()
+	url := connect()
+	name, id := seedTag(t, url)
+
+	backupDir := t.TempDir()
+	run(t, env, backupBinary, append(append([]string{}, target...), "--backup-dir", backupDir, "create", "--force")...)
+	archive := latestBackup(t, backupDir)
+
+	url = connect()
+	deleteTag(t, url, id)
+	if tagExists(t, url, name) {
+		t.Fatalf("tag %s still exists after deletion", name)
+	}
+
+	run(t, env, backupBinary, append(append([]string{}, target...), "restore", archive)...)
+
+	url = connect()
+	if !tagExists(t, url, name) {
+		t.Errorf("tag %s was not restored from %s", name, filepath.Base(archive))
+	}
+}
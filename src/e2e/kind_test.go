@@ -0,0 +1,89 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// defaultHelmChart is the Infrahub chart installed on the kind cluster;
+// INFRAHUB_HELM_CHART points at another one.
+const defaultHelmChart = "oci://registry.opsmill.io/opsmill/chart/infrahub"
+
+// forwardingPattern matches the local address kubectl port-forward prints.
+var forwardingPattern = regexp.MustCompile(`Forwarding from 127\.0\.0\.1:(\d+)`)
+
+// portForward forwards a local port to the Infrahub server service and
+// returns its URL. The forward is stopped when the test ends.
+func portForward(t *testing.T, env []string, namespace string) string {
+	t.Helper()
+	cmd := exec.Command("kubectl", "port-forward", "-n", namespace, "svc/infrahub-infrahub-server", ":8000")
+	cmd.Env = append(os.Environ(), env...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed to start kubectl port-forward: %v", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		if match := forwardingPattern.FindStringSubmatch(scanner.Text()); match != nil {
+			go io.Copy(io.Discard, stdout)
+			return "http://127.0.0.1:" + match[1]
+		}
+	}
+	t.Fatalf("kubectl port-forward exited before forwarding: %v", scanner.Err())
+	return ""
+}
+
+// TestKindBackupRestore restores a backup of Infrahub installed with Helm on a
+// throwaway kind cluster.
+func TestKindBackupRestore(t *testing.T) {
+	requireTools(t, "kind", "kubectl", "helm")
+
+	cluster := fmt.Sprintf("infrahub-e2e-%d", time.Now().Unix())
+	kubeconfig := filepath.Join(t.TempDir(), "kubeconfig")
+	env := []string{"KUBECONFIG=" + kubeconfig}
+	const namespace = "infrahub"
+
+	t.Cleanup(func() {
+		if t.Failed() {
+			pods, _ := exec.Command("kubectl", "--kubeconfig", kubeconfig, "get", "pods", "-n", namespace, "-o", "wide").CombinedOutput()
+			t.Logf("pods:\n%s", pods)
+		}
+		if output, err := exec.Command("kind", "delete", "cluster", "--name", cluster).CombinedOutput(); err != nil {
+			t.Logf("failed to delete kind cluster %s: %v\n%s", cluster, err, output)
+		}
+	})
+	run(t, nil, "kind", "create", "cluster", "--name", cluster, "--kubeconfig", kubeconfig, "--wait", "5m")
+
+	chart := os.Getenv("INFRAHUB_HELM_CHART")
+	if chart == "" {
+		chart = defaultHelmChart
+	}
+	values := filepath.Join(projectRoot(t), "tests", "e2e", "fixtures", "helm", "infrahub-values.yaml")
+	run(t, env, "helm", "upgrade", "--install", "infrahub", chart,
+		"--dependency-update", "--create-namespace", "-n", namespace,
+		"-f", values, "--wait", "--timeout", "15m")
+
+	backupAndRestore(t, env, []string{"--k8s-namespace", namespace}, func() string {
+		run(t, env, "kubectl", "rollout", "status", "-n", namespace, "deployment/infrahub-infrahub-server", "--timeout", "10m")
+		url := portForward(t, env, namespace)
+		waitForInfrahub(t, url, 5*time.Minute)
+		return url
+	})
+}
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"embed"
 	"errors"
 	"fmt"
@@ -8,10 +9,13 @@ import (
 	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
 )
 
 // maxPrefectPaginationSize is the largest pagination size the task-manager accepts.
@@ -45,52 +49,210 @@ type PlakarConfig struct {
 
 // Configuration holds the application configuration
 type Configuration struct {
-	BackupDir            string
-	DockerComposeProject string
-	K8sNamespace         string
-	Neo4jUsername        string
-	Neo4jPassword        string
-	Neo4jDatabase        string
-	PostgresUsername     string
-	PostgresPassword     string
-	PostgresDatabase     string
-	S3                   *S3Config
-	Backend              BackendType
-	Plakar               *PlakarConfig
+	BackupDir             string
+	DockerComposeProject  string
+	K8sNamespace          string
+	K8sReleaseName        string             // Helm release to target when several share a namespace
+	K8sInstanceLabel      string             // pod label holding the release name
+	Kubernetes            *KubernetesMapping // selector and service overrides from the kubernetes key of --config
+	K8sReadyTimeout       time.Duration      // wait for a ready pod, sidecars included, after scaling up; 0 disables
+	Neo4jUsername         string
+	Neo4jPassword         string
+	Neo4jDatabase         string
+	Neo4jPIDFile          string              // Neo4j PID file in the database container; empty reads neo4j.conf
+	Neo4jDataDir          string              // Neo4j data directory in the database container; empty reads neo4j.conf
+	Neo4jMetadataScript   string              // restore_metadata.cypher written by restores; empty derives it from the data directory
+	Neo4jAdminHeap        string              // neo4j-admin heap size, e.g. 512m; empty derives it from the container memory limit
+	Neo4jAdminPageCache   string              // neo4j-admin --pagecache, e.g. 512m; empty derives it from the container memory limit
+	Credentials           DatabaseCredentials // credentials from flags or INFRAHUB_* variables; override discovery
+	CredentialFiles       DatabaseCredentials // files holding credentials, read when the matching Credentials field is empty
+	PostgresUsername      string
+	PostgresPassword      string
+	PostgresDatabase      string
+	S3                    *S3Config
+	Backend               BackendType
+	Plakar                *PlakarConfig
+	CredentialMap         *CredentialMapping // source-to-target name mapping applied during restore
+	NoDetect              bool               // trust --project/--k8s-namespace without probing the environment
+	DetectCacheTTL        time.Duration      // reuse of cached environment detection; 0 disables the cache
+	CredentialCache       string             // age:<identity-file> or gpg:<recipient> encrypting cached credentials; empty disables
+	CredentialCacheTTL    time.Duration      // reuse of cached credentials
+	Nice                  int                // niceness for dump commands in the database containers; 0 leaves it unchanged
+	IONice                string             // ionice class for dump commands: idle, best-effort or best-effort:<0-7>
+	PgJobs                int                // parallel pg_dump/pg_restore jobs; dumps use the directory format when set
+	PgExcludeTableData    []string           // task manager tables dumped without their data
+	SkipMQDefinitions     bool               // do not import RabbitMQ definitions after a restore wipes the message queue
+	AcceptSchemaDiff      bool               // restore even when the backup schema differs from the target schema
+	Neo4jIndexReplay      string             // replay the backup's index and constraint script after a restore: auto, always or never
+	ArtifactsInclude      []string           // glob patterns of object store files to back up; empty keeps all
+	ArtifactsExclude      []string           // glob patterns of object store files to skip
+	ImpactWebhook         string             // URL notified with the work a Community Edition backup interrupts
+	PauseWorkPools        bool               // pause Prefect work pools for the duration of a backup
+	WorkPools             []string           // work pools or pool/queue names to pause; empty pauses every pool
+	BackupWindows         []string           // cron-like windows backups may run in; empty allows any time
+	BlackoutPeriods       []string           // cron-like periods backups must not run in
+	OverrideWindow        bool               // warn instead of refusing outside the backup windows
+	VerifyRestore         bool               // rehearse a restore of each new archive and mark it verified
+	SplitSize             string             // cut archives into parts of this size, e.g. 4G; empty disables
+	StreamBackup          bool               // read the database dumps straight into the archive, without staging them locally
+	HealthWatch           time.Duration      // watch services restarted after a backup for this long; 0 disables
+	HealthWatchRetries    int                // starts of a service that stops during the health watch
+	VerifyDecryptKey      string             // private key used to verify encrypted archives
+	WarningsAsErrors      bool               // exit non-zero when a successful command logged warnings
+	PollInterval          time.Duration      // first interval of wait loops; 0 keeps each loop's default
+	PollMaxInterval       time.Duration      // longest interval wait loops back off to; 0 keeps each loop's default
+	TelemetryEndpoint     string             // URL receiving an anonymous usage report per command; empty disables
+	NotifyWebhook         string             // URL the report of each create and restore is posted to; empty disables
+	NotifyWebhookTemplate string             // Go template file rendering the --notify-webhook body; empty posts the report as JSON
+	NotifySlackWebhook    string             // Slack incoming webhook notified of each create and restore; empty disables
+	NotifySlackTemplate   string             // Go template file rendering the Slack message
+	NotifyEmailTo         []string           // recipients of email notifications; empty disables them
+	NotifyEmailFrom       string             // sender of email notifications
+	NotifyEmailTemplate   string             // Go template file rendering the email body
+	SMTP                  SMTPConfig         // mail server email notifications are sent through
+	HTTP                  HTTPConfig         // proxy and TLS settings of outgoing HTTP connections
+	RemoteCleanupAge      time.Duration      // remove container temp files unchanged for this long before create and restore; 0 disables
+	CopyChunkSize         int64              // copy files larger than this out of containers in checksummed chunks; 0 disables
+	CopyParallelism       int                // chunks copied at the same time
+	ThrottleCPU           float64            // pause online backup copies while database CPU is above this percent of a core; 0 disables
+	ThrottleIO            int64              // pause online backup copies while database block IO is above this many bytes per second; 0 disables
+	ThrottleMaxPause      time.Duration      // longest a throttled copy waits before going on regardless
+	StopStrategies        map[string]string  // how services are stopped, by service; "*" applies to services without an entry
+	NonInteractive        bool               // skip the pause before a Community Edition backup stops services
+	RegisterKind          string             // Infrahub schema kind each new backup is upserted as; empty disables
+	LabelFromGit          bool               // record the Git checkout of the working directory in backup metadata
+	FailAt                string             // fail point of the hidden --fail-at test flag; empty disables
+	RecordTo              string             // s3://bucket/prefix or URL receiving a record of each backup; empty disables
+	RecordSignKey         string             // keygen private key signing backup records
+	RecordOperator        string             // operator named in backup records; defaults to the local user
+	RecordRetentionDays   int                // Object Lock compliance retention of S3 records; 0 uses the bucket default
+	S3StorageClasses      []string           // storage class of uploads, as CLASS or <retention class>=CLASS entries
+	S3Tags                []string           // key=value object tags of uploads, for bucket lifecycle rules
+	RetentionClass        string             // retention class of the new backup, e.g. daily or monthly; tagged on upload
+	ChecksumAlgorithm     string             // algorithm of the checksums recorded for new backups: sha256, blake3 or xxh64
+	FastChecksum          bool               // record BLAKE3 checksums instead of --checksum-algorithm
+	GlacierTier           string             // retrieval tier of archived S3 objects: expedited, standard or bulk
+	GlacierDays           int                // days a copy restored from the archive tier stays readable
+	GlacierTimeout        time.Duration      // longest wait for archived S3 objects to be restored
+	RPO                   time.Duration      // largest acceptable age of backed up or restored data; 0 disables
+	RTO                   time.Duration      // longest acceptable restore; 0 disables
+	EnforceSLO            bool               // exit with ExitSLOBreach when a run misses --rpo or --rto
+	TargetPin             *TargetPin         // target pinned by .infrahub-ops.yaml in the working directory; nil when absent
+	Lock                  LockConfig         // distributed lock held while create, restore and rotate-credentials change the deployment
+	Anonymize             AnonymizeConfig    // masking run after a restore, before the application services start
+	FlushAutoBackup       bool               // back up the task manager database before taskmanager flush
+	FlushAutoBackupKeep   int                // pre-flush backups kept; older ones are deleted
+	RunPrefectMigrations  bool               // migrate a restored task manager database whose schema revision differs from the target's
 }
 
 // InfrahubOps is the main application struct
 type InfrahubOps struct {
 	config                  *Configuration
 	backend                 EnvironmentBackend
-	executor                *CommandExecutor
+	executor                CommandExecutor
 	dockerBackend           *DockerBackend
 	kubernetesBackend       *KubernetesBackend
-	infrahubInternalAddress string // cached INFRAHUB_INTERNAL_ADDRESS from task-worker
+	infrahubInternalAddress string            // cached INFRAHUB_INTERNAL_ADDRESS from task-worker
+	report                  *RunReport        // active run report, set by RunWithReport
+	sloBreached             bool              // a run missed --rpo or --rto
+	throttle                *loadThrottle     // pauses the copies of an online backup while the database is busy; nil when off
+	warnings                *warningCollector // warnings logged while this instance configured logging
+	events                  *eventStream      // --events stream, written once configured
+	settings                *viper.Viper      // flag, environment and config file values of this instance
+	run                     *commandRun       // command being run, set before it starts
+	neo4jPaths              *neo4jPaths       // Neo4j locations in the database container, resolved on first use
+	neo4jAdminMem           *neo4jAdminMemory // neo4j-admin heap and page cache, resolved on first use
+	dependencies            *serviceGraph     // dependencies between the services of the deployment, resolved on first use
+	ctx                     context.Context   // cancels the operation at its next phase; nil never cancels
 }
 
 // NewInfrahubOps creates a new InfrahubOps instance
 func NewInfrahubOps() *InfrahubOps {
-	executor := NewCommandExecutor()
+	return NewInfrahubOpsWithExecutor(NewCommandExecutor())
+}
+
+// NewInfrahubOpsWithExecutor creates an InfrahubOps instance that runs every
+// host command through executor.
+func NewInfrahubOpsWithExecutor(executor CommandExecutor) *InfrahubOps {
 	config := &Configuration{
 		BackupDir:    getEnvOrDefault("BACKUP_DIR", filepath.Join(getCurrentDir(), "infrahub_backups")),
 		K8sNamespace: os.Getenv("INFRAHUB_K8S_NAMESPACE"),
 		S3: &S3Config{
 			Region: "us-east-1",
 		},
-		Backend: BackendTarball,
-		Plakar:  &PlakarConfig{},
-	}
+		Backend:            BackendTarball,
+		Plakar:             &PlakarConfig{},
+		DetectCacheTTL:     defaultDetectionCacheTTL,
+		CredentialCacheTTL: defaultCredentialCacheTTL,
+		HealthWatchRetries: defaultHealthWatchRetries,
+		K8sInstanceLabel:   defaultInstanceLabel,
+		K8sReadyTimeout:    defaultK8sReadyTimeout,
+		RemoteCleanupAge:   defaultRemoteCleanupAge,
+		CopyChunkSize:      defaultCopyChunkSize,
+		CopyParallelism:    defaultCopyParallelism,
+		ThrottleMaxPause:   defaultThrottleMaxPause,
+		GlacierTier:        defaultGlacierTier,
+		GlacierDays:        defaultGlacierDays,
+		GlacierTimeout:     defaultGlacierTimeout,
+		Lock:               LockConfig{TTL: defaultLockTTL},
+		ChecksumAlgorithm:  defaultChecksumAlgorithm,
+		Neo4jIndexReplay:   IndexReplayAuto,
+		SMTP:               SMTPConfig{TLS: SMTPStartTLS},
+	}
+	settings := viper.New()
+	settings.SetEnvPrefix("INFRAHUB")
+	settings.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+	settings.AutomaticEnv()
 	return &InfrahubOps{
 		config:   config,
 		executor: executor,
+		settings: settings,
+		warnings: newWarningCollector(),
+		events:   newEventStream(),
 	}
 }
 
+// clone deep-copies the configuration so that the copy can be changed, for
+// example by another target's settings, without touching cfg.
+func (cfg *Configuration) clone() *Configuration {
+	copied := *cfg
+	if cfg.S3 != nil {
+		s3 := *cfg.S3
+		copied.S3 = &s3
+	}
+	if cfg.Plakar != nil {
+		plakar := *cfg.Plakar
+		copied.Plakar = &plakar
+	}
+	copied.Kubernetes = cfg.Kubernetes.clone()
+	copied.CredentialMap = cfg.CredentialMap.clone()
+	if cfg.TargetPin != nil {
+		pin := *cfg.TargetPin
+		copied.TargetPin = &pin
+	}
+	copied.StopStrategies = maps.Clone(cfg.StopStrategies)
+	copied.PgExcludeTableData = slices.Clone(cfg.PgExcludeTableData)
+	copied.ArtifactsInclude = slices.Clone(cfg.ArtifactsInclude)
+	copied.ArtifactsExclude = slices.Clone(cfg.ArtifactsExclude)
+	copied.WorkPools = slices.Clone(cfg.WorkPools)
+	copied.BackupWindows = slices.Clone(cfg.BackupWindows)
+	copied.BlackoutPeriods = slices.Clone(cfg.BlackoutPeriods)
+	copied.NotifyEmailTo = slices.Clone(cfg.NotifyEmailTo)
+	copied.S3StorageClasses = slices.Clone(cfg.S3StorageClasses)
+	copied.S3Tags = slices.Clone(cfg.S3Tags)
+	return &copied
+}
+
 func (iops *InfrahubOps) Config() *Configuration {
 	return iops.config
 }
 
+// Settings returns the viper instance that command flags of this InfrahubOps
+// are bound to (INFRAHUB_<FLAG> environment variables and --config keys).
+func (iops *InfrahubOps) Settings() *viper.Viper {
+	return iops.settings
+}
+
 func (iops *InfrahubOps) getDockerBackend() *DockerBackend {
 	if iops.dockerBackend == nil {
 		iops.dockerBackend = NewDockerBackend(iops.config, iops.executor)
@@ -137,6 +299,39 @@ func (iops *InfrahubOps) ensureBackend() (EnvironmentBackend, error) {
 		return iops.backend, nil
 	}
 
+	backend, err := iops.selectBackend()
+	if err != nil {
+		return nil, err
+	}
+	if err := iops.config.TargetPin.check(backend, iops.getKubernetesBackend().currentContext); err != nil {
+		return nil, err
+	}
+	iops.backend = backend
+	return backend, nil
+}
+
+// selectBackend picks the deployment to operate on: the explicit target with
+// --no-detect, a cached detection, or the first backend that detects one.
+func (iops *InfrahubOps) selectBackend() (EnvironmentBackend, error) {
+	if iops.config.NoDetect {
+		backend, err := iops.explicitBackend()
+		if err != nil {
+			return nil, err
+		}
+		logrus.Infof("Using %s environment (%s) without detection", backend.Name(), backend.Info())
+		return backend, nil
+	}
+
+	cacheKey := iops.detectionCacheKey()
+	if cached, ok := iops.lookupDetectionCache(cacheKey); ok {
+		backend, err := iops.verifyCachedBackend(cached)
+		if err == nil {
+			logrus.Infof("Detected %s environment (%s, cached)", backend.Name(), backend.Info())
+			return backend, nil
+		}
+		logrus.Debugf("Ignoring detection cache entry: %v", err)
+	}
+
 	detectionErrors := []string{}
 	for _, backend := range iops.backendOrder() {
 		if backend == nil {
@@ -152,7 +347,7 @@ func (iops *InfrahubOps) ensureBackend() (EnvironmentBackend, error) {
 			detectionErrors = append(detectionErrors, fmt.Sprintf("%s: %v", backend.Name(), err))
 			continue
 		}
-		iops.backend = backend
+		iops.storeDetectionCache(cacheKey, backend)
 		logrus.Infof("Detected %s environment (%s)", backend.Name(), backend.Info())
 		return backend, nil
 	}
@@ -288,6 +483,16 @@ func (iops *InfrahubOps) IsServiceRunning(service string) (bool, error) {
 	return backend.IsRunning(service)
 }
 
+// RunningServices reports the running state of each service with one status
+// query against the backend.
+func (iops *InfrahubOps) RunningServices(services ...string) (map[string]bool, error) {
+	backend, err := iops.ensureBackend()
+	if err != nil {
+		return nil, err
+	}
+	return backend.RunningServices(services...)
+}
+
 // GetAllPods returns all pod names for a service (Kubernetes only, returns nil for Docker)
 func (iops *InfrahubOps) GetAllPods(service string) ([]string, error) {
 	backend, err := iops.ensureBackend()
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"fmt"
 	"os"
 	"regexp"
 	"strings"
@@ -33,14 +34,87 @@ var prefectConnectionEnvVars = []string{
 	"PREFECT_API_DATABASE_CONNECTION_URL",
 }
 
+// DatabaseCredentials holds database credentials given by the operator. Empty
+// fields are discovered from the containers.
+type DatabaseCredentials struct {
+	Neo4jUsername    string
+	Neo4jPassword    string
+	Neo4jDatabase    string
+	PostgresUsername string
+	PostgresPassword string
+	PostgresDatabase string
+}
+
+// fields pairs each credential with its flag name.
+func (c *DatabaseCredentials) fields() []struct {
+	flag  string
+	value *string
+} {
+	return []struct {
+		flag  string
+		value *string
+	}{
+		{"neo4j-user", &c.Neo4jUsername},
+		{"neo4j-password", &c.Neo4jPassword},
+		{"neo4j-database", &c.Neo4jDatabase},
+		{"pg-user", &c.PostgresUsername},
+		{"pg-password", &c.PostgresPassword},
+		{"pg-database", &c.PostgresDatabase},
+	}
+}
+
+// resolveCredentials returns the explicit credentials, reading the files of
+// the fields that were not given directly.
+func (cfg *Configuration) resolveCredentials() (DatabaseCredentials, error) {
+	resolved := cfg.Credentials
+	files := cfg.CredentialFiles
+	fileFields := files.fields()
+	for i, field := range resolved.fields() {
+		path := *fileFields[i].value
+		if *field.value != "" || path == "" {
+			continue
+		}
+		value, err := readPasswordFile(path)
+		if err != nil {
+			return resolved, fmt.Errorf("--%s-file: %w", field.flag, err)
+		}
+		*field.value = value
+	}
+	return resolved, nil
+}
+
+// apply copies the set credentials into the configuration.
+func (c DatabaseCredentials) apply(cfg *Configuration) {
+	dest := []*string{&cfg.Neo4jUsername, &cfg.Neo4jPassword, &cfg.Neo4jDatabase, &cfg.PostgresUsername, &cfg.PostgresPassword, &cfg.PostgresDatabase}
+	for i, field := range c.fields() {
+		if *field.value != "" {
+			*dest[i] = *field.value
+		}
+	}
+}
+
 // fetchDatabaseCredentials retrieves database credentials from environment or containers
 func (iops *InfrahubOps) fetchDatabaseCredentials() error {
 	if _, err := iops.ensureBackend(); err != nil {
 		return err
 	}
 
-	// Try to get credentials from environment first
+	explicit, err := iops.config.resolveCredentials()
+	if err != nil {
+		return err
+	}
+
+	// Reuse credentials cached for this target; explicit credentials win
+	if cached, ok := iops.loadCachedCredentials(); ok {
+		logrus.Debug("Using cached database credentials")
+		cached.apply(iops.config)
+		explicit.apply(iops.config)
+		return nil
+	}
+
+	// Try to get credentials from environment first; explicit credentials win
 	iops.loadCredentialsFromEnvironment()
+	explicit.apply(iops.config)
 
 	// Fetch Neo4j credentials if not fully configured
 	if !iops.hasNeo4jCredentials() {
@@ -49,15 +123,22 @@ func (iops *InfrahubOps) fetchDatabaseCredentials() error {
 		}
 		iops.applyNeo4jDefaults()
 	}
+	if explicit.Neo4jPassword == "" {
+		if err := iops.resolveNeo4jPassword(); err != nil {
+			return err
+		}
+	}
 
 	// Fetch PostgreSQL credentials if not fully configured
 	if !iops.hasPostgresCredentials() {
 		if err := iops.fetchPostgresCredentials(); err != nil {
 			logrus.Warnf("Could not fetch PostgreSQL credentials from container: %v", err)
 		}
+		explicit.apply(iops.config)
 		iops.applyPostgresDefaults()
 	}
 
+	iops.storeCachedCredentials()
 	return nil
 }
 
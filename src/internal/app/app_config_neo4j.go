@@ -0,0 +1,191 @@
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// neo4jAuthLockout is how long Neo4j blocks a user after repeated failed
+// logins (dbms.security.auth_lock_time defaults to 5s).
+var neo4jAuthLockout = 5 * time.Second
+
+// neo4jPasswordPrompt asks the operator for the Neo4j password. It returns
+// false when no terminal is attached. Tests replace it.
+var neo4jPasswordPrompt = promptTerminalPassword
+
+// neo4jSecretKeys are the Kubernetes secret keys that may hold the Neo4j
+// password. NEO4J_AUTH values have the form user/password.
+var neo4jSecretKeys = []string{"NEO4J_AUTH", "INFRAHUB_DB_PASSWORD", "neo4j-password"}
+
+// neo4jPasswordCandidate is a password to try and where it was found.
+type neo4jPasswordCandidate struct {
+	source   string
+	password string
+}
+
+// readPasswordFile reads a credential from path, dropping the trailing newline.
+func readPasswordFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read password file: %w", err)
+	}
+	password := strings.TrimRight(string(data), "\r\n")
+	if password == "" {
+		return "", fmt.Errorf("password file %s is empty", path)
+	}
+	return password, nil
+}
+
+// isNeo4jAuthFailure reports whether cypher-shell output means the
+// credentials were rejected, as opposed to the server being unreachable.
+func isNeo4jAuthFailure(output string) bool {
+	output = strings.ToLower(output)
+	for _, marker := range []string{"unauthorized", "authentication failure", "authenticationratelimit", "invalid credentials"} {
+		if strings.Contains(output, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// neo4jAuthPassword extracts the password from a NEO4J_AUTH value of the form
+// user/password.
+func neo4jAuthPassword(value string) string {
+	_, password, _ := strings.Cut(strings.TrimSpace(value), "/")
+	return password
+}
+
+// checkNeo4jPassword runs a trivial query with password. It returns false
+// without an error when Neo4j rejects the credentials, and an error when the
+// check itself could not run. A rate-limited login is retried once after the
+// lockout expires.
+func (iops *InfrahubOps) checkNeo4jPassword(password string) (bool, error) {
+	cmd := []string{"cypher-shell", "-u", iops.config.Neo4jUsername, "-p" + password, "-d", "system", "--format", "plain", "RETURN 1"}
+	for attempt := 0; ; attempt++ {
+		output, err := iops.Exec("database", cmd, nil)
+		if err == nil {
+			return true, nil
+		}
+		if attempt == 0 && strings.Contains(strings.ToLower(output+err.Error()), "authenticationratelimit") {
+			time.Sleep(neo4jAuthLockout)
+			continue
+		}
+		if isNeo4jAuthFailure(output) || isNeo4jAuthFailure(err.Error()) {
+			return false, nil
+		}
+		return false, fmt.Errorf("%w\nOutput: %v", err, output)
+	}
+}
+
+// resolveNeo4jPassword makes sure the discovered Neo4j password works before
+// a backup or restore starts. When Neo4j rejects it (typically rotated after
+// the containers started), the database container's NEO4J_AUTH, the
+// namespace's Kubernetes secrets and finally an interactive prompt are tried
+// in turn. Passwords given with --neo4j-password(-file) skip this check.
+func (iops *InfrahubOps) resolveNeo4jPassword() error {
+	ok, err := iops.checkNeo4jPassword(iops.config.Neo4jPassword)
+	if err != nil {
+		logrus.Debugf("Could not verify Neo4j credentials: %v", err)
+		return nil
+	}
+	if ok {
+		return nil
+	}
+	logrus.Warn("Neo4j rejected the discovered password; trying other sources")
+
+	tried := map[string]bool{iops.config.Neo4jPassword: true}
+	for _, candidate := range iops.neo4jPasswordCandidates() {
+		if candidate.password == "" || tried[candidate.password] {
+			continue
+		}
+		tried[candidate.password] = true
+		if ok, err := iops.checkNeo4jPassword(candidate.password); err == nil && ok {
+			logrus.Infof("Using Neo4j password from %s", candidate.source)
+			iops.config.Neo4jPassword = candidate.password
+			return nil
+		}
+	}
+
+	for attempt := 0; attempt < 3; attempt++ {
+		password, ok := neo4jPasswordPrompt(fmt.Sprintf("Neo4j password for %s: ", iops.config.Neo4jUsername))
+		if !ok {
+			break
+		}
+		if ok, err := iops.checkNeo4jPassword(password); err == nil && ok {
+			iops.config.Neo4jPassword = password
+			return nil
+		}
+		logrus.Warn("Neo4j rejected the password")
+	}
+
+	return fmt.Errorf("neo4j rejected the password for user %s; pass --neo4j-password-file or set INFRAHUB_DB_PASSWORD", iops.config.Neo4jUsername)
+}
+
+// neo4jPasswordCandidates gathers the passwords the deployment itself knows
+// about besides the one already tried.
+func (iops *InfrahubOps) neo4jPasswordCandidates() []neo4jPasswordCandidate {
+	candidates := []neo4jPasswordCandidate{}
+
+	if envOut, err := iops.Exec("database", []string{"env"}, nil); err == nil {
+		for _, line := range strings.Split(envOut, "\n") {
+			if value, ok := strings.CutPrefix(strings.TrimSpace(line), "NEO4J_AUTH="); ok {
+				candidates = append(candidates, neo4jPasswordCandidate{source: "NEO4J_AUTH in the database container", password: neo4jAuthPassword(value)})
+			}
+		}
+	}
+
+	if backend, err := iops.ensureBackend(); err == nil {
+		if k8s, ok := backend.(*KubernetesBackend); ok {
+			values, err := k8s.secretValues(neo4jSecretKeys...)
+			if err != nil {
+				logrus.Debugf("Could not read Kubernetes secrets: %v", err)
+			}
+			for _, value := range values {
+				password := value.Value
+				if value.Key == "NEO4J_AUTH" {
+					password = neo4jAuthPassword(password)
+				}
+				candidates = append(candidates, neo4jPasswordCandidate{
+					source:   fmt.Sprintf("key %s of secret %s", value.Key, value.Secret),
+					password: password,
+				})
+			}
+		}
+	}
+
+	return candidates
+}
+
+// promptTerminalPassword reads a password from stdin when it is a terminal.
+// Echo is turned off with stty where available.
+func promptTerminalPassword(prompt string) (string, bool) {
+	info, err := os.Stdin.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return "", false
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	if stty("-echo") == nil {
+		defer func() {
+			_ = stty("echo")
+			fmt.Fprintln(os.Stderr)
+		}()
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", false
+	}
+	return strings.TrimRight(line, "\r\n"), true
+}
+
+func stty(arg string) error {
+	cmd := exec.Command("stty", arg)
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
@@ -0,0 +1,95 @@
+package app
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestResolveNeo4jPassword(t *testing.T) {
+	rejected := errors.New("exit status 1")
+	authFailure := "The client is unauthorized due to authentication failure."
+
+	tests := []struct {
+		name       string
+		responses  func(f *fakeExecutor)
+		prompt     []string
+		wantErr    bool
+		wantPass   string
+		wantChecks int
+	}{
+		{
+			name:       "discovered password works",
+			wantPass:   "old",
+			wantChecks: 1,
+		},
+		{
+			name: "falls back to NEO4J_AUTH from the container",
+			responses: func(f *fakeExecutor) {
+				f.on("-pold ", authFailure, rejected)
+				f.on("database env", "NEO4J_AUTH=neo4j/rotated\nHOME=/var/lib/neo4j", nil)
+			},
+			wantPass:   "rotated",
+			wantChecks: 2,
+		},
+		{
+			name: "prompts when no source works",
+			responses: func(f *fakeExecutor) {
+				f.on("-pold ", authFailure, rejected)
+				f.on("-pwrong ", authFailure, rejected)
+			},
+			prompt:     []string{"wrong", "typed"},
+			wantPass:   "typed",
+			wantChecks: 3,
+		},
+		{
+			name: "fails without a terminal",
+			responses: func(f *fakeExecutor) {
+				f.on("-pold ", authFailure, rejected)
+			},
+			wantErr:    true,
+			wantChecks: 1,
+		},
+		{
+			name: "unreachable server is not treated as a bad password",
+			responses: func(f *fakeExecutor) {
+				f.on("-pold ", "Connection refused", rejected)
+			},
+			wantPass:   "old",
+			wantChecks: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeExecutor()
+			if tt.responses != nil {
+				tt.responses(fake)
+			}
+			iops := newFakeDockerOps(fake)
+			iops.config.Neo4jUsername = "neo4j"
+			iops.config.Neo4jPassword = "old"
+
+			prompts := tt.prompt
+			neo4jPasswordPrompt = func(string) (string, bool) {
+				if len(prompts) == 0 {
+					return "", false
+				}
+				password := prompts[0]
+				prompts = prompts[1:]
+				return password, true
+			}
+			defer func() { neo4jPasswordPrompt = promptTerminalPassword }()
+
+			err := iops.resolveNeo4jPassword()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveNeo4jPassword() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && iops.config.Neo4jPassword != tt.wantPass {
+				t.Errorf("password = %q, want %q", iops.config.Neo4jPassword, tt.wantPass)
+			}
+			if got := fake.commands("RETURN 1"); len(got) != tt.wantChecks {
+				t.Errorf("password checks = %d (%v), want %d", len(got), got, tt.wantChecks)
+			}
+		})
+	}
+}
@@ -1,6 +1,10 @@
 package app
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
 
 func newTestOps() *InfrahubOps {
 	return &InfrahubOps{config: &Configuration{}}
@@ -95,3 +99,49 @@ func TestLoadCredentialsFromEnvironmentPrefersServerName(t *testing.T) {
 		t.Errorf("password = %q, want %q (PREFECT_SERVER_* should win)", iops.config.PostgresPassword, "server-pw")
 	}
 }
+
+func TestFetchDatabaseCredentialsPrefersExplicitValues(t *testing.T) {
+	passwordFile := filepath.Join(t.TempDir(), "pg-password")
+	if err := os.WriteFile(passwordFile, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("INFRAHUB_DB_PASSWORD", "from-env")
+	t.Setenv("PREFECT_API_DATABASE_CONNECTION_URL", "postgresql+asyncpg://prefect:discovered@db/prefect")
+
+	fake := newFakeExecutor()
+	iops := newFakeDockerOps(fake)
+	iops.config.Credentials = DatabaseCredentials{
+		Neo4jUsername:    "backup",
+		Neo4jPassword:    "explicit",
+		Neo4jDatabase:    "infrahub",
+		PostgresUsername: "backup",
+		PostgresDatabase: "prefect",
+	}
+	iops.config.CredentialFiles.PostgresPassword = passwordFile
+
+	if err := iops.fetchDatabaseCredentials(); err != nil {
+		t.Fatalf("fetchDatabaseCredentials() error = %v", err)
+	}
+
+	cfg := iops.config
+	got := DatabaseCredentials{cfg.Neo4jUsername, cfg.Neo4jPassword, cfg.Neo4jDatabase, cfg.PostgresUsername, cfg.PostgresPassword, cfg.PostgresDatabase}
+	want := DatabaseCredentials{"backup", "explicit", "infrahub", "backup", "from-file", "prefect"}
+	if got != want {
+		t.Errorf("credentials = %+v, want %+v", got, want)
+	}
+	if len(fake.calls) != 0 {
+		t.Errorf("container commands were run: %v", fake.calls)
+	}
+}
+
+func TestResolveCredentialsMissingFile(t *testing.T) {
+	cfg := &Configuration{CredentialFiles: DatabaseCredentials{Neo4jPassword: filepath.Join(t.TempDir(), "missing")}}
+	if _, err := cfg.resolveCredentials(); err == nil {
+		t.Fatal("resolveCredentials() error = nil, want missing file error")
+	}
+
+	cfg.Credentials.Neo4jPassword = "given"
+	if _, err := cfg.resolveCredentials(); err != nil {
+		t.Fatalf("resolveCredentials() error = %v, want the direct value to win over the file", err)
+	}
+}
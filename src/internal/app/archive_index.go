@@ -0,0 +1,335 @@
+package app
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// Format 2 archives are gzip-compressed tarballs in which every tar entry is
+// compressed as its own gzip member, followed by an index of the entries and
+// a fixed-size footer:
+//
+//	member: tar header and data of the first entry
+//	member: tar header and data of the next entry
+//	...
+//	member: tar header and data of backup/archive_index.json, end of tar
+//	member: footer, an empty member whose extra field locates the index
+//
+// Concatenated gzip members form a single gzip stream, so format 2 archives
+// remain plain .tar.gz files for tar, gzip and earlier versions of this tool.
+// Readers with random access fetch the footer, then the index, then only the
+// members they need, for example with S3 ranged GETs.
+
+// Archive layout versions recorded in backup metadata.
+const (
+	ArchiveFormatV1 = 1 // a single gzip member
+	ArchiveFormatV2 = 2 // one gzip member per entry, with an index footer
+)
+
+const (
+	archiveIndexFilename = "archive_index.json"
+
+	// archiveFooterSize is the size of the footer member: a gzip header with
+	// a 20-byte extra field, an empty stored deflate block and the trailer.
+	archiveFooterSize = 45
+)
+
+// errNoArchiveIndex is returned for archives without an index footer, such
+// as format 1 archives.
+var errNoArchiveIndex = errors.New("archive has no index")
+
+// ArchiveIndex lists the entries of a format 2 archive.
+type ArchiveIndex struct {
+	FormatVersion int                 `json:"format_version"`
+	Entries       []ArchiveIndexEntry `json:"entries"`
+}
+
+// ArchiveIndexEntry locates one tar entry in a format 2 archive.
+type ArchiveIndexEntry struct {
+	Name    string    `json:"name"`
+	Type    string    `json:"type"` // file, dir or link
+	Size    int64     `json:"size"`
+	Mode    int64     `json:"mode"`
+	ModTime time.Time `json:"mod_time"`
+	Offset  int64     `json:"offset"` // of the gzip member holding the entry
+	Length  int64     `json:"length"` // compressed length of that member
+}
+
+// entry returns the index entry named name.
+func (idx *ArchiveIndex) entry(name string) (ArchiveIndexEntry, bool) {
+	name = path.Clean(filepath.ToSlash(name))
+	for _, entry := range idx.Entries {
+		if path.Clean(entry.Name) == name {
+			return entry, true
+		}
+	}
+	return ArchiveIndexEntry{}, false
+}
+
+// gzipMembers compresses the sections of a stream between calls to cut into
+// separate gzip members.
+type gzipMembers struct {
+	w  io.Writer
+	zw *gzip.Writer
+}
+
+func (g *gzipMembers) Write(p []byte) (int, error) {
+	if g.zw == nil {
+		g.zw = gzip.NewWriter(g.w)
+	}
+	return g.zw.Write(p)
+}
+
+// cut ends the current member; the next write starts a new one.
+func (g *gzipMembers) cut() error {
+	if g.zw == nil {
+		return nil
+	}
+	err := g.zw.Close()
+	g.zw = nil
+	return err
+}
+
+// writeIndexedTar writes sourceDir/pathInTar to archivePath as a format 2
+// archive.
+func writeIndexedTar(archivePath, sourceDir, pathInTar string, stats *archiveStats) error {
+	file, err := os.Create(archivePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	t := newIndexedTar(file, pathInTar, stats)
+	if err := writeTarEntries(t.tw, sourceDir, pathInTar, t.entry); err != nil {
+		return err
+	}
+	if err := t.close(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// indexedTar writes a format 2 archive to a stream, recording each entry in
+// the index written by close.
+type indexedTar struct {
+	tw        *tar.Writer
+	members   *gzipMembers
+	stats     *archiveStats
+	index     *ArchiveIndex
+	pathInTar string
+}
+
+func newIndexedTar(w io.Writer, pathInTar string, stats *archiveStats) *indexedTar {
+	stats.compressed = &countingWriter{w: w}
+	members := &gzipMembers{w: stats.compressed}
+	stats.raw = &countingWriter{w: members}
+	return &indexedTar{
+		tw:        tar.NewWriter(stats.raw),
+		members:   members,
+		stats:     stats,
+		index:     &ArchiveIndex{FormatVersion: ArchiveFormatV2},
+		pathInTar: pathInTar,
+	}
+}
+
+// entry starts the gzip member of header and adds it to the index; the
+// previous entry must be flushed and header is written next.
+func (t *indexedTar) entry(header *tar.Header) error {
+	if err := t.members.cut(); err != nil {
+		return err
+	}
+	name := header.Name
+	if target := header.PAXRecords[paxStreamTarget]; target != "" {
+		name = target // stats count chunks as the dump they belong to
+	}
+	t.stats.entry(name)
+	entryType := "file"
+	switch header.Typeflag {
+	case tar.TypeDir:
+		entryType = "dir"
+	case tar.TypeLink:
+		entryType = "link"
+	}
+	t.index.Entries = append(t.index.Entries, ArchiveIndexEntry{
+		Name:    header.Name,
+		Type:    entryType,
+		Size:    header.Size,
+		Mode:    header.Mode,
+		ModTime: header.ModTime.UTC(),
+		Offset:  t.stats.compressed.n,
+	})
+	return nil
+}
+
+// add writes header followed by the header.Size bytes of r.
+func (t *indexedTar) add(header *tar.Header, r io.Reader) error {
+	if err := t.tw.Flush(); err != nil {
+		return err
+	}
+	if err := t.entry(header); err != nil {
+		return err
+	}
+	if err := t.tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := io.Copy(t.tw, r)
+	return err
+}
+
+// close writes the index, the end of the tar and the footer.
+func (t *indexedTar) close() error {
+	if err := t.tw.Flush(); err != nil {
+		return err
+	}
+	if err := t.members.cut(); err != nil {
+		return err
+	}
+
+	indexOffset := t.stats.compressed.n
+	for i := range t.index.Entries {
+		end := indexOffset
+		if i+1 < len(t.index.Entries) {
+			end = t.index.Entries[i+1].Offset
+		}
+		t.index.Entries[i].Length = end - t.index.Entries[i].Offset
+	}
+	data, err := json.Marshal(t.index)
+	if err != nil {
+		return err
+	}
+	name := path.Join(filepath.ToSlash(t.pathInTar), archiveIndexFilename)
+	t.stats.entry(name)
+	header := &tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(data)), ModTime: time.Now()}
+	if err := t.tw.WriteHeader(header); err != nil {
+		return err
+	}
+	if _, err := t.tw.Write(data); err != nil {
+		return err
+	}
+	if err := t.tw.Close(); err != nil {
+		return err
+	}
+	if err := t.members.cut(); err != nil {
+		return err
+	}
+
+	if _, err := t.stats.compressed.Write(archiveFooter(indexOffset, t.stats.compressed.n-indexOffset)); err != nil {
+		return err
+	}
+	t.stats.flush()
+	return nil
+}
+
+// archiveFooter returns the footer member locating the index member. Its
+// extra field holds an "IX" subfield with the offset and length of the index
+// member as little-endian 64-bit integers.
+func archiveFooter(offset, length int64) []byte {
+	footer := []byte{
+		0x1f, 0x8b, 8, 0x04, // gzip magic, deflate, FEXTRA
+		0, 0, 0, 0, 0, 0xff, // no mtime, no flags, unknown OS
+		20, 0, // extra field length
+		'I', 'X', 16, 0, // subfield ID and length
+	}
+	footer = binary.LittleEndian.AppendUint64(footer, uint64(offset))
+	footer = binary.LittleEndian.AppendUint64(footer, uint64(length))
+	// Empty final stored block, then the CRC-32 and size of no data.
+	return append(footer, 0x01, 0, 0, 0xff, 0xff, 0, 0, 0, 0, 0, 0, 0, 0)
+}
+
+// parseArchiveFooter returns the index location held by footer.
+func parseArchiveFooter(footer []byte) (offset, length int64, ok bool) {
+	template := archiveFooter(0, 0)
+	if len(footer) != len(template) || !bytes.Equal(footer[:16], template[:16]) || !bytes.Equal(footer[32:], template[32:]) {
+		return 0, 0, false
+	}
+	offset = int64(binary.LittleEndian.Uint64(footer[16:24]))
+	length = int64(binary.LittleEndian.Uint64(footer[24:32]))
+	return offset, length, true
+}
+
+// readArchiveIndex reads the index of the format 2 archive of the given size
+// in r, reading only the footer and the index member.
+func readArchiveIndex(r io.ReaderAt, size int64) (*ArchiveIndex, error) {
+	if size < archiveFooterSize {
+		return nil, errNoArchiveIndex
+	}
+	footer := make([]byte, archiveFooterSize)
+	if _, err := r.ReadAt(footer, size-archiveFooterSize); err != nil {
+		return nil, fmt.Errorf("failed to read archive footer: %w", err)
+	}
+	offset, length, ok := parseArchiveFooter(footer)
+	if !ok || offset < 0 || length <= 0 || offset+length > size-archiveFooterSize {
+		return nil, errNoArchiveIndex
+	}
+
+	entry := ArchiveIndexEntry{Name: archiveIndexFilename, Offset: offset, Length: length}
+	member, err := openIndexedMember(r, entry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive index: %w", err)
+	}
+	defer member.Close()
+	var index ArchiveIndex
+	if err := json.NewDecoder(member).Decode(&index); err != nil {
+		return nil, fmt.Errorf("failed to parse archive index: %w", err)
+	}
+	if index.FormatVersion != ArchiveFormatV2 {
+		return nil, fmt.Errorf("unsupported archive index format %d", index.FormatVersion)
+	}
+	return &index, nil
+}
+
+// indexedMember is the content of one tar entry read from its gzip member.
+type indexedMember struct {
+	io.Reader
+	zr *gzip.Reader
+}
+
+func (m *indexedMember) Close() error {
+	return m.zr.Close()
+}
+
+// openIndexedMember returns the content of entry, decompressing only its
+// gzip member. The member must start with a tar entry whose base name
+// matches, which guards against an index that does not fit the archive.
+func openIndexedMember(r io.ReaderAt, entry ArchiveIndexEntry) (io.ReadCloser, error) {
+	zr, err := gzip.NewReader(io.NewSectionReader(r, entry.Offset, entry.Length))
+	if err != nil {
+		return nil, err
+	}
+	zr.Multistream(false)
+	tr := tar.NewReader(zr)
+	header, err := tr.Next()
+	if err != nil {
+		zr.Close()
+		return nil, err
+	}
+	if path.Base(path.Clean(header.Name)) != path.Base(path.Clean(entry.Name)) {
+		zr.Close()
+		return nil, fmt.Errorf("archive index points %s at %s", entry.Name, header.Name)
+	}
+	return &indexedMember{Reader: tr, zr: zr}, nil
+}
+
+// readIndexedMember returns the content of member using the archive index.
+func readIndexedMember(r io.ReaderAt, index *ArchiveIndex, member string) ([]byte, error) {
+	entry, ok := index.entry(member)
+	if !ok || entry.Type != "file" {
+		return nil, fmt.Errorf("%s not found in archive", member)
+	}
+	content, err := openIndexedMember(r, entry)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+	return io.ReadAll(content)
+}
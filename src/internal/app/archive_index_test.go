@@ -0,0 +1,143 @@
+package app
+
+import (
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// countingReaderAt records how many bytes are read from a file.
+type countingReaderAt struct {
+	file *os.File
+	read int64
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := c.file.ReadAt(p, off)
+	c.read += int64(n)
+	return n, err
+}
+
+func writeIndexTestSource(t *testing.T) (string, []byte) {
+	t.Helper()
+	dir := t.TempDir()
+	dump := make([]byte, 1<<20)
+	if _, err := rand.Read(dump); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, dir, "backup/database/neo4j.dump", string(dump))
+	writeTestFile(t, dir, "backup/prefect.dump", strings.Repeat("flow", 1000))
+	writeTestFile(t, dir, "backup/"+backupMetadataFilename, `{"backup_id": "indexed"}`)
+	return dir, dump
+}
+
+func TestIndexedArchive(t *testing.T) {
+	sourceDir, dump := writeIndexTestSource(t)
+	archive, err := defaultArchivePipeline(false, false).Write(sourceDir, "backup/", filepath.Join(t.TempDir(), "indexed"), ArchiveOptions{})
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	file, err := os.Open(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	info, _ := file.Stat()
+
+	reader := &countingReaderAt{file: file}
+	index, err := readArchiveIndex(reader, info.Size())
+	if err != nil {
+		t.Fatalf("readArchiveIndex() error = %v", err)
+	}
+	metadata, err := readIndexedMember(reader, index, "backup/"+backupMetadataFilename)
+	if err != nil || string(metadata) != `{"backup_id": "indexed"}` {
+		t.Fatalf("readIndexedMember() = %q, %v", metadata, err)
+	}
+	if reader.read > info.Size()/10 {
+		t.Errorf("read %d of %d bytes to get the metadata", reader.read, info.Size())
+	}
+
+	got, err := readIndexedMember(reader, index, "backup/database/neo4j.dump")
+	if err != nil || string(got) != string(dump) {
+		t.Errorf("readIndexedMember(neo4j.dump) = %d bytes, %v", len(got), err)
+	}
+	if entry, ok := index.entry("backup/prefect.dump"); !ok || entry.Type != "file" || entry.Size != 4000 {
+		t.Errorf("index entry = %+v, %t", entry, ok)
+	}
+	if _, err := readIndexedMember(reader, index, "backup/database"); err == nil {
+		t.Error("readIndexedMember() of a directory succeeded")
+	}
+
+	// The archive is still a plain .tar.gz for stream readers.
+	destDir := t.TempDir()
+	if _, err := extractArchive(archive, destDir); err != nil {
+		t.Fatalf("extractArchive() error = %v", err)
+	}
+	extracted, err := os.ReadFile(filepath.Join(destDir, "backup/database/neo4j.dump"))
+	if err != nil || string(extracted) != string(dump) {
+		t.Errorf("extracted neo4j.dump = %d bytes, %v", len(extracted), err)
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "backup", archiveIndexFilename)); err != nil {
+		t.Errorf("index not extracted: %v", err)
+	}
+}
+
+func TestReadArchiveMemberWithoutIndex(t *testing.T) {
+	sourceDir, _ := writeIndexTestSource(t)
+	pipeline := defaultArchivePipeline(false, false)
+	pipeline.Format = ArchiveFormatV1
+	archive, err := pipeline.Write(sourceDir, "backup/", filepath.Join(t.TempDir(), "plain"), ArchiveOptions{})
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	file, err := os.Open(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	info, _ := file.Stat()
+	if _, err := readArchiveIndex(file, info.Size()); !errors.Is(err, errNoArchiveIndex) {
+		t.Errorf("readArchiveIndex() error = %v, want errNoArchiveIndex", err)
+	}
+	if data, err := readArchiveMember(archive, "backup/"+backupMetadataFilename); err != nil || !strings.Contains(string(data), "indexed") {
+		t.Errorf("readArchiveMember() = %q, %v", data, err)
+	}
+}
+
+func TestParseArchiveFooter(t *testing.T) {
+	footer := archiveFooter(1234, 56)
+	if len(footer) != archiveFooterSize {
+		t.Fatalf("footer is %d bytes, want %d", len(footer), archiveFooterSize)
+	}
+	tests := []struct {
+		name   string
+		footer []byte
+		wantOK bool
+	}{
+		{"valid", footer, true},
+		{"truncated", footer[1:], false},
+		{"other subfield", append(append([]byte{}, footer[:12]...), append([]byte{'X', 'X'}, footer[14:]...)...), false},
+		{"not gzip", make([]byte, archiveFooterSize), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offset, length, ok := parseArchiveFooter(tt.footer)
+			if ok != tt.wantOK || (ok && (offset != 1234 || length != 56)) {
+				t.Errorf("parseArchiveFooter() = %d, %d, %t", offset, length, ok)
+			}
+		})
+	}
+}
+
+func TestArchivePipelineValidateFormat(t *testing.T) {
+	if err := (ArchivePipeline{Format: 3, Compression: "gzip"}).Validate(); err == nil || !strings.Contains(err.Error(), "unknown archive format") {
+		t.Errorf("Validate() format 3 error = %v", err)
+	}
+	if info := defaultArchivePipeline(false, false).Info(); info.Format != ArchiveFormatV2 {
+		t.Errorf("Info().Format = %d, want %d", info.Format, ArchiveFormatV2)
+	}
+}
@@ -0,0 +1,146 @@
+package app
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+)
+
+// sparseBlockSize is the granularity at which extracted files get holes.
+const sparseBlockSize = 4096
+
+var zeroBlock = make([]byte, sparseBlockSize)
+
+// fileIdentity identifies a file on disk, so that its hard links can be
+// recognised while archiving.
+type fileIdentity struct {
+	dev uint64
+	ino uint64
+}
+
+// fileDiskInfo is what the archive layer needs to know about a file on disk.
+type fileDiskInfo struct {
+	id        fileIdentity
+	links     uint64
+	allocated int64
+}
+
+// hardLinkTracker turns the second and later paths of a hard-linked file
+// into tar.TypeLink entries pointing at the first one.
+type hardLinkTracker map[fileIdentity]string
+
+// observe updates header for info. Sparse files are archived with their holes
+// as zeros, which gzip compresses to almost nothing, since archive/tar cannot
+// write sparse entries; extraction recreates the holes.
+func (links hardLinkTracker) observe(header *tar.Header, info os.FileInfo) {
+	if !info.Mode().IsRegular() {
+		return
+	}
+	disk, ok := diskInfo(info)
+	if !ok {
+		return
+	}
+	if disk.links > 1 {
+		if first, seen := links[disk.id]; seen {
+			header.Typeflag = tar.TypeLink
+			header.Linkname = first
+			header.Size = 0
+			return
+		}
+		links[disk.id] = header.Name
+	}
+	if disk.allocated < info.Size() {
+		logrus.Debugf("Archiving sparse file %s (%s allocated of %s)", header.Name, formatBytes(disk.allocated), formatBytes(info.Size()))
+	}
+}
+
+// extractTarEntry extracts header, read from tr, to target. linkName is the
+// path of a hard link target relative to destDir.
+func extractTarEntry(tr *tar.Reader, header *tar.Header, target, linkName, destDir string) error {
+	switch header.Typeflag {
+	case tar.TypeDir:
+		return os.MkdirAll(target, 0755)
+	case tar.TypeReg, tar.TypeGNUSparse:
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode))
+		if err != nil {
+			return err
+		}
+		if err := copySparse(f, tr); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	case tar.TypeLink:
+		source := filepath.Clean(filepath.Join(destDir, linkName))
+		if !isPathWithinDirectory(source, destDir) {
+			return fmt.Errorf("illegal link target in archive: %s -> %s (attempts to escape destination directory)", header.Name, header.Linkname)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return os.Link(source, target)
+	}
+	return nil
+}
+
+// copySparse copies r to f, seeking over all-zero blocks instead of writing
+// them so that they become holes, and sets the size of f to what was read.
+func copySparse(f *os.File, r io.Reader) error {
+	buf := make([]byte, 64*sparseBlockSize)
+	var size int64
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			if writeErr := writeSparse(f, buf[:n]); writeErr != nil {
+				return writeErr
+			}
+			size += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return f.Truncate(size)
+}
+
+// writeSparse writes data at the offset of f, one write per run of non-zero
+// blocks.
+func writeSparse(f *os.File, data []byte) error {
+	start := 0
+	for start < len(data) {
+		end := min(start+sparseBlockSize, len(data))
+		if bytes.Equal(data[start:end], zeroBlock[:end-start]) {
+			if _, err := f.Seek(int64(end-start), io.SeekCurrent); err != nil {
+				return err
+			}
+			start = end
+			continue
+		}
+		for end < len(data) {
+			next := min(end+sparseBlockSize, len(data))
+			if bytes.Equal(data[end:next], zeroBlock[:next-end]) {
+				break
+			}
+			end = next
+		}
+		if _, err := f.Write(data[start:end]); err != nil {
+			return err
+		}
+		start = end
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package app
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestArchiveKeepsHardLinksAndHoles(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hard links and sparse files are not tracked on Windows")
+	}
+	sourceDir := t.TempDir()
+	store := filepath.Join(sourceDir, "neo4j", "store")
+	if err := os.MkdirAll(store, 0755); err != nil {
+		t.Fatal(err)
+	}
+	const size = 4 << 20
+	sparse, err := os.Create(filepath.Join(store, "nodes.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sparse.WriteString("header"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sparse.WriteAt([]byte("footer"), size-6); err != nil {
+		t.Fatal(err)
+	}
+	sparse.Close()
+	if err := os.Link(filepath.Join(store, "nodes.db"), filepath.Join(store, "nodes.db.link")); err != nil {
+		t.Skipf("hard links not supported: %v", err)
+	}
+
+	var archive bytes.Buffer
+	if err := writeTarObserved(&archive, sourceDir, "neo4j", nil); err != nil {
+		t.Fatalf("writeTarObserved() error = %v", err)
+	}
+	tr := tar.NewReader(bytes.NewReader(archive.Bytes()))
+	links := 0
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header.Typeflag == tar.TypeLink {
+			links++
+			if header.Size != 0 {
+				t.Errorf("link %s has size %d", header.Name, header.Size)
+			}
+		}
+	}
+	if links != 1 {
+		t.Fatalf("archive has %d link entries, want 1", links)
+	}
+
+	destDir := t.TempDir()
+	if err := extractTar(bytes.NewReader(archive.Bytes()), destDir); err != nil {
+		t.Fatalf("extractTar() error = %v", err)
+	}
+	first, err := os.Stat(filepath.Join(destDir, "neo4j", "store", "nodes.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := os.Stat(filepath.Join(destDir, "neo4j", "store", "nodes.db.link"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(first, second) {
+		t.Error("extracted hard links are separate files")
+	}
+	if first.Size() != size {
+		t.Errorf("extracted size = %d, want %d", first.Size(), size)
+	}
+	data, err := os.ReadFile(filepath.Join(destDir, "neo4j", "store", "nodes.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte("header")) || !bytes.HasSuffix(data, []byte("footer")) {
+		t.Error("extracted content differs from the original")
+	}
+	if source, _ := os.Stat(filepath.Join(store, "nodes.db")); source != nil {
+		sourceDisk, _ := diskInfo(source)
+		extractedDisk, _ := diskInfo(first)
+		if sourceDisk.allocated < size && extractedDisk.allocated >= size {
+			t.Errorf("extracted file allocates %d bytes, the sparse original %d", extractedDisk.allocated, sourceDisk.allocated)
+		}
+	}
+}
+
+func TestExtractUncompressedTar_HardLinks(t *testing.T) {
+	tests := []struct {
+		name     string
+		linkname string
+		strip    int
+		wantErr  bool
+	}{
+		{name: "stripped", linkname: "infrahubops/data.txt", strip: 1},
+		{name: "not stripped", linkname: "infrahubops/data.txt"},
+		{name: "escaping", linkname: "infrahubops/../../etc/passwd", strip: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			tarPath := filepath.Join(tmpDir, "test.tar")
+			writeTarFile(t, tarPath, []struct{ name, content string }{
+				{"infrahubops/data.txt", "content"},
+			})
+			appendTarLink(t, tarPath, "infrahubops/link.txt", tt.linkname)
+			destDir := filepath.Join(tmpDir, "output")
+
+			err := extractUncompressedTar(tarPath, destDir, tt.strip)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error for escaping link target, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("extractUncompressedTar failed: %v", err)
+			}
+			dir := destDir
+			if tt.strip == 0 {
+				dir = filepath.Join(destDir, "infrahubops")
+			}
+			data, err := os.ReadFile(filepath.Join(dir, "link.txt"))
+			if err != nil || string(data) != "content" {
+				t.Errorf("link.txt = %q, %v; want %q", data, err, "content")
+			}
+		})
+	}
+}
+
+// appendTarLink rewrites the tar at tarPath with a hard link entry added.
+func appendTarLink(t *testing.T, tarPath, name, linkname string) {
+	t.Helper()
+	original, err := os.ReadFile(tarPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	tw := tar.NewWriter(&out)
+	tr := tar.NewReader(bytes.NewReader(original))
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeLink, Linkname: linkname}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(tarPath, out.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCopySparse(t *testing.T) {
+	block := func(b byte, n int) []byte { return bytes.Repeat([]byte{b}, n) }
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty"},
+		{name: "zeros only", data: block(0, 3*sparseBlockSize)},
+		{name: "trailing hole", data: append(block('a', 10), block(0, 2*sparseBlockSize)...)},
+		{name: "hole between data", data: append(append(block('a', sparseBlockSize), block(0, 5*sparseBlockSize)...), block('b', 100)...)},
+		{name: "beyond one buffer", data: append(block(0, 70*sparseBlockSize), block('c', sparseBlockSize+1)...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "file")
+			f, err := os.Create(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := copySparse(f, bytes.NewReader(tt.data)); err != nil {
+				t.Fatalf("copySparse() error = %v", err)
+			}
+			f.Close()
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tt.data) {
+				t.Errorf("copied %d bytes differing from the %d written", len(got), len(tt.data))
+			}
+		})
+	}
+}
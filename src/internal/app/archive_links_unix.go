@@ -0,0 +1,20 @@
+//go:build !windows
+
+package app
+
+import (
+	"os"
+	"syscall"
+)
+
+func diskInfo(info os.FileInfo) (fileDiskInfo, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileDiskInfo{}, false
+	}
+	return fileDiskInfo{
+		id:        fileIdentity{dev: uint64(stat.Dev), ino: uint64(stat.Ino)},
+		links:     uint64(stat.Nlink),
+		allocated: int64(stat.Blocks) * 512,
+	}, true
+}
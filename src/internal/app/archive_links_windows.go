@@ -0,0 +1,11 @@
+//go:build windows
+
+package app
+
+import "os"
+
+// diskInfo is not available on Windows: hard links and sparse files are
+// archived as regular files.
+func diskInfo(os.FileInfo) (fileDiskInfo, bool) {
+	return fileDiskInfo{}, false
+}
@@ -0,0 +1,446 @@
+package app
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// The tarball backend writes archives through a pipeline:
+//
+//	tar -> compressor -> filters... -> sinks...
+//
+// The compressor wraps the tar stream. Filters transform the finished archive
+// file (ECIES needs the plaintext size up front, so filters work on files
+// rather than streams). Sinks deliver the final file. Each stage is looked up
+// by name in a registry, so new compressors, filters and sinks only need a
+// Register call.
+
+// ArchiveOptions carries the settings stages may need.
+type ArchiveOptions struct {
+	EncryptKey string // public key file for the ecies filter; empty uses the built-in key
+	DecryptKey string // private key file to reverse the ecies filter
+}
+
+// ArchiveCompressor wraps the tar stream.
+type ArchiveCompressor struct {
+	Name      string
+	Extension string // appended after .tar
+	Magic     []byte // leading bytes identifying compressed output
+	NewWriter func(w io.Writer) (io.WriteCloser, error)
+	NewReader func(r io.Reader) (io.ReadCloser, error)
+}
+
+// ArchiveFilter transforms a finished archive file.
+type ArchiveFilter struct {
+	Name      string
+	Extension string // appended to the archive file name
+	Magic     []byte // leading bytes identifying filtered output
+	Apply     func(src, dst string, opts ArchiveOptions) error
+	Reverse   func(src, dst string, opts ArchiveOptions) error
+}
+
+// ArchiveSink delivers the final archive file and returns its location. A
+// sink that returns an empty location left the file where it was.
+type ArchiveSink struct {
+	Name  string
+	Store func(iops *InfrahubOps, path string) (string, error)
+}
+
+var (
+	archiveCompressors = map[string]*ArchiveCompressor{}
+	archiveFilters     = map[string]*ArchiveFilter{}
+	archiveSinks       = map[string]*ArchiveSink{}
+)
+
+// RegisterArchiveCompressor makes a compressor available to pipelines.
+func RegisterArchiveCompressor(c *ArchiveCompressor) {
+	archiveCompressors[c.Name] = c
+}
+
+// RegisterArchiveFilter makes a filter available to pipelines.
+func RegisterArchiveFilter(f *ArchiveFilter) {
+	archiveFilters[f.Name] = f
+}
+
+// RegisterArchiveSink makes a sink available to pipelines.
+func RegisterArchiveSink(s *ArchiveSink) {
+	archiveSinks[s.Name] = s
+}
+
+func init() {
+	RegisterArchiveCompressor(&ArchiveCompressor{
+		Name:      "gzip",
+		Extension: ".gz",
+		Magic:     []byte{0x1f, 0x8b},
+		NewWriter: func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil },
+		NewReader: func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+	})
+	RegisterArchiveFilter(&ArchiveFilter{
+		Name:      "ecies",
+		Extension: ".enc",
+		Magic:     []byte{eciesVersion},
+		Apply: func(src, dst string, opts ArchiveOptions) error {
+			pubKey, err := loadEncryptionKey(opts.EncryptKey)
+			if err != nil {
+				return fmt.Errorf("failed to load encryption key: %w", err)
+			}
+			logrus.Info("Encrypting backup archive...")
+			return EncryptFile(src, dst, pubKey)
+		},
+		Reverse: func(src, dst string, opts ArchiveOptions) error {
+			if opts.DecryptKey == "" {
+				return fmt.Errorf("backup file is encrypted; provide --decrypt-key to decrypt")
+			}
+			privKey, err := LoadPrivateKeyFromFile(opts.DecryptKey)
+			if err != nil {
+				return fmt.Errorf("failed to load decryption key: %w", err)
+			}
+			logrus.Info("Decrypting backup archive...")
+			return DecryptFile(src, dst, privKey)
+		},
+	})
+	RegisterArchiveSink(&ArchiveSink{
+		Name:  "file",
+		Store: func(iops *InfrahubOps, path string) (string, error) { return "", nil },
+	})
+	RegisterArchiveSink(&ArchiveSink{
+		Name: "s3",
+		Store: func(iops *InfrahubOps, path string) (string, error) {
+			return iops.uploadBackupToS3(path)
+		},
+	})
+}
+
+// ArchivePipeline names the stages used to write an archive.
+type ArchivePipeline struct {
+	Format      int // archive layout, ArchiveFormatV1 when unset
+	Compression string
+	Filters     []string
+	Sinks       []string
+}
+
+// ArchivePipelineInfo is the pipeline recorded in backup metadata so restores
+// can check they reversed the same stages.
+type ArchivePipelineInfo struct {
+	Format      int      `json:"format,omitempty"`
+	Compression string   `json:"compression"`
+	Filters     []string `json:"filters,omitempty"`
+}
+
+// defaultArchivePipeline returns the pipeline for the create flags.
+func defaultArchivePipeline(encrypt, s3Upload bool) ArchivePipeline {
+	pipeline := ArchivePipeline{Format: ArchiveFormatV2, Compression: "gzip", Sinks: []string{"file"}}
+	if encrypt {
+		pipeline.Filters = append(pipeline.Filters, "ecies")
+	}
+	if s3Upload {
+		pipeline.Sinks = append(pipeline.Sinks, "s3")
+	}
+	return pipeline
+}
+
+// Validate checks that every stage is registered.
+func (p ArchivePipeline) Validate() error {
+	if _, ok := archiveCompressors[p.Compression]; !ok {
+		return fmt.Errorf("unknown archive compression %q (available: %s)", p.Compression, strings.Join(registeredNames(archiveCompressors), ", "))
+	}
+	switch p.Format {
+	case 0, ArchiveFormatV1:
+	case ArchiveFormatV2:
+		if p.Compression != "gzip" {
+			return fmt.Errorf("archive format %d requires gzip compression", p.Format)
+		}
+	default:
+		return fmt.Errorf("unknown archive format %d", p.Format)
+	}
+	for _, name := range p.Filters {
+		if _, ok := archiveFilters[name]; !ok {
+			return fmt.Errorf("unknown archive filter %q (available: %s)", name, strings.Join(registeredNames(archiveFilters), ", "))
+		}
+	}
+	for _, name := range p.Sinks {
+		if _, ok := archiveSinks[name]; !ok {
+			return fmt.Errorf("unknown archive sink %q (available: %s)", name, strings.Join(registeredNames(archiveSinks), ", "))
+		}
+	}
+	return nil
+}
+
+// Info returns the pipeline description stored in metadata.
+func (p ArchivePipeline) Info() *ArchivePipelineInfo {
+	return &ArchivePipelineInfo{Format: p.Format, Compression: p.Compression, Filters: append([]string(nil), p.Filters...)}
+}
+
+// ArchivePath returns the final file name for basePath once every stage has
+// added its extension, for example base.tar.gz.enc.
+func (p ArchivePipeline) ArchivePath(basePath string) string {
+	path := basePath + ".tar"
+	if c, ok := archiveCompressors[p.Compression]; ok {
+		path += c.Extension
+	}
+	for _, name := range p.Filters {
+		if f, ok := archiveFilters[name]; ok {
+			path += f.Extension
+		}
+	}
+	return path
+}
+
+// Write archives sourceDir/pathInTar to basePath plus the stage extensions
+// and returns the path of the final file. Intermediate files are removed.
+func (p ArchivePipeline) Write(sourceDir, pathInTar, basePath string, opts ArchiveOptions) (string, error) {
+	path, _, err := p.WriteWithStats(sourceDir, pathInTar, basePath, opts)
+	return path, err
+}
+
+// WriteWithStats is Write, also returning the size of each backup component
+// before and after compression.
+func (p ArchivePipeline) WriteWithStats(sourceDir, pathInTar, basePath string, opts ArchiveOptions) (string, []ComponentStats, error) {
+	if err := p.Validate(); err != nil {
+		return "", nil, err
+	}
+	compressor := archiveCompressors[p.Compression]
+
+	path := basePath + ".tar" + compressor.Extension
+	stats := newArchiveStats(pathInTar)
+	write := func() error { return writeCompressedTar(path, sourceDir, pathInTar, compressor, stats) }
+	if p.Format == ArchiveFormatV2 {
+		write = func() error { return writeIndexedTar(path, sourceDir, pathInTar, stats) }
+	}
+	if err := write(); err != nil {
+		os.Remove(path)
+		return "", nil, fmt.Errorf("failed to create archive: %w", err)
+	}
+
+	for _, name := range p.Filters {
+		filter := archiveFilters[name]
+		next := path + filter.Extension
+		if err := filter.Apply(path, next, opts); err != nil {
+			os.Remove(path)
+			return "", nil, fmt.Errorf("archive filter %s failed: %w", name, err)
+		}
+		if err := os.Remove(path); err != nil {
+			logrus.Warnf("Failed to remove intermediate archive %s: %v", path, err)
+		}
+		path = next
+	}
+	return path, stats.result(), nil
+}
+
+// Deliver hands the final archive to every sink and returns the locations
+// reported by sinks other than file. A split archive is delivered part by
+// part, then its manifest, whose location is returned.
+func (p ArchivePipeline) Deliver(iops *InfrahubOps, path string) (map[string]string, error) {
+	locations := map[string]string{}
+	files, err := splitArchiveFiles(path)
+	if err != nil {
+		return locations, err
+	}
+	for _, name := range p.Sinks {
+		sink, ok := archiveSinks[name]
+		if !ok {
+			return locations, fmt.Errorf("unknown archive sink %q", name)
+		}
+		for _, file := range files {
+			location, err := sink.Store(iops, file)
+			if err != nil {
+				return locations, fmt.Errorf("archive sink %s failed: %w", name, err)
+			}
+			if location != "" {
+				locations[name] = location
+			}
+		}
+	}
+	return locations, nil
+}
+
+func writeCompressedTar(path, sourceDir, pathInTar string, compressor *ArchiveCompressor, stats *archiveStats) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	stats.compressed = &countingWriter{w: file}
+	cw, err := compressor.NewWriter(stats.compressed)
+	if err != nil {
+		return err
+	}
+	stats.raw = &countingWriter{w: cw}
+	if err := writeTarObserved(stats.raw, sourceDir, pathInTar, stats.entry); err != nil {
+		cw.Close()
+		return err
+	}
+	if err := cw.Close(); err != nil {
+		return err
+	}
+	stats.flush()
+	return file.Close()
+}
+
+// reverseArchiveFilters undoes filters on path, detected by their magic
+// bytes, and returns the path of the compressed tar with the names of the
+// filters that were reversed, outermost first. Temporary files are placed in
+// tmpDir.
+func reverseArchiveFilters(path, tmpDir string, opts ArchiveOptions) (string, []string, error) {
+	var reversed []string
+	for {
+		magic, err := readArchiveMagic(path)
+		if err != nil {
+			return "", reversed, err
+		}
+		filter := matchArchiveFilter(magic)
+		if filter == nil {
+			return path, reversed, nil
+		}
+		next := filepath.Join(tmpDir, fmt.Sprintf("stage%d_%s", len(reversed), strings.TrimSuffix(filepath.Base(path), filter.Extension)))
+		if err := filter.Reverse(path, next, opts); err != nil {
+			return "", reversed, err
+		}
+		reversed = append(reversed, filter.Name)
+		path = next
+	}
+}
+
+// extractArchive extracts a compressed tar into destDir, detecting the
+// compressor from its magic bytes, and returns the compressor name.
+func extractArchive(path, destDir string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	cr, compressor, err := decompressArchive(file)
+	if err != nil {
+		return "", err
+	}
+	defer cr.Close()
+	return compressor, extractTar(cr, destDir)
+}
+
+// readArchiveMember returns one file of a compressed tar, such as
+// backup/backup_information.json, without extracting the rest. Format 2
+// archives are read through their index; others are scanned.
+func readArchiveMember(path, member string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if info, err := file.Stat(); err == nil {
+		index, err := readArchiveIndex(file, info.Size())
+		if err == nil {
+			return readIndexedMember(file, index, member)
+		}
+		if !errors.Is(err, errNoArchiveIndex) {
+			logrus.Debugf("Ignoring the index of %s: %v", path, err)
+		}
+	}
+	return scanArchiveMember(file, member)
+}
+
+// scanArchiveMember reads a compressed tar from r until member is found and
+// returns its content.
+func scanArchiveMember(r io.Reader, member string) ([]byte, error) {
+	cr, _, err := decompressArchive(r)
+	if err != nil {
+		return nil, err
+	}
+	defer cr.Close()
+
+	tr := tar.NewReader(cr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("%s not found in archive", member)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag == tar.TypeReg && filepath.Clean(header.Name) == filepath.Clean(member) {
+			return io.ReadAll(tr)
+		}
+	}
+}
+
+// decompressArchive detects the compressor of r from its magic bytes and
+// returns the decompressed stream with the compressor name.
+func decompressArchive(r io.Reader) (io.ReadCloser, string, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(8)
+	for _, name := range registeredNames(archiveCompressors) {
+		compressor := archiveCompressors[name]
+		if !bytes.HasPrefix(magic, compressor.Magic) {
+			continue
+		}
+		cr, err := compressor.NewReader(br)
+		if err != nil {
+			return nil, "", err
+		}
+		return cr, compressor.Name, nil
+	}
+	return nil, "", fmt.Errorf("unrecognized archive format: first bytes % x", magic)
+}
+
+// checkArchivePipeline warns when the stages reversed on restore differ from
+// the pipeline recorded at backup time. Archives without a recorded pipeline
+// are not checked.
+func checkArchivePipeline(info *ArchivePipelineInfo, compression string, reversedFilters []string) {
+	if info == nil {
+		return
+	}
+	applied := make([]string, len(reversedFilters))
+	for i, name := range reversedFilters {
+		applied[len(reversedFilters)-1-i] = name
+	}
+	if info.Compression != compression || !slices.Equal(info.Filters, applied) {
+		logrus.Warnf("Archive stages differ from backup metadata: recorded %s %v, found %s %v", info.Compression, info.Filters, compression, applied)
+	}
+}
+
+func readArchiveMagic(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	magic := make([]byte, 8)
+	n, err := io.ReadFull(f, magic)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("failed to read file header: %w", err)
+	}
+	return magic[:n], nil
+}
+
+func matchArchiveFilter(magic []byte) *ArchiveFilter {
+	for _, name := range registeredNames(archiveFilters) {
+		if filter := archiveFilters[name]; bytes.HasPrefix(magic, filter.Magic) {
+			return filter
+		}
+	}
+	return nil
+}
+
+func registeredNames[T any](registry map[string]T) []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,120 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func writeArchiveKeys(t *testing.T, dir string) (pubPath, privPath string) {
+	t.Helper()
+	privPEM, pubB64, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair: %v", err)
+	}
+	privPath = filepath.Join(dir, "backup.key")
+	pubPath = privPath + ".pub"
+	if err := os.WriteFile(privPath, privPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(pubPath, []byte(pubB64+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return pubPath, privPath
+}
+
+func TestArchivePipelineRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	pubPath, privPath := writeArchiveKeys(t, dir)
+
+	sourceDir := filepath.Join(dir, "work")
+	if err := os.MkdirAll(filepath.Join(sourceDir, "backup", "database"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sourceDir, "backup", "database", "neo4j.dump"), []byte("graph"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		encrypt  bool
+		wantExt  string
+		wantRevs []string
+	}{
+		{name: "plain", wantExt: ".tar.gz"},
+		{name: "encrypted", encrypt: true, wantExt: ".tar.gz.enc", wantRevs: []string{"ecies"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline := defaultArchivePipeline(tt.encrypt, false)
+			base := filepath.Join(dir, "infrahub_backup_"+tt.name)
+			path, err := pipeline.Write(sourceDir, "backup/", base, ArchiveOptions{EncryptKey: pubPath})
+			if err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			if path != base+tt.wantExt || pipeline.ArchivePath(base) != path {
+				t.Fatalf("archive path = %s, want %s", path, base+tt.wantExt)
+			}
+			if tt.encrypt && fileExists(base+".tar.gz") {
+				t.Error("plaintext intermediate archive was not removed")
+			}
+
+			stageDir := t.TempDir()
+			plain, reversed, err := reverseArchiveFilters(path, stageDir, ArchiveOptions{DecryptKey: privPath})
+			if err != nil {
+				t.Fatalf("reverseArchiveFilters: %v", err)
+			}
+			if !slices.Equal(reversed, tt.wantRevs) {
+				t.Errorf("reversed filters = %v, want %v", reversed, tt.wantRevs)
+			}
+
+			destDir := t.TempDir()
+			compression, err := extractArchive(plain, destDir)
+			if err != nil {
+				t.Fatalf("extractArchive: %v", err)
+			}
+			if compression != "gzip" {
+				t.Errorf("compression = %s, want gzip", compression)
+			}
+			data, err := os.ReadFile(filepath.Join(destDir, "backup", "database", "neo4j.dump"))
+			if err != nil || string(data) != "graph" {
+				t.Errorf("extracted file = %q, %v", data, err)
+			}
+		})
+	}
+}
+
+func TestReverseArchiveFiltersRequiresKey(t *testing.T) {
+	dir := t.TempDir()
+	pubPath, _ := writeArchiveKeys(t, dir)
+	if err := os.MkdirAll(filepath.Join(dir, "work", "backup"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := defaultArchivePipeline(true, false).Write(filepath.Join(dir, "work"), "backup/", filepath.Join(dir, "b"), ArchiveOptions{EncryptKey: pubPath})
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	_, _, err = reverseArchiveFilters(path, t.TempDir(), ArchiveOptions{})
+	if err == nil || !strings.Contains(err.Error(), "--decrypt-key") {
+		t.Errorf("expected missing --decrypt-key error, got %v", err)
+	}
+}
+
+func TestArchivePipelineValidate(t *testing.T) {
+	if err := defaultArchivePipeline(true, true).Validate(); err != nil {
+		t.Errorf("default pipeline should validate: %v", err)
+	}
+	for _, pipeline := range []ArchivePipeline{
+		{Compression: "lz4"},
+		{Compression: "gzip", Filters: []string{"age"}},
+		{Compression: "gzip", Sinks: []string{"ftp"}},
+	} {
+		if err := pipeline.Validate(); err == nil {
+			t.Errorf("Validate(%+v) should fail", pipeline)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// splitManifestSuffix is appended to the archive name for the manifest of a
+// split archive, which lists its parts (archive.001, archive.002, ...).
+const splitManifestSuffix = ".manifest.json"
+
+// splitManifest describes a split archive. Restores reassemble the parts in
+// order and check every checksum.
+type splitManifest struct {
+	Archive  string      `json:"archive"`
+	Size     int64       `json:"size"`
+	SHA256   string      `json:"sha256"`
+	PartSize int64       `json:"part_size"`
+	Parts    []splitPart `json:"parts"`
+}
+
+type splitPart struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// parseByteSize parses sizes such as 4G, 700M or 1.5GiB. Units are powers of
+// 1024; a plain number is bytes.
+func parseByteSize(value string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "IB"), "B")
+	multiplier := int64(1)
+	if s != "" {
+		if exp := strings.IndexByte("KMGT", s[len(s)-1]); exp >= 0 {
+			multiplier = int64(1) << (10 * (exp + 1))
+			s = s[:len(s)-1]
+		}
+	}
+	number, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || number <= 0 {
+		return 0, fmt.Errorf("invalid size %q: expected a positive number with an optional K, M, G or T unit", value)
+	}
+	return int64(number * float64(multiplier)), nil
+}
+
+// splitArchive cuts path into parts of at most partSize bytes, writes their
+// manifest and removes path. It returns the manifest path, or path itself when
+// the archive already fits in one part.
+func splitArchive(path string, partSize int64) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.Size() <= partSize {
+		logrus.Infof("Archive is %s, within --split-size; not splitting", formatBytes(info.Size()))
+		return path, nil
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	manifest := splitManifest{Archive: filepath.Base(path), Size: info.Size(), PartSize: partSize}
+	whole := sha256.New()
+	var written []string
+	fail := func(err error) (string, error) {
+		for _, part := range written {
+			os.Remove(part)
+		}
+		return "", err
+	}
+
+	for remaining := info.Size(); remaining > 0; remaining -= partSize {
+		partPath := fmt.Sprintf("%s.%03d", path, len(manifest.Parts)+1)
+		written = append(written, partPath)
+		part, err := writeArchivePart(partPath, io.TeeReader(src, whole), min(partSize, remaining))
+		if err != nil {
+			return fail(fmt.Errorf("failed to write archive part %s: %w", partPath, err))
+		}
+		manifest.Parts = append(manifest.Parts, part)
+	}
+	manifest.SHA256 = hex.EncodeToString(whole.Sum(nil))
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fail(err)
+	}
+	manifestPath := path + splitManifestSuffix
+	if err := os.WriteFile(manifestPath, data, 0644); err != nil {
+		return fail(fmt.Errorf("failed to write split manifest: %w", err))
+	}
+	src.Close()
+	if err := os.Remove(path); err != nil {
+		logrus.Warnf("Failed to remove unsplit archive %s: %v", path, err)
+	}
+	logrus.WithFields(logrus.Fields{
+		"manifest": manifestPath,
+		"parts":    len(manifest.Parts),
+	}).Infof("Archive split into parts of at most %s", formatBytes(partSize))
+	return manifestPath, nil
+}
+
+func writeArchivePart(path string, r io.Reader, size int64) (splitPart, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return splitPart{}, err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.CopyN(io.MultiWriter(f, hash), r, size); err != nil {
+		return splitPart{}, err
+	}
+	if err := f.Close(); err != nil {
+		return splitPart{}, err
+	}
+	return splitPart{Name: filepath.Base(path), Size: size, SHA256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+// splitManifestFor returns the manifest path when path names a split archive
+// by its manifest, its first part or its original name, and path otherwise.
+func splitManifestFor(path string) string {
+	switch {
+	case strings.HasSuffix(path, splitManifestSuffix):
+		return path
+	case strings.HasSuffix(path, ".001"):
+		return strings.TrimSuffix(path, ".001") + splitManifestSuffix
+	case !fileExists(path) && fileExists(path+splitManifestSuffix):
+		return path + splitManifestSuffix
+	}
+	return path
+}
+
+func readSplitManifest(path string) (*splitManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read split manifest: %w", err)
+	}
+	manifest := &splitManifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("invalid split manifest %s: %w", path, err)
+	}
+	if manifest.Archive == "" || len(manifest.Parts) == 0 {
+		return nil, fmt.Errorf("invalid split manifest %s: no parts listed", path)
+	}
+	return manifest, nil
+}
+
+// splitArchiveFiles returns the parts listed by a split manifest followed by
+// the manifest itself, or just path for an archive that is not split.
+func splitArchiveFiles(path string) ([]string, error) {
+	if !strings.HasSuffix(path, splitManifestSuffix) {
+		return []string{path}, nil
+	}
+	manifest, err := readSplitManifest(path)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(manifest.Parts)+1)
+	for _, part := range manifest.Parts {
+		files = append(files, filepath.Join(filepath.Dir(path), part.Name))
+	}
+	return append(files, path), nil
+}
+
+// joinSplitArchive reassembles the split archive described by the manifest at
+// path into tmpDir and returns the joined file. Any other path is returned
+// unchanged.
+func joinSplitArchive(path, tmpDir string) (string, error) {
+	if !strings.HasSuffix(path, splitManifestSuffix) {
+		return path, nil
+	}
+	manifest, err := readSplitManifest(path)
+	if err != nil {
+		return "", err
+	}
+	logrus.Infof("Reassembling %d archive parts...", len(manifest.Parts))
+
+	joinedPath := filepath.Join(tmpDir, filepath.Base(manifest.Archive))
+	joined, err := os.Create(joinedPath)
+	if err != nil {
+		return "", err
+	}
+	defer joined.Close()
+
+	whole := sha256.New()
+	var size int64
+	for _, part := range manifest.Parts {
+		n, err := appendArchivePart(io.MultiWriter(joined, whole), filepath.Dir(path), part)
+		if err != nil {
+			return "", err
+		}
+		size += n
+	}
+	if size != manifest.Size || hex.EncodeToString(whole.Sum(nil)) != manifest.SHA256 {
+		return "", fmt.Errorf("reassembled archive does not match the split manifest checksum")
+	}
+	if err := joined.Close(); err != nil {
+		return "", err
+	}
+	return joinedPath, nil
+}
+
+func appendArchivePart(w io.Writer, dir string, part splitPart) (int64, error) {
+	f, err := os.Open(filepath.Join(dir, part.Name))
+	if err != nil {
+		return 0, fmt.Errorf("missing archive part: %w", err)
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(w, hash), f)
+	if err != nil {
+		return n, fmt.Errorf("failed to read archive part %s: %w", part.Name, err)
+	}
+	if n != part.Size || hex.EncodeToString(hash.Sum(nil)) != part.SHA256 {
+		return n, fmt.Errorf("archive part %s is corrupt or truncated", part.Name)
+	}
+	return n, nil
+}
+
+// removeArchiveFiles deletes an archive, with every part when it is split.
+func removeArchiveFiles(path string) error {
+	files, err := splitArchiveFiles(path)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, file := range files {
+		if err := os.Remove(file); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,136 @@
+package app
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{value: "4G", want: 4 << 30},
+		{value: "700M", want: 700 << 20},
+		{value: "1.5GiB", want: 3 << 29},
+		{value: "512kb", want: 512 << 10},
+		{value: "1048576", want: 1 << 20},
+		{value: "0", wantErr: true},
+		{value: "-1G", wantErr: true},
+		{value: "4X", wantErr: true},
+		{value: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseByteSize(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseByteSize(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseByteSize(%q) = %d, want %d", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func writeTestArchive(t *testing.T, size int) (string, []byte) {
+	t.Helper()
+	content := bytes.Repeat([]byte("infrahub"), size/8+1)[:size]
+	path := filepath.Join(t.TempDir(), "infrahub_backup_20261016_120000.tar.gz")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path, content
+}
+
+func TestSplitAndJoinArchive(t *testing.T) {
+	path, content := writeTestArchive(t, 2500)
+
+	manifestPath, err := splitArchive(path, 1000)
+	if err != nil {
+		t.Fatalf("splitArchive() error = %v", err)
+	}
+	if manifestPath != path+splitManifestSuffix {
+		t.Errorf("manifest = %s", manifestPath)
+	}
+	if fileExists(path) {
+		t.Error("unsplit archive was kept")
+	}
+	files, err := splitArchiveFiles(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantFiles := []string{path + ".001", path + ".002", path + ".003", manifestPath}
+	if strings.Join(files, ",") != strings.Join(wantFiles, ",") {
+		t.Errorf("files = %v, want %v", files, wantFiles)
+	}
+	if info, _ := os.Stat(path + ".003"); info.Size() != 500 {
+		t.Errorf("last part is %d bytes, want 500", info.Size())
+	}
+
+	for _, ref := range []string{manifestPath, path + ".001", path} {
+		if got := splitManifestFor(ref); got != manifestPath {
+			t.Errorf("splitManifestFor(%s) = %s", ref, got)
+		}
+	}
+
+	joined, err := joinSplitArchive(manifestPath, t.TempDir())
+	if err != nil {
+		t.Fatalf("joinSplitArchive() error = %v", err)
+	}
+	data, _ := os.ReadFile(joined)
+	if !bytes.Equal(data, content) || filepath.Base(joined) != filepath.Base(path) {
+		t.Errorf("joined %s differs from the original archive", joined)
+	}
+
+	if err := removeArchiveFiles(manifestPath); err != nil {
+		t.Fatalf("removeArchiveFiles() error = %v", err)
+	}
+	for _, file := range wantFiles {
+		if fileExists(file) {
+			t.Errorf("%s was not removed", file)
+		}
+	}
+}
+
+func TestJoinSplitArchiveDetectsDamage(t *testing.T) {
+	tests := []struct {
+		name    string
+		damage  func(path string) error
+		wantErr string
+	}{
+		{name: "missing part", damage: func(path string) error { return os.Remove(path + ".002") }, wantErr: "missing archive part"},
+		{name: "truncated part", damage: func(path string) error { return os.Truncate(path+".001", 10) }, wantErr: ".001 is corrupt or truncated"},
+		{name: "altered part", damage: func(path string) error { return os.WriteFile(path+".002", bytes.Repeat([]byte("x"), 1000), 0644) }, wantErr: ".002 is corrupt or truncated"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, _ := writeTestArchive(t, 2500)
+			manifestPath, err := splitArchive(path, 1000)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.damage(path); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := joinSplitArchive(manifestPath, t.TempDir()); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("joinSplitArchive() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSplitArchiveWithinSize(t *testing.T) {
+	path, _ := writeTestArchive(t, 500)
+	got, err := splitArchive(path, 1000)
+	if err != nil || got != path {
+		t.Errorf("splitArchive() = %s, %v, want the archive unchanged", got, err)
+	}
+	if joined, err := joinSplitArchive(path, t.TempDir()); err != nil || joined != path {
+		t.Errorf("joinSplitArchive() = %s, %v, want the archive unchanged", joined, err)
+	}
+}
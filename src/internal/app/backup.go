@@ -2,9 +2,9 @@ package app
 
 import (
 	"crypto/ecdh"
-	"encoding/json"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"path/filepath"
 	"slices"
@@ -25,10 +25,47 @@ func loadEncryptionKey(keyPath string) (*ecdh.PublicKey, error) {
 
 // CreateBackup creates a full backup of the Infrahub deployment
 func (iops *InfrahubOps) CreateBackup(force bool, neo4jMetadata string, excludeTaskManager bool, s3Upload bool, s3KeepLocal bool, sleepDuration time.Duration, redact bool, encrypt bool, encryptKey string) (retErr error) {
+	if err := iops.validateBackupPriority(); err != nil {
+		return err
+	}
+	if err := iops.checkBackupWindow(time.Now()); err != nil {
+		return err
+	}
+	if err := iops.checkVerifyRestore(encrypt || encryptKey != ""); err != nil {
+		return err
+	}
+	if err := iops.checkBackupRecord(); err != nil {
+		return err
+	}
+	if err := iops.checkBackupRegistration(); err != nil {
+		return err
+	}
+	if problems := iops.config.checkChecksumAlgorithm(); len(problems) > 0 {
+		return problems[0]
+	}
+	artifactFilter, err := NewArtifactFilter(iops.config.ArtifactsInclude, iops.config.ArtifactsExclude)
+	if err != nil {
+		return err
+	}
+	var splitSize int64
+	if iops.config.SplitSize != "" {
+		if splitSize, err = parseByteSize(iops.config.SplitSize); err != nil {
+			return fmt.Errorf("--split-size: %w", err)
+		}
+	}
+
 	if iops.config.Backend == BackendPlakar {
+		if splitSize > 0 {
+			return fmt.Errorf("--split-size is not supported with the plakar backend")
+		}
 		return iops.CreatePlakarBackup(force, neo4jMetadata, excludeTaskManager, sleepDuration, redact)
 	}
 
+	if err := iops.checkStreamBackup(encrypt || encryptKey != "", s3Upload, s3KeepLocal); err != nil {
+		return err
+	}
+	streamedToS3 := iops.config.StreamBackup && s3Upload && !s3KeepLocal
+
 	if err := iops.checkPrerequisites(); err != nil {
 		return err
 	}
@@ -36,13 +73,22 @@ func (iops *InfrahubOps) CreateBackup(force bool, neo4jMetadata string, excludeT
 	if err := iops.DetectEnvironment(); err != nil {
 		return err
 	}
+	releaseLock, err := iops.acquireOperationLock("backup")
+	if err != nil {
+		return err
+	}
+	defer releaseLock()
+	iops.reconcileRemoteTemp()
 
 	// Detect Neo4j edition
 	editionInfo := iops.detectNeo4jEditionInfo("backup")
 	if editionInfo.IsCommunity {
 		logrus.Warn("Neo4j Community Edition detected; Infrahub services will be stopped and restarted before the backup begins.")
-		logrus.Warn("Waiting 10 seconds to allow the user to abort... CTRL+C to cancel.")
-		time.Sleep(10 * time.Second)
+		iops.reportBackupImpact()
+		if !iops.config.NonInteractive {
+			logrus.Warn("Waiting 10 seconds to allow the user to abort... CTRL+C to cancel.")
+			time.Sleep(10 * time.Second)
+		}
 	}
 
 	// Redact attribute values if requested
@@ -55,16 +101,72 @@ func (iops *InfrahubOps) CreateBackup(force bool, neo4jMetadata string, excludeT
 		}
 	}
 
-	version := iops.getInfrahubVersion()
+	infrahubInfo := iops.collectInfrahubInfo()
+
+	// Pause work pools so no new tasks start while running ones drain
+	var pausedWorkPools []string
+	if iops.config.PauseWorkPools {
+		paused, resume, err := iops.pauseWorkPools()
+		if err != nil {
+			return err
+		}
+		defer resume()
+		pausedWorkPools = paused
+	}
 
 	// Check for running tasks unless --force is set
 	if !force {
 		logrus.Info("Checking for running tasks before backup...")
-		if err := iops.waitForRunningTasks(); err != nil {
+		if err := iops.runPhase("wait_for_tasks", iops.waitForRunningTasks); err != nil {
 			return err
 		}
 	}
 
+	// Save the RabbitMQ definitions while the message queue is still running
+	mqDefinitions, mqErr := iops.exportMessageQueueDefinitions()
+	if mqErr != nil {
+		logrus.Warnf("Backing up without RabbitMQ definitions: %v", mqErr)
+	}
+
+	// Snapshot the schema while infrahub-server is still running
+	schemaSnapshot, schemaErr := iops.exportSchemaSnapshot()
+	if schemaErr != nil {
+		logrus.Warnf("Backing up without a schema snapshot: %v", schemaErr)
+	}
+	neo4jIndexes, indexesErr := iops.exportNeo4jIndexes()
+	if indexesErr != nil {
+		logrus.Warnf("Backing up without the Neo4j index script: %v", indexesErr)
+	}
+	branches, branchErr := iops.exportBranchList()
+	if branchErr != nil {
+		logrus.Warnf("Backing up without the branch list: %v", branchErr)
+	}
+
+	// Keep the compose manifest or Helm values so restore can recreate the deployment
+	composeManifest, composeErr := iops.exportComposeManifest()
+	if composeErr != nil {
+		logrus.Warnf("Backing up without the compose manifest: %v", composeErr)
+	}
+	helmRelease, helmErr := iops.exportHelmRelease()
+	if helmErr != nil {
+		logrus.Warnf("Backing up without the Helm values: %v", helmErr)
+	}
+
+	// Register the backup in Infrahub once the services stopped for a
+	// Community Edition backup are running again; the backup itself is
+	// complete by then, so a failure is only a warning
+	var registration *backupRegistration
+	if iops.config.RegisterKind != "" {
+		defer func() {
+			if registration == nil {
+				return
+			}
+			if err := iops.registerBackup(*registration); err != nil {
+				logrus.Warn(err)
+			}
+		}()
+	}
+
 	var servicesToRestart []string
 	if editionInfo.IsCommunity {
 		stoppedServices, stopErr := iops.stopAppContainers()
@@ -81,7 +183,7 @@ func (iops *InfrahubOps) CreateBackup(force bool, neo4jMetadata string, excludeT
 			if len(servicesToRestart) == 0 {
 				return
 			}
-			if startErr := iops.startAppContainers(servicesToRestart); startErr != nil {
+			if startErr := iops.restartAfterBackup(servicesToRestart); startErr != nil {
 				logrus.Errorf("Failed to restart services after backup: %v", startErr)
 				if retErr == nil {
 					retErr = fmt.Errorf("failed to restart services after backup: %w", startErr)
@@ -91,7 +193,6 @@ func (iops *InfrahubOps) CreateBackup(force bool, neo4jMetadata string, excludeT
 	}
 
 	backupFilename := iops.generateBackupFilename()
-	backupPath := filepath.Join(iops.config.BackupDir, backupFilename)
 	workDir, err := os.MkdirTemp("", "infrahub_backup_*")
 	if err != nil {
 		return fmt.Errorf("failed to create temp directory: %w", err)
@@ -116,71 +217,153 @@ func (iops *InfrahubOps) CreateBackup(force bool, neo4jMetadata string, excludeT
 
 	// Create metadata
 	backupID := strings.TrimSuffix(backupFilename, ".tar.gz")
-	metadata := iops.createBackupMetadata(backupID, !excludeTaskManager, version, editionInfo.Edition)
+	metadata := iops.createBackupMetadata(backupID, !excludeTaskManager, infrahubInfo.Version, editionInfo.Edition)
+	metadata.InfrahubInfo = infrahubInfo
+	metadata.Branches = branches
+	iops.recordNeo4jServerInfo(metadata)
 	if redact {
 		metadata.Redacted = true
 	}
-	if encrypt || encryptKey != "" {
-		metadata.Encrypted = true
+	metadata.PausedWorkPools = pausedWorkPools
+	pipeline := defaultArchivePipeline(encrypt || encryptKey != "", s3Upload)
+	metadata.Encrypted = slices.Contains(pipeline.Filters, "ecies")
+	metadata.Archive = pipeline.Info()
+
+	// An online Enterprise backup runs next to production traffic, so its
+	// copies pause while the database is busy
+	if !strings.EqualFold(editionInfo.Edition, neo4jEditionCommunity) {
+		iops.throttle = iops.newLoadThrottle("database")
+		defer iops.stopThrottle()
+	} else if iops.config.ThrottleCPU > 0 || iops.config.ThrottleIO > 0 {
+		logrus.Info("Services are stopped during Community Edition backups; --throttle-cpu and --throttle-io do not apply")
 	}
 
-	// Backup databases
-	if err := iops.backupDatabase(backupDir, neo4jMetadata, editionInfo.Edition); err != nil {
+	// Backup databases, or prepare the streams read into the archive
+	var streams []backupStream
+	if iops.config.StreamBackup {
+		if streams, err = iops.backupStreams(neo4jMetadata, editionInfo.Edition, excludeTaskManager); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Join(backupDir, neo4jBackupDirName), 0755); err != nil {
+			return fmt.Errorf("failed to create backup directory: %w", err)
+		}
+	} else if err := iops.runPhase("neo4j_backup", func() error {
+		return iops.backupDatabase(backupDir, neo4jMetadata, editionInfo.Edition)
+	}); err != nil {
 		return err
 	}
 
 	if !excludeTaskManager {
-		if err := iops.backupTaskManagerDB(backupDir); err != nil {
-			return err
+		if !iops.config.StreamBackup {
+			if err := iops.runPhase("taskmanager_backup", func() error { return iops.backupTaskManagerDB(backupDir) }); err != nil {
+				return err
+			}
 		}
+		iops.recordTaskManagerInfo(metadata)
 	} else {
 		logrus.Info("Skipping task manager database backup as requested")
 	}
 
-	// Calculate checksums for backup files
-	checksums, err := calculateBackupChecksums(backupDir, excludeTaskManager)
+	// Backup the artifact object store when the deployment runs one
+	objectStore, err := iops.objectStoreRunning()
 	if err != nil {
 		return err
 	}
-	metadata.Checksums = checksums
+	if objectStore {
+		if err := iops.runPhase("object_store_backup", func() error { return iops.backupObjectStore(backupDir, artifactFilter) }); err != nil {
+			return err
+		}
+		metadata.Components = append(metadata.Components, objectStoreService)
+		metadata.ArtifactFilter = artifactFilter
+	} else {
+		logrus.Debugf("No running %s service; skipping object store backup", objectStoreService)
+	}
 
-	metadataBytes, err := json.MarshalIndent(metadata, "", "    ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal metadata: %w", err)
+	if mqDefinitions != nil {
+		if err := os.WriteFile(filepath.Join(backupDir, messageQueueDefinitionsFilename), mqDefinitions, 0600); err != nil {
+			return fmt.Errorf("failed to write rabbitmq definitions: %w", err)
+		}
+		metadata.Components = append(metadata.Components, messageQueueService)
 	}
 
-	if err := os.WriteFile(filepath.Join(backupDir, "backup_information.json"), metadataBytes, 0644); err != nil {
-		return fmt.Errorf("failed to write metadata: %w", err)
+	if schemaSnapshot != nil {
+		if err := os.WriteFile(filepath.Join(backupDir, schemaSnapshotFilename), schemaSnapshot, 0644); err != nil {
+			return fmt.Errorf("failed to write schema snapshot: %w", err)
+		}
+		metadata.Components = append(metadata.Components, schemaComponent)
 	}
 
-	// TODO: Backup artifact store
-	logrus.Info("Artifact store backup will be added in future versions")
+	if neo4jIndexes != nil {
+		if err := os.WriteFile(filepath.Join(backupDir, neo4jIndexesFilename), neo4jIndexes, 0644); err != nil {
+			return fmt.Errorf("failed to write neo4j index script: %w", err)
+		}
+		metadata.Components = append(metadata.Components, neo4jIndexesComponent)
+	}
 
-	// Create tarball
-	logrus.Info("Creating backup archive...")
-	if err := createTarball(backupPath, workDir, "backup/"); err != nil {
-		return fmt.Errorf("failed to create archive: %w", err)
+	if composeManifest != nil {
+		if err := os.WriteFile(filepath.Join(backupDir, composeManifestFilename), composeManifest, 0600); err != nil {
+			return fmt.Errorf("failed to write compose manifest: %w", err)
+		}
+		metadata.Components = append(metadata.Components, composeComponent)
 	}
 
-	// Encrypt backup if requested
-	if encrypt || encryptKey != "" {
-		pubKey, loadErr := loadEncryptionKey(encryptKey)
-		if loadErr != nil {
-			return fmt.Errorf("failed to load encryption key: %w", loadErr)
+	if helmRelease != nil {
+		if err := os.WriteFile(filepath.Join(backupDir, helmValuesFilename), helmRelease.Values, 0600); err != nil {
+			return fmt.Errorf("failed to write Helm values: %w", err)
 		}
+		metadata.Components = append(metadata.Components, helmComponent)
+		metadata.Source.Release = helmRelease.Name
+		metadata.Source.Chart = helmRelease.Chart
+	}
 
-		encryptedPath := backupPath + ".enc"
-		logrus.Info("Encrypting backup archive...")
-		if err := EncryptFile(backupPath, encryptedPath, pubKey); err != nil {
-			return fmt.Errorf("failed to encrypt backup: %w", err)
+	// Calculate checksums for backup files; streamed files were hashed as
+	// they were read
+	writeMetadata := func(streamed map[string]string) error {
+		checksums, err := calculateBackupChecksums(backupDir, excludeTaskManager, iops.config.checksumAlgorithm())
+		if err != nil {
+			return err
 		}
+		maps.Copy(checksums, streamed)
+		metadata.Checksums = checksums
 
-		if err := os.Remove(backupPath); err != nil {
-			logrus.Warnf("Failed to remove plaintext backup: %v", err)
+		metadataBytes, err := marshalBackupMetadata(metadata)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(backupDir, "backup_information.json"), metadataBytes, 0644); err != nil {
+			return fmt.Errorf("failed to write metadata: %w", err)
 		}
+		return nil
+	}
 
-		backupPath = encryptedPath
-		backupFilename = filepath.Base(encryptedPath)
+	// Write the archive through the compression and filter stages
+	logrus.Info("Creating backup archive...")
+	var backupPath, streamedURI string
+	var backupSize int64
+	var componentStats []ComponentStats
+	if iops.config.StreamBackup {
+		var location string
+		location, backupSize, componentStats, err = iops.writeStreamedBackup(pipeline, workDir, backupID, streamedToS3, streams, writeMetadata)
+		if err != nil {
+			return err
+		}
+		if streamedToS3 {
+			streamedURI = location
+		} else {
+			backupPath = location
+		}
+		backupFilename = filepath.Base(location)
+	} else {
+		if err := writeMetadata(nil); err != nil {
+			return err
+		}
+		archivePhase := iops.startPhase("archive")
+		backupPath, componentStats, err = pipeline.WriteWithStats(workDir, "backup/", filepath.Join(iops.config.BackupDir, backupID), ArchiveOptions{EncryptKey: encryptKey})
+		archivePhase.end(err)
+		if err != nil {
+			return err
+		}
+		backupFilename = filepath.Base(backupPath)
 	}
 
 	// Log backup creation with structured fields
@@ -188,22 +371,56 @@ func (iops *InfrahubOps) CreateBackup(force bool, neo4jMetadata string, excludeT
 		"path":     backupPath,
 		"filename": backupFilename,
 	}
-	if stat, err := os.Stat(backupPath); err == nil {
+	if streamedURI != "" {
+		fields["path"] = streamedURI
+		fields["size_bytes"] = backupSize
+		fields["size_human"] = formatBytes(backupSize)
+	} else if stat, err := os.Stat(backupPath); err == nil {
+		backupSize = stat.Size()
 		fields["size_bytes"] = stat.Size()
 		fields["size_human"] = formatBytes(stat.Size())
 	}
 	logrus.WithFields(fields).Info("Backup created successfully")
 
-	// Upload to S3 if requested
-	if s3Upload {
-		s3URI, err := iops.uploadBackupToS3(backupPath)
-		if err != nil {
-			return fmt.Errorf("backup created locally but S3 upload failed: %w", err)
+	// Rehearse a restore before the local archive may be removed after upload
+	verified, verifyErr := iops.verifyCreatedBackup(backupPath, excludeTaskManager)
+
+	// Cut the archive into parts for size-capped destinations
+	if splitSize > 0 {
+		if backupPath, err = splitArchive(backupPath, splitSize); err != nil {
+			return fmt.Errorf("failed to split archive: %w", err)
+		}
+	}
+
+	// Hash the archive for the backup record while it is still local
+	var record *BackupRecord
+	if iops.config.RecordTo != "" {
+		if record, err = iops.newBackupRecord(metadata, backupPath); err != nil {
+			logrus.Warnf("Failed to prepare backup record: %v", err)
+		}
+	}
+
+	// Hand the archive to the configured sinks (S3 upload when requested)
+	locations := map[string]string{"s3": streamedURI}
+	if streamedURI == "" {
+		if locations, err = pipeline.Deliver(iops, backupPath); err != nil {
+			return fmt.Errorf("backup created locally but delivery failed: %w", err)
+		}
+	}
+	if record != nil {
+		record.Locations = locations
+		if location, err := iops.sendBackupRecord(record); err != nil {
+			logrus.Warnf("Failed to write backup record: %v", err)
+		} else {
+			logrus.Infof("Backup record written to %s", location)
 		}
+	}
+	s3URI := locations["s3"]
+	if s3URI != "" {
 		logrus.Infof("Backup uploaded to: %s", s3URI)
 
-		if !s3KeepLocal {
-			if err := os.Remove(backupPath); err != nil {
+		if !s3KeepLocal && backupPath != "" {
+			if err := removeArchiveFiles(backupPath); err != nil {
 				logrus.Warnf("Failed to delete local backup file: %v", err)
 			} else {
 				logrus.Infof("Local backup file deleted: %s", backupPath)
@@ -211,6 +428,32 @@ func (iops *InfrahubOps) CreateBackup(force bool, neo4jMetadata string, excludeT
 		}
 	}
 
+	if err := iops.recordBackupInCatalog(backupID, backupPath, s3URI, backupSize); err != nil {
+		logrus.Warnf("Failed to record backup in catalog: %v", err)
+	} else if err := iops.reportBackupStats(backupID, componentStats, metadata.Checksums); err != nil {
+		logrus.Warnf("Failed to record backup statistics: %v", err)
+	}
+	if verified {
+		if err := iops.markBackupVerified(backupID, verifyErr); err != nil {
+			logrus.Warnf("Failed to record verification in catalog: %v", err)
+		}
+	}
+	if s3URI != "" && !s3KeepLocal {
+		iops.recordArtifact(backupID, "", s3URI, backupSize)
+	} else {
+		iops.recordArtifact(backupID, backupPath, s3URI, backupSize)
+	}
+	registration = &backupRegistration{BackupID: backupID, Location: backupPath, SizeBytes: backupSize, Status: RegisteredStatusCompleted, CreatedAt: time.Now()}
+	if s3URI != "" {
+		registration.Location = s3URI
+	}
+	switch {
+	case verifyErr != nil:
+		registration.Status = RegisteredStatusVerificationFailed
+	case verified:
+		registration.Status = RegisteredStatusVerified
+	}
+
 	// Sleep if requested (for K8s users to transfer backup file)
 	if sleepDuration > 0 {
 		logrus.Infof("Sleeping for %v to allow backup file transfer...", sleepDuration)
@@ -218,12 +461,27 @@ func (iops *InfrahubOps) CreateBackup(force bool, neo4jMetadata string, excludeT
 		time.Sleep(sleepDuration)
 	}
 
+	// The services stopped for a Community Edition backup are started again
+	// by the deferred restart
+	if err := iops.failAt(FailAtBeforeRestart); err != nil {
+		return err
+	}
+	if verifyErr != nil {
+		return fmt.Errorf("backup %s was created but its verification restore failed: %w", backupID, verifyErr)
+	}
 	return retErr
 }
 
 // RestoreBackup restores an Infrahub deployment from a backup archive
-func (iops *InfrahubOps) RestoreBackup(backupFile string, excludeTaskManager bool, restoreMigrateFormat bool, sleepDuration time.Duration, decryptKey string, force bool, resetDeploymentID bool) error {
+//
+// With minimizeDowntime, infrahub-server keeps serving read traffic while the
+// task manager database is restored and the Neo4j backup is staged; it is only
+// stopped for the final database switch.
+func (iops *InfrahubOps) RestoreBackup(backupFile string, excludeTaskManager bool, restoreMigrateFormat bool, sleepDuration time.Duration, decryptKey string, force bool, resetDeploymentID bool, minimizeDowntime bool) error {
 	if iops.config.Backend == BackendPlakar {
+		if minimizeDowntime {
+			return fmt.Errorf("--minimize-downtime is not supported with the plakar backend")
+		}
 		return iops.RestorePlakarBackup(excludeTaskManager, restoreMigrateFormat, sleepDuration, force, resetDeploymentID)
 	}
 
@@ -236,8 +494,9 @@ func (iops *InfrahubOps) RestoreBackup(backupFile string, excludeTaskManager boo
 			return err
 		}
 		actualBackupFile = downloadedPath
-		defer os.Remove(actualBackupFile) // Clean up downloaded file after restore
+		defer removeArchiveFiles(downloadedPath) // Clean up downloaded file after restore
 	}
+	actualBackupFile = splitManifestFor(actualBackupFile)
 
 	// Sleep if requested (for K8s users to transfer backup file into pod)
 	if sleepDuration > 0 {
@@ -250,40 +509,22 @@ func (iops *InfrahubOps) RestoreBackup(backupFile string, excludeTaskManager boo
 		return fmt.Errorf("backup file not found: %s", actualBackupFile)
 	}
 
-	// Auto-detect and decrypt if necessary
-	encrypted, err := IsEncryptedFile(actualBackupFile)
+	// Reverse archive filters (decryption) detected from the file header
+	stageDir, err := os.MkdirTemp("", "infrahub_restore_archive_*")
 	if err != nil {
-		return fmt.Errorf("failed to detect file format: %w", err)
+		return fmt.Errorf("failed to create temp directory: %w", err)
 	}
+	defer os.RemoveAll(stageDir)
 
-	if encrypted {
-		if decryptKey == "" {
-			return fmt.Errorf("backup file is encrypted; provide --decrypt-key to decrypt")
-		}
-
-		privKey, err := LoadPrivateKeyFromFile(decryptKey)
-		if err != nil {
-			return fmt.Errorf("failed to load decryption key: %w", err)
-		}
-
-		decryptedPath := strings.TrimSuffix(actualBackupFile, ".enc")
-		if decryptedPath == actualBackupFile {
-			decryptedPath = actualBackupFile + ".decrypted.tar.gz"
-		}
-
-		logrus.Info("Decrypting backup archive...")
-		if err := DecryptFile(actualBackupFile, decryptedPath, privKey); err != nil {
-			return fmt.Errorf("failed to decrypt backup: %w", err)
-		}
-
-		// If the encrypted file was downloaded from S3 (temporary), remove it
-		if IsS3URI(backupFile) {
-			os.Remove(actualBackupFile)
-		}
-
-		actualBackupFile = decryptedPath
-		defer os.Remove(actualBackupFile)
-	} else if decryptKey != "" {
+	actualBackupFile, err = joinSplitArchive(actualBackupFile, stageDir)
+	if err != nil {
+		return err
+	}
+	actualBackupFile, reversedFilters, err := reverseArchiveFilters(actualBackupFile, stageDir, ArchiveOptions{DecryptKey: decryptKey})
+	if err != nil {
+		return err
+	}
+	if decryptKey != "" && !slices.Contains(reversedFilters, "ecies") {
 		return fmt.Errorf("--decrypt-key provided but backup file is not encrypted")
 	}
 
@@ -294,6 +535,12 @@ func (iops *InfrahubOps) RestoreBackup(backupFile string, excludeTaskManager boo
 	if err := iops.DetectEnvironment(); err != nil {
 		return err
 	}
+	releaseLock, err := iops.acquireOperationLock("restore")
+	if err != nil {
+		return err
+	}
+	defer releaseLock()
+	iops.reconcileRemoteTemp()
 
 	workDir, err := os.MkdirTemp("", "infrahub_restore_*")
 	if err != nil {
@@ -308,7 +555,10 @@ func (iops *InfrahubOps) RestoreBackup(backupFile string, excludeTaskManager boo
 
 	// Extract backup
 	logrus.Info("Extracting backup archive...")
-	if err := extractTarball(actualBackupFile, workDir); err != nil {
+	extractPhase := iops.startPhase("extract")
+	compression, err := extractArchive(actualBackupFile, workDir)
+	extractPhase.end(err)
+	if err != nil {
 		return fmt.Errorf("failed to extract backup: %w", err)
 	}
 
@@ -323,20 +573,55 @@ func (iops *InfrahubOps) RestoreBackup(backupFile string, excludeTaskManager boo
 	if err != nil {
 		return fmt.Errorf("failed to read metadata: %w", err)
 	}
-	var metadata BackupMetadata
-	if err := json.Unmarshal(metadataBytes, &metadata); err != nil {
-		return fmt.Errorf("failed to parse metadata: %w", err)
+	metadata, err := parseBackupMetadata(metadataBytes)
+	if err != nil {
+		return err
 	}
 
 	// Log backup metadata with structured fields
-	logrus.WithFields(logrus.Fields{
+	metadataFields := logrus.Fields{
 		"backup_id":        metadata.BackupID,
 		"created_at":       metadata.CreatedAt,
 		"tool_version":     metadata.ToolVersion,
 		"infrahub_version": metadata.InfrahubVersion,
 		"neo4j_edition":    metadata.Neo4jEdition,
+		"neo4j_version":    metadata.Neo4jVersion,
 		"components":       metadata.Components,
-	}).Info("Backup metadata loaded")
+	}
+	if src := metadata.Source; src != nil {
+		metadataFields["source_backend"] = src.Backend
+		metadataFields["source_host"] = src.Host
+		if src.Project != "" {
+			metadataFields["source_project"] = src.Project
+		}
+		if src.Namespace != "" {
+			metadataFields["source_namespace"] = src.Namespace
+		}
+		if src.Cluster != "" {
+			metadataFields["source_cluster"] = src.Cluster
+		}
+		if src.Git != nil {
+			metadataFields["source_git_commit"] = src.Git.Commit
+		}
+	}
+	logrus.WithFields(metadataFields).Info("Backup metadata loaded")
+	iops.recordRestoreSource(metadata.BackupID, backupFile, metadata.CreatedAt)
+	checkArchivePipeline(metadata.Archive, compression, reversedFilters)
+
+	// Validate checksums for all backup files
+	if err := validateBackupChecksums(workDir, metadata, excludeTaskManager); err != nil {
+		return err
+	}
+
+	return iops.restoreExtractedBackup(workDir, metadata, excludeTaskManager, restoreMigrateFormat, resetDeploymentID, minimizeDowntime)
+}
+
+// restoreExtractedBackup restores the databases staged under workDir/backup,
+// described by metadata, into the running deployment.
+func (iops *InfrahubOps) restoreExtractedBackup(workDir string, metadata *BackupMetadata, excludeTaskManager, restoreMigrateFormat, resetDeploymentID, minimizeDowntime bool) error {
+	if !metadata.hasComponent("database") {
+		return iops.restoreTaskManagerOnly(workDir, metadata, excludeTaskManager)
+	}
 
 	// Detect Neo4j edition for restore
 	detectedEdition, detectionErr := iops.detectNeo4jEdition()
@@ -348,23 +633,17 @@ func (iops *InfrahubOps) RestoreBackup(backupFile string, excludeTaskManager boo
 	}
 	editionInfo.LogDetection("restore")
 
-	// Determine task manager database availability
-	taskManagerIncluded := slices.Contains(metadata.Components, "task-manager-db")
-	if !taskManagerIncluded {
-		if _, ok := metadata.Checksums["prefect.dump"]; ok {
-			taskManagerIncluded = true
-		}
-	}
-
-	// Validate checksums for all backup files
-	if err := validateBackupChecksums(workDir, &metadata, excludeTaskManager); err != nil {
+	restoreMigrateFormat, err = iops.resolveNeo4jRestoreFormat(metadata, neo4jEdition, restoreMigrateFormat)
+	if err != nil {
 		return err
 	}
 
+	// Determine task manager database availability
+	taskManagerIncluded := metadata.hasComponent("task-manager-db")
+
 	// Determine if we should restore task manager database
 	shouldRestoreTaskManager := taskManagerIncluded && !excludeTaskManager
-	prefectPath := filepath.Join(workDir, "backup", prefectDumpFilename)
-	prefectExists := fileExists(prefectPath)
+	prefectExists := hasTaskManagerDump(filepath.Join(workDir, "backup"))
 	validatePrefect := shouldRestoreTaskManager && prefectExists
 
 	// Validate task manager restore requirements
@@ -379,50 +658,173 @@ func (iops *InfrahubOps) RestoreBackup(backupFile string, excludeTaskManager boo
 		logrus.Info("Backup does not include task manager database; skipping restore")
 	} else if prefectExists {
 		logrus.Info("Task manager database dump detected; will restore")
+		logExcludedTableData(metadata)
 	}
 
-	// Wipe transient data
-	iops.wipeTransientData()
+	migratePrefect := false
+	if validatePrefect {
+		if err := iops.verifyPostgresRestoreCompatibility(metadata); err != nil {
+			return err
+		}
+		var err error
+		if migratePrefect, err = iops.verifyPrefectSchemaRevision(metadata); err != nil {
+			return err
+		}
+	}
 
-	// Stop application containers
-	if _, err := iops.stopAppContainers(); err != nil {
+	if err := iops.preflightSchemaSnapshot(workDir); err != nil {
 		return err
 	}
 
-	// Restore PostgreSQL when available
-	if validatePrefect {
-		if err := iops.restorePostgreSQL(workDir); err != nil {
+	// The object store is independent of the databases, so it is restored
+	// while the application is still up.
+	restoreObjectStore, err := iops.planObjectStoreRestore(workDir, metadata)
+	if err != nil {
+		return err
+	}
+	if restoreObjectStore {
+		if err := iops.restoreObjectStore(workDir); err != nil {
 			return err
 		}
-	} else {
+	}
+
+	neo4jIndexes := iops.neo4jIndexesForRestore(filepath.Join(workDir, "backup"), metadata, neo4jEdition)
+
+	if minimizeDowntime {
+		return iops.restoreWithMinimalDowntime(workDir, metadata, neo4jEdition, neo4jIndexes, validatePrefect, migratePrefect, restoreMigrateFormat, resetDeploymentID)
+	}
+
+	if !validatePrefect {
 		logrus.Info("Skipping task manager database restore step")
 	}
+	window := restoreWindow{
+		restorePrefect:    validatePrefect,
+		migratePrefect:    migratePrefect,
+		resetDeploymentID: resetDeploymentID,
+		neo4jIndexes:      neo4jIndexes,
+		restoreNeo4j:      func() error { return iops.restoreNeo4j(workDir, neo4jEdition, restoreMigrateFormat) },
+	}
+	if validatePrefect {
+		window.restoreTaskManager = func() error { return iops.restorePostgreSQL(workDir) }
+	}
+	if err := iops.runRestoreWindow(workDir, metadata, window); err != nil {
+		return err
+	}
+
+	logrus.Info("Restore completed successfully")
+	logrus.Info("Infrahub should be available shortly")
+
+	return nil
+}
+
+// restoreWindow describes the part of a restore run while the application is
+// stopped.
+type restoreWindow struct {
+	restoreTaskManager func() error // restores the task manager database once the application is stopped; nil when done beforehand or skipped
+	restoreNeo4j       func() error // loads the graph database
+	restorePrefect     bool         // the task manager database is restored, in this window or before it
+	migratePrefect     bool         // run the Prefect migrations once the task manager is back up
+	resetDeploymentID  bool
+	neo4jIndexes       []byte // index and constraint script replayed after the Neo4j restore
+}
+
+// runRestoreWindow stops the application, restores the databases described
+// by window, then starts the application again. The transient data is wiped,
+// keeping the RabbitMQ definitions to import once the message queue is back.
+func (iops *InfrahubOps) runRestoreWindow(workDir string, metadata *BackupMetadata, window restoreWindow) error {
+	mqDefinitions := iops.messageQueueDefinitionsForRestore(filepath.Join(workDir, "backup"))
+	iops.wipeTransientData()
+
+	stopped, err := iops.stopAppContainers()
+	if err != nil {
+		return err
+	}
+
+	if window.restoreTaskManager != nil {
+		if err := iops.runPhase("taskmanager_restore", window.restoreTaskManager); err != nil {
+			return err
+		}
+	}
 
-	// Restart dependencies
 	if err := iops.restartDependencies(); err != nil {
 		return err
 	}
+	if window.migratePrefect {
+		if err := iops.runPrefectMigrations(); err != nil {
+			return err
+		}
+	}
+	iops.restoreMessageQueueDefinitions(mqDefinitions, workDir)
 
-	// Restore Neo4j
-	if err := iops.restoreNeo4j(workDir, neo4jEdition, restoreMigrateFormat); err != nil {
+	if err := iops.runPhase("neo4j_restore", window.restoreNeo4j); err != nil {
 		return err
 	}
+	iops.replayNeo4jIndexes(window.neo4jIndexes, workDir)
 
 	// Reset deployment ID before the app containers come back up so they never
 	// observe the source deployment's UUID.
-	if resetDeploymentID {
+	if window.resetDeploymentID {
 		if err := iops.resetDeploymentID(); err != nil {
 			return err
 		}
 	}
+	if err := iops.anonymizeRestoredData(workDir, true, window.restorePrefect); err != nil {
+		return err
+	}
 
-	// Restart all services
+	if err := iops.failAt(FailAtBeforeRestart); err != nil {
+		return err
+	}
 	logrus.Info("Restarting Infrahub services...")
-	if err := iops.StartServices("infrahub-server", "task-worker"); err != nil {
+	if err := iops.runPhase("start_services", func() error { return iops.restartAppServices(stopped) }); err != nil {
 		return fmt.Errorf("failed to restart infrahub services: %w", err)
 	}
+	if window.restorePrefect {
+		iops.resumeRestoredWorkPools(metadata)
+	}
+	return nil
+}
 
-	logrus.Info("Restore completed successfully")
+// restoreWithMinimalDowntime performs everything that does not require the
+// graph database to be offline first, then stops the application for a short
+// switch window covering only the Neo4j restore and the service restarts.
+func (iops *InfrahubOps) restoreWithMinimalDowntime(workDir string, metadata *BackupMetadata, neo4jEdition string, neo4jIndexes []byte, restorePrefect, migratePrefect, restoreMigrateFormat, resetDeploymentID bool) error {
+	logrus.Info("Minimizing downtime: infrahub-server stays up until the Neo4j switch")
+
+	// Stage the Neo4j backup inside the database container while it is still live
+	logrus.Info("Staging Neo4j backup files...")
+	cleanupNeo4j, err := iops.prepareNeo4jRestore(workDir)
+	if err != nil {
+		return err
+	}
+	defer cleanupNeo4j()
+
+	// Only the task manager stack uses the PostgreSQL database, so it can be
+	// restored while infrahub-server keeps answering read requests.
+	if restorePrefect {
+		if _, err := iops.stopRunningServices([]string{"task-worker", "task-manager", "task-manager-background-svc"}); err != nil {
+			return err
+		}
+		if err := iops.runPhase("taskmanager_restore", func() error { return iops.restorePostgreSQL(workDir) }); err != nil {
+			return err
+		}
+	} else {
+		logrus.Info("Skipping task manager database restore step")
+	}
+
+	logrus.Info("Entering downtime window")
+	windowStart := time.Now()
+	if err := iops.runRestoreWindow(workDir, metadata, restoreWindow{
+		restorePrefect:    restorePrefect,
+		migratePrefect:    migratePrefect,
+		resetDeploymentID: resetDeploymentID,
+		neo4jIndexes:      neo4jIndexes,
+		restoreNeo4j:      func() error { return iops.applyNeo4jRestore(neo4jEdition, restoreMigrateFormat) },
+	}); err != nil {
+		return err
+	}
+
+	logrus.WithField("downtime", time.Since(windowStart).Round(time.Second).String()).Info("Restore completed successfully")
 	logrus.Info("Infrahub should be available shortly")
 
 	return nil
@@ -503,7 +905,7 @@ func (iops *InfrahubOps) CreateBackupFromFiles(neo4jPath string, postgresPath st
 		}
 		if !info.IsDir() {
 			rel, _ := filepath.Rel(backupDir, path)
-			if sum, err := calculateSHA256(path); err == nil {
+			if sum, err := calculateChecksum(path, iops.config.checksumAlgorithm()); err == nil {
 				checksums[rel] = sum
 			}
 		}
@@ -515,7 +917,7 @@ func (iops *InfrahubOps) CreateBackupFromFiles(neo4jPath string, postgresPath st
 
 	if postgresIncluded {
 		prefectPath := filepath.Join(backupDir, "prefect.dump")
-		if sum, err := calculateSHA256(prefectPath); err == nil {
+		if sum, err := calculateChecksum(prefectPath, iops.config.checksumAlgorithm()); err == nil {
 			checksums["prefect.dump"] = sum
 		} else {
 			return fmt.Errorf("failed to calculate Prefect DB checksum: %w", err)
@@ -524,71 +926,55 @@ func (iops *InfrahubOps) CreateBackupFromFiles(neo4jPath string, postgresPath st
 
 	// Generate backup filename and ID
 	backupFilename := iops.generateBackupFilename()
-	backupPath := filepath.Join(iops.config.BackupDir, backupFilename)
 	backupID := strings.TrimSuffix(backupFilename, ".tar.gz")
+	pipeline := defaultArchivePipeline(encrypt || encryptKey != "", false)
 
 	// Normalize neo4j edition
 	edition := strings.ToLower(neo4jEdition)
 	if edition == "" {
-		// Try to detect from file structure
-		// If it's a .dump file, likely community edition
-		if !neo4jInfo.IsDir() && strings.HasSuffix(neo4jPath, ".dump") {
-			edition = neo4jEditionCommunity
-		} else {
-			edition = neo4jEditionEnterprise
-		}
+		edition = neo4jArtifactEdition(neo4jPath, neo4jInfo)
 		logrus.Infof("Auto-detected Neo4j edition: %s", edition)
 	}
 
 	// Create metadata
 	metadata := iops.createBackupMetadata(backupID, postgresIncluded, infrahubVersion, edition)
 	metadata.Checksums = checksums
-	if encrypt || encryptKey != "" {
-		metadata.Encrypted = true
-	}
+	metadata.Encrypted = slices.Contains(pipeline.Filters, "ecies")
+	metadata.Archive = pipeline.Info()
 
-	metadataBytes, err := json.MarshalIndent(metadata, "", "    ")
+	metadataBytes, err := marshalBackupMetadata(metadata)
 	if err != nil {
-		return fmt.Errorf("failed to marshal metadata: %w", err)
+		return err
 	}
 
 	if err := os.WriteFile(filepath.Join(backupDir, "backup_information.json"), metadataBytes, 0644); err != nil {
 		return fmt.Errorf("failed to write metadata: %w", err)
 	}
 
-	// Create tarball
+	// Write the archive through the compression and filter stages
 	logrus.Info("Creating backup archive...")
-	if err := createTarball(backupPath, workDir, "backup/"); err != nil {
-		return fmt.Errorf("failed to create archive: %w", err)
-	}
-
-	// Encrypt backup if requested
-	if encrypt || encryptKey != "" {
-		pubKey, loadErr := loadEncryptionKey(encryptKey)
-		if loadErr != nil {
-			return fmt.Errorf("failed to load encryption key: %w", loadErr)
-		}
-
-		encryptedPath := backupPath + ".enc"
-		logrus.Info("Encrypting backup archive...")
-		if err := EncryptFile(backupPath, encryptedPath, pubKey); err != nil {
-			return fmt.Errorf("failed to encrypt backup: %w", err)
-		}
-
-		if err := os.Remove(backupPath); err != nil {
-			logrus.Warnf("Failed to remove plaintext backup: %v", err)
-		}
-
-		backupPath = encryptedPath
+	archivePhase := iops.startPhase("archive")
+	backupPath, componentStats, err := pipeline.WriteWithStats(workDir, "backup/", filepath.Join(iops.config.BackupDir, backupID), ArchiveOptions{EncryptKey: encryptKey})
+	archivePhase.end(err)
+	if err != nil {
+		return err
 	}
 
 	logrus.Infof("Backup created: %s", backupPath)
 
 	// Show backup size
+	var backupSize int64
 	if stat, err := os.Stat(backupPath); err == nil {
+		backupSize = stat.Size()
 		logrus.Infof("Backup size: %s", formatBytes(stat.Size()))
 	}
 
+	if err := iops.recordBackupInCatalog(backupID, backupPath, "", backupSize); err != nil {
+		logrus.Warnf("Failed to record backup in catalog: %v", err)
+	} else if err := iops.reportBackupStats(backupID, componentStats, checksums); err != nil {
+		logrus.Warnf("Failed to record backup statistics: %v", err)
+	}
+
 	return nil
 }
 
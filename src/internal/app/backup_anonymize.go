@@ -0,0 +1,181 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// AnonymizeConfig selects the anonymization run after a restore, once the
+// databases are restored and before the application services start, so
+// production data restored into a lower environment is masked before anyone
+// can reach it.
+type AnonymizeConfig struct {
+	MaskSecrets   bool     // mask the secrets Infrahub stores with secretMaskers
+	CypherScripts []string // Cypher files run against the restored graph database
+	SQLScripts    []string // SQL files run against the restored task manager database
+}
+
+// Enabled reports whether any anonymization is configured.
+func (c AnonymizeConfig) Enabled() bool {
+	return c.MaskSecrets || len(c.CypherScripts) > 0 || len(c.SQLScripts) > 0
+}
+
+// Validate checks that every script can be read, so a typo fails the restore
+// before anything is stopped.
+func (c AnonymizeConfig) Validate() error {
+	for _, script := range append(append([]string{}, c.CypherScripts...), c.SQLScripts...) {
+		if _, err := os.ReadFile(script); err != nil {
+			return fmt.Errorf("cannot read anonymization script: %w", err)
+		}
+	}
+	return nil
+}
+
+// secretMasker replaces the values of one attribute of one node kind.
+type secretMasker struct {
+	Kind      string
+	Attribute string
+}
+
+// secretMaskers are the secrets --mask-secrets replaces: the passwords of
+// stored credentials and the API tokens of accounts. Account passwords are
+// stored hashed and left alone, so users can still log in.
+var secretMaskers = []secretMasker{
+	{Kind: "CorePasswordCredential", Attribute: "password"},
+	{Kind: "InternalAccountToken", Attribute: "token"},
+}
+
+// cypher returns the statement replacing every value of the attribute, in
+// every branch and at every point of its history, with a random one. Values
+// left without an attribute are deleted.
+func (m secretMasker) cypher() string {
+	return fmt.Sprintf("MATCH (:`%s`)-[:HAS_ATTRIBUTE]->(a:Attribute {name: '%s'})-[r:HAS_VALUE]->(v:AttributeValue)\n"+
+		"CREATE (a)-[masked:HAS_VALUE]->(:AttributeValue {value: 'masked-' + randomUUID(), is_default: false})\n"+
+		"SET masked = properties(r)\n"+
+		"DELETE r\n"+
+		"WITH DISTINCT v\n"+
+		"WHERE NOT (v)--()\n"+
+		"DELETE v;\n", m.Kind, m.Attribute)
+}
+
+// anonymizeRestoredData runs --mask-secrets and --anonymize-cypher when the
+// graph database was restored, and --anonymize-sql when the task manager
+// database was. A failure is returned so the caller leaves the application
+// services stopped.
+func (iops *InfrahubOps) anonymizeRestoredData(tempDir string, neo4jRestored, taskManagerRestored bool) (err error) {
+	cfg := iops.config.Anonymize
+	if !cfg.Enabled() {
+		return nil
+	}
+	logrus.Info("Anonymizing the restored data before the services start...")
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("%w\nThe Infrahub services were left stopped so the restored data stays unreachable; fix the script and restore again", err)
+		}
+	}()
+
+	if !neo4jRestored {
+		cfg.MaskSecrets, cfg.CypherScripts = false, nil
+	}
+	if cfg.MaskSecrets || len(cfg.CypherScripts) > 0 {
+		if err := iops.waitForNeo4jStart(neo4jProcessStopTimeout); err != nil {
+			return fmt.Errorf("anonymization failed: %w", err)
+		}
+	}
+	if cfg.MaskSecrets {
+		var statements strings.Builder
+		for _, masker := range secretMaskers {
+			statements.WriteString(masker.cypher())
+		}
+		if err := iops.runAnonymizeScript("database", tempDir, "mask_secrets.cypher", []byte(statements.String()), iops.cypherScriptCommand); err != nil {
+			return fmt.Errorf("failed to mask secrets: %w", err)
+		}
+		logrus.Info("Masked stored credential passwords and account tokens")
+	}
+	for _, script := range cfg.CypherScripts {
+		if err := iops.runAnonymizeFile("database", tempDir, script, iops.cypherScriptCommand); err != nil {
+			return err
+		}
+	}
+	if len(cfg.SQLScripts) > 0 {
+		if !taskManagerRestored {
+			logrus.Info("Skipping --anonymize-sql: the task manager database was not restored")
+		} else {
+			for _, script := range cfg.SQLScripts {
+				if err := iops.runAnonymizeFile("task-manager-db", tempDir, script, iops.sqlScriptCommand); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	logrus.Info("Restored data anonymized")
+	return nil
+}
+
+// runAnonymizeFile runs the local script file in service.
+func (iops *InfrahubOps) runAnonymizeFile(service, tempDir, script string, command func(string) ([]string, *ExecOptions)) error {
+	content, err := os.ReadFile(script)
+	if err != nil {
+		return fmt.Errorf("cannot read anonymization script: %w", err)
+	}
+	logrus.Infof("Running anonymization script %s...", script)
+	if err := iops.runAnonymizeScript(service, tempDir, filepath.Base(script), content, command); err != nil {
+		return fmt.Errorf("anonymization script %s failed: %w", script, err)
+	}
+	return nil
+}
+
+// runAnonymizeScript copies content into service and runs it with command.
+func (iops *InfrahubOps) runAnonymizeScript(service, tempDir, name string, content []byte, command func(string) ([]string, *ExecOptions)) error {
+	localPath := filepath.Join(tempDir, "infrahubops_anonymize_"+name)
+	// The database clients may run as another user than the owner of the copy.
+	if err := os.WriteFile(localPath, content, 0644); err != nil {
+		return err
+	}
+	defer os.Remove(localPath)
+
+	remotePath := "/tmp/infrahubops_anonymize_" + name
+	if err := iops.CopyTo(service, localPath, remotePath); err != nil {
+		return fmt.Errorf("failed to copy the script to %s: %w", service, err)
+	}
+	defer func() {
+		if _, err := iops.Exec(service, []string{"rm", "-f", remotePath}, nil); err != nil {
+			logrus.Warnf("Failed to remove temporary anonymization script: %v", err)
+		}
+	}()
+
+	cmd, opts := command(remotePath)
+	if output, err := iops.Exec(service, cmd, opts); err != nil {
+		return fmt.Errorf("%w\nOutput: %v", err, output)
+	}
+	return nil
+}
+
+// cypherScriptCommand runs a Cypher file against the Infrahub database,
+// stopping at the first failed statement.
+func (iops *InfrahubOps) cypherScriptCommand(path string) ([]string, *ExecOptions) {
+	return []string{
+		"cypher-shell",
+		"-u", iops.config.Neo4jUsername,
+		"-p" + iops.config.Neo4jPassword,
+		"-d", iops.config.Neo4jDatabase,
+		"-f", path,
+	}, nil
+}
+
+// sqlScriptCommand runs a SQL file against the task manager database in one
+// transaction, which a failed statement rolls back.
+func (iops *InfrahubOps) sqlScriptCommand(path string) ([]string, *ExecOptions) {
+	opts := &ExecOptions{Env: map[string]string{
+		"PGPASSWORD": iops.config.PostgresPassword,
+	}}
+	return []string{
+		"psql", "-h", "localhost", "-U", iops.config.PostgresUsername, "-d", iops.config.PostgresDatabase,
+		"-v", "ON_ERROR_STOP=1", "--single-transaction", "-f", path,
+	}, opts
+}
@@ -0,0 +1,117 @@
+package app
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAnonymizeRestoredData(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "scrub.cypher", "MATCH (n:TestPerson) SET n.email = 'x';\n")
+	writeTestFile(t, dir, "scrub.sql", "UPDATE variable SET value = '\"\"';\n")
+	cypher, sql := filepath.Join(dir, "scrub.cypher"), filepath.Join(dir, "scrub.sql")
+
+	tests := []struct {
+		name          string
+		config        AnonymizeConfig
+		neo4j         bool
+		taskManager   bool
+		psqlErr       error
+		wantCommands  []string
+		avoidCommands []string
+		wantErr       string
+	}{
+		{
+			name:        "everything",
+			config:      AnonymizeConfig{MaskSecrets: true, CypherScripts: []string{cypher}, SQLScripts: []string{sql}},
+			neo4j:       true,
+			taskManager: true,
+			wantCommands: []string{
+				"exec -T database cypher-shell -u neo4j -psecret -d neo4j -f /tmp/infrahubops_anonymize_mask_secrets.cypher",
+				"exec -T database cypher-shell -u neo4j -psecret -d neo4j -f /tmp/infrahubops_anonymize_scrub.cypher",
+				"exec -T -e PGPASSWORD=pgsecret task-manager-db psql -h localhost -U postgres -d prefect -v ON_ERROR_STOP=1 --single-transaction -f /tmp/infrahubops_anonymize_scrub.sql",
+				"exec -T task-manager-db rm -f /tmp/infrahubops_anonymize_scrub.sql",
+			},
+		},
+		{
+			name:          "task manager database not restored",
+			config:        AnonymizeConfig{CypherScripts: []string{cypher}, SQLScripts: []string{sql}},
+			neo4j:         true,
+			wantCommands:  []string{"-f /tmp/infrahubops_anonymize_scrub.cypher"},
+			avoidCommands: []string{"psql"},
+		},
+		{
+			name:          "graph database not restored",
+			config:        AnonymizeConfig{MaskSecrets: true, SQLScripts: []string{sql}},
+			taskManager:   true,
+			wantCommands:  []string{"-f /tmp/infrahubops_anonymize_scrub.sql"},
+			avoidCommands: []string{"cypher-shell"},
+		},
+		{
+			name:          "disabled",
+			neo4j:         true,
+			taskManager:   true,
+			avoidCommands: []string{"cypher-shell", "psql"},
+		},
+		{
+			name:        "failed script",
+			config:      AnonymizeConfig{SQLScripts: []string{sql}},
+			taskManager: true,
+			psqlErr:     errors.New("exit status 3"),
+			wantErr:     "left stopped",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeExecutor().on("psql", "ERROR: relation does not exist", tt.psqlErr)
+			iops := newFakeDockerOps(fake)
+			iops.config.Neo4jUsername, iops.config.Neo4jPassword, iops.config.Neo4jDatabase = "neo4j", "secret", "neo4j"
+			iops.config.PostgresUsername, iops.config.PostgresPassword, iops.config.PostgresDatabase = "postgres", "pgsecret", "prefect"
+			iops.config.Anonymize = tt.config
+
+			err := iops.anonymizeRestoredData(t.TempDir(), tt.neo4j, tt.taskManager)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) || !strings.Contains(err.Error(), "scrub.sql") {
+					t.Fatalf("anonymizeRestoredData() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.wantCommands {
+				if len(fake.commands(want)) == 0 {
+					t.Errorf("missing command %q in %v", want, fake.commands(""))
+				}
+			}
+			for _, avoid := range tt.avoidCommands {
+				if got := fake.commands(avoid); len(got) != 0 {
+					t.Errorf("unexpected commands %v", got)
+				}
+			}
+		})
+	}
+}
+
+func TestSecretMaskerCypher(t *testing.T) {
+	statement := secretMasker{Kind: "CorePasswordCredential", Attribute: "password"}.cypher()
+	for _, want := range []string{"(:`CorePasswordCredential`)", "{name: 'password'}", "randomUUID()", "SET masked = properties(r)", "DELETE r"} {
+		if !strings.Contains(statement, want) {
+			t.Errorf("statement lacks %q:\n%s", want, statement)
+		}
+	}
+}
+
+func TestAnonymizeConfigValidate(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "scrub.cypher", "RETURN 1;\n")
+
+	if err := (AnonymizeConfig{CypherScripts: []string{filepath.Join(dir, "scrub.cypher")}}).Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+	if err := (AnonymizeConfig{SQLScripts: []string{filepath.Join(dir, "missing.sql")}}).Validate(); err == nil {
+		t.Error("Validate() with a missing script succeeded")
+	}
+}
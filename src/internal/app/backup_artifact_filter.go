@@ -0,0 +1,109 @@
+package app
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ArtifactFilter holds the include/exclude glob patterns applied to the
+// object store component. Excluded lists the objects that were skipped and is
+// recorded in backup metadata so a restore can tell what is missing.
+type ArtifactFilter struct {
+	Include  []string `json:"include,omitempty"`
+	Exclude  []string `json:"exclude,omitempty"`
+	Excluded []string `json:"excluded,omitempty"`
+}
+
+// NewArtifactFilter validates the patterns and returns nil when there are
+// none.
+func NewArtifactFilter(include, exclude []string) (*ArtifactFilter, error) {
+	filter := &ArtifactFilter{}
+	for _, pattern := range include {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			filter.Include = append(filter.Include, pattern)
+		}
+	}
+	for _, pattern := range exclude {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			filter.Exclude = append(filter.Exclude, pattern)
+		}
+	}
+	for _, pattern := range append(append([]string(nil), filter.Include...), filter.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid artifact pattern %q: %w", pattern, err)
+		}
+	}
+	if len(filter.Include) == 0 && len(filter.Exclude) == 0 {
+		return nil, nil
+	}
+	return filter, nil
+}
+
+// Keep reports whether the object at relPath (bucket/key) is backed up.
+// Patterns match either the full path or the file name, so "*.iso" matches in
+// every bucket. Exclusions win over inclusions.
+func (f *ArtifactFilter) Keep(relPath string) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.Include) > 0 && !matchArtifactPattern(f.Include, relPath) {
+		return false
+	}
+	return !matchArtifactPattern(f.Exclude, relPath)
+}
+
+func matchArtifactPattern(patterns []string, relPath string) bool {
+	base := path.Base(relPath)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, relPath); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, base); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// applyArtifactFilter removes the objects the filter skips from dumpDir in
+// the object store container and records them in the filter.
+func (iops *InfrahubOps) applyArtifactFilter(filter *ArtifactFilter, dumpDir string) error {
+	if filter == nil {
+		return nil
+	}
+
+	output, err := iops.Exec(objectStoreService, []string{"find", dumpDir, "-type", "f"}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to list object store files: %w\nOutput: %v", err, output)
+	}
+
+	var remove []string
+	for _, line := range strings.Split(output, "\n") {
+		file := strings.TrimSpace(line)
+		relPath := strings.TrimPrefix(file, strings.TrimSuffix(dumpDir, "/")+"/")
+		if file == "" || relPath == file {
+			continue
+		}
+		if !filter.Keep(relPath) {
+			filter.Excluded = append(filter.Excluded, relPath)
+			remove = append(remove, file)
+		}
+	}
+	sort.Strings(filter.Excluded)
+
+	for start := 0; start < len(remove); start += 100 {
+		end := min(start+100, len(remove))
+		if output, err := iops.Exec(objectStoreService, append([]string{"rm", "-f"}, remove[start:end]...), nil); err != nil {
+			return fmt.Errorf("failed to remove excluded artifacts: %w\nOutput: %v", err, output)
+		}
+	}
+
+	if len(filter.Excluded) > 0 {
+		logrus.WithField("count", len(filter.Excluded)).Info("Excluded artifacts matching --artifacts-include/--artifacts-exclude")
+	}
+	return nil
+}
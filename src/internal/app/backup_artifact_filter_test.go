@@ -0,0 +1,56 @@
+package app
+
+import "testing"
+
+func TestNewArtifactFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "no patterns", wantNil: true},
+		{name: "blank patterns", exclude: []string{" ", ""}, wantNil: true},
+		{name: "exclude", exclude: []string{"*.iso"}},
+		{name: "include", include: []string{"infrahub-storage/*"}},
+		{name: "bad pattern", exclude: []string{"[a-"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := NewArtifactFilter(tt.include, tt.exclude)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewArtifactFilter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (filter == nil) != tt.wantNil {
+				t.Errorf("NewArtifactFilter() = %v, wantNil %v", filter, tt.wantNil)
+			}
+		})
+	}
+}
+
+func TestArtifactFilterKeep(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  *ArtifactFilter
+		relPath string
+		want    bool
+	}{
+		{name: "nil filter keeps everything", relPath: "bucket/a.iso", want: true},
+		{name: "exclude by file name", filter: &ArtifactFilter{Exclude: []string{"*.iso"}}, relPath: "bucket/images/a.iso", want: false},
+		{name: "exclude does not match", filter: &ArtifactFilter{Exclude: []string{"*.iso"}}, relPath: "bucket/config.txt", want: true},
+		{name: "exclude by full path", filter: &ArtifactFilter{Exclude: []string{"scratch/*"}}, relPath: "scratch/tmp.bin", want: false},
+		{name: "include matches", filter: &ArtifactFilter{Include: []string{"infrahub-storage/*"}}, relPath: "infrahub-storage/abc", want: true},
+		{name: "include does not match", filter: &ArtifactFilter{Include: []string{"infrahub-storage/*"}}, relPath: "other/abc", want: false},
+		{name: "exclude wins over include", filter: &ArtifactFilter{Include: []string{"*"}, Exclude: []string{"*.iso"}}, relPath: "a.iso", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Keep(tt.relPath); got != tt.want {
+				t.Errorf("Keep(%q) = %v, want %v", tt.relPath, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,403 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Audit check outcomes. Skipped checks do not count towards the score.
+const (
+	AuditPass = "pass"
+	AuditWarn = "warn"
+	AuditFail = "fail"
+	AuditSkip = "skip"
+)
+
+// Disk utilisation thresholds, in percent.
+const (
+	auditDiskWarnPercent = 80
+	auditDiskFailPercent = 90
+)
+
+// AuditCheck is the outcome of one read-only audit check.
+type AuditCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+	Weight int    `json:"weight"`
+}
+
+// AuditReport collects the audit checks and their weighted score out of 100.
+type AuditReport struct {
+	Target string       `json:"target"`
+	Checks []AuditCheck `json:"checks"`
+	Score  int          `json:"score"`
+}
+
+func (r *AuditReport) add(name, status, detail string, weight int) {
+	r.Checks = append(r.Checks, AuditCheck{Name: name, Status: status, Detail: detail, Weight: weight})
+}
+
+// score weighs passed checks fully and warnings at half. Skipped checks are
+// left out; a report with nothing to score gets 0.
+func (r *AuditReport) score() int {
+	total, earned := 0, 0
+	for _, check := range r.Checks {
+		switch check.Status {
+		case AuditPass:
+			earned += 2 * check.Weight
+		case AuditWarn:
+			earned += check.Weight
+		case AuditSkip:
+			continue
+		}
+		total += 2 * check.Weight
+	}
+	if total == 0 {
+		return 0
+	}
+	return earned * 100 / total
+}
+
+// Failed reports whether any check failed.
+func (r *AuditReport) Failed() bool {
+	for _, check := range r.Checks {
+		if check.Status == AuditFail {
+			return true
+		}
+	}
+	return false
+}
+
+// Write prints the report as a table followed by the score.
+func (r *AuditReport) Write(w io.Writer) {
+	if r.Target != "" {
+		fmt.Fprintf(w, "Target: %s\n\n", r.Target)
+	}
+	fmt.Fprintf(w, "%-24s  %-6s  %s\n", "CHECK", "STATUS", "DETAIL")
+	for _, check := range r.Checks {
+		fmt.Fprintf(w, "%-24s  %-6s  %s\n", check.Name, check.Status, check.Detail)
+	}
+	fmt.Fprintf(w, "\nScore: %d/100\n", r.Score)
+}
+
+// Audit inspects the running deployment without modifying it: the Neo4j
+// settings backups depend on, the task manager database WAL settings, disk
+// utilisation of the database volumes and the backup directory, and whether
+// the latest backup archive passes its checksums. decryptKey is needed to
+// check an encrypted archive.
+func (iops *InfrahubOps) Audit(decryptKey string) (*AuditReport, error) {
+	if err := iops.DetectEnvironment(); err != nil {
+		return nil, err
+	}
+
+	report := &AuditReport{}
+	if backend, err := iops.ensureBackend(); err == nil {
+		report.Target = backend.Info()
+	}
+
+	iops.auditNeo4j(report)
+	iops.auditPostgres(report)
+	iops.auditDisk(report)
+	iops.auditLatestBackup(report, decryptKey)
+
+	report.Score = report.score()
+	return report, nil
+}
+
+// neo4jBackupSettings are the settings read from Neo4j, with their 4.x names.
+var neo4jBackupSettings = []string{
+	"server.backup.enabled", "dbms.backup.enabled",
+	"server.backup.listen_address", "dbms.backup.listen_address",
+	"db.tx_log.rotation.retention_policy", "dbms.tx_log.rotation.retention_policy",
+}
+
+func (iops *InfrahubOps) auditNeo4j(report *AuditReport) {
+	edition, err := iops.detectNeo4jEdition()
+	if err != nil {
+		report.add("neo4j-edition", AuditFail, err.Error(), 2)
+		return
+	}
+	edition = strings.ToLower(edition)
+	if edition == neo4jEditionCommunity {
+		report.add("neo4j-edition", AuditWarn, "community: backups stop Neo4j and the Infrahub services", 1)
+	} else {
+		report.add("neo4j-edition", AuditPass, edition+": online backups available", 1)
+	}
+
+	settings, err := iops.neo4jSettings(neo4jBackupSettings)
+	if err != nil {
+		report.add("neo4j-settings", AuditSkip, err.Error(), 0)
+		return
+	}
+	setting := func(names ...string) (string, bool) {
+		for _, name := range names {
+			if value, ok := settings[name]; ok {
+				return value, true
+			}
+		}
+		return "", false
+	}
+
+	if edition != neo4jEditionCommunity {
+		enabled, ok := setting("server.backup.enabled", "dbms.backup.enabled")
+		switch {
+		case !ok:
+			report.add("neo4j-backup-enabled", AuditWarn, "backup setting not reported", 2)
+		case strings.EqualFold(enabled, "true"):
+			address, _ := setting("server.backup.listen_address", "dbms.backup.listen_address")
+			report.add("neo4j-backup-enabled", AuditPass, "online backup listening on "+address, 2)
+		default:
+			report.add("neo4j-backup-enabled", AuditFail, "server.backup.enabled is false; neo4j-admin backup will fail", 2)
+		}
+	}
+
+	if retention, ok := setting("db.tx_log.rotation.retention_policy", "dbms.tx_log.rotation.retention_policy"); ok {
+		if isNeo4jKeepNone(retention) {
+			report.add("neo4j-tx-log-retention", AuditWarn, fmt.Sprintf("retention_policy=%s keeps no transaction logs for incremental backups", retention), 1)
+		} else {
+			report.add("neo4j-tx-log-retention", AuditPass, "retention_policy="+retention, 1)
+		}
+	}
+}
+
+// neo4jSettings reads the named settings with SHOW SETTINGS, falling back to
+// dbms.listConfig on servers older than 5.x.
+func (iops *InfrahubOps) neo4jSettings(names []string) (map[string]string, error) {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = "'" + name + "'"
+	}
+	filter := "WHERE name IN [" + strings.Join(quoted, ", ") + "] RETURN name, value"
+
+	var lastErr error
+	for _, query := range []string{
+		"SHOW SETTINGS YIELD name, value " + filter,
+		"CALL dbms.listConfig() YIELD name, value " + filter,
+	} {
+		output, err := iops.Exec("database", []string{
+			"cypher-shell",
+			"-u", iops.config.Neo4jUsername,
+			"-p" + iops.config.Neo4jPassword,
+			"-d", "system",
+			"--format", "plain",
+			query,
+		}, nil)
+		if err == nil {
+			return parseNeo4jSettings(output), nil
+		}
+		lastErr = err
+	}
+	return nil, fmt.Errorf("failed to read neo4j settings: %w", lastErr)
+}
+
+// parseNeo4jSettings parses the plain cypher-shell output of a name, value
+// query; the header row is skipped.
+func parseNeo4jSettings(output string) map[string]string {
+	settings := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		name, value, ok := strings.Cut(strings.TrimSpace(line), ", ")
+		if !ok {
+			continue
+		}
+		name = strings.Trim(name, "\"")
+		if name == "name" {
+			continue
+		}
+		settings[name] = strings.Trim(value, "\"")
+	}
+	return settings
+}
+
+func isNeo4jKeepNone(retention string) bool {
+	retention = strings.ToLower(strings.TrimSpace(retention))
+	return retention == "false" || strings.HasPrefix(retention, "keep_none")
+}
+
+func (iops *InfrahubOps) auditPostgres(report *AuditReport) {
+	opts := &ExecOptions{Env: map[string]string{"PGPASSWORD": iops.config.PostgresPassword}}
+	query := func(sql string) (string, error) {
+		output, err := iops.Exec("task-manager-db",
+			[]string{"psql", "-h", "localhost", "-U", iops.config.PostgresUsername, "-d", iops.config.PostgresDatabase, "-tAc", sql},
+			opts)
+		return strings.TrimSpace(output), err
+	}
+
+	walLevel, err := query("SHOW wal_level")
+	if err != nil {
+		report.add("postgres-wal", AuditSkip, "task manager database not reachable", 0)
+		return
+	}
+	archiveMode, _ := query("SHOW archive_mode")
+	detail := fmt.Sprintf("wal_level=%s archive_mode=%s", walLevel, archiveMode)
+	if walLevel == "minimal" {
+		report.add("postgres-wal", AuditWarn, detail+"; no replication or point-in-time recovery possible", 1)
+		return
+	}
+	report.add("postgres-wal", AuditPass, detail, 1)
+}
+
+func (iops *InfrahubOps) auditDisk(report *AuditReport) {
+	for _, volume := range []struct{ name, service, path string }{
+		{"disk-database", "database", "/data"},
+		{"disk-task-manager-db", "task-manager-db", "/var/lib/postgresql/data"},
+	} {
+		output, err := iops.Exec(volume.service, []string{"df", "-P", volume.path}, nil)
+		if err != nil {
+			report.add(volume.name, AuditSkip, fmt.Sprintf("could not run df in %s", volume.service), 0)
+			continue
+		}
+		addDiskCheck(report, volume.name, volume.path, output)
+	}
+
+	output, err := iops.executor.runCommand("df", "-P", iops.config.BackupDir)
+	if err != nil {
+		report.add("disk-backup-dir", AuditSkip, "could not run df for "+iops.config.BackupDir, 0)
+		return
+	}
+	addDiskCheck(report, "disk-backup-dir", iops.config.BackupDir, output)
+}
+
+func addDiskCheck(report *AuditReport, name, path, dfOutput string) {
+	used, err := parseDfUsage(dfOutput)
+	if err != nil {
+		report.add(name, AuditSkip, err.Error(), 0)
+		return
+	}
+	detail := fmt.Sprintf("%s %d%% used", path, used)
+	switch {
+	case used >= auditDiskFailPercent:
+		report.add(name, AuditFail, detail, 2)
+	case used >= auditDiskWarnPercent:
+		report.add(name, AuditWarn, detail, 2)
+	default:
+		report.add(name, AuditPass, detail, 2)
+	}
+}
+
+// parseDfUsage returns the use percentage from POSIX df -P output.
+func parseDfUsage(output string) (int, error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(lines) < 2 || len(fields) < 5 {
+		return 0, fmt.Errorf("unexpected df output: %s", strings.TrimSpace(output))
+	}
+	used, err := strconv.Atoi(strings.TrimSuffix(fields[4], "%"))
+	if err != nil {
+		return 0, fmt.Errorf("unexpected df output: %s", strings.TrimSpace(output))
+	}
+	return used, nil
+}
+
+func (iops *InfrahubOps) auditLatestBackup(report *AuditReport, decryptKey string) {
+	if iops.config.Backend == BackendPlakar {
+		report.add("latest-backup", AuditSkip, "not supported with the plakar backend", 0)
+		return
+	}
+
+	ref, createdAt, err := latestBackupArchive(iops.config.BackupDir)
+	if err != nil {
+		report.add("latest-backup", AuditFail, err.Error(), 3)
+		return
+	}
+	if strings.HasSuffix(ref, ".enc") && decryptKey == "" {
+		report.add("latest-backup", AuditWarn, fmt.Sprintf("%s is encrypted; pass --decrypt-key to verify it", filepath.Base(ref)), 3)
+		return
+	}
+
+	metadata, err := iops.verifyBackupArchive(ref, decryptKey)
+	if err != nil {
+		report.add("latest-backup", AuditFail, fmt.Sprintf("%s: %v", filepath.Base(ref), err), 3)
+		return
+	}
+	detail := fmt.Sprintf("%s passes its checksums", metadata.BackupID)
+	if !createdAt.IsZero() {
+		detail += fmt.Sprintf(" (%s old)", time.Since(createdAt).Round(time.Minute))
+	}
+	report.add("latest-backup", AuditPass, detail, 3)
+}
+
+// latestBackupArchive returns the newest archive known to the backup catalog,
+// or the newest archive in backupDir when the catalog is empty.
+func latestBackupArchive(backupDir string) (string, time.Time, error) {
+	catalog, err := loadBackupCatalog(backupDir)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	var ref string
+	var newest time.Time
+	for _, entry := range catalog.Entries {
+		createdAt, err := time.Parse(time.RFC3339, entry.CreatedAt)
+		if err != nil || (ref != "" && !createdAt.After(newest)) {
+			continue
+		}
+		switch {
+		case entry.LocalPath != "" && fileExists(entry.LocalPath):
+			ref, newest = entry.LocalPath, createdAt
+		case entry.S3URI != "":
+			ref, newest = entry.S3URI, createdAt
+		}
+	}
+	if ref != "" {
+		return ref, newest, nil
+	}
+
+	var archives []string
+	for _, pattern := range []string{"*.tar.gz", "*.tar.gz.enc"} {
+		matches, _ := filepath.Glob(filepath.Join(backupDir, pattern))
+		archives = append(archives, matches...)
+	}
+	if len(archives) == 0 {
+		return "", time.Time{}, fmt.Errorf("no backup found in %s", backupDir)
+	}
+	modTimes := map[string]time.Time{}
+	for _, archive := range archives {
+		if info, err := os.Stat(archive); err == nil {
+			modTimes[archive] = info.ModTime()
+		}
+	}
+	sort.Slice(archives, func(i, j int) bool { return modTimes[archives[i]].After(modTimes[archives[j]]) })
+	return archives[0], modTimes[archives[0]], nil
+}
+
+// verifyBackupArchive extracts backupFile into a temporary directory and
+// validates its metadata and checksums.
+func (iops *InfrahubOps) verifyBackupArchive(backupFile, decryptKey string) (*BackupMetadata, error) {
+	archive, cleanupArchive, err := iops.prepareSharedRestoreArchive(backupFile, decryptKey)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanupArchive()
+
+	workDir, err := os.MkdirTemp("", "infrahub_audit_*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	logrus.Debugf("Extracting %s for audit", backupFile)
+	if _, err := extractArchive(archive, workDir); err != nil {
+		return nil, fmt.Errorf("failed to extract backup: %w", err)
+	}
+	metadataBytes, err := os.ReadFile(filepath.Join(workDir, "backup", backupMetadataFilename))
+	if err != nil {
+		return nil, fmt.Errorf("invalid backup file: missing metadata")
+	}
+	metadata, err := parseBackupMetadata(metadataBytes)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateBackupChecksums(workDir, metadata, false); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
@@ -0,0 +1,175 @@
+package app
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseNeo4jSettings(t *testing.T) {
+	output := "name, value\n\"server.backup.enabled\", \"true\"\n\"server.backup.listen_address\", \"127.0.0.1:6362\"\n"
+	want := map[string]string{
+		"server.backup.enabled":        "true",
+		"server.backup.listen_address": "127.0.0.1:6362",
+	}
+	if got := parseNeo4jSettings(output); !reflect.DeepEqual(got, want) {
+		t.Fatalf("parseNeo4jSettings() = %v, want %v", got, want)
+	}
+}
+
+func TestParseDfUsage(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    int
+		wantErr bool
+	}{
+		{
+			name:   "posix output",
+			output: "Filesystem     1024-blocks     Used Available Capacity Mounted on\n/dev/sda1         1000000   870000    130000      87% /data\n",
+			want:   87,
+		},
+		{name: "header only", output: "Filesystem 1024-blocks Used Available Capacity Mounted on", wantErr: true},
+		{name: "garbage", output: "df: /data: No such file or directory\nfoo bar baz qux n/a", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDfUsage(tt.output)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseDfUsage() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseDfUsage() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuditReportScore(t *testing.T) {
+	tests := []struct {
+		name   string
+		checks []AuditCheck
+		want   int
+	}{
+		{name: "empty", want: 0},
+		{
+			name:   "all pass",
+			checks: []AuditCheck{{Status: AuditPass, Weight: 1}, {Status: AuditPass, Weight: 3}},
+			want:   100,
+		},
+		{
+			name:   "warnings count half",
+			checks: []AuditCheck{{Status: AuditPass, Weight: 1}, {Status: AuditWarn, Weight: 1}},
+			want:   75,
+		},
+		{
+			name:   "skipped checks are ignored",
+			checks: []AuditCheck{{Status: AuditPass, Weight: 1}, {Status: AuditFail, Weight: 1}, {Status: AuditSkip, Weight: 5}},
+			want:   50,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := &AuditReport{Checks: tt.checks}
+			if got := report.score(); got != tt.want {
+				t.Errorf("score() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuditDeploymentChecks(t *testing.T) {
+	fake := newFakeExecutor().
+		on("YIELD edition", "edition\n\"enterprise\"\n", nil).
+		on("SHOW SETTINGS", "name, value\n\"server.backup.enabled\", \"false\"\n\"db.tx_log.rotation.retention_policy\", \"keep_none\"\n", nil).
+		on("SHOW wal_level", "replica\n", nil).
+		on("SHOW archive_mode", "off\n", nil).
+		on("exec -T database df", "Filesystem 1024-blocks Used Available Capacity Mounted on\n/dev/sda1 100 95 5 95% /data\n", nil).
+		on("exec -T task-manager-db df", "Filesystem 1024-blocks Used Available Capacity Mounted on\n/dev/sdb1 100 10 90 10% /var/lib/postgresql/data\n", nil).
+		on("df -P", "", errors.New("df: not found"))
+	iops := newFakeDockerOps(fake)
+
+	report := &AuditReport{}
+	iops.auditNeo4j(report)
+	iops.auditPostgres(report)
+	iops.auditDisk(report)
+
+	want := map[string]string{
+		"neo4j-edition":          AuditPass,
+		"neo4j-backup-enabled":   AuditFail,
+		"neo4j-tx-log-retention": AuditWarn,
+		"postgres-wal":           AuditPass,
+		"disk-database":          AuditFail,
+		"disk-task-manager-db":   AuditPass,
+		"disk-backup-dir":        AuditSkip,
+	}
+	got := map[string]string{}
+	for _, check := range report.Checks {
+		got[check.Name] = check.Status
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("audit statuses = %v, want %v", got, want)
+	}
+	if len(fake.commands("dbms.listConfig")) != 0 {
+		t.Error("dbms.listConfig queried although SHOW SETTINGS succeeded")
+	}
+}
+
+func TestLatestBackupArchive(t *testing.T) {
+	t.Run("catalog entries win", func(t *testing.T) {
+		dir := t.TempDir()
+		older := filepath.Join(dir, "infrahub_backup_older.tar.gz")
+		newer := filepath.Join(dir, "infrahub_backup_newer.tar.gz")
+		for _, path := range []string{older, newer} {
+			if err := os.WriteFile(path, []byte("x"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		catalog, _ := loadBackupCatalog(dir)
+		catalog.upsert(CatalogEntry{BackupID: "newer", LocalPath: newer, CreatedAt: "2025-02-01T00:00:00Z"})
+		catalog.upsert(CatalogEntry{BackupID: "older", LocalPath: older, CreatedAt: "2025-01-01T00:00:00Z"})
+		catalog.upsert(CatalogEntry{BackupID: "gone", LocalPath: filepath.Join(dir, "gone.tar.gz"), CreatedAt: "2025-03-01T00:00:00Z"})
+		if err := catalog.save(); err != nil {
+			t.Fatal(err)
+		}
+
+		ref, createdAt, err := latestBackupArchive(dir)
+		if err != nil {
+			t.Fatalf("latestBackupArchive() error = %v", err)
+		}
+		if ref != newer || createdAt.Format(time.DateOnly) != "2025-02-01" {
+			t.Errorf("latestBackupArchive() = %s, %s, want %s", ref, createdAt, newer)
+		}
+	})
+
+	t.Run("newest archive without catalog", func(t *testing.T) {
+		dir := t.TempDir()
+		older := filepath.Join(dir, "a.tar.gz")
+		newer := filepath.Join(dir, "b.tar.gz.enc")
+		for i, path := range []string{older, newer} {
+			if err := os.WriteFile(path, []byte("x"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			modTime := time.Now().Add(time.Duration(i-2) * time.Hour)
+			if err := os.Chtimes(path, modTime, modTime); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		ref, _, err := latestBackupArchive(dir)
+		if err != nil || ref != newer {
+			t.Errorf("latestBackupArchive() = %s, %v, want %s", ref, err, newer)
+		}
+	})
+
+	t.Run("empty directory", func(t *testing.T) {
+		if _, _, err := latestBackupArchive(t.TempDir()); err == nil {
+			t.Error("latestBackupArchive() error = nil, want an error")
+		}
+	})
+}
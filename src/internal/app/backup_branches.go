@@ -0,0 +1,90 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// graphQLQueryScript posts the GraphQL query given as its first argument,
+// with the JSON variables given as the optional second one, to the local
+// infrahub-server, on the branch given as the optional third one,
+// authenticating with INFRAHUB_API_TOKEN when the container defines it.
+const graphQLQueryScript = `import json, os, sys, urllib.parse, urllib.request
+variables = json.loads(sys.argv[2]) if len(sys.argv) > 2 else {}
+url = "http://localhost:8000/graphql" + ("/" + urllib.parse.quote(sys.argv[3]) if len(sys.argv) > 3 else "")
+request = urllib.request.Request(url, data=json.dumps({"query": sys.argv[1], "variables": variables}).encode(), headers={"Content-Type": "application/json"})
+token = os.environ.get("INFRAHUB_API_TOKEN")
+if token:
+    request.add_header("X-INFRAHUB-KEY", token)
+print(urllib.request.urlopen(request, timeout=60).read().decode())`
+
+// branchQueries are tried in order; Infrahub releases before branch status
+// reject the first one.
+var branchQueries = []string{
+	"query { Branch { name status sync_with_git is_default branched_from } }",
+	"query { Branch { name sync_with_git is_default branched_from } }",
+}
+
+// BranchInfo is an Infrahub branch as it existed when the backup was taken.
+type BranchInfo struct {
+	Name         string `json:"name"`
+	Status       string `json:"status,omitempty"`
+	SyncWithGit  bool   `json:"sync_with_git"`
+	IsDefault    bool   `json:"is_default,omitempty"`
+	BranchedFrom string `json:"branched_from,omitempty"`
+}
+
+// exportBranchList returns the branches of the running Infrahub, the default
+// branch first.
+func (iops *InfrahubOps) exportBranchList() ([]BranchInfo, error) {
+	var err error
+	for _, query := range branchQueries {
+		var output string
+		output, err = iops.Exec("infrahub-server", []string{"python", "-c", graphQLQueryScript, query}, nil)
+		if err != nil {
+			err = fmt.Errorf("failed to query branches: %w", err)
+			continue
+		}
+		var branches []BranchInfo
+		if branches, err = parseBranchList(output); err == nil {
+			return branches, nil
+		}
+		logrus.Debugf("Branch query %q failed: %v", query, err)
+	}
+	return nil, err
+}
+
+// parseBranchList reads the GraphQL response to a branch query.
+func parseBranchList(output string) ([]BranchInfo, error) {
+	start := strings.IndexByte(output, '{')
+	if start < 0 {
+		return nil, fmt.Errorf("no JSON in branch query output")
+	}
+	var response struct {
+		Data struct {
+			Branch []BranchInfo `json:"Branch"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal([]byte(output[start:]), &response); err != nil {
+		return nil, fmt.Errorf("invalid branch query output: %w", err)
+	}
+	if len(response.Errors) > 0 {
+		return nil, fmt.Errorf("branch query failed: %s", response.Errors[0].Message)
+	}
+
+	branches := response.Data.Branch
+	sort.SliceStable(branches, func(i, j int) bool {
+		if branches[i].IsDefault != branches[j].IsDefault {
+			return branches[i].IsDefault
+		}
+		return branches[i].Name < branches[j].Name
+	})
+	return branches, nil
+}
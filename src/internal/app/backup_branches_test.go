@@ -0,0 +1,67 @@
+package app
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseBranchList(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    []BranchInfo
+		wantErr string
+	}{
+		{
+			name:   "default branch first",
+			output: `{"data":{"Branch":[{"name":"feature","status":"OPEN","sync_with_git":false,"is_default":false,"branched_from":"2025-09-01T10:00:00Z"},{"name":"main","status":"OPEN","sync_with_git":true,"is_default":true},{"name":"dev","sync_with_git":true}]}}`,
+			want: []BranchInfo{
+				{Name: "main", Status: "OPEN", SyncWithGit: true, IsDefault: true},
+				{Name: "dev", SyncWithGit: true},
+				{Name: "feature", Status: "OPEN", BranchedFrom: "2025-09-01T10:00:00Z"},
+			},
+		},
+		{
+			name:   "warnings before the response",
+			output: "DeprecationWarning: something\n{\"data\":{\"Branch\":[{\"name\":\"main\",\"is_default\":true}]}}",
+			want:   []BranchInfo{{Name: "main", IsDefault: true}},
+		},
+		{name: "graphql error", output: `{"data":null,"errors":[{"message":"Cannot query field 'status'"}]}`, wantErr: "Cannot query field"},
+		{name: "not json", output: "Traceback", wantErr: "no JSON"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseBranchList(tt.output)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseBranchList() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseBranchList() error = %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("parseBranchList() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExportBranchListFallsBackWithoutStatus(t *testing.T) {
+	fake := newFakeExecutor().
+		on("{ name status", `{"errors":[{"message":"Cannot query field 'status' on type 'Branch'"}]}`, nil).
+		on("{ name sync_with_git", `{"data":{"Branch":[{"name":"main","sync_with_git":true,"is_default":true}]}}`, nil)
+
+	branches, err := newFakeDockerOps(fake).exportBranchList()
+	if err != nil {
+		t.Fatalf("exportBranchList() error = %v", err)
+	}
+	if want := []BranchInfo{{Name: "main", SyncWithGit: true, IsDefault: true}}; !slices.Equal(branches, want) {
+		t.Errorf("exportBranchList() = %+v, want %+v", branches, want)
+	}
+	if queries := fake.commands("graphql"); len(queries) != 2 {
+		t.Errorf("queries = %d, want 2", len(queries))
+	}
+}
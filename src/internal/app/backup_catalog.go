@@ -0,0 +1,200 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// backupCatalogFilename is the catalog file kept alongside the archives in BackupDir.
+const backupCatalogFilename = "backup_catalog.json"
+
+// CatalogEntry records a single backup archive produced by this tool.
+type CatalogEntry struct {
+	BackupID       string           `json:"backup_id"`
+	Filename       string           `json:"filename"`
+	LocalPath      string           `json:"local_path,omitempty"`
+	S3URI          string           `json:"s3_uri,omitempty"`
+	CreatedAt      string           `json:"created_at"`
+	SizeBytes      int64            `json:"size_bytes,omitempty"`
+	Held           bool             `json:"held,omitempty"`
+	HoldReason     string           `json:"hold_reason,omitempty"`
+	HeldAt         string           `json:"held_at,omitempty"`
+	Verified       bool             `json:"verified,omitempty"`
+	VerifiedAt     string           `json:"verified_at,omitempty"`
+	VerifyError    string           `json:"verify_error,omitempty"`
+	Components     []ComponentStats `json:"components,omitempty"`
+	RetentionClass string           `json:"retention_class,omitempty"`
+}
+
+// BackupCatalog is the local index of backup archives stored in BackupDir.
+type BackupCatalog struct {
+	path    string
+	Entries []CatalogEntry `json:"entries"`
+}
+
+// loadBackupCatalog reads the catalog from backupDir. A missing catalog is not an
+// error: an empty catalog bound to the expected path is returned instead.
+func loadBackupCatalog(backupDir string) (*BackupCatalog, error) {
+	catalog := &BackupCatalog{path: filepath.Join(backupDir, backupCatalogFilename)}
+
+	data, err := os.ReadFile(catalog.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return catalog, nil
+		}
+		return nil, fmt.Errorf("failed to read backup catalog: %w", err)
+	}
+
+	if err := json.Unmarshal(data, catalog); err != nil {
+		return nil, fmt.Errorf("failed to parse backup catalog %s: %w", catalog.path, err)
+	}
+	return catalog, nil
+}
+
+// save writes the catalog atomically so an interrupted run never leaves a
+// truncated file behind.
+func (c *BackupCatalog) save() error {
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return fmt.Errorf("failed to create backup catalog directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(c, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal backup catalog: %w", err)
+	}
+
+	tmpPath := c.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write backup catalog: %w", err)
+	}
+	if err := os.Rename(tmpPath, c.path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace backup catalog: %w", err)
+	}
+	return nil
+}
+
+// find returns the entry matching ref, which may be a backup ID, an archive
+// filename, a local path, or an S3 URI. A local path only matches the entry
+// recorded for that exact path, not another archive with the same filename.
+func (c *BackupCatalog) find(ref string) *CatalogEntry {
+	for i := range c.Entries {
+		entry := &c.Entries[i]
+		switch {
+		case entry.BackupID == ref,
+			entry.Filename == ref,
+			entry.S3URI != "" && entry.S3URI == ref,
+			!IsS3URI(ref) && entry.LocalPath != "" && sameLocalPath(entry.LocalPath, ref):
+			return entry
+		}
+	}
+	return nil
+}
+
+// sameLocalPath reports whether a and b name the same file path once both
+// are made absolute.
+func sameLocalPath(a, b string) bool {
+	if filepath.Clean(a) == filepath.Clean(b) {
+		return true
+	}
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+// upsert adds entry to the catalog or refreshes the location fields of an
+// existing entry with the same backup ID. Hold state is never overwritten.
+func (c *BackupCatalog) upsert(entry CatalogEntry) *CatalogEntry {
+	for i := range c.Entries {
+		existing := &c.Entries[i]
+		if existing.BackupID != entry.BackupID {
+			continue
+		}
+		existing.Filename = entry.Filename
+		existing.LocalPath = entry.LocalPath
+		if entry.S3URI != "" {
+			existing.S3URI = entry.S3URI
+		}
+		if entry.SizeBytes > 0 {
+			existing.SizeBytes = entry.SizeBytes
+		}
+		if entry.RetentionClass != "" {
+			existing.RetentionClass = entry.RetentionClass
+		}
+		return existing
+	}
+	c.Entries = append(c.Entries, entry)
+	return &c.Entries[len(c.Entries)-1]
+}
+
+// backupIDFromFilename strips the archive extensions from a backup filename.
+func backupIDFromFilename(filename string) string {
+	id := filepath.Base(filename)
+	id = strings.TrimSuffix(id, splitManifestSuffix)
+	id = strings.TrimSuffix(id, ".enc")
+	id = strings.TrimSuffix(id, ".tar.gz")
+	return id
+}
+
+// recordBackupInCatalog registers a freshly created archive. Failures are
+// returned to the caller, which logs them without failing the backup.
+func (iops *InfrahubOps) recordBackupInCatalog(backupID, localPath, s3URI string, sizeBytes int64) error {
+	return iops.recordClassifiedBackup(backupID, localPath, s3URI, sizeBytes, iops.config.RetentionClass)
+}
+
+// recordClassifiedBackup registers a freshly created archive under
+// retentionClass.
+func (iops *InfrahubOps) recordClassifiedBackup(backupID, localPath, s3URI string, sizeBytes int64, retentionClass string) error {
+	catalog, err := loadBackupCatalog(iops.config.BackupDir)
+	if err != nil {
+		return err
+	}
+
+	entry := CatalogEntry{
+		BackupID:       backupID,
+		Filename:       filepath.Base(localPath),
+		CreatedAt:      time.Now().UTC().Format(time.RFC3339),
+		S3URI:          s3URI,
+		SizeBytes:      sizeBytes,
+		RetentionClass: retentionClass,
+	}
+	if fileExists(localPath) {
+		entry.LocalPath = localPath
+	}
+
+	catalog.upsert(entry)
+	return catalog.save()
+}
+
+// CatalogEntries returns the entries of the catalog in BackupDir.
+func (iops *InfrahubOps) CatalogEntries() ([]CatalogEntry, error) {
+	catalog, err := loadBackupCatalog(iops.config.BackupDir)
+	if err != nil {
+		return nil, err
+	}
+	return catalog.Entries, nil
+}
+
+// ResolveCatalogBackup returns the archive of the catalog entry matching
+// ref: its local copy while it exists, otherwise its S3 copy. Backups outside
+// the catalog are not resolved.
+func (iops *InfrahubOps) ResolveCatalogBackup(ref string) (string, error) {
+	catalog, err := loadBackupCatalog(iops.config.BackupDir)
+	if err != nil {
+		return "", err
+	}
+	entry := catalog.find(ref)
+	switch {
+	case entry == nil:
+		return "", fmt.Errorf("backup %q is not in the catalog of %s", ref, iops.config.BackupDir)
+	case entry.LocalPath != "" && fileExists(entry.LocalPath):
+		return entry.LocalPath, nil
+	case entry.S3URI != "":
+		return entry.S3URI, nil
+	}
+	return "", fmt.Errorf("backup %s has no local or S3 copy left", entry.BackupID)
+}
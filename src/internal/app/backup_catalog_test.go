@@ -0,0 +1,111 @@
+package app
+
+import "testing"
+
+func TestBackupCatalogFind(t *testing.T) {
+	catalog := &BackupCatalog{Entries: []CatalogEntry{
+		{
+			BackupID:  "infrahub_backup_20250101_120000",
+			Filename:  "infrahub_backup_20250101_120000.tar.gz",
+			LocalPath: "/backups/infrahub_backup_20250101_120000.tar.gz",
+		},
+		{
+			BackupID: "infrahub_backup_20250102_120000",
+			Filename: "infrahub_backup_20250102_120000.tar.gz.enc",
+			S3URI:    "s3://bucket/prod/infrahub_backup_20250102_120000.tar.gz.enc",
+		},
+	}}
+
+	tests := []struct {
+		name   string
+		ref    string
+		wantID string
+	}{
+		{name: "backup id", ref: "infrahub_backup_20250101_120000", wantID: "infrahub_backup_20250101_120000"},
+		{name: "filename", ref: "infrahub_backup_20250101_120000.tar.gz", wantID: "infrahub_backup_20250101_120000"},
+		{name: "local path", ref: "/backups/./infrahub_backup_20250101_120000.tar.gz", wantID: "infrahub_backup_20250101_120000"},
+		{name: "same filename elsewhere", ref: "./other/infrahub_backup_20250101_120000.tar.gz", wantID: ""},
+		{name: "same filename without local copy", ref: "/tmp/infrahub_backup_20250102_120000.tar.gz.enc", wantID: ""},
+		{name: "s3 uri", ref: "s3://bucket/prod/infrahub_backup_20250102_120000.tar.gz.enc", wantID: "infrahub_backup_20250102_120000"},
+		{name: "different s3 uri", ref: "s3://other/infrahub_backup_20250102_120000.tar.gz.enc", wantID: ""},
+		{name: "unknown", ref: "infrahub_backup_20240101_000000", wantID: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := catalog.find(tt.ref)
+			gotID := ""
+			if entry != nil {
+				gotID = entry.BackupID
+			}
+			if gotID != tt.wantID {
+				t.Errorf("find(%q) = %q, want %q", tt.ref, gotID, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestBackupCatalogUpsertPreservesHold(t *testing.T) {
+	catalog := &BackupCatalog{Entries: []CatalogEntry{{
+		BackupID:   "infrahub_backup_20250101_120000",
+		Filename:   "infrahub_backup_20250101_120000.tar.gz",
+		Held:       true,
+		HoldReason: "audit",
+	}}}
+
+	entry := catalog.upsert(CatalogEntry{
+		BackupID: "infrahub_backup_20250101_120000",
+		Filename: "infrahub_backup_20250101_120000.tar.gz",
+		S3URI:    "s3://bucket/infrahub_backup_20250101_120000.tar.gz",
+	})
+
+	if len(catalog.Entries) != 1 {
+		t.Fatalf("upsert added a duplicate entry: %d entries", len(catalog.Entries))
+	}
+	if !entry.Held || entry.HoldReason != "audit" {
+		t.Errorf("upsert dropped hold state: held=%t reason=%q", entry.Held, entry.HoldReason)
+	}
+	if entry.S3URI != "s3://bucket/infrahub_backup_20250101_120000.tar.gz" {
+		t.Errorf("upsert did not record S3 URI, got %q", entry.S3URI)
+	}
+}
+
+func TestBackupCatalogSaveAndLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	catalog, err := loadBackupCatalog(dir)
+	if err != nil {
+		t.Fatalf("loadBackupCatalog on empty dir: %v", err)
+	}
+	if len(catalog.Entries) != 0 {
+		t.Fatalf("expected empty catalog, got %d entries", len(catalog.Entries))
+	}
+
+	catalog.upsert(CatalogEntry{BackupID: "b1", Filename: "b1.tar.gz", Held: true})
+	if err := catalog.save(); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	reloaded, err := loadBackupCatalog(dir)
+	if err != nil {
+		t.Fatalf("loadBackupCatalog: %v", err)
+	}
+	if entry := reloaded.find("b1"); entry == nil || !entry.Held {
+		t.Errorf("reloaded catalog lost entry or hold state: %+v", entry)
+	}
+}
+
+func TestBackupIDFromFilename(t *testing.T) {
+	tests := map[string]string{
+		"infrahub_backup_20250101_120000.tar.gz":               "infrahub_backup_20250101_120000",
+		"infrahub_backup_20250101_120000.tar.gz.enc":           "infrahub_backup_20250101_120000",
+		"/backups/infrahub_backup_20250101_120000.tar.gz":      "infrahub_backup_20250101_120000",
+		"s3://bucket/prefix/backup.tar.gz.enc":                 "backup",
+		"infrahub_backup_20250101_120000.tar.gz.manifest.json": "infrahub_backup_20250101_120000",
+	}
+	for in, want := range tests {
+		if got := backupIDFromFilename(in); got != want {
+			t.Errorf("backupIDFromFilename(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -1,40 +1,167 @@
 package app
 
 import (
+	"crypto/sha256"
 	"fmt"
+	"hash"
+	"io"
+	"maps"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/sirupsen/logrus"
+	"github.com/zeebo/blake3"
 )
 
 const (
 	backupMetadataFilename = "backup_information.json"
 	prefectDumpFilename    = "prefect.dump"
+	prefectDumpDirName     = "prefect.dir"
 	neo4jBackupDirName     = "database"
 )
 
-// calculateBackupChecksums calculates SHA256 checksums for all backup files
-func calculateBackupChecksums(backupDir string, excludeTaskManager bool) (map[string]string, error) {
-	checksums := make(map[string]string)
+// Checksum algorithms of backup files. blake3 and xxh64 hash much faster than
+// sha256; xxh64 only detects corruption and offers no tamper evidence.
+const (
+	checksumSHA256 = "sha256"
+	checksumBLAKE3 = "blake3"
+	checksumXXH64  = "xxh64"
+)
+
+// defaultChecksumAlgorithm is the algorithm of --checksum-algorithm, and of
+// checksums recorded without one before the algorithm was recorded per file.
+const defaultChecksumAlgorithm = checksumSHA256
+
+// fastChecksumAlgorithm is the algorithm of --fast-checksum. BLAKE3 hashes
+// several times faster than SHA256 and still reveals deliberate changes.
+const fastChecksumAlgorithm = checksumBLAKE3
 
-	// Calculate checksums for Neo4j backup files
+// checksumParallelism is how many backup files are hashed at a time.
+var checksumParallelism = runtime.NumCPU()
+
+var checksumAlgorithms = map[string]func() hash.Hash{
+	checksumSHA256: sha256.New,
+	checksumBLAKE3: func() hash.Hash { return blake3.New() },
+	checksumXXH64:  func() hash.Hash { return xxhash.New() },
+}
+
+// checksumHasher returns a hasher for algorithm, empty meaning the default.
+func checksumHasher(algorithm string) (hash.Hash, error) {
+	if algorithm == "" {
+		algorithm = defaultChecksumAlgorithm
+	}
+	newHash, ok := checksumAlgorithms[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("unsupported checksum algorithm %q: expected sha256, blake3 or xxh64", algorithm)
+	}
+	return newHash(), nil
+}
+
+// formatChecksum returns a checksum entry recording its algorithm, e.g.
+// "blake3:<hex>".
+func formatChecksum(algorithm, sum string) string {
+	return algorithm + ":" + sum
+}
+
+// parseChecksum splits a checksum entry into its algorithm and digest. Bare
+// digests come from archives written before the algorithm was recorded, and
+// are SHA256.
+func parseChecksum(entry string) (algorithm, sum string) {
+	if algorithm, sum, ok := strings.Cut(entry, ":"); ok {
+		return algorithm, sum
+	}
+	return defaultChecksumAlgorithm, entry
+}
+
+// calculateChecksum returns the checksum entry of a file with algorithm.
+func calculateChecksum(filePath, algorithm string) (string, error) {
+	if algorithm == "" {
+		algorithm = defaultChecksumAlgorithm
+	}
+	hasher, err := checksumHasher(algorithm)
+	if err != nil {
+		return "", err
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", err
+	}
+	return formatChecksum(algorithm, fmt.Sprintf("%x", hasher.Sum(nil))), nil
+}
+
+// calculateBackupChecksums calculates checksums with algorithm for all backup
+// files, hashing checksumParallelism files at a time
+func calculateBackupChecksums(backupDir string, excludeTaskManager bool, algorithm string) (map[string]string, error) {
+	files := make(map[string]string) // relative name to path
+
+	// Collect Neo4j backup files
 	neo4jDir := filepath.Join(backupDir, neo4jBackupDirName)
-	if err := calculateDirectoryChecksums(backupDir, neo4jDir, checksums); err != nil {
+	if err := collectDirectoryFiles(backupDir, neo4jDir, files); err != nil {
 		return nil, fmt.Errorf("failed to calculate Neo4j backup checksums: %w", err)
 	}
 
-	// Calculate checksum for Prefect DB dump if included
+	// Collect object store files if included
+	objectStoreDir := filepath.Join(backupDir, objectStoreDirName)
+	if _, err := os.Stat(objectStoreDir); err == nil {
+		if err := collectDirectoryFiles(backupDir, objectStoreDir, files); err != nil {
+			return nil, fmt.Errorf("failed to calculate object store checksums: %w", err)
+		}
+	}
+
+	// Collect the RabbitMQ definitions if included
+	definitionsPath := filepath.Join(backupDir, messageQueueDefinitionsFilename)
+	if err := collectFile(definitionsPath, messageQueueDefinitionsFilename, files); err != nil {
+		return nil, err
+	}
+
+	// Collect Prefect DB dump if included
 	if !excludeTaskManager {
 		prefectPath := filepath.Join(backupDir, prefectDumpFilename)
-		if err := calculateFileChecksum(backupDir, prefectPath, prefectDumpFilename, checksums); err != nil {
+		if err := collectFile(prefectPath, prefectDumpFilename, files); err != nil {
 			return nil, err
 		}
+		prefectDir := filepath.Join(backupDir, prefectDumpDirName)
+		if _, err := os.Stat(prefectDir); err == nil {
+			if err := collectDirectoryFiles(backupDir, prefectDir, files); err != nil {
+				return nil, fmt.Errorf("failed to calculate Prefect DB dump checksums: %w", err)
+			}
+		}
 	}
 
+	names := slices.Sorted(maps.Keys(files))
+	sums := make([]string, len(names))
+	err := forEachParallel(len(names), func(i int) error {
+		sum, err := calculateChecksum(files[names[i]], algorithm)
+		if err != nil {
+			return fmt.Errorf("failed to calculate checksum for %s: %w", names[i], err)
+		}
+		sums[i] = sum
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	checksums := make(map[string]string, len(names))
+	for i, name := range names {
+		checksums[name] = sums[i]
+	}
 	return checksums, nil
 }
 
-// calculateDirectoryChecksums walks a directory and calculates checksums for all files
-func calculateDirectoryChecksums(baseDir, targetDir string, checksums map[string]string) error {
+// collectDirectoryFiles walks a directory and adds all its files to files
+func collectDirectoryFiles(baseDir, targetDir string, files map[string]string) error {
 	return filepath.Walk(targetDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -47,19 +174,13 @@ func calculateDirectoryChecksums(baseDir, targetDir string, checksums map[string
 		if err != nil {
 			return fmt.Errorf("failed to get relative path for %s: %w", path, err)
 		}
-
-		sum, err := calculateSHA256(path)
-		if err != nil {
-			return fmt.Errorf("failed to calculate checksum for %s: %w", relPath, err)
-		}
-
-		checksums[relPath] = sum
+		files[relPath] = path
 		return nil
 	})
 }
 
-// calculateFileChecksum calculates checksum for a single file if it exists
-func calculateFileChecksum(baseDir, filePath, relativeName string, checksums map[string]string) error {
+// collectFile adds a single file to files if it exists
+func collectFile(filePath, relativeName string, files map[string]string) error {
 	stat, err := os.Stat(filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -69,31 +190,56 @@ func calculateFileChecksum(baseDir, filePath, relativeName string, checksums map
 	}
 
 	if !stat.IsDir() {
-		sum, err := calculateSHA256(filePath)
+		files[relativeName] = filePath
+	}
+	return nil
+}
+
+// forEachParallel calls fn for 0 to n-1 with checksumParallelism calls at a
+// time, and returns the error of the lowest index that failed.
+func forEachParallel(n int, fn func(i int) error) error {
+	errs := make([]error, n)
+	slots := make(chan struct{}, max(checksumParallelism, 1))
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			errs[i] = fn(i)
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
 		if err != nil {
-			return fmt.Errorf("failed to calculate %s checksum: %w", relativeName, err)
+			return err
 		}
-		checksums[relativeName] = sum
 	}
-
 	return nil
 }
 
-// validateBackupChecksums validates all checksums in the backup metadata
+// validateBackupChecksums validates all checksums in the backup metadata, each
+// with the algorithm it was recorded with
 func validateBackupChecksums(workDir string, metadata *BackupMetadata, excludeTaskManager bool) error {
 	backupDir := filepath.Join(workDir, "backup")
+	if algorithms := checksumAlgorithmCounts(metadata.Checksums); len(algorithms) > 1 {
+		logrus.Infof("Backup checksums use several algorithms: %s", strings.Join(algorithms, ", "))
+	}
 
 	// Validate Neo4j backup file checksums
-	for relPath, expectedSum := range metadata.Checksums {
-		if relPath == prefectDumpFilename {
-			continue // Handle separately
-		}
-
-		filePath := filepath.Join(backupDir, relPath)
-		if err := validateFileChecksum(filePath, relPath, expectedSum); err != nil {
-			return err
+	var names []string
+	for _, relPath := range slices.Sorted(maps.Keys(metadata.Checksums)) {
+		if relPath != prefectDumpFilename {
+			names = append(names, relPath) // Prefect DB dump is handled separately
 		}
 	}
+	err := forEachParallel(len(names), func(i int) error {
+		return validateFileChecksum(filepath.Join(backupDir, names[i]), names[i], metadata.Checksums[names[i]])
+	})
+	if err != nil {
+		return err
+	}
 
 	// Validate Prefect DB dump checksum if applicable
 	if !excludeTaskManager {
@@ -118,14 +264,54 @@ func validateFileChecksum(filePath, name, expectedSum string) error {
 		return fmt.Errorf("missing backup file: %s", name)
 	}
 
-	actualSum, err := calculateSHA256(filePath)
+	algorithm, expected := parseChecksum(expectedSum)
+	if _, ok := checksumAlgorithms[algorithm]; !ok {
+		return fmt.Errorf("unsupported checksum algorithm %q for %s; upgrade infrahub-backup", algorithm, name)
+	}
+	actualSum, err := calculateChecksum(filePath, algorithm)
 	if err != nil {
 		return fmt.Errorf("failed to calculate checksum for %s: %w", name, err)
 	}
 
-	if actualSum != expectedSum {
-		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", name, expectedSum, actualSum)
+	if _, actual := parseChecksum(actualSum); actual != expected {
+		return fmt.Errorf("checksum mismatch for %s: expected %s %s, got %s", name, algorithm, expected, actual)
+	}
+
+	return nil
+}
+
+// checksumAlgorithmCounts summarizes the algorithms of checksums, e.g.
+// ["blake3 (12 files)", "sha256 (3 files)"].
+func checksumAlgorithmCounts(checksums map[string]string) []string {
+	counts := map[string]int{}
+	for _, entry := range checksums {
+		algorithm, _ := parseChecksum(entry)
+		counts[algorithm]++
+	}
+	summary := make([]string, 0, len(counts))
+	for algorithm, count := range counts {
+		summary = append(summary, fmt.Sprintf("%s (%d files)", algorithm, count))
+	}
+	sort.Strings(summary)
+	return summary
+}
+
+// checksumAlgorithm returns the algorithm of the checksums recorded for new
+// backups: --checksum-algorithm, or BLAKE3 with --fast-checksum.
+func (cfg *Configuration) checksumAlgorithm() string {
+	if cfg.FastChecksum {
+		return fastChecksumAlgorithm
 	}
+	return cfg.ChecksumAlgorithm
+}
 
+// checkChecksumAlgorithm validates --checksum-algorithm and --fast-checksum.
+func (cfg *Configuration) checkChecksumAlgorithm() []error {
+	if _, err := checksumHasher(cfg.ChecksumAlgorithm); err != nil {
+		return []error{fmt.Errorf("invalid --checksum-algorithm: %w", err)}
+	}
+	if cfg.FastChecksum && cfg.ChecksumAlgorithm != "" && cfg.ChecksumAlgorithm != defaultChecksumAlgorithm && cfg.ChecksumAlgorithm != fastChecksumAlgorithm {
+		return []error{fmt.Errorf("--fast-checksum conflicts with --checksum-algorithm %s", cfg.ChecksumAlgorithm)}
+	}
 	return nil
 }
@@ -0,0 +1,160 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCalculateChecksum(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "empty", "")
+	tests := []struct {
+		algorithm string
+		want      string
+	}{
+		{algorithm: "", want: "sha256:" + validChecksum},
+		{algorithm: checksumSHA256, want: "sha256:" + validChecksum},
+		{algorithm: checksumBLAKE3, want: "blake3:af1349b9f5f9a1a6a0404dea36dcc9499bcb25c9adc112b7cc9a93cae41f3262"},
+		{algorithm: checksumXXH64, want: "xxh64:ef46db3751d8e999"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			got, err := calculateChecksum(filepath.Join(dir, "empty"), tt.algorithm)
+			if err != nil || got != tt.want {
+				t.Errorf("calculateChecksum() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+	if _, err := calculateChecksum(filepath.Join(dir, "empty"), "md5"); err == nil || !strings.Contains(err.Error(), "unsupported checksum algorithm") {
+		t.Errorf("calculateChecksum(md5) error = %v", err)
+	}
+}
+
+func TestValidateBackupChecksumsMixedAlgorithms(t *testing.T) {
+	workDir := t.TempDir()
+	backupDir := filepath.Join(workDir, "backup")
+	writeTestFile(t, backupDir, "database/neo4j.dump", "graph")
+	writeTestFile(t, backupDir, "object-store/artifacts/abc", "artifact")
+	writeTestFile(t, backupDir, prefectDumpFilename, "prefect")
+
+	legacy, err := calculateSHA256(filepath.Join(backupDir, "database/neo4j.dump"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	artifact, err := calculateChecksum(filepath.Join(backupDir, "object-store/artifacts/abc"), checksumXXH64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefect, err := calculateChecksum(filepath.Join(backupDir, prefectDumpFilename), checksumBLAKE3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		checksums map[string]string
+		corrupt   string
+		wantErr   string
+	}{
+		{name: "mixed", checksums: map[string]string{"database/neo4j.dump": legacy, "object-store/artifacts/abc": artifact, prefectDumpFilename: prefect}},
+		{name: "corrupt xxh64 file", checksums: map[string]string{"object-store/artifacts/abc": artifact}, corrupt: "object-store/artifacts/abc", wantErr: "checksum mismatch for object-store/artifacts/abc: expected xxh64"},
+		{name: "corrupt legacy file", checksums: map[string]string{"database/neo4j.dump": legacy}, corrupt: "database/neo4j.dump", wantErr: "expected sha256"},
+		{name: "unknown algorithm", checksums: map[string]string{"database/neo4j.dump": "crc32c:0badf00d"}, wantErr: `unsupported checksum algorithm "crc32c"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.corrupt != "" {
+				path := filepath.Join(backupDir, tt.corrupt)
+				original, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer os.WriteFile(path, original, 0644)
+				if err := os.WriteFile(path, append(original, '!'), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := validateBackupChecksums(workDir, &BackupMetadata{Checksums: tt.checksums}, true)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateBackupChecksums() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateBackupChecksums() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestComponentFingerprintsIgnoreSHA256Prefix(t *testing.T) {
+	legacy := componentFingerprints(map[string]string{"database/neo4j.dump": validChecksum})
+	prefixed := componentFingerprints(map[string]string{"database/neo4j.dump": "sha256:" + validChecksum})
+	if legacy["database"] != prefixed["database"] {
+		t.Errorf("fingerprints differ: %v and %v", legacy, prefixed)
+	}
+}
+
+func TestCalculateBackupChecksumsParallel(t *testing.T) {
+	defer func(n int) { checksumParallelism = n }(checksumParallelism)
+	workDir := t.TempDir()
+	backupDir := filepath.Join(workDir, "backup")
+	for i := range 20 {
+		writeTestFile(t, backupDir, fmt.Sprintf("object-store/artifacts/%02d", i), fmt.Sprintf("artifact %d", i))
+	}
+	writeTestFile(t, backupDir, "database/neo4j.dump", "graph")
+
+	checksumParallelism = 1
+	serial, err := calculateBackupChecksums(backupDir, true, checksumBLAKE3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checksumParallelism = 8
+	parallel, err := calculateBackupChecksums(backupDir, true, checksumBLAKE3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parallel) != 21 || !reflect.DeepEqual(serial, parallel) {
+		t.Errorf("parallel checksums = %v, want %v", parallel, serial)
+	}
+	if err := validateBackupChecksums(workDir, &BackupMetadata{Checksums: parallel}, true); err != nil {
+		t.Errorf("validateBackupChecksums() error = %v", err)
+	}
+}
+
+func TestConfigurationChecksumAlgorithm(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Configuration
+		want    string
+		wantErr string
+	}{
+		{name: "default", cfg: Configuration{ChecksumAlgorithm: defaultChecksumAlgorithm}, want: checksumSHA256},
+		{name: "fast", cfg: Configuration{ChecksumAlgorithm: defaultChecksumAlgorithm, FastChecksum: true}, want: checksumBLAKE3},
+		{name: "xxh64", cfg: Configuration{ChecksumAlgorithm: checksumXXH64}, want: checksumXXH64},
+		{name: "fast and xxh64", cfg: Configuration{ChecksumAlgorithm: checksumXXH64, FastChecksum: true}, want: checksumBLAKE3, wantErr: "--fast-checksum conflicts"},
+		{name: "unknown", cfg: Configuration{ChecksumAlgorithm: "md5"}, want: "md5", wantErr: "invalid --checksum-algorithm"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.checksumAlgorithm(); got != tt.want {
+				t.Errorf("checksumAlgorithm() = %q, want %q", got, tt.want)
+			}
+			problems := tt.cfg.checkChecksumAlgorithm()
+			if tt.wantErr == "" {
+				if len(problems) != 0 {
+					t.Errorf("checkChecksumAlgorithm() = %v", problems)
+				}
+				return
+			}
+			if len(problems) != 1 || !strings.Contains(problems[0].Error(), tt.wantErr) {
+				t.Errorf("checkChecksumAlgorithm() = %v, want %q", problems, tt.wantErr)
+			}
+		})
+	}
+}
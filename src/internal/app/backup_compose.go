@@ -0,0 +1,178 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// composeComponent is recorded in backup metadata when the compose
+	// manifest of a Docker deployment is included.
+	composeComponent = "compose"
+
+	// composeManifestFilename holds the resolved `docker compose config` of
+	// the backed-up project, and is the file written by --bootstrap-compose.
+	composeManifestFilename = "docker-compose.yml"
+)
+
+// bootstrapDataServices are started, and restored into, before the
+// application services of a bootstrapped project are created.
+var bootstrapDataServices = []string{"database", "task-manager-db", "cache", "message-queue", objectStoreService}
+
+// readCommandOutput runs a command and returns its standard output only, so
+// warnings on standard error do not end up in the result.
+func (iops *InfrahubOps) readCommandOutput(name string, args ...string) ([]byte, error) {
+	reader, wait, err := iops.executor.runCommandPipe(name, args...)
+	if err != nil {
+		return nil, err
+	}
+	output, readErr := io.ReadAll(reader)
+	reader.Close()
+	if err := wait(); err != nil {
+		return nil, err
+	}
+	return output, readErr
+}
+
+// exportComposeManifest returns the resolved compose configuration of a
+// Docker deployment, or nil on Kubernetes.
+func (iops *InfrahubOps) exportComposeManifest() ([]byte, error) {
+	backend, err := iops.ensureBackend()
+	if err != nil {
+		return nil, err
+	}
+	docker, ok := backend.(*DockerBackend)
+	if !ok {
+		return nil, nil
+	}
+	args, err := docker.composeConfigArgs("config")
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := iops.readCommandOutput("docker", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the compose configuration: %w", err)
+	}
+	return manifest, nil
+}
+
+// composeProjectName derives the project name docker compose uses for dir:
+// its base name, lower-cased, keeping letters, digits, dashes and underscores.
+func composeProjectName(dir string) string {
+	var name strings.Builder
+	for _, r := range strings.ToLower(filepath.Base(dir)) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || ((r == '-' || r == '_') && name.Len() > 0) {
+			name.WriteRune(r)
+		}
+	}
+	return name.String()
+}
+
+// bootstrapComposeManifest returns the compose template, or the manifest
+// captured in the backup when no template is given.
+func (iops *InfrahubOps) bootstrapComposeManifest(template, backupFile, decryptKey string) ([]byte, error) {
+	if template != "" {
+		manifest, err := os.ReadFile(template)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read compose template: %w", err)
+		}
+		return manifest, nil
+	}
+
+	archive, cleanup, err := iops.prepareSharedRestoreArchive(backupFile, decryptKey)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	manifest, err := readArchiveMember(archive, "backup/"+composeManifestFilename)
+	if err != nil {
+		return nil, fmt.Errorf("backup has no compose manifest; pass --compose-template: %w", err)
+	}
+	return manifest, nil
+}
+
+// RestoreIntoNewComposeProject creates a compose project in dir from the
+// template or the manifest captured in the backup, starts its data services
+// only, runs restore against it and then starts the whole project.
+func (iops *InfrahubOps) RestoreIntoNewComposeProject(dir, template, backupFile, decryptKey string, restore func() error) error {
+	project := composeProjectName(dir)
+	if project == "" {
+		return fmt.Errorf("cannot derive a compose project name from %s", dir)
+	}
+	composeFile := filepath.Join(dir, composeManifestFilename)
+	if fileExists(composeFile) {
+		return fmt.Errorf("%s already exists; --bootstrap-compose only creates new projects", composeFile)
+	}
+	// Any project of that name counts, not only Infrahub ones.
+	projects, err := iops.executor.runCommand("docker", "compose", "ls", "--all", "--quiet")
+	if err != nil {
+		return fmt.Errorf("failed to list docker compose projects: %w", err)
+	}
+	if slices.Contains(strings.Fields(projects), project) {
+		return fmt.Errorf("docker compose project %s already exists; restore into it with --project", project)
+	}
+
+	manifest, err := iops.bootstrapComposeManifest(template, backupFile, decryptKey)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	// The resolved configuration can hold database passwords.
+	if err := os.WriteFile(composeFile, manifest, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", composeFile, err)
+	}
+	logrus.WithFields(logrus.Fields{"project": project, "file": composeFile}).Info("Created compose project")
+
+	compose := func(args ...string) []string {
+		return append([]string{"compose", "-p", project, "-f", composeFile}, args...)
+	}
+	output, err := iops.readCommandOutput("docker", compose("config", "--services")...)
+	if err != nil {
+		return fmt.Errorf("invalid compose manifest: %w", err)
+	}
+	defined := strings.Fields(string(output))
+	var dataServices []string
+	for _, service := range bootstrapDataServices {
+		if slices.Contains(defined, service) {
+			dataServices = append(dataServices, service)
+		}
+	}
+	if !slices.Contains(dataServices, "database") {
+		return fmt.Errorf("compose manifest has no database service")
+	}
+
+	// Create every container, so the restore can start and stop the
+	// application services, but only run the data services.
+	logrus.Infof("Starting data services: %s", strings.Join(dataServices, ", "))
+	if output, err := iops.executor.runCommand("docker", compose("up", "--no-start")...); err != nil {
+		return fmt.Errorf("failed to create compose services: %w\nOutput: %s", err, output)
+	}
+	if output, err := iops.executor.runCommand("docker", compose(append([]string{"up", "-d", "--wait"}, dataServices...)...)...); err != nil {
+		return fmt.Errorf("failed to start data services: %w\nOutput: %s", err, output)
+	}
+
+	// Restore into the new project, not the one detection or a pin file picks.
+	backend, err := iops.assumeBackend("docker", project)
+	if err != nil {
+		return err
+	}
+	iops.backend = backend
+	if err := restore(); err != nil {
+		return err
+	}
+
+	logrus.Info("Starting every service of the restored project...")
+	if output, err := iops.executor.runCommand("docker", compose("up", "-d")...); err != nil {
+		return fmt.Errorf("failed to start compose project %s: %w\nOutput: %s", project, err, output)
+	}
+	logrus.Infof("Compose project %s restored; manage it from %s", project, dir)
+	return nil
+}
@@ -0,0 +1,136 @@
+package app
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestComposeProjectName(t *testing.T) {
+	tests := []struct {
+		dir  string
+		want string
+	}{
+		{"/srv/infrahub", "infrahub"},
+		{"/srv/Infrahub Prod", "infrahubprod"},
+		{"/srv/dr-site_2", "dr-site_2"},
+		{"/srv/_restored", "restored"},
+		{"/srv/...", ""},
+	}
+	for _, tt := range tests {
+		if got := composeProjectName(tt.dir); got != tt.want {
+			t.Errorf("composeProjectName(%q) = %q, want %q", tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestExportComposeManifest(t *testing.T) {
+	fake := newFakeExecutor().
+		on("label=com.docker.compose.project=test", "|\n/srv/infrahub|/srv/infrahub/docker-compose.yml,/srv/infrahub/override.yml", nil).
+		on("compose -p test --project-directory /srv/infrahub -f /srv/infrahub/docker-compose.yml -f /srv/infrahub/override.yml config", "name: test\nservices: {}", nil)
+	manifest, err := newFakeDockerOps(fake).exportComposeManifest()
+	if err != nil || string(manifest) != "name: test\nservices: {}" {
+		t.Errorf("exportComposeManifest() = %q, %v", manifest, err)
+	}
+
+	unlabelled := newFakeDockerOps(newFakeExecutor())
+	if _, err := unlabelled.exportComposeManifest(); err == nil || !strings.Contains(err.Error(), "records its compose files") {
+		t.Errorf("exportComposeManifest() without labels error = %v", err)
+	}
+
+	iops := NewInfrahubOpsWithExecutor(newFakeExecutor())
+	iops.backend = &KubernetesBackend{namespace: "infrahub"}
+	if manifest, err := iops.exportComposeManifest(); manifest != nil || err != nil {
+		t.Errorf("kubernetes exportComposeManifest() = %q, %v; want nothing", manifest, err)
+	}
+}
+
+func TestRestoreIntoNewComposeProject(t *testing.T) {
+	template := filepath.Join(t.TempDir(), "template.yml")
+	if err := os.WriteFile(template, []byte("services: {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(t.TempDir(), "recovered")
+	fake := newFakeExecutor().
+		on("compose ls", "infrahub\nother", nil).
+		on("config --services", "infrahub-server\ntask-worker\ndatabase\ncache\nmessage-queue\ntask-manager\ntask-manager-db", nil)
+	iops := NewInfrahubOpsWithExecutor(fake)
+
+	var restoredInto string
+	restore := func() error {
+		restoredInto = iops.config.DockerComposeProject
+		if len(fake.commands("up -d")) != 1 {
+			t.Errorf("restore ran before only the data services were started: %v", fake.commands("compose -p"))
+		}
+		return nil
+	}
+	if err := iops.RestoreIntoNewComposeProject(dir, template, "backup.tar.gz", "", restore); err != nil {
+		t.Fatalf("RestoreIntoNewComposeProject() error = %v", err)
+	}
+
+	if restoredInto != "recovered" {
+		t.Errorf("restored into project %q, want recovered", restoredInto)
+	}
+	if backend, _ := iops.ensureBackend(); backend.Name() != "docker" || backend.Info() != "recovered" {
+		t.Errorf("backend = %s %s", backend.Name(), backend.Info())
+	}
+	composeFile := filepath.Join(dir, composeManifestFilename)
+	if content, err := os.ReadFile(composeFile); err != nil || string(content) != "services: {}\n" {
+		t.Errorf("compose file = %q, %v", content, err)
+	}
+	prefix := "docker compose -p recovered -f " + composeFile + " "
+	want := []string{
+		prefix + "config --services",
+		prefix + "up --no-start",
+		prefix + "up -d --wait database task-manager-db cache message-queue",
+		prefix + "up -d",
+	}
+	if got := fake.commands(prefix); !slices.Equal(got, want) {
+		t.Errorf("compose commands =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestRestoreIntoNewComposeProjectRefusesExisting(t *testing.T) {
+	template := filepath.Join(t.TempDir(), "template.yml")
+	writeTestFile(t, filepath.Dir(template), "template.yml", "services: {}\n")
+	existingFile := t.TempDir()
+	writeTestFile(t, existingFile, composeManifestFilename, "services: {}\n")
+
+	tests := []struct {
+		name string
+		dir  string
+		want string
+	}{
+		{"compose file exists", existingFile, "already exists"},
+		{"project exists", filepath.Join(t.TempDir(), "infrahub"), "project infrahub already exists"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeExecutor().on("compose ls", "infrahub", nil)
+			iops := NewInfrahubOpsWithExecutor(fake)
+			restore := func() error { return errors.New("restore should not run") }
+			err := iops.RestoreIntoNewComposeProject(tt.dir, template, "backup.tar.gz", "", restore)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+			if up := fake.commands(" up "); len(up) != 0 {
+				t.Errorf("services started: %v", up)
+			}
+		})
+	}
+}
+
+func TestRestoreIntoNewComposeProjectNeedsDatabase(t *testing.T) {
+	template := filepath.Join(t.TempDir(), "template.yml")
+	writeTestFile(t, filepath.Dir(template), "template.yml", "services: {}\n")
+	fake := newFakeExecutor().on("config --services", "infrahub-server\ncache", nil)
+	iops := NewInfrahubOpsWithExecutor(fake)
+
+	err := iops.RestoreIntoNewComposeProject(filepath.Join(t.TempDir(), "new"), template, "backup.tar.gz", "", func() error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "no database service") {
+		t.Errorf("error = %v", err)
+	}
+}
@@ -2,10 +2,14 @@ package app
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"regexp"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -63,6 +67,13 @@ func (iops *InfrahubOps) discoverPrefectPaginationLimit() (int, bool) {
 	return limit, true
 }
 
+// Polling of the running-tasks check starts every 5 seconds and backs off to
+// once a minute during long waits.
+const (
+	runningTasksPollInterval    = 5 * time.Second
+	runningTasksMaxPollInterval = time.Minute
+)
+
 func (iops *InfrahubOps) waitForRunningTasks() error {
 	useInfrahubctl := true
 	var scriptContent string
@@ -114,6 +125,10 @@ func (iops *InfrahubOps) waitForRunningTasks() error {
 		return true
 	}
 
+	poll := iops.newPoller("running tasks to complete", runningTasksPollInterval, runningTasksMaxPollInterval)
+	defer poll.done()
+	reported := -1
+
 	for {
 		var (
 			output string
@@ -163,39 +178,153 @@ func (iops *InfrahubOps) waitForRunningTasks() error {
 			}
 		}
 		if len(tasks) == 0 {
+			poll.done()
 			logrus.Info("No running tasks detected. Proceeding with backup.")
 			return nil
 		}
 
-		logrus.Warnf("There are running %v tasks: %v", len(tasks), tasks)
-		logrus.Warnf("Waiting for them to complete... (use --force to override)")
-		time.Sleep(5 * time.Second)
+		if reported < 0 {
+			logrus.Warnf("There are running %v tasks: %v", len(tasks), tasks)
+			logrus.Warnf("Waiting for them to complete... (use --force to override)")
+		} else if len(tasks) != reported {
+			logrus.Debugf("Running tasks: %v", tasks)
+		}
+		reported = len(tasks)
+		poll.status(fmt.Sprintf("%d running", len(tasks)))
+		poll.wait(time.Time{})
 	}
 }
 
+// appServiceGroups lists the application services in start order. Services in
+// the same group do not depend on each other, so they are started and stopped
+// concurrently; groups are stopped in reverse order.
+var appServiceGroups = [][]string{
+	{"cache", "message-queue"},
+	{"task-manager", "task-manager-background-svc"},
+	{"infrahub-server", "task-worker"},
+}
+
+// orderServiceGroups arranges services into start-order groups. When the
+// deployment declares dependencies, each service follows the listed services
+// it depends on. Otherwise, or when they form a cycle, the groups follow
+// appServiceGroups and services outside the known groups form a final group.
+func orderServiceGroups(services []string, dependencies *serviceGraph) [][]string {
+	if dependencies.declared() {
+		groups, err := dependencies.layers(services)
+		if err == nil {
+			return groups
+		}
+		logrus.Warnf("Ignoring the service dependencies of the deployment: %v", err)
+	}
+
+	serviceSet := make(map[string]struct{}, len(services))
+	for _, svc := range services {
+		serviceSet[svc] = struct{}{}
+	}
+
+	groups := [][]string{}
+	for _, group := range appServiceGroups {
+		selected := []string{}
+		for _, svc := range group {
+			if _, ok := serviceSet[svc]; ok {
+				selected = append(selected, svc)
+				delete(serviceSet, svc)
+			}
+		}
+		if len(selected) > 0 {
+			groups = append(groups, selected)
+		}
+	}
+
+	if len(serviceSet) > 0 {
+		remaining := make([]string, 0, len(serviceSet))
+		for svc := range serviceSet {
+			remaining = append(remaining, svc)
+		}
+		sort.Strings(remaining)
+		groups = append(groups, remaining)
+	}
+
+	return groups
+}
+
+// forEachServiceParallel runs fn for every service concurrently and joins the
+// errors in service order.
+func forEachServiceParallel(services []string, fn func(service string) error) error {
+	errs := make([]error, len(services))
+	var wg sync.WaitGroup
+	for i, svc := range services {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = fn(svc)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// appServices are the services stopped while a backup or restore needs the
+// database quiet.
+var appServices = []string{
+	"infrahub-server", "task-worker", "task-manager",
+	"task-manager-background-svc", "cache", "message-queue",
+}
+
+// stopAppContainers stops the application services, and the services the
+// deployment declares as depending on them, such as an authenticating proxy.
 func (iops *InfrahubOps) stopAppContainers() ([]string, error) {
 	logrus.Info("Stopping Infrahub application services...")
 
-	services := []string{
-		"infrahub-server", "task-worker", "task-manager",
-		"task-manager-background-svc", "cache", "message-queue",
+	return iops.stopRunningServices(iops.appServicesWithDependents())
+}
+
+// appServicesWithDependents returns appServices followed by the services the
+// deployment declares as depending on them.
+func (iops *InfrahubOps) appServicesWithDependents() []string {
+	dependents := iops.serviceDependencies().dependents(appServices)
+	if len(dependents) > 0 {
+		logrus.Debugf("Services depending on the Infrahub services: %s", strings.Join(dependents, ", "))
 	}
+	return append(slices.Clone(appServices), dependents...)
+}
 
+// stopRunningServices stops each listed service that is currently running and
+// returns the services it stopped. Dependents are stopped before the services
+// they rely on; independent services are stopped concurrently.
+func (iops *InfrahubOps) stopRunningServices(services []string) ([]string, error) {
 	stopped := []string{}
 
-	for _, service := range services {
-		running, err := iops.IsServiceRunning(service)
-		if err != nil {
-			logrus.Debugf("Could not determine status of %s: %v", service, err)
-			continue
-		}
+	// One status query covers every service and resolves the backend before
+	// fanning out. Without it, nothing proves the services are down, so the
+	// caller must not go on writing under them.
+	running, err := iops.RunningServices(services...)
+	if err != nil {
+		return stopped, fmt.Errorf("failed to determine which services are running: %w", err)
+	}
+
+	groups := orderServiceGroups(services, iops.serviceDependencies())
+	slices.Reverse(groups)
+
+	var mu sync.Mutex
+
+	for _, group := range groups {
+		err := forEachServiceParallel(group, func(service string) error {
+			if !running[service] {
+				return nil
+			}
 
-		if running {
 			logrus.Infof("Stopping %s...", service)
 			if err := iops.StopServices(service); err != nil {
-				return stopped, fmt.Errorf("failed to stop %s: %w", service, err)
+				return fmt.Errorf("failed to stop %s: %w", service, err)
 			}
+			mu.Lock()
 			stopped = append(stopped, service)
+			mu.Unlock()
+			return nil
+		})
+		if err != nil {
+			return stopped, err
 		}
 	}
 
@@ -215,40 +344,52 @@ func (iops *InfrahubOps) startAppContainers(services []string) error {
 
 	logrus.Info("Starting Infrahub application services...")
 
-	preferredOrder := []string{
-		"cache",
-		"message-queue",
-		"task-manager",
-		"task-manager-background-svc",
-		"infrahub-server",
-		"task-worker",
+	if err := iops.startServicesInOrder(services); err != nil {
+		return err
 	}
 
-	serviceSet := make(map[string]struct{}, len(services))
-	for _, svc := range services {
-		serviceSet[svc] = struct{}{}
+	logrus.Info("Application services started")
+	return nil
+}
+
+// startServicesInOrder starts services group by group, dependencies first.
+// Before each group, it waits for the services the group needs healthy.
+func (iops *InfrahubOps) startServicesInOrder(services []string) error {
+	if _, err := iops.ensureBackend(); err != nil {
+		return err
 	}
 
-	ordered := make([]string, 0, len(serviceSet))
-	for _, svc := range preferredOrder {
-		if _, ok := serviceSet[svc]; ok {
-			ordered = append(ordered, svc)
-			delete(serviceSet, svc)
+	dependencies := iops.serviceDependencies()
+	groups := orderServiceGroups(services, dependencies)
+	for i, group := range groups {
+		if i > 0 {
+			iops.waitUntilHealthy(dependencies.needsHealthy(slices.Concat(groups[:i]...), group))
+		}
+		err := forEachServiceParallel(group, func(service string) error {
+			logrus.Infof("Starting %s...", service)
+			if err := iops.StartServices(service); err != nil {
+				return fmt.Errorf("failed to start %s: %w", service, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
 		}
 	}
-	for svc := range serviceSet {
-		ordered = append(ordered, svc)
-	}
+	return nil
+}
 
-	for _, svc := range ordered {
-		logrus.Infof("Starting %s...", svc)
-		if err := iops.StartServices(svc); err != nil {
-			return fmt.Errorf("failed to start %s: %w", svc, err)
+// restartAppServices starts infrahub-server and task-worker at the end of a
+// restore, followed by the services stopAppContainers stopped because they
+// depend on the application services.
+func (iops *InfrahubOps) restartAppServices(stopped []string) error {
+	services := []string{"infrahub-server", "task-worker"}
+	for _, service := range stopped {
+		if !slices.Contains(appServices, service) {
+			services = append(services, service)
 		}
 	}
-
-	logrus.Info("Application services started")
-	return nil
+	return iops.startServicesInOrder(services)
 }
 
 func (iops *InfrahubOps) wipeTransientData() error {
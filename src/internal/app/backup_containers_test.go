@@ -1,6 +1,10 @@
 package app
 
-import "testing"
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
 
 func TestParsePrefectMaxLimit(t *testing.T) {
 	tests := []struct {
@@ -51,3 +55,101 @@ func TestParsePrefectMaxLimit(t *testing.T) {
 		})
 	}
 }
+
+func TestOrderServiceGroups(t *testing.T) {
+	tests := []struct {
+		name     string
+		services []string
+		want     [][]string
+	}{
+		{
+			name: "all application services",
+			services: []string{
+				"infrahub-server", "task-worker", "task-manager",
+				"task-manager-background-svc", "cache", "message-queue",
+			},
+			want: [][]string{
+				{"cache", "message-queue"},
+				{"task-manager", "task-manager-background-svc"},
+				{"infrahub-server", "task-worker"},
+			},
+		},
+		{
+			name:     "partial set skips empty groups",
+			services: []string{"task-worker", "cache"},
+			want:     [][]string{{"cache"}, {"task-worker"}},
+		},
+		{
+			name:     "unknown services run last in sorted order",
+			services: []string{"zeta", "infrahub-server", "alpha"},
+			want:     [][]string{{"infrahub-server"}, {"alpha", "zeta"}},
+		},
+		{
+			name:     "no services",
+			services: nil,
+			want:     [][]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := orderServiceGroups(tt.services, nil)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("orderServiceGroups(%v) = %v, want %v", tt.services, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStopRunningServicesStopsDependentsFirst(t *testing.T) {
+	fake := newFakeExecutor().on("ps -a --format json", `[
+{"Service":"infrahub-server","State":"running"},
+{"Service":"task-worker","State":"exited"},
+{"Service":"task-manager","State":"running"},
+{"Service":"cache","State":"running"}
+]`, nil)
+	iops := newFakeDockerOps(fake)
+
+	stopped, err := iops.stopRunningServices([]string{"cache", "task-manager", "infrahub-server", "task-worker"})
+	if err != nil {
+		t.Fatalf("stopRunningServices() error = %v", err)
+	}
+	if want := []string{"infrahub-server", "task-manager", "cache"}; !reflect.DeepEqual(stopped, want) {
+		t.Errorf("stopped = %v, want %v", stopped, want)
+	}
+	want := []string{
+		"docker compose -p test stop infrahub-server",
+		"docker compose -p test stop task-manager",
+		"docker compose -p test stop cache",
+	}
+	if got := fake.commands(" stop "); !reflect.DeepEqual(got, want) {
+		t.Errorf("stop commands = %v, want %v", got, want)
+	}
+}
+
+func TestStopRunningServicesFailsWithoutStatus(t *testing.T) {
+	fake := newFakeExecutor().on("ps -a --format json", "", errors.New("Cannot connect to the Docker daemon"))
+	iops := newFakeDockerOps(fake)
+
+	if _, err := iops.stopRunningServices([]string{"cache", "infrahub-server"}); err == nil {
+		t.Fatal("stopRunningServices() error = nil, want status failure")
+	}
+}
+
+func TestStopRunningServicesHaltsOnFailure(t *testing.T) {
+	fake := newFakeExecutor().
+		on("ps -a --format json", `[{"Service":"infrahub-server","State":"running"},{"Service":"cache","State":"running"}]`, nil).
+		on("stop infrahub-server", "", errors.New("exit status 1"))
+	iops := newFakeDockerOps(fake)
+
+	stopped, err := iops.stopRunningServices([]string{"cache", "infrahub-server"})
+	if err == nil {
+		t.Fatal("stopRunningServices() error = nil, want failure")
+	}
+	if len(stopped) != 0 {
+		t.Errorf("stopped = %v, want none", stopped)
+	}
+	if got := fake.commands("stop cache"); len(got) != 0 {
+		t.Errorf("cache was stopped after a dependent failed: %v", got)
+	}
+}
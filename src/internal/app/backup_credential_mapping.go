@@ -0,0 +1,243 @@
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Credential mapping keys accepted by --map-credentials.
+const (
+	mapNeo4jDatabase    = "neo4j-database"
+	mapNeo4jUser        = "neo4j-user"
+	mapPostgresDatabase = "postgres-database"
+	mapPostgresRole     = "postgres-role"
+)
+
+// mappingNamePattern restricts mapped names to identifiers that are safe to
+// pass to shell commands and Cypher scripts.
+var mappingNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// NameMapping renames a source deployment identifier to its target equivalent.
+type NameMapping struct {
+	Source string
+	Target string
+}
+
+// CredentialMapping translates names from the deployment a backup was taken
+// on to the deployment it is restored into.
+type CredentialMapping struct {
+	Neo4jDatabase    *NameMapping
+	Neo4jUser        *NameMapping
+	PostgresDatabase *NameMapping
+	PostgresRole     *NameMapping
+}
+
+// clone deep-copies the mapping; it returns nil when m is nil.
+func (m *CredentialMapping) clone() *CredentialMapping {
+	if m == nil {
+		return nil
+	}
+	copyName := func(n *NameMapping) *NameMapping {
+		if n == nil {
+			return nil
+		}
+		copied := *n
+		return &copied
+	}
+	return &CredentialMapping{
+		Neo4jDatabase:    copyName(m.Neo4jDatabase),
+		Neo4jUser:        copyName(m.Neo4jUser),
+		PostgresDatabase: copyName(m.PostgresDatabase),
+		PostgresRole:     copyName(m.PostgresRole),
+	}
+}
+
+// ParseCredentialMappings builds a mapping from `key=source:target` entries,
+// given inline and/or in a file with one entry per line.
+func ParseCredentialMappings(entries []string, file string) (*CredentialMapping, error) {
+	all := append([]string(nil), entries...)
+	if file != "" {
+		fromFile, err := readCredentialMappingFile(file)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, fromFile...)
+	}
+	if len(all) == 0 {
+		return nil, nil
+	}
+
+	mapping := &CredentialMapping{}
+	for _, entry := range all {
+		key, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid credential mapping %q: expected key=source:target", entry)
+		}
+		source, target, ok := strings.Cut(value, ":")
+		source, target = strings.TrimSpace(source), strings.TrimSpace(target)
+		if !ok || source == "" || target == "" {
+			return nil, fmt.Errorf("invalid credential mapping %q: expected key=source:target", entry)
+		}
+		if !mappingNamePattern.MatchString(source) || !mappingNamePattern.MatchString(target) {
+			return nil, fmt.Errorf("invalid credential mapping %q: names may only contain letters, digits, '.', '_' and '-'", entry)
+		}
+
+		pair := &NameMapping{Source: source, Target: target}
+		switch strings.TrimSpace(key) {
+		case mapNeo4jDatabase:
+			mapping.Neo4jDatabase = pair
+		case mapNeo4jUser:
+			mapping.Neo4jUser = pair
+		case mapPostgresDatabase:
+			mapping.PostgresDatabase = pair
+		case mapPostgresRole:
+			mapping.PostgresRole = pair
+		default:
+			return nil, fmt.Errorf("unknown credential mapping key %q (expected %s, %s, %s or %s)",
+				key, mapNeo4jDatabase, mapNeo4jUser, mapPostgresDatabase, mapPostgresRole)
+		}
+	}
+	return mapping, nil
+}
+
+func readCredentialMappingFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open credential mapping file: %w", err)
+	}
+	defer file.Close()
+
+	entries := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read credential mapping file: %w", err)
+	}
+	return entries, nil
+}
+
+// LogSummary reports the active mappings before the restore starts.
+func (m *CredentialMapping) LogSummary() {
+	if m == nil {
+		return
+	}
+	pairs := []struct {
+		key  string
+		pair *NameMapping
+	}{
+		{mapNeo4jDatabase, m.Neo4jDatabase},
+		{mapNeo4jUser, m.Neo4jUser},
+		{mapPostgresDatabase, m.PostgresDatabase},
+		{mapPostgresRole, m.PostgresRole},
+	}
+	for _, p := range pairs {
+		if p.pair != nil {
+			logrus.Infof("Mapping %s %q to %q", p.key, p.pair.Source, p.pair.Target)
+		}
+	}
+}
+
+// pgRestoreTargetArgs returns the pg_restore options that choose the target
+// database and ownership handling. Without mappings the dump recreates its own
+// database; a database mapping restores into the existing target database and
+// a role mapping drops the source ownership and grants.
+func pgRestoreTargetArgs(mapping *CredentialMapping, connectUser string) []string {
+	args := []string{"-d", "postgres", "--clean", "--create"}
+	if mapping == nil {
+		return args
+	}
+
+	if mapping.PostgresDatabase != nil {
+		args = []string{"-d", mapping.PostgresDatabase.Target, "--clean", "--if-exists"}
+	}
+	if mapping.PostgresRole != nil {
+		args = append(args, "--no-owner", "--no-privileges")
+		if mapping.PostgresRole.Target != connectUser {
+			args = append(args, "--role="+mapping.PostgresRole.Target)
+		}
+	}
+	return args
+}
+
+// renameNeo4jBackupFiles renames the staged Neo4j dump or backup artifacts so
+// neo4j-admin finds them under the target database name.
+func renameNeo4jBackupFiles(databaseDir string, pair *NameMapping) error {
+	if pair == nil || pair.Source == pair.Target {
+		return nil
+	}
+
+	entries, err := os.ReadDir(databaseDir)
+	if err != nil {
+		return fmt.Errorf("failed to read neo4j backup directory: %w", err)
+	}
+
+	renamed := 0
+	for _, entry := range entries {
+		name := entry.Name()
+		var newName string
+		switch {
+		case name == pair.Source+".dump":
+			newName = pair.Target + ".dump"
+		case strings.HasPrefix(name, pair.Source+"-") && strings.HasSuffix(name, ".backup"):
+			newName = pair.Target + strings.TrimPrefix(name, pair.Source)
+		default:
+			continue
+		}
+		if err := os.Rename(filepath.Join(databaseDir, name), filepath.Join(databaseDir, newName)); err != nil {
+			return fmt.Errorf("failed to rename %s: %w", name, err)
+		}
+		renamed++
+	}
+
+	if renamed == 0 {
+		return fmt.Errorf("no neo4j backup files for database %q found in archive", pair.Source)
+	}
+	return nil
+}
+
+// neo4jMetadataUserRewrite returns a sed expression renaming the mapped Neo4j
+// user in the restore_metadata.cypher script, or an empty string.
+func neo4jMetadataUserRewrite(mapping *CredentialMapping) string {
+	if mapping == nil || mapping.Neo4jUser == nil {
+		return ""
+	}
+	return fmt.Sprintf("s/`%s`/`%s`/g", sedQuoteMeta(mapping.Neo4jUser.Source), sedQuoteReplacement(mapping.Neo4jUser.Target))
+}
+
+// sedQuoteMeta escapes the characters that are special in a sed basic regular
+// expression, so that a name such as "infra.admin" only matches itself.
+func sedQuoteMeta(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`\/.*[]^$`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// sedQuoteReplacement escapes the characters that are special in the
+// replacement of a sed substitution.
+func sedQuoteReplacement(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`\/&`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
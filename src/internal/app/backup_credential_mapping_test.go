@@ -0,0 +1,122 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseCredentialMappings(t *testing.T) {
+	mappingFile := filepath.Join(t.TempDir(), "mapping.txt")
+	content := "# source deployment: helm\nneo4j-database=graph:neo4j\n\npostgres-role=prefect:postgres\n"
+	if err := os.WriteFile(mappingFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mapping, err := ParseCredentialMappings([]string{"postgres-database=prefect:infrahub_tm"}, mappingFile)
+	if err != nil {
+		t.Fatalf("ParseCredentialMappings: %v", err)
+	}
+	want := &CredentialMapping{
+		Neo4jDatabase:    &NameMapping{Source: "graph", Target: "neo4j"},
+		PostgresDatabase: &NameMapping{Source: "prefect", Target: "infrahub_tm"},
+		PostgresRole:     &NameMapping{Source: "prefect", Target: "postgres"},
+	}
+	if !reflect.DeepEqual(mapping, want) {
+		t.Errorf("ParseCredentialMappings() = %+v, want %+v", mapping, want)
+	}
+
+	if mapping, err := ParseCredentialMappings(nil, ""); err != nil || mapping != nil {
+		t.Errorf("empty input = (%v, %v), want (nil, nil)", mapping, err)
+	}
+
+	for _, bad := range []string{"neo4j-database", "neo4j-database=graph", "redis-db=a:b", "neo4j-user=a:b'; DROP", "postgres-role=:x"} {
+		if _, err := ParseCredentialMappings([]string{bad}, ""); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestPgRestoreTargetArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping *CredentialMapping
+		want    []string
+	}{
+		{
+			name: "no mapping",
+			want: []string{"-d", "postgres", "--clean", "--create"},
+		},
+		{
+			name:    "database mapping",
+			mapping: &CredentialMapping{PostgresDatabase: &NameMapping{Source: "prefect", Target: "tm"}},
+			want:    []string{"-d", "tm", "--clean", "--if-exists"},
+		},
+		{
+			name:    "role mapped to connecting user",
+			mapping: &CredentialMapping{PostgresRole: &NameMapping{Source: "prefect", Target: "postgres"}},
+			want:    []string{"-d", "postgres", "--clean", "--create", "--no-owner", "--no-privileges"},
+		},
+		{
+			name:    "role mapped to another role",
+			mapping: &CredentialMapping{PostgresRole: &NameMapping{Source: "prefect", Target: "app"}},
+			want:    []string{"-d", "postgres", "--clean", "--create", "--no-owner", "--no-privileges", "--role=app"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := pgRestoreTargetArgs(tt.mapping, "postgres")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pgRestoreTargetArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenameNeo4jBackupFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"graph.dump", "graph-2025-01-01T00-00-00.backup", "other.dump"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := renameNeo4jBackupFiles(dir, &NameMapping{Source: "graph", Target: "neo4j"}); err != nil {
+		t.Fatalf("renameNeo4jBackupFiles: %v", err)
+	}
+	for _, name := range []string{"neo4j.dump", "neo4j-2025-01-01T00-00-00.backup", "other.dump"} {
+		if !fileExists(filepath.Join(dir, name)) {
+			t.Errorf("expected %s after rename", name)
+		}
+	}
+
+	if err := renameNeo4jBackupFiles(dir, &NameMapping{Source: "missing", Target: "neo4j"}); err == nil {
+		t.Error("expected error when no files match the source database")
+	}
+}
+
+func TestNeo4jMetadataUserRewrite(t *testing.T) {
+	if got := neo4jMetadataUserRewrite(nil); got != "" {
+		t.Errorf("neo4jMetadataUserRewrite(nil) = %q, want empty", got)
+	}
+
+	mapping := &CredentialMapping{Neo4jUser: &NameMapping{Source: "infra.admin", Target: "neo4j"}}
+	if got, want := neo4jMetadataUserRewrite(mapping), "s/`infra\\.admin`/`neo4j`/g"; got != want {
+		t.Errorf("neo4jMetadataUserRewrite() = %q, want %q", got, want)
+	}
+
+	for in, want := range map[string]string{
+		"neo4j":     "neo4j",
+		"a.b":       `a\.b`,
+		`x*[^$]/\y`: `x\*\[\^\$\]\/\\y`,
+	} {
+		if got := sedQuoteMeta(in); got != want {
+			t.Errorf("sedQuoteMeta(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if got, want := sedQuoteReplacement(`a&b/c\d`), `a\&b\/c\\d`; got != want {
+		t.Errorf("sedQuoteReplacement() = %q, want %q", got, want)
+	}
+}
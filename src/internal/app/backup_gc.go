@@ -0,0 +1,292 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Garbage collection finding kinds.
+const (
+	GCMissing      = "missing"      // catalog entry whose archive is gone
+	GCUncatalogued = "uncatalogued" // archive that is not in the catalog
+	GCPartial      = "partial"      // leftover of an interrupted write or upload
+)
+
+// gcPartialMinAge keeps gc away from leftovers that may still belong to a
+// running backup or upload.
+const gcPartialMinAge = time.Hour
+
+// splitPartPattern matches the parts written by splitArchive.
+var splitPartPattern = regexp.MustCompile(`\.tar\.gz(\.enc)?\.\d{3}$`)
+
+// GCFinding is one difference between the catalog and the stored archives,
+// with the action --fix takes for it.
+type GCFinding struct {
+	Kind     string `json:"kind"`
+	BackupID string `json:"backup_id,omitempty"`
+	Location string `json:"location"`
+	Action   string `json:"action"`
+}
+
+// GCReport lists what gc found and whether it was fixed.
+type GCReport struct {
+	Findings []GCFinding `json:"findings"`
+	Fixed    bool        `json:"fixed"`
+}
+
+// Write prints the findings as a table.
+func (r *GCReport) Write(w io.Writer) {
+	if len(r.Findings) == 0 {
+		fmt.Fprintln(w, "Backup catalog matches the stored archives")
+		return
+	}
+	fmt.Fprintf(w, "%-12s  %-40s  %s\n", "KIND", "LOCATION", "ACTION")
+	for _, finding := range r.Findings {
+		fmt.Fprintf(w, "%-12s  %-40s  %s\n", finding.Kind, finding.Location, finding.Action)
+	}
+	if !r.Fixed {
+		fmt.Fprintf(w, "\n%d findings; run with --fix to apply the actions\n", len(r.Findings))
+	}
+}
+
+// gcFile is a file in BackupDir, an S3 object key or an incomplete upload.
+type gcFile struct {
+	name    string
+	modTime time.Time
+}
+
+// gcInventory is what is actually stored in BackupDir and, when S3 is
+// configured, under the bucket prefix.
+type gcInventory struct {
+	backupDir string
+	local     []gcFile
+	s3Listed  bool
+	s3Bucket  string
+	s3Prefix  string
+	s3Keys    []string
+	uploads   []gcFile
+}
+
+func (inv gcInventory) s3URI(key string) string {
+	return fmt.Sprintf("s3://%s/%s", inv.s3Bucket, key)
+}
+
+// s3Key returns the key of an S3 URI inside the listed bucket prefix.
+func (inv gcInventory) s3Key(uri string) (string, bool) {
+	bucket, key, ok := ParseS3URI(uri)
+	if !ok || !inv.s3Listed || bucket != inv.s3Bucket || !strings.HasPrefix(key, inv.s3Prefix) {
+		return "", false
+	}
+	return key, true
+}
+
+// isBackupArchiveName reports whether name is an archive written by create,
+// or the manifest of a split one.
+func isBackupArchiveName(name string) bool {
+	name = strings.TrimSuffix(name, splitManifestSuffix)
+	return strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tar.gz.enc")
+}
+
+// reconcileCatalog compares the catalog with the inventory. Entries are
+// removed only when no copy of the archive is left and the backup is not held.
+func reconcileCatalog(catalog *BackupCatalog, inv gcInventory, now time.Time) []GCFinding {
+	var findings []GCFinding
+
+	for _, entry := range catalog.Entries {
+		localGone := entry.LocalPath != "" && !fileExists(entry.LocalPath)
+		key, inBucket := inv.s3Key(entry.S3URI)
+		s3Gone := inBucket && !slices.Contains(inv.s3Keys, key)
+		if !localGone && !s3Gone {
+			continue
+		}
+		remaining := (entry.LocalPath != "" && !localGone) || (entry.S3URI != "" && !s3Gone)
+		action := "forget this copy"
+		switch {
+		case !remaining && entry.Held:
+			action = "none (backup is held)"
+		case !remaining:
+			action = "remove catalog entry"
+		}
+		if localGone {
+			findings = append(findings, GCFinding{Kind: GCMissing, BackupID: entry.BackupID, Location: entry.LocalPath, Action: action})
+		}
+		if s3Gone {
+			findings = append(findings, GCFinding{Kind: GCMissing, BackupID: entry.BackupID, Location: entry.S3URI, Action: action})
+		}
+	}
+
+	splitParts := map[string]bool{}
+	for _, file := range inv.local {
+		if strings.HasSuffix(file.name, splitManifestSuffix) {
+			parts, err := splitArchiveFiles(filepath.Join(inv.backupDir, file.name))
+			if err != nil {
+				logrus.Warnf("Cannot read split manifest %s: %v", file.name, err)
+				continue
+			}
+			for _, part := range parts {
+				splitParts[filepath.Base(part)] = true
+			}
+		}
+	}
+	for _, file := range inv.local {
+		path := filepath.Join(inv.backupDir, file.name)
+		switch {
+		case strings.HasSuffix(file.name, ".partial") || strings.HasSuffix(file.name, ".tmp") ||
+			(splitPartPattern.MatchString(file.name) && !splitParts[file.name]):
+			if now.Sub(file.modTime) >= gcPartialMinAge {
+				findings = append(findings, GCFinding{Kind: GCPartial, Location: path, Action: "delete file"})
+			}
+		case isBackupArchiveName(file.name) && catalog.find(file.name) == nil:
+			findings = append(findings, GCFinding{Kind: GCUncatalogued, BackupID: backupIDFromFilename(file.name), Location: path, Action: "add to catalog"})
+		}
+	}
+
+	for _, key := range inv.s3Keys {
+		uri := inv.s3URI(key)
+		if !isBackupArchiveName(key) || slices.ContainsFunc(catalog.Entries, func(e CatalogEntry) bool { return e.S3URI == uri }) {
+			continue
+		}
+		findings = append(findings, GCFinding{Kind: GCUncatalogued, BackupID: backupIDFromFilename(key), Location: uri, Action: "add to catalog"})
+	}
+	for _, upload := range inv.uploads {
+		if now.Sub(upload.modTime) >= gcPartialMinAge {
+			findings = append(findings, GCFinding{Kind: GCPartial, Location: inv.s3URI(upload.name), Action: "abort multipart upload"})
+		}
+	}
+	return findings
+}
+
+// applyGCFindings carries out the actions of the findings. abort cancels an
+// incomplete S3 upload by key.
+func (iops *InfrahubOps) applyGCFindings(catalog *BackupCatalog, inv gcInventory, findings []GCFinding, abort func(key string) error) error {
+	for _, finding := range findings {
+		switch finding.Kind {
+		case GCMissing:
+			forgetCatalogCopy(catalog, finding.BackupID, finding.Location)
+		case GCUncatalogued:
+			if IsS3URI(finding.Location) {
+				if entry := catalog.find(finding.BackupID); entry != nil {
+					entry.S3URI = finding.Location
+					continue
+				}
+				catalog.Entries = append(catalog.Entries, CatalogEntry{
+					BackupID:  finding.BackupID,
+					Filename:  filepath.Base(finding.Location),
+					S3URI:     finding.Location,
+					CreatedAt: time.Now().UTC().Format(time.RFC3339),
+				})
+				continue
+			}
+			entry, err := iops.catalogEntryForRef(finding.Location)
+			if err != nil {
+				return err
+			}
+			catalog.upsert(*entry)
+		case GCPartial:
+			if key, ok := inv.s3Key(finding.Location); ok {
+				if err := abort(key); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := os.Remove(finding.Location); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to delete %s: %w", finding.Location, err)
+			}
+		}
+	}
+	return catalog.save()
+}
+
+// forgetCatalogCopy clears a vanished location from an entry and drops the
+// entry once no copy is left, unless it is held.
+func forgetCatalogCopy(catalog *BackupCatalog, backupID, location string) {
+	for i := range catalog.Entries {
+		entry := &catalog.Entries[i]
+		if entry.BackupID != backupID {
+			continue
+		}
+		switch location {
+		case entry.LocalPath:
+			entry.LocalPath = ""
+		case entry.S3URI:
+			entry.S3URI = ""
+		}
+		if entry.LocalPath == "" && entry.S3URI == "" && !entry.Held {
+			catalog.Entries = slices.Delete(catalog.Entries, i, i+1)
+		}
+		return
+	}
+}
+
+// GarbageCollect reconciles the backup catalog with the archives in
+// BackupDir and, when a bucket is configured, in S3. With fix, vanished
+// archives are dropped from the catalog, uncatalogued archives are added, and
+// leftovers older than an hour are deleted or aborted.
+func (iops *InfrahubOps) GarbageCollect(fix bool) (*GCReport, error) {
+	catalog, err := loadBackupCatalog(iops.config.BackupDir)
+	if err != nil {
+		return nil, err
+	}
+
+	inv := gcInventory{backupDir: iops.config.BackupDir}
+	dirEntries, err := os.ReadDir(iops.config.BackupDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+	for _, dirEntry := range dirEntries {
+		info, err := dirEntry.Info()
+		if err != nil || !info.Mode().IsRegular() || dirEntry.Name() == backupCatalogFilename {
+			continue
+		}
+		inv.local = append(inv.local, gcFile{name: dirEntry.Name(), modTime: info.ModTime()})
+	}
+
+	var client *S3Client
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	if iops.config.S3 != nil && iops.config.S3.Bucket != "" {
+		client, err = iops.s3Client(*iops.config.S3)
+		if err != nil {
+			return nil, err
+		}
+		if inv.s3Keys, err = client.List(ctx); err != nil {
+			return nil, err
+		}
+		uploads, err := client.ListIncompleteUploads(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, upload := range uploads {
+			inv.uploads = append(inv.uploads, gcFile{name: upload.Key, modTime: upload.Initiated})
+		}
+		inv.s3Listed = true
+		inv.s3Bucket = iops.config.S3.Bucket
+		inv.s3Prefix = client.buildS3Key("")
+	}
+
+	report := &GCReport{Findings: reconcileCatalog(catalog, inv, time.Now())}
+	if !fix || len(report.Findings) == 0 {
+		return report, nil
+	}
+	abort := func(key string) error { return client.AbortIncompleteUpload(ctx, key) }
+	if err := iops.applyGCFindings(catalog, inv, report.Findings, abort); err != nil {
+		return report, err
+	}
+	report.Fixed = true
+	logrus.Infof("Applied %d garbage collection actions", len(report.Findings))
+	actions := make([]string, 0, len(report.Findings))
+	for _, finding := range report.Findings {
+		actions = append(actions, fmt.Sprintf("%s %s: %s", finding.Kind, finding.Location, finding.Action))
+	}
+	iops.appendRunHistory(HistoryEntry{Operation: HistoryGC, Status: "success", Actions: actions})
+	return report, nil
+}
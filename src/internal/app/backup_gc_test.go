@@ -0,0 +1,156 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// writeGCFile creates name in dir with the given age.
+func writeGCFile(t *testing.T, dir, name string, age time.Duration) gcFile {
+	t.Helper()
+	writeTestFile(t, dir, name, "data")
+	modTime := time.Now().Add(-age)
+	if err := os.Chtimes(filepath.Join(dir, name), modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	return gcFile{name: name, modTime: modTime}
+}
+
+func newGCFixture(t *testing.T) (*InfrahubOps, *BackupCatalog, gcInventory) {
+	t.Helper()
+	dir := t.TempDir()
+	iops := NewInfrahubOps()
+	iops.config.BackupDir = dir
+
+	inv := gcInventory{
+		backupDir: dir,
+		s3Listed:  true,
+		s3Bucket:  "bucket",
+		s3Prefix:  "prod/",
+		s3Keys:    []string{"prod/b.tar.gz", "prod/i.tar.gz.enc", "prod/readme.txt"},
+		uploads: []gcFile{
+			{name: "prod/j.tar.gz", modTime: time.Now().Add(-2 * time.Hour)},
+			{name: "prod/k.tar.gz", modTime: time.Now()},
+		},
+	}
+	for _, file := range []struct {
+		name string
+		age  time.Duration
+	}{
+		{"a.tar.gz", 0},
+		{"f.tar.gz", 0},
+		{"old.tar.gz.partial", 2 * time.Hour},
+		{"new.tar.gz.partial", 0},
+		{"g.tar.gz.001", 2 * time.Hour},
+		{"notes.txt", 2 * time.Hour},
+	} {
+		inv.local = append(inv.local, writeGCFile(t, dir, file.name, file.age))
+	}
+	writeTestFile(t, dir, "h.tar.gz.001", "data")
+	writeTestFile(t, dir, "h.tar.gz"+splitManifestSuffix, `{"archive":"h.tar.gz","parts":[{"name":"h.tar.gz.001"}]}`)
+	inv.local = append(inv.local,
+		gcFile{name: "h.tar.gz.001", modTime: time.Now().Add(-2 * time.Hour)},
+		gcFile{name: "h.tar.gz" + splitManifestSuffix, modTime: time.Now()})
+
+	catalog := &BackupCatalog{path: filepath.Join(dir, backupCatalogFilename), Entries: []CatalogEntry{
+		{BackupID: "a", Filename: "a.tar.gz", LocalPath: filepath.Join(dir, "a.tar.gz")},
+		{BackupID: "b", Filename: "b.tar.gz", LocalPath: filepath.Join(dir, "b.tar.gz"), S3URI: "s3://bucket/prod/b.tar.gz"},
+		{BackupID: "c", Filename: "c.tar.gz", LocalPath: filepath.Join(dir, "c.tar.gz")},
+		{BackupID: "d", Filename: "d.tar.gz", LocalPath: filepath.Join(dir, "d.tar.gz"), Held: true},
+		{BackupID: "e", Filename: "e.tar.gz", S3URI: "s3://bucket/prod/e.tar.gz"},
+		{BackupID: "x", Filename: "x.tar.gz", S3URI: "s3://other/x.tar.gz"},
+	}}
+	return iops, catalog, inv
+}
+
+func TestReconcileCatalog(t *testing.T) {
+	_, catalog, inv := newGCFixture(t)
+	dir := inv.backupDir
+
+	got := reconcileCatalog(catalog, inv, time.Now())
+	want := []GCFinding{
+		{Kind: GCMissing, BackupID: "b", Location: filepath.Join(dir, "b.tar.gz"), Action: "forget this copy"},
+		{Kind: GCMissing, BackupID: "c", Location: filepath.Join(dir, "c.tar.gz"), Action: "remove catalog entry"},
+		{Kind: GCMissing, BackupID: "d", Location: filepath.Join(dir, "d.tar.gz"), Action: "none (backup is held)"},
+		{Kind: GCMissing, BackupID: "e", Location: "s3://bucket/prod/e.tar.gz", Action: "remove catalog entry"},
+		{Kind: GCUncatalogued, BackupID: "f", Location: filepath.Join(dir, "f.tar.gz"), Action: "add to catalog"},
+		{Kind: GCPartial, Location: filepath.Join(dir, "old.tar.gz.partial"), Action: "delete file"},
+		{Kind: GCPartial, Location: filepath.Join(dir, "g.tar.gz.001"), Action: "delete file"},
+		{Kind: GCUncatalogued, BackupID: "h", Location: filepath.Join(dir, "h.tar.gz"+splitManifestSuffix), Action: "add to catalog"},
+		{Kind: GCUncatalogued, BackupID: "i", Location: "s3://bucket/prod/i.tar.gz.enc", Action: "add to catalog"},
+		{Kind: GCPartial, Location: "s3://bucket/prod/j.tar.gz", Action: "abort multipart upload"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("reconcileCatalog() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestApplyGCFindings(t *testing.T) {
+	iops, catalog, inv := newGCFixture(t)
+	dir := inv.backupDir
+	var aborted []string
+	abort := func(key string) error {
+		aborted = append(aborted, key)
+		return nil
+	}
+
+	if err := iops.applyGCFindings(catalog, inv, reconcileCatalog(catalog, inv, time.Now()), abort); err != nil {
+		t.Fatalf("applyGCFindings() error = %v", err)
+	}
+
+	saved, err := loadBackupCatalog(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, entry := range saved.Entries {
+		ids = append(ids, entry.BackupID)
+	}
+	if want := []string{"a", "b", "d", "x", "f", "h", "i"}; !slices.Equal(ids, want) {
+		t.Errorf("catalog entries = %v, want %v", ids, want)
+	}
+	if b := saved.find("b"); b.LocalPath != "" || b.S3URI == "" {
+		t.Errorf("entry b = %+v, want only its S3 copy", b)
+	}
+	if d := saved.find("d"); !d.Held {
+		t.Errorf("held entry d lost its hold: %+v", d)
+	}
+	if f := saved.find("f"); f.LocalPath != filepath.Join(dir, "f.tar.gz") {
+		t.Errorf("entry f = %+v", f)
+	}
+	for name, wantExists := range map[string]bool{
+		"old.tar.gz.partial": false,
+		"new.tar.gz.partial": true,
+		"g.tar.gz.001":       false,
+		"h.tar.gz.001":       true,
+		"notes.txt":          true,
+	} {
+		if got := fileExists(filepath.Join(dir, name)); got != wantExists {
+			t.Errorf("%s exists = %v, want %v", name, got, wantExists)
+		}
+	}
+	if !slices.Equal(aborted, []string{"prod/j.tar.gz"}) {
+		t.Errorf("aborted uploads = %v", aborted)
+	}
+}
+
+func TestGarbageCollectWithoutFixChangesNothing(t *testing.T) {
+	iops := NewInfrahubOps()
+	iops.config.BackupDir = t.TempDir()
+	iops.config.S3.Bucket = ""
+	writeTestFile(t, iops.config.BackupDir, "f.tar.gz", "data")
+
+	report, err := iops.GarbageCollect(false)
+	if err != nil {
+		t.Fatalf("GarbageCollect() error = %v", err)
+	}
+	if len(report.Findings) != 1 || report.Findings[0].Kind != GCUncatalogued || report.Fixed {
+		t.Errorf("report = %+v", report)
+	}
+	if fileExists(filepath.Join(iops.config.BackupDir, backupCatalogFilename)) {
+		t.Error("catalog written without --fix")
+	}
+}
@@ -0,0 +1,54 @@
+package app
+
+import (
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// GitSource is the Git checkout a backup was taken from, recorded with
+// --label-from-git to correlate the backup with the infrastructure-as-code
+// state of the time.
+type GitSource struct {
+	Commit string `json:"commit"`
+	Branch string `json:"branch,omitempty"` // empty on a detached HEAD
+	Dirty  bool   `json:"dirty,omitempty"`  // the checkout had uncommitted changes
+}
+
+// String formats the checkout as branch@commit, with the commit shortened.
+func (g *GitSource) String() string {
+	commit := g.Commit
+	if len(commit) > 12 {
+		commit = commit[:12]
+	}
+	if g.Branch != "" {
+		commit = g.Branch + "@" + commit
+	}
+	if g.Dirty {
+		commit += " (uncommitted changes)"
+	}
+	return commit
+}
+
+// describeGitCheckout reads the commit and branch of the Git repository in
+// the working directory. Outside a repository, or without git, it warns and
+// returns nil: the label is informational and never fails a backup.
+func (iops *InfrahubOps) describeGitCheckout() *GitSource {
+	commit, err := iops.executor.runCommand("git", "rev-parse", "--verify", "HEAD")
+	commit = strings.TrimSpace(commit)
+	if err != nil || commit == "" {
+		logrus.Warnf("--label-from-git: no Git commit found in the working directory: %v", err)
+		return nil
+	}
+	source := &GitSource{Commit: commit}
+	if branch, err := iops.executor.runCommand("git", "rev-parse", "--abbrev-ref", "HEAD"); err == nil {
+		if branch = strings.TrimSpace(branch); branch != "HEAD" {
+			source.Branch = branch
+		}
+	}
+	if status, err := iops.executor.runCommand("git", "status", "--porcelain", "--untracked-files=no"); err == nil {
+		source.Dirty = strings.TrimSpace(status) != ""
+	}
+	logrus.Infof("Labelling backup with Git checkout %s", source)
+	return source
+}
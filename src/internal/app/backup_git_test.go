@@ -0,0 +1,63 @@
+package app
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDescribeGitCheckout(t *testing.T) {
+	const sha = "0123456789abcdef0123456789abcdef01234567"
+	tests := []struct {
+		name   string
+		fake   *fakeExecutor
+		want   *GitSource
+		String string
+	}{
+		{
+			name:   "clean branch",
+			fake:   newFakeExecutor().on("--verify HEAD", sha+"\n", nil).on("--abbrev-ref HEAD", "main\n", nil),
+			want:   &GitSource{Commit: sha, Branch: "main"},
+			String: "main@0123456789ab",
+		},
+		{
+			name:   "detached with changes",
+			fake:   newFakeExecutor().on("--verify HEAD", sha, nil).on("--abbrev-ref HEAD", "HEAD", nil).on("status --porcelain", " M schema.yml\n", nil),
+			want:   &GitSource{Commit: sha, Dirty: true},
+			String: "0123456789ab (uncommitted changes)",
+		},
+		{
+			name: "not a repository",
+			fake: newFakeExecutor().on("git rev-parse", "fatal: not a git repository", errors.New("exit status 128")),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iops := NewInfrahubOpsWithExecutor(tt.fake)
+			got := iops.describeGitCheckout()
+			if tt.want == nil {
+				if got != nil {
+					t.Fatalf("describeGitCheckout() = %+v, want nil", got)
+				}
+				return
+			}
+			if got == nil || *got != *tt.want {
+				t.Fatalf("describeGitCheckout() = %+v, want %+v", got, tt.want)
+			}
+			if got.String() != tt.String {
+				t.Errorf("String() = %q, want %q", got.String(), tt.String)
+			}
+		})
+	}
+}
+
+func TestDescribeBackupSourceGitLabel(t *testing.T) {
+	fake := newFakeExecutor().on("--verify HEAD", "abc123", nil)
+	iops := NewInfrahubOpsWithExecutor(fake)
+	if source := iops.describeBackupSource(); source.Git != nil || len(fake.commands("git")) != 0 {
+		t.Fatalf("Git read without --label-from-git: %+v", source.Git)
+	}
+	iops.config.LabelFromGit = true
+	if source := iops.describeBackupSource(); source.Git == nil || source.Git.Commit != "abc123" {
+		t.Errorf("source.Git = %+v, want commit abc123", source.Git)
+	}
+}
@@ -0,0 +1,220 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// helmComponent is recorded in backup metadata when the values of the
+	// Helm release are included.
+	helmComponent = "helm"
+
+	// helmValuesFilename holds the user-supplied values of the backed-up Helm
+	// release, as printed by `helm get values`.
+	helmValuesFilename = "helm_values.yaml"
+
+	// DefaultHelmChart is installed by --bootstrap-helm unless --helm-chart is set.
+	DefaultHelmChart = "oci://registry.opsmill.io/opsmill/chart/infrahub"
+
+	// defaultHelmRelease names the release --bootstrap-helm installs when
+	// neither --release-name nor the backup records one.
+	defaultHelmRelease = "infrahub"
+)
+
+// helmReleaseName returns the release set with --release-name, or the only
+// Infrahub release in the namespace.
+func (k *KubernetesBackend) helmReleaseName() (string, error) {
+	if k.config.K8sReleaseName != "" {
+		return k.config.K8sReleaseName, nil
+	}
+	releases, err := k.listReleases()
+	if err != nil {
+		return "", fmt.Errorf("failed to list Helm releases: %w", err)
+	}
+	if len(releases) != 1 {
+		return "", fmt.Errorf("expected one Helm release in namespace %s, found %d", k.namespace, len(releases))
+	}
+	return releases[0], nil
+}
+
+// helmRelease is the Helm release an Infrahub deployment was installed with.
+type helmRelease struct {
+	Name   string
+	Chart  string // name-version, as printed by `helm list`
+	Values []byte
+}
+
+// exportHelmRelease returns the Helm release on Kubernetes with its
+// user-supplied values. It returns nil on Docker, or when the helm CLI is not
+// installed.
+func (iops *InfrahubOps) exportHelmRelease() (*helmRelease, error) {
+	backend, err := iops.ensureBackend()
+	if err != nil {
+		return nil, err
+	}
+	k8s, ok := backend.(*KubernetesBackend)
+	if !ok {
+		return nil, nil
+	}
+	if err := iops.executor.runCommandQuiet("helm", "version", "--short"); err != nil {
+		logrus.Debugf("helm CLI not available; skipping Helm values: %v", err)
+		return nil, nil
+	}
+
+	name, err := k8s.helmReleaseName()
+	if err != nil {
+		return nil, err
+	}
+	release := &helmRelease{Name: name}
+	release.Values, err = iops.readCommandOutput("helm", "get", "values", name, "-n", k8s.namespace, "-o", "yaml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read values of Helm release %s: %w", name, err)
+	}
+
+	output, err := iops.readCommandOutput("helm", "list", "-n", k8s.namespace, "--filter", "^"+name+"$", "-o", "json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chart of Helm release %s: %w", name, err)
+	}
+	var releases []struct {
+		Chart string `json:"chart"`
+	}
+	if err := json.Unmarshal(output, &releases); err != nil {
+		return nil, fmt.Errorf("failed to parse helm list output: %w", err)
+	}
+	if len(releases) == 1 {
+		release.Chart = releases[0].Chart
+	}
+	return release, nil
+}
+
+// splitHelmChart splits the name-version chart string of `helm list`. Chart
+// names can contain dashes; the version starts at the first dash followed by
+// a digit.
+func splitHelmChart(chart string) (name, version string) {
+	for i := 0; i < len(chart)-1; i++ {
+		if chart[i] == '-' && chart[i+1] >= '0' && chart[i+1] <= '9' {
+			return chart[:i], chart[i+1:]
+		}
+	}
+	return chart, ""
+}
+
+// HelmBootstrapOptions selects what restore --bootstrap-helm installs. Empty
+// fields fall back to what the backup recorded.
+type HelmBootstrapOptions struct {
+	Namespace    string
+	ValuesFile   string
+	Chart        string
+	ChartVersion string
+}
+
+// helmBootstrapSource returns the Helm values and the metadata of the
+// backup. Values come from opts.ValuesFile when set.
+func (iops *InfrahubOps) helmBootstrapSource(opts HelmBootstrapOptions, backupFile, decryptKey string) ([]byte, *BackupMetadata, error) {
+	archive, cleanup, err := iops.prepareSharedRestoreArchive(backupFile, decryptKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cleanup()
+	data, err := readArchiveMember(archive, "backup/"+backupMetadataFilename)
+	if err != nil {
+		return nil, nil, err
+	}
+	metadata, err := parseBackupMetadata(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if opts.ValuesFile != "" {
+		values, err := os.ReadFile(opts.ValuesFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read Helm values: %w", err)
+		}
+		return values, metadata, nil
+	}
+	values, err := readArchiveMember(archive, "backup/"+helmValuesFilename)
+	if err != nil {
+		return nil, nil, fmt.Errorf("backup has no Helm values; pass --helm-values: %w", err)
+	}
+	return values, metadata, nil
+}
+
+// RestoreIntoNewHelmRelease installs the Infrahub chart into a namespace
+// without an Infrahub deployment, waits for its workloads, then runs restore
+// against the new release.
+func (iops *InfrahubOps) RestoreIntoNewHelmRelease(opts HelmBootstrapOptions, backupFile, decryptKey string, restore func() error) error {
+	namespace := opts.Namespace
+	pods, err := iops.readCommandOutput("kubectl", "get", "pods", "-n", namespace, "-l", infrahubPodSelector, "-o", "name")
+	if err != nil {
+		return fmt.Errorf("failed to check namespace %s: %w", namespace, err)
+	}
+	if len(nonEmptyLines(string(pods))) > 0 {
+		return fmt.Errorf("namespace %s already runs Infrahub; restore into it with --k8s-namespace", namespace)
+	}
+
+	values, metadata, err := iops.helmBootstrapSource(opts, backupFile, decryptKey)
+	if err != nil {
+		return err
+	}
+
+	release := iops.config.K8sReleaseName
+	chart, version := opts.Chart, opts.ChartVersion
+	if source := metadata.Source; source != nil {
+		if release == "" {
+			release = source.Release
+		}
+		if recordedName, recordedVersion := splitHelmChart(source.Chart); chart == "" && version == "" && strings.HasPrefix(recordedName, "infrahub") {
+			version = recordedVersion
+		}
+	}
+	if release == "" {
+		release = defaultHelmRelease
+	}
+	if chart == "" {
+		chart = DefaultHelmChart
+	}
+	if _, err := iops.executor.runCommand("helm", "status", release, "-n", namespace); err == nil {
+		return fmt.Errorf("release %s already exists in namespace %s", release, namespace)
+	}
+
+	// The values can hold database passwords.
+	valuesFile, err := os.CreateTemp("", "infrahub-helm-values-*.yaml")
+	if err != nil {
+		return fmt.Errorf("failed to create values file: %w", err)
+	}
+	defer os.Remove(valuesFile.Name())
+	_, err = valuesFile.Write(values)
+	if closeErr := valuesFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write values file: %w", err)
+	}
+
+	args := []string{"install", release, chart, "-n", namespace, "--create-namespace", "-f", valuesFile.Name(), "--wait", "--timeout", "20m"}
+	if version != "" {
+		args = append(args, "--version", version)
+	}
+	logrus.WithFields(logrus.Fields{"release": release, "chart": chart, "version": version, "namespace": namespace}).Info("Installing Helm release")
+	if output, err := iops.executor.runCommand("helm", args...); err != nil {
+		return fmt.Errorf("failed to install Helm release %s: %w\nOutput: %s", release, err, output)
+	}
+
+	// Restore into the new release, not the one detection or a pin file picks.
+	iops.config.K8sReleaseName = release
+	backend, err := iops.assumeBackend("kubernetes", namespace)
+	if err != nil {
+		return err
+	}
+	iops.backend = backend
+	if err := restore(); err != nil {
+		return err
+	}
+	logrus.Infof("Helm release %s in namespace %s restored", release, namespace)
+	return nil
+}
@@ -0,0 +1,179 @@
+package app
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSplitHelmChart(t *testing.T) {
+	tests := []struct {
+		chart, name, version string
+	}{
+		{"infrahub-4.2.0", "infrahub", "4.2.0"},
+		{"infrahub-enterprise-4.2.0-rc1", "infrahub-enterprise", "4.2.0-rc1"},
+		{"infrahub", "infrahub", ""},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		name, version := splitHelmChart(tt.chart)
+		if name != tt.name || version != tt.version {
+			t.Errorf("splitHelmChart(%q) = %q, %q; want %q, %q", tt.chart, name, version, tt.name, tt.version)
+		}
+	}
+}
+
+func TestExportHelmRelease(t *testing.T) {
+	fake := newFakeExecutor().
+		on("metadata.labels", "prod\nprod", nil).
+		on("helm get values prod -n infrahub -o yaml", "database:\n  password: secret\n", nil).
+		on("helm list -n infrahub --filter ^prod$", `[{"name":"prod","chart":"infrahub-4.2.0"}]`, nil)
+	iops := NewInfrahubOpsWithExecutor(fake)
+	iops.backend = &KubernetesBackend{config: iops.config, executor: fake, namespace: "infrahub"}
+
+	release, err := iops.exportHelmRelease()
+	if err != nil {
+		t.Fatalf("exportHelmRelease() error = %v", err)
+	}
+	if release.Name != "prod" || release.Chart != "infrahub-4.2.0" || string(release.Values) != "database:\n  password: secret\n" {
+		t.Errorf("exportHelmRelease() = %+v", release)
+	}
+
+	if release, err := newFakeDockerOps(newFakeExecutor()).exportHelmRelease(); release != nil || err != nil {
+		t.Errorf("docker exportHelmRelease() = %+v, %v; want nothing", release, err)
+	}
+}
+
+// writeHelmBackup writes an archive whose metadata records source and, when
+// values is not empty, Helm values.
+func writeHelmBackup(t *testing.T, iops *InfrahubOps, source *BackupSource, values string) string {
+	t.Helper()
+	workDir := t.TempDir()
+	metadata := iops.createBackupMetadata("helm", false, "1.5.0", neo4jEditionCommunity)
+	metadata.Source = source
+	if values != "" {
+		writeTestFile(t, workDir, "backup/"+helmValuesFilename, values)
+		metadata.Components = append(metadata.Components, helmComponent)
+	}
+	data, err := marshalBackupMetadata(metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, workDir, "backup/"+backupMetadataFilename, string(data))
+	path, err := defaultArchivePipeline(false, false).Write(workDir, "backup/", filepath.Join(t.TempDir(), "helm"), ArchiveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRestoreIntoNewHelmRelease(t *testing.T) {
+	valuesFile := filepath.Join(t.TempDir(), "values.yaml")
+	writeTestFile(t, filepath.Dir(valuesFile), "values.yaml", "from: file\n")
+
+	tests := []struct {
+		name        string
+		opts        HelmBootstrapOptions
+		source      *BackupSource
+		wantValues  string
+		wantInstall string
+	}{
+		{
+			name:        "recorded release and chart",
+			source:      &BackupSource{Backend: "kubernetes", Release: "prod", Chart: "infrahub-4.2.0"},
+			wantValues:  "from: backup\n",
+			wantInstall: "helm install prod " + DefaultHelmChart + " -n dr",
+		},
+		{
+			name:        "explicit chart and values",
+			opts:        HelmBootstrapOptions{ValuesFile: valuesFile, Chart: "./charts/infrahub", ChartVersion: "5.0.0"},
+			source:      &BackupSource{Backend: "kubernetes", Chart: "infrahub-4.2.0"},
+			wantValues:  "from: file\n",
+			wantInstall: "helm install " + defaultHelmRelease + " ./charts/infrahub -n dr",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotValues string
+			fake := newFakeExecutor().on("helm status", "", errors.New("release: not found"))
+			iops := NewInfrahubOpsWithExecutor(fake)
+			backup := writeHelmBackup(t, iops, tt.source, "from: backup\n")
+			opts := tt.opts
+			opts.Namespace = "dr"
+
+			restore := func() error {
+				installs := fake.commands("helm install")
+				if len(installs) != 1 {
+					t.Fatalf("helm install calls = %v", installs)
+				}
+				fields := strings.Fields(installs[0])
+				values, err := os.ReadFile(fields[slices.Index(fields, "-f")+1])
+				if err != nil {
+					t.Fatal(err)
+				}
+				gotValues = string(values)
+				return nil
+			}
+			if err := iops.RestoreIntoNewHelmRelease(opts, backup, "", restore); err != nil {
+				t.Fatalf("RestoreIntoNewHelmRelease() error = %v", err)
+			}
+
+			install := fake.commands("helm install")[0]
+			if !strings.HasPrefix(install, tt.wantInstall) || !strings.Contains(install, "--create-namespace") || !strings.Contains(install, "--wait") {
+				t.Errorf("install = %q, want prefix %q", install, tt.wantInstall)
+			}
+			wantVersion := "--version 4.2.0"
+			if tt.opts.ChartVersion != "" {
+				wantVersion = "--version " + tt.opts.ChartVersion
+			}
+			if !strings.HasSuffix(install, wantVersion) {
+				t.Errorf("install = %q, want %q", install, wantVersion)
+			}
+			if gotValues != tt.wantValues {
+				t.Errorf("values = %q, want %q", gotValues, tt.wantValues)
+			}
+			if backend, _ := iops.ensureBackend(); backend.Name() != "kubernetes" || backend.Info() != "dr" {
+				t.Errorf("backend = %s %s", backend.Name(), backend.Info())
+			}
+		})
+	}
+}
+
+func TestRestoreIntoNewHelmReleaseRefusesExisting(t *testing.T) {
+	tests := []struct {
+		name string
+		fake *fakeExecutor
+		want string
+	}{
+		{"infrahub pods", newFakeExecutor().on("get pods", "pod/infrahub-server-0", nil), "already runs Infrahub"},
+		{"release exists", newFakeExecutor(), "release infrahub already exists"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iops := NewInfrahubOpsWithExecutor(tt.fake)
+			backup := writeHelmBackup(t, iops, &BackupSource{Backend: "kubernetes"}, "a: b\n")
+			restore := func() error { return errors.New("restore should not run") }
+			err := iops.RestoreIntoNewHelmRelease(HelmBootstrapOptions{Namespace: "dr"}, backup, "", restore)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+			if installs := tt.fake.commands("helm install"); len(installs) != 0 {
+				t.Errorf("release installed: %v", installs)
+			}
+		})
+	}
+}
+
+func TestRestoreIntoNewHelmReleaseNeedsValues(t *testing.T) {
+	fake := newFakeExecutor().on("helm status", "", errors.New("release: not found"))
+	iops := NewInfrahubOpsWithExecutor(fake)
+	backup := writeHelmBackup(t, iops, &BackupSource{Backend: "kubernetes"}, "")
+
+	err := iops.RestoreIntoNewHelmRelease(HelmBootstrapOptions{Namespace: "dr"}, backup, "", func() error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "--helm-values") {
+		t.Errorf("error = %v", err)
+	}
+}
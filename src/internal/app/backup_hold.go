@@ -0,0 +1,149 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// HoldBackup protects a backup archive from retention pruning. The hold is
+// recorded in the local catalog and, when the archive lives in S3, applied to
+// the object itself (Object Lock legal hold, or a tag when the bucket has no
+// Object Lock configuration).
+func (iops *InfrahubOps) HoldBackup(ref string, reason string) error {
+	return iops.setBackupHold(ref, true, reason)
+}
+
+// ReleaseBackup removes a hold previously placed with HoldBackup.
+func (iops *InfrahubOps) ReleaseBackup(ref string) error {
+	return iops.setBackupHold(ref, false, "")
+}
+
+func (iops *InfrahubOps) setBackupHold(ref string, held bool, reason string) error {
+	catalog, err := loadBackupCatalog(iops.config.BackupDir)
+	if err != nil {
+		return err
+	}
+
+	entry := catalog.find(ref)
+	if entry == nil {
+		imported, err := iops.catalogEntryForRef(ref)
+		if err != nil {
+			return err
+		}
+		entry = catalog.upsert(*imported)
+		logrus.Infof("Added %s to the backup catalog", entry.BackupID)
+	}
+
+	if entry.S3URI != "" {
+		if err := iops.setS3ObjectHold(entry.S3URI, held); err != nil {
+			return err
+		}
+	}
+
+	entry.Held = held
+	if held {
+		entry.HoldReason = reason
+		entry.HeldAt = time.Now().UTC().Format(time.RFC3339)
+	} else {
+		entry.HoldReason = ""
+		entry.HeldAt = ""
+	}
+
+	if err := catalog.save(); err != nil {
+		return err
+	}
+	history := HistoryEntry{Operation: HistoryRelease, Status: "success", BackupID: entry.BackupID}
+	if held {
+		history.Operation = HistoryHold
+		if reason != "" {
+			history.Actions = []string{reason}
+		}
+	}
+	iops.appendRunHistory(history)
+
+	fields := logrus.Fields{"backup_id": entry.BackupID}
+	if entry.LocalPath != "" {
+		fields["path"] = entry.LocalPath
+	}
+	if entry.S3URI != "" {
+		fields["s3_uri"] = entry.S3URI
+	}
+	if held {
+		if reason != "" {
+			fields["reason"] = reason
+		}
+		logrus.WithFields(fields).Info("Backup placed on hold")
+	} else {
+		logrus.WithFields(fields).Info("Backup hold released")
+	}
+	return nil
+}
+
+// catalogEntryForRef builds a catalog entry for an archive that predates the
+// catalog, so holds can be placed on any existing backup.
+func (iops *InfrahubOps) catalogEntryForRef(ref string) (*CatalogEntry, error) {
+	if IsS3URI(ref) {
+		if _, _, ok := ParseS3URI(ref); !ok {
+			return nil, fmt.Errorf("invalid S3 URI: %s", ref)
+		}
+		return &CatalogEntry{
+			BackupID:  backupIDFromFilename(ref),
+			Filename:  filepath.Base(ref),
+			S3URI:     ref,
+			CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		}, nil
+	}
+
+	candidates := []string{ref, filepath.Join(iops.config.BackupDir, ref)}
+	for _, suffix := range []string{".tar.gz", ".tar.gz.enc", ".tar.gz" + splitManifestSuffix, ".tar.gz.enc" + splitManifestSuffix} {
+		candidates = append(candidates, filepath.Join(iops.config.BackupDir, ref+suffix))
+	}
+
+	for _, candidate := range candidates {
+		stat, err := os.Stat(candidate)
+		if err != nil || stat.IsDir() {
+			continue
+		}
+		absPath, err := filepath.Abs(candidate)
+		if err != nil {
+			absPath = candidate
+		}
+		return &CatalogEntry{
+			BackupID:  backupIDFromFilename(candidate),
+			Filename:  filepath.Base(candidate),
+			LocalPath: absPath,
+			CreatedAt: stat.ModTime().UTC().Format(time.RFC3339),
+			SizeBytes: stat.Size(),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("backup not found in catalog or %s: %s", iops.config.BackupDir, ref)
+}
+
+// setS3ObjectHold applies or removes the hold on an archive stored in S3.
+func (iops *InfrahubOps) setS3ObjectHold(s3URI string, held bool) error {
+	bucket, key, ok := ParseS3URI(s3URI)
+	if !ok {
+		return fmt.Errorf("invalid S3 URI: %s", s3URI)
+	}
+
+	client, err := iops.s3Client(S3Config{
+		Bucket:      bucket,
+		Endpoint:    iops.config.S3.Endpoint,
+		Region:      iops.config.S3.Region,
+		Credentials: iops.config.S3.Credentials,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create S3 client: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	return client.SetHold(ctx, key, held)
+}
@@ -0,0 +1,150 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// impactWebhookTimeout bounds the impact notification so an unreachable
+// endpoint cannot hold up the backup.
+const impactWebhookTimeout = 10 * time.Second
+
+// InterruptedWork is a flow run or proposed change that stopping the Infrahub
+// services would interrupt.
+type InterruptedWork struct {
+	Name      string    `json:"name"`
+	State     string    `json:"state"`
+	Owner     string    `json:"owner"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BackupImpact lists the work a Community Edition backup interrupts.
+type BackupImpact struct {
+	FlowRuns        []InterruptedWork `json:"flow_runs"`
+	ProposedChanges []InterruptedWork `json:"proposed_changes"`
+}
+
+// Empty reports whether nothing would be interrupted.
+func (b *BackupImpact) Empty() bool {
+	return len(b.FlowRuns) == 0 && len(b.ProposedChanges) == 0
+}
+
+// estimateBackupImpact lists the running and pending Prefect flow runs and
+// the open proposed changes from the task worker.
+func (iops *InfrahubOps) estimateBackupImpact() (*BackupImpact, error) {
+	scriptContent, err := readEmbeddedScript("get_backup_impact.py")
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve get_backup_impact.py: %w", err)
+	}
+
+	limit := defaultPrefectPaginationSize
+	if discovered, ok := iops.discoverPrefectPaginationLimit(); ok && discovered < limit {
+		limit = discovered
+	}
+	execOpts := iops.buildTaskWorkerExecOpts(&ExecOptions{
+		Env: map[string]string{"INFRAHUB_PAGINATION_SIZE": strconv.Itoa(limit)},
+	})
+	scriptPath := "/tmp/infrahubops_get_backup_impact.py"
+	output, err := iops.executeScriptWithOpts("task-worker", string(scriptContent), scriptPath, execOpts, "python", "-u", scriptPath, strconv.Itoa(limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list running work: %w", err)
+	}
+	return parseBackupImpact(output)
+}
+
+// parseBackupImpact decodes the script output. Prefect may log before the
+// result, so only the last line is parsed.
+func parseBackupImpact(output string) (*BackupImpact, error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	last := strings.TrimSpace(lines[len(lines)-1])
+	impact := &BackupImpact{}
+	if err := json.Unmarshal([]byte(last), impact); err != nil {
+		return nil, fmt.Errorf("could not parse json: %w\n%v", err, output)
+	}
+	return impact, nil
+}
+
+// writeBackupImpact prints the interrupted work with its owner and age.
+func writeBackupImpact(w io.Writer, impact *BackupImpact, now time.Time) {
+	section := func(title string, items []InterruptedWork) {
+		if len(items) == 0 {
+			return
+		}
+		fmt.Fprintf(w, "%s (%d):\n", title, len(items))
+		fmt.Fprintf(w, "  %-40s  %-8s  %-24s  %s\n", "NAME", "STATE", "OWNER", "AGE")
+		for _, item := range items {
+			owner, age := item.Owner, "unknown"
+			if owner == "" {
+				owner = "-"
+			}
+			if !item.CreatedAt.IsZero() {
+				age = now.Sub(item.CreatedAt).Round(time.Second).String()
+			}
+			fmt.Fprintf(w, "  %-40s  %-8s  %-24s  %s\n", item.Name, strings.ToLower(item.State), owner, age)
+		}
+	}
+	section("Flow runs that will be interrupted", impact.FlowRuns)
+	section("Open proposed changes whose pipelines will be interrupted", impact.ProposedChanges)
+}
+
+// reportBackupImpact prints what stopping the services for a Community
+// Edition backup interrupts and posts it to the configured webhook. Failures
+// are logged; they never stop the backup.
+func (iops *InfrahubOps) reportBackupImpact() {
+	impact, err := iops.estimateBackupImpact()
+	if err != nil {
+		logrus.Warnf("Could not determine which work the backup interrupts: %v", err)
+		return
+	}
+	if impact.Empty() {
+		logrus.Info("No running flow runs or open proposed changes will be interrupted")
+	} else {
+		writeBackupImpact(os.Stderr, impact, time.Now())
+	}
+
+	if iops.config.ImpactWebhook == "" {
+		return
+	}
+	target := ""
+	if backend, err := iops.ensureBackend(); err == nil {
+		target = backend.Info()
+	}
+	client, err := iops.config.HTTP.Client(impactWebhookTimeout)
+	if err == nil {
+		err = postBackupImpact(client, iops.config.ImpactWebhook, target, impact)
+	}
+	if err != nil {
+		logrus.Warnf("Failed to send backup impact notification: %v", err)
+	}
+}
+
+// postBackupImpact sends impact as JSON to url.
+func postBackupImpact(client *http.Client, url, target string, impact *BackupImpact) error {
+	payload, err := json.Marshal(struct {
+		Event  string `json:"event"`
+		Target string `json:"target"`
+		*BackupImpact
+	}{Event: "backup.services_stopping", Target: target, BackupImpact: impact})
+	if err != nil {
+		return fmt.Errorf("failed to marshal backup impact: %w", err)
+	}
+
+	resp, err := client.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
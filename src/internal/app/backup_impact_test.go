@@ -0,0 +1,88 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const impactOutput = `12:00:01.123 | INFO | prefect - connecting
+{"flow_runs": [{"name": "fancy-lion", "state": "RUNNING", "owner": "admin", "created_at": "2025-01-01T11:30:00+00:00"}], "proposed_changes": [{"name": "add-site", "state": "open", "owner": "", "created_at": null}]}`
+
+func TestParseBackupImpact(t *testing.T) {
+	impact, err := parseBackupImpact(impactOutput)
+	if err != nil {
+		t.Fatalf("parseBackupImpact() error = %v", err)
+	}
+	if len(impact.FlowRuns) != 1 || impact.FlowRuns[0].Owner != "admin" || impact.FlowRuns[0].CreatedAt.IsZero() {
+		t.Errorf("FlowRuns = %+v", impact.FlowRuns)
+	}
+	if len(impact.ProposedChanges) != 1 || !impact.ProposedChanges[0].CreatedAt.IsZero() {
+		t.Errorf("ProposedChanges = %+v", impact.ProposedChanges)
+	}
+
+	if _, err := parseBackupImpact("Traceback (most recent call last):"); err == nil {
+		t.Error("parseBackupImpact() error = nil for non-JSON output")
+	}
+}
+
+func TestWriteBackupImpact(t *testing.T) {
+	impact, err := parseBackupImpact(impactOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	writeBackupImpact(&buf, impact, time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	out := buf.String()
+
+	for _, want := range []string{
+		"Flow runs that will be interrupted (1):",
+		"fancy-lion",
+		"admin",
+		"30m0s",
+		"Open proposed changes whose pipelines will be interrupted (1):",
+		"unknown",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	buf.Reset()
+	writeBackupImpact(&buf, &BackupImpact{}, time.Now())
+	if buf.Len() != 0 {
+		t.Errorf("empty impact printed %q", buf.String())
+	}
+}
+
+func TestPostBackupImpact(t *testing.T) {
+	var received map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	impact := &BackupImpact{FlowRuns: []InterruptedWork{{Name: "fancy-lion", State: "RUNNING"}}}
+	if err := postBackupImpact(server.Client(), server.URL, "infrahub", impact); err != nil {
+		t.Fatalf("postBackupImpact() error = %v", err)
+	}
+	if received["event"] != "backup.services_stopping" || received["target"] != "infrahub" {
+		t.Errorf("payload = %v", received)
+	}
+	if runs, ok := received["flow_runs"].([]any); !ok || len(runs) != 1 {
+		t.Errorf("payload flow_runs = %v", received["flow_runs"])
+	}
+
+	if err := postBackupImpact(server.Client(), server.URL+"/fail", "infrahub", impact); err == nil {
+		t.Error("postBackupImpact() error = nil for a 500 response")
+	}
+}
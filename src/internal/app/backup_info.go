@@ -0,0 +1,232 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// errArchiveNeedsDownload is returned when the metadata of a remote archive
+// cannot be read in place, because it is encrypted or split.
+var errArchiveNeedsDownload = errors.New("archive must be downloaded to be read")
+
+// archiveObject is an archive that can be read both at offsets and as a
+// stream: a local file, or an S3 object.
+type archiveObject interface {
+	io.ReaderAt
+	io.ReadSeeker
+}
+
+// meteredObject counts the bytes read from an archive object.
+type meteredObject struct {
+	archiveObject
+	read int64
+}
+
+func (m *meteredObject) Read(p []byte) (int, error) {
+	n, err := m.archiveObject.Read(p)
+	m.read += int64(n)
+	return n, err
+}
+
+func (m *meteredObject) ReadAt(p []byte, off int64) (int, error) {
+	n, err := m.archiveObject.ReadAt(p, off)
+	m.read += int64(n)
+	return n, err
+}
+
+// BackupInfo returns the metadata of a backup archive without restoring it.
+func (iops *InfrahubOps) BackupInfo(backupFile, decryptKey string) (*BackupMetadata, error) {
+	metadata, _, err := iops.BackupContents(backupFile, decryptKey)
+	return metadata, err
+}
+
+// BackupContents returns the metadata of a backup archive and, for format 2
+// archives, its index. Archives on S3 are read in place with ranged reads
+// when they are neither encrypted nor split; others are downloaded first.
+func (iops *InfrahubOps) BackupContents(backupFile, decryptKey string) (*BackupMetadata, *ArchiveIndex, error) {
+	if IsS3URI(backupFile) && decryptKey == "" {
+		metadata, index, err := iops.remoteBackupContents(backupFile)
+		if !errors.Is(err, errArchiveNeedsDownload) {
+			return metadata, index, err
+		}
+		logrus.Infof("%s is encrypted or split; downloading it", backupFile)
+	}
+
+	archive, cleanup, err := iops.prepareSharedRestoreArchive(backupFile, decryptKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cleanup()
+
+	file, err := os.Open(archive)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	return archiveContents(file, info.Size())
+}
+
+// remoteBackupContents reads the metadata of an S3 archive without
+// downloading it: through the index of a format 2 archive, or by streaming
+// the archive only until the metadata file.
+func (iops *InfrahubOps) remoteBackupContents(s3URI string) (*BackupMetadata, *ArchiveIndex, error) {
+	if strings.HasSuffix(s3URI, splitManifestSuffix) {
+		return nil, nil, errArchiveNeedsDownload
+	}
+	client, key, err := iops.s3ClientForURI(s3URI)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	obj, size, err := client.Open(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer obj.Close()
+
+	metered := &meteredObject{archiveObject: obj}
+	metadata, index, err := archiveContents(metered, size)
+	if err == nil {
+		logrus.Infof("Read %s of %s from %s", formatBytes(metered.read), formatBytes(size), s3URI)
+	}
+	return metadata, index, err
+}
+
+// archiveContents reads the metadata, and the index when there is one, of a
+// compressed archive of the given size.
+func archiveContents(obj archiveObject, size int64) (*BackupMetadata, *ArchiveIndex, error) {
+	magic := make([]byte, 8)
+	n, err := obj.ReadAt(magic, 0)
+	if err != nil && err != io.EOF {
+		return nil, nil, fmt.Errorf("failed to read archive header: %w", err)
+	}
+	if matchArchiveFilter(magic[:n]) != nil {
+		return nil, nil, errArchiveNeedsDownload
+	}
+
+	member := "backup/" + backupMetadataFilename
+	var data []byte
+	index, err := readArchiveIndex(obj, size)
+	switch {
+	case err == nil:
+		data, err = readIndexedMember(obj, index, member)
+	case errors.Is(err, errNoArchiveIndex):
+		index = nil
+		if _, err = obj.Seek(0, io.SeekStart); err == nil {
+			data, err = scanArchiveMember(obj, member)
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	metadata, err := parseBackupMetadata(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return metadata, index, nil
+}
+
+// WriteBackupInfo prints a summary of the backup metadata and the branches
+// that existed when it was taken.
+func WriteBackupInfo(w io.Writer, metadata *BackupMetadata) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Backup ID:\t%s\n", metadata.BackupID)
+	fmt.Fprintf(tw, "Created:\t%s\n", metadata.CreatedAt)
+	fmt.Fprintf(tw, "Infrahub version:\t%s\n", metadata.InfrahubVersion)
+	fmt.Fprintf(tw, "Tool version:\t%s\n", metadata.ToolVersion)
+	if metadata.Neo4jEdition != "" || metadata.Neo4jVersion != "" {
+		fmt.Fprintf(tw, "Neo4j:\t%s\n", strings.TrimSpace(metadata.Neo4jEdition+" "+metadata.Neo4jVersion))
+	}
+	if metadata.PostgresVersion != "" {
+		fmt.Fprintf(tw, "PostgreSQL:\t%s\n", metadata.PostgresVersion)
+	}
+	if metadata.PrefectSchemaRevision != "" {
+		fmt.Fprintf(tw, "Prefect schema:\t%s\n", metadata.PrefectSchemaRevision)
+	}
+	fmt.Fprintf(tw, "Components:\t%s\n", strings.Join(metadata.Components, ", "))
+	if source := metadata.Source; source != nil && source.Backend != "" {
+		target := source.Project
+		if source.Backend == "kubernetes" {
+			target = source.Namespace
+		}
+		fmt.Fprintf(tw, "Source:\t%s (%s) on %s\n", source.Backend, target, source.Host)
+	}
+	if source := metadata.Source; source != nil && source.Git != nil {
+		fmt.Fprintf(tw, "Git:\t%s\n", source.Git)
+	}
+	fmt.Fprintf(tw, "Encrypted:\t%t\n", metadata.Encrypted)
+	if len(metadata.Branches) == 0 {
+		fmt.Fprintln(tw, "Branches:\tnot recorded")
+		return tw.Flush()
+	}
+
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "BRANCH\tSTATUS\tSYNC WITH GIT\tBRANCHED FROM")
+	for _, branch := range metadata.Branches {
+		name := branch.Name
+		if branch.IsDefault {
+			name += " (default)"
+		}
+		status := branch.Status
+		if status == "" {
+			status = "-"
+		}
+		branchedFrom := branch.BranchedFrom
+		if branchedFrom == "" {
+			branchedFrom = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%t\t%s\n", name, status, branch.SyncWithGit, branchedFrom)
+	}
+	return tw.Flush()
+}
+
+// WriteArchiveContents prints the files of each backup component listed in
+// an archive index, with their size before and after compression.
+func WriteArchiveContents(w io.Writer, index *ArchiveIndex) error {
+	type contents struct {
+		files            int
+		size, compressed int64
+	}
+	components := map[string]*contents{}
+	for _, entry := range index.Entries {
+		relPath := strings.TrimPrefix(entry.Name, "backup/")
+		if entry.Type != "file" || relPath == archiveIndexFilename {
+			continue
+		}
+		name := backupComponent(relPath)
+		if components[name] == nil {
+			components[name] = &contents{}
+		}
+		components[name].files++
+		components[name].size += entry.Size
+		components[name].compressed += entry.Length
+	}
+	names := make([]string, 0, len(components))
+	for name := range components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "COMPONENT\tFILES\tSIZE\tCOMPRESSED")
+	for _, name := range names {
+		c := components[name]
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", name, c.files, formatBytes(c.size), formatBytes(c.compressed))
+	}
+	return tw.Flush()
+}
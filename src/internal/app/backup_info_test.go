@@ -0,0 +1,145 @@
+package app
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBackupInfo(t *testing.T) {
+	iops := NewInfrahubOps()
+	workDir := t.TempDir()
+	metadata := iops.createBackupMetadata("info", true, "1.5.0", neo4jEditionCommunity)
+	metadata.Neo4jVersion = "5.26.1"
+	metadata.Source = &BackupSource{Backend: "docker", Project: "infrahub", Host: "ops-1"}
+	metadata.Branches = []BranchInfo{
+		{Name: "main", Status: "OPEN", SyncWithGit: true, IsDefault: true},
+		{Name: "feature", Status: "NEED_REBASE", BranchedFrom: "2025-09-01T10:00:00Z"},
+	}
+	data, err := marshalBackupMetadata(metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, workDir, "backup/"+backupMetadataFilename, string(data))
+	archive, err := defaultArchivePipeline(false, false).Write(workDir, "backup/", filepath.Join(t.TempDir(), "info"), ArchiveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := iops.BackupInfo(archive, "")
+	if err != nil {
+		t.Fatalf("BackupInfo() error = %v", err)
+	}
+	var out bytes.Buffer
+	if err := WriteBackupInfo(&out, got); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Backup ID:         info",
+		"Neo4j:             community 5.26.1",
+		"Components:        database, task-manager-db",
+		"Source:            docker (infrahub) on ops-1",
+		"main (default)  OPEN         true           -",
+		"feature         NEED_REBASE  false          2025-09-01T10:00:00Z",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output misses %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestWriteBackupInfoWithoutBranches(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteBackupInfo(&out, &BackupMetadata{BackupID: "old", Components: []string{"database"}}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Branches:          not recorded") {
+		t.Errorf("output:\n%s", out.String())
+	}
+}
+
+func TestArchiveContents(t *testing.T) {
+	workDir := t.TempDir()
+	data, err := marshalBackupMetadata(NewInfrahubOps().createBackupMetadata("contents", true, "1.5.0", neo4jEditionCommunity))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, workDir, "backup/"+backupMetadataFilename, string(data))
+	dump := make([]byte, 1<<20)
+	if _, err := rand.Read(dump); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, workDir, "backup/database/neo4j.dump", string(dump))
+	pubPath, _ := writeArchiveKeys(t, t.TempDir())
+
+	tests := []struct {
+		name      string
+		format    int
+		encrypt   bool
+		wantIndex bool
+		wantErr   error
+	}{
+		{name: "format 2", format: ArchiveFormatV2, wantIndex: true},
+		{name: "format 1", format: ArchiveFormatV1},
+		{name: "encrypted", format: ArchiveFormatV2, encrypt: true, wantErr: errArchiveNeedsDownload},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline := defaultArchivePipeline(tt.encrypt, false)
+			pipeline.Format = tt.format
+			archive, err := pipeline.Write(workDir, "backup/", filepath.Join(t.TempDir(), "contents"), ArchiveOptions{EncryptKey: pubPath})
+			if err != nil {
+				t.Fatal(err)
+			}
+			file, err := os.Open(archive)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			info, _ := file.Stat()
+
+			obj := &meteredObject{archiveObject: file}
+			metadata, index, err := archiveContents(obj, info.Size())
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("archiveContents() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || metadata.BackupID != "contents" {
+				t.Fatalf("archiveContents() = %+v, %v", metadata, err)
+			}
+			if (index != nil) != tt.wantIndex {
+				t.Errorf("archiveContents() index = %v, want index %t", index, tt.wantIndex)
+			}
+			if obj.read > info.Size()/10 {
+				t.Errorf("read %d of %d bytes", obj.read, info.Size())
+			}
+		})
+	}
+}
+
+func TestWriteArchiveContents(t *testing.T) {
+	index := &ArchiveIndex{FormatVersion: ArchiveFormatV2, Entries: []ArchiveIndexEntry{
+		{Name: "backup", Type: "dir"},
+		{Name: "backup/" + backupMetadataFilename, Type: "file", Size: 900, Length: 400},
+		{Name: "backup/database", Type: "dir"},
+		{Name: "backup/database/neo4j.dump", Type: "file", Size: 3 << 20, Length: 1 << 20},
+		{Name: "backup/database/neo4j.backup", Type: "file", Size: 1 << 20, Length: 1 << 20},
+		{Name: "backup/" + archiveIndexFilename, Type: "file", Size: 300, Length: 200},
+	}}
+	var out bytes.Buffer
+	if err := WriteArchiveContents(&out, index); err != nil {
+		t.Fatal(err)
+	}
+	want := "COMPONENT  FILES  SIZE    COMPRESSED\n" +
+		"database   2      4.0 MB  2.0 MB\n" +
+		"metadata   1      900 B   400 B\n"
+	if out.String() != want {
+		t.Errorf("output:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
@@ -0,0 +1,108 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// messageQueueService runs RabbitMQ. It is also the component name
+	// recorded in backup metadata when its definitions are saved.
+	messageQueueService = "message-queue"
+
+	// messageQueueDefinitionsFilename holds the output of rabbitmqctl
+	// export_definitions in the backup.
+	messageQueueDefinitionsFilename = "rabbitmq_definitions.json"
+)
+
+// exportMessageQueueDefinitions returns the RabbitMQ users, vhosts, queues,
+// exchanges and policies as exported by rabbitmqctl.
+func (iops *InfrahubOps) exportMessageQueueDefinitions() ([]byte, error) {
+	remotePath := iops.getWritableTempDir(messageQueueService) + "/infrahubops_definitions.json"
+	if output, err := iops.Exec(messageQueueService, []string{"rabbitmqctl", "-q", "export_definitions", remotePath}, nil); err != nil {
+		return nil, fmt.Errorf("failed to export rabbitmq definitions: %w\nOutput: %v", err, output)
+	}
+	defer func() {
+		if _, err := iops.Exec(messageQueueService, []string{"rm", "-f", remotePath}, nil); err != nil {
+			logrus.Warnf("Failed to remove temporary rabbitmq definitions: %v", err)
+		}
+	}()
+
+	output, err := iops.Exec(messageQueueService, []string{"cat", remotePath}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rabbitmq definitions: %w", err)
+	}
+	if !json.Valid([]byte(output)) {
+		return nil, fmt.Errorf("rabbitmq definitions are not valid JSON")
+	}
+	return []byte(output), nil
+}
+
+// importMessageQueueDefinitions loads definitions exported earlier into
+// RabbitMQ once it has started. Existing definitions are updated, not removed.
+func (iops *InfrahubOps) importMessageQueueDefinitions(definitions []byte, tempDir string) error {
+	localPath := filepath.Join(tempDir, "infrahubops_definitions.json")
+	if err := os.WriteFile(localPath, definitions, 0600); err != nil {
+		return err
+	}
+	defer os.Remove(localPath)
+
+	remotePath := iops.getWritableTempDir(messageQueueService) + "/infrahubops_definitions.json"
+	if err := iops.CopyTo(messageQueueService, localPath, remotePath); err != nil {
+		return fmt.Errorf("failed to copy rabbitmq definitions: %w", err)
+	}
+	defer func() {
+		if _, err := iops.Exec(messageQueueService, []string{"rm", "-f", remotePath}, nil); err != nil {
+			logrus.Warnf("Failed to remove temporary rabbitmq definitions: %v", err)
+		}
+	}()
+
+	if output, err := iops.Exec(messageQueueService, []string{"rabbitmqctl", "await_startup"}, nil); err != nil {
+		return fmt.Errorf("rabbitmq did not start: %w\nOutput: %v", err, output)
+	}
+	if output, err := iops.Exec(messageQueueService, []string{"rabbitmqctl", "-q", "import_definitions", remotePath}, nil); err != nil {
+		return fmt.Errorf("failed to import rabbitmq definitions: %w\nOutput: %v", err, output)
+	}
+	return nil
+}
+
+// messageQueueDefinitionsForRestore picks the definitions to load once the
+// wiped message queue is back: those saved in the backup, or otherwise the
+// target's own, exported before the wipe. It returns nil when there are none
+// or with --skip-mq-definitions.
+func (iops *InfrahubOps) messageQueueDefinitionsForRestore(backupDir string) []byte {
+	if iops.config.SkipMQDefinitions {
+		logrus.Info("Skipping RabbitMQ definitions as requested")
+		return nil
+	}
+	if definitions, err := os.ReadFile(filepath.Join(backupDir, messageQueueDefinitionsFilename)); err == nil {
+		logrus.Info("RabbitMQ definitions found in the backup; they will be imported after the message queue is wiped")
+		return definitions
+	}
+	definitions, err := iops.exportMessageQueueDefinitions()
+	if err != nil {
+		logrus.Warnf("Backup has no RabbitMQ definitions and those of the target could not be saved before wiping the message queue: %v", err)
+		return nil
+	}
+	logrus.Info("Saved the target's RabbitMQ definitions; they will be imported after the message queue is wiped")
+	return definitions
+}
+
+// restoreMessageQueueDefinitions imports definitions picked by
+// messageQueueDefinitionsForRestore. A failure is logged, not returned: the
+// data is restored and Infrahub recreates its own queues on startup.
+func (iops *InfrahubOps) restoreMessageQueueDefinitions(definitions []byte, tempDir string) {
+	if len(definitions) == 0 {
+		return
+	}
+	logrus.Info("Importing RabbitMQ definitions...")
+	if err := iops.importMessageQueueDefinitions(definitions, tempDir); err != nil {
+		logrus.Warnf("Custom RabbitMQ users, queues and policies may be missing: %v", err)
+		return
+	}
+	logrus.Info("RabbitMQ definitions imported")
+}
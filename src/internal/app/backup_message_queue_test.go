@@ -0,0 +1,105 @@
+package app
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExportMessageQueueDefinitions(t *testing.T) {
+	tests := []struct {
+		name      string
+		exportErr error
+		content   string
+		wantErr   bool
+	}{
+		{name: "exported", content: `{"rabbit_version":"3.13.7","users":[],"policies":[]}`},
+		{name: "export fails", exportErr: errors.New("exit status 69"), wantErr: true},
+		{name: "not json", content: "Error: unable to perform an operation on node", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeExecutor().
+				on("export_definitions", "", tt.exportErr).
+				on("cat /tmp/infrahubops_definitions.json", tt.content, nil)
+			iops := newFakeDockerOps(fake)
+
+			definitions, err := iops.exportMessageQueueDefinitions()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("exportMessageQueueDefinitions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(definitions) != tt.content {
+				t.Errorf("definitions = %s, want %s", definitions, tt.content)
+			}
+			if tt.exportErr == nil {
+				if got := fake.commands("rm -f /tmp/infrahubops_definitions.json"); len(got) != 1 {
+					t.Errorf("cleanup commands = %v, want one", got)
+				}
+			}
+		})
+	}
+}
+
+func TestMessageQueueDefinitionsForRestore(t *testing.T) {
+	tests := []struct {
+		name       string
+		inBackup   string
+		target     string
+		skip       bool
+		want       string
+		wantExport bool
+	}{
+		{name: "from the backup", inBackup: `{"users":["backup"]}`, target: `{"users":["target"]}`, want: `{"users":["backup"]}`},
+		{name: "from the target", target: `{"users":["target"]}`, want: `{"users":["target"]}`, wantExport: true},
+		{name: "skipped", inBackup: `{"users":["backup"]}`, skip: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backupDir := t.TempDir()
+			if tt.inBackup != "" {
+				if err := os.WriteFile(filepath.Join(backupDir, messageQueueDefinitionsFilename), []byte(tt.inBackup), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			fake := newFakeExecutor().on("cat /tmp/infrahubops_definitions.json", tt.target, nil)
+			iops := newFakeDockerOps(fake)
+			iops.config.SkipMQDefinitions = tt.skip
+
+			if got := iops.messageQueueDefinitionsForRestore(backupDir); string(got) != tt.want {
+				t.Errorf("messageQueueDefinitionsForRestore() = %s, want %s", got, tt.want)
+			}
+			if got := fake.commands("export_definitions"); (len(got) > 0) != tt.wantExport {
+				t.Errorf("export commands = %v, want export %v", got, tt.wantExport)
+			}
+		})
+	}
+}
+
+func TestImportMessageQueueDefinitions(t *testing.T) {
+	fake := newFakeExecutor()
+	iops := newFakeDockerOps(fake)
+
+	if err := iops.importMessageQueueDefinitions([]byte(`{"users":[]}`), t.TempDir()); err != nil {
+		t.Fatalf("importMessageQueueDefinitions() error = %v", err)
+	}
+	for _, command := range []string{
+		"cp -a",
+		"rabbitmqctl await_startup",
+		"rabbitmqctl -q import_definitions /tmp/infrahubops_definitions.json",
+		"rm -f /tmp/infrahubops_definitions.json",
+	} {
+		if got := fake.commands(command); len(got) != 1 {
+			t.Errorf("%q commands = %v, want one", command, got)
+		}
+	}
+
+	fake = newFakeExecutor().on("await_startup", "", errors.New("timeout"))
+	iops = newFakeDockerOps(fake)
+	if err := iops.importMessageQueueDefinitions([]byte(`{}`), t.TempDir()); err == nil {
+		t.Error("importMessageQueueDefinitions() error = nil when rabbitmq did not start")
+	}
+	if got := fake.commands("import_definitions"); len(got) != 0 {
+		t.Errorf("imported before rabbitmq started: %v", got)
+	}
+}
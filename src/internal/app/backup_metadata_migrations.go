@@ -0,0 +1,148 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/sirupsen/logrus"
+)
+
+// metadataMigration upgrades a decoded backup_information.json document to
+// the version in To. Migrations run in ascending order for every step newer
+// than the document's version.
+type metadataMigration struct {
+	To          int
+	Description string
+	Apply       func(doc map[string]any) error
+}
+
+// metadataMigrations lists every metadata version change. Append new entries
+// when bumping metadataVersion.
+var metadataMigrations = []metadataMigration{
+	{
+		To:          2025111200,
+		Description: "declare task manager dump in components",
+		Apply:       migrateTaskManagerComponent,
+	},
+	{
+		To:          2026101600,
+		Description: "add optional source identity",
+		Apply:       func(map[string]any) error { return nil },
+	},
+	{
+		To:          2026101601,
+		Description: "record the checksum algorithm per file",
+		Apply:       func(map[string]any) error { return nil },
+	},
+	{
+		To:          2026101602,
+		Description: "add optional git checkout to the source",
+		Apply:       func(map[string]any) error { return nil },
+	},
+}
+
+// migrateTaskManagerComponent covers archives written before the task manager
+// database was listed in components; only its checksum revealed the dump.
+func migrateTaskManagerComponent(doc map[string]any) error {
+	checksums, _ := doc["checksums"].(map[string]any)
+	if _, ok := checksums[prefectDumpFilename]; !ok {
+		return nil
+	}
+
+	components, _ := doc["components"].([]any)
+	for _, component := range components {
+		if component == "task-manager-db" || component == ComponentPostgres {
+			return nil
+		}
+	}
+	doc["components"] = append(components, "task-manager-db")
+	return nil
+}
+
+// metadataDocumentVersion reads metadata_version, treating archives written
+// before the field existed as version 0.
+func metadataDocumentVersion(doc map[string]any) (int, error) {
+	raw, ok := doc["metadata_version"]
+	if !ok || raw == nil {
+		return 0, nil
+	}
+	num, ok := raw.(float64)
+	if !ok || num != float64(int(num)) {
+		return 0, fmt.Errorf("invalid metadata_version: %v", raw)
+	}
+	return int(num), nil
+}
+
+// migrateMetadataDocument upgrades doc in place to metadataVersion.
+func migrateMetadataDocument(doc map[string]any) error {
+	version, err := metadataDocumentVersion(doc)
+	if err != nil {
+		return err
+	}
+	if version > metadataVersion {
+		return fmt.Errorf("backup metadata version %d is newer than supported version %d; upgrade infrahub-backup", version, metadataVersion)
+	}
+
+	for _, migration := range metadataMigrations {
+		if migration.To <= version {
+			continue
+		}
+		logrus.Debugf("Migrating backup metadata from version %d to %d: %s", version, migration.To, migration.Description)
+		if err := migration.Apply(doc); err != nil {
+			return fmt.Errorf("failed to migrate backup metadata to version %d: %w", migration.To, err)
+		}
+		version = migration.To
+		doc["metadata_version"] = float64(version)
+	}
+	return nil
+}
+
+// parseBackupMetadata decodes backup_information.json, migrates it to the
+// current version, and validates it against the schema.
+func parseBackupMetadata(data []byte) (*BackupMetadata, error) {
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse metadata: %w", err)
+	}
+
+	if err := migrateMetadataDocument(doc); err != nil {
+		return nil, err
+	}
+	if err := validateMetadataDocument(doc); err != nil {
+		return nil, err
+	}
+
+	migrated, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode migrated metadata: %w", err)
+	}
+	var metadata BackupMetadata
+	if err := json.Unmarshal(migrated, &metadata); err != nil {
+		return nil, fmt.Errorf("failed to parse metadata: %w", err)
+	}
+	return &metadata, nil
+}
+
+// marshalBackupMetadata validates metadata against the schema and encodes it
+// for backup_information.json.
+func marshalBackupMetadata(metadata *BackupMetadata) ([]byte, error) {
+	data, err := json.MarshalIndent(metadata, "", "    ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	if err := validateMetadataDocument(doc); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// hasComponent reports whether the metadata lists component.
+func (m *BackupMetadata) hasComponent(component string) bool {
+	return slices.Contains(m.Components, component)
+}
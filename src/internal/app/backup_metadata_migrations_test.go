@@ -0,0 +1,161 @@
+package app
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const validChecksum = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+func TestParseBackupMetadataMigrations(t *testing.T) {
+	tests := []struct {
+		name           string
+		doc            string
+		wantComponents []string
+	}{
+		{
+			name: "pre-versioned archive with prefect checksum",
+			doc: `{"backup_id":"infrahub_backup_20250101_000000","created_at":"2025-01-01T00:00:00Z",
+				"tool_version":"abc","infrahub_version":"1.1.0","components":["database"],
+				"checksums":{"prefect.dump":"` + validChecksum + `"}}`,
+			wantComponents: []string{"database", "task-manager-db"},
+		},
+		{
+			name: "2025111200 archive without task manager",
+			doc: `{"metadata_version":2025111200,"backup_id":"b","created_at":"2025-11-12T00:00:00Z",
+				"tool_version":"abc","infrahub_version":"1.4.0","components":["database"],"neo4j_edition":"community"}`,
+			wantComponents: []string{"database"},
+		},
+		{
+			name: "plakar components are kept",
+			doc: `{"metadata_version":2025111200,"backup_id":"b","created_at":"2025-11-12T00:00:00Z",
+				"tool_version":"abc","infrahub_version":"1.4.0","components":["neo4j","postgres","metadata"],
+				"checksums":{"prefect.dump":"` + validChecksum + `"}}`,
+			wantComponents: []string{"neo4j", "postgres", "metadata"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metadata, err := parseBackupMetadata([]byte(tt.doc))
+			if err != nil {
+				t.Fatalf("parseBackupMetadata: %v", err)
+			}
+			if metadata.MetadataVersion != metadataVersion {
+				t.Errorf("MetadataVersion = %d, want %d", metadata.MetadataVersion, metadataVersion)
+			}
+			if !slices.Equal(metadata.Components, tt.wantComponents) {
+				t.Errorf("Components = %v, want %v", metadata.Components, tt.wantComponents)
+			}
+		})
+	}
+}
+
+func TestParseBackupMetadataRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		wantErr string
+	}{
+		{
+			name:    "newer version",
+			doc:     `{"metadata_version":9999999999,"backup_id":"b","created_at":"2025-11-12T00:00:00Z","tool_version":"","infrahub_version":"","components":["database"]}`,
+			wantErr: "newer than supported",
+		},
+		{
+			name:    "missing backup id",
+			doc:     `{"metadata_version":2025111200,"created_at":"2025-11-12T00:00:00Z","tool_version":"","infrahub_version":"","components":["database"]}`,
+			wantErr: `missing required property "backup_id"`,
+		},
+		{
+			name:    "bad edition",
+			doc:     `{"metadata_version":2025111200,"backup_id":"b","created_at":"2025-11-12T00:00:00Z","tool_version":"","infrahub_version":"","components":["database"],"neo4j_edition":"desktop"}`,
+			wantErr: "$.neo4j_edition",
+		},
+		{
+			name:    "bad timestamp",
+			doc:     `{"metadata_version":2025111200,"backup_id":"b","created_at":"yesterday","tool_version":"","infrahub_version":"","components":["database"]}`,
+			wantErr: "$.created_at",
+		},
+		{
+			name:    "short checksum",
+			doc:     `{"metadata_version":2025111200,"backup_id":"b","created_at":"2025-11-12T00:00:00Z","tool_version":"","infrahub_version":"","components":["database"],"checksums":{"prefect.dump":"abc"}}`,
+			wantErr: "$.checksums.prefect.dump",
+		},
+		{
+			name:    "unknown checksum algorithm",
+			doc:     `{"metadata_version":2026101601,"backup_id":"b","created_at":"2025-11-12T00:00:00Z","tool_version":"","infrahub_version":"","components":["database"],"checksums":{"prefect.dump":"md5:d41d8cd98f00b204e9800998ecf8427e"}}`,
+			wantErr: "$.checksums.prefect.dump: does not match",
+		},
+		{
+			name:    "unknown source field",
+			doc:     `{"backup_id":"b","created_at":"2025-11-12T00:00:00Z","tool_version":"","infrahub_version":"","components":["database"],"source":{"hostname":"x"}}`,
+			wantErr: "$.source.hostname: unexpected property",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseBackupMetadata([]byte(tt.doc))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseBackupMetadata() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMarshalBackupMetadataRoundTrip(t *testing.T) {
+	iops := NewInfrahubOps()
+	metadata := iops.createBackupMetadata("infrahub_backup_20260101_000000", true, "1.5.0", "Enterprise")
+	metadata.Checksums = map[string]string{prefectDumpFilename: validChecksum, "database/neo4j.dump": "xxh64:ef46db3751d8e999"}
+
+	data, err := marshalBackupMetadata(metadata)
+	if err != nil {
+		t.Fatalf("marshalBackupMetadata: %v", err)
+	}
+	parsed, err := parseBackupMetadata(data)
+	if err != nil {
+		t.Fatalf("parseBackupMetadata: %v", err)
+	}
+	if parsed.BackupID != metadata.BackupID || parsed.Neo4jEdition != "enterprise" {
+		t.Errorf("round trip mismatch: %+v", parsed)
+	}
+
+	metadata.Source = &BackupSource{Backend: "docker", Git: &GitSource{Commit: "0123456789abcdef0123456789abcdef01234567", Branch: "main", Dirty: true}}
+	data, err = marshalBackupMetadata(metadata)
+	if err != nil {
+		t.Fatalf("marshalBackupMetadata with git source: %v", err)
+	}
+	if parsed, err = parseBackupMetadata(data); err != nil {
+		t.Fatalf("parseBackupMetadata with git source: %v", err)
+	}
+	if !reflect.DeepEqual(parsed.Source.Git, metadata.Source.Git) {
+		t.Errorf("git source = %+v, want %+v", parsed.Source.Git, metadata.Source.Git)
+	}
+
+	metadata.Components = nil
+	if _, err := marshalBackupMetadata(metadata); err == nil {
+		t.Error("expected schema violation for empty components")
+	}
+}
+
+func TestMetadataMigrationsOrdered(t *testing.T) {
+	last := 0
+	for _, migration := range metadataMigrations {
+		if migration.To <= last {
+			t.Fatalf("migration to %d is out of order", migration.To)
+		}
+		last = migration.To
+	}
+	if last != metadataVersion {
+		t.Errorf("last migration targets %d, metadataVersion is %d", last, metadataVersion)
+	}
+
+	var schema map[string]any
+	if err := json.Unmarshal(BackupMetadataSchema(), &schema); err != nil {
+		t.Fatalf("embedded schema is not valid JSON: %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package app
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backupMetadataSchemaJSON is the JSON Schema for backup_information.json. It
+// is published with the source so external tooling can validate archives too.
+//
+//go:embed schemas/backup_information.schema.json
+var backupMetadataSchemaJSON []byte
+
+// jsonSchema is the subset of JSON Schema keywords used by the metadata schema.
+type jsonSchema struct {
+	Type                 string                 `json:"type"`
+	Format               string                 `json:"format"`
+	Required             []string               `json:"required"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	AdditionalProperties json.RawMessage        `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+	Enum                 []any                  `json:"enum"`
+	Minimum              *float64               `json:"minimum"`
+	MinLength            *int                   `json:"minLength"`
+	Pattern              string                 `json:"pattern"`
+	MinItems             *int                   `json:"minItems"`
+}
+
+// BackupMetadataSchema returns the embedded JSON Schema document.
+func BackupMetadataSchema() []byte {
+	return backupMetadataSchemaJSON
+}
+
+func loadBackupMetadataSchema() (*jsonSchema, error) {
+	var schema jsonSchema
+	if err := json.Unmarshal(backupMetadataSchemaJSON, &schema); err != nil {
+		return nil, fmt.Errorf("failed to parse embedded metadata schema: %w", err)
+	}
+	return &schema, nil
+}
+
+// validateMetadataDocument validates a decoded backup_information.json document
+// against the embedded schema and returns all violations at once.
+func validateMetadataDocument(doc map[string]any) error {
+	schema, err := loadBackupMetadataSchema()
+	if err != nil {
+		return err
+	}
+
+	var violations []string
+	schema.validate("$", doc, &violations)
+	if len(violations) == 0 {
+		return nil
+	}
+	return fmt.Errorf("backup metadata does not match schema: %s", strings.Join(violations, "; "))
+}
+
+func (s *jsonSchema) validate(path string, value any, violations *[]string) {
+	fail := func(format string, args ...any) {
+		*violations = append(*violations, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	if value == nil {
+		fail("must not be null")
+		return
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			fail("expected object")
+			return
+		}
+		for _, name := range s.Required {
+			if _, present := obj[name]; !present {
+				fail("missing required property %q", name)
+			}
+		}
+		additional, err := s.additionalSchema()
+		if err != nil {
+			fail("%v", err)
+			return
+		}
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			child := path + "." + key
+			if prop, ok := s.Properties[key]; ok {
+				prop.validate(child, obj[key], violations)
+				continue
+			}
+			switch {
+			case additional != nil:
+				additional.validate(child, obj[key], violations)
+			case s.disallowsAdditional():
+				*violations = append(*violations, child+": unexpected property")
+			}
+		}
+	case "array":
+		arr, ok := value.([]any)
+		if !ok {
+			fail("expected array")
+			return
+		}
+		if s.MinItems != nil && len(arr) < *s.MinItems {
+			fail("expected at least %d items", *s.MinItems)
+		}
+		if s.Items != nil {
+			for i, item := range arr {
+				s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, violations)
+			}
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			fail("expected string")
+			return
+		}
+		if s.MinLength != nil && len(str) < *s.MinLength {
+			fail("expected at least %d characters", *s.MinLength)
+		}
+		if s.Pattern != "" {
+			if matched, err := regexp.MatchString(s.Pattern, str); err != nil {
+				fail("invalid pattern in schema: %v", err)
+			} else if !matched {
+				fail("does not match %s", s.Pattern)
+			}
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, str); err != nil {
+				fail("expected RFC 3339 date-time")
+			}
+		}
+	case "integer":
+		num, ok := value.(float64)
+		if !ok || num != float64(int64(num)) {
+			fail("expected integer")
+			return
+		}
+		if s.Minimum != nil && num < *s.Minimum {
+			fail("must be >= %v", *s.Minimum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("expected boolean")
+			return
+		}
+	}
+
+	if len(s.Enum) > 0 {
+		for _, allowed := range s.Enum {
+			if allowed == value {
+				return
+			}
+		}
+		fail("value %v is not one of %v", value, s.Enum)
+	}
+}
+
+// additionalSchema returns the schema for additionalProperties when it is an
+// object rather than a boolean.
+func (s *jsonSchema) additionalSchema() (*jsonSchema, error) {
+	raw := strings.TrimSpace(string(s.AdditionalProperties))
+	if raw == "" || raw == "true" || raw == "false" {
+		return nil, nil
+	}
+	var schema jsonSchema
+	if err := json.Unmarshal(s.AdditionalProperties, &schema); err != nil {
+		return nil, errors.New("invalid additionalProperties in schema")
+	}
+	return &schema, nil
+}
+
+func (s *jsonSchema) disallowsAdditional() bool {
+	return strings.TrimSpace(string(s.AdditionalProperties)) == "false"
+}
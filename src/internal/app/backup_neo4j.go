@@ -13,10 +13,10 @@ import (
 )
 
 const (
-	neo4jTempBackupDir       = "/tmp/infrahubops"
-	neo4jWatchdogInitTimeout = 5 * time.Second
-	neo4jProcessStopTimeout  = 120 * time.Second
-	neo4jMetadataScriptPath  = "/data/scripts/neo4j/restore_metadata.cypher"
+	neo4jTempBackupDir        = "/tmp/infrahubops"
+	neo4jFallbackMetadataPath = "/tmp/infrahubops_restore_metadata.cypher"
+	neo4jWatchdogInitTimeout  = 5 * time.Second
+	neo4jProcessStopTimeout   = 120 * time.Second
 )
 
 // backupNeo4jEnterpriseStream returns a data factory that streams a tar archive of the Neo4j
@@ -38,20 +38,14 @@ func (iops *InfrahubOps) backupNeo4jEnterpriseStream(backupMetadata string) (fun
 		}
 
 		// Run backup command separately so its stdout logs don't contaminate the data stream
-		if output, err := iops.Exec("database", []string{
-			"neo4j-admin", "database", "backup",
-			"--expand-commands",
-			"--include-metadata=" + backupMetadata,
-			"--compress=false",
-			"--to-path=" + neo4jTempBackupDir,
-			iops.config.Neo4jDatabase,
-		}, nil); err != nil {
+		backupCmd, backupOpts := iops.neo4jAdmin(iops.neo4jStreamBackupCommand(backupMetadata), nil)
+		if output, err := iops.Exec("database", iops.lowPriority("database", backupCmd), backupOpts); err != nil {
 			cleanupBackupDir()
 			return nil, fmt.Errorf("failed to backup neo4j: %w\nOutput: %v", err, output)
 		}
 
 		// Stream only the tar archive — no other command output in the pipe
-		stdout, wait, err := iops.ExecStreamPipe("database", []string{"tar", "cf", "-", "-C", "/tmp", "infrahubops"}, nil)
+		stdout, wait, err := iops.ExecStreamPipe("database", neo4jStreamTarCommand, nil)
 		if err != nil {
 			cleanupBackupDir()
 			return nil, fmt.Errorf("failed to start neo4j enterprise stream: %w", err)
@@ -86,11 +80,8 @@ func (iops *InfrahubOps) backupNeo4jCommunityStream() (func() (io.ReadCloser, er
 		}
 
 		// Stream the dump directly to stdout — no temp files needed
-		stdout, wait, err := iops.ExecStreamPipe("database", []string{
-			"neo4j-admin", "database", "dump",
-			"--to-stdout",
-			iops.config.Neo4jDatabase,
-		}, nil)
+		dumpCmd, dumpOpts := iops.neo4jAdmin(iops.neo4jStreamDumpCommand(), nil)
+		stdout, wait, err := iops.ExecStreamPipe("database", iops.lowPriority("database", dumpCmd), dumpOpts)
 		if err != nil {
 			restoreNeo4j(pidStr)
 			return nil, fmt.Errorf("failed to start neo4j community stream: %w", err)
@@ -203,6 +194,42 @@ func (iops *InfrahubOps) backupDatabase(backupDir string, backupMetadata string,
 	}
 }
 
+// neo4jBackupCommand is the online Enterprise backup into neo4jTempBackupDir.
+func (iops *InfrahubOps) neo4jBackupCommand(backupMetadata string) []string {
+	return []string{"neo4j-admin", "database", "backup", "--expand-commands", "--include-metadata=" + backupMetadata, "--to-path=" + neo4jTempBackupDir, iops.config.Neo4jDatabase}
+}
+
+// neo4jStreamBackupCommand is the online Enterprise backup read by
+// backupNeo4jEnterpriseStream, uncompressed for better deduplication.
+func (iops *InfrahubOps) neo4jStreamBackupCommand(backupMetadata string) []string {
+	return []string{"neo4j-admin", "database", "backup", "--expand-commands", "--include-metadata=" + backupMetadata, "--compress=false", "--to-path=" + neo4jTempBackupDir, iops.config.Neo4jDatabase}
+}
+
+// neo4jStreamTarCommand writes the Enterprise backup in neo4jTempBackupDir to
+// stdout as a tar archive.
+var neo4jStreamTarCommand = []string{"tar", "cf", "-", "-C", "/tmp", "infrahubops"}
+
+// neo4jStreamDumpCommand is the offline Community dump to stdout.
+func (iops *InfrahubOps) neo4jStreamDumpCommand() []string {
+	return []string{"neo4j-admin", "database", "dump", "--to-stdout", iops.config.Neo4jDatabase}
+}
+
+// neo4jDumpCommand is the offline Community dump into neo4jRemoteWorkDir.
+func (iops *InfrahubOps) neo4jDumpCommand() []string {
+	return []string{"neo4j-admin", "database", "dump", "--overwrite-destination=true", "--to-path=" + neo4jRemoteWorkDir, iops.config.Neo4jDatabase}
+}
+
+// neo4jRestoreCommand restores the Enterprise backup staged in
+// neo4jTempBackupDir.
+func (iops *InfrahubOps) neo4jRestoreCommand() []string {
+	return []string{"neo4j-admin", "database", "restore", "--expand-commands", "--overwrite-destination=true", "--from-path=" + neo4jTempBackupDir, iops.config.Neo4jDatabase}
+}
+
+// neo4jLoadCommand loads the Community dump staged in neo4jTempBackupDir.
+func (iops *InfrahubOps) neo4jLoadCommand() []string {
+	return []string{"neo4j-admin", "database", "load", "--overwrite-destination=true", "--from-path=" + neo4jTempBackupDir, iops.config.Neo4jDatabase}
+}
+
 func (iops *InfrahubOps) backupNeo4jEnterprise(backupDir string, backupMetadata string) error {
 	logrus.Info("Backing up Neo4j database (Enterprise Edition online backup)...")
 
@@ -215,15 +242,19 @@ func (iops *InfrahubOps) backupNeo4jEnterprise(backupDir string, backupMetadata
 		}
 	}()
 
-	if output, err := iops.Exec(
-		"database",
-		[]string{"neo4j-admin", "database", "backup", "--expand-commands", "--include-metadata=" + backupMetadata, "--to-path=/tmp/infrahubops", iops.config.Neo4jDatabase},
-		nil,
-	); err != nil {
+	backupCmd, backupOpts := iops.neo4jAdmin(iops.neo4jBackupCommand(backupMetadata), nil)
+	if output, err := iops.Exec("database", iops.lowPriority("database", backupCmd), backupOpts); err != nil {
 		return fmt.Errorf("failed to backup neo4j: %w\nOutput: %v", err, output)
 	}
 
-	if err := iops.CopyFrom("database", neo4jTempBackupDir, filepath.Join(backupDir, "database")); err != nil {
+	if err := iops.failAt(FailAtNeo4jCopy); err != nil {
+		return err
+	}
+	copyBackup := iops.CopyFrom
+	if iops.throttle != nil {
+		copyBackup = iops.copyDirThrottled
+	}
+	if err := copyBackup("database", neo4jTempBackupDir, filepath.Join(backupDir, "database")); err != nil {
 		return fmt.Errorf("failed to copy database backup: %w", err)
 	}
 
@@ -232,33 +263,19 @@ func (iops *InfrahubOps) backupNeo4jEnterprise(backupDir string, backupMetadata
 }
 
 func (iops *InfrahubOps) stopNeo4jCommunity(pidStr string) error {
-	if _, err := iops.Exec("database", []string{"mkdir", "-p", neo4jRemoteWorkDir}, nil); err != nil {
-		return fmt.Errorf("failed to prepare remote work directory: %w", err)
-	}
-
-	arch, err := iops.detectNeo4jArchitecture()
-	if err != nil {
-		return err
-	}
-
-	watchdogBytes, err := selectWatchdogBinary(arch)
+	arch, err := iops.deployWatchdog()
 	if err != nil {
 		return err
 	}
 
-	localWatchdog, cleanup, err := writeEmbeddedWatchdog(watchdogBytes)
-	if err != nil {
+	// Prove the watchdog runs in this container before relying on it to
+	// freeze Neo4j.
+	if err := iops.selfTestWatchdog(arch); err != nil {
+		if _, rmErr := iops.Exec("database", []string{"rm", "-f", neo4jRemoteWatchdogBinary}, nil); rmErr != nil {
+			logrus.Debugf("Failed to remove watchdog binary: %v", rmErr)
+		}
 		return err
 	}
-	defer cleanup()
-
-	if err := iops.CopyTo("database", localWatchdog, neo4jRemoteWatchdogBinary); err != nil {
-		return fmt.Errorf("failed to deploy watchdog binary: %w", err)
-	}
-
-	if _, err := iops.Exec("database", []string{"chmod", "+x", neo4jRemoteWatchdogBinary}, nil); err != nil {
-		return fmt.Errorf("failed to mark watchdog executable: %w", err)
-	}
 
 	if _, err := iops.Exec("database", []string{"rm", "-f", neo4jRemoteWatchdogReady, neo4jRemoteWatchdogLog}, nil); err != nil {
 		logrus.Debugf("Could not clear watchdog markers: %v", err)
@@ -319,18 +336,16 @@ func (iops *InfrahubOps) backupNeo4jCommunity(backupDir string) (retErr error) {
 		return fmt.Errorf("failed to prepare local dump directory: %w", err)
 	}
 
-	dumpCmd := []string{
-		"neo4j-admin", "database", "dump",
-		"--overwrite-destination=true",
-		"--to-path=" + neo4jRemoteWorkDir,
-		iops.config.Neo4jDatabase,
-	}
-	if output, dumpErr := iops.Exec("database", dumpCmd, nil); dumpErr != nil {
+	dumpCmd, dumpOpts := iops.neo4jAdmin(iops.neo4jDumpCommand(), nil)
+	if output, dumpErr := iops.Exec("database", iops.lowPriority("database", dumpCmd), dumpOpts); dumpErr != nil {
 		return fmt.Errorf("failed to dump neo4j database: %w\nOutput: %v", dumpErr, output)
 	}
 
 	dumpFilename := fmt.Sprintf("%s.dump", iops.config.Neo4jDatabase)
-	if err := iops.CopyFrom("database", neo4jRemoteWorkDir+"/"+dumpFilename, filepath.Join(databaseDir, dumpFilename)); err != nil {
+	if err := iops.failAt(FailAtNeo4jCopy); err != nil {
+		return err
+	}
+	if err := iops.CopyFileFrom("database", neo4jRemoteWorkDir+"/"+dumpFilename, filepath.Join(databaseDir, dumpFilename)); err != nil {
 		return fmt.Errorf("failed to copy neo4j dump: %w", err)
 	}
 
@@ -339,21 +354,96 @@ func (iops *InfrahubOps) backupNeo4jCommunity(backupDir string) (retErr error) {
 }
 
 func (iops *InfrahubOps) restoreNeo4j(workDir, neo4jEdition string, restoreMigrateFormat bool) error {
+	cleanup, err := iops.prepareNeo4jRestore(workDir)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	return iops.applyNeo4jRestore(neo4jEdition, restoreMigrateFormat)
+}
+
+// prepareNeo4jRestore stages the backup files inside the database container.
+// It does not touch the live database, so it can run while Infrahub is still
+// serving traffic. The returned cleanup removes the staged files.
+func (iops *InfrahubOps) prepareNeo4jRestore(workDir string) (func(), error) {
 	backupPath := filepath.Join(workDir, "backup", "database")
 
-	if err := iops.CopyTo("database", backupPath, neo4jTempBackupDir); err != nil {
-		return fmt.Errorf("failed to copy backup to container: %w", err)
+	if mapping := iops.config.CredentialMap; mapping != nil {
+		if err := renameNeo4jBackupFiles(backupPath, mapping.Neo4jDatabase); err != nil {
+			return nil, err
+		}
 	}
-	defer func() {
+
+	cleanup := func() {
 		if _, err := iops.Exec("database", []string{"rm", "-rf", neo4jTempBackupDir}, nil); err != nil {
 			logrus.Warnf("Failed to cleanup temporary Neo4j backup data (this is expected for community restore method): %v", err)
 		}
-	}()
+	}
+
+	if err := iops.CopyTo("database", backupPath, neo4jTempBackupDir); err != nil {
+		return nil, fmt.Errorf("failed to copy backup to container: %w", err)
+	}
 
 	if _, err := iops.Exec("database", []string{"chown", "-R", "neo4j:neo4j", neo4jTempBackupDir}, nil); err != nil {
-		return fmt.Errorf("failed to change backup ownership: %w", err)
+		cleanup()
+		return nil, fmt.Errorf("failed to change backup ownership: %w", err)
 	}
 
+	return cleanup, nil
+}
+
+// neo4jMetadataScriptSource returns the shell pipeline source that emits the
+// metadata script at scriptPath, renaming the mapped Neo4j user when requested.
+func (iops *InfrahubOps) neo4jMetadataScriptSource(scriptPath string) string {
+	if rewrite := neo4jMetadataUserRewrite(iops.config.CredentialMap); rewrite != "" {
+		return "sed '" + rewrite + "' " + scriptPath
+	}
+	return "cat " + scriptPath
+}
+
+// neo4jMetadataScript returns the path of the metadata script to run after a
+// restore. When the restore wrote none, the embedded restore_metadata.cypher
+// is copied into the container instead; cleanup removes that copy.
+func (iops *InfrahubOps) neo4jMetadataScript() (string, func(), error) {
+	scriptPath := iops.getNeo4jPaths().MetadataScript
+	if _, err := iops.Exec("database", []string{"test", "-f", scriptPath}, nil); err == nil {
+		logrus.Infof("Restoring Neo4j metadata from %s", scriptPath)
+		return scriptPath, func() {}, nil
+	}
+
+	content, err := readEmbeddedScript("restore_metadata.cypher")
+	if err != nil {
+		return "", nil, fmt.Errorf("could not retrieve restore_metadata.cypher: %w", err)
+	}
+	tmpFile, err := os.CreateTemp("", "infrahubops_restore_metadata_*.cypher")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err := tmpFile.Write(content); err != nil {
+		tmpFile.Close()
+		return "", nil, fmt.Errorf("failed to write metadata script: %w", err)
+	}
+	tmpFile.Close()
+	// The script runs as the neo4j user, not the owner of the copy.
+	if err := os.Chmod(tmpFile.Name(), 0o644); err != nil {
+		return "", nil, fmt.Errorf("failed to write metadata script: %w", err)
+	}
+	if err := iops.CopyTo("database", tmpFile.Name(), neo4jFallbackMetadataPath); err != nil {
+		return "", nil, fmt.Errorf("failed to copy metadata script to container: %w", err)
+	}
+	logrus.Infof("%s not found in the database container, restoring Neo4j metadata with the embedded script", scriptPath)
+	cleanup := func() {
+		if _, err := iops.Exec("database", []string{"rm", "-f", neo4jFallbackMetadataPath}, nil); err != nil {
+			logrus.Warnf("Failed to clean up %s: %v", neo4jFallbackMetadataPath, err)
+		}
+	}
+	return neo4jFallbackMetadataPath, cleanup, nil
+}
+
+// applyNeo4jRestore loads the staged backup files into the live database.
+func (iops *InfrahubOps) applyNeo4jRestore(neo4jEdition string, restoreMigrateFormat bool) error {
 	edition := strings.ToLower(neo4jEdition)
 	switch edition {
 	case neo4jEditionCommunity:
@@ -381,27 +471,26 @@ func (iops *InfrahubOps) restoreNeo4jEnterprise(restoreMigrateFormat bool) error
 		return fmt.Errorf("failed to stop neo4j database: %w", err)
 	}
 
-	if output, err := iops.Exec(
-		"database",
-		[]string{"neo4j-admin", "database", "restore", "--expand-commands", "--overwrite-destination=true", "--from-path=" + neo4jTempBackupDir, iops.config.Neo4jDatabase},
-		opts,
-	); err != nil {
+	restoreCmd, restoreOpts := iops.neo4jAdmin(iops.neo4jRestoreCommand(), opts)
+	if output, err := iops.Exec("database", restoreCmd, restoreOpts); err != nil {
 		return fmt.Errorf("failed to restore neo4j: %w\nOutput: %v", err, output)
 	}
 
 	if restoreMigrateFormat {
-		if output, err := iops.Exec(
-			"database",
-			[]string{"neo4j-admin", "database", "migrate", "--expand-commands", "--to-format=block", iops.config.Neo4jDatabase},
-			opts,
-		); err != nil {
+		migrateCmd, migrateOpts := iops.neo4jAdmin([]string{"neo4j-admin", "database", "migrate", "--expand-commands", "--to-format=block", iops.config.Neo4jDatabase}, opts)
+		if output, err := iops.Exec("database", migrateCmd, migrateOpts); err != nil {
 			return fmt.Errorf("failed to migrate neo4j to block format: %w\nOutput: %v", err, output)
 		}
 	}
 
+	metadataScript, cleanup, err := iops.neo4jMetadataScript()
+	if err != nil {
+		return err
+	}
+	defer cleanup()
 	if output, err := iops.Exec(
 		"database",
-		[]string{"sh", "-c", "cat " + neo4jMetadataScriptPath + " | cypher-shell -u " + iops.config.Neo4jUsername + " -p" + iops.config.Neo4jPassword + " -d system --param \"database => '" + iops.config.Neo4jDatabase + "'\""},
+		[]string{"sh", "-c", iops.neo4jMetadataScriptSource(metadataScript) + " | cypher-shell -u " + iops.config.Neo4jUsername + " -p" + iops.config.Neo4jPassword + " -d system --param \"database => '" + iops.config.Neo4jDatabase + "'\""},
 		opts,
 	); err != nil {
 		return fmt.Errorf("failed to restore neo4j metadata: %w\nOutput: %v", err, output)
@@ -442,12 +531,13 @@ func (iops *InfrahubOps) restoreNeo4jCluster(opts *ExecOptions) error {
 
 	// 2. Restore backup using neo4j-admin (on current node only)
 	logrus.Info("Restoring backup with neo4j-admin...")
-	if output, err := iops.Exec("database", []string{
+	restoreCmd, restoreOpts := iops.neo4jAdmin([]string{
 		"neo4j-admin", "database", "restore",
 		"--expand-commands", "--overwrite-destination=true",
 		"--from-path=" + neo4jTempBackupDir,
 		iops.config.Neo4jDatabase,
-	}, opts); err != nil {
+	}, opts)
+	if output, err := iops.Exec("database", restoreCmd, restoreOpts); err != nil {
 		return fmt.Errorf("failed to restore neo4j: %w\nOutput: %v", err, output)
 	}
 
@@ -553,20 +643,14 @@ func (iops *InfrahubOps) restoreNeo4jCommunity(restoreMigrateFormat bool) (retEr
 	}()
 
 	opts := iops.getNeo4jExecOptions()
-	if output, err := iops.Exec(
-		"database",
-		[]string{"neo4j-admin", "database", "load", "--overwrite-destination=true", "--from-path=" + neo4jTempBackupDir, iops.config.Neo4jDatabase},
-		opts,
-	); err != nil {
+	loadCmd, loadOpts := iops.neo4jAdmin(iops.neo4jLoadCommand(), opts)
+	if output, err := iops.Exec("database", loadCmd, loadOpts); err != nil {
 		return fmt.Errorf("failed to load neo4j dump: %w\nOutput: %v", err, output)
 	}
 
 	if restoreMigrateFormat {
-		if output, err := iops.Exec(
-			"database",
-			[]string{"neo4j-admin", "database", "migrate", "--to-format=block", iops.config.Neo4jDatabase},
-			opts,
-		); err != nil {
+		migrateCmd, migrateOpts := iops.neo4jAdmin([]string{"neo4j-admin", "database", "migrate", "--to-format=block", iops.config.Neo4jDatabase}, opts)
+		if output, err := iops.Exec("database", migrateCmd, migrateOpts); err != nil {
 			return fmt.Errorf("failed to migrate neo4j to block format: %w\nOutput: %v", err, output)
 		}
 	}
@@ -604,12 +688,8 @@ func (iops *InfrahubOps) restoreNeo4jCommunityStream(reader io.ReadCloser, resto
 
 	opts := iops.getNeo4jExecOptions()
 
-	wait, err := iops.ExecWritePipe(
-		"database",
-		[]string{"neo4j-admin", "database", "load", "--from-stdin", "--overwrite-destination=true", iops.config.Neo4jDatabase},
-		opts,
-		reader,
-	)
+	loadCmd, loadOpts := iops.neo4jAdmin([]string{"neo4j-admin", "database", "load", "--from-stdin", "--overwrite-destination=true", iops.config.Neo4jDatabase}, opts)
+	wait, err := iops.ExecWritePipe("database", loadCmd, loadOpts, reader)
 	if err != nil {
 		return fmt.Errorf("failed to start neo4j streamed load: %w", err)
 	}
@@ -619,11 +699,8 @@ func (iops *InfrahubOps) restoreNeo4jCommunityStream(reader io.ReadCloser, resto
 	}
 
 	if restoreMigrateFormat {
-		if output, err := iops.Exec(
-			"database",
-			[]string{"neo4j-admin", "database", "migrate", "--to-format=block", iops.config.Neo4jDatabase},
-			opts,
-		); err != nil {
+		migrateCmd, migrateOpts := iops.neo4jAdmin([]string{"neo4j-admin", "database", "migrate", "--to-format=block", iops.config.Neo4jDatabase}, opts)
+		if output, err := iops.Exec("database", migrateCmd, migrateOpts); err != nil {
 			return fmt.Errorf("failed to migrate neo4j to block format: %w\nOutput: %v", err, output)
 		}
 	}
@@ -632,10 +709,18 @@ func (iops *InfrahubOps) restoreNeo4jCommunityStream(reader io.ReadCloser, resto
 	return nil
 }
 
+// readNeo4jPID returns the PID of the Neo4j server from its PID file or,
+// when the file cannot be read, from the running processes.
 func (iops *InfrahubOps) readNeo4jPID() (string, error) {
-	output, err := iops.Exec("database", []string{"cat", neo4jPIDFile}, nil)
+	pidFile := iops.getNeo4jPaths().PIDFile
+	output, err := iops.Exec("database", []string{"cat", pidFile}, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to read neo4j pid file: %w", err)
+		pid, procErr := iops.findNeo4jProcess()
+		if procErr != nil {
+			return "", fmt.Errorf("failed to read neo4j pid file %s: %w (set --neo4j-pid-file; process lookup: %v)", pidFile, err, procErr)
+		}
+		logrus.Infof("Neo4j PID file %s not found; using the Neo4j server process %s", pidFile, pid)
+		return pid, nil
 	}
 	pid := strings.TrimSpace(output)
 	if pid == "" {
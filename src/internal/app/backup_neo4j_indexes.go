@@ -0,0 +1,183 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// neo4jIndexesComponent is recorded in backup metadata when the index
+	// and constraint script is included.
+	neo4jIndexesComponent = "neo4j-indexes"
+
+	// neo4jIndexesFilename holds the Cypher statements recreating the
+	// indexes and constraints of the database at backup time.
+	neo4jIndexesFilename = "neo4j_indexes.cypher"
+)
+
+// Modes of restore --replay-indexes.
+const (
+	IndexReplayAuto   = "auto"
+	IndexReplayAlways = "always"
+	IndexReplayNever  = "never"
+)
+
+// Constraints come first: they create their own backing indexes, which are
+// left out of the index list. Token lookup indexes exist in every new
+// database.
+const (
+	neo4jConstraintsQuery = "SHOW CONSTRAINTS YIELD createStatement RETURN createStatement"
+	neo4jIndexesQuery     = "SHOW INDEXES YIELD type, owningConstraint, createStatement WHERE owningConstraint IS NULL AND type <> 'LOOKUP' RETURN createStatement"
+)
+
+// exportNeo4jIndexes returns a Cypher script recreating the indexes and
+// constraints of the configured database.
+func (iops *InfrahubOps) exportNeo4jIndexes() ([]byte, error) {
+	var statements []string
+	for _, query := range []string{neo4jConstraintsQuery, neo4jIndexesQuery} {
+		output, err := iops.Exec("database", []string{
+			"cypher-shell",
+			"-u", iops.config.Neo4jUsername,
+			"-p" + iops.config.Neo4jPassword,
+			"-d", iops.config.Neo4jDatabase,
+			"--format", "plain",
+			query,
+		}, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list neo4j indexes and constraints: %w\nOutput: %v", err, output)
+		}
+		statements = append(statements, parseCreateStatements(output)...)
+	}
+	return neo4jIndexScript(statements), nil
+}
+
+// parseCreateStatements parses the plain cypher-shell output of a
+// createStatement query; the header row is skipped.
+func parseCreateStatements(output string) []string {
+	var statements []string
+	for _, line := range nonEmptyLines(output) {
+		if unquoted, err := strconv.Unquote(line); err == nil {
+			line = unquoted
+		}
+		if strings.HasPrefix(line, "CREATE ") {
+			statements = append(statements, line)
+		}
+	}
+	return statements
+}
+
+// neo4jIndexScript turns create statements into a script that can run
+// against a database that already has some of them.
+func neo4jIndexScript(statements []string) []byte {
+	var script strings.Builder
+	script.WriteString("// Indexes and constraints at backup time, replayed by restore --replay-indexes.\n")
+	for _, statement := range statements {
+		if !strings.Contains(statement, " IF NOT EXISTS ") {
+			statement = strings.Replace(statement, " FOR (", " IF NOT EXISTS FOR (", 1)
+		}
+		script.WriteString(statement + ";\n")
+	}
+	return []byte(script.String())
+}
+
+// neo4jIndexReplayReason returns why the dump may not carry the index
+// definitions onto the target: a different major version or edition. It
+// returns an empty string when neither differs or the versions are unknown.
+func neo4jIndexReplayReason(sourceVersion, sourceEdition, targetVersion, targetEdition string) string {
+	source, sourceErr := parseNeo4jVersion(sourceVersion)
+	target, targetErr := parseNeo4jVersion(targetVersion)
+	if sourceErr == nil && targetErr == nil && source[0] != target[0] {
+		return fmt.Sprintf("backup taken on Neo4j %s, restoring onto %s", sourceVersion, targetVersion)
+	}
+	if sourceEdition != "" && targetEdition != "" && !strings.EqualFold(sourceEdition, targetEdition) {
+		return fmt.Sprintf("backup taken on Neo4j %s edition, restoring onto %s", sourceEdition, targetEdition)
+	}
+	return ""
+}
+
+// neo4jIndexesForRestore returns the index script to replay once the Neo4j
+// restore completes, or nil. With --replay-indexes auto, the script is only
+// replayed across major versions or editions.
+func (iops *InfrahubOps) neo4jIndexesForRestore(backupDir string, metadata *BackupMetadata, targetEdition string) []byte {
+	mode := iops.config.Neo4jIndexReplay
+	if mode == IndexReplayNever {
+		return nil
+	}
+	script, err := os.ReadFile(filepath.Join(backupDir, neo4jIndexesFilename))
+	if err != nil {
+		if mode == IndexReplayAlways {
+			logrus.Warnf("Backup has no %s; indexes and constraints will not be replayed", neo4jIndexesFilename)
+		}
+		return nil
+	}
+	if mode == IndexReplayAlways {
+		logrus.Info("Indexes and constraints from the backup will be replayed after the Neo4j restore")
+		return script
+	}
+
+	targetVersion := ""
+	if target, err := iops.detectNeo4jServerInfo(); err == nil {
+		targetVersion = target.Version
+	}
+	reason := neo4jIndexReplayReason(metadata.Neo4jVersion, metadata.Neo4jEdition, targetVersion, targetEdition)
+	if reason == "" {
+		logrus.Debug("Neo4j version and edition match the backup; index definitions come with the dump")
+		return nil
+	}
+	logrus.Infof("%s; indexes and constraints from the backup will be replayed after the Neo4j restore (--replay-indexes never to skip)", reason)
+	return script
+}
+
+// replayNeo4jIndexes runs an index script picked by neo4jIndexesForRestore. A
+// failure is logged, not returned: the data is restored, and the statements
+// can be replayed by hand from the backup.
+func (iops *InfrahubOps) replayNeo4jIndexes(script []byte, tempDir string) {
+	if len(script) == 0 {
+		return
+	}
+	logrus.Info("Replaying Neo4j indexes and constraints...")
+	if err := iops.runNeo4jIndexScript(script, tempDir); err != nil {
+		logrus.Warnf("Some indexes or constraints may be missing; replay %s from the backup by hand: %v", neo4jIndexesFilename, err)
+		return
+	}
+	logrus.Info("Neo4j indexes and constraints replayed")
+}
+
+func (iops *InfrahubOps) runNeo4jIndexScript(script []byte, tempDir string) error {
+	localPath := filepath.Join(tempDir, "infrahubops_"+neo4jIndexesFilename)
+	// cypher-shell may run as the neo4j user, not the owner of the copy.
+	if err := os.WriteFile(localPath, script, 0644); err != nil {
+		return err
+	}
+	defer os.Remove(localPath)
+
+	remotePath := "/tmp/infrahubops_" + neo4jIndexesFilename
+	if err := iops.CopyTo("database", localPath, remotePath); err != nil {
+		return fmt.Errorf("failed to copy index script: %w", err)
+	}
+	defer func() {
+		if _, err := iops.Exec("database", []string{"rm", "-f", remotePath}, nil); err != nil {
+			logrus.Warnf("Failed to remove temporary index script: %v", err)
+		}
+	}()
+
+	if err := iops.waitForNeo4jStart(neo4jProcessStopTimeout); err != nil {
+		return err
+	}
+	if output, err := iops.Exec("database", []string{
+		"cypher-shell",
+		"-u", iops.config.Neo4jUsername,
+		"-p" + iops.config.Neo4jPassword,
+		"-d", iops.config.Neo4jDatabase,
+		"--fail-at-end",
+		"-f", remotePath,
+	}, nil); err != nil {
+		return fmt.Errorf("%w\nOutput: %v", err, output)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package app
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestExportNeo4jIndexes(t *testing.T) {
+	fake := newFakeExecutor().
+		on("SHOW CONSTRAINTS", "createStatement\n\"CREATE CONSTRAINT `node_uuid` FOR (n:`Node`) REQUIRE (n.`uuid`) IS UNIQUE\"\n", nil).
+		on("SHOW INDEXES", "createStatement\n\"CREATE RANGE INDEX `attr_value` FOR (n:`AttributeValue`) ON (n.`value`)\"\n\"CREATE RANGE INDEX `rel_branch` IF NOT EXISTS FOR ()-[r:`HAS_VALUE`]-() ON (r.`branch`)\"\n", nil)
+	iops := newFakeDockerOps(fake)
+	iops.config.Neo4jDatabase = "neo4j"
+
+	script, err := iops.exportNeo4jIndexes()
+	if err != nil {
+		t.Fatalf("exportNeo4jIndexes() error = %v", err)
+	}
+	want := "// Indexes and constraints at backup time, replayed by restore --replay-indexes.\n" +
+		"CREATE CONSTRAINT `node_uuid` IF NOT EXISTS FOR (n:`Node`) REQUIRE (n.`uuid`) IS UNIQUE;\n" +
+		"CREATE RANGE INDEX `attr_value` IF NOT EXISTS FOR (n:`AttributeValue`) ON (n.`value`);\n" +
+		"CREATE RANGE INDEX `rel_branch` IF NOT EXISTS FOR ()-[r:`HAS_VALUE`]-() ON (r.`branch`);\n"
+	if string(script) != want {
+		t.Errorf("script:\n%s\nwant:\n%s", script, want)
+	}
+}
+
+func TestNeo4jIndexReplayReason(t *testing.T) {
+	tests := []struct {
+		name                                                       string
+		sourceVersion, sourceEdition, targetVersion, targetEdition string
+		wantReason                                                 bool
+	}{
+		{"same version and edition", "5.26.1", "enterprise", "5.20.0", "enterprise", false},
+		{"major upgrade", "4.4.30", "enterprise", "5.26.1", "enterprise", true},
+		{"calendar versioning", "5.26.1", "community", "2025.01.0", "community", true},
+		{"edition change", "5.26.1", "enterprise", "5.26.1", "community", true},
+		{"unknown versions", "", "", "", "community", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason := neo4jIndexReplayReason(tt.sourceVersion, tt.sourceEdition, tt.targetVersion, tt.targetEdition)
+			if (reason != "") != tt.wantReason {
+				t.Errorf("neo4jIndexReplayReason() = %q, want reason %t", reason, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestNeo4jIndexesForRestore(t *testing.T) {
+	backupDir := t.TempDir()
+	writeTestFile(t, backupDir, neo4jIndexesFilename, "CREATE INDEX;\n")
+
+	tests := []struct {
+		name      string
+		mode      string
+		backupDir string
+		source    string
+		want      bool
+	}{
+		{name: "auto, same version", mode: IndexReplayAuto, backupDir: backupDir, source: "5.26.1"},
+		{name: "auto, major upgrade", mode: IndexReplayAuto, backupDir: backupDir, source: "4.4.30", want: true},
+		{name: "always", mode: IndexReplayAlways, backupDir: backupDir, source: "5.26.1", want: true},
+		{name: "never", mode: IndexReplayNever, backupDir: backupDir, source: "4.4.30"},
+		{name: "no script in the backup", mode: IndexReplayAlways, backupDir: filepath.Join(backupDir, "missing")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iops := newFakeDockerOps(newFakeExecutor().on("dbms.components()", "versions[0]\n\"5.26.1\"\n", nil))
+			iops.config.Neo4jIndexReplay = tt.mode
+			metadata := &BackupMetadata{Neo4jVersion: tt.source, Neo4jEdition: "enterprise"}
+
+			script := iops.neo4jIndexesForRestore(tt.backupDir, metadata, "enterprise")
+			if (script != nil) != tt.want {
+				t.Errorf("neo4jIndexesForRestore() = %q, want script %t", script, tt.want)
+			}
+		})
+	}
+}
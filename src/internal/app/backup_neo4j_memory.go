@@ -0,0 +1,124 @@
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// neo4j-admin runs next to the Neo4j server in the database container, so by
+// default its heap and page cache each get an eighth of the container memory
+// limit, within these bounds.
+const (
+	neo4jAdminMemoryShare  = 8
+	neo4jAdminMinHeap      = 256 << 20
+	neo4jAdminMaxHeap      = 2 << 30
+	neo4jAdminMinPageCache = 64 << 20
+	neo4jAdminMaxPageCache = 2 << 30
+)
+
+// unlimitedMemory is the smallest limit treated as no limit: cgroup v1
+// reports an unlimited container as a number close to the int64 maximum.
+const unlimitedMemory = int64(1) << 50
+
+// containerMemoryLimitScript prints the memory limit of the container with
+// cgroup v2 or v1, or nothing.
+const containerMemoryLimitScript = "cat /sys/fs/cgroup/memory.max 2>/dev/null || cat /sys/fs/cgroup/memory/memory.limit_in_bytes 2>/dev/null || true"
+
+// neo4jPageCacheSubcommands are the neo4j-admin database subcommands that
+// accept --pagecache.
+var neo4jPageCacheSubcommands = map[string]bool{"backup": true, "migrate": true}
+
+// neo4jAdminMemory is the heap size and page cache of neo4j-admin, in the
+// Neo4j size notation; empty values keep neo4j-admin's defaults.
+type neo4jAdminMemory struct {
+	heap      string
+	pageCache string
+}
+
+// parseNeo4jMemorySize validates a --neo4j-admin-heap or
+// --neo4j-admin-pagecache value and returns it in megabytes, e.g. 1G as 1024m.
+func parseNeo4jMemorySize(flag, value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	size, err := parseByteSize(value)
+	if err != nil {
+		return "", fmt.Errorf("--%s: %w", flag, err)
+	}
+	return formatNeo4jMemorySize(size), nil
+}
+
+func formatNeo4jMemorySize(bytes int64) string {
+	return strconv.FormatInt(max(bytes>>20, 1), 10) + "m"
+}
+
+// deriveNeo4jAdminMemory returns the default neo4j-admin memory for a
+// container limited to limit bytes.
+func deriveNeo4jAdminMemory(limit int64) neo4jAdminMemory {
+	share := limit / neo4jAdminMemoryShare
+	return neo4jAdminMemory{
+		heap:      formatNeo4jMemorySize(min(max(share, neo4jAdminMinHeap), neo4jAdminMaxHeap)),
+		pageCache: formatNeo4jMemorySize(min(max(share, neo4jAdminMinPageCache), neo4jAdminMaxPageCache)),
+	}
+}
+
+// parseContainerMemoryLimit reads the output of containerMemoryLimitScript.
+func parseContainerMemoryLimit(output string) (int64, bool) {
+	limit, err := strconv.ParseInt(strings.TrimSpace(output), 10, 64)
+	if err != nil || limit <= 0 || limit >= unlimitedMemory {
+		return 0, false
+	}
+	return limit, true
+}
+
+// neo4jAdminMemorySettings returns the neo4j-admin memory: the configured
+// values, with unset ones derived from the database container memory limit
+// when it has one. It is resolved on first use.
+func (iops *InfrahubOps) neo4jAdminMemorySettings() neo4jAdminMemory {
+	if iops.neo4jAdminMem != nil {
+		return *iops.neo4jAdminMem
+	}
+	memory := neo4jAdminMemory{heap: iops.config.Neo4jAdminHeap, pageCache: iops.config.Neo4jAdminPageCache}
+	if memory.heap == "" || memory.pageCache == "" {
+		output, err := iops.Exec("database", []string{"sh", "-c", containerMemoryLimitScript}, nil)
+		if limit, ok := parseContainerMemoryLimit(output); err == nil && ok {
+			derived := deriveNeo4jAdminMemory(limit)
+			if memory.heap == "" {
+				memory.heap = derived.heap
+			}
+			if memory.pageCache == "" {
+				memory.pageCache = derived.pageCache
+			}
+			logrus.Infof("Database container is limited to %s; running neo4j-admin with heap %s and page cache %s", formatBytes(limit), memory.heap, memory.pageCache)
+		}
+	}
+	iops.neo4jAdminMem = &memory
+	return memory
+}
+
+// neo4jAdmin applies the neo4j-admin memory settings to a neo4j-admin
+// command: --pagecache where the subcommand accepts it, and the heap size
+// through the HEAP_SIZE variable read by neo4j-admin.
+func (iops *InfrahubOps) neo4jAdmin(cmd []string, opts *ExecOptions) ([]string, *ExecOptions) {
+	memory := iops.neo4jAdminMemorySettings()
+	if memory.pageCache != "" && len(cmd) > 2 && neo4jPageCacheSubcommands[cmd[2]] {
+		withPageCache := append([]string{}, cmd[:3]...)
+		withPageCache = append(withPageCache, "--pagecache="+memory.pageCache)
+		cmd = append(withPageCache, cmd[3:]...)
+	}
+	if memory.heap != "" {
+		withHeap := &ExecOptions{Env: map[string]string{}}
+		if opts != nil {
+			withHeap.User = opts.User
+			for key, value := range opts.Env {
+				withHeap.Env[key] = value
+			}
+		}
+		withHeap.Env["HEAP_SIZE"] = memory.heap
+		opts = withHeap
+	}
+	return cmd, opts
+}
@@ -0,0 +1,86 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseContainerMemoryLimit(t *testing.T) {
+	tests := []struct {
+		output string
+		want   int64
+		ok     bool
+	}{
+		{output: "4294967296\n", want: 4 << 30, ok: true},
+		{output: "max\n"},
+		{output: "9223372036854771712\n"},
+		{output: ""},
+	}
+	for _, tt := range tests {
+		got, ok := parseContainerMemoryLimit(tt.output)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseContainerMemoryLimit(%q) = %d, %t; want %d, %t", tt.output, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestDeriveNeo4jAdminMemory(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit int64
+		want  neo4jAdminMemory
+	}{
+		{name: "small container", limit: 1 << 30, want: neo4jAdminMemory{heap: "256m", pageCache: "128m"}},
+		{name: "medium container", limit: 8 << 30, want: neo4jAdminMemory{heap: "1024m", pageCache: "1024m"}},
+		{name: "large container", limit: 64 << 30, want: neo4jAdminMemory{heap: "2048m", pageCache: "2048m"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := deriveNeo4jAdminMemory(tt.limit); got != tt.want {
+				t.Errorf("deriveNeo4jAdminMemory() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNeo4jMemorySize(t *testing.T) {
+	if got, err := parseNeo4jMemorySize("neo4j-admin-heap", "1.5G"); err != nil || got != "1536m" {
+		t.Errorf("parseNeo4jMemorySize(1.5G) = %q, %v", got, err)
+	}
+	if _, err := parseNeo4jMemorySize("neo4j-admin-heap", "lots"); err == nil {
+		t.Error("parseNeo4jMemorySize(lots) succeeded")
+	}
+}
+
+func TestNeo4jAdmin(t *testing.T) {
+	fake := newFakeExecutor().on("memory.max", "2147483648\n", nil)
+	iops := newFakeDockerOps(fake)
+	iops.config.Neo4jAdminHeap = "768m"
+
+	cmd, opts := iops.neo4jAdmin([]string{"neo4j-admin", "database", "backup", "--to-path=/tmp/infrahubops", "neo4j"}, nil)
+	if want := []string{"neo4j-admin", "database", "backup", "--pagecache=256m", "--to-path=/tmp/infrahubops", "neo4j"}; !reflect.DeepEqual(cmd, want) {
+		t.Errorf("backup command = %v, want %v", cmd, want)
+	}
+	if opts == nil || opts.Env["HEAP_SIZE"] != "768m" {
+		t.Errorf("backup options = %+v", opts)
+	}
+
+	cmd, opts = iops.neo4jAdmin([]string{"neo4j-admin", "database", "load", "--from-stdin", "neo4j"}, &ExecOptions{User: "neo4j"})
+	if want := []string{"neo4j-admin", "database", "load", "--from-stdin", "neo4j"}; !reflect.DeepEqual(cmd, want) {
+		t.Errorf("load command = %v, want %v", cmd, want)
+	}
+	if opts.User != "neo4j" || opts.Env["HEAP_SIZE"] != "768m" {
+		t.Errorf("load options = %+v", opts)
+	}
+	if probes := fake.commands("memory.max"); len(probes) != 1 {
+		t.Errorf("memory limit probed %d times, want once", len(probes))
+	}
+}
+
+func TestNeo4jAdminWithoutMemoryLimit(t *testing.T) {
+	iops := newFakeDockerOps(newFakeExecutor().on("memory.max", "max\n", nil))
+	cmd, opts := iops.neo4jAdmin([]string{"neo4j-admin", "database", "backup", "neo4j"}, nil)
+	if len(cmd) != 4 || opts != nil {
+		t.Errorf("neo4jAdmin() = %v, %+v; want the command unchanged", cmd, opts)
+	}
+}
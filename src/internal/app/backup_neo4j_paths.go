@@ -0,0 +1,145 @@
+package app
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Neo4j locations used when neither flags nor neo4j.conf say otherwise; they
+// match the official Neo4j images.
+const (
+	defaultNeo4jHome    = "/var/lib/neo4j"
+	defaultNeo4jDataDir = "/data"
+)
+
+// neo4jConfScript prints NEO4J_HOME, then the neo4j.conf the server reads:
+// $NEO4J_CONF/neo4j.conf, or conf/neo4j.conf under NEO4J_HOME.
+const neo4jConfScript = `home="${NEO4J_HOME:-` + defaultNeo4jHome + `}"
+echo "NEO4J_HOME=$home"
+cat "${NEO4J_CONF:-$home/conf}/neo4j.conf" 2>/dev/null || true`
+
+// neo4jProcessScript prints the PID of the Neo4j server process, found by
+// its main class, for images whose PID file cannot be found. The escaped
+// dots keep the script and grep from matching their own command lines.
+const neo4jProcessScript = `for p in /proc/[0-9]*; do
+  if tr '\0' ' ' < "$p/cmdline" 2>/dev/null | grep -qE '(org|com)\.neo4j\.server\.'; then basename "$p"; fi
+done`
+
+// neo4jPaths are the locations inside the database container that depend on
+// how the image configures Neo4j.
+type neo4jPaths struct {
+	PIDFile        string
+	DataDir        string
+	MetadataScript string
+}
+
+// parseNeo4jConf returns the settings of a neo4j.conf, plus NEO4J_HOME when
+// the output starts with it as neo4jConfScript prints.
+func parseNeo4jConf(content string) map[string]string {
+	settings := map[string]string{}
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		settings[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return settings
+}
+
+// neo4jConfDir returns the directory a neo4j.conf setting names, for either
+// the Neo4j 5 (server.) or Neo4j 4 (dbms.) key. Relative paths are relative
+// to NEO4J_HOME.
+func neo4jConfDir(conf map[string]string, name string) string {
+	value := conf["server.directories."+name]
+	if value == "" {
+		value = conf["dbms.directories."+name]
+	}
+	if value == "" {
+		return ""
+	}
+	if !path.IsAbs(value) {
+		home := conf["NEO4J_HOME"]
+		if home == "" {
+			home = defaultNeo4jHome
+		}
+		value = path.Join(home, value)
+	}
+	return value
+}
+
+// resolveNeo4jPaths combines the --neo4j-* path flags, neo4j.conf and the
+// defaults of the official images.
+func resolveNeo4jPaths(cfg *Configuration, conf map[string]string) *neo4jPaths {
+	paths := &neo4jPaths{PIDFile: cfg.Neo4jPIDFile, DataDir: cfg.Neo4jDataDir, MetadataScript: cfg.Neo4jMetadataScript}
+	if paths.PIDFile == "" {
+		if run := neo4jConfDir(conf, "run"); run != "" {
+			paths.PIDFile = path.Join(run, "neo4j.pid")
+		} else {
+			home := conf["NEO4J_HOME"]
+			if home == "" {
+				home = defaultNeo4jHome
+			}
+			paths.PIDFile = path.Join(home, "run", "neo4j.pid")
+		}
+	}
+	if paths.DataDir == "" {
+		paths.DataDir = neo4jConfDir(conf, "data")
+		if paths.DataDir == "" {
+			paths.DataDir = defaultNeo4jDataDir
+		}
+	}
+	if paths.MetadataScript == "" {
+		database := cfg.Neo4jDatabase
+		if database == "" {
+			database = "neo4j"
+		}
+		paths.MetadataScript = path.Join(paths.DataDir, "scripts", database, "restore_metadata.cypher")
+	}
+	return paths
+}
+
+// getNeo4jPaths returns the Neo4j locations in the database container,
+// reading neo4j.conf on first use unless every path is set by flags.
+func (iops *InfrahubOps) getNeo4jPaths() *neo4jPaths {
+	if iops.neo4jPaths != nil {
+		return iops.neo4jPaths
+	}
+	cfg := iops.config
+	conf := map[string]string{}
+	if cfg.Neo4jPIDFile == "" || cfg.Neo4jDataDir == "" || cfg.Neo4jMetadataScript == "" {
+		output, err := iops.Exec("database", []string{"sh", "-c", neo4jConfScript}, nil)
+		if err != nil {
+			logrus.Debugf("Could not read neo4j.conf, using default Neo4j paths: %v", err)
+		} else {
+			conf = parseNeo4jConf(output)
+		}
+	}
+	iops.neo4jPaths = resolveNeo4jPaths(cfg, conf)
+	logrus.Debugf("Neo4j paths: pid file %s, data %s, metadata script %s", iops.neo4jPaths.PIDFile, iops.neo4jPaths.DataDir, iops.neo4jPaths.MetadataScript)
+	return iops.neo4jPaths
+}
+
+// findNeo4jProcess returns the PID of the Neo4j server process from /proc.
+func (iops *InfrahubOps) findNeo4jProcess() (string, error) {
+	output, err := iops.Exec("database", []string{"sh", "-c", neo4jProcessScript}, nil)
+	if err != nil {
+		return "", err
+	}
+	pids := nonEmptyLines(output)
+	switch len(pids) {
+	case 0:
+		return "", fmt.Errorf("no Neo4j server process found")
+	case 1:
+		return pids[0], nil
+	default:
+		return "", fmt.Errorf("several Neo4j server processes found: %s", strings.Join(pids, ", "))
+	}
+}
@@ -0,0 +1,107 @@
+package app
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestResolveNeo4jPaths(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Configuration
+		conf string
+		want neo4jPaths
+	}{
+		{
+			name: "official image defaults",
+			cfg:  Configuration{Neo4jDatabase: "neo4j"},
+			conf: "NEO4J_HOME=/var/lib/neo4j\n#server.directories.data=data\n",
+			want: neo4jPaths{PIDFile: "/var/lib/neo4j/run/neo4j.pid", DataDir: "/data", MetadataScript: "/data/scripts/neo4j/restore_metadata.cypher"},
+		},
+		{
+			name: "neo4j 5 relative and absolute directories",
+			cfg:  Configuration{Neo4jDatabase: "infrahub"},
+			conf: "NEO4J_HOME=/opt/neo4j\nserver.directories.run=var/run\nserver.directories.data = /srv/neo4j/data\n",
+			want: neo4jPaths{PIDFile: "/opt/neo4j/var/run/neo4j.pid", DataDir: "/srv/neo4j/data", MetadataScript: "/srv/neo4j/data/scripts/infrahub/restore_metadata.cypher"},
+		},
+		{
+			name: "neo4j 4 keys",
+			conf: "NEO4J_HOME=/var/lib/neo4j\ndbms.directories.run=/run/neo4j\ndbms.directories.data=/data/neo4j\n",
+			want: neo4jPaths{PIDFile: "/run/neo4j/neo4j.pid", DataDir: "/data/neo4j", MetadataScript: "/data/neo4j/scripts/neo4j/restore_metadata.cypher"},
+		},
+		{
+			name: "flags win over neo4j.conf",
+			cfg:  Configuration{Neo4jPIDFile: "/tmp/n.pid", Neo4jMetadataScript: "/meta.cypher"},
+			conf: "server.directories.run=/run/neo4j\nserver.directories.data=/srv/data\n",
+			want: neo4jPaths{PIDFile: "/tmp/n.pid", DataDir: "/srv/data", MetadataScript: "/meta.cypher"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolveNeo4jPaths(&tt.cfg, parseNeo4jConf(tt.conf)); *got != tt.want {
+				t.Errorf("resolveNeo4jPaths() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetNeo4jPathsSkipsConfWhenAllSet(t *testing.T) {
+	fake := newFakeExecutor()
+	iops := newFakeDockerOps(fake)
+	iops.config.Neo4jPIDFile = "/run/neo4j.pid"
+	iops.config.Neo4jDataDir = "/data"
+	iops.config.Neo4jMetadataScript = "/data/meta.cypher"
+
+	if got := iops.getNeo4jPaths(); got.PIDFile != "/run/neo4j.pid" {
+		t.Errorf("PIDFile = %s", got.PIDFile)
+	}
+	if len(fake.calls) != 0 {
+		t.Errorf("commands run = %v", fake.calls)
+	}
+}
+
+func TestReadNeo4jPID(t *testing.T) {
+	tests := []struct {
+		name    string
+		fake    *fakeExecutor
+		want    string
+		wantErr string
+	}{
+		{
+			name: "pid file from neo4j.conf",
+			fake: newFakeExecutor().
+				on("NEO4J_HOME", "NEO4J_HOME=/opt/neo4j\nserver.directories.run=/run/neo4j\n", nil).
+				on("cat /run/neo4j/neo4j.pid", "42\n", nil),
+			want: "42",
+		},
+		{
+			name: "process lookup when the pid file is missing",
+			fake: newFakeExecutor().
+				on("cat /var/lib/neo4j/run/neo4j.pid", "", errors.New("No such file or directory")).
+				on("/proc/", "7\n", nil),
+			want: "7",
+		},
+		{
+			name: "no pid file and no process",
+			fake: newFakeExecutor().
+				on("cat /var/lib/neo4j/run/neo4j.pid", "", errors.New("No such file or directory")),
+			wantErr: "--neo4j-pid-file",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iops := newFakeDockerOps(tt.fake)
+			pid, err := iops.readNeo4jPID()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("readNeo4jPID() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || pid != tt.want {
+				t.Errorf("readNeo4jPID() = %q, %v, want %q", pid, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package app
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNeo4jMetadataScript(t *testing.T) {
+	tests := []struct {
+		name     string
+		fake     *fakeExecutor
+		want     string
+		wantCopy bool
+	}{
+		{
+			name: "script written by the restore",
+			fake: newFakeExecutor(),
+			want: "/data/scripts/neo4j/restore_metadata.cypher",
+		},
+		{
+			name: "embedded script when missing",
+			fake: newFakeExecutor().
+				on("test -f /data/scripts/neo4j/restore_metadata.cypher", "", errors.New("exit status 1")),
+			want:     neo4jFallbackMetadataPath,
+			wantCopy: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iops := newFakeDockerOps(tt.fake)
+			iops.config.Neo4jDatabase = "neo4j"
+
+			got, cleanup, err := iops.neo4jMetadataScript()
+			if err != nil {
+				t.Fatalf("neo4jMetadataScript() error = %v", err)
+			}
+			cleanup()
+			if got != tt.want {
+				t.Errorf("neo4jMetadataScript() = %s, want %s", got, tt.want)
+			}
+			copies := tt.fake.commands(" cp -a ")
+			if tt.wantCopy != (len(copies) == 1 && strings.HasSuffix(copies[0], "database:"+neo4jFallbackMetadataPath)) {
+				t.Errorf("copies = %v, want copy %t", copies, tt.wantCopy)
+			}
+			removed := len(tt.fake.commands("rm -f "+neo4jFallbackMetadataPath)) == 1
+			if removed != tt.wantCopy {
+				t.Errorf("fallback script removed = %t, want %t", removed, tt.wantCopy)
+			}
+		})
+	}
+}
+
+func TestEmbeddedMetadataScript(t *testing.T) {
+	content, err := readEmbeddedScript("restore_metadata.cypher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "CREATE DATABASE $database IF NOT EXISTS;") {
+		t.Errorf("embedded script does not use the database parameter:\n%s", content)
+	}
+}
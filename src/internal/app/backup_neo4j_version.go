@@ -0,0 +1,196 @@
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// neo4jServerInfo describes the Neo4j server version and the store format of
+// the Infrahub database.
+type neo4jServerInfo struct {
+	Version     string
+	StoreFormat string
+}
+
+// detectNeo4jServerInfo queries the running Neo4j server for its version and
+// the store format of the configured database. Servers older than 5.x do not
+// report a store format; StoreFormat is left empty in that case.
+func (iops *InfrahubOps) detectNeo4jServerInfo() (*neo4jServerInfo, error) {
+	output, err := iops.Exec("database", []string{
+		"cypher-shell",
+		"-u", iops.config.Neo4jUsername,
+		"-p" + iops.config.Neo4jPassword,
+		"-d", "system",
+		"--format", "plain",
+		"CALL dbms.components() YIELD versions RETURN versions[0]",
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query neo4j version: %w", err)
+	}
+	version := lastCypherValue(output)
+	if _, err := parseNeo4jVersion(version); err != nil {
+		return nil, fmt.Errorf("unable to parse neo4j version from output: %s", strings.TrimSpace(output))
+	}
+
+	info := &neo4jServerInfo{Version: version}
+	output, err = iops.Exec("database", []string{
+		"cypher-shell",
+		"-u", iops.config.Neo4jUsername,
+		"-p" + iops.config.Neo4jPassword,
+		"-d", "system",
+		"--format", "plain",
+		"SHOW DATABASE " + quoteCypherName(iops.config.Neo4jDatabase) + " YIELD store RETURN store",
+	}, nil)
+	if err != nil {
+		logrus.Debugf("Could not query neo4j store format: %v", err)
+	} else {
+		info.StoreFormat = lastCypherValue(output)
+	}
+	return info, nil
+}
+
+// quoteCypherName backtick-quotes a database name for use in a Cypher
+// administration command, so names containing dashes or dots are accepted.
+func quoteCypherName(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// parseNeo4jVersion splits a version such as "5.26.1" or "2025.01.0" into its
+// numeric components.
+func parseNeo4jVersion(version string) ([]int, error) {
+	version = strings.TrimSpace(version)
+	if version == "" {
+		return nil, fmt.Errorf("empty neo4j version")
+	}
+	if idx := strings.IndexAny(version, "-+ "); idx >= 0 {
+		version = version[:idx]
+	}
+	parts := strings.Split(version, ".")
+	nums := make([]int, 0, len(parts))
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid neo4j version %q", version)
+		}
+		nums = append(nums, n)
+	}
+	return nums, nil
+}
+
+// compareNeo4jVersions returns -1, 0 or 1 when a is older than, equal to or
+// newer than b. Missing trailing components count as zero.
+func compareNeo4jVersions(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	return 0
+}
+
+// neo4jMinorVersion truncates a parsed version to its major and minor
+// components.
+func neo4jMinorVersion(version []int) []int {
+	if len(version) > 2 {
+		return version[:2]
+	}
+	return version
+}
+
+// isBlockStoreFormat reports whether a store format string names the block
+// format, which only Neo4j Enterprise can open.
+func isBlockStoreFormat(format string) bool {
+	return strings.HasPrefix(strings.ToLower(format), "block")
+}
+
+// planNeo4jRestore checks that a backup taken from source can be restored on
+// target and returns whether the store format has to be migrated after the
+// restore. Only the major and minor versions are compared: patch releases
+// share a store format, so a backup from 5.26.2 restores on 5.26.1. Store
+// formats changed with Neo4j 5, so restoring a 4.x backup on a 5.x or newer
+// server always needs a migration.
+func planNeo4jRestore(source, target neo4jServerInfo, targetEdition string, migrateFormat bool) (bool, error) {
+	if source.Version == "" || target.Version == "" {
+		return migrateFormat, nil
+	}
+	sourceVersion, err := parseNeo4jVersion(source.Version)
+	if err != nil {
+		return migrateFormat, err
+	}
+	targetVersion, err := parseNeo4jVersion(target.Version)
+	if err != nil {
+		return migrateFormat, err
+	}
+
+	if compareNeo4jVersions(neo4jMinorVersion(targetVersion), neo4jMinorVersion(sourceVersion)) < 0 {
+		return migrateFormat, fmt.Errorf("backup was taken from Neo4j %s but the target runs Neo4j %s; Neo4j cannot restore onto an older version, upgrade the target first", source.Version, target.Version)
+	}
+
+	isCommunity := strings.EqualFold(targetEdition, neo4jEditionCommunity)
+	if isCommunity && isBlockStoreFormat(source.StoreFormat) {
+		return migrateFormat, fmt.Errorf("backup uses the %s store format which Neo4j Community cannot open", source.StoreFormat)
+	}
+
+	if sourceVersion[0] < 5 && targetVersion[0] >= 5 && !migrateFormat {
+		if isCommunity {
+			logrus.Warnf("Backup from Neo4j %s needs a store migration on Neo4j %s; run 'neo4j-admin database migrate' on the target after restore", source.Version, target.Version)
+			return migrateFormat, nil
+		}
+		logrus.Infof("Backup from Neo4j %s needs a store migration on Neo4j %s; enabling --migrate-format", source.Version, target.Version)
+		return true, nil
+	}
+
+	return migrateFormat, nil
+}
+
+// resolveNeo4jRestoreFormat compares the Neo4j version recorded in metadata
+// with the target server before anything is stopped. Backups without a
+// recorded version, or targets that cannot be queried, keep the requested
+// migrate setting.
+func (iops *InfrahubOps) resolveNeo4jRestoreFormat(metadata *BackupMetadata, targetEdition string, migrateFormat bool) (bool, error) {
+	if metadata.Neo4jVersion == "" {
+		logrus.Debug("Backup metadata does not record a Neo4j version; skipping version check")
+		return migrateFormat, nil
+	}
+
+	target, err := iops.detectNeo4jServerInfo()
+	if err != nil {
+		logrus.Warnf("Could not determine target Neo4j version; skipping version check: %v", err)
+		return migrateFormat, nil
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"source_version":      metadata.Neo4jVersion,
+		"source_store_format": metadata.Neo4jStoreFormat,
+		"target_version":      target.Version,
+		"target_store_format": target.StoreFormat,
+	}).Info("Checking Neo4j version compatibility")
+
+	source := neo4jServerInfo{Version: metadata.Neo4jVersion, StoreFormat: metadata.Neo4jStoreFormat}
+	return planNeo4jRestore(source, *target, targetEdition, migrateFormat)
+}
+
+// recordNeo4jServerInfo stores the Neo4j version and store format in the
+// backup metadata. Failures only produce a warning.
+func (iops *InfrahubOps) recordNeo4jServerInfo(metadata *BackupMetadata) {
+	info, err := iops.detectNeo4jServerInfo()
+	if err != nil {
+		logrus.Warnf("Could not record Neo4j version: %v", err)
+		return
+	}
+	metadata.Neo4jVersion = info.Version
+	metadata.Neo4jStoreFormat = info.StoreFormat
+}
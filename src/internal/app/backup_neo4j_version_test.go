@@ -0,0 +1,132 @@
+package app
+
+import "testing"
+
+func TestCompareNeo4jVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "5.26.1", b: "5.26.1", want: 0},
+		{a: "5.26", b: "5.26.0", want: 0},
+		{a: "5.20.0", b: "5.26.1", want: -1},
+		{a: "2025.01.0", b: "5.26.1", want: 1},
+		{a: "5.26.1-aura", b: "5.26.0", want: 1},
+	}
+
+	for _, tt := range tests {
+		a, err := parseNeo4jVersion(tt.a)
+		if err != nil {
+			t.Fatalf("parseNeo4jVersion(%q): %v", tt.a, err)
+		}
+		b, err := parseNeo4jVersion(tt.b)
+		if err != nil {
+			t.Fatalf("parseNeo4jVersion(%q): %v", tt.b, err)
+		}
+		if got := compareNeo4jVersions(a, b); got != tt.want {
+			t.Errorf("compareNeo4jVersions(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestPlanNeo4jRestore(t *testing.T) {
+	tests := []struct {
+		name        string
+		source      neo4jServerInfo
+		target      neo4jServerInfo
+		edition     string
+		migrate     bool
+		wantMigrate bool
+		wantErr     bool
+	}{
+		{
+			name:    "same version",
+			source:  neo4jServerInfo{Version: "5.26.1", StoreFormat: "block-block-1.1"},
+			target:  neo4jServerInfo{Version: "5.26.1"},
+			edition: neo4jEditionEnterprise,
+		},
+		{
+			name:        "unknown source version",
+			target:      neo4jServerInfo{Version: "5.26.1"},
+			edition:     neo4jEditionEnterprise,
+			migrate:     true,
+			wantMigrate: true,
+		},
+		{
+			name:    "target older than source",
+			source:  neo4jServerInfo{Version: "5.26.1"},
+			target:  neo4jServerInfo{Version: "5.20.0"},
+			edition: neo4jEditionEnterprise,
+			wantErr: true,
+		},
+		{
+			name:    "patch downgrade",
+			source:  neo4jServerInfo{Version: "5.26.2"},
+			target:  neo4jServerInfo{Version: "5.26.1"},
+			edition: neo4jEditionEnterprise,
+		},
+		{
+			name:        "major upgrade enables migration on enterprise",
+			source:      neo4jServerInfo{Version: "4.4.30"},
+			target:      neo4jServerInfo{Version: "5.26.1"},
+			edition:     neo4jEditionEnterprise,
+			wantMigrate: true,
+		},
+		{
+			name:    "major upgrade on community only warns",
+			source:  neo4jServerInfo{Version: "4.4.30"},
+			target:  neo4jServerInfo{Version: "5.26.1"},
+			edition: neo4jEditionCommunity,
+		},
+		{
+			name:    "block format on community",
+			source:  neo4jServerInfo{Version: "5.26.1", StoreFormat: "block-block-1.1"},
+			target:  neo4jServerInfo{Version: "5.26.1"},
+			edition: neo4jEditionCommunity,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrate, err := planNeo4jRestore(tt.source, tt.target, tt.edition, tt.migrate)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("planNeo4jRestore() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err == nil && migrate != tt.wantMigrate {
+				t.Errorf("planNeo4jRestore() migrate = %t, want %t", migrate, tt.wantMigrate)
+			}
+		})
+	}
+}
+
+func TestDetectNeo4jServerInfoQuotesDatabase(t *testing.T) {
+	fake := newFakeExecutor().
+		on("dbms.components", "versions[0]\n\"5.26.1\"\n", nil).
+		on("SHOW DATABASE", "store\n\"block-block-1.1\"\n", nil)
+	iops := newFakeDockerOps(fake)
+	iops.config.Neo4jDatabase = "infrahub-prod"
+
+	info, err := iops.detectNeo4jServerInfo()
+	if err != nil {
+		t.Fatalf("detectNeo4jServerInfo() error = %v", err)
+	}
+	if info.Version != "5.26.1" || info.StoreFormat != "block-block-1.1" {
+		t.Errorf("detectNeo4jServerInfo() = %+v", info)
+	}
+	if got := fake.commands("SHOW DATABASE `infrahub-prod` YIELD store"); len(got) != 1 {
+		t.Errorf("database name not quoted: %v", fake.commands("SHOW DATABASE"))
+	}
+}
+
+func TestQuoteCypherName(t *testing.T) {
+	for name, want := range map[string]string{
+		"neo4j":         "`neo4j`",
+		"infrahub-prod": "`infrahub-prod`",
+		"odd`name":      "`odd``name`",
+	} {
+		if got := quoteCypherName(name); got != want {
+			t.Errorf("quoteCypherName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
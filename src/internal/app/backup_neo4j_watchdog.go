@@ -10,7 +10,6 @@ import (
 )
 
 const (
-	neo4jPIDFile              = "/var/lib/neo4j/run/neo4j.pid"
 	neo4jRemoteWorkDir        = "/tmp/infrahubops"
 	neo4jRemoteWatchdogBinary = neo4jRemoteWorkDir + "/neo4j_watchdog"
 	neo4jRemoteWatchdogReady  = neo4jRemoteWorkDir + "/neo4j_watchdog.ready"
@@ -55,6 +54,78 @@ func writeEmbeddedWatchdog(content []byte) (string, func(), error) {
 	return file.Name(), cleanup, nil
 }
 
+// deployWatchdog copies the embedded watchdog matching the database
+// container's architecture into the container and returns that architecture.
+func (iops *InfrahubOps) deployWatchdog() (string, error) {
+	if _, err := iops.Exec("database", []string{"mkdir", "-p", neo4jRemoteWorkDir}, nil); err != nil {
+		return "", fmt.Errorf("failed to prepare remote work directory: %w", err)
+	}
+
+	arch, err := iops.detectNeo4jArchitecture()
+	if err != nil {
+		return "", err
+	}
+
+	watchdogBytes, err := selectWatchdogBinary(arch)
+	if err != nil {
+		return "", err
+	}
+
+	localWatchdog, cleanup, err := writeEmbeddedWatchdog(watchdogBytes)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+
+	if err := iops.CopyTo("database", localWatchdog, neo4jRemoteWatchdogBinary); err != nil {
+		return "", fmt.Errorf("failed to deploy watchdog binary: %w", err)
+	}
+
+	if _, err := iops.Exec("database", []string{"chmod", "+x", neo4jRemoteWatchdogBinary}, nil); err != nil {
+		return "", fmt.Errorf("failed to mark watchdog executable: %w", err)
+	}
+	return arch, nil
+}
+
+// selfTestWatchdog runs the deployed watchdog in self-test mode. It fails when
+// the binary cannot execute in the container (wrong architecture, noexec
+// mount) or inotify is unavailable.
+func (iops *InfrahubOps) selfTestWatchdog(arch string) error {
+	output, err := iops.Exec("database", []string{neo4jRemoteWatchdogBinary, "-self-test"}, nil)
+	if err != nil || !strings.HasPrefix(strings.TrimSpace(output), "ok ") {
+		if err == nil {
+			err = fmt.Errorf("unexpected output")
+		}
+		return fmt.Errorf("watchdog self-test failed on %s: %w\nOutput: %v", arch, err, strings.TrimSpace(output))
+	}
+	logrus.Debugf("Watchdog self-test passed: %s", strings.TrimSpace(output))
+	return nil
+}
+
+// WatchdogSelfTest deploys the embedded watchdog into the database container,
+// runs its self-test and removes it again. Nothing else is touched.
+func (iops *InfrahubOps) WatchdogSelfTest() error {
+	if _, err := iops.ensureBackend(); err != nil {
+		return err
+	}
+
+	arch, err := iops.deployWatchdog()
+	defer func() {
+		if _, err := iops.Exec("database", []string{"rm", "-f", neo4jRemoteWatchdogBinary}, nil); err != nil {
+			logrus.Debugf("Failed to remove watchdog binary: %v", err)
+		}
+	}()
+	if err != nil {
+		return err
+	}
+
+	if err := iops.selfTestWatchdog(arch); err != nil {
+		return err
+	}
+	logrus.Infof("Watchdog runs in the database container (%s)", arch)
+	return nil
+}
+
 func (iops *InfrahubOps) waitForRemoteFile(path string, timeout time.Duration) error {
 	deadline := time.Now().Add(timeout)
 	for {
@@ -71,6 +142,8 @@ func (iops *InfrahubOps) waitForRemoteFile(path string, timeout time.Duration) e
 
 func (iops *InfrahubOps) waitForProcessStopped(pid string, timeout time.Duration) error {
 	deadline := time.Now().Add(timeout)
+	poll := iops.newPoller(fmt.Sprintf("neo4j process %s to stop", pid), time.Second, 5*time.Second)
+	defer poll.done()
 	for {
 		stateCmd := fmt.Sprintf("sed -n 's/^State:\t//p' /proc/%s/status", pid)
 		state, err := iops.Exec("database", []string{"sh", "-c", stateCmd}, nil)
@@ -79,11 +152,12 @@ func (iops *InfrahubOps) waitForProcessStopped(pid string, timeout time.Duration
 			if strings.HasPrefix(trimmed, "T") {
 				return nil
 			}
+			poll.status("state " + trimmed)
 		}
 		if time.Now().After(deadline) {
 			break
 		}
-		time.Sleep(1 * time.Second)
+		poll.wait(deadline)
 	}
 	return fmt.Errorf("timed out waiting for neo4j process %s to stop", pid)
 }
@@ -0,0 +1,67 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSelectWatchdogBinary(t *testing.T) {
+	tests := []struct {
+		arch    string
+		want    []byte
+		wantErr bool
+	}{
+		{arch: "x86_64", want: neo4jWatchdogLinuxAMD64},
+		{arch: "amd64", want: neo4jWatchdogLinuxAMD64},
+		{arch: "aarch64", want: neo4jWatchdogLinuxARM64},
+		{arch: "ARM64", want: neo4jWatchdogLinuxARM64},
+		{arch: "armv7l", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.arch, func(t *testing.T) {
+			got, err := selectWatchdogBinary(tt.arch)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("selectWatchdogBinary(%q) error = %v, wantErr %v", tt.arch, err, tt.wantErr)
+			}
+			if !tt.wantErr && !bytes.Equal(got, tt.want) {
+				t.Errorf("selectWatchdogBinary(%q) returned the wrong binary", tt.arch)
+			}
+		})
+	}
+}
+
+func TestWatchdogSelfTest(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		err     error
+		wantErr string
+	}{
+		{name: "passes", output: "ok linux/arm64\n"},
+		{name: "wrong architecture", output: "exec format error", err: errors.New("exit status 126"), wantErr: "watchdog self-test failed on aarch64"},
+		{name: "unexpected output", output: "Usage of neo4j_watchdog", wantErr: "unexpected output"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeExecutor().
+				on("uname -m", "aarch64\n", nil).
+				on("-self-test", tt.output, tt.err)
+			iops := newFakeDockerOps(fake)
+
+			err := iops.WatchdogSelfTest()
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("WatchdogSelfTest() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("WatchdogSelfTest() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+			if got := fake.commands("rm -f " + neo4jRemoteWatchdogBinary); len(got) != 1 {
+				t.Errorf("watchdog cleanup commands = %v, want one", got)
+			}
+		})
+	}
+}
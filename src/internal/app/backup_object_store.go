@@ -0,0 +1,144 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// objectStoreService is the optional MinIO service holding Infrahub
+	// artifacts. It is also the component name recorded in backup metadata.
+	objectStoreService = "object-store"
+	objectStoreDirName = "object-store"
+
+	// objectStoreAlias is the mc alias configured inside the container. The mc
+	// configuration lives in a temporary directory so nothing is left behind
+	// in the container's home directory.
+	objectStoreAlias    = "infrahubops"
+	objectStoreEndpoint = "http://localhost:9000"
+)
+
+// objectStoreAliasScript returns the shell prefix that points mc at the local
+// MinIO server using the root credentials from the container environment.
+func objectStoreAliasScript(configDir string) string {
+	return fmt.Sprintf(`export MC_CONFIG_DIR=%s && mc alias set %s %s "$MINIO_ROOT_USER" "$MINIO_ROOT_PASSWORD" >/dev/null`,
+		shellQuote(configDir), objectStoreAlias, objectStoreEndpoint)
+}
+
+// objectStoreMirrorOutScript mirrors every bucket into dumpDir, one
+// sub-directory per bucket.
+func objectStoreMirrorOutScript(configDir, dumpDir string) string {
+	return strings.Join([]string{
+		objectStoreAliasScript(configDir),
+		"mkdir -p " + shellQuote(dumpDir),
+		fmt.Sprintf("mc mirror --quiet --overwrite %s/ %s", objectStoreAlias, shellQuote(dumpDir)),
+	}, " && ")
+}
+
+// objectStoreMirrorInScript recreates each bucket found in dumpDir and mirrors
+// its objects back. Objects that are not part of the backup are kept.
+func objectStoreMirrorInScript(configDir, dumpDir string) string {
+	return strings.Join([]string{
+		objectStoreAliasScript(configDir),
+		fmt.Sprintf(`for dir in %s/*/; do [ -d "$dir" ] || continue; bucket=$(basename "$dir"); mc mb --ignore-existing %s/"$bucket" >/dev/null && mc mirror --quiet --overwrite "$dir" %s/"$bucket" || exit 1; done`,
+			shellQuote(dumpDir), objectStoreAlias, objectStoreAlias),
+	}, " && ")
+}
+
+// objectStoreRunning reports whether the deployment runs the optional object
+// store service. A status that cannot be read is an error rather than "not
+// running", so the object store is never left out silently.
+func (iops *InfrahubOps) objectStoreRunning() (bool, error) {
+	running, err := iops.RunningServices(objectStoreService)
+	if err != nil {
+		return false, fmt.Errorf("failed to check the %s service: %w", objectStoreService, err)
+	}
+	return running[objectStoreService], nil
+}
+
+// backupObjectStore copies every object store bucket into
+// backupDir/object-store, leaving out the objects the filter skips.
+func (iops *InfrahubOps) backupObjectStore(backupDir string, filter *ArtifactFilter) error {
+	logrus.Info("Backing up object store...")
+
+	tempDir := iops.getWritableTempDir(objectStoreService)
+	configDir := tempDir + "/infrahubops_mc"
+	dumpDir := tempDir + "/infrahubops_object_store"
+	defer func() {
+		if _, err := iops.Exec(objectStoreService, []string{"rm", "-rf", configDir, dumpDir}, nil); err != nil {
+			logrus.Warnf("Failed to remove temporary object store files: %v", err)
+		}
+	}()
+
+	if output, err := iops.Exec(objectStoreService, []string{"sh", "-c", objectStoreMirrorOutScript(configDir, dumpDir)}, nil); err != nil {
+		return fmt.Errorf("failed to mirror object store buckets: %w\nOutput: %v", err, output)
+	}
+
+	if err := iops.applyArtifactFilter(filter, dumpDir); err != nil {
+		return err
+	}
+
+	iops.throttle.wait("copying the object store")
+	if err := iops.CopyFrom(objectStoreService, dumpDir, filepath.Join(backupDir, objectStoreDirName)); err != nil {
+		return fmt.Errorf("failed to copy object store contents: %w", err)
+	}
+
+	logrus.Info("Object store backup completed")
+	return nil
+}
+
+// restoreObjectStore mirrors the buckets saved in the backup back into the
+// object store service.
+func (iops *InfrahubOps) restoreObjectStore(workDir string) error {
+	logrus.Info("Restoring object store...")
+
+	sourceDir := filepath.Join(workDir, "backup", objectStoreDirName)
+	tempDir := iops.getWritableTempDir(objectStoreService)
+	configDir := tempDir + "/infrahubops_mc"
+	dumpDir := tempDir + "/infrahubops_object_store"
+	defer func() {
+		if _, err := iops.Exec(objectStoreService, []string{"rm", "-rf", configDir, dumpDir}, nil); err != nil {
+			logrus.Warnf("Failed to remove temporary object store files: %v", err)
+		}
+	}()
+
+	if err := iops.CopyTo(objectStoreService, sourceDir, dumpDir); err != nil {
+		return fmt.Errorf("failed to copy object store contents: %w", err)
+	}
+
+	if output, err := iops.Exec(objectStoreService, []string{"sh", "-c", objectStoreMirrorInScript(configDir, dumpDir)}, nil); err != nil {
+		return fmt.Errorf("failed to restore object store buckets: %w\nOutput: %v", err, output)
+	}
+
+	logrus.Info("Object store restore completed")
+	return nil
+}
+
+// planObjectStoreRestore decides whether the object store is restored. A
+// backup that contains the component but a target without the service only
+// produces a warning, since artifacts can be regenerated.
+func (iops *InfrahubOps) planObjectStoreRestore(workDir string, metadata *BackupMetadata) (bool, error) {
+	if !metadata.hasComponent(objectStoreService) {
+		return false, nil
+	}
+	if _, err := os.Stat(filepath.Join(workDir, "backup", objectStoreDirName)); err != nil {
+		logrus.Warnf("Backup metadata includes the object store but %s is missing; skipping object store restore", objectStoreDirName)
+		return false, nil
+	}
+	running, err := iops.objectStoreRunning()
+	if err != nil {
+		return false, err
+	}
+	if !running {
+		logrus.Warnf("Backup includes the object store but no running %s service was found; artifacts will not be restored", objectStoreService)
+		return false, nil
+	}
+	if filter := metadata.ArtifactFilter; filter != nil && len(filter.Excluded) > 0 {
+		logrus.Warnf("%d artifacts were excluded from this backup and must be regenerated after restore", len(filter.Excluded))
+	}
+	return true, nil
+}
@@ -0,0 +1,97 @@
+package app
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestObjectStoreScripts(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{
+			name:   "mirror out",
+			script: objectStoreMirrorOutScript("/tmp/infrahubops_mc", "/tmp/infrahubops_object_store"),
+			want: []string{
+				"export MC_CONFIG_DIR=/tmp/infrahubops_mc && ",
+				`mc alias set infrahubops http://localhost:9000 "$MINIO_ROOT_USER" "$MINIO_ROOT_PASSWORD"`,
+				"mkdir -p /tmp/infrahubops_object_store",
+				"mc mirror --quiet --overwrite infrahubops/ /tmp/infrahubops_object_store",
+			},
+		},
+		{
+			name:   "mirror in",
+			script: objectStoreMirrorInScript("/run/infrahubops_mc", "/run/infrahubops_object_store"),
+			want: []string{
+				"export MC_CONFIG_DIR=/run/infrahubops_mc && ",
+				"for dir in /run/infrahubops_object_store/*/; do",
+				`mc mb --ignore-existing infrahubops/"$bucket"`,
+				`mc mirror --quiet --overwrite "$dir" infrahubops/"$bucket" || exit 1`,
+			},
+		},
+		{
+			name:   "quotes paths",
+			script: objectStoreMirrorOutScript("/tmp/mc dir", "/tmp/dump dir"),
+			want: []string{
+				"export MC_CONFIG_DIR='/tmp/mc dir'",
+				"mkdir -p '/tmp/dump dir'",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, want := range tt.want {
+				if !strings.Contains(tt.script, want) {
+					t.Errorf("script %q does not contain %q", tt.script, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCalculateBackupChecksumsIncludesObjectStore(t *testing.T) {
+	backupDir := t.TempDir()
+	writeTestFile(t, backupDir, "database/neo4j.backup", "graph")
+	writeTestFile(t, backupDir, "object-store/infrahub-storage/artifacts/abc", "artifact")
+
+	checksums, err := calculateBackupChecksums(backupDir, true, checksumSHA256)
+	if err != nil {
+		t.Fatalf("calculateBackupChecksums() error = %v", err)
+	}
+	if _, ok := checksums["object-store/infrahub-storage/artifacts/abc"]; !ok {
+		t.Errorf("object store file missing from checksums: %v", checksums)
+	}
+}
+
+func writeTestFile(t *testing.T, baseDir, relPath, content string) {
+	t.Helper()
+	path := filepath.Join(baseDir, relPath)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestObjectStoreStatusFailure(t *testing.T) {
+	fake := newFakeExecutor().on("ps -a --format json", "", errors.New("Cannot connect to the Docker daemon"))
+	iops := newFakeDockerOps(fake)
+
+	if _, err := iops.objectStoreRunning(); err == nil {
+		t.Error("objectStoreRunning() error = nil, want status failure")
+	}
+
+	workDir := t.TempDir()
+	writeTestFile(t, workDir, filepath.Join("backup", objectStoreDirName, "artifacts", "a.txt"), "artifact")
+	metadata := &BackupMetadata{Components: []string{objectStoreService}}
+	if restore, err := iops.planObjectStoreRestore(workDir, metadata); err == nil || restore {
+		t.Errorf("planObjectStoreRestore() = %t, %v; want status failure", restore, err)
+	}
+}
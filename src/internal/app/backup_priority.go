@@ -0,0 +1,96 @@
+package app
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxNiceLevel is the lowest scheduling priority nice accepts.
+const maxNiceLevel = 19
+
+// priorityPrefix builds the nice/ionice prefix for the backup commands run in
+// the database containers. nice is a niceness between 0 (unchanged) and 19.
+// ionice is empty, "idle", "best-effort" or "best-effort:<0-7>".
+func priorityPrefix(nice int, ionice string) ([]string, error) {
+	if nice < 0 || nice > maxNiceLevel {
+		return nil, fmt.Errorf("invalid --nice %d: must be between 0 and %d", nice, maxNiceLevel)
+	}
+
+	var prefix []string
+	if ionice = strings.TrimSpace(strings.ToLower(ionice)); ionice != "" {
+		class, level, hasLevel := strings.Cut(ionice, ":")
+		switch class {
+		case "idle":
+			if hasLevel {
+				return nil, fmt.Errorf("invalid --ionice %q: the idle class takes no level", ionice)
+			}
+			prefix = append(prefix, "ionice", "-c", "3")
+		case "best-effort":
+			prefix = append(prefix, "ionice", "-c", "2")
+			if hasLevel {
+				n, err := strconv.Atoi(level)
+				if err != nil || n < 0 || n > 7 {
+					return nil, fmt.Errorf("invalid --ionice %q: level must be between 0 and 7", ionice)
+				}
+				prefix = append(prefix, "-n", level)
+			}
+		default:
+			return nil, fmt.Errorf("invalid --ionice %q: expected idle, best-effort or best-effort:<0-7>", ionice)
+		}
+	}
+	if nice > 0 {
+		prefix = append(prefix, "nice", "-n", strconv.Itoa(nice))
+	}
+	return prefix, nil
+}
+
+// validateBackupPriority rejects invalid --nice and --ionice values before a
+// backup starts.
+func (iops *InfrahubOps) validateBackupPriority() error {
+	_, err := priorityPrefix(iops.config.Nice, iops.config.IONice)
+	return err
+}
+
+// lowPriority wraps cmd with the configured nice/ionice prefix. Tools missing
+// from the service container are dropped with a warning, so images without
+// ionice (or nice) still back up at normal priority.
+func (iops *InfrahubOps) lowPriority(service string, cmd []string) []string {
+	prefix, err := priorityPrefix(iops.config.Nice, iops.config.IONice)
+	if err != nil || len(prefix) == 0 {
+		return cmd
+	}
+
+	output, _ := iops.Exec(service, []string{"sh", "-c", "command -v ionice; command -v nice; true"}, nil)
+	available := map[string]bool{}
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			available[path.Base(line)] = true
+		}
+	}
+	return applyPriorityPrefix(prefix, available, cmd, func(tool string) {
+		logrus.Warnf("%s is not available in the %s container; running %s without it", tool, service, cmd[0])
+	})
+}
+
+// applyPriorityPrefix prepends the parts of prefix whose tool is available.
+func applyPriorityPrefix(prefix []string, available map[string]bool, cmd []string, missing func(tool string)) []string {
+	var wrapped []string
+	for i := 0; i < len(prefix); {
+		tool := prefix[i]
+		j := i + 1
+		for j < len(prefix) && prefix[j] != "ionice" && prefix[j] != "nice" {
+			j++
+		}
+		if available[tool] {
+			wrapped = append(wrapped, prefix[i:j]...)
+		} else {
+			missing(tool)
+		}
+		i = j
+	}
+	return append(wrapped, cmd...)
+}
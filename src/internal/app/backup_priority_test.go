@@ -0,0 +1,82 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPriorityPrefix(t *testing.T) {
+	tests := []struct {
+		name    string
+		nice    int
+		ionice  string
+		want    []string
+		wantErr bool
+	}{
+		{name: "disabled"},
+		{name: "nice only", nice: 10, want: []string{"nice", "-n", "10"}},
+		{name: "idle", ionice: "idle", want: []string{"ionice", "-c", "3"}},
+		{name: "best effort", ionice: "best-effort", want: []string{"ionice", "-c", "2"}},
+		{name: "best effort level", nice: 19, ionice: "Best-Effort:7", want: []string{"ionice", "-c", "2", "-n", "7", "nice", "-n", "19"}},
+		{name: "negative nice", nice: -5, wantErr: true},
+		{name: "nice too high", nice: 20, wantErr: true},
+		{name: "idle with level", ionice: "idle:3", wantErr: true},
+		{name: "level out of range", ionice: "best-effort:8", wantErr: true},
+		{name: "realtime", ionice: "realtime", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := priorityPrefix(tt.nice, tt.ionice)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("priorityPrefix() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("priorityPrefix() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyPriorityPrefix(t *testing.T) {
+	prefix := []string{"ionice", "-c", "3", "nice", "-n", "10"}
+	cmd := []string{"pg_dump", "-Fc"}
+
+	tests := []struct {
+		name        string
+		available   map[string]bool
+		want        []string
+		wantMissing []string
+	}{
+		{
+			name:      "both available",
+			available: map[string]bool{"ionice": true, "nice": true},
+			want:      []string{"ionice", "-c", "3", "nice", "-n", "10", "pg_dump", "-Fc"},
+		},
+		{
+			name:        "ionice missing",
+			available:   map[string]bool{"nice": true},
+			want:        []string{"nice", "-n", "10", "pg_dump", "-Fc"},
+			wantMissing: []string{"ionice"},
+		},
+		{
+			name:        "none available",
+			available:   map[string]bool{},
+			want:        []string{"pg_dump", "-Fc"},
+			wantMissing: []string{"ionice", "nice"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var missing []string
+			got := applyPriorityPrefix(prefix, tt.available, cmd, func(tool string) { missing = append(missing, tool) })
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("applyPriorityPrefix() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(missing, tt.wantMissing) {
+				t.Errorf("missing = %v, want %v", missing, tt.wantMissing)
+			}
+		})
+	}
+}
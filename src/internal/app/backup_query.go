@@ -0,0 +1,87 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Output formats of query.
+const (
+	QueryFormatTable = "table"
+	QueryFormatPlain = "plain"
+)
+
+// queryShellFormats maps the query formats to cypher-shell formats.
+var queryShellFormats = map[string]string{
+	QueryFormatTable: "verbose",
+	QueryFormatPlain: "plain",
+}
+
+// QueryOptions is a Cypher query run against the graph of a backup.
+type QueryOptions struct {
+	Cypher     string
+	Format     string // QueryFormatTable or QueryFormatPlain
+	Neo4jImage string // image loading the backup; derived from the metadata when empty
+}
+
+// Validate checks the query options before the backup is read.
+func (o QueryOptions) Validate() error {
+	if strings.TrimSpace(o.Cypher) == "" {
+		return fmt.Errorf("--cypher is required")
+	}
+	if _, ok := queryShellFormats[o.Format]; !ok {
+		return fmt.Errorf("--format must be %s or %s, got %q", QueryFormatTable, QueryFormatPlain, o.Format)
+	}
+	return nil
+}
+
+// QueryBackup loads the Neo4j backup of backupFile into a throwaway
+// container whose databases are read-only, like --rehearse, runs the query
+// against it and writes the result to w. The container is removed afterwards
+// and the live deployment is never touched.
+func (iops *InfrahubOps) QueryBackup(backupFile, decryptKey string, opts QueryOptions, w io.Writer) error {
+	if iops.config.Backend == BackendPlakar {
+		return fmt.Errorf("query is not supported with the plakar backend")
+	}
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	if err := iops.executor.runCommandQuiet("docker", "version"); err != nil {
+		return fmt.Errorf("query needs a working docker CLI on this host: %w", err)
+	}
+
+	workDir, metadata, cleanup, err := iops.extractBackupForRehearsal(backupFile, decryptKey, true)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	neo4jImage := opts.Neo4jImage
+	if neo4jImage == "" {
+		if neo4jImage, err = rehearsalNeo4jImage(metadata); err != nil {
+			return err
+		}
+	}
+	r, err := newRehearsal(iops.executor)
+	if err != nil {
+		return err
+	}
+	r.readOnly = true
+	defer r.cleanup()
+
+	logrus.WithFields(logrus.Fields{"backup_id": metadata.BackupID, "neo4j_image": neo4jImage}).Info("Loading the backup into a read-only Neo4j container...")
+	if _, err := r.rehearseNeo4j(filepath.Join(workDir, "backup", neo4jBackupDirName), neo4jImage, metadata.Neo4jEdition); err != nil {
+		return fmt.Errorf("failed to load the backup: %w", err)
+	}
+
+	output, err := r.cypherShell(queryShellFormats[opts.Format], opts.Cypher)
+	if err != nil {
+		return fmt.Errorf("query failed: %w\nOutput: %v", err, strings.TrimSpace(output))
+	}
+	_, err = io.WriteString(w, output)
+	return err
+}
@@ -0,0 +1,83 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestQueryBackup(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       QueryOptions
+		queryErr   error
+		wantFormat string
+		wantOutput string
+		wantErr    string
+	}{
+		{
+			name:       "table",
+			opts:       QueryOptions{Cypher: "MATCH (n:InfraDevice) RETURN n.uuid", Format: QueryFormatTable, Neo4jImage: "neo4j:5.26-community"},
+			wantFormat: "--format verbose",
+			wantOutput: "| n.uuid |\n",
+		},
+		{
+			name:       "plain",
+			opts:       QueryOptions{Cypher: "MATCH (n:InfraDevice) RETURN n.uuid", Format: QueryFormatPlain, Neo4jImage: "neo4j:5.26-community"},
+			wantFormat: "--format plain",
+			wantOutput: "| n.uuid |\n",
+		},
+		{
+			name:     "failed query",
+			opts:     QueryOptions{Cypher: "MATCH (n:InfraDevice) RETURN n.uuid", Format: QueryFormatTable, Neo4jImage: "neo4j:5.26-community"},
+			queryErr: errors.New("exit status 1"),
+			wantErr:  "query failed",
+		},
+		{
+			name:    "no query",
+			opts:    QueryOptions{Format: QueryFormatTable},
+			wantErr: "--cypher is required",
+		},
+		{
+			name:    "unknown format",
+			opts:    QueryOptions{Cypher: "RETURN 1", Format: "csv"},
+			wantErr: "--format",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeExecutor().
+				on("RETURN count(n)", "count(n)\n12\n", nil).
+				on("RETURN n.uuid", "| n.uuid |\n", tt.queryErr)
+			iops := NewInfrahubOpsWithExecutor(fake)
+			iops.config.BackupDir = t.TempDir()
+			path := writeVerifiableBackup(t, iops, "query-test", false)
+
+			var out bytes.Buffer
+			err := iops.QueryBackup(path, "", tt.opts, &out)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("QueryBackup() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.wantOutput {
+				t.Errorf("output = %q, want %q", out.String(), tt.wantOutput)
+			}
+			queries := fake.commands("RETURN n.uuid")
+			if len(queries) != 1 || !strings.Contains(queries[0], tt.wantFormat+" --access-mode read") {
+				t.Errorf("query commands = %v, want %q in read access mode", queries, tt.wantFormat)
+			}
+			if len(fake.commands("NEO4J_server_databases_default__to__read__only=true")) != 1 {
+				t.Errorf("Neo4j not started read-only: %v", fake.commands("docker run"))
+			}
+			if len(fake.commands("docker rm -f -v")) == 0 {
+				t.Error("throwaway containers not removed")
+			}
+		})
+	}
+}
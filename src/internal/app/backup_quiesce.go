@@ -0,0 +1,230 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// quiesceStateFilename records in BackupDir what quiesce changed, so
+	// unquiesce undoes exactly that.
+	quiesceStateFilename = ".infrahub_quiesce.json"
+
+	// neo4jStartTimeout bounds the wait for a restarted Neo4j container to
+	// accept connections.
+	neo4jStartTimeout = 5 * time.Minute
+)
+
+var neo4jStartPollInterval = 2 * time.Second
+
+// quiesceState is what quiesce stopped.
+type quiesceState struct {
+	QuiescedAt           string   `json:"quiesced_at"`
+	StoppedServices      []string `json:"stopped_services"`
+	Neo4jDatabaseStopped bool     `json:"neo4j_database_stopped,omitempty"`
+	Neo4jServiceStopped  bool     `json:"neo4j_service_stopped,omitempty"`
+}
+
+func (iops *InfrahubOps) quiesceStatePath() string {
+	return filepath.Join(iops.config.BackupDir, quiesceStateFilename)
+}
+
+func (iops *InfrahubOps) loadQuiesceState() (*quiesceState, error) {
+	data, err := os.ReadFile(iops.quiesceStatePath())
+	if err != nil {
+		return nil, err
+	}
+	state := &quiesceState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("invalid quiesce state %s: %w", iops.quiesceStatePath(), err)
+	}
+	return state, nil
+}
+
+func (iops *InfrahubOps) saveQuiesceState(state *quiesceState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(iops.config.BackupDir, 0755); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	return os.WriteFile(iops.quiesceStatePath(), data, 0644)
+}
+
+// Quiesce brings the deployment to a state where an external snapshot of its
+// volumes is application consistent: application services are stopped, Neo4j
+// and PostgreSQL are checkpointed, and the Neo4j database is stopped (the
+// whole database service on Community Edition). Unquiesce undoes it.
+func (iops *InfrahubOps) Quiesce(force bool) error {
+	if state, err := iops.loadQuiesceState(); err == nil {
+		return fmt.Errorf("deployment is already quiesced since %s; run unquiesce first", state.QuiescedAt)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if err := iops.DetectEnvironment(); err != nil {
+		return err
+	}
+	editionInfo := iops.detectNeo4jEditionInfo("quiesce")
+
+	if !force {
+		if err := iops.waitForRunningTasks(); err != nil {
+			return err
+		}
+	}
+
+	state := &quiesceState{QuiescedAt: time.Now().UTC().Format(time.RFC3339)}
+	fail := func(err error) error {
+		if undoErr := iops.undoQuiesce(state); undoErr != nil {
+			logrus.Errorf("Failed to undo partial quiesce: %v", undoErr)
+		}
+		return err
+	}
+
+	stopped, err := iops.stopAppContainers()
+	state.StoppedServices = stopped
+	if err != nil {
+		return fail(err)
+	}
+
+	iops.checkpointPostgres()
+	if err := iops.checkpointNeo4j(); err != nil {
+		return fail(err)
+	}
+
+	if editionInfo.IsCommunity {
+		logrus.Info("Stopping the database service (Community Edition cannot stop a single database)...")
+		if err := iops.StopServices("database"); err != nil {
+			return fail(fmt.Errorf("failed to stop database: %w", err))
+		}
+		state.Neo4jServiceStopped = true
+	} else {
+		if err := iops.runNeo4jSystemCommand("STOP DATABASE " + iops.config.Neo4jDatabase); err != nil {
+			return fail(fmt.Errorf("failed to stop neo4j database: %w", err))
+		}
+		state.Neo4jDatabaseStopped = true
+	}
+
+	for _, service := range []string{"database", "task-manager-db"} {
+		if service == "database" && state.Neo4jServiceStopped {
+			continue
+		}
+		if _, err := iops.Exec(service, []string{"sync"}, nil); err != nil {
+			logrus.Warnf("Failed to flush file system buffers in %s: %v", service, err)
+		}
+	}
+
+	if err := iops.saveQuiesceState(state); err != nil {
+		return fail(fmt.Errorf("failed to record quiesce state: %w", err))
+	}
+	logrus.WithField("stopped_services", strings.Join(state.StoppedServices, ",")).
+		Info("Deployment quiesced; take the snapshot, then run unquiesce")
+	return nil
+}
+
+// Unquiesce restarts what Quiesce stopped, as recorded in BackupDir.
+func (iops *InfrahubOps) Unquiesce() error {
+	state, err := iops.loadQuiesceState()
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("no quiesce state in %s; the deployment was not quiesced from this backup directory", iops.config.BackupDir)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := iops.undoQuiesce(state); err != nil {
+		return err
+	}
+	if err := os.Remove(iops.quiesceStatePath()); err != nil {
+		logrus.Warnf("Failed to remove quiesce state: %v", err)
+	}
+	logrus.Info("Deployment unquiesced")
+	return nil
+}
+
+// undoQuiesce restarts Neo4j and then the application services in state.
+func (iops *InfrahubOps) undoQuiesce(state *quiesceState) error {
+	if _, err := iops.ensureBackend(); err != nil {
+		return err
+	}
+	if state.Neo4jServiceStopped {
+		logrus.Info("Starting the database service...")
+		if err := iops.StartServices("database"); err != nil {
+			return fmt.Errorf("failed to start database: %w", err)
+		}
+		if err := iops.waitForNeo4jStart(neo4jStartTimeout); err != nil {
+			return err
+		}
+	}
+	if state.Neo4jDatabaseStopped {
+		if !iops.hasNeo4jCredentials() {
+			if err := iops.fetchDatabaseCredentials(); err != nil {
+				return fmt.Errorf("could not fetch database credentials: %w", err)
+			}
+		}
+		if err := iops.runNeo4jSystemCommand("START DATABASE " + iops.config.Neo4jDatabase); err != nil {
+			return fmt.Errorf("failed to start neo4j database: %w", err)
+		}
+	}
+	return iops.startAppContainers(state.StoppedServices)
+}
+
+// checkpointNeo4j flushes the Neo4j transaction logs to the store files.
+func (iops *InfrahubOps) checkpointNeo4j() error {
+	logrus.Info("Checkpointing Neo4j...")
+	if output, err := iops.Exec("database", []string{
+		"cypher-shell", "-u", iops.config.Neo4jUsername, "-p" + iops.config.Neo4jPassword,
+		"-d", iops.config.Neo4jDatabase, "--format", "plain", "CALL db.checkpoint()",
+	}, nil); err != nil {
+		return fmt.Errorf("failed to checkpoint neo4j: %w\nOutput: %v", err, output)
+	}
+	return nil
+}
+
+// checkpointPostgres flushes the task manager database to its data files. A
+// failure is only logged: PostgreSQL recovers from its WAL either way.
+func (iops *InfrahubOps) checkpointPostgres() {
+	logrus.Info("Checkpointing the task manager database...")
+	opts := &ExecOptions{Env: map[string]string{"PGPASSWORD": iops.config.PostgresPassword}}
+	if output, err := iops.Exec("task-manager-db", []string{
+		"psql", "-h", "localhost", "-U", iops.config.PostgresUsername, "-d", iops.config.PostgresDatabase, "-c", "CHECKPOINT",
+	}, opts); err != nil {
+		logrus.Warnf("Failed to checkpoint the task manager database: %v\nOutput: %v", err, output)
+	}
+}
+
+func (iops *InfrahubOps) runNeo4jSystemCommand(query string) error {
+	output, err := iops.Exec("database", []string{
+		"cypher-shell", "-u", iops.config.Neo4jUsername, "-p" + iops.config.Neo4jPassword, "-d", "system", query,
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("%w\nOutput: %v", err, output)
+	}
+	return nil
+}
+
+// waitForNeo4jStart waits until Neo4j answers cypher-shell. A rejected login
+// counts as started, since credentials are resolved afterwards.
+func (iops *InfrahubOps) waitForNeo4jStart(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		output, err := iops.Exec("database", []string{
+			"cypher-shell", "-u", iops.config.Neo4jUsername, "-p" + iops.config.Neo4jPassword, "-d", "system", "--format", "plain", "RETURN 1",
+		}, nil)
+		if err == nil || isNeo4jAuthFailure(output) {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("neo4j did not accept connections within %s: %w", timeout, err)
+		}
+		time.Sleep(neo4jStartPollInterval)
+	}
+}
@@ -0,0 +1,111 @@
+package app
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestQuiesceAndUnquiesce(t *testing.T) {
+	tests := []struct {
+		name         string
+		edition      string
+		wantStop     string
+		wantStart    string
+		wantNoStop   string
+		wantNoDBSync bool
+	}{
+		{
+			name:       "enterprise",
+			edition:    "enterprise",
+			wantStop:   "STOP DATABASE neo4j",
+			wantStart:  "START DATABASE neo4j",
+			wantNoStop: "stop database",
+		},
+		{
+			name:         "community",
+			edition:      "community",
+			wantStop:     "docker compose -p test stop database",
+			wantStart:    "docker compose -p test start database",
+			wantNoStop:   "STOP DATABASE",
+			wantNoDBSync: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeExecutor().
+				on("dbms.components", "edition\n\""+tt.edition+"\"", nil).
+				on("ps -a --format json", `[{"Service":"infrahub-server","State":"running"},{"Service":"cache","State":"running"}]`, nil)
+			iops := newFakeDockerOps(fake)
+			iops.config.BackupDir = t.TempDir()
+			iops.config.Neo4jUsername = "neo4j"
+			iops.config.Neo4jPassword = "secret"
+			iops.config.Neo4jDatabase = "neo4j"
+
+			if err := iops.Quiesce(true); err != nil {
+				t.Fatalf("Quiesce() error = %v", err)
+			}
+			if got := fake.commands(tt.wantStop); len(got) != 1 {
+				t.Errorf("stop commands = %v, want one %q", got, tt.wantStop)
+			}
+			if got := fake.commands(tt.wantNoStop); len(got) != 0 {
+				t.Errorf("unexpected stop commands %v", got)
+			}
+			if got := fake.commands("CALL db.checkpoint()"); len(got) != 1 {
+				t.Errorf("checkpoint commands = %v, want one", got)
+			}
+			if got := fake.commands("database sync"); (len(got) == 0) != tt.wantNoDBSync {
+				t.Errorf("database sync commands = %v", got)
+			}
+			if err := iops.Quiesce(true); err == nil || !strings.Contains(err.Error(), "already quiesced") {
+				t.Errorf("second Quiesce() error = %v, want already quiesced", err)
+			}
+
+			if err := iops.Unquiesce(); err != nil {
+				t.Fatalf("Unquiesce() error = %v", err)
+			}
+			if got := fake.commands(tt.wantStart); len(got) != 1 {
+				t.Errorf("start commands = %v, want one %q", got, tt.wantStart)
+			}
+			for _, service := range []string{"infrahub-server", "cache"} {
+				if got := fake.commands("start " + service); len(got) != 1 {
+					t.Errorf("start %s commands = %v, want one", service, got)
+				}
+			}
+			if _, err := os.Stat(iops.quiesceStatePath()); !os.IsNotExist(err) {
+				t.Errorf("quiesce state was not removed: %v", err)
+			}
+		})
+	}
+}
+
+func TestQuiesceUndoesOnFailure(t *testing.T) {
+	fake := newFakeExecutor().
+		on("dbms.components", "edition\n\"enterprise\"", nil).
+		on("ps -a --format json", `[{"Service":"infrahub-server","State":"running"}]`, nil).
+		on("CALL db.checkpoint()", "", errors.New("exit status 1"))
+	iops := newFakeDockerOps(fake)
+	iops.config.BackupDir = t.TempDir()
+	iops.config.Neo4jUsername = "neo4j"
+	iops.config.Neo4jPassword = "secret"
+	iops.config.Neo4jDatabase = "neo4j"
+
+	if err := iops.Quiesce(true); err == nil {
+		t.Fatal("Quiesce() error = nil, want checkpoint failure")
+	}
+	if got := fake.commands("start infrahub-server"); len(got) != 1 {
+		t.Errorf("application services were not restarted: %v", got)
+	}
+	if _, err := os.Stat(iops.quiesceStatePath()); !os.IsNotExist(err) {
+		t.Errorf("quiesce state written after a failure: %v", err)
+	}
+}
+
+func TestUnquiesceWithoutState(t *testing.T) {
+	iops := NewInfrahubOpsWithExecutor(newFakeExecutor())
+	iops.config.BackupDir = t.TempDir()
+	if err := iops.Unquiesce(); err == nil || !strings.Contains(err.Error(), "no quiesce state") {
+		t.Errorf("Unquiesce() error = %v, want missing state", err)
+	}
+}
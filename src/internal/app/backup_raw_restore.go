@@ -0,0 +1,115 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// neo4jArtifactEdition guesses the Neo4j edition that produced raw backup
+// artifacts: neo4j-admin database dump files come from Community Edition,
+// anything else is taken to be Enterprise backup output.
+func neo4jArtifactEdition(path string, info os.FileInfo) string {
+	if !info.IsDir() {
+		if strings.HasSuffix(path, ".dump") {
+			return neo4jEditionCommunity
+		}
+		return neo4jEditionEnterprise
+	}
+	dumps, _ := filepath.Glob(filepath.Join(path, "*.dump"))
+	backups, _ := filepath.Glob(filepath.Join(path, "*.backup"))
+	if len(dumps) > 0 && len(backups) == 0 {
+		return neo4jEditionCommunity
+	}
+	return neo4jEditionEnterprise
+}
+
+// RestoreFromRawArtifacts restores a deployment directly from neo4j-admin
+// output and an optional task manager pg_dump, without building an archive
+// first. It is meant for emergency recoveries from artifacts produced by other
+// tools, so there are no recorded checksums or versions to check against.
+func (iops *InfrahubOps) RestoreFromRawArtifacts(neo4jPath, prefectDump string, excludeTaskManager, restoreMigrateFormat, resetDeploymentID, minimizeDowntime bool) error {
+	if iops.config.Backend == BackendPlakar {
+		return fmt.Errorf("--from-neo4j-dir is not supported with the plakar backend")
+	}
+	neo4jInfo, err := os.Stat(neo4jPath)
+	if err != nil {
+		return fmt.Errorf("neo4j backup path not accessible: %w", err)
+	}
+	if prefectDump != "" {
+		if info, err := os.Stat(prefectDump); err != nil {
+			return fmt.Errorf("prefect dump not accessible: %w", err)
+		} else if info.IsDir() {
+			return fmt.Errorf("prefect dump must be a pg_dump file, got directory %s", prefectDump)
+		}
+	}
+
+	if err := iops.checkPrerequisites(); err != nil {
+		return err
+	}
+	if err := iops.DetectEnvironment(); err != nil {
+		return err
+	}
+	releaseLock, err := iops.acquireOperationLock("restore")
+	if err != nil {
+		return err
+	}
+	defer releaseLock()
+	iops.reconcileRemoteTemp()
+
+	workDir, err := os.MkdirTemp("", "infrahub_restore_*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	logrus.WithFields(logrus.Fields{
+		"neo4j_path":   neo4jPath,
+		"prefect_dump": prefectDump,
+		"work_dir":     workDir,
+	}).Info("Starting restore from raw database artifacts")
+
+	metadata, err := iops.stageRawArtifacts(workDir, neo4jPath, neo4jInfo, prefectDump)
+	if err != nil {
+		return err
+	}
+	logrus.Warn("Raw artifacts carry no checksums or version information; they are restored as-is")
+	iops.recordRestoreSource(metadata.BackupID, neo4jPath, "")
+
+	return iops.restoreExtractedBackup(workDir, metadata, excludeTaskManager, restoreMigrateFormat, resetDeploymentID, minimizeDowntime)
+}
+
+// stageRawArtifacts lays the artifacts out under workDir/backup the way an
+// extracted archive is, and returns metadata describing them.
+func (iops *InfrahubOps) stageRawArtifacts(workDir, neo4jPath string, neo4jInfo os.FileInfo, prefectDump string) (*BackupMetadata, error) {
+	backupDir := filepath.Join(workDir, "backup")
+	databaseDir := filepath.Join(backupDir, "database")
+	if err := os.MkdirAll(databaseDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+
+	logrus.Info("Staging Neo4j backup files...")
+	if neo4jInfo.IsDir() {
+		if err := copyDir(neo4jPath, databaseDir); err != nil {
+			return nil, fmt.Errorf("failed to stage neo4j backup directory: %w", err)
+		}
+	} else if err := copyFile(neo4jPath, filepath.Join(databaseDir, filepath.Base(neo4jPath))); err != nil {
+		return nil, fmt.Errorf("failed to stage neo4j backup file: %w", err)
+	}
+
+	if prefectDump != "" {
+		logrus.Info("Staging task manager database dump...")
+		if err := copyFile(prefectDump, filepath.Join(backupDir, prefectDumpFilename)); err != nil {
+			return nil, fmt.Errorf("failed to stage prefect dump: %w", err)
+		}
+	}
+
+	edition := neo4jArtifactEdition(neo4jPath, neo4jInfo)
+	logrus.Infof("Auto-detected Neo4j edition of the artifacts: %s", edition)
+	backupID := "raw_" + time.Now().UTC().Format("20060102_150405")
+	return iops.createBackupMetadata(backupID, prefectDump != "", "", edition), nil
+}
@@ -0,0 +1,91 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestNeo4jArtifactEdition(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		file  string
+		want  string
+	}{
+		{name: "dump file", file: "neo4j.dump", want: neo4jEditionCommunity},
+		{name: "other file", file: "neo4j.tar", want: neo4jEditionEnterprise},
+		{name: "directory of dumps", files: []string{"neo4j.dump", "system.dump"}, want: neo4jEditionCommunity},
+		{name: "backup artifacts", files: []string{"neo4j-2025-01-01T12-00-00.backup"}, want: neo4jEditionEnterprise},
+		{name: "empty directory", want: neo4jEditionEnterprise},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range append(slices.Clone(tt.files), tt.file) {
+				if name != "" {
+					if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0644); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			path := dir
+			if tt.file != "" {
+				path = filepath.Join(dir, tt.file)
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := neo4jArtifactEdition(path, info); got != tt.want {
+				t.Errorf("neo4jArtifactEdition() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStageRawArtifacts(t *testing.T) {
+	neo4jDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(neo4jDir, "neo4j-2025-01-01T12-00-00.backup"), []byte("graph"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	prefectDump := filepath.Join(t.TempDir(), "tasks.pgdump")
+	if err := os.WriteFile(prefectDump, []byte("pg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(neo4jDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		prefectDump    string
+		wantComponents []string
+	}{
+		{name: "neo4j only", wantComponents: []string{"database"}},
+		{name: "with prefect dump", prefectDump: prefectDump, wantComponents: []string{"database", "task-manager-db"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workDir := t.TempDir()
+			metadata, err := NewInfrahubOps().stageRawArtifacts(workDir, neo4jDir, info, tt.prefectDump)
+			if err != nil {
+				t.Fatalf("stageRawArtifacts() error = %v", err)
+			}
+			if !slices.Equal(metadata.Components, tt.wantComponents) {
+				t.Errorf("components = %v, want %v", metadata.Components, tt.wantComponents)
+			}
+			if metadata.Neo4jEdition != neo4jEditionEnterprise {
+				t.Errorf("edition = %s, want enterprise", metadata.Neo4jEdition)
+			}
+			if !fileExists(filepath.Join(workDir, "backup", "database", "neo4j-2025-01-01T12-00-00.backup")) {
+				t.Error("neo4j backup not staged under backup/database")
+			}
+			if got := hasTaskManagerDump(filepath.Join(workDir, "backup")); got != (tt.prefectDump != "") {
+				t.Errorf("task manager dump staged = %v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,299 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// A backup record is a compact, optionally signed statement of what a backup
+// contained, sent to an append-only destination separate from the archive: an
+// S3 bucket with Object Lock or an HTTP endpoint. Records outlive the archives
+// they describe, so deleted or altered backups can still be accounted for.
+const (
+	backupRecordVersion   = 1
+	backupRecordSuffix    = ".record.json"
+	backupRecordAlgorithm = "ecdsa-p256-sha256"
+	backupRecordTimeout   = 30 * time.Second
+)
+
+// BackupRecord describes one backup.
+type BackupRecord struct {
+	Version         int               `json:"version"`
+	BackupID        string            `json:"backup_id"`
+	CreatedAt       string            `json:"created_at"`
+	Operator        string            `json:"operator"`
+	Host            string            `json:"host"`
+	Backend         string            `json:"backend,omitempty"`
+	Target          string            `json:"target,omitempty"`
+	InfrahubVersion string            `json:"infrahub_version,omitempty"`
+	ToolVersion     string            `json:"tool_version"`
+	Components      []string          `json:"components"`
+	Encrypted       bool              `json:"encrypted"`
+	Archive         []RecordFile      `json:"archive"`
+	Locations       map[string]string `json:"locations,omitempty"`
+	Checksums       map[string]string `json:"checksums"`
+}
+
+// RecordFile is an archive file, or one part of a split archive.
+type RecordFile struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// SignedBackupRecord is the document written to the record destination. The
+// signature covers the exact bytes of Record.
+type SignedBackupRecord struct {
+	Record    json.RawMessage `json:"record"`
+	Algorithm string          `json:"algorithm,omitempty"`
+	Signature string          `json:"signature,omitempty"`
+}
+
+// recordOperator returns who ran the backup: --record-operator, or the local
+// user name.
+func (iops *InfrahubOps) recordOperator() string {
+	if iops.config.RecordOperator != "" {
+		return iops.config.RecordOperator
+	}
+	if current, err := user.Current(); err == nil && current.Username != "" {
+		return current.Username
+	}
+	return os.Getenv("USER")
+}
+
+// newBackupRecord describes the archive at archivePath, hashing each of its
+// files. It must run before delivery may remove the local archive.
+func (iops *InfrahubOps) newBackupRecord(metadata *BackupMetadata, archivePath string) (*BackupRecord, error) {
+	files, err := splitArchiveFiles(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	record := &BackupRecord{
+		Version:         backupRecordVersion,
+		BackupID:        metadata.BackupID,
+		CreatedAt:       metadata.CreatedAt,
+		Operator:        iops.recordOperator(),
+		InfrahubVersion: metadata.InfrahubVersion,
+		ToolVersion:     metadata.ToolVersion,
+		Components:      metadata.Components,
+		Encrypted:       metadata.Encrypted,
+		Checksums:       metadata.Checksums,
+	}
+	record.Host, _ = os.Hostname()
+	if source := metadata.Source; source != nil {
+		record.Backend = source.Backend
+		record.Target = source.Project
+		if source.Backend == "kubernetes" {
+			record.Target = source.Namespace
+		}
+	}
+	for _, file := range files {
+		sum, err := calculateSHA256(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash %s: %w", file, err)
+		}
+		stat, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		record.Archive = append(record.Archive, RecordFile{Name: filepath.Base(file), Size: stat.Size(), SHA256: sum})
+	}
+	return record, nil
+}
+
+// signBackupRecord serializes record and signs it with signer when set.
+func signBackupRecord(record *BackupRecord, signer *ecdsa.PrivateKey) ([]byte, error) {
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal backup record: %w", err)
+	}
+	signed := SignedBackupRecord{Record: payload}
+	if signer != nil {
+		digest := sha256.Sum256(payload)
+		signature, err := ecdsa.SignASN1(rand.Reader, signer, digest[:])
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign backup record: %w", err)
+		}
+		signed.Algorithm = backupRecordAlgorithm
+		signed.Signature = base64.StdEncoding.EncodeToString(signature)
+	}
+	// Indenting would also reformat the signed bytes of the record
+	data, err := json.Marshal(signed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal backup record: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// VerifyBackupRecord parses a signed backup record and checks its signature
+// with the base64 public key in verifyKey. Without a key, an unchecked
+// signature is only reported.
+func VerifyBackupRecord(data []byte, verifyKey string) (*BackupRecord, error) {
+	var signed SignedBackupRecord
+	if err := json.Unmarshal(data, &signed); err != nil || len(signed.Record) == 0 {
+		return nil, fmt.Errorf("not a backup record: %v", err)
+	}
+	var record BackupRecord
+	if err := json.Unmarshal(signed.Record, &record); err != nil {
+		return nil, fmt.Errorf("invalid backup record: %w", err)
+	}
+
+	if verifyKey == "" {
+		if signed.Signature != "" {
+			logrus.Warn("Backup record is signed but the signature was not checked; pass --verify-key to check it")
+		} else {
+			logrus.Warn("Backup record is not signed")
+		}
+		return &record, nil
+	}
+	if signed.Signature == "" {
+		return nil, fmt.Errorf("--verify-key was given but the backup record is not signed")
+	}
+	if signed.Algorithm != backupRecordAlgorithm {
+		return nil, fmt.Errorf("unsupported backup record signature algorithm %q", signed.Algorithm)
+	}
+	publicKey, err := loadECDSAPublicKey(verifyKey)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.StdEncoding.DecodeString(signed.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("invalid backup record signature: %w", err)
+	}
+	digest := sha256.Sum256(signed.Record)
+	if !ecdsa.VerifyASN1(publicKey, digest[:], signature) {
+		return nil, fmt.Errorf("signature check failed: the backup record was not signed by this key or was altered")
+	}
+	logrus.Info("Backup record signature is valid")
+	return &record, nil
+}
+
+// CheckRecordArchive compares the files of a local archive with the hashes in
+// record.
+func CheckRecordArchive(record *BackupRecord, archivePath string) error {
+	files, err := splitArchiveFiles(archivePath)
+	if err != nil {
+		return err
+	}
+	if len(files) != len(record.Archive) {
+		return fmt.Errorf("archive has %d files, the record lists %d", len(files), len(record.Archive))
+	}
+	for i, file := range files {
+		expected := record.Archive[i]
+		if filepath.Base(file) != expected.Name {
+			return fmt.Errorf("archive file %s is not the recorded %s", filepath.Base(file), expected.Name)
+		}
+		sum, err := calculateSHA256(file)
+		if err != nil {
+			return fmt.Errorf("failed to hash %s: %w", file, err)
+		}
+		if sum != expected.SHA256 {
+			return fmt.Errorf("%s does not match the record: sha256 %s, recorded %s", expected.Name, sum, expected.SHA256)
+		}
+	}
+	return nil
+}
+
+// sendBackupRecord writes the signed record to the --record-to destination.
+// An S3 object that already exists is never replaced.
+func (iops *InfrahubOps) sendBackupRecord(record *BackupRecord) (string, error) {
+	var signer *ecdsa.PrivateKey
+	if iops.config.RecordSignKey != "" {
+		var err error
+		if signer, err = loadECDSAPrivateKey(iops.config.RecordSignKey); err != nil {
+			return "", fmt.Errorf("failed to load record signing key: %w", err)
+		}
+	}
+	data, err := signBackupRecord(record, signer)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), backupRecordTimeout)
+	defer cancel()
+
+	destination := iops.config.RecordTo
+	if bucket, prefix, ok := ParseS3URI(destination); ok {
+		client, err := iops.s3Client(S3Config{
+			Bucket:      bucket,
+			Prefix:      prefix,
+			Endpoint:    iops.config.S3.Endpoint,
+			Region:      iops.config.S3.Region,
+			Credentials: iops.config.S3.Credentials,
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to create S3 client: %w", err)
+		}
+		var retainUntil time.Time
+		if iops.config.RecordRetentionDays > 0 {
+			retainUntil = time.Now().UTC().AddDate(0, 0, iops.config.RecordRetentionDays)
+		}
+		return client.PutImmutable(ctx, record.BackupID+backupRecordSuffix, data, retainUntil)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, destination, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	client, err := iops.config.HTTP.Client(0)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("record endpoint returned %s", resp.Status)
+	}
+	return destination, nil
+}
+
+// checkBackupRecord rejects invalid --record-* options before the backup
+// starts.
+func (iops *InfrahubOps) checkBackupRecord() error {
+	cfg := iops.config
+	if cfg.RecordTo == "" {
+		if cfg.RecordSignKey != "" || cfg.RecordRetentionDays != 0 {
+			return fmt.Errorf("--record-sign-key and --record-retention-days require --record-to")
+		}
+		return nil
+	}
+	if cfg.Backend == BackendPlakar {
+		return fmt.Errorf("--record-to is not supported with the plakar backend")
+	}
+	if IsS3URI(cfg.RecordTo) {
+		if _, _, ok := ParseS3URI(cfg.RecordTo); !ok {
+			return fmt.Errorf("invalid --record-to %q: expected s3://bucket[/prefix]", cfg.RecordTo)
+		}
+	} else if u, err := url.Parse(cfg.RecordTo); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid --record-to %q: expected s3://bucket[/prefix] or an http:// or https:// URL", cfg.RecordTo)
+	} else if cfg.RecordRetentionDays != 0 {
+		return fmt.Errorf("--record-retention-days only applies to an s3:// --record-to")
+	}
+	if cfg.RecordRetentionDays < 0 {
+		return fmt.Errorf("invalid --record-retention-days %d: must not be negative", cfg.RecordRetentionDays)
+	}
+	if cfg.RecordSignKey != "" {
+		if _, err := loadECDSAPrivateKey(cfg.RecordSignKey); err != nil {
+			return fmt.Errorf("invalid --record-sign-key: %w", err)
+		}
+	}
+	return nil
+}
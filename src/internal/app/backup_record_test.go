@@ -0,0 +1,164 @@
+package app
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testBackupRecord(t *testing.T) (*InfrahubOps, *BackupRecord, string) {
+	t.Helper()
+	dir := t.TempDir()
+	writeTestFile(t, dir, "infrahub_backup_20250101_000000.tar.gz", "archive")
+	archive := filepath.Join(dir, "infrahub_backup_20250101_000000.tar.gz")
+
+	iops := newFakeDockerOps(newFakeExecutor())
+	iops.config.RecordOperator = "alice"
+	metadata := iops.createBackupMetadata("20250101_000000", true, "1.4.0", "community")
+	metadata.Checksums = map[string]string{"database/neo4j.dump": "abc"}
+	record, err := iops.newBackupRecord(metadata, archive)
+	if err != nil {
+		t.Fatalf("newBackupRecord() error = %v", err)
+	}
+	return iops, record, archive
+}
+
+func TestNewBackupRecord(t *testing.T) {
+	_, record, archive := testBackupRecord(t)
+	if record.BackupID != "20250101_000000" || record.Operator != "alice" || record.Checksums["database/neo4j.dump"] != "abc" {
+		t.Errorf("record = %+v", record)
+	}
+	if len(record.Archive) != 1 || record.Archive[0].Name != filepath.Base(archive) || record.Archive[0].Size != 7 {
+		t.Errorf("record archive = %+v", record.Archive)
+	}
+
+	if err := CheckRecordArchive(record, archive); err != nil {
+		t.Errorf("CheckRecordArchive() error = %v", err)
+	}
+	if err := os.WriteFile(archive, []byte("altered"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckRecordArchive(record, archive); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("CheckRecordArchive() after change error = %v", err)
+	}
+}
+
+func TestVerifyBackupRecord(t *testing.T) {
+	_, record, _ := testBackupRecord(t)
+	verifyKey, signKey := writeArchiveKeys(t, t.TempDir())
+	otherKey, _ := writeArchiveKeys(t, t.TempDir())
+	signer, err := loadECDSAPrivateKey(signKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := signBackupRecord(record, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned, err := signBackupRecord(record, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := []byte(strings.Replace(string(signed), `"operator":"alice"`, `"operator":"mallory"`, 1))
+
+	tests := []struct {
+		name    string
+		data    []byte
+		key     string
+		wantErr string
+	}{
+		{"signed", signed, verifyKey, ""},
+		{"signed without key", signed, "", ""},
+		{"unsigned without key", unsigned, "", ""},
+		{"unsigned with key", unsigned, verifyKey, "not signed"},
+		{"other key", signed, otherKey, "signature check failed"},
+		{"tampered", tampered, verifyKey, "signature check failed"},
+		{"not a record", []byte(`{"backup_id": "x"}`), "", "not a backup record"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := VerifyBackupRecord(tt.data, tt.key)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("VerifyBackupRecord() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got.BackupID != record.BackupID {
+				t.Errorf("VerifyBackupRecord() = %+v, %v", got, err)
+			}
+		})
+	}
+}
+
+func TestSendBackupRecordToEndpoint(t *testing.T) {
+	iops, record, _ := testBackupRecord(t)
+	verifyKey, signKey := writeArchiveKeys(t, t.TempDir())
+
+	var received []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	iops.config.RecordTo = server.URL + "/records"
+	iops.config.RecordSignKey = signKey
+	location, err := iops.sendBackupRecord(record)
+	if err != nil || location != iops.config.RecordTo {
+		t.Fatalf("sendBackupRecord() = %q, %v", location, err)
+	}
+	if _, err := VerifyBackupRecord(received, verifyKey); err != nil {
+		t.Errorf("received record does not verify: %v\n%s", err, received)
+	}
+	var signed SignedBackupRecord
+	if err := json.Unmarshal(received, &signed); err != nil || signed.Algorithm != backupRecordAlgorithm {
+		t.Errorf("received = %s", received)
+	}
+
+	rejecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+	}))
+	defer rejecting.Close()
+	iops.config.RecordTo = rejecting.URL
+	if _, err := iops.sendBackupRecord(record); err == nil || !strings.Contains(err.Error(), "409") {
+		t.Errorf("sendBackupRecord() to a rejecting endpoint error = %v", err)
+	}
+}
+
+func TestCheckBackupRecord(t *testing.T) {
+	verifyKey, signKey := writeArchiveKeys(t, t.TempDir())
+	tests := []struct {
+		name    string
+		cfg     Configuration
+		wantErr string
+	}{
+		{"disabled", Configuration{}, ""},
+		{"s3", Configuration{RecordTo: "s3://audit/infrahub", RecordRetentionDays: 365, RecordSignKey: signKey}, ""},
+		{"https", Configuration{RecordTo: "https://audit.example.com/records"}, ""},
+		{"sign key without destination", Configuration{RecordSignKey: signKey}, "require --record-to"},
+		{"not a url", Configuration{RecordTo: "/var/audit"}, "invalid --record-to"},
+		{"empty bucket", Configuration{RecordTo: "s3://"}, "invalid --record-to"},
+		{"retention on https", Configuration{RecordTo: "https://audit.example.com", RecordRetentionDays: 30}, "only applies"},
+		{"negative retention", Configuration{RecordTo: "s3://audit", RecordRetentionDays: -1}, "must not be negative"},
+		{"public key as sign key", Configuration{RecordTo: "s3://audit", RecordSignKey: verifyKey}, "invalid --record-sign-key"},
+		{"plakar", Configuration{RecordTo: "s3://audit", Backend: BackendPlakar}, "plakar"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iops := &InfrahubOps{config: &tt.cfg}
+			err := iops.checkBackupRecord()
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("checkBackupRecord() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("checkBackupRecord() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Status values registered by --register-kind.
+const (
+	RegisteredStatusCompleted          = "completed"
+	RegisteredStatusVerified           = "verified"
+	RegisteredStatusVerificationFailed = "verification_failed"
+)
+
+// registerKindPattern matches Infrahub schema kinds such as InfraBackup. The
+// kind is part of the mutation name, so nothing else is accepted.
+var registerKindPattern = regexp.MustCompile(`^[A-Z][A-Za-z0-9]+$`)
+
+// backupRegistration is a backup as it is registered in Infrahub.
+type backupRegistration struct {
+	BackupID  string
+	Location  string
+	SizeBytes int64
+	Status    string
+	CreatedAt time.Time
+}
+
+// checkBackupRegistration validates --register-kind before a backup starts.
+func (iops *InfrahubOps) checkBackupRegistration() error {
+	kind := iops.config.RegisterKind
+	if kind == "" {
+		return nil
+	}
+	if iops.config.Backend == BackendPlakar {
+		return fmt.Errorf("--register-kind is not supported with the plakar backend")
+	}
+	if !registerKindPattern.MatchString(kind) {
+		return fmt.Errorf("invalid --register-kind %q: expected a schema kind such as InfraBackup", kind)
+	}
+	return nil
+}
+
+// backupRegistrationMutation returns the upsert mutation of kind and its
+// variables for registration.
+func backupRegistrationMutation(kind string, registration backupRegistration) (string, string, error) {
+	mutation := fmt.Sprintf("mutation($data: %[1]sUpsertInput!) { %[1]sUpsert(data: $data) { ok } }", kind)
+	variables, err := json.Marshal(map[string]any{"data": map[string]any{
+		"backup_id":  map[string]any{"value": registration.BackupID},
+		"location":   map[string]any{"value": registration.Location},
+		"size":       map[string]any{"value": formatBytes(registration.SizeBytes)},
+		"status":     map[string]any{"value": registration.Status},
+		"created_at": map[string]any{"value": registration.CreatedAt.UTC().Format(time.RFC3339)},
+	}})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encode registration: %w", err)
+	}
+	return mutation, string(variables), nil
+}
+
+// registerBackup upserts registration as a node of --register-kind through
+// the GraphQL API of the local infrahub-server.
+func (iops *InfrahubOps) registerBackup(registration backupRegistration) error {
+	kind := iops.config.RegisterKind
+	mutation, variables, err := backupRegistrationMutation(kind, registration)
+	if err != nil {
+		return err
+	}
+	if err := iops.runGraphQL(mutation, variables); err != nil {
+		return fmt.Errorf("failed to register backup %s in Infrahub as %s: %w", registration.BackupID, kind, err)
+	}
+	logrus.Infof("Registered backup %s in Infrahub as %s", registration.BackupID, kind)
+	return nil
+}
+
+// runGraphQL posts query with its JSON variables and fails on GraphQL errors.
+func (iops *InfrahubOps) runGraphQL(query, variables string) error {
+	return iops.runBranchGraphQL("", query, variables)
+}
+
+// runBranchGraphQL runs query like runGraphQL on branch, or on the default
+// branch when branch is empty.
+func (iops *InfrahubOps) runBranchGraphQL(branch, query, variables string) error {
+	cmd := []string{"python", "-c", graphQLQueryScript, query, variables}
+	if branch != "" {
+		cmd = append(cmd, branch)
+	}
+	output, err := iops.Exec("infrahub-server", cmd, nil)
+	if err != nil {
+		return err
+	}
+	start := strings.IndexByte(output, '{')
+	if start < 0 {
+		return fmt.Errorf("no JSON in GraphQL output")
+	}
+	var response struct {
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal([]byte(output[start:]), &response); err != nil {
+		return fmt.Errorf("invalid GraphQL output: %w", err)
+	}
+	if len(response.Errors) > 0 {
+		return fmt.Errorf("%s", response.Errors[0].Message)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package app
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckBackupRegistration(t *testing.T) {
+	tests := []struct {
+		name    string
+		kind    string
+		backend BackendType
+		wantErr string
+	}{
+		{name: "disabled", kind: ""},
+		{name: "schema kind", kind: "InfraBackup"},
+		{name: "not a kind", kind: "InfraBackup(data: {})", wantErr: "invalid --register-kind"},
+		{name: "lowercase", kind: "infraBackup", wantErr: "invalid --register-kind"},
+		{name: "plakar", kind: "InfraBackup", backend: BackendPlakar, wantErr: "not supported with the plakar backend"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iops := NewInfrahubOpsWithExecutor(newFakeExecutor())
+			iops.config.RegisterKind = tt.kind
+			iops.config.Backend = tt.backend
+			err := iops.checkBackupRegistration()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkBackupRegistration() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("checkBackupRegistration() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRegisterBackup(t *testing.T) {
+	registration := backupRegistration{
+		BackupID:  "infrahub_backup_20260115_020000",
+		Location:  "s3://backups/infrahub_backup_20260115_020000.tar.gz",
+		SizeBytes: 3 * 1024 * 1024,
+		Status:    RegisteredStatusVerified,
+		CreatedAt: time.Date(2026, 1, 15, 2, 0, 0, 0, time.UTC),
+	}
+
+	fake := newFakeExecutor().on("InfraBackupUpsert", `{"data": {"InfraBackupUpsert": {"ok": true}}}`, nil)
+	iops := newFakeDockerOps(fake)
+	iops.config.RegisterKind = "InfraBackup"
+	if err := iops.registerBackup(registration); err != nil {
+		t.Errorf("registerBackup() error = %v", err)
+	}
+
+	mutation, variables, err := backupRegistrationMutation("InfraBackup", registration)
+	if err != nil {
+		t.Fatalf("backupRegistrationMutation() error = %v", err)
+	}
+	if want := "mutation($data: InfraBackupUpsertInput!) { InfraBackupUpsert(data: $data) { ok } }"; mutation != want {
+		t.Errorf("mutation = %q, want %q", mutation, want)
+	}
+	var decoded struct {
+		Data map[string]struct {
+			Value string `json:"value"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(variables), &decoded); err != nil {
+		t.Fatalf("invalid variables %s: %v", variables, err)
+	}
+	want := map[string]string{
+		"backup_id":  registration.BackupID,
+		"location":   registration.Location,
+		"size":       "3.0 MB",
+		"status":     "verified",
+		"created_at": "2026-01-15T02:00:00Z",
+	}
+	for field, value := range want {
+		if decoded.Data[field].Value != value {
+			t.Errorf("%s = %q, want %q", field, decoded.Data[field].Value, value)
+		}
+	}
+}
+
+func TestRegisterBackupGraphQLError(t *testing.T) {
+	fake := newFakeExecutor().on("InfraBackupUpsert", `{"data": null, "errors": [{"message": "Unknown type 'InfraBackupUpsertInput'"}]}`, nil)
+	iops := newFakeDockerOps(fake)
+	iops.config.RegisterKind = "InfraBackup"
+	err := iops.registerBackup(backupRegistration{BackupID: "b1", Status: RegisteredStatusCompleted, CreatedAt: time.Now()})
+	if err == nil || !strings.Contains(err.Error(), "Unknown type") {
+		t.Errorf("registerBackup() error = %v", err)
+	}
+}
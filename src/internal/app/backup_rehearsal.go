@@ -0,0 +1,392 @@
+package app
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	rehearsalReadyTimeout = 5 * time.Minute
+	rehearsalPollInterval = 5 * time.Second
+	rehearsalBackupDir    = "/tmp/infrahubops"
+)
+
+// RehearsalOptions overrides the images used for a restore rehearsal. Empty
+// images are derived from the versions recorded in the backup metadata.
+type RehearsalOptions struct {
+	Neo4jImage    string
+	PostgresImage string
+}
+
+// rehearsal tracks the throwaway containers and volumes of one rehearsal so
+// they can be removed afterwards.
+type rehearsal struct {
+	executor   CommandExecutor
+	prefix     string
+	password   string
+	containers []string
+	volumes    []string
+	database   string // Neo4j database loaded by rehearseNeo4j
+	readOnly   bool   // start Neo4j with its databases read-only
+}
+
+// RehearseRestore restores backupFile into throwaway Neo4j and PostgreSQL
+// containers started with docker run, checks that the data loads, and removes
+// the containers again. The live deployment is never touched.
+func (iops *InfrahubOps) RehearseRestore(backupFile, decryptKey string, excludeTaskManager bool, opts RehearsalOptions) error {
+	if iops.config.Backend == BackendPlakar {
+		return fmt.Errorf("--rehearse is not supported with the plakar backend")
+	}
+	if err := iops.executor.runCommandQuiet("docker", "version"); err != nil {
+		return fmt.Errorf("--rehearse needs a working docker CLI on this host: %w", err)
+	}
+
+	workDir, metadata, cleanup, err := iops.extractBackupForRehearsal(backupFile, decryptKey, excludeTaskManager)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	iops.recordRestoreSource(metadata.BackupID, backupFile, metadata.CreatedAt)
+
+	neo4jImage := opts.Neo4jImage
+	if neo4jImage == "" && metadata.hasComponent("database") {
+		if neo4jImage, err = rehearsalNeo4jImage(metadata); err != nil {
+			return err
+		}
+	}
+
+	r, err := newRehearsal(iops.executor)
+	if err != nil {
+		return err
+	}
+	defer r.cleanup()
+
+	logrus.WithFields(logrus.Fields{
+		"backup_id":   metadata.BackupID,
+		"neo4j_image": neo4jImage,
+	}).Info("Starting restore rehearsal")
+
+	if metadata.hasComponent("database") {
+		nodes, err := r.rehearseNeo4j(filepath.Join(workDir, "backup", neo4jBackupDirName), neo4jImage, metadata.Neo4jEdition)
+		if err != nil {
+			return fmt.Errorf("neo4j rehearsal failed: %w", err)
+		}
+		logrus.WithField("nodes", nodes).Info("Neo4j backup loaded in rehearsal container")
+	} else {
+		logrus.Info("Backup has no graph database; skipping Neo4j rehearsal")
+	}
+
+	dumpPath := taskManagerDumpPath(filepath.Join(workDir, "backup"))
+	if !excludeTaskManager && metadata.hasComponent("task-manager-db") && hasTaskManagerDump(filepath.Join(workDir, "backup")) {
+		postgresImage := opts.PostgresImage
+		if postgresImage == "" {
+			postgresImage = rehearsalPostgresImage(metadata)
+		}
+		tables, err := r.rehearsePostgres(dumpPath, postgresImage)
+		if err != nil {
+			return fmt.Errorf("postgresql rehearsal failed: %w", err)
+		}
+		logrus.WithFields(logrus.Fields{"tables": tables, "postgres_image": postgresImage}).Info("Task manager dump loaded in rehearsal container")
+	} else {
+		logrus.Info("Skipping task manager database rehearsal")
+	}
+
+	logrus.Infof("Restore rehearsal of %s succeeded", metadata.BackupID)
+	return nil
+}
+
+// extractBackupForRehearsal extracts backupFile into a temporary directory
+// and validates its checksums. cleanup removes the directory.
+func (iops *InfrahubOps) extractBackupForRehearsal(backupFile, decryptKey string, excludeTaskManager bool) (string, *BackupMetadata, func(), error) {
+	archive, cleanupArchive, err := iops.prepareSharedRestoreArchive(backupFile, decryptKey)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	defer cleanupArchive()
+
+	workDir, err := os.MkdirTemp("", "infrahub_rehearsal_*")
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	cleanup := func() { os.RemoveAll(workDir) }
+
+	logrus.Info("Extracting backup archive...")
+	metadata, err := func() (*BackupMetadata, error) {
+		if _, err := extractArchive(archive, workDir); err != nil {
+			return nil, fmt.Errorf("failed to extract backup: %w", err)
+		}
+		metadataBytes, err := os.ReadFile(filepath.Join(workDir, "backup", backupMetadataFilename))
+		if err != nil {
+			return nil, fmt.Errorf("invalid backup file: missing metadata")
+		}
+		metadata, err := parseBackupMetadata(metadataBytes)
+		if err != nil {
+			return nil, err
+		}
+		return metadata, validateBackupChecksums(workDir, metadata, excludeTaskManager)
+	}()
+	if err != nil {
+		cleanup()
+		return "", nil, nil, err
+	}
+	return workDir, metadata, cleanup, nil
+}
+
+// rehearsalNeo4jImage picks the official Neo4j image matching the recorded
+// version and edition.
+func rehearsalNeo4jImage(metadata *BackupMetadata) (string, error) {
+	if metadata.Neo4jVersion == "" {
+		return "", fmt.Errorf("backup does not record a Neo4j version; pass --rehearse-neo4j-image")
+	}
+	edition := neo4jEditionCommunity
+	if strings.EqualFold(metadata.Neo4jEdition, "enterprise") {
+		edition = "enterprise"
+	}
+	return fmt.Sprintf("neo4j:%s-%s", metadata.Neo4jVersion, edition), nil
+}
+
+// rehearsalPostgresImage picks the official PostgreSQL image for the recorded
+// major version. Without one, the latest image is used since pg_restore reads
+// dumps from older versions.
+func rehearsalPostgresImage(metadata *BackupMetadata) string {
+	if major, err := postgresMajorVersion(metadata.PostgresVersion); err == nil {
+		return "postgres:" + strconv.Itoa(major)
+	}
+	return "postgres:latest"
+}
+
+var neo4jBackupFileRe = regexp.MustCompile(`^(.+)-\d{4}-\d{2}-\d{2}T.*\.backup$`)
+
+// neo4jBackupDatabaseName infers the database name from the files in the Neo4j
+// backup directory: <db>.dump for Community, <db>-<timestamp>.backup for
+// Enterprise.
+func neo4jBackupDatabaseName(files []string) string {
+	for _, file := range files {
+		if name, ok := strings.CutSuffix(file, ".dump"); ok && name != "" {
+			return name
+		}
+		if match := neo4jBackupFileRe.FindStringSubmatch(file); match != nil {
+			return match[1]
+		}
+	}
+	return defaultNeo4jDatabase
+}
+
+func newRehearsal(executor CommandExecutor) (*rehearsal, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("failed to generate rehearsal id: %w", err)
+	}
+	password := make([]byte, 16)
+	if _, err := rand.Read(password); err != nil {
+		return nil, fmt.Errorf("failed to generate rehearsal password: %w", err)
+	}
+	return &rehearsal{
+		executor: executor,
+		prefix:   "infrahub-rehearsal-" + hex.EncodeToString(buf),
+		password: hex.EncodeToString(password),
+	}, nil
+}
+
+func (r *rehearsal) docker(args ...string) (string, error) {
+	return r.executor.runCommand("docker", args...)
+}
+
+// run starts a detached container and registers it for cleanup.
+func (r *rehearsal) run(name string, args ...string) error {
+	runArgs := append([]string{"run", "-d", "--name", name, "--label", "infrahub-ops.rehearsal=" + r.prefix}, args...)
+	if output, err := r.docker(runArgs...); err != nil {
+		return fmt.Errorf("failed to start %s: %w\nOutput: %v", name, err, output)
+	}
+	r.containers = append(r.containers, name)
+	return nil
+}
+
+func (r *rehearsal) removeContainer(name string) {
+	if _, err := r.docker("rm", "-f", "-v", name); err != nil {
+		logrus.Warnf("Failed to remove rehearsal container %s: %v", name, err)
+	}
+	for i, c := range r.containers {
+		if c == name {
+			r.containers = append(r.containers[:i], r.containers[i+1:]...)
+			break
+		}
+	}
+}
+
+func (r *rehearsal) cleanup() {
+	for len(r.containers) > 0 {
+		r.removeContainer(r.containers[len(r.containers)-1])
+	}
+	for _, volume := range r.volumes {
+		if _, err := r.docker("volume", "rm", "-f", volume); err != nil {
+			logrus.Warnf("Failed to remove rehearsal volume %s: %v", volume, err)
+		}
+	}
+	logrus.Info("Rehearsal containers removed")
+}
+
+// waitFor polls check until it succeeds or the rehearsal timeout expires.
+func waitFor(what string, check func() error) error {
+	deadline := time.Now().Add(rehearsalReadyTimeout)
+	for {
+		err := check()
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for %s: %w", what, err)
+		}
+		time.Sleep(rehearsalPollInterval)
+	}
+}
+
+// rehearseNeo4j loads the backup into a data volume with neo4j-admin while
+// the server is not running, then starts Neo4j on that volume and counts the
+// nodes of the restored database.
+func (r *rehearsal) rehearseNeo4j(backupPath, image, edition string) (int, error) {
+	entries, err := os.ReadDir(backupPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read neo4j backup: %w", err)
+	}
+	files := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		files = append(files, entry.Name())
+	}
+	database := neo4jBackupDatabaseName(files)
+	r.database = database
+
+	volume := r.prefix + "-neo4j-data"
+	if output, err := r.docker("volume", "create", volume); err != nil {
+		return 0, fmt.Errorf("failed to create volume: %w\nOutput: %v", err, output)
+	}
+	r.volumes = append(r.volumes, volume)
+
+	env := []string{
+		"-e", "NEO4J_ACCEPT_LICENSE_AGREEMENT=yes",
+		"-e", "NEO4J_initial_dbms_default__database=" + database,
+		"-e", "NEO4J_AUTH=neo4j/" + r.password,
+	}
+
+	// Load the backup with the server stopped
+	loader := r.prefix + "-neo4j-load"
+	args := append([]string{"-v", volume + ":/data", "--entrypoint", "sleep"}, env...)
+	if err := r.run(loader, append(args, image, "infinity")...); err != nil {
+		return 0, err
+	}
+	if output, err := r.docker("cp", backupPath, loader+":"+rehearsalBackupDir); err != nil {
+		return 0, fmt.Errorf("failed to copy backup: %w\nOutput: %v", err, output)
+	}
+	loadCmd := []string{"neo4j-admin", "database", "restore", "--expand-commands", "--overwrite-destination=true", "--from-path=" + rehearsalBackupDir, database}
+	if strings.EqualFold(edition, neo4jEditionCommunity) {
+		loadCmd = []string{"neo4j-admin", "database", "load", "--overwrite-destination=true", "--from-path=" + rehearsalBackupDir, database}
+	}
+	if output, err := r.docker(append([]string{"exec", loader}, loadCmd...)...); err != nil {
+		return 0, fmt.Errorf("failed to load backup: %w\nOutput: %v", err, output)
+	}
+	if output, err := r.docker("exec", loader, "chown", "-R", "neo4j:neo4j", "/data"); err != nil {
+		return 0, fmt.Errorf("failed to fix data ownership: %w\nOutput: %v", err, output)
+	}
+	r.removeContainer(loader)
+
+	// Start Neo4j on the loaded data and query it
+	server := r.prefix + "-neo4j"
+	if r.readOnly {
+		env = append(env, "-e", "NEO4J_server_databases_default__to__read__only=true")
+	}
+	if err := r.run(server, append(append([]string{"-v", volume + ":/data"}, env...), image)...); err != nil {
+		return 0, err
+	}
+
+	var nodes int
+	err = waitFor("neo4j", func() error {
+		output, err := r.cypher("MATCH (n) RETURN count(n)")
+		if err != nil {
+			return fmt.Errorf("%w: %s", err, strings.TrimSpace(output))
+		}
+		nodes, err = parseLastInt(output)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	if nodes == 0 {
+		return 0, fmt.Errorf("restored database %s is empty", database)
+	}
+	return nodes, nil
+}
+
+// cypher runs query against the database loaded by rehearseNeo4j and returns
+// the plain cypher-shell output.
+func (r *rehearsal) cypher(query string) (string, error) {
+	return r.cypherShell("plain", query)
+}
+
+// cypherShell runs query with cypher-shell in format, in read access mode
+// when the databases are read-only.
+func (r *rehearsal) cypherShell(format, query string) (string, error) {
+	args := []string{"exec", r.prefix + "-neo4j", "cypher-shell", "-u", "neo4j", "-p", r.password, "-d", r.database, "--format", format}
+	if r.readOnly {
+		args = append(args, "--access-mode", "read")
+	}
+	return r.docker(append(args, query)...)
+}
+
+// rehearsePostgres restores the task manager dump into a fresh PostgreSQL
+// container and counts the restored tables.
+func (r *rehearsal) rehearsePostgres(dumpPath, image string) (int, error) {
+	server := r.prefix + "-postgres"
+	if err := r.run(server, "-e", "POSTGRES_PASSWORD="+r.password, "-e", "POSTGRES_DB="+defaultPostgresDatabase, image); err != nil {
+		return 0, err
+	}
+	if err := waitFor("postgresql", func() error {
+		_, err := r.docker("exec", server, "pg_isready", "-U", "postgres", "-d", defaultPostgresDatabase)
+		return err
+	}); err != nil {
+		return 0, err
+	}
+
+	remotePath := "/tmp/" + filepath.Base(dumpPath)
+	if output, err := r.docker("cp", dumpPath, server+":"+remotePath); err != nil {
+		return 0, fmt.Errorf("failed to copy dump: %w\nOutput: %v", err, output)
+	}
+	if output, err := r.docker("exec", "-u", "postgres", server, "pg_restore", "--no-owner", "--no-privileges", "-d", defaultPostgresDatabase, remotePath); err != nil {
+		return 0, fmt.Errorf("failed to restore dump: %w\nOutput: %v", err, output)
+	}
+
+	output, err := r.docker("exec", "-u", "postgres", server, "psql", "-d", defaultPostgresDatabase, "-tAc",
+		"SELECT count(*) FROM information_schema.tables WHERE table_schema = 'public'")
+	if err != nil {
+		return 0, fmt.Errorf("failed to query restored database: %w\nOutput: %v", err, output)
+	}
+	tables, err := parseLastInt(output)
+	if err != nil {
+		return 0, err
+	}
+	if tables == 0 {
+		return 0, fmt.Errorf("restored task manager database has no tables")
+	}
+	return tables, nil
+}
+
+// parseLastInt returns the integer on the last non-empty line of output.
+func parseLastInt(output string) (int, error) {
+	lines := nonEmptyLines(output)
+	if len(lines) == 0 {
+		return 0, fmt.Errorf("empty query output")
+	}
+	value, err := strconv.Atoi(strings.Trim(lines[len(lines)-1], `"`))
+	if err != nil {
+		return 0, fmt.Errorf("unexpected query output %q", output)
+	}
+	return value, nil
+}
@@ -0,0 +1,91 @@
+package app
+
+import "testing"
+
+func TestRehearsalImages(t *testing.T) {
+	tests := []struct {
+		name         string
+		metadata     BackupMetadata
+		wantNeo4j    string
+		wantNeo4jErr bool
+		wantPostgres string
+	}{
+		{
+			name:         "enterprise",
+			metadata:     BackupMetadata{Neo4jVersion: "5.26.1", Neo4jEdition: "enterprise", PostgresVersion: "16.4 (Debian 16.4-1.pgdg120+2)"},
+			wantNeo4j:    "neo4j:5.26.1-enterprise",
+			wantPostgres: "postgres:16",
+		},
+		{
+			name:         "community",
+			metadata:     BackupMetadata{Neo4jVersion: "2025.01.0", Neo4jEdition: "community"},
+			wantNeo4j:    "neo4j:2025.01.0-community",
+			wantPostgres: "postgres:latest",
+		},
+		{
+			name:         "no recorded version",
+			metadata:     BackupMetadata{Neo4jEdition: "enterprise"},
+			wantNeo4jErr: true,
+			wantPostgres: "postgres:latest",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rehearsalNeo4jImage(&tt.metadata)
+			if (err != nil) != tt.wantNeo4jErr {
+				t.Fatalf("rehearsalNeo4jImage() error = %v, wantErr %v", err, tt.wantNeo4jErr)
+			}
+			if got != tt.wantNeo4j {
+				t.Errorf("rehearsalNeo4jImage() = %q, want %q", got, tt.wantNeo4j)
+			}
+			if got := rehearsalPostgresImage(&tt.metadata); got != tt.wantPostgres {
+				t.Errorf("rehearsalPostgresImage() = %q, want %q", got, tt.wantPostgres)
+			}
+		})
+	}
+}
+
+func TestNeo4jBackupDatabaseName(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  string
+	}{
+		{name: "community dump", files: []string{"infrahub.dump"}, want: "infrahub"},
+		{name: "enterprise backup", files: []string{"neo4j-2025-01-15T10-00-00.backup"}, want: "neo4j"},
+		{name: "hyphenated name", files: []string{"my-db-2025-01-15T10-00-00.backup"}, want: "my-db"},
+		{name: "unknown files", files: []string{"README"}, want: "neo4j"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := neo4jBackupDatabaseName(tt.files); got != tt.want {
+				t.Errorf("neo4jBackupDatabaseName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLastInt(t *testing.T) {
+	tests := []struct {
+		output  string
+		want    int
+		wantErr bool
+	}{
+		{output: "count(n)\n1234\n", want: 1234},
+		{output: " 42 ", want: 42},
+		{output: "", wantErr: true},
+		{output: "count(n)\nnull", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseLastInt(tt.output)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("parseLastInt(%q) error = %v, wantErr %v", tt.output, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("parseLastInt(%q) = %d, want %d", tt.output, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,420 @@
+package app
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Output formats of report generate.
+const (
+	ReportMarkdown = "markdown"
+	ReportHTML     = "html"
+)
+
+// reportTimeLayout is how times are shown in generated reports.
+const reportTimeLayout = "2006-01-02 15:04 UTC"
+
+// BackupReport summarises the backups, restores, SLO breaches and retention
+// actions of a period, for ops reviews.
+type BackupReport struct {
+	From             time.Time
+	To               time.Time
+	Summary          ReportSummary
+	Backups          []ReportRun
+	Restores         []ReportRun
+	Failures         []ReportRun
+	SLOBreaches      []ReportRun // runs that missed --rpo or --rto
+	Verifications    []ReportVerification
+	RetentionActions []HistoryEntry // gc, hold and release
+	Held             []CatalogEntry // backups on hold at the end of the period
+}
+
+// ReportSummary holds the totals shown at the top of a report.
+type ReportSummary struct {
+	Backups         int
+	FailedBackups   int
+	Restores        int
+	FailedRestores  int
+	TotalSizeBytes  int64
+	AverageDuration time.Duration
+	LongestDuration time.Duration
+	Verified        int
+	VerifyFailed    int
+	Unverified      int
+}
+
+// SuccessRate is the share of backups that succeeded, in percent.
+func (s ReportSummary) SuccessRate() string {
+	if s.Backups == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.0f%%", 100*float64(s.Backups-s.FailedBackups)/float64(s.Backups))
+}
+
+// ReportRun is one backup or restore of the period.
+type ReportRun struct {
+	Time         time.Time
+	Operation    string
+	Status       string
+	Target       string
+	BackupID     string
+	SizeBytes    int64
+	Duration     time.Duration // zero when the run is only known from the catalog
+	Error        string
+	SLOBreaches  []string
+	Verification string // verified, failed or not verified; backups only
+}
+
+// ReportVerification is a verify of a catalogued backup during the period.
+type ReportVerification struct {
+	Time     time.Time
+	BackupID string
+	Passed   bool
+	Error    string
+}
+
+// ParseReportPeriod parses --period: a number of days ("7d") or weeks ("2w"),
+// or a Go duration ("36h").
+func ParseReportPeriod(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	var period time.Duration
+	var err error
+	switch {
+	case strings.HasSuffix(value, "d"), strings.HasSuffix(value, "w"):
+		var count int
+		count, err = strconv.Atoi(value[:len(value)-1])
+		period = time.Duration(count) * 24 * time.Hour
+		if strings.HasSuffix(value, "w") {
+			period *= 7
+		}
+	default:
+		period, err = time.ParseDuration(value)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid --period %q: expected e.g. 7d, 2w or 36h", value)
+	}
+	if period <= 0 {
+		return 0, fmt.Errorf("invalid --period %q: must be positive", value)
+	}
+	return period, nil
+}
+
+// GenerateBackupReport builds the report of the period ending at now from the
+// run history and the backup catalog. Catalogued backups without a history
+// entry, such as those taken before the history existed, are listed with what
+// the catalog knows about them.
+func (iops *InfrahubOps) GenerateBackupReport(period time.Duration, now time.Time) (*BackupReport, error) {
+	report := &BackupReport{From: now.Add(-period).UTC(), To: now.UTC()}
+	history, err := loadRunHistory(iops.config.BackupDir, report.From)
+	if err != nil {
+		return nil, err
+	}
+	catalog, err := loadBackupCatalog(iops.config.BackupDir)
+	if err != nil {
+		return nil, err
+	}
+
+	inPeriod := func(t time.Time) bool { return !t.Before(report.From) && !t.After(report.To) }
+	recorded := map[string]bool{}
+	for _, entry := range history {
+		if !inPeriod(entry.Time) {
+			continue
+		}
+		switch entry.Operation {
+		case HistoryGC, HistoryHold, HistoryRelease:
+			report.RetentionActions = append(report.RetentionActions, entry)
+			continue
+		}
+		run := ReportRun{
+			Time:        entry.Time,
+			Operation:   entry.Operation,
+			Status:      entry.Status,
+			Target:      entry.Target,
+			BackupID:    entry.BackupID,
+			SizeBytes:   entry.SizeBytes,
+			Duration:    time.Duration(entry.DurationSeconds * float64(time.Second)),
+			Error:       entry.Error,
+			SLOBreaches: entry.SLOBreaches,
+		}
+		if entry.Operation == "backup" {
+			recorded[entry.BackupID] = true
+			report.Backups = append(report.Backups, run)
+		} else {
+			report.Restores = append(report.Restores, run)
+		}
+	}
+	for _, entry := range catalog.Entries {
+		created, err := time.Parse(time.RFC3339, entry.CreatedAt)
+		if err == nil && inPeriod(created) && !recorded[entry.BackupID] {
+			report.Backups = append(report.Backups, ReportRun{
+				Time:      created,
+				Operation: "backup",
+				Status:    "success",
+				BackupID:  entry.BackupID,
+				SizeBytes: entry.SizeBytes,
+			})
+		}
+		if verified, err := time.Parse(time.RFC3339, entry.VerifiedAt); err == nil && inPeriod(verified) {
+			report.Verifications = append(report.Verifications, ReportVerification{
+				Time: verified, BackupID: entry.BackupID, Passed: entry.Verified, Error: entry.VerifyError,
+			})
+		}
+		if entry.Held {
+			report.Held = append(report.Held, entry)
+		}
+	}
+
+	byTime := func(a, b ReportRun) int { return a.Time.Compare(b.Time) }
+	slices.SortFunc(report.Backups, byTime)
+	slices.SortFunc(report.Restores, byTime)
+	slices.SortFunc(report.Verifications, func(a, b ReportVerification) int { return a.Time.Compare(b.Time) })
+
+	summary := &report.Summary
+	var timed int
+	var total time.Duration
+	for i := range report.Backups {
+		run := &report.Backups[i]
+		summary.Backups++
+		if run.Status == "failure" {
+			summary.FailedBackups++
+			report.Failures = append(report.Failures, *run)
+		} else {
+			summary.TotalSizeBytes += run.SizeBytes
+			run.Verification = "not verified"
+			if entry := catalog.find(run.BackupID); run.BackupID != "" && entry != nil && entry.VerifiedAt != "" {
+				run.Verification = "verified"
+				if !entry.Verified {
+					run.Verification = "failed"
+				}
+			}
+			switch run.Verification {
+			case "verified":
+				summary.Verified++
+			case "failed":
+				summary.VerifyFailed++
+			default:
+				summary.Unverified++
+			}
+		}
+		if run.Duration > 0 {
+			timed++
+			total += run.Duration
+			summary.LongestDuration = max(summary.LongestDuration, run.Duration)
+		}
+	}
+	if timed > 0 {
+		summary.AverageDuration = total / time.Duration(timed)
+	}
+	for _, run := range report.Restores {
+		summary.Restores++
+		if run.Status == "failure" {
+			summary.FailedRestores++
+			report.Failures = append(report.Failures, run)
+		}
+	}
+	slices.SortFunc(report.Failures, byTime)
+	for _, run := range slices.Concat(report.Backups, report.Restores) {
+		if len(run.SLOBreaches) > 0 {
+			report.SLOBreaches = append(report.SLOBreaches, run)
+		}
+	}
+	slices.SortFunc(report.SLOBreaches, byTime)
+	return report, nil
+}
+
+// Write renders the report in format, markdown or html.
+func (r *BackupReport) Write(w io.Writer, format string) error {
+	switch format {
+	case ReportMarkdown:
+		r.writeMarkdown(w)
+		return nil
+	case ReportHTML:
+		return backupReportHTML.Execute(w, r)
+	}
+	return fmt.Errorf("invalid --format %q: expected markdown or html", format)
+}
+
+func (r *BackupReport) writeMarkdown(w io.Writer) {
+	s := r.Summary
+	fmt.Fprintf(w, "# Infrahub backup report\n\n%s to %s\n\n", r.From.Format(reportTimeLayout), r.To.Format(reportTimeLayout))
+	fmt.Fprintln(w, "## Summary")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "| Metric | Value |")
+	fmt.Fprintln(w, "|--------|-------|")
+	fmt.Fprintf(w, "| Backups | %d (%d failed) |\n", s.Backups, s.FailedBackups)
+	fmt.Fprintf(w, "| Success rate | %s |\n", s.SuccessRate())
+	fmt.Fprintf(w, "| Total size | %s |\n", formatBytes(s.TotalSizeBytes))
+	fmt.Fprintf(w, "| Average duration | %s |\n", reportDuration(s.AverageDuration))
+	fmt.Fprintf(w, "| Longest duration | %s |\n", reportDuration(s.LongestDuration))
+	fmt.Fprintf(w, "| Verification | %d verified, %d failed, %d not verified |\n", s.Verified, s.VerifyFailed, s.Unverified)
+	fmt.Fprintf(w, "| Restores | %d (%d failed) |\n", s.Restores, s.FailedRestores)
+	fmt.Fprintf(w, "| SLO breaches | %d |\n", len(r.SLOBreaches))
+	fmt.Fprintf(w, "| Retention actions | %d |\n", len(r.RetentionActions))
+
+	fmt.Fprintf(w, "\n## Backups\n\n")
+	if len(r.Backups) == 0 {
+		fmt.Fprintln(w, "No backups were taken.")
+	} else {
+		fmt.Fprintln(w, "| Time | Target | Backup ID | Status | Size | Duration | Verification |")
+		fmt.Fprintln(w, "|------|--------|-----------|--------|------|----------|--------------|")
+		for _, run := range r.Backups {
+			fmt.Fprintf(w, "| %s | %s | %s | %s | %s | %s | %s |\n", run.Time.Format(reportTimeLayout), markdownCell(run.Target),
+				markdownCell(run.BackupID), run.Status, reportSize(run.SizeBytes), reportDuration(run.Duration), run.Verification)
+		}
+	}
+
+	if len(r.Failures) > 0 {
+		fmt.Fprintf(w, "\n## Failures\n\n")
+		fmt.Fprintln(w, "| Time | Operation | Target | Error |")
+		fmt.Fprintln(w, "|------|-----------|--------|-------|")
+		for _, run := range r.Failures {
+			fmt.Fprintf(w, "| %s | %s | %s | %s |\n", run.Time.Format(reportTimeLayout), run.Operation, markdownCell(run.Target), markdownCell(run.Error))
+		}
+	}
+
+	if len(r.SLOBreaches) > 0 {
+		fmt.Fprintf(w, "\n## SLO breaches\n\n")
+		fmt.Fprintln(w, "| Time | Operation | Target | Breach |")
+		fmt.Fprintln(w, "|------|-----------|--------|--------|")
+		for _, run := range r.SLOBreaches {
+			fmt.Fprintf(w, "| %s | %s | %s | %s |\n", run.Time.Format(reportTimeLayout), run.Operation,
+				markdownCell(run.Target), markdownCell(strings.Join(run.SLOBreaches, "; ")))
+		}
+	}
+
+	if len(r.Restores) > 0 {
+		fmt.Fprintf(w, "\n## Restores\n\n")
+		fmt.Fprintln(w, "| Time | Operation | Target | Backup ID | Status | Duration |")
+		fmt.Fprintln(w, "|------|-----------|--------|-----------|--------|----------|")
+		for _, run := range r.Restores {
+			fmt.Fprintf(w, "| %s | %s | %s | %s | %s | %s |\n", run.Time.Format(reportTimeLayout), run.Operation,
+				markdownCell(run.Target), markdownCell(run.BackupID), run.Status, reportDuration(run.Duration))
+		}
+	}
+
+	if len(r.Verifications) > 0 {
+		fmt.Fprintf(w, "\n## Verifications\n\n")
+		fmt.Fprintln(w, "| Time | Backup ID | Result |")
+		fmt.Fprintln(w, "|------|-----------|--------|")
+		for _, v := range r.Verifications {
+			result := "passed"
+			if !v.Passed {
+				result = "failed: " + v.Error
+			}
+			fmt.Fprintf(w, "| %s | %s | %s |\n", v.Time.Format(reportTimeLayout), markdownCell(v.BackupID), markdownCell(result))
+		}
+	}
+
+	fmt.Fprintf(w, "\n## Retention actions\n\n")
+	if len(r.RetentionActions) == 0 {
+		fmt.Fprintln(w, "No retention actions were taken.")
+	} else {
+		fmt.Fprintln(w, "| Time | Action | Backup ID | Details |")
+		fmt.Fprintln(w, "|------|--------|-----------|---------|")
+		for _, entry := range r.RetentionActions {
+			fmt.Fprintf(w, "| %s | %s | %s | %s |\n", entry.Time.Format(reportTimeLayout), entry.Operation,
+				markdownCell(entry.BackupID), markdownCell(strings.Join(entry.Actions, "; ")))
+		}
+	}
+	if len(r.Held) > 0 {
+		fmt.Fprintf(w, "\nBackups on hold: ")
+		ids := make([]string, 0, len(r.Held))
+		for _, entry := range r.Held {
+			ids = append(ids, entry.BackupID)
+		}
+		fmt.Fprintln(w, markdownCell(strings.Join(ids, ", ")))
+	}
+}
+
+// reportDuration formats d for a report; zero is an unknown duration.
+func reportDuration(d time.Duration) string {
+	if d <= 0 {
+		return "-"
+	}
+	return d.Round(time.Second).String()
+}
+
+// reportSize formats size for a report; zero is an unknown size.
+func reportSize(size int64) string {
+	if size <= 0 {
+		return "-"
+	}
+	return formatBytes(size)
+}
+
+var backupReportHTML = template.Must(template.New("report").Funcs(template.FuncMap{
+	"time":     func(t time.Time) string { return t.Format(reportTimeLayout) },
+	"duration": reportDuration,
+	"size":     reportSize,
+	"bytes":    formatBytes,
+	"join":     strings.Join,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Infrahub backup report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; }
+th { background: #f0f0f0; }
+.failure, .failed { color: #b00020; }
+.degraded { color: #a15c00; }
+</style>
+</head>
+<body>
+<h1>Infrahub backup report</h1>
+<p>{{ time .From }} to {{ time .To }}</p>
+<h2>Summary</h2>
+<table>
+<tr><th>Backups</th><td>{{ .Summary.Backups }} ({{ .Summary.FailedBackups }} failed)</td></tr>
+<tr><th>Success rate</th><td>{{ .Summary.SuccessRate }}</td></tr>
+<tr><th>Total size</th><td>{{ bytes .Summary.TotalSizeBytes }}</td></tr>
+<tr><th>Average duration</th><td>{{ duration .Summary.AverageDuration }}</td></tr>
+<tr><th>Longest duration</th><td>{{ duration .Summary.LongestDuration }}</td></tr>
+<tr><th>Verification</th><td>{{ .Summary.Verified }} verified, {{ .Summary.VerifyFailed }} failed, {{ .Summary.Unverified }} not verified</td></tr>
+<tr><th>Restores</th><td>{{ .Summary.Restores }} ({{ .Summary.FailedRestores }} failed)</td></tr>
+<tr><th>SLO breaches</th><td>{{ len .SLOBreaches }}</td></tr>
+<tr><th>Retention actions</th><td>{{ len .RetentionActions }}</td></tr>
+</table>
+<h2>Backups</h2>
+{{ if .Backups }}<table>
+<tr><th>Time</th><th>Target</th><th>Backup ID</th><th>Status</th><th>Size</th><th>Duration</th><th>Verification</th></tr>
+{{ range .Backups }}<tr><td>{{ time .Time }}</td><td>{{ .Target }}</td><td>{{ .BackupID }}</td><td class="{{ .Status }}">{{ .Status }}</td><td>{{ size .SizeBytes }}</td><td>{{ duration .Duration }}</td><td class="{{ .Verification }}">{{ .Verification }}</td></tr>
+{{ end }}</table>
+{{ else }}<p>No backups were taken.</p>
+{{ end }}{{ if .Failures }}<h2>Failures</h2>
+<table>
+<tr><th>Time</th><th>Operation</th><th>Target</th><th>Error</th></tr>
+{{ range .Failures }}<tr><td>{{ time .Time }}</td><td>{{ .Operation }}</td><td>{{ .Target }}</td><td class="failure">{{ .Error }}</td></tr>
+{{ end }}</table>
+{{ end }}{{ if .SLOBreaches }}<h2>SLO breaches</h2>
+<table>
+<tr><th>Time</th><th>Operation</th><th>Target</th><th>Breach</th></tr>
+{{ range .SLOBreaches }}<tr><td>{{ time .Time }}</td><td>{{ .Operation }}</td><td>{{ .Target }}</td><td class="failure">{{ join .SLOBreaches "; " }}</td></tr>
+{{ end }}</table>
+{{ end }}{{ if .Restores }}<h2>Restores</h2>
+<table>
+<tr><th>Time</th><th>Operation</th><th>Target</th><th>Backup ID</th><th>Status</th><th>Duration</th></tr>
+{{ range .Restores }}<tr><td>{{ time .Time }}</td><td>{{ .Operation }}</td><td>{{ .Target }}</td><td>{{ .BackupID }}</td><td class="{{ .Status }}">{{ .Status }}</td><td>{{ duration .Duration }}</td></tr>
+{{ end }}</table>
+{{ end }}{{ if .Verifications }}<h2>Verifications</h2>
+<table>
+<tr><th>Time</th><th>Backup ID</th><th>Result</th></tr>
+{{ range .Verifications }}<tr><td>{{ time .Time }}</td><td>{{ .BackupID }}</td>{{ if .Passed }}<td>passed</td>{{ else }}<td class="failed">failed: {{ .Error }}</td>{{ end }}</tr>
+{{ end }}</table>
+{{ end }}<h2>Retention actions</h2>
+{{ if .RetentionActions }}<table>
+<tr><th>Time</th><th>Action</th><th>Backup ID</th><th>Details</th></tr>
+{{ range .RetentionActions }}<tr><td>{{ time .Time }}</td><td>{{ .Operation }}</td><td>{{ .BackupID }}</td><td>{{ join .Actions "; " }}</td></tr>
+{{ end }}</table>
+{{ else }}<p>No retention actions were taken.</p>
+{{ end }}{{ if .Held }}<p>Backups on hold: {{ range $i, $e := .Held }}{{ if $i }}, {{ end }}{{ $e.BackupID }}{{ end }}</p>
+{{ end }}</body>
+</html>
+`))
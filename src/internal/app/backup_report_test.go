@@ -0,0 +1,122 @@
+package app
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseReportPeriod(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: "7d", want: 7 * 24 * time.Hour},
+		{value: "2w", want: 14 * 24 * time.Hour},
+		{value: "36h", want: 36 * time.Hour},
+		{value: " 1d ", want: 24 * time.Hour},
+		{value: "0d", wantErr: true},
+		{value: "d", wantErr: true},
+		{value: "", wantErr: true},
+		{value: "week", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseReportPeriod(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseReportPeriod(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseReportPeriod(%q) = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateBackupReport(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, runHistoryFilename, strings.Join([]string{
+		`{"time":"2025-12-31T02:00:00Z","operation":"backup","status":"success","target":"prod","backup_id":"infrahub_backup_20251231_020000","size_bytes":1048576,"duration_seconds":600}`,
+		`{"time":"2026-01-05T02:00:00Z","operation":"backup","status":"success","target":"prod","backup_id":"infrahub_backup_20260105_020000","size_bytes":2097152,"duration_seconds":300}`,
+		`{"time":"2026-01-06T02:00:00Z","operation":"backup","status":"failure","target":"prod","duration_seconds":30,"error":"database | offline"}`,
+		`{"time":"2026-01-06T09:00:00Z","operation":"restore","status":"success","target":"staging","backup_id":"infrahub_backup_20260105_020000","duration_seconds":900}`,
+		`{"time":"2026-01-07T03:00:00Z","operation":"gc","status":"success","actions":["partial /backups/x.tmp: delete file"]}`,
+		`{"time":"2026-01-07T04:00:00Z","operation":"hold","status":"success","backup_id":"infrahub_backup_20260105_020000","actions":["audit"]}`,
+	}, "\n")+"\n")
+	writeTestFile(t, dir, backupCatalogFilename, `{"entries": [
+		{"backup_id": "infrahub_backup_20251231_020000", "filename": "infrahub_backup_20251231_020000.tar.gz", "created_at": "2025-12-31T02:10:00Z"},
+		{"backup_id": "infrahub_backup_20260105_020000", "filename": "infrahub_backup_20260105_020000.tar.gz", "created_at": "2026-01-05T02:05:00Z",
+		 "held": true, "verified": true, "verified_at": "2026-01-05T03:00:00Z"},
+		{"backup_id": "infrahub_backup_20260104_020000", "filename": "infrahub_backup_20260104_020000.tar.gz", "created_at": "2026-01-04T02:05:00Z",
+		 "size_bytes": 1048576, "verified_at": "2026-01-04T03:00:00Z", "verify_error": "checksum mismatch"}
+	]}`)
+	iops := newFakeDockerOps(newFakeExecutor())
+	iops.config.BackupDir = dir
+
+	report, err := iops.GenerateBackupReport(7*24*time.Hour, time.Date(2026, 1, 8, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := ReportSummary{
+		Backups:         3,
+		FailedBackups:   1,
+		Restores:        1,
+		TotalSizeBytes:  3 << 20,
+		AverageDuration: 165 * time.Second,
+		LongestDuration: 300 * time.Second,
+		Verified:        1,
+		VerifyFailed:    1,
+	}
+	if report.Summary != want {
+		t.Errorf("Summary = %+v, want %+v", report.Summary, want)
+	}
+	var ids []string
+	for _, run := range report.Backups {
+		ids = append(ids, run.BackupID+"/"+run.Verification)
+	}
+	if got := strings.Join(ids, " "); got != "infrahub_backup_20260104_020000/failed infrahub_backup_20260105_020000/verified /" {
+		t.Errorf("Backups = %s", got)
+	}
+	if len(report.Failures) != 1 || len(report.Verifications) != 2 || len(report.RetentionActions) != 2 || len(report.Held) != 1 {
+		t.Errorf("report = %+v", report)
+	}
+
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{format: ReportMarkdown, want: []string{
+			"2026-01-01 00:00 UTC to 2026-01-08 00:00 UTC",
+			"| Success rate | 67% |",
+			"| 2026-01-05 02:00 UTC | prod | infrahub_backup_20260105_020000 | success | 2.0 MB | 5m0s | verified |",
+			`| 2026-01-06 02:00 UTC | backup | prod | database \| offline |`,
+			"| 2026-01-07 03:00 UTC | gc |  | partial /backups/x.tmp: delete file |",
+			"Backups on hold: infrahub_backup_20260105_020000",
+		}},
+		{format: ReportHTML, want: []string{
+			"<title>Infrahub backup report</title>",
+			`<td class="failure">database | offline</td>`,
+			`<td class="failed">failed: checksum mismatch</td>`,
+			"<td>hold</td><td>infrahub_backup_20260105_020000</td><td>audit</td>",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := report.Write(&out, tt.format); err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("report lacks %q:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+	if err := report.Write(&bytes.Buffer{}, "pdf"); err == nil {
+		t.Error("Write(pdf) succeeded")
+	}
+}
@@ -3,8 +3,11 @@ package app
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -14,35 +17,93 @@ func (iops *InfrahubOps) uploadBackupToS3(backupPath string) (string, error) {
 		return "", err
 	}
 
-	client, err := NewS3Client(iops.config.S3)
+	storageClass, err := s3StorageClass(iops.config.S3StorageClasses, iops.config.RetentionClass)
+	if err != nil {
+		return "", err
+	}
+	objectTags, err := s3ObjectTags(iops.config.S3Tags, iops.config.RetentionClass)
+	if err != nil {
+		return "", err
+	}
+	client, err := iops.s3Client(*iops.config.S3)
 	if err != nil {
 		return "", fmt.Errorf("failed to create S3 client: %w", err)
 	}
+	client.storageClass, client.tags = storageClass, objectTags
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
 	defer cancel()
 
-	return client.Upload(ctx, backupPath)
+	client.progress = iops.startPhase("upload")
+	s3URI, err := client.Upload(ctx, backupPath)
+	client.progress.end(err)
+	return s3URI, err
 }
 
-// downloadBackupFromS3 downloads a backup from S3
-func (iops *InfrahubOps) downloadBackupFromS3(s3URI string) (string, error) {
+// uploadStreamToS3 uploads r, of unknown size, as filename under the S3
+// prefix and returns its URI.
+func (iops *InfrahubOps) uploadStreamToS3(filename string, r io.Reader) (string, error) {
+	if err := iops.config.S3.ValidateConfig(); err != nil {
+		return "", err
+	}
+	storageClass, err := s3StorageClass(iops.config.S3StorageClasses, iops.config.RetentionClass)
+	if err != nil {
+		return "", err
+	}
+	objectTags, err := s3ObjectTags(iops.config.S3Tags, iops.config.RetentionClass)
+	if err != nil {
+		return "", err
+	}
+	client, err := iops.s3Client(*iops.config.S3)
+	if err != nil {
+		return "", fmt.Errorf("failed to create S3 client: %w", err)
+	}
+	client.storageClass, client.tags = storageClass, objectTags
+
+	// The upload lasts as long as the dumps, so it has no timeout of its own
+	client.progress = iops.startPhase("upload")
+	s3URI, err := client.UploadStream(context.Background(), filename, r)
+	client.progress.end(err)
+	return s3URI, err
+}
+
+// s3Client returns a client for cfg that connects with the proxy and TLS
+// settings of the other HTTP clients.
+func (iops *InfrahubOps) s3Client(cfg S3Config) (*S3Client, error) {
+	cfg.HTTP = iops.config.HTTP
+	return NewS3Client(&cfg)
+}
+
+// s3ClientForURI returns a client for the bucket of s3URI, using the
+// --s3-endpoint and --s3-region flags, with the key of the URI.
+func (iops *InfrahubOps) s3ClientForURI(s3URI string) (*S3Client, string, error) {
 	bucket, key, ok := ParseS3URI(s3URI)
 	if !ok {
-		return "", fmt.Errorf("invalid S3 URI: %s", s3URI)
+		return nil, "", fmt.Errorf("invalid S3 URI: %s", s3URI)
 	}
-
-	// Create S3 config from URI, using CLI flags for endpoint/region
-	s3Config := &S3Config{
-		Bucket:   bucket,
-		Endpoint: iops.config.S3.Endpoint,
-		Region:   iops.config.S3.Region,
+	client, err := iops.s3Client(S3Config{
+		Bucket:      bucket,
+		Endpoint:    iops.config.S3.Endpoint,
+		Region:      iops.config.S3.Region,
+		Credentials: iops.config.S3.Credentials,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create S3 client: %w", err)
 	}
+	return client, key, nil
+}
 
-	client, err := NewS3Client(s3Config)
+// downloadBackupFromS3 downloads a backup from S3
+func (iops *InfrahubOps) downloadBackupFromS3(s3URI string) (localPath string, err error) {
+	client, key, err := iops.s3ClientForURI(s3URI)
 	if err != nil {
-		return "", fmt.Errorf("failed to create S3 client: %w", err)
+		return "", err
 	}
+	if err := iops.thawS3Objects(client, []string{key}); err != nil {
+		return "", err
+	}
+	client.progress = iops.startPhase("download")
+	defer func() { client.progress.end(err) }()
 
 	// Ensure backup directory exists
 	if err := os.MkdirAll(iops.config.BackupDir, 0755); err != nil {
@@ -51,7 +112,7 @@ func (iops *InfrahubOps) downloadBackupFromS3(s3URI string) (string, error) {
 
 	// Download to local backup directory
 	filename := filepath.Base(key)
-	localPath := filepath.Join(iops.config.BackupDir, filename)
+	localPath = filepath.Join(iops.config.BackupDir, filename)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
 	defer cancel()
@@ -60,5 +121,30 @@ func (iops *InfrahubOps) downloadBackupFromS3(s3URI string) (string, error) {
 		return "", fmt.Errorf("failed to download backup from S3: %w", err)
 	}
 
+	// A split archive is downloaded by its manifest; fetch the parts next to it
+	if strings.HasSuffix(key, splitManifestSuffix) {
+		manifest, err := readSplitManifest(localPath)
+		if err != nil {
+			os.Remove(localPath)
+			return "", err
+		}
+		partKeys := make([]string, len(manifest.Parts))
+		for i, part := range manifest.Parts {
+			partKeys[i] = path.Join(path.Dir(key), part.Name)
+		}
+		if err := iops.thawS3Objects(client, partKeys); err != nil {
+			os.Remove(localPath)
+			return "", err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+		defer cancel()
+		for i, part := range manifest.Parts {
+			if err := client.Download(ctx, partKeys[i], filepath.Join(iops.config.BackupDir, part.Name)); err != nil {
+				removeArchiveFiles(localPath)
+				return "", fmt.Errorf("failed to download archive part %s from S3: %w", part.Name, err)
+			}
+		}
+	}
+
 	return localPath, nil
 }
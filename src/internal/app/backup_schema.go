@@ -0,0 +1,260 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// schemaComponent is recorded in backup metadata when the schema
+	// snapshot is included.
+	schemaComponent = "schema"
+
+	// schemaSnapshotFilename holds the Infrahub schema of the main branch at
+	// backup time.
+	schemaSnapshotFilename = "infrahub_schema.json"
+)
+
+// schemaExportScript reads the schema of the main branch from the REST API of
+// the local infrahub-server, authenticating with INFRAHUB_API_TOKEN when the
+// container defines it.
+const schemaExportScript = `import os, urllib.request
+request = urllib.request.Request("http://localhost:8000/api/schema?branch=main")
+token = os.environ.get("INFRAHUB_API_TOKEN")
+if token:
+    request.add_header("X-INFRAHUB-KEY", token)
+print(urllib.request.urlopen(request, timeout=60).read().decode())`
+
+// exportSchemaSnapshot returns the current Infrahub schema as indented JSON
+// with sorted keys, so two snapshots can be compared line by line.
+func (iops *InfrahubOps) exportSchemaSnapshot() ([]byte, error) {
+	output, err := iops.Exec("infrahub-server", []string{"python", "-c", schemaExportScript}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the Infrahub schema: %w", err)
+	}
+	var schema any
+	if err := json.Unmarshal([]byte(output), &schema); err != nil {
+		return nil, fmt.Errorf("infrahub schema is not valid JSON: %w", err)
+	}
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// BackupSchemaSnapshot returns the schema snapshot stored in a backup archive
+// without restoring it.
+func (iops *InfrahubOps) BackupSchemaSnapshot(backupFile, decryptKey string) ([]byte, error) {
+	archive, cleanup, err := iops.prepareSharedRestoreArchive(backupFile, decryptKey)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	snapshot, err := readArchiveMember(archive, "backup/"+schemaSnapshotFilename)
+	if err != nil {
+		return nil, fmt.Errorf("backup has no schema snapshot: %w", err)
+	}
+	return snapshot, nil
+}
+
+// schemaKinds maps each node and generic kind of a schema snapshot to its
+// attribute names.
+func schemaKinds(snapshot []byte) (map[string][]string, error) {
+	var schema struct {
+		Nodes    []schemaNodeSnapshot `json:"nodes"`
+		Generics []schemaNodeSnapshot `json:"generics"`
+	}
+	if err := json.Unmarshal(snapshot, &schema); err != nil {
+		return nil, fmt.Errorf("invalid schema snapshot: %w", err)
+	}
+	kinds := map[string][]string{}
+	for _, node := range append(schema.Nodes, schema.Generics...) {
+		kind := node.Kind
+		if kind == "" {
+			kind = node.Namespace + node.Name
+		}
+		var attributes []string
+		for _, attribute := range node.Attributes {
+			attributes = append(attributes, attribute.Name)
+		}
+		sort.Strings(attributes)
+		kinds[kind] = attributes
+	}
+	return kinds, nil
+}
+
+type schemaNodeSnapshot struct {
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace"`
+	Name       string `json:"name"`
+	Attributes []struct {
+		Name     string `json:"name"`
+		ReadOnly bool   `json:"read_only"`
+	} `json:"attributes"`
+	Relationships []schemaRelationshipSnapshot `json:"relationships"`
+}
+
+// schemaRelationshipSnapshot is a relationship of a schema snapshot. Its
+// identifier names the Relationship nodes of the graph.
+type schemaRelationshipSnapshot struct {
+	Name        string `json:"name"`
+	Identifier  string `json:"identifier"`
+	Cardinality string `json:"cardinality"`
+	Direction   string `json:"direction"`
+	ReadOnly    bool   `json:"read_only"`
+}
+
+// SchemaDiff lists the kinds and attributes that exist in only one of two
+// schema snapshots. Attributes are written as Kind.attribute.
+type SchemaDiff struct {
+	KindsOnlyInBackup      []string
+	KindsOnlyInTarget      []string
+	AttributesOnlyInBackup []string
+	AttributesOnlyInTarget []string
+}
+
+// Empty reports whether both schemas have the same kinds and attributes.
+func (d SchemaDiff) Empty() bool {
+	return len(d.KindsOnlyInBackup)+len(d.KindsOnlyInTarget)+len(d.AttributesOnlyInBackup)+len(d.AttributesOnlyInTarget) == 0
+}
+
+// diffSchemaSnapshots compares the schema stored in a backup with the schema
+// of the target deployment.
+func diffSchemaSnapshots(backup, target []byte) (SchemaDiff, error) {
+	backupKinds, err := schemaKinds(backup)
+	if err != nil {
+		return SchemaDiff{}, err
+	}
+	targetKinds, err := schemaKinds(target)
+	if err != nil {
+		return SchemaDiff{}, err
+	}
+
+	var diff SchemaDiff
+	for kind, attributes := range backupKinds {
+		targetAttributes, ok := targetKinds[kind]
+		if !ok {
+			diff.KindsOnlyInBackup = append(diff.KindsOnlyInBackup, kind)
+			continue
+		}
+		for _, attribute := range attributes {
+			if !slices.Contains(targetAttributes, attribute) {
+				diff.AttributesOnlyInBackup = append(diff.AttributesOnlyInBackup, kind+"."+attribute)
+			}
+		}
+	}
+	for kind, attributes := range targetKinds {
+		backupAttributes, ok := backupKinds[kind]
+		if !ok {
+			diff.KindsOnlyInTarget = append(diff.KindsOnlyInTarget, kind)
+			continue
+		}
+		for _, attribute := range attributes {
+			if !slices.Contains(backupAttributes, attribute) {
+				diff.AttributesOnlyInTarget = append(diff.AttributesOnlyInTarget, kind+"."+attribute)
+			}
+		}
+	}
+	sort.Strings(diff.KindsOnlyInBackup)
+	sort.Strings(diff.KindsOnlyInTarget)
+	sort.Strings(diff.AttributesOnlyInBackup)
+	sort.Strings(diff.AttributesOnlyInTarget)
+	return diff, nil
+}
+
+// Write prints the differences, one kind or attribute per line.
+func (d SchemaDiff) Write(w io.Writer) {
+	sections := []struct {
+		title string
+		items []string
+	}{
+		{"Node kinds only in the backup:", d.KindsOnlyInBackup},
+		{"Node kinds only in the target (their data is lost by the restore):", d.KindsOnlyInTarget},
+		{"Attributes only in the backup:", d.AttributesOnlyInBackup},
+		{"Attributes only in the target (their values are lost by the restore):", d.AttributesOnlyInTarget},
+	}
+	for _, section := range sections {
+		if len(section.items) == 0 {
+			continue
+		}
+		fmt.Fprintln(w, section.title)
+		for _, item := range section.items {
+			fmt.Fprintf(w, "  %s\n", item)
+		}
+	}
+}
+
+// schemaDiffError refuses a restore whose schema differs from the target's,
+// unless --accept-schema-diff is set.
+func (iops *InfrahubOps) schemaDiffError(diff SchemaDiff) error {
+	if diff.Empty() {
+		return nil
+	}
+	if iops.config.AcceptSchemaDiff {
+		logrus.Warn("Backup schema differs from the target schema; continuing because --accept-schema-diff is set")
+		return nil
+	}
+	return fmt.Errorf("backup schema differs from the target schema; review the differences with restore check and pass --accept-schema-diff to restore anyway")
+}
+
+// preflightSchemaSnapshot compares the schema snapshot of an extracted backup
+// with the target's current schema before anything is stopped. Backups
+// without a snapshot, or targets whose schema cannot be read, are not
+// compared.
+func (iops *InfrahubOps) preflightSchemaSnapshot(workDir string) error {
+	snapshot, err := os.ReadFile(filepath.Join(workDir, "backup", schemaSnapshotFilename))
+	if err != nil {
+		logrus.Debug("Backup has no schema snapshot; skipping schema comparison")
+		return nil
+	}
+	current, err := iops.exportSchemaSnapshot()
+	if err != nil {
+		logrus.Warnf("Could not read the target schema; skipping schema comparison: %v", err)
+		return nil
+	}
+	diff, err := diffSchemaSnapshots(snapshot, current)
+	if err != nil {
+		logrus.Warnf("Could not compare schemas: %v", err)
+		return nil
+	}
+	if diff.Empty() {
+		logrus.Info("Backup schema matches the target schema")
+		return nil
+	}
+	var report strings.Builder
+	diff.Write(&report)
+	for _, line := range strings.Split(strings.TrimRight(report.String(), "\n"), "\n") {
+		logrus.Warn(line)
+	}
+	return iops.schemaDiffError(diff)
+}
+
+// CheckRestoreSchema compares the schema snapshot of a backup with the
+// target's current schema and writes the differences to w, without stopping
+// or restoring anything.
+func (iops *InfrahubOps) CheckRestoreSchema(backupFile, decryptKey string, w io.Writer) error {
+	snapshot, err := iops.BackupSchemaSnapshot(backupFile, decryptKey)
+	if err != nil {
+		return err
+	}
+	current, err := iops.exportSchemaSnapshot()
+	if err != nil {
+		return err
+	}
+	diff, err := diffSchemaSnapshots(snapshot, current)
+	if err != nil {
+		return err
+	}
+	if diff.Empty() {
+		fmt.Fprintln(w, "Backup schema matches the target schema")
+		return nil
+	}
+	diff.Write(w)
+	return iops.schemaDiffError(diff)
+}
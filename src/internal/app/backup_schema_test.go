@@ -0,0 +1,160 @@
+package app
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const testBackupSchema = `{
+  "main": "abc",
+  "nodes": [
+    {"namespace": "Infra", "name": "Device", "attributes": [{"name": "name"}, {"name": "serial"}]},
+    {"kind": "InfraSite", "attributes": [{"name": "name"}]}
+  ],
+  "generics": [{"kind": "CoreNode", "attributes": []}]
+}`
+
+func TestDiffSchemaSnapshots(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		want   SchemaDiff
+	}{
+		{name: "same", target: testBackupSchema},
+		{
+			name:   "evolved target",
+			target: `{"nodes": [{"kind": "InfraDevice", "attributes": [{"name": "name"}, {"name": "role"}]}, {"kind": "InfraRack", "attributes": []}], "generics": [{"kind": "CoreNode"}]}`,
+			want: SchemaDiff{
+				KindsOnlyInBackup:      []string{"InfraSite"},
+				KindsOnlyInTarget:      []string{"InfraRack"},
+				AttributesOnlyInBackup: []string{"InfraDevice.serial"},
+				AttributesOnlyInTarget: []string{"InfraDevice.role"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff, err := diffSchemaSnapshots([]byte(testBackupSchema), []byte(tt.target))
+			if err != nil {
+				t.Fatalf("diffSchemaSnapshots() error = %v", err)
+			}
+			if !slices.Equal(diff.KindsOnlyInBackup, tt.want.KindsOnlyInBackup) ||
+				!slices.Equal(diff.KindsOnlyInTarget, tt.want.KindsOnlyInTarget) ||
+				!slices.Equal(diff.AttributesOnlyInBackup, tt.want.AttributesOnlyInBackup) ||
+				!slices.Equal(diff.AttributesOnlyInTarget, tt.want.AttributesOnlyInTarget) {
+				t.Errorf("diffSchemaSnapshots() = %+v, want %+v", diff, tt.want)
+			}
+			if diff.Empty() != tt.want.Empty() {
+				t.Errorf("Empty() = %v", diff.Empty())
+			}
+		})
+	}
+}
+
+func TestExportSchemaSnapshot(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		err     error
+		wantErr bool
+	}{
+		{name: "normalized", output: `{"nodes":[],"main":"abc"}`},
+		{name: "server down", err: errors.New("connection refused"), wantErr: true},
+		{name: "not json", output: "Internal Server Error", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeExecutor().on("/api/schema?branch=main", tt.output, tt.err)
+			snapshot, err := newFakeDockerOps(fake).exportSchemaSnapshot()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("exportSchemaSnapshot() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(snapshot) != "{\n  \"main\": \"abc\",\n  \"nodes\": []\n}" {
+				t.Errorf("snapshot = %s", snapshot)
+			}
+		})
+	}
+}
+
+func TestBackupSchemaSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	sourceDir := filepath.Join(dir, "work")
+	if err := os.MkdirAll(filepath.Join(sourceDir, "backup", "database"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sourceDir, "backup", "database", "neo4j.dump"), []byte("graph"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	withoutSchema, err := defaultArchivePipeline(false, false).Write(sourceDir, "backup/", filepath.Join(dir, "without"), ArchiveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sourceDir, "backup", schemaSnapshotFilename), []byte(testBackupSchema), 0644); err != nil {
+		t.Fatal(err)
+	}
+	withSchema, err := defaultArchivePipeline(false, false).Write(sourceDir, "backup/", filepath.Join(dir, "with"), ArchiveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	iops := NewInfrahubOps()
+	snapshot, err := iops.BackupSchemaSnapshot(withSchema, "")
+	if err != nil || string(snapshot) != testBackupSchema {
+		t.Errorf("BackupSchemaSnapshot() = %q, %v", snapshot, err)
+	}
+	if _, err := iops.BackupSchemaSnapshot(withoutSchema, ""); err == nil || !strings.Contains(err.Error(), "no schema snapshot") {
+		t.Errorf("BackupSchemaSnapshot() without snapshot error = %v", err)
+	}
+}
+
+func TestPreflightSchemaSnapshot(t *testing.T) {
+	evolved := `{"nodes": [{"kind": "InfraDevice", "attributes": [{"name": "name"}, {"name": "serial"}, {"name": "role"}]}, {"kind": "InfraSite", "attributes": [{"name": "name"}]}], "generics": [{"kind": "CoreNode"}]}`
+	tests := []struct {
+		name      string
+		snapshot  string
+		target    string
+		targetErr error
+		accept    bool
+		wantErr   bool
+	}{
+		{name: "no snapshot", target: evolved},
+		{name: "same schema", snapshot: testBackupSchema, target: testBackupSchema},
+		{name: "target unreadable", snapshot: testBackupSchema, targetErr: errors.New("connection refused")},
+		{name: "differs", snapshot: testBackupSchema, target: evolved, wantErr: true},
+		{name: "differs accepted", snapshot: testBackupSchema, target: evolved, accept: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workDir := t.TempDir()
+			if err := os.MkdirAll(filepath.Join(workDir, "backup"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if tt.snapshot != "" {
+				if err := os.WriteFile(filepath.Join(workDir, "backup", schemaSnapshotFilename), []byte(tt.snapshot), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			iops := newFakeDockerOps(newFakeExecutor().on("/api/schema", tt.target, tt.targetErr))
+			iops.config.AcceptSchemaDiff = tt.accept
+			err := iops.preflightSchemaSnapshot(workDir)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("preflightSchemaSnapshot() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSchemaDiffWrite(t *testing.T) {
+	diff := SchemaDiff{KindsOnlyInTarget: []string{"InfraRack"}, AttributesOnlyInTarget: []string{"InfraDevice.role"}}
+	var out strings.Builder
+	diff.Write(&out)
+	want := "Node kinds only in the target (their data is lost by the restore):\n  InfraRack\n" +
+		"Attributes only in the target (their values are lost by the restore):\n  InfraDevice.role\n"
+	if out.String() != want {
+		t.Errorf("Write() =\n%s\nwant\n%s", out.String(), want)
+	}
+}
@@ -0,0 +1,83 @@
+package app
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// defaultHealthWatchRetries is how often a service that stops during the
+// health watch is started again.
+const defaultHealthWatchRetries = 3
+
+var healthWatchPollInterval = 10 * time.Second
+
+// restartAfterBackup starts the services a Community Edition backup stopped
+// and, with --health-watch, watches them until they are stable. Services that
+// do not stabilize are recorded as degraded in the run report; the backup
+// itself is unaffected.
+func (iops *InfrahubOps) restartAfterBackup(services []string) error {
+	if err := iops.startAppContainers(services); err != nil {
+		return err
+	}
+	degraded := iops.watchRestartedServices(services, iops.config.HealthWatch, iops.config.HealthWatchRetries)
+	if len(degraded) > 0 {
+		logrus.WithField("services", strings.Join(degraded, ",")).
+			Errorf("Services did not stabilize within %s after the backup; deployment is degraded", iops.config.HealthWatch)
+		iops.recordDegraded(degraded)
+	}
+	return nil
+}
+
+// watchRestartedServices polls services for period and starts any that is not
+// running again, at most retries times each, backing off exponentially from
+// the poll interval. It returns the services still down when period ends.
+func (iops *InfrahubOps) watchRestartedServices(services []string, period time.Duration, retries int) []string {
+	if period <= 0 || len(services) == 0 {
+		return nil
+	}
+	logrus.Infof("Watching restarted services for %s...", period)
+
+	attempts := map[string]int{}
+	nextStart := map[string]time.Time{}
+	deadline := time.Now().Add(period)
+	var down []string
+	for {
+		running, err := iops.RunningServices(services...)
+		if err != nil {
+			logrus.Warnf("Could not check restarted services: %v", err)
+		} else {
+			down = down[:0]
+			for _, service := range services {
+				if !running[service] {
+					down = append(down, service)
+				}
+			}
+		}
+
+		now := time.Now()
+		if !now.Before(deadline) {
+			break
+		}
+		for _, service := range down {
+			if attempts[service] >= retries || now.Before(nextStart[service]) {
+				continue
+			}
+			attempts[service]++
+			nextStart[service] = now.Add(healthWatchPollInterval << attempts[service])
+			logrus.Warnf("%s is not running; starting it again (attempt %d/%d)", service, attempts[service], retries)
+			if err := iops.StartServices(service); err != nil {
+				logrus.Warnf("Failed to start %s: %v", service, err)
+			}
+		}
+		time.Sleep(min(healthWatchPollInterval, time.Until(deadline)))
+	}
+
+	if len(down) == 0 {
+		logrus.Info("Restarted services are stable")
+		return nil
+	}
+	return slices.Clone(down)
+}
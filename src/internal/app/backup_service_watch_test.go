@@ -0,0 +1,91 @@
+package app
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flappingExecutor answers docker compose ps with the next state in a script,
+// repeating the last one.
+type flappingExecutor struct {
+	*fakeExecutor
+	mu     sync.Mutex
+	states []string
+}
+
+func (f *flappingExecutor) runCommand(name string, args ...string) (string, error) {
+	output, err := f.fakeExecutor.runCommand(name, args...)
+	if !strings.Contains(strings.Join(args, " "), "ps -a --format json") {
+		return output, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	state := f.states[0]
+	if len(f.states) > 1 {
+		f.states = f.states[1:]
+	}
+	return `[{"Service":"infrahub-server","State":"running"},{"Service":"task-worker","State":"` + state + `"}]`, nil
+}
+
+func TestWatchRestartedServices(t *testing.T) {
+	restore := healthWatchPollInterval
+	healthWatchPollInterval = time.Millisecond
+	t.Cleanup(func() { healthWatchPollInterval = restore })
+
+	tests := []struct {
+		name         string
+		states       []string
+		retries      int
+		wantDegraded []string
+		wantStarts   int
+	}{
+		{name: "stable", states: []string{"running"}, retries: 3},
+		{name: "recovers", states: []string{"exited", "running"}, retries: 3, wantStarts: 1},
+		{name: "crash loop", states: []string{"restarting"}, retries: 2, wantDegraded: []string{"task-worker"}, wantStarts: 2},
+		{name: "no retries", states: []string{"exited"}, wantDegraded: []string{"task-worker"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &flappingExecutor{fakeExecutor: newFakeExecutor(), states: tt.states}
+			iops := newFakeDockerOps(fake)
+
+			degraded := iops.watchRestartedServices([]string{"infrahub-server", "task-worker"}, 200*time.Millisecond, tt.retries)
+			if !slices.Equal(degraded, tt.wantDegraded) {
+				t.Errorf("degraded = %v, want %v", degraded, tt.wantDegraded)
+			}
+			if got := fake.commands("start task-worker"); len(got) != tt.wantStarts {
+				t.Errorf("starts = %v, want %d", got, tt.wantStarts)
+			}
+			if got := fake.commands("start infrahub-server"); len(got) != 0 {
+				t.Errorf("running service was started again: %v", got)
+			}
+		})
+	}
+}
+
+func TestRestartAfterBackupRecordsDegraded(t *testing.T) {
+	restore := healthWatchPollInterval
+	healthWatchPollInterval = time.Millisecond
+	t.Cleanup(func() { healthWatchPollInterval = restore })
+
+	fake := &flappingExecutor{fakeExecutor: newFakeExecutor(), states: []string{"exited"}}
+	iops := newFakeDockerOps(fake)
+	iops.config.HealthWatch = 50 * time.Millisecond
+	iops.config.HealthWatchRetries = 1
+
+	err := iops.RunWithReport("backup", func() error {
+		if err := iops.restartAfterBackup([]string{"infrahub-server", "task-worker"}); err != nil {
+			return err
+		}
+		if !slices.Equal(iops.report.Degraded, []string{"task-worker"}) {
+			t.Errorf("report degraded = %v", iops.report.Degraded)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("restartAfterBackup() error = %v, degraded services must not fail the backup", err)
+	}
+}
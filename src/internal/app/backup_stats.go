@@ -0,0 +1,274 @@
+package app
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// backupComponents maps the first path element of a file inside the backup
+// directory to the component it belongs to. Other files count as metadata.
+var backupComponents = map[string]string{
+	neo4jBackupDirName:              "database",
+	prefectDumpFilename:             "task-manager",
+	prefectDumpDirName:              "task-manager",
+	objectStoreDirName:              "object-store",
+	messageQueueDefinitionsFilename: "message-queue",
+	schemaSnapshotFilename:          "schema",
+	composeManifestFilename:         composeComponent,
+	helmValuesFilename:              helmComponent,
+}
+
+const metadataComponent = "metadata"
+
+// backupComponent returns the component of a path relative to the backup
+// directory.
+func backupComponent(relPath string) string {
+	first, _, _ := strings.Cut(filepath.ToSlash(relPath), "/")
+	if name, ok := backupComponents[first]; ok {
+		return name
+	}
+	return metadataComponent
+}
+
+// ComponentStats is the size of one backup component before and after
+// compression, with a fingerprint of its file checksums.
+type ComponentStats struct {
+	Name              string `json:"name"`
+	UncompressedBytes int64  `json:"uncompressed_bytes"`
+	CompressedBytes   int64  `json:"compressed_bytes"`
+	Fingerprint       string `json:"fingerprint,omitempty"`
+}
+
+// Ratio returns the compression ratio, or 0 when nothing was compressed.
+func (c ComponentStats) Ratio() float64 {
+	if c.CompressedBytes <= 0 {
+		return 0
+	}
+	return float64(c.UncompressedBytes) / float64(c.CompressedBytes)
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// archiveStats attributes the bytes of a tar stream to backup components as
+// it is compressed. Compressed bytes are measured between tar entries, so
+// buffering in the compressor makes the split between components approximate;
+// the totals are exact.
+type archiveStats struct {
+	prefix          string
+	raw, compressed *countingWriter
+	current         string
+	lastRaw         int64
+	lastCompressed  int64
+	components      map[string]*ComponentStats
+}
+
+func newArchiveStats(pathInTar string) *archiveStats {
+	return &archiveStats{
+		prefix:     strings.TrimSuffix(filepath.ToSlash(pathInTar), "/") + "/",
+		current:    metadataComponent,
+		components: map[string]*ComponentStats{},
+	}
+}
+
+// entry is called before each tar entry is written.
+func (s *archiveStats) entry(name string) {
+	s.flush()
+	s.current = backupComponent(strings.TrimPrefix(name, s.prefix))
+}
+
+// flush charges the bytes written since the previous entry to the current
+// component.
+func (s *archiveStats) flush() {
+	stats, ok := s.components[s.current]
+	if !ok {
+		stats = &ComponentStats{Name: s.current}
+		s.components[s.current] = stats
+	}
+	stats.UncompressedBytes += s.raw.n - s.lastRaw
+	stats.CompressedBytes += s.compressed.n - s.lastCompressed
+	s.lastRaw, s.lastCompressed = s.raw.n, s.compressed.n
+}
+
+// result returns the components sorted by name.
+func (s *archiveStats) result() []ComponentStats {
+	stats := make([]ComponentStats, 0, len(s.components))
+	for _, c := range s.components {
+		if c.UncompressedBytes > 0 || c.CompressedBytes > 0 {
+			stats = append(stats, *c)
+		}
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
+
+// componentFingerprints digests the checksums of each component's files. A
+// component whose fingerprint matches the previous backup is unchanged, and
+// so deduplicates entirely on content-addressed storage. SHA256 checksums are
+// digested bare, as archives recorded them before the algorithm was added.
+func componentFingerprints(checksums map[string]string) map[string]string {
+	paths := make([]string, 0, len(checksums))
+	for path := range checksums {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	hashers := map[string]hash.Hash{}
+	for _, path := range paths {
+		component := backupComponent(path)
+		h, ok := hashers[component]
+		if !ok {
+			h = sha256.New()
+			hashers[component] = h
+		}
+		checksum := checksums[path]
+		if algorithm, sum := parseChecksum(checksum); algorithm == checksumSHA256 {
+			checksum = sum
+		}
+		fmt.Fprintf(h, "%s\x00%s\n", filepath.ToSlash(path), checksum)
+	}
+
+	fingerprints := map[string]string{}
+	for component, h := range hashers {
+		fingerprints[component] = fmt.Sprintf("%x", h.Sum(nil))
+	}
+	return fingerprints
+}
+
+// previousBackup returns the newest catalog entry with a known size created
+// before entry.
+func (c *BackupCatalog) previousBackup(entry *CatalogEntry) *CatalogEntry {
+	var previous *CatalogEntry
+	for i := range c.Entries {
+		candidate := &c.Entries[i]
+		if candidate.BackupID == entry.BackupID || candidate.SizeBytes <= 0 || candidate.CreatedAt > entry.CreatedAt {
+			continue
+		}
+		if previous == nil || candidate.CreatedAt > previous.CreatedAt {
+			previous = candidate
+		}
+	}
+	return previous
+}
+
+// backupGrowthTrend fits a line through the archive sizes in the catalog and
+// returns the growth in bytes per day. It needs at least three backups spread
+// over more than an hour.
+func backupGrowthTrend(entries []CatalogEntry) (float64, bool) {
+	var xs, ys []float64
+	for _, entry := range entries {
+		created, err := time.Parse(time.RFC3339, entry.CreatedAt)
+		if err != nil || entry.SizeBytes <= 0 {
+			continue
+		}
+		xs = append(xs, float64(created.Unix())/86400)
+		ys = append(ys, float64(entry.SizeBytes))
+	}
+	if len(xs) < 3 {
+		return 0, false
+	}
+
+	var meanX, meanY float64
+	for i := range xs {
+		meanX += xs[i]
+		meanY += ys[i]
+	}
+	meanX /= float64(len(xs))
+	meanY /= float64(len(ys))
+
+	var num, den float64
+	for i := range xs {
+		num += (xs[i] - meanX) * (ys[i] - meanY)
+		den += (xs[i] - meanX) * (xs[i] - meanX)
+	}
+	if den < (1.0/24)*(1.0/24) {
+		return 0, false
+	}
+	return num / den, true
+}
+
+// formatByteDelta formats a signed size difference, for example "+1.2 MB".
+func formatByteDelta(delta int64) string {
+	if delta < 0 {
+		return "-" + formatBytes(-delta)
+	}
+	return "+" + formatBytes(delta)
+}
+
+// reportBackupStats stores the component statistics of backupID in the
+// catalog and logs the compression ratio of each component, the change from
+// the previous backup and the growth trend of the backups in the catalog.
+func (iops *InfrahubOps) reportBackupStats(backupID string, components []ComponentStats, checksums map[string]string) error {
+	catalog, err := loadBackupCatalog(iops.config.BackupDir)
+	if err != nil {
+		return err
+	}
+	entry := catalog.find(backupID)
+	if entry == nil {
+		return fmt.Errorf("backup %s is not in the catalog", backupID)
+	}
+
+	fingerprints := componentFingerprints(checksums)
+	for i := range components {
+		components[i].Fingerprint = fingerprints[components[i].Name]
+	}
+	entry.Components = components
+	if err := catalog.save(); err != nil {
+		return err
+	}
+
+	previous := catalog.previousBackup(entry)
+	previousFingerprints := map[string]string{}
+	if previous != nil {
+		for _, c := range previous.Components {
+			previousFingerprints[c.Name] = c.Fingerprint
+		}
+	}
+
+	for _, c := range components {
+		fields := logrus.Fields{
+			"component":         c.Name,
+			"size_bytes":        c.UncompressedBytes,
+			"size_human":        formatBytes(c.UncompressedBytes),
+			"compressed_bytes":  c.CompressedBytes,
+			"compressed_human":  formatBytes(c.CompressedBytes),
+			"compression_ratio": fmt.Sprintf("%.2f", c.Ratio()),
+		}
+		if prev, ok := previousFingerprints[c.Name]; ok && c.Fingerprint != "" {
+			fields["unchanged"] = prev == c.Fingerprint
+		}
+		logrus.WithFields(fields).Info("Backup component")
+	}
+
+	fields := logrus.Fields{"size_bytes": entry.SizeBytes}
+	if previous != nil {
+		delta := entry.SizeBytes - previous.SizeBytes
+		fields["previous_backup"] = previous.BackupID
+		fields["delta_bytes"] = delta
+		fields["delta_human"] = formatByteDelta(delta)
+		fields["delta_percent"] = fmt.Sprintf("%+.1f", float64(delta)*100/float64(previous.SizeBytes))
+	}
+	if perDay, ok := backupGrowthTrend(catalog.Entries); ok {
+		fields["growth_per_day"] = formatByteDelta(int64(perDay))
+		fields["projected_30d"] = formatBytes(max(0, entry.SizeBytes+int64(perDay*30)))
+	}
+	logrus.WithFields(fields).Info("Backup size trend")
+	return nil
+}
@@ -0,0 +1,174 @@
+package app
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBackupComponent(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "database/neo4j.dump", want: "database"},
+		{path: filepath.Join("database", "data", "store"), want: "database"},
+		{path: "prefect.dump", want: "task-manager"},
+		{path: "object-store/ab/cd", want: "object-store"},
+		{path: "backup_information.json", want: "metadata"},
+		{path: "", want: "metadata"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := backupComponent(tt.path); got != tt.want {
+				t.Errorf("backupComponent(%q) = %s, want %s", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteWithStats(t *testing.T) {
+	sourceDir := t.TempDir()
+	files := map[string]string{
+		"backup/database/neo4j.dump":     strings.Repeat("graph", 20000),
+		"backup/prefect.dump":            strings.Repeat("flow", 1000),
+		"backup/backup_information.json": "{}",
+	}
+	for name, content := range files {
+		path := filepath.Join(sourceDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	base := filepath.Join(t.TempDir(), "infrahub_backup")
+	path, stats, err := defaultArchivePipeline(false, false).WriteWithStats(sourceDir, "backup/", base, ArchiveOptions{})
+	if err != nil {
+		t.Fatalf("WriteWithStats: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var compressed int64
+	byName := map[string]ComponentStats{}
+	for _, c := range stats {
+		compressed += c.CompressedBytes
+		byName[c.Name] = c
+	}
+	if compressed != info.Size() {
+		t.Errorf("compressed bytes sum to %d, archive is %d bytes", compressed, info.Size())
+	}
+	for _, name := range []string{"database", "task-manager", "metadata"} {
+		if byName[name].UncompressedBytes == 0 {
+			t.Errorf("component %s missing from %+v", name, stats)
+		}
+	}
+	if db := byName["database"]; db.UncompressedBytes < 100000 || db.Ratio() < 10 {
+		t.Errorf("database stats = %+v, ratio %.1f", db, db.Ratio())
+	}
+}
+
+func TestComponentFingerprints(t *testing.T) {
+	base := map[string]string{"database/neo4j.dump": "aa", "prefect.dump": "bb"}
+	changed := map[string]string{"database/neo4j.dump": "aa", "prefect.dump": "cc"}
+
+	a, b := componentFingerprints(base), componentFingerprints(changed)
+	if a["database"] == "" || a["database"] != b["database"] {
+		t.Errorf("database fingerprints differ: %s, %s", a["database"], b["database"])
+	}
+	if a["task-manager"] == b["task-manager"] {
+		t.Error("task-manager fingerprint did not change with its checksum")
+	}
+}
+
+func TestBackupGrowthTrend(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []CatalogEntry
+		want    float64
+		wantOK  bool
+	}{
+		{
+			name: "linear growth",
+			entries: []CatalogEntry{
+				{CreatedAt: "2026-10-01T00:00:00Z", SizeBytes: 1000},
+				{CreatedAt: "2026-10-02T00:00:00Z", SizeBytes: 1100},
+				{CreatedAt: "2026-10-04T00:00:00Z", SizeBytes: 1300},
+			},
+			want:   100,
+			wantOK: true,
+		},
+		{
+			name: "shrinking",
+			entries: []CatalogEntry{
+				{CreatedAt: "2026-10-01T00:00:00Z", SizeBytes: 3000},
+				{CreatedAt: "2026-10-02T00:00:00Z", SizeBytes: 2000},
+				{CreatedAt: "2026-10-03T00:00:00Z", SizeBytes: 1000},
+			},
+			want:   -1000,
+			wantOK: true,
+		},
+		{
+			name: "too few backups",
+			entries: []CatalogEntry{
+				{CreatedAt: "2026-10-01T00:00:00Z", SizeBytes: 1000},
+				{CreatedAt: "2026-10-02T00:00:00Z", SizeBytes: 1100},
+				{CreatedAt: "2026-10-03T00:00:00Z"},
+			},
+		},
+		{
+			name: "same instant",
+			entries: []CatalogEntry{
+				{CreatedAt: "2026-10-01T00:00:00Z", SizeBytes: 1000},
+				{CreatedAt: "2026-10-01T00:00:00Z", SizeBytes: 1100},
+				{CreatedAt: "2026-10-01T00:00:00Z", SizeBytes: 1200},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := backupGrowthTrend(tt.entries)
+			if ok != tt.wantOK || math.Abs(got-tt.want) > 0.001 {
+				t.Errorf("backupGrowthTrend() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestReportBackupStats(t *testing.T) {
+	iops := NewInfrahubOps()
+	iops.config.BackupDir = t.TempDir()
+	catalog, err := loadBackupCatalog(iops.config.BackupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	catalog.upsert(CatalogEntry{BackupID: "infrahub_backup_20261015_220000", CreatedAt: "2026-10-15T22:00:00Z", SizeBytes: 1000})
+	catalog.upsert(CatalogEntry{BackupID: "infrahub_backup_20261016_220000", CreatedAt: "2026-10-16T22:00:00Z", SizeBytes: 1500})
+	if err := catalog.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := []ComponentStats{{Name: "database", UncompressedBytes: 4000, CompressedBytes: 1000}}
+	if err := iops.reportBackupStats("infrahub_backup_20261016_220000", stats, map[string]string{"database/neo4j.dump": "aa"}); err != nil {
+		t.Fatalf("reportBackupStats() error = %v", err)
+	}
+	catalog, _ = loadBackupCatalog(iops.config.BackupDir)
+	entry := catalog.find("infrahub_backup_20261016_220000")
+	if len(entry.Components) != 1 || entry.Components[0].Fingerprint == "" {
+		t.Errorf("components recorded as %+v", entry.Components)
+	}
+	if previous := catalog.previousBackup(entry); previous == nil || previous.BackupID != "infrahub_backup_20261015_220000" {
+		t.Errorf("previousBackup() = %+v", previous)
+	}
+
+	if err := iops.reportBackupStats("unknown", stats, nil); err == nil {
+		t.Error("reportBackupStats() error = nil for a backup missing from the catalog")
+	}
+}
@@ -0,0 +1,330 @@
+package app
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// With --stream, the database dumps are read from the containers straight
+// into the archive instead of being copied to the work directory first, so a
+// backup needs local space for the archive only, or none when it is uploaded
+// directly to S3. The files that are cheap to stage, such as the metadata
+// and the object store, are still written to the work directory and follow
+// the dumps in the archive.
+//
+// A tar entry needs its size up front, which a dump read from a pipe does not
+// have. Such a dump is cut into chunk entries of at most streamChunkSize
+// bytes, each carrying PAX records naming the file it belongs to and its
+// offset; extractTar joins them back. Plain tar extracts the chunks as
+// <file>.chunk000000, <file>.chunk000001, ..., which concatenate to the file.
+
+// streamChunkSize is the size of the chunks a streamed dump is cut into; one
+// chunk is held in memory at a time.
+var streamChunkSize = 64 << 20
+
+const (
+	// PAX records of a chunk entry: the tar path of the file it belongs to,
+	// and its offset in that file.
+	paxStreamTarget = "INFRAHUB.stream.target"
+	paxStreamOffset = "INFRAHUB.stream.offset"
+)
+
+// backupStream is a database dump read into the archive.
+type backupStream struct {
+	phase string // event phase, e.g. neo4j_backup
+	name  string // path relative to the backup directory: the dump file, or the directory a tar stream is placed in
+	tar   bool   // the stream is a tar archive whose entries are copied, minus their first path element
+	open  func() (io.ReadCloser, error)
+}
+
+// backupStreams returns the dumps streamed by create --stream.
+func (iops *InfrahubOps) backupStreams(neo4jMetadata, neo4jEdition string, excludeTaskManager bool) ([]backupStream, error) {
+	neo4jFactory, err := iops.neo4jStreamFactory(neo4jEdition, neo4jMetadata)
+	if err != nil {
+		return nil, err
+	}
+	neo4j := backupStream{phase: "neo4j_backup", name: neo4jBackupDirName, tar: true, open: neo4jFactory}
+	if strings.EqualFold(neo4jEdition, neo4jEditionCommunity) {
+		neo4j = backupStream{phase: "neo4j_backup", name: path.Join(neo4jBackupDirName, iops.config.Neo4jDatabase+".dump"), open: neo4jFactory}
+	}
+	streams := []backupStream{neo4j}
+	if !excludeTaskManager {
+		postgresFactory, err := iops.postgresStreamFactory()
+		if err != nil {
+			return nil, err
+		}
+		streams = append(streams, backupStream{phase: "taskmanager_backup", name: prefectDumpFilename, open: postgresFactory})
+	}
+	return streams, nil
+}
+
+// checkStreamBackup rejects the create options that need the dumps or the
+// archive as local files. Without --s3-keep-local, an uploaded streamed
+// backup never exists locally.
+func (iops *InfrahubOps) checkStreamBackup(encrypt, s3Upload, s3KeepLocal bool) error {
+	if !iops.config.StreamBackup {
+		return nil
+	}
+	if encrypt {
+		return fmt.Errorf("--stream cannot be combined with --encrypt, which needs the size of the archive before encrypting it")
+	}
+	if iops.config.PgJobs > 0 {
+		return fmt.Errorf("--stream cannot be combined with --pg-jobs; the directory format cannot be streamed")
+	}
+	if !s3Upload || s3KeepLocal {
+		return nil
+	}
+	for flag, set := range map[string]bool{
+		"--split-size":     iops.config.SplitSize != "",
+		"--verify-restore": iops.config.VerifyRestore,
+		"--record-to":      iops.config.RecordTo != "",
+	} {
+		if set {
+			return fmt.Errorf("%s needs a local archive; use --s3-keep-local with --stream", flag)
+		}
+	}
+	return nil
+}
+
+// writeStreamedArchive writes a format 2 archive to w: the streams first,
+// then the files of workDir/backup. writeMetadata is called with the
+// checksums of the streamed files once they are known, and must write the
+// metadata file to workDir/backup.
+func (iops *InfrahubOps) writeStreamedArchive(w io.Writer, workDir string, streams []backupStream, writeMetadata func(checksums map[string]string) error) ([]ComponentStats, error) {
+	const pathInTar = "backup/"
+	stats := newArchiveStats(pathInTar)
+	t := newIndexedTar(w, pathInTar, stats)
+	algorithm := iops.config.checksumAlgorithm()
+	if algorithm == "" {
+		algorithm = defaultChecksumAlgorithm
+	}
+
+	checksums := map[string]string{}
+	for _, stream := range streams {
+		if err := iops.runPhase(stream.phase, func() error {
+			logrus.Infof("Streaming %s into the archive...", stream.name)
+			r, err := stream.open()
+			if err != nil {
+				return err
+			}
+			if stream.tar {
+				err = copyTarStream(t, r, pathInTar, stream.name, algorithm, checksums)
+			} else {
+				err = writeChunkedStream(t, r, pathInTar, stream.name, algorithm, checksums)
+			}
+			// Close reports the exit status of the dump, which a truncated
+			// stream does not show
+			if closeErr := r.Close(); err == nil && closeErr != nil {
+				err = fmt.Errorf("dump of %s failed: %w", stream.name, closeErr)
+			}
+			return err
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := writeMetadata(checksums); err != nil {
+		return nil, err
+	}
+	if err := writeTarEntries(t.tw, workDir, pathInTar, t.entry); err != nil {
+		return nil, err
+	}
+	if err := t.close(); err != nil {
+		return nil, err
+	}
+	return stats.result(), nil
+}
+
+// writeChunkedStream writes r to the archive as chunk entries of the file
+// name and records its checksum.
+func writeChunkedStream(t *indexedTar, r io.Reader, pathInTar, name, algorithm string, checksums map[string]string) error {
+	hasher, err := checksumHasher(algorithm)
+	if err != nil {
+		return err
+	}
+	target := path.Join(pathInTar, name)
+	buf := make([]byte, streamChunkSize)
+	var offset int64
+	for i := 0; ; i++ {
+		n, readErr := io.ReadFull(r, buf)
+		if readErr == io.EOF {
+			if i == 0 {
+				return fmt.Errorf("dump of %s is empty", name)
+			}
+			break
+		}
+		if readErr != nil && readErr != io.ErrUnexpectedEOF {
+			return fmt.Errorf("failed to read dump of %s: %w", name, readErr)
+		}
+		header := &tar.Header{
+			Name:     fmt.Sprintf("%s.chunk%06d", target, i),
+			Typeflag: tar.TypeReg,
+			Mode:     0644,
+			Size:     int64(n),
+			ModTime:  time.Now(),
+			Format:   tar.FormatPAX,
+			PAXRecords: map[string]string{
+				paxStreamTarget: target,
+				paxStreamOffset: strconv.FormatInt(offset, 10),
+			},
+		}
+		if err := t.add(header, bytes.NewReader(buf[:n])); err != nil {
+			return err
+		}
+		hasher.Write(buf[:n])
+		offset += int64(n)
+		if readErr == io.ErrUnexpectedEOF {
+			break
+		}
+	}
+	checksums[filepath.FromSlash(name)] = formatChecksum(algorithm, fmt.Sprintf("%x", hasher.Sum(nil)))
+	logrus.Infof("Streamed %s (%s)", name, formatBytes(offset))
+	return nil
+}
+
+// copyTarStream copies the entries of the tar stream r into the directory
+// dir of the archive, dropping their first path element, and records the
+// checksums of the files. Hard links are kept.
+func copyTarStream(t *indexedTar, r io.Reader, pathInTar, dir, algorithm string, checksums map[string]string) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s stream: %w", dir, err)
+		}
+		rel, ok := stripTarPath(header.Name, 1)
+		if !ok {
+			continue
+		}
+		name := path.Join(dir, rel)
+		header.Name = path.Join(pathInTar, name)
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := t.add(header, bytes.NewReader(nil)); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			hasher, err := checksumHasher(algorithm)
+			if err != nil {
+				return err
+			}
+			if err := t.add(header, io.TeeReader(tr, hasher)); err != nil {
+				return err
+			}
+			checksums[filepath.FromSlash(name)] = formatChecksum(algorithm, fmt.Sprintf("%x", hasher.Sum(nil)))
+		case tar.TypeLink:
+			// Hard links point at an earlier entry of the stream, which was
+			// moved the same way
+			linkRel, ok := stripTarPath(header.Linkname, 1)
+			if !ok {
+				return fmt.Errorf("invalid link target %s in %s stream", header.Linkname, dir)
+			}
+			linkName := path.Join(dir, linkRel)
+			header.Linkname = path.Join(pathInTar, linkName)
+			header.Size = 0
+			if err := t.add(header, bytes.NewReader(nil)); err != nil {
+				return err
+			}
+			if sum, ok := checksums[filepath.FromSlash(linkName)]; ok {
+				checksums[filepath.FromSlash(name)] = sum
+			}
+		default:
+			return fmt.Errorf("unsupported entry %s in %s stream", header.Name, dir)
+		}
+	}
+}
+
+// extractStreamChunk writes a chunk of a streamed dump, read from r, at its
+// offset in target.
+func extractStreamChunk(r io.Reader, target string, header *tar.Header) error {
+	offset, err := strconv.ParseInt(header.PAXRecords[paxStreamOffset], 10, 64)
+	if err != nil || offset < 0 {
+		return fmt.Errorf("invalid offset of chunk %s", header.Name)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if offset == 0 {
+		if err := f.Truncate(0); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeStreamedBackup writes the archive of a streamed backup to
+// BackupDir, or uploads it to S3 as it is written when upload is set. It
+// returns the local path or the S3 URI, with the archive size.
+func (iops *InfrahubOps) writeStreamedBackup(pipeline ArchivePipeline, workDir, backupID string, upload bool, streams []backupStream, writeMetadata func(checksums map[string]string) error) (string, int64, []ComponentStats, error) {
+	if err := pipeline.Validate(); err != nil {
+		return "", 0, nil, err
+	}
+	archivePath := pipeline.ArchivePath(filepath.Join(iops.config.BackupDir, backupID))
+	if !upload {
+		file, err := os.Create(archivePath)
+		if err != nil {
+			return "", 0, nil, fmt.Errorf("failed to create archive: %w", err)
+		}
+		counter := &countingWriter{w: file}
+		stats, err := iops.writeStreamedArchive(counter, workDir, streams, writeMetadata)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(archivePath)
+			return "", 0, nil, fmt.Errorf("failed to create archive: %w", err)
+		}
+		return archivePath, counter.n, stats, nil
+	}
+
+	pr, pw := io.Pipe()
+	type result struct {
+		uri string
+		err error
+	}
+	uploaded := make(chan result, 1)
+	go func() {
+		uri, err := iops.uploadStreamToS3(filepath.Base(archivePath), pr)
+		pr.CloseWithError(err) // unblocks the writer if the upload stopped reading
+		uploaded <- result{uri, err}
+	}()
+
+	counter := &countingWriter{w: pw}
+	stats, err := iops.writeStreamedArchive(counter, workDir, streams, writeMetadata)
+	// A write error aborts the upload, so no partial object is stored
+	pw.CloseWithError(err)
+	res := <-uploaded
+	if err != nil {
+		return "", 0, nil, fmt.Errorf("failed to create archive: %w", err)
+	}
+	if res.err != nil {
+		return "", 0, nil, res.err
+	}
+	return res.uri, counter.n, stats, nil
+}
@@ -0,0 +1,201 @@
+package app
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteStreamedBackup(t *testing.T) {
+	defer func(size int) { streamChunkSize = size }(streamChunkSize)
+	streamChunkSize = 8
+
+	var neo4jTar bytes.Buffer
+	tw := tar.NewWriter(&neo4jTar)
+	backupFile := []byte("neo4j enterprise backup")
+	tw.WriteHeader(&tar.Header{Name: "infrahubops/", Typeflag: tar.TypeDir, Mode: 0755})
+	tw.WriteHeader(&tar.Header{Name: "infrahubops/neo4j-2026-10-16.backup", Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(backupFile))})
+	tw.Write(backupFile)
+	tw.Close()
+	prefectDump := "PGDMP custom format dump of the task manager"
+
+	fake := newFakeExecutor().on("tar cf -", neo4jTar.String(), nil).on("pg_dump", prefectDump, nil)
+	iops := newFakeDockerOps(fake)
+	iops.config.BackupDir = t.TempDir()
+	streams, err := iops.backupStreams("all", "enterprise", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	workDir := t.TempDir()
+	writeTestFile(t, workDir, filepath.Join("backup", schemaSnapshotFilename), "{}")
+	var recorded map[string]string
+	writeMetadata := func(checksums map[string]string) error {
+		recorded = checksums
+		data, err := json.Marshal(&BackupMetadata{BackupID: "streamed", Checksums: checksums})
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(workDir, "backup", backupMetadataFilename), data, 0644)
+	}
+
+	path, size, stats, err := iops.writeStreamedBackup(defaultArchivePipeline(false, false), workDir, "streamed", false, streams, writeMetadata)
+	if err != nil {
+		t.Fatalf("writeStreamedBackup() error = %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Size() != size {
+		t.Errorf("archive size = %d, want %d (%v)", size, info.Size(), err)
+	}
+	components := map[string]bool{}
+	for _, c := range stats {
+		components[c.Name] = true
+	}
+	if !components["database"] || !components["task-manager"] {
+		t.Errorf("component stats = %+v, want database and task-manager", stats)
+	}
+	if _, err := readArchiveMember(path, "backup/"+backupMetadataFilename); err != nil {
+		t.Errorf("metadata not readable through the index: %v", err)
+	}
+
+	dest := t.TempDir()
+	if _, err := extractArchive(path, dest); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		prefectDumpFilename: prefectDump,
+		filepath.Join("database", "neo4j-2026-10-16.backup"): string(backupFile),
+		schemaSnapshotFilename:                               "{}",
+	} {
+		got, err := os.ReadFile(filepath.Join(dest, "backup", name))
+		if err != nil || string(got) != want {
+			t.Errorf("%s = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	if chunks, _ := filepath.Glob(filepath.Join(dest, "backup", "*.chunk*")); len(chunks) != 0 {
+		t.Errorf("chunks left after extraction: %v", chunks)
+	}
+	for name, sum := range recorded {
+		got, err := calculateChecksum(filepath.Join(dest, "backup", name), defaultChecksumAlgorithm)
+		if err != nil || got != sum {
+			t.Errorf("checksum of %s = %s, %v; recorded %s", name, got, err, sum)
+		}
+	}
+	if len(recorded) != 2 {
+		t.Errorf("streamed checksums = %v, want the two dumps", recorded)
+	}
+}
+
+func TestWriteStreamedBackupHardLinks(t *testing.T) {
+	var neo4jTar bytes.Buffer
+	tw := tar.NewWriter(&neo4jTar)
+	data := []byte("neo4j store file")
+	tw.WriteHeader(&tar.Header{Name: "infrahubops/", Typeflag: tar.TypeDir, Mode: 0755})
+	tw.WriteHeader(&tar.Header{Name: "infrahubops/neostore", Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(data))})
+	tw.Write(data)
+	tw.WriteHeader(&tar.Header{Name: "infrahubops/neostore.link", Typeflag: tar.TypeLink, Linkname: "infrahubops/neostore", Mode: 0644})
+	tw.Close()
+
+	iops := newFakeDockerOps(newFakeExecutor())
+	iops.config.BackupDir = t.TempDir()
+	streams := []backupStream{{phase: "neo4j_backup", name: neo4jBackupDirName, tar: true, open: func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(neo4jTar.Bytes())), nil
+	}}}
+	workDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(workDir, "backup"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	var recorded map[string]string
+	writeMetadata := func(checksums map[string]string) error {
+		recorded = checksums
+		return nil
+	}
+
+	path, _, _, err := iops.writeStreamedBackup(defaultArchivePipeline(false, false), workDir, "linked", false, streams, writeMetadata)
+	if err != nil {
+		t.Fatalf("writeStreamedBackup() error = %v", err)
+	}
+	dest := t.TempDir()
+	if _, err := extractArchive(path, dest); err != nil {
+		t.Fatal(err)
+	}
+	original := filepath.Join(dest, "backup", "database", "neostore")
+	link := filepath.Join(dest, "backup", "database", "neostore.link")
+	originalInfo, err := os.Stat(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	linkInfo, err := os.Stat(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(originalInfo, linkInfo) {
+		t.Error("hard link extracted as a separate file")
+	}
+	linkKey := filepath.Join("database", "neostore.link")
+	if sum := recorded[linkKey]; sum == "" || sum != recorded[filepath.Join("database", "neostore")] {
+		t.Errorf("checksums = %v, want the link recorded with the checksum of its target", recorded)
+	}
+}
+
+func TestWriteStreamedBackupFailedDump(t *testing.T) {
+	fake := newFakeExecutor().on("pg_dump", "PGDMP truncated", errors.New("exit status 1"))
+	iops := newFakeDockerOps(fake)
+	iops.config.BackupDir = t.TempDir()
+	streams := []backupStream{{phase: "taskmanager_backup", name: prefectDumpFilename, open: func() (io.ReadCloser, error) {
+		factory, _ := iops.postgresStreamFactory()
+		return factory()
+	}}}
+
+	workDir := t.TempDir()
+	writeMetadata := func(map[string]string) error { return nil }
+	_, _, _, err := iops.writeStreamedBackup(defaultArchivePipeline(false, false), workDir, "failed", false, streams, writeMetadata)
+	if err == nil || !strings.Contains(err.Error(), "dump of prefect.dump failed") {
+		t.Fatalf("writeStreamedBackup() error = %v, want the dump failure", err)
+	}
+	if entries, _ := os.ReadDir(iops.config.BackupDir); len(entries) != 0 {
+		t.Errorf("partial archive left: %v", entries)
+	}
+}
+
+func TestCheckStreamBackup(t *testing.T) {
+	tests := []struct {
+		name        string
+		modify      func(cfg *Configuration)
+		encrypt     bool
+		s3Upload    bool
+		s3KeepLocal bool
+		wantErr     string
+	}{
+		{name: "not streamed", modify: func(cfg *Configuration) { cfg.StreamBackup = false }, encrypt: true},
+		{name: "local"},
+		{name: "encrypted", encrypt: true, wantErr: "--encrypt"},
+		{name: "parallel dump", modify: func(cfg *Configuration) { cfg.PgJobs = 4 }, wantErr: "--pg-jobs"},
+		{name: "split local archive", modify: func(cfg *Configuration) { cfg.SplitSize = "1G" }},
+		{name: "split streamed to s3", modify: func(cfg *Configuration) { cfg.SplitSize = "1G" }, s3Upload: true, wantErr: "--split-size needs a local archive"},
+		{name: "verify streamed to s3", modify: func(cfg *Configuration) { cfg.VerifyRestore = true }, s3Upload: true, wantErr: "--verify-restore"},
+		{name: "verify kept locally", modify: func(cfg *Configuration) { cfg.VerifyRestore = true }, s3Upload: true, s3KeepLocal: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iops := newFakeDockerOps(newFakeExecutor())
+			iops.config.StreamBackup = true
+			if tt.modify != nil {
+				tt.modify(iops.config)
+			}
+			err := iops.checkStreamBackup(tt.encrypt, tt.s3Upload, tt.s3KeepLocal)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkStreamBackup() error = %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("checkStreamBackup() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
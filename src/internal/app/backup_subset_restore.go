@@ -0,0 +1,594 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sirupsen/logrus"
+)
+
+// subsetBatchSize is the number of objects written per GraphQL request.
+const subsetBatchSize = 50
+
+// infrahubNullValue is how Infrahub stores an attribute without a value.
+const infrahubNullValue = "NULL"
+
+// SubsetRestoreOptions selects the objects a subset restore copies from a
+// backup into the running instance.
+type SubsetRestoreOptions struct {
+	Kinds      []string // node or generic kinds, e.g. InfraDevice
+	Namespaces []string // schema namespaces, e.g. Infra
+	Branch     string   // branch read from the backup and written to; the default branch when empty
+	Neo4jImage string   // image loading the backup; derived from the metadata when empty
+	DryRun     bool     // list the objects instead of writing them
+}
+
+// Validate checks the selection before the backup is read. Kinds and
+// namespaces are part of the mutation names, so nothing else is accepted.
+func (o SubsetRestoreOptions) Validate() error {
+	if len(o.Kinds) == 0 && len(o.Namespaces) == 0 {
+		return fmt.Errorf("select the objects to restore with --kind or --namespace")
+	}
+	for _, name := range append(slices.Clone(o.Kinds), o.Namespaces...) {
+		if !registerKindPattern.MatchString(name) {
+			return fmt.Errorf("invalid kind or namespace %q: expected a name such as InfraDevice or Infra", name)
+		}
+	}
+	return nil
+}
+
+// subsetObject is an Infrahub object exported from a backup.
+type subsetObject struct {
+	ID         string
+	Kind       string
+	Attributes map[string]any
+	// Peers maps relationship names to the IDs of the related objects.
+	Peers map[string][]string
+}
+
+// RestoreSubset copies the objects of the selected kinds and namespaces, as
+// they were in the backup, into the running instance without stopping or
+// wiping it. The backup is loaded into a throwaway Neo4j container like
+// --rehearse; its objects are read with Cypher and written back through the
+// GraphQL API, creating deleted objects under their original IDs and
+// resetting the attributes and relationships of existing ones.
+func (iops *InfrahubOps) RestoreSubset(backupFile, decryptKey string, opts SubsetRestoreOptions, w io.Writer) error {
+	if iops.config.Backend == BackendPlakar {
+		return fmt.Errorf("restore subset is not supported with the plakar backend")
+	}
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	if err := iops.executor.runCommandQuiet("docker", "version"); err != nil {
+		return fmt.Errorf("restore subset needs a working docker CLI on this host: %w", err)
+	}
+	if !opts.DryRun {
+		if err := iops.DetectEnvironment(); err != nil {
+			return err
+		}
+		releaseLock, err := iops.acquireOperationLock("restore")
+		if err != nil {
+			return err
+		}
+		defer releaseLock()
+	}
+
+	workDir, metadata, cleanup, err := iops.extractBackupForRehearsal(backupFile, decryptKey, true)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	snapshot, err := os.ReadFile(filepath.Join(workDir, "backup", schemaSnapshotFilename))
+	if err != nil {
+		return fmt.Errorf("backup %s has no schema snapshot, which a subset restore needs to write its objects: %w", metadata.BackupID, err)
+	}
+	schema, err := parseSubsetSchema(snapshot)
+	if err != nil {
+		return err
+	}
+	if err := schema.checkSelection(opts); err != nil {
+		return err
+	}
+
+	neo4jImage := opts.Neo4jImage
+	if neo4jImage == "" {
+		if neo4jImage, err = rehearsalNeo4jImage(metadata); err != nil {
+			return err
+		}
+	}
+	r, err := newRehearsal(iops.executor)
+	if err != nil {
+		return err
+	}
+	defer r.cleanup()
+
+	logrus.WithFields(logrus.Fields{"backup_id": metadata.BackupID, "neo4j_image": neo4jImage}).Info("Loading the backup into a throwaway Neo4j container...")
+	if _, err := r.rehearseNeo4j(filepath.Join(workDir, "backup", neo4jBackupDirName), neo4jImage, metadata.Neo4jEdition); err != nil {
+		return fmt.Errorf("failed to load the backup: %w", err)
+	}
+
+	objects, branch, err := exportSubset(r.cypher, schema, opts)
+	if err != nil {
+		return err
+	}
+	if len(objects) == 0 {
+		return fmt.Errorf("backup %s has no objects of %s on branch %s", metadata.BackupID, strings.Join(append(slices.Clone(opts.Kinds), opts.Namespaces...), ", "), branch)
+	}
+
+	if opts.DryRun {
+		writeSubsetObjects(w, objects)
+		return nil
+	}
+	if err := iops.importSubset(objects, schema, branch); err != nil {
+		return err
+	}
+	logrus.Infof("Restored %d objects from backup %s on branch %s", len(objects), metadata.BackupID, branch)
+	return nil
+}
+
+// subsetSchema holds the node kinds of a schema snapshot and the namespaces
+// and generics selections may name.
+type subsetSchema struct {
+	nodes    map[string]schemaNodeSnapshot
+	generics map[string]bool
+}
+
+// parseSubsetSchema reads the nodes and generics of a schema snapshot.
+func parseSubsetSchema(snapshot []byte) (*subsetSchema, error) {
+	var schema struct {
+		Nodes    []schemaNodeSnapshot `json:"nodes"`
+		Generics []schemaNodeSnapshot `json:"generics"`
+	}
+	if err := json.Unmarshal(snapshot, &schema); err != nil {
+		return nil, fmt.Errorf("invalid schema snapshot: %w", err)
+	}
+	s := &subsetSchema{nodes: map[string]schemaNodeSnapshot{}, generics: map[string]bool{}}
+	for _, node := range schema.Nodes {
+		if node.Kind == "" {
+			node.Kind = node.Namespace + node.Name
+		}
+		s.nodes[node.Kind] = node
+	}
+	for _, generic := range schema.Generics {
+		if generic.Kind == "" {
+			generic.Kind = generic.Namespace + generic.Name
+		}
+		s.generics[generic.Kind] = true
+	}
+	return s, nil
+}
+
+// checkSelection fails on kinds and namespaces the backup schema lacks.
+func (s *subsetSchema) checkSelection(opts SubsetRestoreOptions) error {
+	for _, kind := range opts.Kinds {
+		if _, ok := s.nodes[kind]; !ok && !s.generics[kind] {
+			return fmt.Errorf("kind %s is not in the backup schema", kind)
+		}
+	}
+	for _, namespace := range opts.Namespaces {
+		found := false
+		for _, node := range s.nodes {
+			found = found || node.Namespace == namespace
+		}
+		if !found {
+			return fmt.Errorf("namespace %s has no node kinds in the backup schema", namespace)
+		}
+	}
+	return nil
+}
+
+// relationship returns the relationship of kind stored under identifier in
+// direction, out when the object points to the Relationship node.
+func (s *subsetSchema) relationship(kind, identifier, direction string) (schemaRelationshipSnapshot, bool) {
+	var found []schemaRelationshipSnapshot
+	for _, rel := range s.nodes[kind].Relationships {
+		if rel.Identifier != identifier || rel.ReadOnly {
+			continue
+		}
+		if (rel.Direction == "inbound") == (direction == "in") {
+			found = append(found, rel)
+		}
+	}
+	if len(found) != 1 {
+		return schemaRelationshipSnapshot{}, false
+	}
+	return found[0], true
+}
+
+// writableAttribute reports whether the GraphQL API accepts attribute of kind.
+func (s *subsetSchema) writableAttribute(kind, attribute string) bool {
+	for _, attr := range s.nodes[kind].Attributes {
+		if attr.Name == attribute {
+			return !attr.ReadOnly
+		}
+	}
+	return false
+}
+
+// subsetBranchScope selects the graph edges visible on a branch: its own, the
+// branch-agnostic ones and, for other branches than the default one, the
+// edges of the default branch from before the branch was created.
+type subsetBranchScope struct {
+	branch        string
+	defaultBranch string
+	branchedFrom  string
+}
+
+// visible returns the Cypher condition selecting the current edges r of the
+// scope.
+func (b subsetBranchScope) visible(r string) string {
+	if b.branch == b.defaultBranch {
+		return fmt.Sprintf("%[1]s.branch IN [%s, '-global-'] AND %[1]s.to IS NULL", r, cypherString(b.branch))
+	}
+	return fmt.Sprintf("(%[1]s.branch IN [%[2]s, '-global-'] OR (%[1]s.branch = %[3]s AND %[1]s.from <= %[4]s)) AND %[1]s.to IS NULL",
+		r, cypherString(b.branch), cypherString(b.defaultBranch), cypherString(b.branchedFrom))
+}
+
+// cypherEscaped returns expr as a string with backslashes and line breaks
+// escaped, so every row of the plain cypher-shell output is one line that
+// parseCypherRows reads back unambiguously.
+func cypherEscaped(expr string) string {
+	return fmt.Sprintf(`replace(replace(replace(toString(%s), '\\', '\\\\'), '\n', '\\n'), '\r', '\\r')`, expr)
+}
+
+// subsetNodesCypher matches the selected objects that exist on the branch as n.
+func subsetNodesCypher(opts SubsetRestoreOptions, scope subsetBranchScope) string {
+	quote := func(names []string) string {
+		quoted := make([]string, len(names))
+		for i, name := range names {
+			quoted[i] = cypherString(name)
+		}
+		return "[" + strings.Join(quoted, ", ") + "]"
+	}
+	return fmt.Sprintf("MATCH (n:Node)-[p:IS_PART_OF]->(:Root)\n"+
+		"WHERE (any(label IN labels(n) WHERE label IN %s) OR n.namespace IN %s) AND %s\n"+
+		"WITH n, p ORDER BY p.branch_level DESC, p.from DESC\n"+
+		"WITH n, head(collect(p.status)) AS status\n"+
+		"WHERE status = 'active'\n", quote(opts.Kinds), quote(opts.Namespaces), scope.visible("p"))
+}
+
+// subsetAttributesCypher returns the current value of every attribute of the
+// selected objects, with its type: string, number, bool or null.
+func subsetAttributesCypher(opts SubsetRestoreOptions, scope subsetBranchScope) string {
+	return subsetNodesCypher(opts, scope) +
+		"MATCH (n)-[ha:HAS_ATTRIBUTE]->(a:Attribute)-[hv:HAS_VALUE]->(v)\n" +
+		"WHERE " + scope.visible("ha") + " AND " + scope.visible("hv") + "\n" +
+		"WITH n, a, ha, hv, v ORDER BY ha.branch_level DESC, ha.from DESC, hv.branch_level DESC, hv.from DESC\n" +
+		"WITH n, a, head(collect([ha.status, hv.status, v.value])) AS latest\n" +
+		"WHERE latest[0] = 'active' AND latest[1] = 'active'\n" +
+		"WITH n, a.name AS name, latest[2] AS value\n" +
+		"RETURN " + cypherEscaped("n.uuid") + " AS id, " + cypherEscaped("n.kind") + " AS kind, " + cypherEscaped("name") + " AS name,\n" +
+		"CASE WHEN value IS NULL THEN 'null' WHEN value = true OR value = false THEN 'bool' WHEN toString(value) = value THEN 'string' ELSE 'number' END AS type,\n" +
+		cypherEscaped("value") + " AS value\n" +
+		"ORDER BY id, name"
+}
+
+// subsetRelationshipsCypher returns the current peers of the selected objects
+// with the identifier of each relationship, and whether the object points to
+// the Relationship node (out) or the reverse (in).
+func subsetRelationshipsCypher(opts SubsetRestoreOptions, scope subsetBranchScope) string {
+	return subsetNodesCypher(opts, scope) +
+		"MATCH (n)-[r1:IS_RELATED]-(rel:Relationship)-[r2:IS_RELATED]-(peer:Node)\n" +
+		"WHERE peer <> n AND " + scope.visible("r1") + " AND " + scope.visible("r2") + "\n" +
+		"WITH n, rel, peer, r1, r2 ORDER BY r1.branch_level DESC, r1.from DESC, r2.branch_level DESC, r2.from DESC\n" +
+		"WITH n, rel, peer, head(collect([r1.status, r2.status, CASE WHEN startNode(r1) = n THEN 'out' ELSE 'in' END])) AS latest\n" +
+		"WHERE latest[0] = 'active' AND latest[1] = 'active'\n" +
+		"RETURN " + cypherEscaped("n.uuid") + " AS id, " + cypherEscaped("rel.name") + " AS identifier, latest[2] AS direction, " + cypherEscaped("peer.uuid") + " AS peer\n" +
+		"ORDER BY id, identifier, peer"
+}
+
+// subsetBranchesCypher lists the branches of the backup.
+var subsetBranchesCypher = "MATCH (b:Branch) RETURN " + cypherEscaped("b.name") + " AS name, " +
+	cypherEscaped("coalesce(b.is_default, false)") + " AS is_default, " + cypherEscaped("coalesce(b.branched_from, '')") + " AS branched_from"
+
+// exportSubset reads the selected objects of the branch with query, which
+// runs Cypher against the backup, and returns them with the branch read: the
+// default branch of the backup when opts.Branch is empty.
+func exportSubset(query func(string) (string, error), schema *subsetSchema, opts SubsetRestoreOptions) ([]subsetObject, string, error) {
+	output, err := query(subsetBranchesCypher)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read the branches of the backup: %w\nOutput: %v", err, output)
+	}
+	rows, err := parseCypherRows(output, 3)
+	if err != nil {
+		return nil, "", err
+	}
+	scope := subsetBranchScope{branch: opts.Branch}
+	found := false
+	for _, row := range rows {
+		if row[1] == "true" {
+			scope.defaultBranch = row[0]
+		}
+		if row[0] == opts.Branch {
+			found, scope.branchedFrom = true, row[2]
+		}
+	}
+	if scope.defaultBranch == "" {
+		return nil, "", fmt.Errorf("backup has no default branch")
+	}
+	if scope.branch == "" {
+		scope.branch, found = scope.defaultBranch, true
+	}
+	if !found {
+		return nil, "", fmt.Errorf("branch %s is not in the backup", opts.Branch)
+	}
+	opts.Branch = scope.branch
+
+	logrus.Infof("Exporting the selected objects of branch %s...", scope.branch)
+	output, err = query(subsetAttributesCypher(opts, scope))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to export the attributes: %w\nOutput: %v", err, output)
+	}
+	attributeRows, err := parseCypherRows(output, 5)
+	if err != nil {
+		return nil, "", err
+	}
+	output, err = query(subsetRelationshipsCypher(opts, scope))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to export the relationships: %w\nOutput: %v", err, output)
+	}
+	relationshipRows, err := parseCypherRows(output, 4)
+	if err != nil {
+		return nil, "", err
+	}
+	objects, err := buildSubsetObjects(schema, attributeRows, relationshipRows)
+	if err != nil {
+		return nil, "", err
+	}
+	return objects, scope.branch, nil
+}
+
+// buildSubsetObjects assembles the exported rows into objects, keeping the
+// attributes and relationships the GraphQL API accepts for their kind.
+func buildSubsetObjects(schema *subsetSchema, attributeRows, relationshipRows [][]string) ([]subsetObject, error) {
+	byID := map[string]*subsetObject{}
+	var ids []string
+	for _, row := range attributeRows {
+		id, kind, name, valueType, raw := row[0], row[1], row[2], row[3], row[4]
+		if _, ok := schema.nodes[kind]; !ok {
+			logrus.Warnf("Skipping object %s: kind %s is not in the backup schema", id, kind)
+			continue
+		}
+		object := byID[id]
+		if object == nil {
+			object = &subsetObject{ID: id, Kind: kind, Attributes: map[string]any{}, Peers: map[string][]string{}}
+			byID[id] = object
+			ids = append(ids, id)
+		}
+		if !schema.writableAttribute(kind, name) {
+			logrus.Debugf("Skipping attribute %s.%s", kind, name)
+			continue
+		}
+		value, err := subsetAttributeValue(valueType, raw)
+		if err != nil {
+			return nil, fmt.Errorf("attribute %s of %s: %w", name, id, err)
+		}
+		if value != nil {
+			object.Attributes[name] = value
+		}
+	}
+	for _, row := range relationshipRows {
+		id, identifier, direction, peer := row[0], row[1], row[2], row[3]
+		object := byID[id]
+		if object == nil {
+			continue
+		}
+		rel, ok := schema.relationship(object.Kind, identifier, direction)
+		if !ok {
+			logrus.Debugf("Skipping relationship %s of %s", identifier, id)
+			continue
+		}
+		object.Peers[rel.Name] = append(object.Peers[rel.Name], peer)
+	}
+
+	sort.Strings(ids)
+	objects := make([]subsetObject, 0, len(ids))
+	for _, id := range ids {
+		objects = append(objects, *byID[id])
+	}
+	return objects, nil
+}
+
+// subsetAttributeValue converts an exported value to its JSON value; nil
+// means the attribute has no value.
+func subsetAttributeValue(valueType, raw string) (any, error) {
+	switch valueType {
+	case "null":
+		return nil, nil
+	case "bool":
+		return raw == "true", nil
+	case "number":
+		return json.Number(raw), nil
+	case "string":
+		if raw == infrahubNullValue {
+			return nil, nil
+		}
+		return raw, nil
+	}
+	return nil, fmt.Errorf("unexpected value type %q", valueType)
+}
+
+// parseCypherRows reads the plain cypher-shell output of a query returning
+// columns strings escaped with cypherEscaped. The header line is skipped and
+// NULL reads as an empty string.
+func parseCypherRows(output string, columns int) ([][]string, error) {
+	lines := nonEmptyLines(output)
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("empty query output")
+	}
+	var rows [][]string
+	for _, line := range lines[1:] {
+		row, err := parseCypherRow(line)
+		if err != nil {
+			return nil, err
+		}
+		if len(row) != columns {
+			return nil, fmt.Errorf("unexpected query output %q: want %d columns", line, columns)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// parseCypherRow splits one line of plain cypher-shell output into its
+// quoted string values.
+func parseCypherRow(line string) ([]string, error) {
+	var row []string
+	rest := strings.TrimSpace(line)
+	for {
+		if value, ok := strings.CutPrefix(rest, "NULL"); ok {
+			row, rest = append(row, ""), value
+		} else {
+			if !strings.HasPrefix(rest, `"`) {
+				return nil, fmt.Errorf("unexpected query output %q", line)
+			}
+			var value strings.Builder
+			i, closed := 1, false
+			for ; i < len(rest); i++ {
+				c := rest[i]
+				if c == '"' {
+					closed = true
+					break
+				}
+				if c == '\\' && i+1 < len(rest) {
+					i++
+					switch rest[i] {
+					case 'n':
+						c = '\n'
+					case 'r':
+						c = '\r'
+					default:
+						c = rest[i]
+					}
+				}
+				value.WriteByte(c)
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated value in query output %q", line)
+			}
+			row, rest = append(row, value.String()), rest[i+1:]
+		}
+		if rest == "" {
+			return row, nil
+		}
+		var ok bool
+		if rest, ok = strings.CutPrefix(rest, ", "); !ok {
+			return nil, fmt.Errorf("unexpected query output %q", line)
+		}
+	}
+}
+
+// subsetMutation is one aliased mutation of a batch.
+type subsetMutation struct {
+	Operation string // Upsert or Update
+	Kind      string
+	Data      map[string]any
+}
+
+// subsetMutations returns the two passes writing objects: the first upserts
+// each object with its attributes and its relationships to objects outside
+// the selection, which must exist in the instance; the second sets the
+// relationships to selected objects once they all exist.
+func subsetMutations(objects []subsetObject, schema *subsetSchema) (create, link []subsetMutation) {
+	selected := map[string]bool{}
+	for _, object := range objects {
+		selected[object.ID] = true
+	}
+	for _, object := range objects {
+		data := map[string]any{"id": object.ID}
+		for name, value := range object.Attributes {
+			data[name] = map[string]any{"value": value}
+		}
+		links := map[string]any{"id": object.ID}
+		for name, peers := range object.Peers {
+			value := subsetRelationshipValue(schema, object.Kind, name, peers)
+			if slices.ContainsFunc(peers, func(peer string) bool { return selected[peer] }) {
+				links[name] = value
+			} else {
+				data[name] = value
+			}
+		}
+		create = append(create, subsetMutation{Operation: "Upsert", Kind: object.Kind, Data: data})
+		if len(links) > 1 {
+			link = append(link, subsetMutation{Operation: "Update", Kind: object.Kind, Data: links})
+		}
+	}
+	return create, link
+}
+
+// subsetRelationshipValue returns the GraphQL input of relationship name.
+func subsetRelationshipValue(schema *subsetSchema, kind, name string, peers []string) any {
+	for _, rel := range schema.nodes[kind].Relationships {
+		if rel.Name == name && rel.Cardinality == "one" && len(peers) == 1 {
+			return map[string]any{"id": peers[0]}
+		}
+	}
+	values := make([]map[string]any, len(peers))
+	for i, peer := range peers {
+		values[i] = map[string]any{"id": peer}
+	}
+	return values
+}
+
+// subsetMutationDocument returns one GraphQL document running mutations,
+// with their variables.
+func subsetMutationDocument(mutations []subsetMutation) (string, string, error) {
+	var declarations, fields []string
+	variables := map[string]any{}
+	for i, mutation := range mutations {
+		name := fmt.Sprintf("d%d", i)
+		declarations = append(declarations, fmt.Sprintf("$%s: %s%sInput!", name, mutation.Kind, mutation.Operation))
+		fields = append(fields, fmt.Sprintf("o%d: %s%s(data: $%s) { ok }", i, mutation.Kind, mutation.Operation, name))
+		variables[name] = mutation.Data
+	}
+	encoded, err := json.Marshal(variables)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encode objects: %w", err)
+	}
+	return fmt.Sprintf("mutation(%s) { %s }", strings.Join(declarations, ", "), strings.Join(fields, " ")), string(encoded), nil
+}
+
+// importSubset writes objects to branch of the running instance in batches
+// of subsetBatchSize.
+func (iops *InfrahubOps) importSubset(objects []subsetObject, schema *subsetSchema, branch string) error {
+	create, link := subsetMutations(objects, schema)
+	written := 0
+	for _, pass := range [][]subsetMutation{create, link} {
+		for start := 0; start < len(pass); start += subsetBatchSize {
+			batch := pass[start:min(start+subsetBatchSize, len(pass))]
+			query, variables, err := subsetMutationDocument(batch)
+			if err != nil {
+				return err
+			}
+			if err := iops.runBranchGraphQL(branch, query, variables); err != nil {
+				return fmt.Errorf("failed to write objects to branch %s after %d of %d writes: %w", branch, written, len(create)+len(link), err)
+			}
+			written += len(batch)
+			logrus.Infof("Completed %d of %d writes", written, len(create)+len(link))
+		}
+	}
+	return nil
+}
+
+// writeSubsetObjects lists the objects a subset restore would write.
+func writeSubsetObjects(w io.Writer, objects []subsetObject) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tID\tATTRIBUTES\tRELATIONSHIPS")
+	for _, object := range objects {
+		relationships := make([]string, 0, len(object.Peers))
+		for name := range object.Peers {
+			relationships = append(relationships, name)
+		}
+		sort.Strings(relationships)
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", object.Kind, object.ID, len(object.Attributes), strings.Join(relationships, ", "))
+	}
+	tw.Flush()
+}
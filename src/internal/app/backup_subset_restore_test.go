@@ -0,0 +1,184 @@
+package app
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const subsetTestSchema = `{"nodes":[
+{"namespace":"Infra","name":"Device","kind":"InfraDevice",
+ "attributes":[{"name":"name"},{"name":"description"},{"name":"serial","read_only":true}],
+ "relationships":[
+  {"name":"site","identifier":"infradevice__site","cardinality":"one","direction":"outbound"},
+  {"name":"tags","identifier":"builtintag__infradevice","cardinality":"many","direction":"bidirectional"}]},
+{"namespace":"Infra","name":"Interface","kind":"InfraInterface","attributes":[{"name":"name"}],
+ "relationships":[{"name":"device","identifier":"infradevice__interface","cardinality":"one","direction":"outbound"}]}
+],"generics":[{"namespace":"Infra","name":"Endpoint","kind":"InfraEndpoint"}]}`
+
+func TestParseCypherRows(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		columns int
+		want    [][]string
+		wantErr bool
+	}{
+		{
+			name:    "escaped values",
+			output:  "id, value\n\"d1\", \"say \\\"hi\\\"\\nC:\\\\dir\"\n\"d2\", NULL\n",
+			columns: 2,
+			want:    [][]string{{"d1", "say \"hi\"\nC:\\dir"}, {"d2", ""}},
+		},
+		{name: "header only", output: "id, value\n", columns: 2},
+		{name: "wrong column count", output: "id\n\"d1\"\n", columns: 2, wantErr: true},
+		{name: "unterminated value", output: "id\n\"d1\n", columns: 1, wantErr: true},
+		{name: "unquoted value", output: "count\n42\n", columns: 1, wantErr: true},
+		{name: "empty", output: "", columns: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCypherRows(tt.output, tt.columns)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCypherRows() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseCypherRows() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExportSubset(t *testing.T) {
+	schema, err := parseSubsetSchema([]byte(subsetTestSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var queries []string
+	query := func(cypher string) (string, error) {
+		queries = append(queries, cypher)
+		switch {
+		case strings.Contains(cypher, "MATCH (b:Branch)"):
+			return "name, is_default, branched_from\n\"main\", \"true\", \"\"\n\"fix\", \"false\", \"2026-10-01T00:00:00Z\"\n", nil
+		case strings.Contains(cypher, "HAS_ATTRIBUTE"):
+			return "id, kind, name, type, value\n" +
+				"\"d1\", \"InfraDevice\", \"description\", \"string\", \"NULL\"\n" +
+				"\"d1\", \"InfraDevice\", \"name\", \"string\", \"edge-1\"\n" +
+				"\"d1\", \"InfraDevice\", \"serial\", \"string\", \"X1\"\n" +
+				"\"i1\", \"InfraInterface\", \"mtu\", \"number\", \"1500\"\n" +
+				"\"i1\", \"InfraInterface\", \"name\", \"string\", \"eth0\"\n", nil
+		default:
+			return "id, identifier, direction, peer\n" +
+				"\"d1\", \"builtintag__infradevice\", \"out\", \"t1\"\n" +
+				"\"d1\", \"builtintag__infradevice\", \"out\", \"t2\"\n" +
+				"\"d1\", \"infradevice__interface\", \"in\", \"i1\"\n" +
+				"\"d1\", \"infradevice__site\", \"out\", \"s1\"\n" +
+				"\"i1\", \"infradevice__interface\", \"out\", \"d1\"\n", nil
+		}
+	}
+
+	objects, branch, err := exportSubset(query, schema, SubsetRestoreOptions{Kinds: []string{"InfraEndpoint"}, Namespaces: []string{"Infra"}, Branch: "fix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if branch != "fix" {
+		t.Errorf("branch = %q, want fix", branch)
+	}
+	want := []subsetObject{
+		{ID: "d1", Kind: "InfraDevice", Attributes: map[string]any{"name": "edge-1"}, Peers: map[string][]string{"site": {"s1"}, "tags": {"t1", "t2"}}},
+		{ID: "i1", Kind: "InfraInterface", Attributes: map[string]any{"name": "eth0"}, Peers: map[string][]string{"device": {"d1"}}},
+	}
+	if !reflect.DeepEqual(objects, want) {
+		t.Errorf("exportSubset() = %+v, want %+v", objects, want)
+	}
+	for _, want := range []string{"label IN ['InfraEndpoint']", "n.namespace IN ['Infra']", "p.branch = 'main' AND p.from <= '2026-10-01T00:00:00Z'"} {
+		if !strings.Contains(queries[1], want) {
+			t.Errorf("attribute query lacks %q:\n%s", want, queries[1])
+		}
+	}
+
+	if _, _, err := exportSubset(query, schema, SubsetRestoreOptions{Kinds: []string{"InfraDevice"}, Branch: "gone"}); err == nil {
+		t.Error("exportSubset() of a missing branch succeeded")
+	}
+	if _, branch, err := exportSubset(query, schema, SubsetRestoreOptions{Kinds: []string{"InfraDevice"}}); err != nil || branch != "main" {
+		t.Errorf("exportSubset() without a branch = %q, %v; want main", branch, err)
+	}
+}
+
+func TestImportSubset(t *testing.T) {
+	schema, err := parseSubsetSchema([]byte(subsetTestSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	objects := []subsetObject{
+		{ID: "d1", Kind: "InfraDevice", Attributes: map[string]any{"name": "edge-1"}, Peers: map[string][]string{"site": {"s1"}, "tags": {"t1"}}},
+		{ID: "i1", Kind: "InfraInterface", Attributes: map[string]any{"name": "eth0"}, Peers: map[string][]string{"device": {"d1"}}},
+	}
+	fake := newFakeExecutor().on("graphql", `{"data":{}}`, nil)
+	iops := newFakeDockerOps(fake)
+
+	if err := iops.importSubset(objects, schema, "fix"); err != nil {
+		t.Fatal(err)
+	}
+	calls := fake.commands("python -c")
+	if len(calls) != 2 {
+		t.Fatalf("GraphQL requests = %d, want 2: %v", len(calls), calls)
+	}
+	for _, want := range []string{"InfraDeviceUpsert(data: $d0)", "InfraInterfaceUpsert(data: $d1)", `"site":{"id":"s1"}`, `"tags":[{"id":"t1"}]`, " fix"} {
+		if !strings.Contains(calls[0], want) {
+			t.Errorf("first request lacks %q: %s", want, calls[0])
+		}
+	}
+	if strings.Contains(calls[0], `"device"`) {
+		t.Errorf("first request links selected objects: %s", calls[0])
+	}
+	if !strings.Contains(calls[1], "InfraInterfaceUpdate(data: $d0)") || !strings.Contains(calls[1], `"device":{"id":"d1"}`) {
+		t.Errorf("second request = %s", calls[1])
+	}
+}
+
+func TestSubsetMutationDocument(t *testing.T) {
+	query, variables, err := subsetMutationDocument([]subsetMutation{
+		{Operation: "Upsert", Kind: "InfraDevice", Data: map[string]any{"id": "d1", "mtu": map[string]any{"value": json.Number("1500")}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "mutation($d0: InfraDeviceUpsertInput!) { o0: InfraDeviceUpsert(data: $d0) { ok } }"; query != want {
+		t.Errorf("query = %q, want %q", query, want)
+	}
+	if want := `{"d0":{"id":"d1","mtu":{"value":1500}}}`; variables != want {
+		t.Errorf("variables = %s, want %s", variables, want)
+	}
+}
+
+func TestSubsetRestoreOptionsValidate(t *testing.T) {
+	schema, err := parseSubsetSchema([]byte(subsetTestSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		opts    SubsetRestoreOptions
+		wantErr bool
+	}{
+		{name: "kind", opts: SubsetRestoreOptions{Kinds: []string{"InfraDevice"}}},
+		{name: "generic and namespace", opts: SubsetRestoreOptions{Kinds: []string{"InfraEndpoint"}, Namespaces: []string{"Infra"}}},
+		{name: "nothing selected", wantErr: true},
+		{name: "invalid kind", opts: SubsetRestoreOptions{Kinds: []string{"Infra Device"}}, wantErr: true},
+		{name: "unknown kind", opts: SubsetRestoreOptions{Kinds: []string{"InfraRack"}}, wantErr: true},
+		{name: "unknown namespace", opts: SubsetRestoreOptions{Namespaces: []string{"Ipam"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opts.Validate()
+			if err == nil {
+				err = schema.checkSelection(tt.opts)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
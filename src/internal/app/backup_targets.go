@@ -0,0 +1,261 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RestoreTarget names one deployment a backup is restored into.
+type RestoreTarget struct {
+	Spec    string // as given on the command line
+	Backend string // docker or kubernetes; empty until resolved
+	Name    string // Docker Compose project or Kubernetes namespace
+}
+
+// targetRestoreResult is the outcome of restoring into one target.
+type targetRestoreResult struct {
+	Target   RestoreTarget
+	Duration time.Duration
+	Err      error
+}
+
+// ParseRestoreTargets parses --target values of the form docker:<project>,
+// k8s:<namespace> or a bare name that is resolved against the deployments
+// found on this host.
+func ParseRestoreTargets(specs []string) ([]RestoreTarget, error) {
+	targets := make([]RestoreTarget, 0, len(specs))
+	seen := map[string]bool{}
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			return nil, fmt.Errorf("empty --target value")
+		}
+
+		target := RestoreTarget{Spec: spec, Name: spec}
+		if prefix, name, ok := strings.Cut(spec, ":"); ok {
+			switch prefix {
+			case "docker":
+				target.Backend = "docker"
+			case "k8s", "kubernetes":
+				target.Backend = "kubernetes"
+			default:
+				return nil, fmt.Errorf("invalid --target %q: unknown backend %q (expected docker or k8s)", spec, prefix)
+			}
+			if name == "" {
+				return nil, fmt.Errorf("invalid --target %q: missing name", spec)
+			}
+			target.Name = name
+		}
+
+		key := target.Backend + ":" + target.Name
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate --target %q", spec)
+		}
+		seen[key] = true
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// resolveRestoreTargets assigns a backend to bare target names by looking them
+// up among the Docker Compose projects and Kubernetes namespaces.
+func resolveRestoreTargets(targets []RestoreTarget, executor CommandExecutor) ([]RestoreTarget, error) {
+	var dockerProjects, k8sNamespaces []string
+	listed := false
+
+	resolved := make([]RestoreTarget, 0, len(targets))
+	for _, target := range targets {
+		if target.Backend == "" {
+			if !listed {
+				dockerProjects, _ = ListDockerProjects(executor)
+				k8sNamespaces, _ = ListKubernetesNamespaces(executor)
+				listed = true
+			}
+			inDocker := contains(dockerProjects, target.Name)
+			inK8s := contains(k8sNamespaces, target.Name)
+			switch {
+			case inDocker && inK8s:
+				return nil, fmt.Errorf("target %s matches both a Docker Compose project and a Kubernetes namespace; use docker:%s or k8s:%s", target.Name, target.Name, target.Name)
+			case inDocker:
+				target.Backend = "docker"
+			case inK8s:
+				target.Backend = "kubernetes"
+			default:
+				return nil, fmt.Errorf("target %s not found as a Docker Compose project or Kubernetes namespace", target.Name)
+			}
+		}
+		resolved = append(resolved, target)
+	}
+	return resolved, nil
+}
+
+// forTarget returns an independent InfrahubOps bound to target, with its own
+// configuration, backends and run report so targets can be driven
+// concurrently.
+func (iops *InfrahubOps) forTarget(target RestoreTarget) *InfrahubOps {
+	scoped := iops.scopedCopy()
+	scoped.config.detachDeployment()
+	switch target.Backend {
+	case "docker":
+		scoped.config.DockerComposeProject = target.Name
+	case "kubernetes":
+		scoped.config.K8sNamespace = target.Name
+	}
+	scoped.report = &RunReport{Operation: "restore", StartedAt: time.Now()}
+	return scoped
+}
+
+// RestoreBackupToTargets restores one archive into several deployments at
+// once. The archive is downloaded and decrypted a single time; every target
+// then extracts it into its own work directory. All targets run to completion
+// and a consolidated report is printed; the returned error lists the targets
+// that failed.
+func (iops *InfrahubOps) RestoreBackupToTargets(backupFile string, targetSpecs []string, excludeTaskManager bool, restoreMigrateFormat bool, sleepDuration time.Duration, decryptKey string, force bool, resetDeploymentID bool, minimizeDowntime bool) error {
+	if iops.config.Backend == BackendPlakar {
+		return fmt.Errorf("--target is not supported with the plakar backend")
+	}
+
+	targets, err := ParseRestoreTargets(targetSpecs)
+	if err != nil {
+		return err
+	}
+	targets, err = resolveRestoreTargets(targets, iops.executor)
+	if err != nil {
+		return err
+	}
+
+	archive, cleanup, err := iops.prepareSharedRestoreArchive(backupFile, decryptKey)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	if sleepDuration > 0 {
+		logrus.Infof("Sleeping for %v before restoring into %d targets...", sleepDuration, len(targets))
+		time.Sleep(sleepDuration)
+	}
+
+	logrus.Infof("Restoring %s into %d targets concurrently", filepath.Base(backupFile), len(targets))
+
+	results := make([]targetRestoreResult, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target RestoreTarget) {
+			defer wg.Done()
+			started := time.Now()
+			logrus.WithField("target", target.Spec).Infof("Starting restore into %s %s", target.Backend, target.Name)
+			err := iops.forTarget(target).RestoreBackup(archive, excludeTaskManager, restoreMigrateFormat, 0, "", force, resetDeploymentID, minimizeDowntime)
+			results[i] = targetRestoreResult{Target: target, Duration: time.Since(started), Err: err}
+			if err != nil {
+				logrus.WithField("target", target.Spec).Errorf("Restore failed: %v", err)
+				return
+			}
+			logrus.WithField("target", target.Spec).Info("Restore completed")
+		}(i, target)
+	}
+	wg.Wait()
+
+	writeTargetRestoreReport(os.Stdout, results)
+	iops.recordRestoreSource("", backupFile, "")
+	if iops.report != nil {
+		iops.report.Targets = results
+	}
+
+	var failed []string
+	for _, result := range results {
+		if result.Err != nil {
+			failed = append(failed, result.Target.Spec)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("restore failed for %d of %d targets: %s", len(failed), len(results), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// prepareSharedRestoreArchive downloads and decrypts the archive once so that
+// concurrent target restores read the same plaintext file. The returned
+// cleanup removes any temporary copies.
+func (iops *InfrahubOps) prepareSharedRestoreArchive(backupFile, decryptKey string) (string, func(), error) {
+	var tempPaths []string
+	cleanup := func() {
+		for _, path := range tempPaths {
+			os.RemoveAll(path)
+		}
+	}
+	archive, err := iops.fetchSharedRestoreArchive(backupFile, decryptKey, &tempPaths)
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return archive, cleanup, nil
+}
+
+// fetchSharedRestoreArchive does the work for prepareSharedRestoreArchive and
+// appends every temporary path it creates to tempPaths.
+func (iops *InfrahubOps) fetchSharedRestoreArchive(backupFile, decryptKey string, tempPaths *[]string) (string, error) {
+	archive := backupFile
+	if IsS3URI(backupFile) {
+		downloadedPath, err := iops.downloadBackupFromS3(backupFile)
+		if err != nil {
+			return "", err
+		}
+		archive = downloadedPath
+		if files, err := splitArchiveFiles(downloadedPath); err == nil {
+			*tempPaths = append(*tempPaths, files...)
+		} else {
+			*tempPaths = append(*tempPaths, downloadedPath)
+		}
+	}
+	archive = splitManifestFor(archive)
+
+	if _, err := os.Stat(archive); os.IsNotExist(err) {
+		return "", fmt.Errorf("backup file not found: %s", archive)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "infrahub_restore_archive_*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	*tempPaths = append(*tempPaths, tmpDir)
+
+	archive, err = joinSplitArchive(archive, tmpDir)
+	if err != nil {
+		return "", err
+	}
+	archive, reversedFilters, err := reverseArchiveFilters(archive, tmpDir, ArchiveOptions{DecryptKey: decryptKey})
+	if err != nil {
+		return "", err
+	}
+	if decryptKey != "" && !slices.Contains(reversedFilters, "ecies") {
+		return "", fmt.Errorf("--decrypt-key provided but backup file is not encrypted")
+	}
+	return archive, nil
+}
+
+// writeTargetRestoreReport prints one line per target with its outcome.
+func writeTargetRestoreReport(w io.Writer, results []targetRestoreResult) {
+	fmt.Fprintf(w, "%-30s  %-10s  %-8s  %-10s  %s\n", "TARGET", "BACKEND", "STATUS", "DURATION", "ERROR")
+	for _, result := range results {
+		status, errMsg := "ok", ""
+		if result.Err != nil {
+			status, errMsg = "failed", result.Err.Error()
+		}
+		fmt.Fprintf(w, "%-30s  %-10s  %-8s  %-10s  %s\n",
+			result.Target.Spec,
+			result.Target.Backend,
+			status,
+			result.Duration.Round(time.Second),
+			errMsg,
+		)
+	}
+}
@@ -0,0 +1,101 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRestoreTargets(t *testing.T) {
+	tests := []struct {
+		name    string
+		specs   []string
+		want    []RestoreTarget
+		wantErr bool
+	}{
+		{
+			name:  "prefixed and bare",
+			specs: []string{"docker:infrahub-eu", "k8s:infrahub-us", "kubernetes:dr", "prod-ap"},
+			want: []RestoreTarget{
+				{Spec: "docker:infrahub-eu", Backend: "docker", Name: "infrahub-eu"},
+				{Spec: "k8s:infrahub-us", Backend: "kubernetes", Name: "infrahub-us"},
+				{Spec: "kubernetes:dr", Backend: "kubernetes", Name: "dr"},
+				{Spec: "prod-ap", Name: "prod-ap"},
+			},
+		},
+		{name: "unknown backend", specs: []string{"nomad:infrahub"}, wantErr: true},
+		{name: "missing name", specs: []string{"docker:"}, wantErr: true},
+		{name: "empty", specs: []string{" "}, wantErr: true},
+		{name: "duplicate", specs: []string{"k8s:infrahub", "kubernetes:infrahub"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRestoreTargets(tt.specs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRestoreTargets() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseRestoreTargets() = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("target %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestForTargetIsolatesConfiguration(t *testing.T) {
+	fake := newFakeExecutor()
+	iops := NewInfrahubOpsWithExecutor(fake)
+	iops.config.DockerComposeProject = "original"
+	iops.config.K8sReleaseName = "infrahub"
+	iops.config.TargetPin = &TargetPin{}
+	iops.config.S3 = &S3Config{Bucket: "backups"}
+
+	scoped := iops.forTarget(RestoreTarget{Backend: "kubernetes", Name: "infrahub-us"})
+	if scoped.config == iops.config || scoped.config.S3 == iops.config.S3 {
+		t.Fatal("forTarget should not share configuration")
+	}
+	if scoped.executor != fake || scoped.events != iops.events || scoped.warnings != iops.warnings {
+		t.Error("forTarget should share the executor, events and warnings")
+	}
+	if scoped.report == nil {
+		t.Error("forTarget should give the target its own run report")
+	}
+	if scoped.config.K8sReleaseName != "" || scoped.config.TargetPin != nil {
+		t.Errorf("scoped config keeps release %q and pin %+v of the parent", scoped.config.K8sReleaseName, scoped.config.TargetPin)
+	}
+	if scoped.config.K8sNamespace != "infrahub-us" || scoped.config.DockerComposeProject != "" {
+		t.Errorf("scoped config = project %q namespace %q, want namespace infrahub-us only", scoped.config.DockerComposeProject, scoped.config.K8sNamespace)
+	}
+	if iops.config.DockerComposeProject != "original" {
+		t.Error("forTarget modified the parent configuration")
+	}
+}
+
+func TestWriteTargetRestoreReport(t *testing.T) {
+	var buf bytes.Buffer
+	writeTargetRestoreReport(&buf, []targetRestoreResult{
+		{Target: RestoreTarget{Spec: "prod-eu", Backend: "docker"}, Duration: 90 * time.Second},
+		{Target: RestoreTarget{Spec: "k8s:prod-us", Backend: "kubernetes"}, Duration: time.Minute, Err: errors.New("neo4j restore failed")},
+	})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("report has %d lines, want 3:\n%s", len(lines), buf.String())
+	}
+	if !strings.Contains(lines[1], "prod-eu") || !strings.Contains(lines[1], "ok") || !strings.Contains(lines[1], "1m30s") {
+		t.Errorf("unexpected success line: %q", lines[1])
+	}
+	if !strings.Contains(lines[2], "failed") || !strings.Contains(lines[2], "neo4j restore failed") {
+		t.Errorf("unexpected failure line: %q", lines[2])
+	}
+}
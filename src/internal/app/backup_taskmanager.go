@@ -3,7 +3,11 @@ package app
 import (
 	"fmt"
 	"io"
+	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/sirupsen/logrus"
@@ -17,11 +21,7 @@ func (iops *InfrahubOps) backupTaskManagerDBStream() (func() (io.ReadCloser, err
 			"PGPASSWORD": iops.config.PostgresPassword,
 		}}
 
-		stdout, wait, err := iops.ExecStreamPipe(
-			"task-manager-db",
-			[]string{"pg_dump", "-Fc", "-Z0", "-h", "localhost", "-U", iops.config.PostgresUsername, "-d", iops.config.PostgresDatabase},
-			opts,
-		)
+		stdout, wait, err := iops.ExecStreamPipe("task-manager-db", iops.lowPriority("task-manager-db", iops.pgStreamDumpArgs()), opts)
 		if err != nil {
 			return nil, fmt.Errorf("failed to start postgres stream: %w", err)
 		}
@@ -30,12 +30,246 @@ func (iops *InfrahubOps) backupTaskManagerDBStream() (func() (io.ReadCloser, err
 	}, nil
 }
 
+// pgStreamDumpArgs is the uncompressed custom format dump to stdout read by
+// backupTaskManagerDBStream.
+func (iops *InfrahubOps) pgStreamDumpArgs() []string {
+	args := []string{"pg_dump", "-Fc", "-Z0", "-h", "localhost", "-U", iops.config.PostgresUsername, "-d", iops.config.PostgresDatabase}
+	return append(args, pgExcludeTableDataArgs(iops.config.PgExcludeTableData)...)
+}
+
+// getPostgresVersion returns the server version of the task manager database,
+// e.g. "16.4 (Debian 16.4-1.pgdg120+2)".
+func (iops *InfrahubOps) getPostgresVersion() (string, error) {
+	opts := &ExecOptions{Env: map[string]string{
+		"PGPASSWORD": iops.config.PostgresPassword,
+	}}
+	output, err := iops.Exec(
+		"task-manager-db",
+		[]string{"psql", "-h", "localhost", "-U", iops.config.PostgresUsername, "-d", iops.config.PostgresDatabase, "-tAc", "SHOW server_version"},
+		opts,
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to query postgresql version: %w\nOutput: %v", err, output)
+	}
+	version := strings.TrimSpace(output)
+	if version == "" {
+		return "", fmt.Errorf("empty postgresql version")
+	}
+	return version, nil
+}
+
+// postgresMajorVersion extracts the major version from a server version
+// string. Releases before 10 used two-part majors (9.6) and are reported as 9.
+func postgresMajorVersion(version string) (int, error) {
+	fields := strings.Fields(version)
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("empty postgresql version")
+	}
+	major, _, _ := strings.Cut(fields[0], ".")
+	value, err := strconv.Atoi(major)
+	if err != nil {
+		return 0, fmt.Errorf("invalid postgresql version %q", version)
+	}
+	return value, nil
+}
+
+// checkPostgresCompatibility fails when the dump comes from a newer major
+// version than the target: pg_restore cannot read archives written by a newer
+// pg_dump, so no format fallback is possible on the target side.
+func checkPostgresCompatibility(sourceVersion, targetVersion string) error {
+	source, err := postgresMajorVersion(sourceVersion)
+	if err != nil {
+		return err
+	}
+	target, err := postgresMajorVersion(targetVersion)
+	if err != nil {
+		return err
+	}
+	if target < source {
+		return fmt.Errorf("task manager database dump was taken from PostgreSQL %d but the target runs PostgreSQL %d; "+
+			"pg_restore cannot downgrade. Upgrade the target database or use --exclude-taskmanager", source, target)
+	}
+	return nil
+}
+
+// verifyPostgresRestoreCompatibility compares the recorded source version with
+// the target server before anything is stopped.
+func (iops *InfrahubOps) verifyPostgresRestoreCompatibility(metadata *BackupMetadata) error {
+	if metadata.PostgresVersion == "" {
+		logrus.Debug("Backup does not record a PostgreSQL version; skipping compatibility check")
+		return nil
+	}
+	targetVersion, err := iops.getPostgresVersion()
+	if err != nil {
+		logrus.Warnf("Could not determine target PostgreSQL version; skipping compatibility check: %v", err)
+		return nil
+	}
+	logrus.WithFields(logrus.Fields{
+		"source_version": metadata.PostgresVersion,
+		"target_version": targetVersion,
+	}).Info("Checking PostgreSQL version compatibility")
+	return checkPostgresCompatibility(metadata.PostgresVersion, targetVersion)
+}
+
+// recordTaskManagerInfo records the PostgreSQL version, the Prefect schema
+// revision and the tables dumped without data in the metadata. A version or
+// revision that cannot be read is left out with a warning.
+func (iops *InfrahubOps) recordTaskManagerInfo(metadata *BackupMetadata) {
+	if pgVersion, err := iops.getPostgresVersion(); err != nil {
+		logrus.Warnf("Could not record PostgreSQL version: %v", err)
+	} else {
+		metadata.PostgresVersion = pgVersion
+	}
+	if revision, err := iops.getPrefectSchemaRevision(); err != nil {
+		logrus.Warnf("Could not record Prefect schema revision: %v", err)
+	} else {
+		metadata.PrefectSchemaRevision = revision
+	}
+	metadata.PostgresExcludedTableData = iops.config.PgExcludeTableData
+}
+
+// prefectMigrateCommand upgrades the Prefect database to the schema of the
+// running task manager.
+var prefectMigrateCommand = []string{"prefect", "server", "database", "upgrade", "-y"}
+
+// getPrefectSchemaRevision returns the alembic revision of the task manager
+// database schema, comma-separated when it has several heads.
+func (iops *InfrahubOps) getPrefectSchemaRevision() (string, error) {
+	opts := &ExecOptions{Env: map[string]string{
+		"PGPASSWORD": iops.config.PostgresPassword,
+	}}
+	output, err := iops.Exec(
+		"task-manager-db",
+		[]string{"psql", "-h", "localhost", "-U", iops.config.PostgresUsername, "-d", iops.config.PostgresDatabase, "-tAc", "SELECT string_agg(version_num, ',' ORDER BY version_num) FROM alembic_version"},
+		opts,
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to query prefect schema revision: %w\nOutput: %v", err, output)
+	}
+	revision := strings.TrimSpace(output)
+	if revision == "" {
+		return "", fmt.Errorf("empty prefect schema revision")
+	}
+	return revision, nil
+}
+
+// verifyPrefectSchemaRevision compares the Prefect schema revision recorded
+// in the backup with the target's before anything is stopped. Alembic
+// revisions cannot be ordered without the migration history, so any
+// difference fails unless --run-prefect-migrations is set, in which case it
+// returns true and the migrations run once the task manager is back up.
+func (iops *InfrahubOps) verifyPrefectSchemaRevision(metadata *BackupMetadata) (bool, error) {
+	if metadata.PrefectSchemaRevision == "" {
+		logrus.Debug("Backup does not record a Prefect schema revision; skipping schema check")
+		return false, nil
+	}
+	targetRevision, err := iops.getPrefectSchemaRevision()
+	if err != nil {
+		logrus.Warnf("Could not determine target Prefect schema revision; skipping schema check: %v", err)
+		return false, nil
+	}
+	logrus.WithFields(logrus.Fields{
+		"source_revision": metadata.PrefectSchemaRevision,
+		"target_revision": targetRevision,
+	}).Info("Checking Prefect schema revision")
+	if targetRevision == metadata.PrefectSchemaRevision {
+		return false, nil
+	}
+	if !iops.config.RunPrefectMigrations {
+		return false, fmt.Errorf("task manager database was backed up at Prefect schema revision %s but the target is at %s; "+
+			"the task manager may not start on the restored schema. Use --run-prefect-migrations to migrate it after the restore, "+
+			"restore into the Infrahub version the backup was taken from, or use --exclude-taskmanager",
+			metadata.PrefectSchemaRevision, targetRevision)
+	}
+	logrus.Warnf("Prefect schema revision %s of the backup differs from the target's %s; migrations will run after the restore",
+		metadata.PrefectSchemaRevision, targetRevision)
+	return true, nil
+}
+
+// runPrefectMigrations upgrades the restored task manager database to the
+// schema of the running task manager, then restarts it on the new schema.
+func (iops *InfrahubOps) runPrefectMigrations() error {
+	logrus.Info("Running Prefect database migrations...")
+	if output, err := iops.Exec("task-manager", prefectMigrateCommand, nil); err != nil {
+		return fmt.Errorf("prefect database migrations failed; the backup may come from a newer Infrahub version than the target: %w\nOutput: %v", err, output)
+	}
+	if err := iops.StopServices("task-manager"); err != nil {
+		logrus.Debugf("Failed to stop task-manager: %v", err)
+	}
+	if err := iops.StartServices("task-manager"); err != nil {
+		return fmt.Errorf("failed to restart task-manager after the migrations: %w", err)
+	}
+	logrus.Info("Prefect database migrations completed")
+	return nil
+}
+
+// pgDumpArgs returns the pg_dump command writing to dumpPath. With jobs, the
+// dump uses the directory format so tables are dumped in parallel.
+func (iops *InfrahubOps) pgDumpArgs(dumpPath string, jobs int) []string {
+	args := []string{"pg_dump", "-Fc"}
+	if jobs > 0 {
+		args = []string{"pg_dump", "-Fd", "-j", strconv.Itoa(jobs)}
+	}
+	args = append(args, "-h", "localhost", "-U", iops.config.PostgresUsername, "-d", iops.config.PostgresDatabase, "-f", dumpPath)
+	return append(args, pgExcludeTableDataArgs(iops.config.PgExcludeTableData)...)
+}
+
+// pgExcludeTableDataArgs returns the pg_dump options skipping the data of the
+// given tables. Their definitions are still dumped, so restores recreate them
+// empty.
+func pgExcludeTableDataArgs(tables []string) []string {
+	args := make([]string, 0, len(tables))
+	for _, table := range tables {
+		args = append(args, "--exclude-table-data="+table)
+	}
+	return args
+}
+
+// logExcludedTableData tells that the tables whose data was excluded from the
+// backup will be restored empty on purpose.
+func logExcludedTableData(metadata *BackupMetadata) {
+	if len(metadata.PostgresExcludedTableData) > 0 {
+		logrus.Infof("Task manager tables %s were backed up without data and will be restored empty",
+			strings.Join(metadata.PostgresExcludedTableData, ", "))
+	}
+}
+
+// taskManagerDumpPath returns the directory format dump in backupDir when there
+// is one, and the custom format dump file otherwise.
+func taskManagerDumpPath(backupDir string) string {
+	if info, err := os.Stat(filepath.Join(backupDir, prefectDumpDirName)); err == nil && info.IsDir() {
+		return filepath.Join(backupDir, prefectDumpDirName)
+	}
+	return filepath.Join(backupDir, prefectDumpFilename)
+}
+
+// hasTaskManagerDump reports whether backupDir holds a task manager dump in
+// either format.
+func hasTaskManagerDump(backupDir string) bool {
+	_, err := os.Stat(taskManagerDumpPath(backupDir))
+	return err == nil
+}
+
+// removeDumpArgs returns the command removing a temporary dump file, or a
+// directory format dump.
+func removeDumpArgs(dumpPath string) []string {
+	if strings.HasSuffix(dumpPath, prefectDumpDirName) {
+		return []string{"rm", "-r", dumpPath}
+	}
+	return []string{"rm", dumpPath}
+}
+
 func (iops *InfrahubOps) backupTaskManagerDB(backupDir string) error {
 	logrus.Info("Backing up PostgreSQL database...")
 
 	// Determine writable temp directory
 	tempDir := iops.getWritableTempDir("task-manager-db")
-	dumpFile := tempDir + "/infrahubops_prefect.dump"
+	dumpName := prefectDumpFilename
+	if iops.config.PgJobs > 0 {
+		dumpName = prefectDumpDirName
+		logrus.Infof("Dumping in directory format with %d parallel jobs", iops.config.PgJobs)
+	}
+	dumpPath := tempDir + "/infrahubops_" + dumpName
 
 	// Create dump
 	opts := &ExecOptions{Env: map[string]string{
@@ -43,19 +277,20 @@ func (iops *InfrahubOps) backupTaskManagerDB(backupDir string) error {
 	}}
 	if output, err := iops.Exec(
 		"task-manager-db",
-		[]string{"pg_dump", "-Fc", "-h", "localhost", "-U", iops.config.PostgresUsername, "-d", iops.config.PostgresDatabase, "-f", dumpFile},
+		iops.lowPriority("task-manager-db", iops.pgDumpArgs(dumpPath, iops.config.PgJobs)),
 		opts,
 	); err != nil {
 		return fmt.Errorf("failed to create postgresql dump: %w\nOutput: %v", err, output)
 	}
 	defer func() {
-		if _, err := iops.Exec("task-manager-db", []string{"rm", dumpFile}, nil); err != nil {
+		if _, err := iops.Exec("task-manager-db", removeDumpArgs(dumpPath), nil); err != nil {
 			logrus.Warnf("Failed to remove temporary postgres dump: %v", err)
 		}
 	}()
 
 	// Copy dump
-	if err := iops.CopyFrom("task-manager-db", dumpFile, filepath.Join(backupDir, "prefect.dump")); err != nil {
+	iops.throttle.wait("copying the task manager dump")
+	if err := iops.CopyFrom("task-manager-db", dumpPath, filepath.Join(backupDir, dumpName)); err != nil {
 		return fmt.Errorf("failed to copy postgresql dump: %w", err)
 	}
 
@@ -63,6 +298,32 @@ func (iops *InfrahubOps) backupTaskManagerDB(backupDir string) error {
 	return nil
 }
 
+// pgRestoreCommand returns the pg_restore command loading dumpFile, over the
+// Unix socket when the container user can use it and over TCP with the
+// password otherwise.
+func (iops *InfrahubOps) pgRestoreCommand(dumpFile string, useUnixSocket bool) ([]string, *ExecOptions) {
+	targetArgs := pgRestoreTargetArgs(iops.config.CredentialMap, iops.config.PostgresUsername)
+	if iops.config.PgJobs > 0 {
+		targetArgs = append(targetArgs, "-j", strconv.Itoa(iops.config.PgJobs))
+	}
+	if !useUnixSocket {
+		opts := &ExecOptions{Env: map[string]string{
+			"PGPASSWORD": iops.config.PostgresPassword,
+		}}
+		restoreCmd := append([]string{"pg_restore", "-h", "localhost", "-U", iops.config.PostgresUsername}, targetArgs...)
+		return append(restoreCmd, dumpFile), opts
+	}
+
+	// Use Unix socket connection (no host, user, or password)
+	restoreCmd := append([]string{"pg_restore"}, targetArgs...)
+	restoreCmd = append(restoreCmd, dumpFile)
+	// On Docker, run as the postgres user so Unix socket auth succeeds
+	if backend, err := iops.ensureBackend(); err == nil && backend.Name() == "docker" {
+		return restoreCmd, &ExecOptions{User: iops.config.PostgresUsername}
+	}
+	return restoreCmd, nil
+}
+
 func (iops *InfrahubOps) restorePostgreSQL(workDir string) error {
 	logrus.Info("Restoring PostgreSQL database...")
 
@@ -76,51 +337,58 @@ func (iops *InfrahubOps) restorePostgreSQL(workDir string) error {
 		}
 	}
 
+	dumpPath := taskManagerDumpPath(filepath.Join(workDir, "backup"))
+
 	// Determine writable temp directory
 	tempDir := iops.getWritableTempDir("task-manager-db")
-	dumpFile := tempDir + "/infrahubops_prefect.dump"
+	dumpFile := tempDir + "/infrahubops_" + filepath.Base(dumpPath)
 
 	// Copy dump to container
-	dumpPath := filepath.Join(workDir, "backup", "prefect.dump")
 	if err := iops.CopyTo("task-manager-db", dumpPath, dumpFile); err != nil {
 		return fmt.Errorf("failed to copy dump to container: %w", err)
 	}
 	defer func() {
-		if _, err := iops.Exec("task-manager-db", []string{"rm", dumpFile}, nil); err != nil {
+		if _, err := iops.Exec("task-manager-db", removeDumpArgs(dumpFile), nil); err != nil {
 			logrus.Warnf("Failed to remove temporary postgres dump: %v", err)
 		}
 	}()
 
 	// Restore database
 	// Check if we can use Unix socket (container user matches postgres username)
-	var restoreCmd []string
-	var opts *ExecOptions
 	containerUser, err := iops.Exec("task-manager-db", []string{"whoami"}, nil)
 	useUnixSocket := err == nil && !strings.Contains(strings.TrimSpace(containerUser), "cannot find name")
-	if useUnixSocket {
-		// Use Unix socket connection (no host, user, or password)
-		restoreCmd = []string{"pg_restore", "-d", "postgres", "--clean", "--create", dumpFile}
-		// On Docker, run as the postgres user so Unix socket auth succeeds
-		backend, backendErr := iops.ensureBackend()
-		if backendErr == nil && backend.Name() == "docker" {
-			opts = &ExecOptions{User: iops.config.PostgresUsername}
-		} else {
-			opts = nil
+	restoreCmd, opts := iops.pgRestoreCommand(dumpFile, useUnixSocket)
+	output, err := iops.Exec("task-manager-db", restoreCmd, opts)
+	if err != nil {
+		// Dumps from Helm deployments routinely reference roles (e.g. prefect)
+		// that do not exist on compose targets; ownership is not needed to run.
+		missingRoles := parseMissingPostgresRoles(output)
+		if len(missingRoles) == 0 || slices.Contains(restoreCmd, "--no-owner") {
+			return fmt.Errorf("failed to restore postgresql: %w\nOutput: %v", err, output)
+		}
+
+		logrus.Warnf("pg_restore failed because role(s) %s do not exist on the target; retrying with --no-owner --no-privileges",
+			strings.Join(missingRoles, ", "))
+		retryCmd := append(restoreCmd[:len(restoreCmd)-1:len(restoreCmd)-1], "--no-owner", "--no-privileges", dumpFile)
+		if output, err := iops.Exec("task-manager-db", retryCmd, opts); err != nil {
+			return fmt.Errorf("failed to restore postgresql without ownership: %w\nOutput: %v", err, output)
 		}
-	} else {
-		// Use TCP connection with credentials
-		opts = &ExecOptions{Env: map[string]string{
-			"PGPASSWORD": iops.config.PostgresPassword,
-		}}
-		restoreCmd = []string{"pg_restore", "-h", "localhost", "-d", "postgres", "-U", iops.config.PostgresUsername, "--clean", "--create", dumpFile}
-	}
-	if output, err := iops.Exec(
-		"task-manager-db",
-		restoreCmd,
-		opts,
-	); err != nil {
-		return fmt.Errorf("failed to restore postgresql: %w\nOutput: %v", err, output)
 	}
 
 	return nil
 }
+
+// missingRolePattern matches PostgreSQL "role does not exist" errors.
+var missingRolePattern = regexp.MustCompile(`role "([^"]+)" does not exist`)
+
+// parseMissingPostgresRoles returns the distinct role names reported missing
+// in pg_restore output.
+func parseMissingPostgresRoles(output string) []string {
+	roles := []string{}
+	for _, match := range missingRolePattern.FindAllStringSubmatch(output, -1) {
+		if !slices.Contains(roles, match[1]) {
+			roles = append(roles, match[1])
+		}
+	}
+	return roles
+}
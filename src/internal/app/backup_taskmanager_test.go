@@ -0,0 +1,249 @@
+package app
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMissingPostgresRoles(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []string
+	}{
+		{
+			name: "owner and grant errors for the same role",
+			output: `pg_restore: error: could not execute query: ERROR:  role "prefect" does not exist
+Command was: ALTER DATABASE prefect OWNER TO prefect;
+pg_restore: error: could not execute query: ERROR:  role "prefect" does not exist
+Command was: GRANT ALL ON SCHEMA public TO prefect;`,
+			want: []string{"prefect"},
+		},
+		{
+			name:   "several roles",
+			output: `ERROR:  role "prefect" does not exist ... ERROR:  role "readonly" does not exist`,
+			want:   []string{"prefect", "readonly"},
+		},
+		{
+			name:   "unrelated failure",
+			output: `pg_restore: error: connection to server failed: FATAL:  password authentication failed`,
+			want:   []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseMissingPostgresRoles(tt.output)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseMissingPostgresRoles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckPostgresCompatibility(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		target  string
+		wantErr bool
+	}{
+		{name: "same major", source: "16.4 (Debian 16.4-1.pgdg120+2)", target: "16.2", wantErr: false},
+		{name: "upgrade", source: "15.8", target: "17.0", wantErr: false},
+		{name: "downgrade", source: "17.2", target: "16.4", wantErr: true},
+		{name: "legacy two-part major", source: "9.6.24", target: "13.1", wantErr: false},
+		{name: "unparseable", source: "unknown", target: "16.4", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkPostgresCompatibility(tt.source, tt.target)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkPostgresCompatibility(%q, %q) error = %v, wantErr %t", tt.source, tt.target, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyPrefectSchemaRevision(t *testing.T) {
+	tests := []struct {
+		name        string
+		source      string
+		target      string
+		targetErr   error
+		migrate     bool
+		wantMigrate bool
+		wantErr     bool
+	}{
+		{name: "same revision", source: "a49711513ad4", target: "a49711513ad4\n"},
+		{name: "different revision", source: "7495a5013e7e", target: "a49711513ad4\n", wantErr: true},
+		{name: "different revision with migrations", source: "7495a5013e7e", target: "a49711513ad4\n", migrate: true, wantMigrate: true},
+		{name: "not recorded", target: "a49711513ad4\n"},
+		{name: "target unreadable", source: "7495a5013e7e", targetErr: errors.New("relation \"alembic_version\" does not exist")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iops := newFakeDockerOps(newFakeExecutor().on("alembic_version", tt.target, tt.targetErr))
+			iops.config.RunPrefectMigrations = tt.migrate
+
+			migrate, err := iops.verifyPrefectSchemaRevision(&BackupMetadata{PrefectSchemaRevision: tt.source})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verifyPrefectSchemaRevision() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if migrate != tt.wantMigrate {
+				t.Errorf("verifyPrefectSchemaRevision() = %t, want %t", migrate, tt.wantMigrate)
+			}
+		})
+	}
+}
+
+func TestRunPrefectMigrations(t *testing.T) {
+	fake := newFakeExecutor()
+	iops := newFakeDockerOps(fake)
+
+	if err := iops.runPrefectMigrations(); err != nil {
+		t.Fatalf("runPrefectMigrations() error = %v", err)
+	}
+	if got := fake.commands(strings.Join(prefectMigrateCommand, " ")); len(got) != 1 {
+		t.Errorf("migration commands = %v, want one", got)
+	}
+
+	fake = newFakeExecutor().on("database upgrade", "Can't locate revision", errors.New("exit status 1"))
+	iops = newFakeDockerOps(fake)
+	if err := iops.runPrefectMigrations(); err == nil || !strings.Contains(err.Error(), "newer Infrahub version") {
+		t.Errorf("runPrefectMigrations() error = %v, want a newer version hint", err)
+	}
+	if got := fake.commands("start task-manager"); len(got) != 0 {
+		t.Errorf("task manager restarted after failed migrations: %v", got)
+	}
+}
+
+func TestBackupTaskManagerDBRemovesDumpWhenCopyFails(t *testing.T) {
+	fake := newFakeExecutor().on("cp task-manager-db:", "", errors.New("no space left on device"))
+	iops := newFakeDockerOps(fake)
+
+	if err := iops.backupTaskManagerDB(t.TempDir()); err == nil {
+		t.Fatal("backupTaskManagerDB() error = nil, want copy failure")
+	}
+	if got := fake.commands("rm /tmp/infrahubops_prefect.dump"); len(got) != 1 {
+		t.Errorf("dump cleanup commands = %v, want one", got)
+	}
+}
+
+func TestBackupTaskManagerDBSkipsCleanupWhenDumpFails(t *testing.T) {
+	fake := newFakeExecutor().on("pg_dump", "connection refused", errors.New("exit status 1"))
+	iops := newFakeDockerOps(fake)
+
+	if err := iops.backupTaskManagerDB(t.TempDir()); err == nil {
+		t.Fatal("backupTaskManagerDB() error = nil, want dump failure")
+	}
+	if got := fake.commands("cp task-manager-db:"); len(got) != 0 {
+		t.Errorf("copied a dump that was never created: %v", got)
+	}
+	if got := fake.commands("rm /tmp/infrahubops_prefect.dump"); len(got) != 0 {
+		t.Errorf("removed a dump that was never created: %v", got)
+	}
+}
+
+func TestRestorePostgreSQLRemovesDumpWhenRestoreFails(t *testing.T) {
+	fake := newFakeExecutor().on("pg_restore", "pg_restore: error: could not connect", errors.New("exit status 1"))
+	iops := newFakeDockerOps(fake)
+
+	if err := iops.restorePostgreSQL(t.TempDir()); err == nil {
+		t.Fatal("restorePostgreSQL() error = nil, want restore failure")
+	}
+	if got := fake.commands("start task-manager-db"); len(got) != 1 {
+		t.Errorf("start commands = %v, want one", got)
+	}
+	if got := fake.commands("rm /tmp/infrahubops_prefect.dump"); len(got) != 1 {
+		t.Errorf("dump cleanup commands = %v, want one", got)
+	}
+}
+
+func TestBackupTaskManagerDBDirectoryFormat(t *testing.T) {
+	fake := newFakeExecutor()
+	iops := newFakeDockerOps(fake)
+	iops.config.PgJobs = 4
+
+	if err := iops.backupTaskManagerDB(t.TempDir()); err != nil {
+		t.Fatalf("backupTaskManagerDB() error = %v", err)
+	}
+	dumps := fake.commands("pg_dump")
+	if len(dumps) != 1 || !strings.Contains(dumps[0], "pg_dump -Fd -j 4") || !strings.Contains(dumps[0], "-f /tmp/infrahubops_prefect.dir") {
+		t.Errorf("dump commands = %v, want a directory format dump with 4 jobs", dumps)
+	}
+	if got := fake.commands("rm -r /tmp/infrahubops_prefect.dir"); len(got) != 1 {
+		t.Errorf("dump cleanup commands = %v, want one", got)
+	}
+}
+
+func TestRestorePostgreSQLDirectoryFormat(t *testing.T) {
+	workDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(workDir, "backup", prefectDumpDirName), 0755); err != nil {
+		t.Fatal(err)
+	}
+	fake := newFakeExecutor()
+	iops := newFakeDockerOps(fake)
+	iops.config.PgJobs = 8
+
+	if err := iops.restorePostgreSQL(workDir); err != nil {
+		t.Fatalf("restorePostgreSQL() error = %v", err)
+	}
+	restores := fake.commands("pg_restore")
+	if len(restores) != 1 || !strings.Contains(restores[0], "-j 8") || !strings.HasSuffix(restores[0], "/tmp/infrahubops_prefect.dir") {
+		t.Errorf("restore commands = %v, want a parallel restore of the dump directory", restores)
+	}
+}
+
+func TestTaskManagerDumpPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		create  string
+		isDir   bool
+		want    string
+		wantHas bool
+	}{
+		{name: "custom format", create: prefectDumpFilename, want: prefectDumpFilename, wantHas: true},
+		{name: "directory format", create: prefectDumpDirName, isDir: true, want: prefectDumpDirName, wantHas: true},
+		{name: "none", want: prefectDumpFilename},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.isDir {
+				if err := os.Mkdir(filepath.Join(dir, tt.create), 0755); err != nil {
+					t.Fatal(err)
+				}
+			} else if tt.create != "" {
+				if err := os.WriteFile(filepath.Join(dir, tt.create), []byte("dump"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := taskManagerDumpPath(dir); got != filepath.Join(dir, tt.want) {
+				t.Errorf("taskManagerDumpPath() = %s, want %s", got, tt.want)
+			}
+			if got := hasTaskManagerDump(dir); got != tt.wantHas {
+				t.Errorf("hasTaskManagerDump() = %v, want %v", got, tt.wantHas)
+			}
+		})
+	}
+}
+
+func TestBackupTaskManagerDBExcludesTableData(t *testing.T) {
+	fake := newFakeExecutor()
+	iops := newFakeDockerOps(fake)
+	iops.config.PgExcludeTableData = []string{"log", "event*"}
+
+	if err := iops.backupTaskManagerDB(t.TempDir()); err != nil {
+		t.Fatalf("backupTaskManagerDB() error = %v", err)
+	}
+	dumps := fake.commands("pg_dump")
+	if len(dumps) != 1 || !strings.HasSuffix(dumps[0], "--exclude-table-data=log --exclude-table-data=event*") {
+		t.Errorf("dump commands = %v, want the table data exclusions", dumps)
+	}
+}
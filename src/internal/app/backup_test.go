@@ -0,0 +1,86 @@
+package app
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// commandIndex returns the position of the first command containing match.
+func commandIndex(t *testing.T, commands []string, match string) int {
+	t.Helper()
+	index := slices.IndexFunc(commands, func(command string) bool { return strings.Contains(command, match) })
+	if index < 0 {
+		t.Fatalf("no command contains %q in %v", match, commands)
+	}
+	return index
+}
+
+func newRestoreWorkDir(t *testing.T) string {
+	workDir := t.TempDir()
+	writeTestFile(t, workDir, filepath.Join("backup", "database", "neo4j.backup"), "backup")
+	writeTestFile(t, workDir, filepath.Join("backup", prefectDumpFilename), "dump")
+	return workDir
+}
+
+func newRestoreFake() *fakeExecutor {
+	return newFakeExecutor().on("ps -a --format json", `[
+{"Service":"infrahub-server","State":"running"},
+{"Service":"task-worker","State":"running"},
+{"Service":"task-manager","State":"running"}
+]`, nil)
+}
+
+func TestRestoreWithMinimalDowntimeOrdering(t *testing.T) {
+	fake := newRestoreFake()
+	iops := newFakeDockerOps(fake)
+
+	if err := iops.restoreWithMinimalDowntime(newRestoreWorkDir(t), &BackupMetadata{BackupID: "b"}, neo4jEditionEnterprise, nil, true, false, false, false); err != nil {
+		t.Fatalf("restoreWithMinimalDowntime() error = %v", err)
+	}
+	commands := fake.commands("")
+	staged := commandIndex(t, commands, filepath.Join("backup", "database")+" database:")
+	stopTaskManager := commandIndex(t, commands, "stop task-manager")
+	pgRestore := commandIndex(t, commands, "pg_restore")
+	stopServer := commandIndex(t, commands, "stop infrahub-server")
+	neo4jRestore := commandIndex(t, commands, "neo4j-admin database restore")
+	startServer := commandIndex(t, commands, "start infrahub-server")
+
+	if staged > stopServer || pgRestore > stopServer {
+		t.Errorf("Neo4j staged at %d and task manager restored at %d, want both before infrahub-server stops at %d", staged, pgRestore, stopServer)
+	}
+	if stopTaskManager > pgRestore {
+		t.Errorf("task manager stopped at %d, after its database was restored at %d", stopTaskManager, pgRestore)
+	}
+	if !(stopServer < neo4jRestore && neo4jRestore < startServer) {
+		t.Errorf("infrahub-server stopped at %d and started at %d, want the Neo4j restore at %d in between", stopServer, startServer, neo4jRestore)
+	}
+}
+
+func TestRunRestoreWindowRestoresTaskManagerWhileStopped(t *testing.T) {
+	fake := newRestoreFake()
+	iops := newFakeDockerOps(fake)
+	workDir := newRestoreWorkDir(t)
+
+	var neo4jRestored bool
+	window := restoreWindow{
+		restorePrefect:     true,
+		restoreTaskManager: func() error { return iops.restorePostgreSQL(workDir) },
+		restoreNeo4j:       func() error { neo4jRestored = true; return nil },
+	}
+	if err := iops.runRestoreWindow(workDir, &BackupMetadata{BackupID: "b"}, window); err != nil {
+		t.Fatalf("runRestoreWindow() error = %v", err)
+	}
+	if !neo4jRestored {
+		t.Error("graph database not restored")
+	}
+	commands := fake.commands("")
+	stopServer := commandIndex(t, commands, "stop infrahub-server")
+	pgRestore := commandIndex(t, commands, "pg_restore")
+	startTaskManager := commandIndex(t, commands, "start task-manager-background-svc")
+	startServer := commandIndex(t, commands, "start infrahub-server")
+	if !(stopServer < pgRestore && pgRestore < startTaskManager && startTaskManager < startServer) {
+		t.Errorf("order = stop server %d, pg_restore %d, start task-manager %d, start server %d; want ascending", stopServer, pgRestore, startTaskManager, startServer)
+	}
+}
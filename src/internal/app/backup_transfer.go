@@ -0,0 +1,378 @@
+package app
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// A transfer package is a directory holding an archive with everything needed
+// to check it after a one-way transfer (data diode, USB disk) without access
+// to the source: detached checksums in sha256sum format, an optional ECDSA
+// signature over them, and a manifest an operator can read.
+const (
+	transferChecksumsFilename = "SHA256SUMS"
+	transferSignatureFilename = "SHA256SUMS.sig"
+	transferManifestFilename  = "MANIFEST.txt"
+)
+
+// transferFile is one archive file listed in SHA256SUMS.
+type transferFile struct {
+	Name   string
+	SHA256 string
+}
+
+// PackageForTransfer copies the archive of a catalogued or local backup into
+// outputDir/<backup-id> with its checksums, manifest and, when signKey is set,
+// a signature made with that keygen private key. It returns the package
+// directory.
+func (iops *InfrahubOps) PackageForTransfer(ref, outputDir, signKey string) (string, error) {
+	entry, err := iops.localBackupEntry(ref)
+	if err != nil {
+		return "", err
+	}
+	var signer *ecdsa.PrivateKey
+	if signKey != "" {
+		if signer, err = loadECDSAPrivateKey(signKey); err != nil {
+			return "", fmt.Errorf("failed to load signing key: %w", err)
+		}
+	}
+	files, err := splitArchiveFiles(entry.LocalPath)
+	if err != nil {
+		return "", err
+	}
+
+	packageDir := filepath.Join(outputDir, entry.BackupID)
+	if _, err := os.Stat(packageDir); err == nil {
+		return "", fmt.Errorf("transfer package %s already exists", packageDir)
+	}
+	if err := os.MkdirAll(packageDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create transfer package: %w", err)
+	}
+	fail := func(err error) (string, error) {
+		os.RemoveAll(packageDir)
+		return "", err
+	}
+
+	logrus.Infof("Packaging %s for transfer...", entry.BackupID)
+	var listed []transferFile
+	var sizes []int64
+	for _, file := range files {
+		sum, size, err := copyFileWithSHA256(file, filepath.Join(packageDir, filepath.Base(file)))
+		if err != nil {
+			return fail(fmt.Errorf("failed to copy %s: %w", file, err))
+		}
+		listed = append(listed, transferFile{Name: filepath.Base(file), SHA256: sum})
+		sizes = append(sizes, size)
+	}
+
+	checksums := formatTransferChecksums(listed)
+	if err := os.WriteFile(filepath.Join(packageDir, transferChecksumsFilename), checksums, 0644); err != nil {
+		return fail(err)
+	}
+	if signer != nil {
+		digest := sha256.Sum256(checksums)
+		signature, err := ecdsa.SignASN1(rand.Reader, signer, digest[:])
+		if err != nil {
+			return fail(fmt.Errorf("failed to sign checksums: %w", err))
+		}
+		if err := os.WriteFile(filepath.Join(packageDir, transferSignatureFilename), []byte(base64.StdEncoding.EncodeToString(signature)+"\n"), 0644); err != nil {
+			return fail(err)
+		}
+	}
+	manifest := formatTransferManifest(entry, listed, sizes, signer != nil, time.Now().UTC())
+	if err := os.WriteFile(filepath.Join(packageDir, transferManifestFilename), manifest, 0644); err != nil {
+		return fail(err)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"backup_id": entry.BackupID,
+		"path":      packageDir,
+		"files":     len(listed),
+		"signed":    signer != nil,
+	}).Info("Transfer package created")
+	return packageDir, nil
+}
+
+// ReceiveTransfer checks a transfer package and, when every check passes,
+// copies its archive into the backup directory and adds it to the catalog.
+// With verifyKey, the package must carry a valid signature by that key.
+func (iops *InfrahubOps) ReceiveTransfer(packageDir, verifyKey string) error {
+	checksums, err := os.ReadFile(filepath.Join(packageDir, transferChecksumsFilename))
+	if err != nil {
+		return fmt.Errorf("not a transfer package: %w", err)
+	}
+	if err := verifyTransferSignature(packageDir, checksums, verifyKey); err != nil {
+		return err
+	}
+	listed, err := parseTransferChecksums(checksums)
+	if err != nil {
+		return err
+	}
+
+	var size int64
+	for _, file := range listed {
+		sum, err := calculateSHA256(filepath.Join(packageDir, file.Name))
+		if err != nil {
+			return fmt.Errorf("package file missing: %w", err)
+		}
+		if sum != file.SHA256 {
+			return fmt.Errorf("checksum mismatch for %s: package is corrupt or was altered", file.Name)
+		}
+		if info, err := os.Stat(filepath.Join(packageDir, file.Name)); err == nil && !strings.HasSuffix(file.Name, splitManifestSuffix) {
+			size += info.Size()
+		}
+	}
+	warnUnlistedTransferFiles(packageDir, listed)
+	logrus.Infof("All %d package files match their checksums", len(listed))
+
+	archive := listed[0].Name
+	for _, file := range listed {
+		if strings.HasSuffix(file.Name, splitManifestSuffix) {
+			archive = file.Name
+		}
+	}
+	if len(listed) > 1 && !strings.HasSuffix(archive, splitManifestSuffix) {
+		return fmt.Errorf("transfer package lists %d files but no split manifest", len(listed))
+	}
+	// MANIFEST.txt is covered by neither the checksums nor the signature, so
+	// the catalog entry is built from the checked archive only.
+	backupID, createdAt := iops.transferArchiveIdentity(filepath.Join(packageDir, archive))
+
+	if err := os.MkdirAll(iops.config.BackupDir, 0755); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	for _, file := range listed {
+		if fileExists(filepath.Join(iops.config.BackupDir, file.Name)) {
+			return fmt.Errorf("%s already exists in %s", file.Name, iops.config.BackupDir)
+		}
+	}
+	var copied []string
+	removeCopied := func() {
+		for _, path := range copied {
+			os.Remove(path)
+		}
+	}
+	for _, file := range listed {
+		dst := filepath.Join(iops.config.BackupDir, file.Name)
+		if _, _, err := copyFileWithSHA256(filepath.Join(packageDir, file.Name), dst); err != nil {
+			// copyFileWithSHA256 creates dst exclusively, so a partial copy is ours
+			if !errors.Is(err, os.ErrExist) {
+				copied = append(copied, dst)
+			}
+			removeCopied()
+			return fmt.Errorf("failed to copy %s: %w", file.Name, err)
+		}
+		copied = append(copied, dst)
+	}
+
+	catalog, err := loadBackupCatalog(iops.config.BackupDir)
+	if err != nil {
+		removeCopied()
+		return err
+	}
+	localPath, err := filepath.Abs(filepath.Join(iops.config.BackupDir, archive))
+	if err != nil {
+		localPath = filepath.Join(iops.config.BackupDir, archive)
+	}
+	catalog.upsert(CatalogEntry{
+		BackupID:  backupID,
+		Filename:  archive,
+		LocalPath: localPath,
+		CreatedAt: createdAt,
+		SizeBytes: size,
+	})
+	if err := catalog.save(); err != nil {
+		removeCopied()
+		return err
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"backup_id": backupID,
+		"path":      localPath,
+	}).Info("Transfer package received and added to the catalog")
+	return nil
+}
+
+// transferArchiveIdentity returns the backup ID and creation time recorded in
+// the metadata of a checked transfer archive. When the metadata cannot be
+// read, such as in an encrypted archive, the ID comes from the archive name,
+// which the checksums cover, and the creation time is now.
+func (iops *InfrahubOps) transferArchiveIdentity(archivePath string) (string, string) {
+	metadata, err := iops.BackupInfo(archivePath, "")
+	if err == nil && metadata.BackupID != "" {
+		return metadata.BackupID, metadata.CreatedAt
+	}
+	logrus.Warnf("Could not read the metadata of %s; naming the backup after the archive: %v", filepath.Base(archivePath), err)
+	return backupIDFromFilename(filepath.Base(archivePath)), time.Now().UTC().Format(time.RFC3339)
+}
+
+// localBackupEntry resolves ref to a backup with a local archive.
+func (iops *InfrahubOps) localBackupEntry(ref string) (*CatalogEntry, error) {
+	catalog, err := loadBackupCatalog(iops.config.BackupDir)
+	if err != nil {
+		return nil, err
+	}
+	entry := catalog.find(ref)
+	if entry == nil {
+		if entry, err = iops.catalogEntryForRef(ref); err != nil {
+			return nil, err
+		}
+	}
+	if entry.LocalPath == "" || !fileExists(entry.LocalPath) {
+		return nil, fmt.Errorf("backup %s has no local archive; download it first", entry.BackupID)
+	}
+	return entry, nil
+}
+
+func copyFileWithSHA256(src, dst string) (string, int64, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return "", 0, err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", 0, err
+	}
+	defer out.Close()
+
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(out, hash), in)
+	if err != nil {
+		return "", n, err
+	}
+	if err := out.Sync(); err != nil {
+		return "", n, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), n, out.Close()
+}
+
+// formatTransferChecksums writes files in sha256sum format, so the package can
+// also be checked with `sha256sum -c SHA256SUMS`.
+func formatTransferChecksums(files []transferFile) []byte {
+	var b strings.Builder
+	for _, file := range files {
+		fmt.Fprintf(&b, "%s  %s\n", file.SHA256, file.Name)
+	}
+	return []byte(b.String())
+}
+
+func parseTransferChecksums(data []byte) ([]transferFile, error) {
+	var files []transferFile
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		sum, name, ok := strings.Cut(line, "  ")
+		name = strings.TrimPrefix(name, "*")
+		if !ok || len(sum) != sha256.Size*2 || name == "" || name != filepath.Base(name) || name == ".." {
+			return nil, fmt.Errorf("invalid %s line: %q", transferChecksumsFilename, line)
+		}
+		files = append(files, transferFile{Name: name, SHA256: strings.ToLower(sum)})
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s lists no files", transferChecksumsFilename)
+	}
+	return files, nil
+}
+
+// verifyTransferSignature checks the signature over SHA256SUMS with the
+// base64 public key in verifyKey. Without a key, an unchecked signature is
+// only reported.
+func verifyTransferSignature(packageDir string, checksums []byte, verifyKey string) error {
+	encoded, err := os.ReadFile(filepath.Join(packageDir, transferSignatureFilename))
+	if verifyKey == "" {
+		if err == nil {
+			logrus.Warn("Transfer package is signed but the signature was not checked; pass --verify-key to check it")
+		} else {
+			logrus.Warn("Transfer package is not signed; only its checksums are checked")
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("--verify-key was given but the package has no %s", transferSignatureFilename)
+	}
+
+	publicKey, err := loadECDSAPublicKey(verifyKey)
+	if err != nil {
+		return err
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", transferSignatureFilename, err)
+	}
+	digest := sha256.Sum256(checksums)
+	if !ecdsa.VerifyASN1(publicKey, digest[:], signature) {
+		return fmt.Errorf("signature check failed: %s was not signed by this key or was altered", transferChecksumsFilename)
+	}
+	logrus.Info("Transfer package signature is valid")
+	return nil
+}
+
+// warnUnlistedTransferFiles reports files that travelled with the package
+// but are not covered by its checksums; they are not received.
+func warnUnlistedTransferFiles(packageDir string, listed []transferFile) {
+	entries, err := os.ReadDir(packageDir)
+	if err != nil {
+		return
+	}
+	known := []string{transferChecksumsFilename, transferSignatureFilename, transferManifestFilename}
+	for _, file := range listed {
+		known = append(known, file.Name)
+	}
+	for _, entry := range entries {
+		if !slices.Contains(known, entry.Name()) {
+			logrus.Warnf("Ignoring %s: not listed in %s", entry.Name(), transferChecksumsFilename)
+		}
+	}
+}
+
+// formatTransferManifest renders the human-readable MANIFEST.txt. It is for
+// operators only: receive never trusts it.
+func formatTransferManifest(entry *CatalogEntry, files []transferFile, sizes []int64, signed bool, packagedAt time.Time) []byte {
+	var b strings.Builder
+	fmt.Fprintln(&b, "Infrahub backup transfer package")
+	fmt.Fprintln(&b)
+	fmt.Fprintf(&b, "Backup ID: %s\n", entry.BackupID)
+	fmt.Fprintf(&b, "Created: %s\n", entry.CreatedAt)
+	fmt.Fprintf(&b, "Packaged: %s\n", packagedAt.Format(time.RFC3339))
+	if entry.SizeBytes > 0 {
+		fmt.Fprintf(&b, "Archive size: %s (%d bytes)\n", formatBytes(entry.SizeBytes), entry.SizeBytes)
+	}
+	if entry.Verified {
+		fmt.Fprintf(&b, "Restore verified: %s\n", entry.VerifiedAt)
+	}
+	if signed {
+		fmt.Fprintf(&b, "Signature: %s (ECDSA P-256 over %s)\n", transferSignatureFilename, transferChecksumsFilename)
+	} else {
+		fmt.Fprintln(&b, "Signature: none")
+	}
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "Files:")
+	for i, file := range files {
+		fmt.Fprintf(&b, "  %s  %12d  %s\n", file.SHA256, sizes[i], file.Name)
+	}
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "To check and import this package on the receiving side:")
+	fmt.Fprintln(&b, "  infrahub-backup receive <this-directory> [--verify-key <public-key-file>]")
+	fmt.Fprintln(&b, "To check the files only:")
+	fmt.Fprintf(&b, "  sha256sum -c %s\n", transferChecksumsFilename)
+	return []byte(b.String())
+}
@@ -0,0 +1,183 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTransferRoundTrip(t *testing.T) {
+	verifyKey, signKey := writeArchiveKeys(t, t.TempDir())
+	otherKey, _ := writeArchiveKeys(t, t.TempDir())
+
+	tests := []struct {
+		name      string
+		split     bool
+		sign      bool
+		verifyKey string
+		tamper    func(t *testing.T, dir string)
+		wantErr   string
+	}{
+		{name: "unsigned"},
+		{name: "signed", sign: true, verifyKey: verifyKey},
+		{name: "split archive", split: true, sign: true, verifyKey: verifyKey},
+		{name: "signed without verify key", sign: true},
+		{name: "wrong key", sign: true, verifyKey: otherKey, wantErr: "signature check failed"},
+		{name: "unsigned with verify key", verifyKey: verifyKey, wantErr: "has no SHA256SUMS.sig"},
+		{
+			name: "archive altered",
+			tamper: func(t *testing.T, dir string) {
+				if err := os.WriteFile(filepath.Join(dir, "infrahub_backup_20250101_120000.tar.gz"), []byte("evil"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: "checksum mismatch",
+		},
+		{
+			name: "checksums altered",
+			sign: true, verifyKey: verifyKey,
+			tamper: func(t *testing.T, dir string) {
+				path := filepath.Join(dir, transferChecksumsFilename)
+				data, _ := os.ReadFile(path)
+				if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: "signature check failed",
+		},
+		{
+			name: "path escape",
+			tamper: func(t *testing.T, dir string) {
+				line := strings.Repeat("0", 64) + "  ../outside\n"
+				if err := os.WriteFile(filepath.Join(dir, transferChecksumsFilename), []byte(line), 0644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: "invalid SHA256SUMS line",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := NewInfrahubOps()
+			source.config.BackupDir = t.TempDir()
+			archive := filepath.Join(source.config.BackupDir, "infrahub_backup_20250101_120000.tar.gz")
+			if err := os.WriteFile(archive, []byte(strings.Repeat("backup data ", 100)), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if tt.split {
+				var err error
+				if archive, err = splitArchive(archive, 500); err != nil {
+					t.Fatal(err)
+				}
+			}
+			key := ""
+			if tt.sign {
+				key = signKey
+			}
+
+			packageDir, err := source.PackageForTransfer(filepath.Base(archive), t.TempDir(), key)
+			if err != nil {
+				t.Fatalf("PackageForTransfer() error = %v", err)
+			}
+			if filepath.Base(packageDir) != "infrahub_backup_20250101_120000" {
+				t.Errorf("package dir = %s", packageDir)
+			}
+			if tt.tamper != nil {
+				tt.tamper(t, packageDir)
+			}
+
+			target := NewInfrahubOps()
+			target.config.BackupDir = t.TempDir()
+			err = target.ReceiveTransfer(packageDir, tt.verifyKey)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ReceiveTransfer() error = %v, want %q", err, tt.wantErr)
+				}
+				if entries, _ := os.ReadDir(target.config.BackupDir); len(entries) != 0 {
+					t.Errorf("files copied despite failed checks: %v", entries)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReceiveTransfer() error = %v", err)
+			}
+
+			catalog, err := loadBackupCatalog(target.config.BackupDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			entry := catalog.find("infrahub_backup_20250101_120000")
+			if entry == nil {
+				t.Fatal("received backup missing from catalog")
+			}
+			if entry.Filename != filepath.Base(archive) || !fileExists(entry.LocalPath) {
+				t.Errorf("catalog entry = %+v, want archive %s", entry, filepath.Base(archive))
+			}
+			if tt.split {
+				if _, err := joinSplitArchive(entry.LocalPath, t.TempDir()); err != nil {
+					t.Errorf("received split archive does not reassemble: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestReceiveTransferIgnoresManifest(t *testing.T) {
+	source := NewInfrahubOps()
+	source.config.BackupDir = t.TempDir()
+	archive := writeVerifiableBackup(t, source, "infrahub_backup_20250101_120000", false)
+	packageDir, err := source.PackageForTransfer(archive, t.TempDir(), "")
+	if err != nil {
+		t.Fatalf("PackageForTransfer() error = %v", err)
+	}
+	manifestPath := filepath.Join(packageDir, transferManifestFilename)
+	manifest, err := os.ReadFile(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest = []byte(strings.Replace(string(manifest), "Backup ID: infrahub_backup_20250101_120000", "Backup ID: victim", 1))
+	if err := os.WriteFile(manifestPath, manifest, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	target := NewInfrahubOps()
+	target.config.BackupDir = t.TempDir()
+	catalog, err := loadBackupCatalog(target.config.BackupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	catalog.upsert(CatalogEntry{BackupID: "victim", Filename: "victim.tar.gz", S3URI: "s3://backups/victim.tar.gz"})
+	if err := catalog.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := target.ReceiveTransfer(packageDir, ""); err != nil {
+		t.Fatalf("ReceiveTransfer() error = %v", err)
+	}
+	if catalog, err = loadBackupCatalog(target.config.BackupDir); err != nil {
+		t.Fatal(err)
+	}
+	if entry := catalog.find("victim"); entry == nil || entry.LocalPath != "" {
+		t.Errorf("victim entry = %+v, want it untouched", entry)
+	}
+	if entry := catalog.find("infrahub_backup_20250101_120000"); entry == nil || entry.CreatedAt == "" {
+		t.Errorf("received entry = %+v, want the ID and creation time of the archive metadata", entry)
+	}
+}
+
+func TestPackageForTransferRefusesExistingPackage(t *testing.T) {
+	iops := NewInfrahubOps()
+	iops.config.BackupDir = t.TempDir()
+	archive := filepath.Join(iops.config.BackupDir, "infrahub_backup_20250101_120000.tar.gz")
+	if err := os.WriteFile(archive, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	output := t.TempDir()
+	if _, err := iops.PackageForTransfer(archive, output, ""); err != nil {
+		t.Fatalf("PackageForTransfer() error = %v", err)
+	}
+	if _, err := iops.PackageForTransfer(archive, output, ""); err == nil {
+		t.Error("second package into the same directory should fail")
+	}
+}
@@ -0,0 +1,115 @@
+package app
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// checkVerifyRestore rejects --verify-restore before the backup starts when
+// the rehearsal could not run afterwards.
+func (iops *InfrahubOps) checkVerifyRestore(encrypted bool) error {
+	if !iops.config.VerifyRestore {
+		return nil
+	}
+	if iops.config.Backend == BackendPlakar {
+		return fmt.Errorf("--verify-restore is not supported with the plakar backend")
+	}
+	if encrypted && iops.config.VerifyDecryptKey == "" {
+		return fmt.Errorf("--verify-restore of an encrypted backup requires --verify-decrypt-key")
+	}
+	if err := iops.executor.runCommandQuiet("docker", "version"); err != nil {
+		return fmt.Errorf("--verify-restore needs a working docker CLI on this host: %w", err)
+	}
+	return nil
+}
+
+// verifyCreatedBackup rehearses a restore of the archive at backupPath in
+// throwaway containers. It returns false when --verify-restore is not set.
+func (iops *InfrahubOps) verifyCreatedBackup(backupPath string, excludeTaskManager bool) (bool, error) {
+	if !iops.config.VerifyRestore {
+		return false, nil
+	}
+	logrus.Info("Verifying the backup with a rehearsal restore...")
+	err := iops.RehearseRestore(backupPath, iops.config.VerifyDecryptKey, excludeTaskManager, RehearsalOptions{})
+	if err != nil {
+		logrus.Errorf("Verification restore failed: %v", err)
+	} else {
+		logrus.Info("Backup verified")
+	}
+	return true, err
+}
+
+// markBackupVerified records the outcome of the verification restore of
+// backupID in the catalog. Only a successful rehearsal marks it verified.
+func (iops *InfrahubOps) markBackupVerified(backupID string, verifyErr error) error {
+	catalog, err := loadBackupCatalog(iops.config.BackupDir)
+	if err != nil {
+		return err
+	}
+	entry := catalog.find(backupID)
+	if entry == nil {
+		return fmt.Errorf("backup %s is not in the catalog", backupID)
+	}
+
+	entry.VerifiedAt = time.Now().UTC().Format(time.RFC3339)
+	entry.Verified = verifyErr == nil
+	entry.VerifyError = ""
+	if verifyErr != nil {
+		entry.VerifyError = verifyErr.Error()
+	}
+	return catalog.save()
+}
+
+// VerifyRetainedBackup picks a random backup from the catalog whose archive
+// is still on the local backup volume and checks its checksums, so bit-rot is
+// found before the data is needed. With rehearse, a rehearsal restore follows
+// and its outcome is recorded in the catalog. Encrypted archives are only
+// picked when --verify-decrypt-key is set.
+func (iops *InfrahubOps) VerifyRetainedBackup(rehearse bool) error {
+	catalog, err := loadBackupCatalog(iops.config.BackupDir)
+	if err != nil {
+		return err
+	}
+	var candidates []CatalogEntry
+	for _, entry := range catalog.Entries {
+		if entry.LocalPath == "" || !fileExists(entry.LocalPath) {
+			continue
+		}
+		if strings.Contains(entry.Filename, ".enc") && iops.config.VerifyDecryptKey == "" {
+			continue
+		}
+		candidates = append(candidates, entry)
+	}
+	if len(candidates) == 0 {
+		logrus.Info("No local backups to verify")
+		return nil
+	}
+
+	entry := candidates[rand.IntN(len(candidates))]
+	logrus.WithField("backup_id", entry.BackupID).Info("Verifying checksums of a retained backup...")
+	if _, err := iops.verifyBackupArchive(entry.LocalPath, iops.config.VerifyDecryptKey); err != nil {
+		verifyErr := fmt.Errorf("backup %s failed verification: %w", entry.BackupID, err)
+		if markErr := iops.markBackupVerified(entry.BackupID, verifyErr); markErr != nil {
+			logrus.Warnf("Failed to record verification in catalog: %v", markErr)
+		}
+		return verifyErr
+	}
+	if !rehearse {
+		logrus.WithField("backup_id", entry.BackupID).Info("Retained backup checksums verified")
+		return nil
+	}
+
+	err = iops.RehearseRestore(entry.LocalPath, iops.config.VerifyDecryptKey, false, RehearsalOptions{})
+	if markErr := iops.markBackupVerified(entry.BackupID, err); markErr != nil {
+		logrus.Warnf("Failed to record verification in catalog: %v", markErr)
+	}
+	if err != nil {
+		return fmt.Errorf("backup %s failed the rehearsal restore: %w", entry.BackupID, err)
+	}
+	logrus.WithField("backup_id", entry.BackupID).Info("Retained backup verified with a rehearsal restore")
+	return nil
+}
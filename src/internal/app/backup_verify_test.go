@@ -0,0 +1,170 @@
+package app
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckVerifyRestore(t *testing.T) {
+	tests := []struct {
+		name      string
+		modify    func(cfg *Configuration)
+		encrypted bool
+		dockerErr error
+		want      string
+	}{
+		{name: "disabled", modify: func(cfg *Configuration) {}},
+		{name: "enabled", modify: func(cfg *Configuration) { cfg.VerifyRestore = true }},
+		{
+			name:   "plakar",
+			modify: func(cfg *Configuration) { cfg.VerifyRestore = true; cfg.Backend = BackendPlakar },
+			want:   "not supported with the plakar backend",
+		},
+		{
+			name:      "encrypted without key",
+			modify:    func(cfg *Configuration) { cfg.VerifyRestore = true },
+			encrypted: true,
+			want:      "requires --verify-decrypt-key",
+		},
+		{
+			name:      "encrypted with key",
+			modify:    func(cfg *Configuration) { cfg.VerifyRestore = true; cfg.VerifyDecryptKey = "key.pem" },
+			encrypted: true,
+		},
+		{
+			name:      "no docker",
+			modify:    func(cfg *Configuration) { cfg.VerifyRestore = true },
+			dockerErr: errors.New("command not found"),
+			want:      "needs a working docker CLI",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iops := NewInfrahubOpsWithExecutor(newFakeExecutor().on("docker version", "", tt.dockerErr))
+			tt.modify(iops.config)
+			err := iops.checkVerifyRestore(tt.encrypted)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("checkVerifyRestore() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("checkVerifyRestore() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarkBackupVerified(t *testing.T) {
+	iops := NewInfrahubOps()
+	iops.config.BackupDir = t.TempDir()
+	if err := iops.recordBackupInCatalog("infrahub_backup_20261016_220000", "/nonexistent/infrahub_backup_20261016_220000.tar.gz", "", 10); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := iops.markBackupVerified("infrahub_backup_20261016_220000", errors.New("neo4j rehearsal failed")); err != nil {
+		t.Fatalf("markBackupVerified() error = %v", err)
+	}
+	catalog, err := loadBackupCatalog(iops.config.BackupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := catalog.find("infrahub_backup_20261016_220000")
+	if entry.Verified || entry.VerifyError != "neo4j rehearsal failed" || entry.VerifiedAt == "" {
+		t.Errorf("failed verification recorded as %+v", entry)
+	}
+
+	if err := iops.markBackupVerified("infrahub_backup_20261016_220000", nil); err != nil {
+		t.Fatal(err)
+	}
+	catalog, _ = loadBackupCatalog(iops.config.BackupDir)
+	if entry := catalog.find("infrahub_backup_20261016_220000"); !entry.Verified || entry.VerifyError != "" {
+		t.Errorf("successful verification recorded as %+v", entry)
+	}
+
+	if err := iops.markBackupVerified("unknown", nil); err == nil {
+		t.Error("markBackupVerified() error = nil for a backup missing from the catalog")
+	}
+}
+
+// writeVerifiableBackup writes a catalogued archive with valid metadata whose
+// database checksum matches, or not when corrupt is set.
+func writeVerifiableBackup(t *testing.T, iops *InfrahubOps, backupID string, corrupt bool) string {
+	t.Helper()
+	workDir := t.TempDir()
+	backupDir := filepath.Join(workDir, "backup")
+	if err := os.MkdirAll(filepath.Join(backupDir, "database"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	dumpPath := filepath.Join(backupDir, "database", "neo4j.dump")
+	if err := os.WriteFile(dumpPath, []byte("graph"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sum, err := calculateSHA256(dumpPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if corrupt {
+		if err := os.WriteFile(dumpPath, []byte("grap\x00"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	metadata := iops.createBackupMetadata(backupID, false, "1.5.0", neo4jEditionCommunity)
+	metadata.Checksums = map[string]string{"database/neo4j.dump": sum}
+	data, err := marshalBackupMetadata(metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(backupDir, backupMetadataFilename), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	path, err := defaultArchivePipeline(false, false).Write(workDir, "backup/", filepath.Join(iops.config.BackupDir, backupID), ArchiveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := iops.recordBackupInCatalog(backupID, path, "", 10); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestVerifyRetainedBackup(t *testing.T) {
+	tests := []struct {
+		name       string
+		corrupt    bool
+		remove     bool
+		wantErr    bool
+		wantMarked bool
+	}{
+		{name: "intact"},
+		{name: "bit rot", corrupt: true, wantErr: true, wantMarked: true},
+		{name: "archive gone", remove: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iops := NewInfrahubOpsWithExecutor(newFakeExecutor())
+			iops.config.BackupDir = t.TempDir()
+			path := writeVerifiableBackup(t, iops, "infrahub_backup_20261016_220000", tt.corrupt)
+			if tt.remove {
+				os.Remove(path)
+			}
+
+			err := iops.VerifyRetainedBackup(false)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyRetainedBackup() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			catalog, err := loadBackupCatalog(iops.config.BackupDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			entry := catalog.find("infrahub_backup_20261016_220000")
+			if marked := entry.VerifiedAt != ""; marked != tt.wantMarked || entry.Verified {
+				t.Errorf("catalog entry after verification = %+v", entry)
+			}
+		})
+	}
+}
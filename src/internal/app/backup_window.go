@@ -0,0 +1,283 @@
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxWindowDuration bounds the duration of a window so that checking it stays
+// cheap; a week covers every weekly schedule.
+const maxWindowDuration = 7 * 24 * time.Hour
+
+// cronField describes the range of one cron field.
+type cronField struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}},
+	{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}},
+}
+
+// cronSchedule is a parsed five-field cron expression. Each field is a bit
+// set of the values it matches.
+type cronSchedule struct {
+	fields  [5]uint64
+	domStar bool
+	dowStar bool
+}
+
+// parseCronSchedule parses "minute hour day-of-month month day-of-week".
+// Fields accept *, lists, ranges, steps and three-letter month and weekday
+// names.
+func parseCronSchedule(expr string) (*cronSchedule, error) {
+	parts := strings.Fields(expr)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("expected 5 fields, got %d", len(parts))
+	}
+	schedule := &cronSchedule{domStar: parts[2] == "*", dowStar: parts[4] == "*"}
+	for i, part := range parts {
+		bits, err := parseCronField(part, cronFields[i])
+		if err != nil {
+			return nil, err
+		}
+		schedule.fields[i] = bits
+	}
+	// Sunday may be written as 0 or 7.
+	if schedule.fields[4]&(1<<7) != 0 {
+		schedule.fields[4] |= 1
+	}
+	return schedule, nil
+}
+
+func parseCronField(text string, field cronField) (uint64, error) {
+	value := func(s string) (int, error) {
+		if n, ok := field.names[strings.ToLower(s)]; ok {
+			return n, nil
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < field.min || n > field.max {
+			return 0, fmt.Errorf("invalid %s %q", field.name, s)
+		}
+		return n, nil
+	}
+
+	var bits uint64
+	for _, item := range strings.Split(text, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid %s step %q", field.name, stepPart)
+			}
+			step = n
+		}
+
+		low, high := field.min, field.max
+		if rangePart != "*" {
+			lowText, highText, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if low, err = value(lowText); err != nil {
+				return 0, err
+			}
+			high = low
+			if isRange {
+				if high, err = value(highText); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				high = field.max
+			}
+			if high < low {
+				return 0, fmt.Errorf("invalid %s range %q", field.name, rangePart)
+			}
+		}
+		for v := low; v <= high; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// matches reports whether t falls in a minute selected by the schedule. As
+// in cron, a restricted day of month and day of week match either.
+func (c *cronSchedule) matches(t time.Time) bool {
+	has := func(field, v int) bool { return c.fields[field]&(1<<v) != 0 }
+	if !has(0, t.Minute()) || !has(1, t.Hour()) || !has(3, int(t.Month())) {
+		return false
+	}
+	dom, dow := has(2, t.Day()), has(4, int(t.Weekday()))
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// timeWindow is a recurring period written as a cron expression with an
+// optional duration and time zone:
+//
+//	"* 0-5 * * *"            every minute from 00:00 to 05:59
+//	"0 22 * * mon-fri 9h"    9 hours starting at 22:00 on weekdays
+//	"TZ=Europe/Paris 0 8 * * 1-5 10h"
+//
+// Without a duration, the minutes matched by the expression form the window.
+// With one, the expression gives the start of each window.
+type timeWindow struct {
+	spec     string
+	schedule *cronSchedule
+	duration time.Duration
+	location *time.Location
+}
+
+func parseTimeWindow(spec string) (*timeWindow, error) {
+	window := &timeWindow{spec: spec, location: time.Local}
+	parts := strings.Fields(spec)
+	if len(parts) > 0 && strings.HasPrefix(parts[0], "TZ=") {
+		location, err := time.LoadLocation(strings.TrimPrefix(parts[0], "TZ="))
+		if err != nil {
+			return nil, fmt.Errorf("invalid window %q: %w", spec, err)
+		}
+		window.location = location
+		parts = parts[1:]
+	}
+	if len(parts) == len(cronFields)+1 {
+		duration, err := time.ParseDuration(parts[len(parts)-1])
+		if err != nil || duration <= 0 || duration > maxWindowDuration {
+			return nil, fmt.Errorf("invalid window %q: duration must be between 1m and %s", spec, maxWindowDuration)
+		}
+		window.duration = duration
+		parts = parts[:len(parts)-1]
+	}
+	schedule, err := parseCronSchedule(strings.Join(parts, " "))
+	if err != nil {
+		return nil, fmt.Errorf("invalid window %q: %w", spec, err)
+	}
+	window.schedule = schedule
+	return window, nil
+}
+
+// contains reports whether t is inside the window.
+func (w *timeWindow) contains(t time.Time) bool {
+	t = t.In(w.location).Truncate(time.Minute)
+	if w.duration == 0 {
+		return w.schedule.matches(t)
+	}
+	for start := t; t.Sub(start) < w.duration; start = start.Add(-time.Minute) {
+		if w.schedule.matches(start) {
+			return true
+		}
+	}
+	return false
+}
+
+// WindowSpecs returns the windows of a --backup-window or --blackout
+// setting. Flags and config file lists give one window per entry; a single
+// string, as read from an environment variable, separates windows with ";".
+func WindowSpecs(value any) []string {
+	var entries []string
+	switch v := value.(type) {
+	case []string:
+		entries = v
+	case []any:
+		for _, item := range v {
+			entries = append(entries, fmt.Sprint(item))
+		}
+	case string:
+		entries = strings.Split(v, ";")
+	}
+	specs := []string{}
+	for _, entry := range entries {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			specs = append(specs, entry)
+		}
+	}
+	return specs
+}
+
+// BackupWindow restricts when backups may run. A backup is allowed inside
+// any of the allowed windows (or at any time when none are set) unless it
+// also falls in a blackout period.
+type BackupWindow struct {
+	allowed   []*timeWindow
+	blackouts []*timeWindow
+}
+
+// NewBackupWindow parses the allowed windows and blackout periods. It returns
+// nil when neither is set.
+func NewBackupWindow(allowed, blackouts []string) (*BackupWindow, error) {
+	if len(allowed) == 0 && len(blackouts) == 0 {
+		return nil, nil
+	}
+	window := &BackupWindow{}
+	for _, spec := range allowed {
+		parsed, err := parseTimeWindow(spec)
+		if err != nil {
+			return nil, fmt.Errorf("--backup-window: %w", err)
+		}
+		window.allowed = append(window.allowed, parsed)
+	}
+	for _, spec := range blackouts {
+		parsed, err := parseTimeWindow(spec)
+		if err != nil {
+			return nil, fmt.Errorf("--blackout: %w", err)
+		}
+		window.blackouts = append(window.blackouts, parsed)
+	}
+	return window, nil
+}
+
+// Check returns an error describing why a backup may not start at t, or nil
+// when it may. A nil window allows every time.
+func (b *BackupWindow) Check(t time.Time) error {
+	if b == nil {
+		return nil
+	}
+	for _, blackout := range b.blackouts {
+		if blackout.contains(t) {
+			return fmt.Errorf("%s is inside the blackout period %q", t.Format(time.RFC3339), blackout.spec)
+		}
+	}
+	if len(b.allowed) == 0 {
+		return nil
+	}
+	specs := make([]string, 0, len(b.allowed))
+	for _, window := range b.allowed {
+		if window.contains(t) {
+			return nil
+		}
+		specs = append(specs, fmt.Sprintf("%q", window.spec))
+	}
+	return fmt.Errorf("%s is outside the backup windows %s", t.Format(time.RFC3339), strings.Join(specs, ", "))
+}
+
+// checkBackupWindow refuses to start a backup outside the configured windows,
+// or only warns with --override-window.
+func (iops *InfrahubOps) checkBackupWindow(now time.Time) error {
+	window, err := NewBackupWindow(iops.config.BackupWindows, iops.config.BlackoutPeriods)
+	if err != nil {
+		return err
+	}
+	if err := window.Check(now); err != nil {
+		if iops.config.OverrideWindow {
+			logrus.Warnf("Backup window overridden: %v", err)
+			return nil
+		}
+		return fmt.Errorf("backup refused: %w (use --override-window to run anyway)", err)
+	}
+	return nil
+}
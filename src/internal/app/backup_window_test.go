@@ -0,0 +1,150 @@
+package app
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+func TestParseTimeWindowErrors(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{spec: "0 22 * *", want: "expected 5 fields"},
+		{spec: "60 22 * * *", want: "invalid minute"},
+		{spec: "0 22 * * funday", want: "invalid day of week"},
+		{spec: "0 5-2 * * *", want: "invalid hour range"},
+		{spec: "*/0 * * * *", want: "invalid minute step"},
+		{spec: "0 22 * * * 8d", want: "duration must be between"},
+		{spec: "0 22 * * * 200h", want: "duration must be between"},
+		{spec: "TZ=Mars/Olympus 0 22 * * *", want: "invalid window"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			_, err := parseTimeWindow(tt.spec)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("parseTimeWindow() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeWindowContains(t *testing.T) {
+	// 2026-10-16 is a Friday.
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 10, day, hour, minute, 30, 0, time.UTC)
+	}
+	tests := []struct {
+		spec string
+		at   time.Time
+		want bool
+	}{
+		{spec: "TZ=UTC * 0-5 * * *", at: at(16, 5, 59), want: true},
+		{spec: "TZ=UTC * 0-5 * * *", at: at(16, 6, 0), want: false},
+		{spec: "TZ=UTC 0 22 * * mon-fri 8h", at: at(17, 5, 59), want: true},
+		{spec: "TZ=UTC 0 22 * * mon-fri 8h", at: at(17, 6, 0), want: false},
+		{spec: "TZ=UTC 0 22 * * mon-fri 8h", at: at(17, 22, 30), want: false},
+		{spec: "TZ=UTC 0 22 * * mon-fri 8h", at: at(16, 21, 59), want: false},
+		{spec: "TZ=UTC 0 0 * * 7 24h", at: at(18, 12, 0), want: true},
+		{spec: "TZ=UTC 0 0 1,15 * sat", at: at(17, 0, 0), want: true},
+		{spec: "TZ=UTC 0 0 1,15 * sat", at: at(15, 0, 0), want: true},
+		{spec: "TZ=UTC 0 0 1,15 * sat", at: at(16, 0, 0), want: false},
+		{spec: "TZ=Europe/Paris * 8-17 * * *", at: at(16, 6, 30), want: true},
+		{spec: "TZ=Europe/Paris * 8-17 * * *", at: at(16, 16, 30), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec+"@"+tt.at.Format(time.RFC3339), func(t *testing.T) {
+			window, err := parseTimeWindow(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := window.contains(tt.at); got != tt.want {
+				t.Errorf("contains() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackupWindowCheck(t *testing.T) {
+	window, err := NewBackupWindow(
+		[]string{"TZ=UTC 0 20 * * * 10h"},
+		[]string{"TZ=UTC * 0-1 1 * *"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		at   time.Time
+		want string
+	}{
+		{name: "inside window", at: time.Date(2026, 10, 16, 23, 0, 0, 0, time.UTC)},
+		{name: "outside window", at: time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC), want: "outside the backup windows"},
+		{name: "blackout wins", at: time.Date(2026, 11, 1, 0, 30, 0, 0, time.UTC), want: "inside the blackout period"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := window.Check(tt.at)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("Check() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Check() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	if none, err := NewBackupWindow(nil, nil); none != nil || err != nil || none.Check(time.Now()) != nil {
+		t.Errorf("NewBackupWindow(nil, nil) = %v, %v", none, err)
+	}
+}
+
+func TestCheckBackupWindowOverride(t *testing.T) {
+	iops := NewInfrahubOps()
+	iops.config.BlackoutPeriods = []string{"* * * * *"}
+	if err := iops.checkBackupWindow(time.Now()); err == nil || !strings.Contains(err.Error(), "--override-window") {
+		t.Errorf("checkBackupWindow() error = %v, want a refusal", err)
+	}
+	iops.config.OverrideWindow = true
+	if err := iops.checkBackupWindow(time.Now()); err != nil {
+		t.Errorf("checkBackupWindow() with override error = %v", err)
+	}
+}
+
+func TestWindowSpecs(t *testing.T) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.StringArray("backup-window", nil, "")
+	if err := flags.Parse([]string{"--backup-window", "0 22 * * 1,2 8h", "--backup-window", "* 0-5 * * *"}); err != nil {
+		t.Fatal(err)
+	}
+	settings := viper.New()
+	if err := settings.BindPFlag("backup-window", flags.Lookup("backup-window")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		value any
+		want  []string
+	}{
+		{name: "flag", value: settings.Get("backup-window"), want: []string{"0 22 * * 1,2 8h", "* 0-5 * * *"}},
+		{name: "environment", value: "0 22 * * 1,2 8h; * 0-5 * * *", want: []string{"0 22 * * 1,2 8h", "* 0-5 * * *"}},
+		{name: "config file", value: []any{"0 22 * * * 8h"}, want: []string{"0 22 * * * 8h"}},
+		{name: "unset", value: nil, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := WindowSpecs(tt.value); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("WindowSpecs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// runWorkPoolScript runs work_pools.py in the task worker and returns the
+// work pools or queues it changed.
+func (iops *InfrahubOps) runWorkPoolScript(action string, names []string) ([]string, error) {
+	scriptContent, err := readEmbeddedScript("work_pools.py")
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve work_pools.py: %w", err)
+	}
+
+	scriptPath := "/tmp/infrahubops_work_pools.py"
+	command := append([]string{"python", "-u", scriptPath, action}, names...)
+	execOpts := iops.buildTaskWorkerExecOpts(&ExecOptions{})
+	output, err := iops.executeScriptWithOpts("task-worker", string(scriptContent), scriptPath, execOpts, command...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to %s work pools: %w", action, err)
+	}
+	return parseWorkPoolOutput(output)
+}
+
+// parseWorkPoolOutput decodes the JSON list printed on the last line of the
+// script output.
+func parseWorkPoolOutput(output string) ([]string, error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	changed := []string{}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(lines[len(lines)-1])), &changed); err != nil {
+		return nil, fmt.Errorf("could not parse json: %w\n%v", err, output)
+	}
+	return changed, nil
+}
+
+// pauseWorkPools pauses the configured Prefect work pools and queues, or every
+// work pool when none are configured, so no new tasks are picked up while
+// running ones drain. It returns the names it paused and a function resuming
+// them.
+func (iops *InfrahubOps) pauseWorkPools() ([]string, func(), error) {
+	paused, err := iops.runWorkPoolScript("pause", iops.config.WorkPools)
+	if err != nil {
+		return nil, func() {}, err
+	}
+	if len(paused) == 0 {
+		logrus.Info("No work pools to pause")
+	} else {
+		logrus.Infof("Paused work pools: %s", strings.Join(paused, ", "))
+	}
+
+	resume := func() {
+		if err := iops.resumeWorkPools(paused); err != nil {
+			logrus.Errorf("Failed to resume work pools %s: %v", strings.Join(paused, ", "), err)
+		}
+	}
+	return paused, resume, nil
+}
+
+// resumeWorkPools resumes the given work pools and queues.
+func (iops *InfrahubOps) resumeWorkPools(names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	resumed, err := iops.runWorkPoolScript("resume", names)
+	if err != nil {
+		return err
+	}
+	if len(resumed) > 0 {
+		logrus.Infof("Resumed work pools: %s", strings.Join(resumed, ", "))
+	}
+	return nil
+}
+
+// resumeRestoredWorkPools resumes the work pools that were paused when the
+// restored backup was taken; the task manager database holds them paused.
+func (iops *InfrahubOps) resumeRestoredWorkPools(metadata *BackupMetadata) {
+	if metadata == nil || len(metadata.PausedWorkPools) == 0 {
+		return
+	}
+	if err := iops.resumeWorkPools(metadata.PausedWorkPools); err != nil {
+		logrus.Warnf("Failed to resume work pools paused by the backup (%s): %v", strings.Join(metadata.PausedWorkPools, ", "), err)
+	}
+}
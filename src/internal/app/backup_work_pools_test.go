@@ -0,0 +1,72 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseWorkPoolOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    []string
+		wantErr bool
+	}{
+		{name: "paused pools", output: `["infrahub-worker", "infrahub-worker/priority"]`, want: []string{"infrahub-worker", "infrahub-worker/priority"}},
+		{name: "log lines before result", output: "12:00:01 | INFO | prefect - connecting\n[]\n", want: []string{}},
+		{name: "traceback", output: "Traceback (most recent call last):", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseWorkPoolOutput(tt.output)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseWorkPoolOutput() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseWorkPoolOutput() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPauseWorkPoolsResumesOnlyPaused(t *testing.T) {
+	fake := newFakeExecutor().
+		on("work_pools.py pause", `["infrahub-worker"]`, nil).
+		on("work_pools.py resume", `["infrahub-worker"]`, nil)
+	iops := newFakeDockerOps(fake)
+	iops.config.WorkPools = []string{"infrahub-worker", "reports"}
+
+	paused, resume, err := iops.pauseWorkPools()
+	if err != nil {
+		t.Fatalf("pauseWorkPools() error = %v", err)
+	}
+	if !reflect.DeepEqual(paused, []string{"infrahub-worker"}) {
+		t.Errorf("paused = %v", paused)
+	}
+	if len(fake.commands("work_pools.py pause infrahub-worker reports")) != 1 {
+		t.Errorf("pause command missing the configured pools: %v", fake.calls)
+	}
+
+	resume()
+	if len(fake.commands("work_pools.py resume infrahub-worker")) != 1 {
+		t.Errorf("resume command missing: %v", fake.calls)
+	}
+	if len(fake.commands("resume infrahub-worker reports")) != 0 {
+		t.Errorf("resumed a pool that was not paused by the backup: %v", fake.calls)
+	}
+}
+
+func TestResumeRestoredWorkPools(t *testing.T) {
+	fake := newFakeExecutor()
+	iops := newFakeDockerOps(fake)
+
+	iops.resumeRestoredWorkPools(&BackupMetadata{})
+	if len(fake.commands("work_pools.py")) != 0 {
+		t.Errorf("ran the work pool script without paused pools: %v", fake.calls)
+	}
+
+	iops.resumeRestoredWorkPools(&BackupMetadata{PausedWorkPools: []string{"infrahub-worker"}})
+	if len(fake.commands("work_pools.py resume infrahub-worker")) != 1 {
+		t.Errorf("restored pools not resumed: %v", fake.calls)
+	}
+}
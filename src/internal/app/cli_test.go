@@ -0,0 +1,54 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// newTestRoot builds a root command with the shared flags and a no-op
+// subcommand, as the binaries do.
+func newTestRoot(iops *InfrahubOps) *cobra.Command {
+	root := &cobra.Command{Use: "test", SilenceUsage: true, SilenceErrors: true}
+	ConfigureRootCommand(root, iops)
+	root.AddCommand(&cobra.Command{Use: "noop", RunE: func(cmd *cobra.Command, args []string) error { return nil }})
+	return root
+}
+
+func TestConfigureRootCommandIsInstanceScoped(t *testing.T) {
+	t.Setenv("INFRAHUB_S3_BUCKET", "from-env")
+
+	first := NewInfrahubOps()
+	second := NewInfrahubOps()
+	firstRoot := newTestRoot(first)
+	secondRoot := newTestRoot(second)
+
+	firstRoot.SetArgs([]string{"--project", "alpha", "--nice", "5", "noop"})
+	if err := firstRoot.Execute(); err != nil {
+		t.Fatalf("first Execute() error = %v", err)
+	}
+	secondRoot.SetArgs([]string{"--k8s-namespace", "beta", "noop"})
+	if err := secondRoot.Execute(); err != nil {
+		t.Fatalf("second Execute() error = %v", err)
+	}
+
+	if got := first.Config(); got.DockerComposeProject != "alpha" || got.K8sNamespace != "" || got.Nice != 5 {
+		t.Errorf("first config = project %q namespace %q nice %d", got.DockerComposeProject, got.K8sNamespace, got.Nice)
+	}
+	if got := second.Config(); got.DockerComposeProject != "" || got.K8sNamespace != "beta" || got.Nice != 0 {
+		t.Errorf("second config = project %q namespace %q nice %d", got.DockerComposeProject, got.K8sNamespace, got.Nice)
+	}
+	for _, iops := range []*InfrahubOps{first, second} {
+		if iops.Config().S3.Bucket != "from-env" {
+			t.Errorf("s3 bucket = %q, want from-env", iops.Config().S3.Bucket)
+		}
+	}
+}
+
+func TestConfigureRootCommandRejectsUnreadableConfigFile(t *testing.T) {
+	root := newTestRoot(NewInfrahubOps())
+	root.SetArgs([]string{"--config", t.TempDir() + "/missing.yaml", "noop"})
+	if err := root.Execute(); err == nil {
+		t.Fatal("Execute() error = nil, want config file error")
+	}
+}
@@ -2,6 +2,7 @@ package app
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os/exec"
@@ -11,11 +12,24 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-// CommandExecutor handles command execution
-type CommandExecutor struct{}
+// CommandExecutor runs the host commands (docker, kubectl, ...) that drive a
+// deployment. Backends and InfrahubOps only talk to the host through it, so
+// tests can substitute a fake that records the commands.
+type CommandExecutor interface {
+	runCommand(name string, args ...string) (string, error)
+	runCommandQuiet(name string, args ...string) error
+	runCommandPipe(name string, args ...string) (io.ReadCloser, func() error, error)
+	runCommandPipeContext(ctx context.Context, name string, args ...string) (io.ReadCloser, func() error, error)
+	runCommandWritePipe(stdin io.Reader, name string, args ...string) (func() error, error)
+	runCommandWithStream(name string, args ...string) (string, error)
+}
+
+// systemExecutor runs commands as local processes.
+type systemExecutor struct{}
 
-func NewCommandExecutor() *CommandExecutor {
-	return &CommandExecutor{}
+// NewCommandExecutor returns the executor that runs commands on this host.
+func NewCommandExecutor() CommandExecutor {
+	return &systemExecutor{}
 }
 
 type lineLogger struct {
@@ -54,21 +68,27 @@ func (l *lineLogger) Flush() {
 	l.flush()
 }
 
-func (ce *CommandExecutor) runCommand(name string, args ...string) (string, error) {
+func (ce *systemExecutor) runCommand(name string, args ...string) (string, error) {
 	cmd := exec.Command(name, args...)
 	output, err := cmd.CombinedOutput()
 	return strings.TrimSpace(string(output)), err
 }
 
-func (ce *CommandExecutor) runCommandQuiet(name string, args ...string) error {
+func (ce *systemExecutor) runCommandQuiet(name string, args ...string) error {
 	cmd := exec.Command(name, args...)
 	return cmd.Run()
 }
 
 // runCommandPipe starts a command and returns the stdout pipe, a wait function, and any startup error.
 // The caller must read from stdout and then call wait() to get the exit status.
-func (ce *CommandExecutor) runCommandPipe(name string, args ...string) (io.ReadCloser, func() error, error) {
-	cmd := exec.Command(name, args...)
+func (ce *systemExecutor) runCommandPipe(name string, args ...string) (io.ReadCloser, func() error, error) {
+	return ce.runCommandPipeContext(context.Background(), name, args...)
+}
+
+// runCommandPipeContext is runCommandPipe with a context that kills the
+// command when cancelled, for long-running streams such as followed logs.
+func (ce *systemExecutor) runCommandPipeContext(ctx context.Context, name string, args ...string) (io.ReadCloser, func() error, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
 	logrus.Debugf("exec pipe: %s %s", name, strings.Join(args, " "))
 
 	stdout, err := cmd.StdoutPipe()
@@ -100,7 +120,7 @@ func (ce *CommandExecutor) runCommandPipe(name string, args ...string) (io.ReadC
 
 // runCommandWritePipe starts a command with stdin connected to the provided reader.
 // The caller must call wait() after the reader is fully consumed to get the exit status.
-func (ce *CommandExecutor) runCommandWritePipe(stdin io.Reader, name string, args ...string) (func() error, error) {
+func (ce *systemExecutor) runCommandWritePipe(stdin io.Reader, name string, args ...string) (func() error, error) {
 	cmd := exec.Command(name, args...)
 	logrus.Debugf("exec write-pipe: %s %s", name, strings.Join(args, " "))
 
@@ -128,7 +148,7 @@ func (ce *CommandExecutor) runCommandWritePipe(stdin io.Reader, name string, arg
 	return wait, nil
 }
 
-func (ce *CommandExecutor) runCommandWithStream(name string, args ...string) (string, error) {
+func (ce *systemExecutor) runCommandWithStream(name string, args ...string) (string, error) {
 	cmd := exec.Command(name, args...)
 
 	stdout, err := cmd.StdoutPipe()
@@ -0,0 +1,117 @@
+package app
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeResponse is the canned result for commands containing match.
+type fakeResponse struct {
+	match  string
+	output string
+	err    error
+}
+
+// fakeExecutor records every command line it is asked to run and answers
+// with the first registered response whose match is a substring of it.
+// Unmatched commands succeed with empty output.
+type fakeExecutor struct {
+	mu        sync.Mutex
+	calls     []string
+	responses []fakeResponse
+}
+
+func newFakeExecutor() *fakeExecutor {
+	return &fakeExecutor{}
+}
+
+func (f *fakeExecutor) on(match, output string, err error) *fakeExecutor {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses = append(f.responses, fakeResponse{match: match, output: output, err: err})
+	return f
+}
+
+func (f *fakeExecutor) record(name string, args []string) (string, error) {
+	line := strings.Join(append([]string{name}, args...), " ")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, line)
+	for _, response := range f.responses {
+		if strings.Contains(line, response.match) {
+			return response.output, response.err
+		}
+	}
+	return "", nil
+}
+
+// commands returns the recorded command lines containing match.
+func (f *fakeExecutor) commands(match string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var matched []string
+	for _, call := range f.calls {
+		if strings.Contains(call, match) {
+			matched = append(matched, call)
+		}
+	}
+	return matched
+}
+
+func (f *fakeExecutor) runCommand(name string, args ...string) (string, error) {
+	return f.record(name, args)
+}
+
+func (f *fakeExecutor) runCommandQuiet(name string, args ...string) error {
+	_, err := f.record(name, args)
+	return err
+}
+
+func (f *fakeExecutor) runCommandPipe(name string, args ...string) (io.ReadCloser, func() error, error) {
+	return f.runCommandPipeContext(context.Background(), name, args...)
+}
+
+func (f *fakeExecutor) runCommandPipeContext(_ context.Context, name string, args ...string) (io.ReadCloser, func() error, error) {
+	output, err := f.record(name, args)
+	return io.NopCloser(strings.NewReader(output)), func() error { return err }, nil
+}
+
+func (f *fakeExecutor) runCommandWritePipe(stdin io.Reader, name string, args ...string) (func() error, error) {
+	_, err := f.record(name, args)
+	_, _ = io.Copy(io.Discard, stdin)
+	return func() error { return err }, nil
+}
+
+func (f *fakeExecutor) runCommandWithStream(name string, args ...string) (string, error) {
+	return f.record(name, args)
+}
+
+// newFakeDockerOps returns an InfrahubOps bound to a docker compose project
+// named "test" whose commands all go to executor.
+func newFakeDockerOps(executor CommandExecutor) *InfrahubOps {
+	iops := NewInfrahubOpsWithExecutor(executor)
+	iops.backend = &DockerBackend{config: iops.config, executor: executor, project: "test"}
+	return iops
+}
+
+func TestFakeExecutorRecordsAndMatches(t *testing.T) {
+	fake := newFakeExecutor().
+		on("compose ls", "NAME STATUS\ninfrahub running(3)\nother running(1)\n", nil).
+		on("-p infrahub ps", "infrahub-infrahub-server-1 running", nil)
+
+	projects, err := ListDockerProjects(fake)
+	if err != nil {
+		t.Fatalf("ListDockerProjects() error = %v", err)
+	}
+	if len(projects) != 1 || projects[0] != "infrahub" {
+		t.Fatalf("ListDockerProjects() = %v, want [infrahub]", projects)
+	}
+	want := []string{"docker compose ls", "docker compose -p infrahub ps -a", "docker compose -p other ps -a"}
+	if got := fake.commands("docker"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("recorded commands = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,492 @@
+package app
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// secretMask replaces secret values in printed configuration.
+const secretMask = "********"
+
+// ConfigSetting is one resolved configuration value and where it came from:
+// flag, env, config, container or default.
+type ConfigSetting struct {
+	Key    string
+	Value  string
+	Source string
+}
+
+// ValidateBackendFlags checks the --backend value and the flags that cannot be
+// combined with it.
+func (iops *InfrahubOps) ValidateBackendFlags(s3Upload bool) error {
+	cfg := iops.config
+
+	switch cfg.Backend {
+	case BackendTarball, BackendPlakar:
+		// valid
+	default:
+		return fmt.Errorf("unknown backend: %s, expected 'tarball' or 'plakar'", cfg.Backend)
+	}
+
+	if cfg.Backend == BackendPlakar {
+		// --repo is required for plakar backend
+		if cfg.Plakar.RepoPath == "" {
+			return fmt.Errorf("--repo is required when using plakar backend")
+		}
+
+		// S3 flags conflict with plakar backend
+		if s3Upload || cfg.S3.Bucket != "" || cfg.S3.Prefix != "" ||
+			cfg.S3.Endpoint != "" || (cfg.S3.Region != "" && cfg.S3.Region != "us-east-1") {
+			return fmt.Errorf("--s3-upload and related S3 flags cannot be used with plakar backend; use --repo s3://... instead")
+		}
+	}
+
+	return nil
+}
+
+// ValidateConfiguration checks the effective configuration for invalid values
+// and conflicting options without touching the deployment. It returns every
+// problem found rather than stopping at the first.
+func (iops *InfrahubOps) ValidateConfiguration() []error {
+	settings := iops.settings
+	cfg := *iops.config
+	if settings.IsSet("artifacts-include") || settings.IsSet("artifacts-exclude") {
+		cfg.ArtifactsInclude = settings.GetStringSlice("artifacts-include")
+		cfg.ArtifactsExclude = settings.GetStringSlice("artifacts-exclude")
+	}
+	if settings.IsSet("impact-webhook") {
+		cfg.ImpactWebhook = settings.GetString("impact-webhook")
+	}
+	if settings.IsSet("backup-window") || settings.IsSet("blackout") {
+		cfg.BackupWindows = WindowSpecs(settings.Get("backup-window"))
+		cfg.BlackoutPeriods = WindowSpecs(settings.Get("blackout"))
+	}
+	if settings.IsSet("pause-work-pools") || settings.IsSet("work-pools") {
+		cfg.PauseWorkPools = settings.GetBool("pause-work-pools")
+		cfg.WorkPools = settings.GetStringSlice("work-pools")
+	}
+	if settings.IsSet("pg-exclude-table-data") {
+		cfg.PgExcludeTableData = settings.GetStringSlice("pg-exclude-table-data")
+	}
+	if settings.IsSet("split-size") {
+		cfg.SplitSize = settings.GetString("split-size")
+	}
+	if settings.IsSet("stream") {
+		cfg.StreamBackup = settings.GetBool("stream")
+	}
+	if settings.IsSet("record-to") {
+		cfg.RecordTo = settings.GetString("record-to")
+		cfg.RecordSignKey = settings.GetString("record-sign-key")
+		cfg.RecordRetentionDays = settings.GetInt("record-retention-days")
+	}
+	if settings.IsSet("register-kind") {
+		cfg.RegisterKind = settings.GetString("register-kind")
+	}
+	if settings.IsSet("label-from-git") {
+		cfg.LabelFromGit = settings.GetBool("label-from-git")
+	}
+	if settings.IsSet("retention-class") {
+		cfg.RetentionClass = settings.GetString("retention-class")
+	}
+	if settings.IsSet("checksum-algorithm") || settings.IsSet("fast-checksum") {
+		cfg.ChecksumAlgorithm = settings.GetString("checksum-algorithm")
+		cfg.FastChecksum = settings.GetBool("fast-checksum")
+	}
+	if settings.IsSet("health-watch") || settings.IsSet("health-watch-retries") {
+		cfg.HealthWatch = settings.GetDuration("health-watch")
+		cfg.HealthWatchRetries = settings.GetInt("health-watch-retries")
+	}
+	scoped := &InfrahubOps{config: &cfg}
+	problems := scoped.validateConfiguration(settings.GetString("log-format"), settings.GetBool("s3-upload"))
+	problems = append(problems, iops.validateConfiguredTargets(&cfg)...)
+	if _, err := iops.APITokens(); err != nil {
+		problems = append(problems, err)
+	}
+	return problems
+}
+
+// validateConfiguredTargets checks every target of the --config file with its
+// settings applied over cfg.
+func (iops *InfrahubOps) validateConfiguredTargets(cfg *Configuration) []error {
+	var problems []error
+	if concurrency := iops.settings.GetInt("target-concurrency"); iops.settings.IsSet("target-concurrency") && concurrency < 1 {
+		problems = append(problems, fmt.Errorf("invalid --target-concurrency %d: must be at least 1", concurrency))
+	}
+	targets, err := iops.ConfiguredTargets()
+	if err != nil {
+		return append(problems, err)
+	}
+	shared := &InfrahubOps{config: cfg, executor: iops.executor}
+	for _, target := range targets {
+		scoped, err := shared.forConfiguredTarget(target)
+		if err != nil {
+			problems = append(problems, err)
+			continue
+		}
+		for _, problem := range scoped.validateConfiguration(iops.settings.GetString("log-format"), iops.settings.GetBool("s3-upload")) {
+			problems = append(problems, fmt.Errorf("target %s: %w", target.Name, problem))
+		}
+	}
+	return problems
+}
+
+func (iops *InfrahubOps) validateConfiguration(logFormat string, s3Upload bool) []error {
+	cfg := iops.config
+	var problems []error
+
+	if cfg.DockerComposeProject != "" && cfg.K8sNamespace != "" {
+		problems = append(problems, fmt.Errorf("--project and --k8s-namespace are mutually exclusive"))
+	}
+	if cfg.K8sReleaseName != "" && cfg.DockerComposeProject != "" {
+		problems = append(problems, fmt.Errorf("--release-name only applies to Kubernetes and cannot be combined with --project"))
+	}
+	if cfg.NoDetect && cfg.DockerComposeProject == "" && cfg.K8sNamespace == "" {
+		problems = append(problems, fmt.Errorf("--no-detect requires --project or --k8s-namespace"))
+	}
+	if cfg.K8sReadyTimeout < 0 {
+		problems = append(problems, fmt.Errorf("invalid --k8s-ready-timeout %s: must not be negative", cfg.K8sReadyTimeout))
+	}
+	if cfg.DetectCacheTTL < 0 {
+		problems = append(problems, fmt.Errorf("invalid --detect-cache-ttl %s: must not be negative", cfg.DetectCacheTTL))
+	}
+	if cfg.CredentialCache != "" {
+		if _, err := parseCredentialCache(cfg.CredentialCache); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	if cfg.PollInterval < 0 || cfg.PollMaxInterval < 0 {
+		problems = append(problems, fmt.Errorf("invalid --poll-interval or --poll-max-interval: must not be negative"))
+	}
+	for _, location := range []struct{ flag, value string }{
+		{"neo4j-pid-file", cfg.Neo4jPIDFile},
+		{"neo4j-data-dir", cfg.Neo4jDataDir},
+		{"neo4j-metadata-script", cfg.Neo4jMetadataScript},
+	} {
+		if location.value != "" && !path.IsAbs(location.value) {
+			problems = append(problems, fmt.Errorf("invalid --%s %q: must be an absolute path in the database container", location.flag, location.value))
+		}
+	}
+	if cfg.RemoteCleanupAge < 0 {
+		problems = append(problems, fmt.Errorf("invalid --remote-cleanup-age %s: must not be negative", cfg.RemoteCleanupAge))
+	}
+	if cfg.ThrottleCPU < 0 {
+		problems = append(problems, fmt.Errorf("invalid --throttle-cpu %g: must not be negative", cfg.ThrottleCPU))
+	}
+	if _, ok := glacierTiers[strings.ToLower(cfg.GlacierTier)]; !ok {
+		problems = append(problems, fmt.Errorf("invalid --glacier-tier %q: expected expedited, standard or bulk", cfg.GlacierTier))
+	}
+	if cfg.GlacierDays < 1 {
+		problems = append(problems, fmt.Errorf("invalid --glacier-days %d: must be at least 1", cfg.GlacierDays))
+	}
+	if cfg.GlacierTimeout < 0 {
+		problems = append(problems, fmt.Errorf("invalid --glacier-timeout %s: must not be negative", cfg.GlacierTimeout))
+	}
+	if cfg.ThrottleMaxPause < 0 {
+		problems = append(problems, fmt.Errorf("invalid --throttle-max-pause %s: must not be negative", cfg.ThrottleMaxPause))
+	}
+	if cfg.RPO < 0 {
+		problems = append(problems, fmt.Errorf("invalid --rpo %s: must not be negative", cfg.RPO))
+	}
+	if cfg.RTO < 0 {
+		problems = append(problems, fmt.Errorf("invalid --rto %s: must not be negative", cfg.RTO))
+	}
+	if cfg.EnforceSLO && cfg.RPO <= 0 && cfg.RTO <= 0 {
+		problems = append(problems, fmt.Errorf("--enforce-slo requires --rpo or --rto"))
+	}
+	if cfg.CredentialCacheTTL < 0 {
+		problems = append(problems, fmt.Errorf("invalid --credential-cache-ttl %s: must not be negative", cfg.CredentialCacheTTL))
+	}
+	if err := iops.ValidateBackendFlags(s3Upload); err != nil {
+		problems = append(problems, err)
+	}
+	if s3Upload && cfg.Backend != BackendPlakar && cfg.S3.Bucket == "" {
+		problems = append(problems, fmt.Errorf("--s3-upload requires --s3-bucket"))
+	}
+	if cfg.S3.Endpoint != "" {
+		if u, err := url.Parse(cfg.S3.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, fmt.Errorf("invalid --s3-endpoint %q: expected an http:// or https:// URL", cfg.S3.Endpoint))
+		}
+	}
+	if _, err := NewBackupWindow(cfg.BackupWindows, cfg.BlackoutPeriods); err != nil {
+		problems = append(problems, err)
+	}
+	if len(cfg.WorkPools) > 0 && !cfg.PauseWorkPools {
+		problems = append(problems, fmt.Errorf("--work-pools requires --pause-work-pools"))
+	}
+	for _, name := range cfg.WorkPools {
+		if pool, queue, found := strings.Cut(name, "/"); pool == "" || (found && queue == "") {
+			problems = append(problems, fmt.Errorf("invalid --work-pools entry %q: expected <pool> or <pool>/<queue>", name))
+		}
+	}
+	if cfg.ImpactWebhook != "" {
+		if u, err := url.Parse(cfg.ImpactWebhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, fmt.Errorf("invalid --impact-webhook %q: expected an http:// or https:// URL", cfg.ImpactWebhook))
+		}
+	}
+	if cfg.TelemetryEndpoint != "" {
+		if u, err := url.Parse(cfg.TelemetryEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, fmt.Errorf("invalid --telemetry-endpoint %q: expected an http:// or https:// URL", cfg.TelemetryEndpoint))
+		}
+	}
+	for flag, value := range map[string]string{"notify-webhook": cfg.NotifyWebhook, "notify-slack-webhook": cfg.NotifySlackWebhook} {
+		if value == "" {
+			continue
+		}
+		if u, err := url.Parse(value); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, fmt.Errorf("invalid --%s %q: expected an http:// or https:// URL", flag, value))
+		}
+	}
+	if cfg.NotifyWebhookTemplate != "" && cfg.NotifyWebhook == "" {
+		problems = append(problems, fmt.Errorf("--notify-webhook-template requires --notify-webhook"))
+	}
+	if cfg.NotifySlackTemplate != "" && cfg.NotifySlackWebhook == "" {
+		problems = append(problems, fmt.Errorf("--notify-slack-template requires --notify-slack-webhook"))
+	}
+	problems = append(problems, cfg.checkEmailNotifications()...)
+	problems = append(problems, cfg.HTTP.checkHTTP()...)
+	problems = append(problems, cfg.S3.Credentials.checkCredentials()...)
+	problems = append(problems, cfg.checkS3Lifecycle()...)
+	problems = append(problems, cfg.checkChecksumAlgorithm()...)
+	problems = append(problems, cfg.checkLock()...)
+	if err := iops.checkNotificationTemplates(); err != nil {
+		problems = append(problems, err)
+	}
+	if _, err := priorityPrefix(cfg.Nice, cfg.IONice); err != nil {
+		problems = append(problems, err)
+	}
+	for _, table := range cfg.PgExcludeTableData {
+		if strings.TrimSpace(table) == "" || strings.HasPrefix(table, "-") {
+			problems = append(problems, fmt.Errorf("invalid --pg-exclude-table-data entry %q: expected a table name or pattern", table))
+		}
+	}
+	if cfg.SplitSize != "" {
+		if _, err := parseByteSize(cfg.SplitSize); err != nil {
+			problems = append(problems, fmt.Errorf("invalid --split-size: %w", err))
+		} else if cfg.Backend == BackendPlakar {
+			problems = append(problems, fmt.Errorf("--split-size is not supported with the plakar backend"))
+		}
+	}
+	if cfg.HealthWatch < 0 {
+		problems = append(problems, fmt.Errorf("invalid --health-watch %s: must not be negative", cfg.HealthWatch))
+	}
+	if cfg.HealthWatchRetries < 0 {
+		problems = append(problems, fmt.Errorf("invalid --health-watch-retries %d: must not be negative", cfg.HealthWatchRetries))
+	}
+	if cfg.PgJobs < 0 {
+		problems = append(problems, fmt.Errorf("invalid --pg-jobs %d: must not be negative", cfg.PgJobs))
+	}
+	if cfg.PgJobs > 0 && cfg.Backend == BackendPlakar {
+		problems = append(problems, fmt.Errorf("--pg-jobs is not supported with the plakar backend, which streams the dump"))
+	}
+	if cfg.StreamBackup && cfg.PgJobs > 0 {
+		problems = append(problems, fmt.Errorf("--stream cannot be combined with --pg-jobs; the directory format cannot be streamed"))
+	}
+	if cfg.StreamBackup && cfg.Backend == BackendPlakar {
+		problems = append(problems, fmt.Errorf("--stream does not apply to the plakar backend, which always streams the dumps"))
+	}
+	if _, err := NewArtifactFilter(cfg.ArtifactsInclude, cfg.ArtifactsExclude); err != nil {
+		problems = append(problems, err)
+	}
+	if err := iops.checkBackupRecord(); err != nil {
+		problems = append(problems, err)
+	}
+	if err := iops.checkBackupRegistration(); err != nil {
+		problems = append(problems, err)
+	}
+	switch logFormat {
+	case "", "text", "json":
+	default:
+		problems = append(problems, fmt.Errorf("invalid --log-format %q: expected text or json", logFormat))
+	}
+	problems = append(problems, cfg.Kubernetes.validate()...)
+	if _, err := cfg.resolveCredentials(); err != nil {
+		problems = append(problems, err)
+	}
+	if info, err := os.Stat(cfg.BackupDir); err == nil && !info.IsDir() {
+		problems = append(problems, fmt.Errorf("--backup-dir %s is not a directory", cfg.BackupDir))
+	}
+
+	return problems
+}
+
+// EffectiveConfiguration lists the resolved global settings with their
+// source. Secrets are masked. flags is the flag set of the running command, used
+// to tell explicitly passed flags from defaults.
+func (iops *InfrahubOps) EffectiveConfiguration(flags *pflag.FlagSet) []ConfigSetting {
+	cfg := iops.config
+	setting := func(key, value string) ConfigSetting {
+		return ConfigSetting{Key: key, Value: value, Source: iops.configSource(flags, key)}
+	}
+
+	settings := []ConfigSetting{
+		setting("config", iops.settings.ConfigFileUsed()),
+		setting("no-pin", strconv.FormatBool(iops.settings.GetBool("no-pin"))),
+		setting("project", cfg.DockerComposeProject),
+		setting("k8s-namespace", cfg.K8sNamespace),
+		setting("release-name", cfg.K8sReleaseName),
+		setting("instance-label", cfg.K8sInstanceLabel),
+		setting("k8s-ready-timeout", cfg.K8sReadyTimeout.String()),
+		setting("kubernetes", cfg.Kubernetes.String()),
+		setting("backup-dir", cfg.BackupDir),
+		setting("no-detect", strconv.FormatBool(cfg.NoDetect)),
+		setting("detect-cache-ttl", cfg.DetectCacheTTL.String()),
+		setting("credential-cache", cfg.CredentialCache),
+		setting("credential-cache-ttl", cfg.CredentialCacheTTL.String()),
+		setting("nice", strconv.Itoa(cfg.Nice)),
+		setting("ionice", cfg.IONice),
+		setting("pg-jobs", strconv.Itoa(cfg.PgJobs)),
+		setting("poll-interval", cfg.PollInterval.String()),
+		setting("poll-max-interval", cfg.PollMaxInterval.String()),
+		setting("remote-cleanup-age", cfg.RemoteCleanupAge.String()),
+		setting("stop-strategy", formatStopStrategies(cfg.StopStrategies)),
+		setting("copy-chunk-size", strconv.FormatInt(cfg.CopyChunkSize, 10)),
+		setting("copy-parallelism", strconv.Itoa(cfg.CopyParallelism)),
+		setting("throttle-cpu", strconv.FormatFloat(cfg.ThrottleCPU, 'g', -1, 64)),
+		setting("throttle-io", strconv.FormatInt(cfg.ThrottleIO, 10)),
+		setting("throttle-max-pause", cfg.ThrottleMaxPause.String()),
+		setting("neo4j-pid-file", cfg.Neo4jPIDFile),
+		setting("neo4j-data-dir", cfg.Neo4jDataDir),
+		setting("neo4j-metadata-script", cfg.Neo4jMetadataScript),
+		setting("neo4j-admin-heap", cfg.Neo4jAdminHeap),
+		setting("neo4j-admin-pagecache", cfg.Neo4jAdminPageCache),
+		setting("log-format", iops.settings.GetString("log-format")),
+		setting("events", iops.settings.GetString("events")),
+		setting("events-fd", strconv.Itoa(iops.settings.GetInt("events-fd"))),
+		setting("warnings-as-errors", strconv.FormatBool(cfg.WarningsAsErrors)),
+		setting("rpo", cfg.RPO.String()),
+		setting("rto", cfg.RTO.String()),
+		setting("enforce-slo", strconv.FormatBool(cfg.EnforceSLO)),
+		setting("telemetry-endpoint", cfg.TelemetryEndpoint),
+		setting("notify-webhook", maskConnectionURL(cfg.NotifyWebhook)),
+		setting("notify-webhook-template", cfg.NotifyWebhookTemplate),
+		setting("notify-slack-webhook", maskSecret(cfg.NotifySlackWebhook)),
+		setting("notify-slack-template", cfg.NotifySlackTemplate),
+		setting("notify-email-to", strings.Join(cfg.NotifyEmailTo, ",")),
+		setting("notify-email-from", cfg.NotifyEmailFrom),
+		setting("notify-email-template", cfg.NotifyEmailTemplate),
+		setting("smtp-host", cfg.SMTP.Host),
+		setting("smtp-username", cfg.SMTP.Username),
+		setting("smtp-password", maskSecret(cfg.SMTP.Password)),
+		setting("smtp-password-file", cfg.SMTP.PasswordFile),
+		setting("smtp-tls", cfg.SMTP.TLS),
+		setting("http-proxy", maskConnectionURL(cfg.HTTP.HTTPProxy)),
+		setting("https-proxy", maskConnectionURL(cfg.HTTP.HTTPSProxy)),
+		setting("no-proxy", cfg.HTTP.NoProxy),
+		setting("ca-bundle", cfg.HTTP.CABundle),
+		setting("insecure-skip-verify", strconv.FormatBool(cfg.HTTP.InsecureSkipVerify)),
+		setting("backend", string(cfg.Backend)),
+		setting("repo", cfg.Plakar.RepoPath),
+		setting("s3-bucket", cfg.S3.Bucket),
+		setting("s3-prefix", cfg.S3.Prefix),
+		setting("s3-endpoint", cfg.S3.Endpoint),
+		setting("s3-region", cfg.S3.Region),
+		setting("s3-storage-class", strings.Join(cfg.S3StorageClasses, ",")),
+		setting("s3-tag", strings.Join(cfg.S3Tags, ",")),
+		setting("glacier-tier", cfg.GlacierTier),
+		setting("glacier-days", strconv.Itoa(cfg.GlacierDays)),
+		setting("glacier-timeout", cfg.GlacierTimeout.String()),
+		setting("aws-profile", cfg.S3.Credentials.Profile),
+		setting("aws-role-arn", cfg.S3.Credentials.RoleARN),
+		setting("aws-external-id", cfg.S3.Credentials.ExternalID),
+		setting("aws-role-session-name", cfg.S3.Credentials.RoleSessionName),
+		setting("aws-web-identity-token-file", cfg.S3.Credentials.WebIdentityTokenFile),
+		setting("aws-sts-endpoint", cfg.S3.Credentials.STSEndpoint),
+		setting("lock", cfg.Lock.URL),
+		setting("lock-key", cfg.Lock.Key),
+		setting("lock-ttl", cfg.Lock.TTL.String()),
+		setting("lock-wait", cfg.Lock.Wait.String()),
+	}
+
+	// Credentials: explicit values, then their files, then the legacy
+	// INFRAHUB_DB_* variables; anything else is discovered from the containers.
+	legacyEnv := map[string]string{
+		"neo4j-user":     "INFRAHUB_DB_USERNAME",
+		"neo4j-password": "INFRAHUB_DB_PASSWORD",
+		"neo4j-database": "INFRAHUB_DB_DATABASE",
+	}
+	files := cfg.CredentialFiles.fields()
+	for i, field := range cfg.Credentials.fields() {
+		entry := ConfigSetting{Key: field.flag, Value: *field.value, Source: iops.configSource(flags, field.flag)}
+		switch {
+		case *field.value != "":
+		case *files[i].value != "":
+			entry.Value = "file " + *files[i].value
+			entry.Source = iops.configSource(flags, field.flag+"-file")
+		case os.Getenv(legacyEnv[field.flag]) != "":
+			entry.Value = os.Getenv(legacyEnv[field.flag])
+			entry.Source = "env"
+		default:
+			entry.Source = "container"
+		}
+		if isSecretKey(entry.Key) && !strings.HasPrefix(entry.Value, "file ") {
+			entry.Value = maskSecret(entry.Value)
+		}
+		settings = append(settings, entry)
+	}
+
+	for _, env := range []struct{ key, name string }{
+		{"aws-access-key-id", "AWS_ACCESS_KEY_ID"},
+		{"aws-secret-access-key", "AWS_SECRET_ACCESS_KEY"},
+	} {
+		value, source := os.Getenv(env.name), "env"
+		if value == "" {
+			source = "default"
+		}
+		if isSecretKey(env.key) {
+			value = maskSecret(value)
+		}
+		settings = append(settings, ConfigSetting{Key: env.key, Value: value, Source: source})
+	}
+
+	for _, name := range prefectConnectionEnvVars {
+		if value := os.Getenv(name); value != "" {
+			settings = append(settings, ConfigSetting{Key: "task-manager-db-url", Value: maskConnectionURL(value), Source: "env"})
+			break
+		}
+	}
+
+	return settings
+}
+
+// configSource reports where the settings took key from.
+func (iops *InfrahubOps) configSource(flags *pflag.FlagSet, key string) string {
+	if flags != nil {
+		if flag := flags.Lookup(key); flag != nil && flag.Changed {
+			return "flag"
+		}
+	}
+	if _, ok := os.LookupEnv("INFRAHUB_" + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))); ok {
+		return "env"
+	}
+	if iops.settings.InConfig(key) {
+		return "config"
+	}
+	return "default"
+}
+
+func isSecretKey(key string) bool {
+	return strings.Contains(key, "password") || strings.Contains(key, "secret")
+}
+
+// maskSecret hides a secret value while still showing whether it is set.
+func maskSecret(value string) string {
+	if value == "" {
+		return ""
+	}
+	return secretMask
+}
+
+// maskConnectionURL hides the password of a database connection URL.
+func maskConnectionURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil {
+		return secretMask
+	}
+	return u.Redacted()
+}
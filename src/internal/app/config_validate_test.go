@@ -0,0 +1,146 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateConfiguration(t *testing.T) {
+	notADir := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(notADir, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		modify    func(cfg *Configuration)
+		logFormat string
+		s3Upload  bool
+		want      []string
+	}{
+		{
+			name: "defaults are valid",
+		},
+		{
+			name: "project and namespace together",
+			modify: func(cfg *Configuration) {
+				cfg.DockerComposeProject = "infrahub"
+				cfg.K8sNamespace = "infrahub"
+			},
+			want: []string{"--project and --k8s-namespace are mutually exclusive"},
+		},
+		{
+			name: "release name with a compose project",
+			modify: func(cfg *Configuration) {
+				cfg.DockerComposeProject = "infrahub"
+				cfg.K8sReleaseName = "prod"
+			},
+			want: []string{"--release-name only applies to Kubernetes"},
+		},
+		{
+			name:   "no-detect without a target",
+			modify: func(cfg *Configuration) { cfg.NoDetect = true },
+			want:   []string{"--no-detect requires --project or --k8s-namespace"},
+		},
+		{
+			name: "plakar without repo",
+			modify: func(cfg *Configuration) {
+				cfg.Backend = BackendPlakar
+			},
+			want: []string{"--repo is required"},
+		},
+		{
+			name:     "s3 upload without bucket",
+			s3Upload: true,
+			want:     []string{"--s3-upload requires --s3-bucket"},
+		},
+		{
+			name:   "s3 endpoint without scheme",
+			modify: func(cfg *Configuration) { cfg.S3.Endpoint = "minio:9000" },
+			want:   []string{"invalid --s3-endpoint"},
+		},
+		{
+			name:   "impact webhook without scheme",
+			modify: func(cfg *Configuration) { cfg.ImpactWebhook = "hooks.example.com/backup" },
+			want:   []string{"invalid --impact-webhook"},
+		},
+		{
+			name:   "work pools without pausing",
+			modify: func(cfg *Configuration) { cfg.WorkPools = []string{"infrahub-worker", "infrahub-worker/"} },
+			want:   []string{"--work-pools requires --pause-work-pools", "invalid --work-pools entry"},
+		},
+		{
+			name:   "empty excluded table",
+			modify: func(cfg *Configuration) { cfg.PgExcludeTableData = []string{"log", " "} },
+			want:   []string{"invalid --pg-exclude-table-data entry"},
+		},
+		{
+			name:   "parallel dump with plakar",
+			modify: func(cfg *Configuration) { cfg.PgJobs = 4; cfg.Backend = BackendPlakar; cfg.Plakar.RepoPath = "/repo" },
+			want:   []string{"--pg-jobs is not supported with the plakar backend"},
+		},
+		{
+			name:   "streamed parallel dump",
+			modify: func(cfg *Configuration) { cfg.PgJobs = 4; cfg.StreamBackup = true },
+			want:   []string{"--stream cannot be combined with --pg-jobs"},
+		},
+		{
+			name: "every problem is reported",
+			modify: func(cfg *Configuration) {
+				cfg.Nice = 20
+				cfg.IONice = "realtime"
+				cfg.DetectCacheTTL = -time.Minute
+				cfg.ArtifactsExclude = []string{"["}
+				cfg.BackupDir = notADir
+			},
+			logFormat: "yaml",
+			want: []string{
+				"invalid --detect-cache-ttl",
+				"invalid --nice 20",
+				"invalid artifact pattern",
+				"invalid --log-format",
+				"is not a directory",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iops := NewInfrahubOps()
+			iops.config.BackupDir = t.TempDir()
+			if tt.modify != nil {
+				tt.modify(iops.config)
+			}
+
+			problems := iops.validateConfiguration(tt.logFormat, tt.s3Upload)
+			if len(problems) != len(tt.want) {
+				t.Fatalf("validateConfiguration() = %v, want %d problem(s)", problems, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(problems[i].Error(), want) {
+					t.Errorf("problem %d = %q, want it to contain %q", i, problems[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestMaskConnectionURL(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"postgresql+asyncpg://prefect:s3cret@db:5432/prefect", "postgresql+asyncpg://prefect:xxxxx@db:5432/prefect"},
+		{"postgresql://prefect@db/prefect", "postgresql://prefect@db/prefect"},
+		{"postgres://%zz:secret@db", secretMask},
+	}
+
+	for _, tt := range tests {
+		if got := maskConnectionURL(tt.value); got != tt.want {
+			t.Errorf("maskConnectionURL(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
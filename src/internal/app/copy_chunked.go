@@ -0,0 +1,202 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Defaults of --copy-chunk-size and --copy-parallelism.
+const (
+	defaultCopyChunkSize   = int64(1) << 30
+	defaultCopyParallelism = 4
+)
+
+// copyChunkAttempts bounds how often one chunk is copied before giving up.
+const copyChunkAttempts = 3
+
+// splitRemoteFileScript splits $1 into $2-byte chunks named $1.chunk.0000,
+// $1.chunk.0001, ... and prints their SHA-256 sums.
+const splitRemoteFileScript = `split -b "$2" -d -a 4 "$1" "$1.chunk." && sha256sum "$1".chunk.*`
+
+// remoteChunk is one chunk of a file split in a container.
+type remoteChunk struct {
+	path   string
+	sha256 string
+}
+
+// parseCopyChunkSize parses --copy-chunk-size; 0 disables chunked copies.
+func parseCopyChunkSize(value string) (int64, error) {
+	if strings.TrimSpace(value) == "0" {
+		return 0, nil
+	}
+	size, err := parseByteSize(value)
+	if err != nil {
+		return 0, fmt.Errorf("--copy-chunk-size: %w", err)
+	}
+	return size, nil
+}
+
+// CopyFileFrom copies the file src out of service to dest. A file larger
+// than --copy-chunk-size is split in the container and its chunks are copied
+// in parallel, each checked against its SHA-256 and copied again on failure,
+// so a broken copy only repeats one chunk. The chunks are then joined into
+// dest.
+func (iops *InfrahubOps) CopyFileFrom(service, src, dest string) error {
+	chunkSize := iops.config.CopyChunkSize
+	if chunkSize <= 0 {
+		return iops.CopyFrom(service, src, dest)
+	}
+	output, err := iops.Exec(service, []string{"stat", "-c", "%s", src}, nil)
+	if err != nil {
+		return iops.CopyFrom(service, src, dest)
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(output), 10, 64)
+	if err != nil || size <= chunkSize {
+		return iops.CopyFrom(service, src, dest)
+	}
+
+	output, err = iops.Exec(service, []string{"sh", "-c", splitRemoteFileScript, "sh", src, strconv.FormatInt(chunkSize, 10)}, nil)
+	chunks := parseChunkSums(output)
+	defer iops.removeRemoteChunks(service, src, chunks)
+	if err != nil || len(chunks) == 0 {
+		logrus.Warnf("Could not split %s in the %s container; copying it whole: %v", src, service, err)
+		return iops.CopyFrom(service, src, dest)
+	}
+
+	logrus.Infof("Copying %s (%s) in %d chunks of %s", src, formatBytes(size), len(chunks), formatBytes(chunkSize))
+	chunkDir := dest + ".chunks"
+	if err := os.MkdirAll(chunkDir, 0755); err != nil {
+		return fmt.Errorf("failed to create chunk directory: %w", err)
+	}
+	defer os.RemoveAll(chunkDir)
+
+	localPaths, err := iops.copyChunks(service, chunks, chunkDir)
+	if err != nil {
+		return err
+	}
+	return joinChunks(localPaths, dest)
+}
+
+// parseChunkSums reads sha256sum output into chunks in name order.
+func parseChunkSums(output string) []remoteChunk {
+	var chunks []remoteChunk
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || len(fields[0]) != sha256.Size*2 {
+			continue
+		}
+		chunks = append(chunks, remoteChunk{path: fields[1], sha256: fields[0]})
+	}
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].path < chunks[j].path })
+	return chunks
+}
+
+// copyChunks copies chunks into dir with --copy-parallelism copies at a time
+// and returns their local paths in order.
+func (iops *InfrahubOps) copyChunks(service string, chunks []remoteChunk, dir string) ([]string, error) {
+	parallelism := max(iops.config.CopyParallelism, 1)
+	localPaths := make([]string, len(chunks))
+	errs := make([]error, len(chunks))
+	slots := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i, chunk := range chunks {
+		localPaths[i] = filepath.Join(dir, filepath.Base(chunk.path))
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			errs[i] = iops.copyChunk(service, chunk, localPaths[i])
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return localPaths, nil
+}
+
+// copyChunk copies one chunk until its checksum matches, at most
+// copyChunkAttempts times.
+func (iops *InfrahubOps) copyChunk(service string, chunk remoteChunk, dest string) error {
+	var err error
+	for attempt := 1; attempt <= copyChunkAttempts; attempt++ {
+		iops.throttle.wait("copying chunk " + filepath.Base(chunk.path))
+		if err = iops.CopyFrom(service, chunk.path, dest); err == nil {
+			err = verifyChunk(dest, chunk.sha256)
+		}
+		if err == nil {
+			return nil
+		}
+		logrus.Warnf("Copy %d/%d of chunk %s failed: %v", attempt, copyChunkAttempts, filepath.Base(chunk.path), err)
+	}
+	return fmt.Errorf("failed to copy chunk %s: %w", chunk.path, err)
+}
+
+// verifyChunk compares the SHA-256 of path with want.
+func verifyChunk(path, want string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != want {
+		return fmt.Errorf("checksum mismatch: got %s, want %s", got, want)
+	}
+	return nil
+}
+
+// joinChunks concatenates paths into dest.
+func joinChunks(paths []string, dest string) error {
+	out, err := os.Create(dest)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", dest, err)
+	}
+	for _, path := range paths {
+		if err := appendFile(out, path); err != nil {
+			out.Close()
+			return fmt.Errorf("failed to join chunk %s: %w", filepath.Base(path), err)
+		}
+	}
+	return out.Close()
+}
+
+func appendFile(out io.Writer, path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	_, err = io.Copy(out, in)
+	return err
+}
+
+// removeRemoteChunks deletes the chunks of src from the container.
+func (iops *InfrahubOps) removeRemoteChunks(service, src string, chunks []remoteChunk) {
+	if len(chunks) == 0 {
+		return
+	}
+	cmd := []string{"rm", "-f"}
+	for _, chunk := range chunks {
+		cmd = append(cmd, chunk.path)
+	}
+	if _, err := iops.Exec(service, cmd, nil); err != nil {
+		logrus.Warnf("Failed to remove the chunks of %s from the %s container: %v", src, service, err)
+	}
+}
@@ -0,0 +1,116 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// chunkedCopyFixture fakes a file of 3 chunks split in the database
+// container. The fake executor copies nothing, so the chunk contents are
+// written where the copies land beforehand.
+func chunkedCopyFixture(t *testing.T, contents []string) (*fakeExecutor, *InfrahubOps, string) {
+	t.Helper()
+	var sums strings.Builder
+	for i := range contents {
+		sums.WriteString(sha256Hex("part" + string(rune('a'+i))))
+		sums.WriteString("  /tmp/neo4j.dump.chunk.000" + string(rune('0'+i)) + "\n")
+	}
+	fake := newFakeExecutor().
+		on("stat -c %s /tmp/neo4j.dump", "3000\n", nil).
+		on("split -b", sums.String(), nil)
+	iops := newFakeDockerOps(fake)
+	iops.config.CopyChunkSize = 1024
+	iops.config.CopyParallelism = 2
+
+	dest := filepath.Join(t.TempDir(), "neo4j.dump")
+	for i, content := range contents {
+		writeTestFile(t, dest+".chunks", "neo4j.dump.chunk.000"+string(rune('0'+i)), content)
+	}
+	return fake, iops, dest
+}
+
+func TestCopyFileFromChunked(t *testing.T) {
+	fake, iops, dest := chunkedCopyFixture(t, []string{"parta", "partb", "partc"})
+
+	if err := iops.CopyFileFrom("database", "/tmp/neo4j.dump", dest); err != nil {
+		t.Fatalf("CopyFileFrom() error = %v", err)
+	}
+	data, err := os.ReadFile(dest)
+	if err != nil || string(data) != "partapartbpartc" {
+		t.Errorf("joined file = %q, %v", data, err)
+	}
+	if copies := fake.commands(" cp "); len(copies) != 3 {
+		t.Errorf("copies = %v, want one per chunk", copies)
+	}
+	if removed := fake.commands("rm -f /tmp/neo4j.dump.chunk.0000 /tmp/neo4j.dump.chunk.0001 /tmp/neo4j.dump.chunk.0002"); len(removed) != 1 {
+		t.Errorf("remote chunks not removed: %v", fake.calls)
+	}
+	if _, err := os.Stat(dest + ".chunks"); !os.IsNotExist(err) {
+		t.Errorf("local chunk directory left behind: %v", err)
+	}
+}
+
+func TestCopyFileFromChunkMismatch(t *testing.T) {
+	fake, iops, dest := chunkedCopyFixture(t, []string{"parta", "corrupted", "partc"})
+
+	err := iops.CopyFileFrom("database", "/tmp/neo4j.dump", dest)
+	if err == nil || !strings.Contains(err.Error(), "neo4j.dump.chunk.0001") || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("CopyFileFrom() error = %v", err)
+	}
+	if retries := fake.commands("database:/tmp/neo4j.dump.chunk.0001"); len(retries) != copyChunkAttempts {
+		t.Errorf("corrupted chunk copied %d times, want %d", len(retries), copyChunkAttempts)
+	}
+	if removed := fake.commands("rm -f /tmp/neo4j.dump.chunk."); len(removed) != 1 {
+		t.Errorf("remote chunks not removed after the failure: %v", fake.calls)
+	}
+}
+
+func TestCopyFileFromSmallFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		stat      string
+		chunkSize int64
+	}{
+		{name: "below the chunk size", stat: "100\n", chunkSize: 1024},
+		{name: "chunks disabled", stat: "3000\n", chunkSize: 0},
+		{name: "stat unavailable", stat: "stat: not found\n", chunkSize: 1024},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeExecutor().on("stat -c", tt.stat, nil)
+			iops := newFakeDockerOps(fake)
+			iops.config.CopyChunkSize = tt.chunkSize
+
+			if err := iops.CopyFileFrom("database", "/tmp/neo4j.dump", "/backups/neo4j.dump"); err != nil {
+				t.Fatalf("CopyFileFrom() error = %v", err)
+			}
+			if copies := fake.commands("cp database:/tmp/neo4j.dump /backups/neo4j.dump"); len(copies) != 1 {
+				t.Errorf("commands = %v, want a whole-file copy", fake.calls)
+			}
+			if splits := fake.commands("split"); len(splits) != 0 {
+				t.Errorf("file was split: %v", splits)
+			}
+		})
+	}
+}
+
+func TestParseCopyChunkSize(t *testing.T) {
+	if size, err := parseCopyChunkSize("0"); err != nil || size != 0 {
+		t.Errorf("parseCopyChunkSize(0) = %d, %v", size, err)
+	}
+	if size, err := parseCopyChunkSize("512M"); err != nil || size != 512<<20 {
+		t.Errorf("parseCopyChunkSize(512M) = %d, %v", size, err)
+	}
+	if _, err := parseCopyChunkSize("-1G"); err == nil {
+		t.Error("parseCopyChunkSize(-1G) succeeded")
+	}
+}
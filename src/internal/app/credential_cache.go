@@ -0,0 +1,199 @@
+package app
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// defaultCredentialCacheTTL bounds how long discovered credentials are reused
+// when --credential-cache is set.
+const defaultCredentialCacheTTL = 15 * time.Minute
+
+// credentialCipher encrypts the credential cache with an external tool, so the
+// key never has to be handled by infrahub-ops.
+type credentialCipher struct {
+	tool string // "age" or "gpg"
+	key  string // age identity file or gpg recipient
+}
+
+// parseCredentialCache parses --credential-cache: age:<identity-file> or
+// gpg:<recipient>.
+func parseCredentialCache(spec string) (*credentialCipher, error) {
+	tool, key, ok := strings.Cut(spec, ":")
+	if !ok || key == "" || (tool != "age" && tool != "gpg") {
+		return nil, fmt.Errorf("invalid --credential-cache %q: expected age:<identity-file> or gpg:<recipient>", spec)
+	}
+	return &credentialCipher{tool: tool, key: key}, nil
+}
+
+func (c *credentialCipher) encryptArgs(outputPath string) []string {
+	if c.tool == "age" {
+		return []string{"--encrypt", "--identity", c.key, "--output", outputPath}
+	}
+	return []string{"--batch", "--yes", "--quiet", "--encrypt", "--recipient", c.key, "--output", outputPath}
+}
+
+func (c *credentialCipher) decryptArgs(inputPath string) []string {
+	if c.tool == "age" {
+		return []string{"--decrypt", "--identity", c.key, inputPath}
+	}
+	return []string{"--batch", "--quiet", "--decrypt", inputPath}
+}
+
+// credentialCacheEntry is the plaintext of one cache file.
+type credentialCacheEntry struct {
+	Target      string              `json:"target"`
+	CachedAt    time.Time           `json:"cached_at"`
+	Credentials DatabaseCredentials `json:"credentials"`
+}
+
+// credentialCachePath returns the encrypted cache file of a target. File names
+// are hashed so the cache directory does not list the deployments.
+func credentialCachePath(target, tool string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(target))
+	return filepath.Join(dir, "infrahub-ops", "credentials", hex.EncodeToString(sum[:8])+"."+tool), nil
+}
+
+// credentialCacheTarget identifies the deployment whose credentials are cached:
+// the project or namespace together with the Docker host or cluster it runs
+// on, so deployments that share a name never share credentials.
+func (iops *InfrahubOps) credentialCacheTarget() string {
+	if iops.backend == nil {
+		return ""
+	}
+	target := iops.backend.Name() + ":" + iops.backend.Info() + "@" + iops.environmentLocation(iops.backend.Name())
+	if docker, ok := iops.backend.(*DockerBackend); ok {
+		if workingDir, _, err := docker.composeProjectFiles(); err == nil {
+			target += ";dir=" + workingDir
+		}
+	}
+	return target
+}
+
+// currentCredentials returns the database credentials in the configuration.
+func (iops *InfrahubOps) currentCredentials() DatabaseCredentials {
+	cfg := iops.config
+	return DatabaseCredentials{
+		Neo4jUsername:    cfg.Neo4jUsername,
+		Neo4jPassword:    cfg.Neo4jPassword,
+		Neo4jDatabase:    cfg.Neo4jDatabase,
+		PostgresUsername: cfg.PostgresUsername,
+		PostgresPassword: cfg.PostgresPassword,
+		PostgresDatabase: cfg.PostgresDatabase,
+	}
+}
+
+// loadCachedCredentials returns the cached credentials of the current target
+// if the cache is enabled and the entry is younger than the TTL. Any problem
+// only means the credentials are discovered again.
+func (iops *InfrahubOps) loadCachedCredentials() (DatabaseCredentials, bool) {
+	if iops.config.CredentialCache == "" || iops.config.CredentialCacheTTL <= 0 {
+		return DatabaseCredentials{}, false
+	}
+	cipher, err := parseCredentialCache(iops.config.CredentialCache)
+	if err != nil {
+		return DatabaseCredentials{}, false
+	}
+	target := iops.credentialCacheTarget()
+	path, err := credentialCachePath(target, cipher.tool)
+	if err != nil {
+		return DatabaseCredentials{}, false
+	}
+	if _, err := os.Stat(path); err != nil {
+		return DatabaseCredentials{}, false
+	}
+
+	stdout, wait, err := iops.executor.runCommandPipe(cipher.tool, cipher.decryptArgs(path)...)
+	if err != nil {
+		logrus.Debugf("Could not decrypt credential cache: %v", err)
+		return DatabaseCredentials{}, false
+	}
+	data, readErr := io.ReadAll(stdout)
+	if err := wait(); err != nil || readErr != nil {
+		logrus.Debugf("Could not decrypt credential cache: %v", errors.Join(err, readErr))
+		return DatabaseCredentials{}, false
+	}
+
+	var entry credentialCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		logrus.Debugf("Ignoring unreadable credential cache %s: %v", path, err)
+		return DatabaseCredentials{}, false
+	}
+	if entry.Target != target || time.Since(entry.CachedAt) > iops.config.CredentialCacheTTL {
+		return DatabaseCredentials{}, false
+	}
+	return entry.Credentials, true
+}
+
+// storeCachedCredentials encrypts the resolved credentials of the current
+// target into the cache. The plaintext is only passed on stdin.
+func (iops *InfrahubOps) storeCachedCredentials() {
+	if iops.config.CredentialCache == "" || iops.config.CredentialCacheTTL <= 0 {
+		return
+	}
+	cipher, err := parseCredentialCache(iops.config.CredentialCache)
+	if err != nil {
+		return
+	}
+	target := iops.credentialCacheTarget()
+	path, err := credentialCachePath(target, cipher.tool)
+	if err != nil {
+		logrus.Debugf("Could not write credential cache: %v", err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		logrus.Debugf("Could not write credential cache: %v", err)
+		return
+	}
+
+	data, err := json.Marshal(credentialCacheEntry{
+		Target:      target,
+		CachedAt:    time.Now().UTC(),
+		Credentials: iops.currentCredentials(),
+	})
+	if err != nil {
+		return
+	}
+	tmpPath := path + ".tmp"
+	wait, err := iops.executor.runCommandWritePipe(bytes.NewReader(data), cipher.tool, cipher.encryptArgs(tmpPath)...)
+	if err == nil {
+		err = wait()
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		logrus.Warnf("Could not write credential cache with %s: %v", cipher.tool, err)
+	}
+}
+
+// ClearCredentialCache removes the cached credentials of the current target.
+func (iops *InfrahubOps) ClearCredentialCache() {
+	cipher, err := parseCredentialCache(iops.config.CredentialCache)
+	if err != nil {
+		return
+	}
+	path, err := credentialCachePath(iops.credentialCacheTarget(), cipher.tool)
+	if err != nil {
+		return
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		logrus.Debugf("Could not remove credential cache: %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCredentialCache(t *testing.T) {
+	tests := []struct {
+		spec     string
+		wantTool string
+		wantErr  bool
+	}{
+		{spec: "age:/etc/infrahub/cache.key", wantTool: "age"},
+		{spec: "gpg:backups@example.com", wantTool: "gpg"},
+		{spec: "gpg:", wantErr: true},
+		{spec: "aes:secret", wantErr: true},
+		{spec: "/etc/infrahub/cache.key", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			cipher, err := parseCredentialCache(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCredentialCache() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && cipher.tool != tt.wantTool {
+				t.Errorf("tool = %s, want %s", cipher.tool, tt.wantTool)
+			}
+		})
+	}
+}
+
+// writeCachedCredentials creates the cache file of the current target of iops
+// and returns the decrypted entry, recorded for target, that the fake tool
+// should print for it.
+func writeCachedCredentials(t *testing.T, iops *InfrahubOps, target string, cachedAt time.Time) string {
+	t.Helper()
+	path, err := credentialCachePath(iops.credentialCacheTarget(), "age")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("age-encryption.org/v1"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(credentialCacheEntry{
+		Target:   target,
+		CachedAt: cachedAt,
+		Credentials: DatabaseCredentials{
+			Neo4jUsername: "neo4j", Neo4jPassword: "cached", Neo4jDatabase: "neo4j",
+			PostgresUsername: "prefect", PostgresPassword: "cached", PostgresDatabase: "prefect",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestFetchDatabaseCredentialsUsesCache(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     string
+		age        time.Duration
+		decryptErr error
+		wantCached bool
+	}{
+		{name: "fresh", age: time.Minute, wantCached: true},
+		{name: "expired", age: time.Hour},
+		{name: "other target", target: "docker:other@docker_host=;docker_context=", age: time.Minute},
+		{name: "decrypt fails", age: time.Minute, decryptErr: errors.New("no identity matched")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_CACHE_HOME", t.TempDir())
+			fake := newFakeExecutor()
+			iops := newFakeDockerOps(fake)
+			iops.config.CredentialCache = "age:/keys/cache.txt"
+			target := tt.target
+			if target == "" {
+				target = iops.credentialCacheTarget()
+			}
+			entry := writeCachedCredentials(t, iops, target, time.Now().Add(-tt.age))
+			fake.on("age --decrypt --identity /keys/cache.txt", entry, tt.decryptErr)
+			iops.config.Credentials.Neo4jPassword = "explicit"
+
+			if err := iops.fetchDatabaseCredentials(); err != nil {
+				t.Fatalf("fetchDatabaseCredentials() error = %v", err)
+			}
+			if iops.config.Neo4jPassword != "explicit" {
+				t.Errorf("Neo4j password = %s, explicit value must win", iops.config.Neo4jPassword)
+			}
+			discovered := fake.commands(" env")
+			if tt.wantCached {
+				if iops.config.PostgresPassword != "cached" || len(discovered) != 0 {
+					t.Errorf("postgres password = %s, discovery commands %v", iops.config.PostgresPassword, discovered)
+				}
+				if got := fake.commands("age --encrypt"); len(got) != 0 {
+					t.Errorf("cache rewritten on a hit: %v", got)
+				}
+				return
+			}
+			if len(discovered) == 0 {
+				t.Error("credentials were not discovered from the containers")
+			}
+			encrypt := fake.commands("age --encrypt --identity /keys/cache.txt --output")
+			if len(encrypt) != 1 || !strings.HasSuffix(encrypt[0], ".age.tmp") {
+				t.Errorf("encrypt commands = %v, want one", encrypt)
+			}
+		})
+	}
+}
+
+func TestCredentialCacheTargetLocation(t *testing.T) {
+	t.Setenv("DOCKER_HOST", "")
+	t.Setenv("DOCKER_CONTEXT", "")
+	t.Setenv("KUBECONFIG", "")
+
+	docker := func(host, workingDir string) string {
+		t.Setenv("DOCKER_HOST", host)
+		fake := newFakeExecutor().on("com.docker.compose.project.working_dir", workingDir+"|"+workingDir+"/docker-compose.yml\n", nil)
+		return newFakeDockerOps(fake).credentialCacheTarget()
+	}
+	newKubernetesOps := func(fake *fakeExecutor) *InfrahubOps {
+		iops := NewInfrahubOpsWithExecutor(fake)
+		iops.backend = &KubernetesBackend{config: iops.config, executor: fake, namespace: "infrahub"}
+		return iops
+	}
+	kubernetes := func(context string) string {
+		return newKubernetesOps(newFakeExecutor().on("config current-context", context+"\n", nil)).credentialCacheTarget()
+	}
+
+	base := docker("", "/srv/infrahub")
+	if base != "docker:test@docker_host=;docker_context=;dir=/srv/infrahub" {
+		t.Errorf("credentialCacheTarget() = %q", base)
+	}
+	if other := docker("ssh://backup@host-b", "/srv/infrahub"); other == base {
+		t.Error("another Docker host shares the cache entry")
+	}
+	if other := docker("", "/opt/infrahub"); other == base {
+		t.Error("another compose directory shares the cache entry")
+	}
+	if a, b := kubernetes("cluster-a"), kubernetes("cluster-b"); a == b {
+		t.Errorf("clusters share the cache entry %q", a)
+	}
+
+	iops := newKubernetesOps(newFakeExecutor())
+	iops.executor = &kubeContextExecutor{CommandExecutor: iops.executor, context: "eu-cluster"}
+	if got := iops.credentialCacheTarget(); !strings.Contains(got, ";context=eu-cluster") {
+		t.Errorf("credentialCacheTarget() = %q, want the target's kube context", got)
+	}
+}
+
+func TestCredentialCacheDisabled(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	fake := newFakeExecutor()
+	iops := newFakeDockerOps(fake)
+	iops.config.Credentials.Neo4jPassword = "explicit"
+
+	if err := iops.fetchDatabaseCredentials(); err != nil {
+		t.Fatalf("fetchDatabaseCredentials() error = %v", err)
+	}
+	if got := append(fake.commands("age "), fake.commands("gpg ")...); len(got) != 0 {
+		t.Errorf("cache commands without --credential-cache: %v", got)
+	}
+}
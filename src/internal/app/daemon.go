@@ -0,0 +1,375 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// defaultDaemonListenAddr is where /healthz and /metrics are served unless
+// --listen is set.
+const defaultDaemonListenAddr = ":9100"
+
+// daemonHeartbeatInterval is how often the scheduler loop proves it is alive.
+// /healthz fails once no heartbeat was seen for three intervals.
+const daemonHeartbeatInterval = 15 * time.Second
+
+// DaemonOptions configures the backup scheduler.
+type DaemonOptions struct {
+	Interval   time.Duration // time between scheduled backups
+	ListenAddr string        // address for /healthz and /metrics
+	RunAtStart bool          // run a backup immediately instead of after the first interval
+	Window     *BackupWindow // scheduled runs outside the window are skipped; nil allows any time
+	Override   bool          // run outside the window with a warning instead of skipping
+
+	VerifyInterval time.Duration // time between verifications of a retained backup; 0 disables them
+	Verify         func() error  // verifies one retained backup; never runs at the same time as a backup of the daemon's deployment
+
+	MaxJobs          int        // jobs running at the same time
+	MaxJobsPerTarget int        // jobs running at the same time on one target
+	JobsToken        string     // bearer token of the API with every scope
+	APITokens        []APIToken // scoped tokens of the API; without them and JobsToken the API is disabled
+	Targets          []string   // configured targets the API accepts besides the daemon's deployment
+
+	Restore func(ctx context.Context, target, backup string, excludeTaskManager bool) error // restores a catalog backup on target
+	Catalog func(target string) ([]CatalogEntry, error)                                     // lists the backup catalog of target
+}
+
+// daemonRun records the outcome of one backup job.
+type daemonRun struct {
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Err        error
+}
+
+// daemonState is the scheduler state shared with the HTTP handlers.
+type daemonState struct {
+	mu          sync.Mutex
+	startedAt   time.Time
+	heartbeat   time.Time
+	running     int // backup jobs in progress
+	pending     int // backup jobs queued
+	nextRun     time.Time
+	last        *daemonRun // most recently finished job
+	lastSuccess time.Time
+	successes   int
+	failures    int
+
+	lastVerification     time.Time
+	verified             int
+	verificationFailures int
+}
+
+func newDaemonState(now time.Time) *daemonState {
+	return &daemonState{startedAt: now, heartbeat: now}
+}
+
+func (s *daemonState) beat(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.heartbeat = now
+}
+
+// queued counts backup jobs added to (delta 1) or cancelled from (delta -1)
+// the queue.
+func (s *daemonState) queued(delta int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending += delta
+}
+
+func (s *daemonState) start(now time.Time) *daemonRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending--
+	s.running++
+	return &daemonRun{StartedAt: now}
+}
+
+func (s *daemonState) finish(run *daemonRun, now time.Time, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	run.FinishedAt = now
+	run.Err = err
+	s.running--
+	s.last = run
+	if err != nil {
+		s.failures++
+		return
+	}
+	s.successes++
+	s.lastSuccess = now
+}
+
+func (s *daemonState) recordVerification(now time.Time, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastVerification = now
+	if err != nil {
+		s.verificationFailures++
+		return
+	}
+	s.verified++
+}
+
+func (s *daemonState) setNextRun(t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextRun = t
+}
+
+// RunDaemon queues a backup of the daemon's deployment every opts.Interval
+// and serves /healthz, /metrics and, with API tokens, the jobs API until
+// ctx is cancelled. backup runs a backup job of target, empty for the
+// daemon's deployment, and should stop at the next phase once its context is
+// cancelled. A failed job is reported and retried at the next interval. With
+// opts.VerifyInterval, opts.Verify is queued too, as a job that runs alone on
+// the daemon's deployment.
+func RunDaemon(ctx context.Context, opts DaemonOptions, backup func(ctx context.Context, target string) error) error {
+	if opts.Interval <= 0 {
+		return fmt.Errorf("--interval must be greater than zero")
+	}
+	if opts.ListenAddr == "" {
+		opts.ListenAddr = defaultDaemonListenAddr
+	}
+
+	state := newDaemonState(time.Now())
+	queue := newJobQueue(opts.MaxJobs, opts.MaxJobsPerTarget, state)
+	checkTarget := func(target string) error {
+		if target != "" && !slices.Contains(opts.Targets, target) {
+			return fmt.Errorf("unknown target %q", target)
+		}
+		return nil
+	}
+	submitBackup := func(target, trigger, requestedBy string) (Job, error) {
+		if err := checkTarget(target); err != nil {
+			return Job{}, err
+		}
+		spec := Job{Kind: JobKindBackup, Target: target, Trigger: trigger, RequestedBy: requestedBy}
+		return queue.submit(spec, func(ctx context.Context) error {
+			return backup(ctx, target)
+		})
+	}
+
+	listener, err := net.Listen("tcp", opts.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", opts.ListenAddr, err)
+	}
+	handler := newDaemonHandler(state)
+	tokens := opts.APITokens
+	if opts.JobsToken != "" {
+		tokens = append(tokens, JobsToken(opts.JobsToken))
+	}
+	if len(tokens) > 0 {
+		api := &jobsAPI{
+			queue:  queue,
+			tokens: tokens,
+			backup: func(target, requestedBy string) (Job, error) {
+				return submitBackup(target, JobTriggerAPI, requestedBy)
+			},
+			restore: func(request restoreRequest, requestedBy string) (Job, error) {
+				if err := checkTarget(request.Target); err != nil {
+					return Job{}, err
+				}
+				if opts.Restore == nil {
+					return Job{}, fmt.Errorf("restores are not enabled")
+				}
+				spec := Job{Kind: JobKindRestore, Target: request.Target, Backup: request.Backup, Trigger: JobTriggerAPI, RequestedBy: requestedBy}
+				return queue.submit(spec, func(ctx context.Context) error {
+					return opts.Restore(ctx, request.Target, request.Backup, request.ExcludeTaskManager)
+				})
+			},
+			catalog: func(target string) ([]CatalogEntry, error) {
+				if err := checkTarget(target); err != nil {
+					return nil, err
+				}
+				if opts.Catalog == nil {
+					return nil, fmt.Errorf("the catalog is not available")
+				}
+				return opts.Catalog(target)
+			},
+		}
+		api.register(handler)
+	}
+	server := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logrus.Errorf("Health endpoint stopped: %v", err)
+		}
+	}()
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	logrus.WithFields(logrus.Fields{
+		"interval": opts.Interval,
+		"listen":   listener.Addr().String(),
+	}).Info("Backup daemon started")
+
+	schedule := func() {
+		if err := opts.Window.Check(time.Now()); err != nil && !opts.Override {
+			logrus.Infof("Skipping scheduled backup: %v", err)
+			return
+		}
+		if _, err := submitBackup("", JobTriggerSched, ""); err != nil {
+			logrus.Warnf("Skipping this scheduled backup: %v", err)
+		}
+	}
+	scheduleVerify := func() {
+		_, err := queue.submit(Job{Kind: JobKindVerify, Trigger: JobTriggerSched}, func(context.Context) error {
+			return opts.Verify()
+		})
+		if err != nil {
+			logrus.Warnf("Skipping this backup verification: %v", err)
+		}
+	}
+
+	if opts.RunAtStart {
+		schedule()
+	}
+	nextRun := time.Now().Add(opts.Interval)
+	state.setNextRun(nextRun)
+	verifying := opts.VerifyInterval > 0 && opts.Verify != nil
+	nextVerify := time.Now().Add(opts.VerifyInterval)
+
+	heartbeat := time.NewTicker(daemonHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			logrus.Info("Backup daemon stopping; waiting for the running jobs to finish")
+			queue.close()
+			return nil
+		case now := <-heartbeat.C:
+			state.beat(now)
+			if !now.Before(nextRun) {
+				schedule()
+				nextRun = now.Add(opts.Interval)
+				state.setNextRun(nextRun)
+			}
+			if verifying && !now.Before(nextVerify) {
+				scheduleVerify()
+				nextVerify = now.Add(opts.VerifyInterval)
+			}
+		}
+	}
+}
+
+// daemonHealth is the /healthz response body.
+type daemonHealth struct {
+	Status               string   `json:"status"`
+	Running              bool     `json:"running"`
+	PendingJobs          int      `json:"pending_jobs"`
+	LastBackupStatus     string   `json:"last_backup_status,omitempty"`
+	LastBackupAgeSeconds *float64 `json:"last_backup_age_seconds,omitempty"`
+	LastBackupError      string   `json:"last_backup_error,omitempty"`
+	NextRun              string   `json:"next_run,omitempty"`
+}
+
+func newDaemonHandler(state *daemonState) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		health, ok := state.health(time.Now())
+		w.Header().Set("Content-Type", "application/json")
+		if !ok {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(health)
+	})
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		state.writeMetrics(w)
+	})
+	return mux
+}
+
+// health reports the scheduler state. The daemon is unhealthy only when the
+// scheduler loop stopped beating; failed backups are visible in the body and
+// in /metrics but do not fail the liveness probe.
+func (s *daemonState) health(now time.Time) (daemonHealth, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	health := daemonHealth{
+		Status:      "ok",
+		Running:     s.running > 0,
+		PendingJobs: s.pending,
+	}
+	if !s.nextRun.IsZero() {
+		health.NextRun = s.nextRun.UTC().Format(time.RFC3339)
+	}
+	if s.last != nil {
+		age := now.Sub(s.last.FinishedAt).Seconds()
+		health.LastBackupAgeSeconds = &age
+		health.LastBackupStatus = "success"
+		if s.last.Err != nil {
+			health.LastBackupStatus = "failure"
+			health.LastBackupError = s.last.Err.Error()
+		}
+	}
+
+	healthy := now.Sub(s.heartbeat) <= 3*daemonHeartbeatInterval
+	if !healthy {
+		health.Status = "stalled"
+	}
+	return health, healthy
+}
+
+// writeMetrics renders the scheduler state in the Prometheus text format.
+func (s *daemonState) writeMetrics(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	gauge := func(name, help string, value float64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %s\n", name, help, name, name, strconv.FormatFloat(value, 'f', -1, 64))
+	}
+	unix := func(t time.Time) float64 {
+		if t.IsZero() {
+			return 0
+		}
+		return float64(t.UnixNano()) / 1e9
+	}
+	boolValue := func(b bool) float64 {
+		if b {
+			return 1
+		}
+		return 0
+	}
+
+	gauge("infrahub_backup_daemon_start_time_seconds", "Unix time the daemon started.", unix(s.startedAt))
+	gauge("infrahub_backup_daemon_heartbeat_timestamp_seconds", "Unix time of the last scheduler heartbeat.", unix(s.heartbeat))
+	gauge("infrahub_backup_daemon_running_jobs", "Number of backups currently running.", float64(s.running))
+	gauge("infrahub_backup_daemon_pending_jobs", "Number of scheduled backups waiting to run.", float64(s.pending))
+	gauge("infrahub_backup_daemon_next_run_timestamp_seconds", "Unix time of the next scheduled backup.", unix(s.nextRun))
+	gauge("infrahub_backup_last_success_timestamp_seconds", "Unix time of the last successful backup.", unix(s.lastSuccess))
+
+	if s.last != nil {
+		gauge("infrahub_backup_last_run_timestamp_seconds", "Unix time the last backup finished.", unix(s.last.FinishedAt))
+		gauge("infrahub_backup_last_run_success", "Whether the last backup succeeded (1) or failed (0).", boolValue(s.last.Err == nil))
+		gauge("infrahub_backup_last_run_duration_seconds", "Duration of the last backup.", s.last.FinishedAt.Sub(s.last.StartedAt).Seconds())
+	}
+
+	fmt.Fprintln(w, "# HELP infrahub_backup_runs_total Scheduled backups by outcome.")
+	fmt.Fprintln(w, "# TYPE infrahub_backup_runs_total counter")
+	fmt.Fprintf(w, "infrahub_backup_runs_total{status=\"success\"} %d\n", s.successes)
+	fmt.Fprintf(w, "infrahub_backup_runs_total{status=\"failure\"} %d\n", s.failures)
+
+	counter := func(name, help string, value int) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, value)
+	}
+	counter("infrahub_backup_verified_total", "Retained backups that passed a background verification.", s.verified)
+	counter("infrahub_backup_verification_failures_total", "Retained backups that failed a background verification.", s.verificationFailures)
+	gauge("infrahub_backup_last_verification_timestamp_seconds", "Unix time of the last background verification.", unix(s.lastVerification))
+}
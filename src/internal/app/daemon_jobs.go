@@ -0,0 +1,464 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// JobState is the state of a daemon job.
+type JobState string
+
+// Job states. Queued and running jobs can be cancelled.
+const (
+	JobQueued    JobState = "queued"
+	JobRunning   JobState = "running"
+	JobSucceeded JobState = "succeeded"
+	JobFailed    JobState = "failed"
+	JobCancelled JobState = "cancelled"
+)
+
+// Job kinds and triggers.
+const (
+	JobKindBackup   = "backup"
+	JobKindVerify   = "verify"
+	JobKindRestore  = "restore"
+	JobTriggerSched = "schedule"
+	JobTriggerAPI   = "api"
+)
+
+// jobHistoryLimit bounds how many finished jobs the daemon remembers.
+const jobHistoryLimit = 100
+
+// Job is a backup, verification or restore run by the daemon.
+type Job struct {
+	ID          string     `json:"id"`
+	Kind        string     `json:"kind"`
+	Target      string     `json:"target,omitempty"` // configured target; empty for the daemon's own deployment
+	Backup      string     `json:"backup,omitempty"` // catalog reference of the backup a restore reads
+	Trigger     string     `json:"trigger"`
+	RequestedBy string     `json:"requested_by,omitempty"` // API token that queued the job
+	State       JobState   `json:"state"`
+	CreatedAt   time.Time  `json:"created_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	Error       string     `json:"error,omitempty"`
+
+	run    func(ctx context.Context) error
+	cancel context.CancelFunc
+}
+
+func (j *Job) finished() bool {
+	return j.State == JobSucceeded || j.State == JobFailed || j.State == JobCancelled
+}
+
+// exclusive jobs run alone on their target: verifications never overlap a
+// backup of the backups they read, and nothing overlaps a restore.
+func (j *Job) exclusive() bool {
+	return j.Kind == JobKindVerify || j.Kind == JobKindRestore
+}
+
+// jobQueue runs jobs in submission order, at most maxJobs at a time and
+// maxPerTarget at a time on one target.
+type jobQueue struct {
+	mu           sync.Mutex
+	jobs         []*Job // oldest first
+	nextID       int
+	maxJobs      int
+	maxPerTarget int
+	running      int
+	perTarget    map[string]int
+	exclusive    map[string]bool
+	closed       bool
+	wg           sync.WaitGroup
+	state        *daemonState
+}
+
+func newJobQueue(maxJobs, maxPerTarget int, state *daemonState) *jobQueue {
+	return &jobQueue{
+		maxJobs:      max(maxJobs, 1),
+		maxPerTarget: max(maxPerTarget, 1),
+		perTarget:    map[string]int{},
+		exclusive:    map[string]bool{},
+		state:        state,
+	}
+}
+
+// submit queues a job described by the kind, target, backup, trigger and
+// requester of spec and starts it if the limits allow.
+func (q *jobQueue) submit(spec Job, run func(ctx context.Context) error) (Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return Job{}, fmt.Errorf("daemon is stopping")
+	}
+	if spec.Trigger == JobTriggerSched {
+		for _, job := range q.jobs {
+			if job.State == JobQueued && job.Kind == spec.Kind && job.Target == spec.Target && job.Trigger == JobTriggerSched {
+				return Job{}, fmt.Errorf("previous scheduled %s is still queued", spec.Kind)
+			}
+		}
+	}
+	q.nextID++
+	job := &Job{
+		ID:          strconv.Itoa(q.nextID),
+		Kind:        spec.Kind,
+		Target:      spec.Target,
+		Backup:      spec.Backup,
+		Trigger:     spec.Trigger,
+		RequestedBy: spec.RequestedBy,
+		State:       JobQueued,
+		CreatedAt:   time.Now(),
+		run:         run,
+	}
+	q.jobs = append(q.jobs, job)
+	if job.Kind == JobKindBackup {
+		q.state.queued(1)
+	}
+	q.dispatchLocked()
+	return *job, nil
+}
+
+// dispatchLocked starts every queued job the limits allow, oldest first. Jobs
+// of one target start in order; a job that cannot start does not hold back
+// jobs of other targets.
+func (q *jobQueue) dispatchLocked() {
+	if q.closed {
+		return
+	}
+	waiting := map[string]bool{} // targets with an older job that could not start
+	for _, job := range q.jobs {
+		if q.running >= q.maxJobs {
+			return
+		}
+		if job.State != JobQueued || waiting[job.Target] {
+			continue
+		}
+		busy := q.perTarget[job.Target]
+		if q.exclusive[job.Target] || busy >= q.maxPerTarget || (job.exclusive() && busy > 0) {
+			waiting[job.Target] = true
+			continue
+		}
+		q.startLocked(job)
+	}
+}
+
+func (q *jobQueue) startLocked(job *Job) {
+	ctx, cancel := context.WithCancel(context.Background())
+	now := time.Now()
+	job.State = JobRunning
+	job.StartedAt = &now
+	job.cancel = cancel
+	q.running++
+	q.perTarget[job.Target]++
+	if job.exclusive() {
+		q.exclusive[job.Target] = true
+	}
+	var run *daemonRun
+	if job.Kind == JobKindBackup {
+		run = q.state.start(now)
+	}
+
+	q.wg.Add(1)
+	go func() {
+		defer q.wg.Done()
+		defer cancel()
+		log := logrus.WithFields(logrus.Fields{"job": job.ID, "kind": job.Kind, "target": job.Target})
+		log.Info("Job started")
+		err := job.run(ctx)
+		finished := time.Now()
+		switch {
+		case err == nil:
+			log.Info("Job succeeded")
+		case errors.Is(err, context.Canceled):
+			log.Warnf("Job cancelled: %v", err)
+		default:
+			log.Errorf("Job failed: %v", err)
+		}
+		if run != nil {
+			q.state.finish(run, finished, err)
+		} else if job.Kind == JobKindVerify {
+			q.state.recordVerification(finished, err)
+		}
+		q.finish(job, finished, err)
+	}()
+}
+
+func (q *jobQueue) finish(job *Job, now time.Time, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job.FinishedAt = &now
+	switch {
+	case err == nil:
+		job.State = JobSucceeded
+	case errors.Is(err, context.Canceled):
+		job.State = JobCancelled
+		job.Error = err.Error()
+	default:
+		job.State = JobFailed
+		job.Error = err.Error()
+	}
+	q.running--
+	q.perTarget[job.Target]--
+	if job.exclusive() {
+		delete(q.exclusive, job.Target)
+	}
+	q.trimLocked()
+	q.dispatchLocked()
+}
+
+// trimLocked forgets the oldest finished jobs beyond jobHistoryLimit.
+func (q *jobQueue) trimLocked() {
+	finished := 0
+	for _, job := range q.jobs {
+		if job.finished() {
+			finished++
+		}
+	}
+	kept := q.jobs[:0]
+	for _, job := range q.jobs {
+		if job.finished() && finished > jobHistoryLimit {
+			finished--
+			continue
+		}
+		kept = append(kept, job)
+	}
+	q.jobs = kept
+}
+
+// cancel cancels a queued job, or asks a running one to stop. A running job
+// stops at the start of its next phase and still cleans up after itself, for
+// example by starting the services a backup stopped.
+func (q *jobQueue) cancel(id string) (Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, job := range q.jobs {
+		if job.ID != id {
+			continue
+		}
+		switch job.State {
+		case JobQueued:
+			now := time.Now()
+			job.State = JobCancelled
+			job.FinishedAt = &now
+			if job.Kind == JobKindBackup {
+				q.state.queued(-1)
+			}
+			q.trimLocked()
+		case JobRunning:
+			job.cancel()
+		default:
+			return *job, fmt.Errorf("job %s already %s", id, job.State)
+		}
+		return *job, nil
+	}
+	return Job{}, errJobNotFound
+}
+
+var errJobNotFound = errors.New("job not found")
+
+// get returns the job with id.
+func (q *jobQueue) get(id string) (Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, job := range q.jobs {
+		if job.ID == id {
+			return *job, true
+		}
+	}
+	return Job{}, false
+}
+
+// list returns the known jobs, newest first.
+func (q *jobQueue) list() []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	jobs := make([]Job, 0, len(q.jobs))
+	for i := len(q.jobs) - 1; i >= 0; i-- {
+		jobs = append(jobs, *q.jobs[i])
+	}
+	return jobs
+}
+
+// close cancels the queued jobs and waits for the running ones.
+func (q *jobQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	now := time.Now()
+	for _, job := range q.jobs {
+		if job.State == JobQueued {
+			job.State = JobCancelled
+			job.FinishedAt = &now
+			if job.Kind == JobKindBackup {
+				q.state.queued(-1)
+			}
+		}
+	}
+	q.mu.Unlock()
+	q.wg.Wait()
+}
+
+// ForJob returns an independent InfrahubOps for a daemon job on target, a
+// configured target or empty for the deployment of iops, that stops at its
+// next phase once ctx is cancelled.
+func (iops *InfrahubOps) ForJob(ctx context.Context, target string) (*InfrahubOps, error) {
+	scoped := iops.scopedCopy()
+	if target != "" {
+		targets, err := iops.ConfiguredTargets()
+		if err != nil {
+			return nil, err
+		}
+		index := slices.IndexFunc(targets, func(t ConfiguredTarget) bool { return t.Name == target })
+		if index < 0 {
+			return nil, fmt.Errorf("unknown target %q", target)
+		}
+		if scoped, err = iops.forConfiguredTarget(targets[index]); err != nil {
+			return nil, err
+		}
+	}
+	scoped.ctx = ctx
+	return scoped, nil
+}
+
+// jobRequest is the body of POST /jobs.
+type jobRequest struct {
+	Target string `json:"target"`
+}
+
+// restoreRequest is the body of POST /restores.
+type restoreRequest struct {
+	Backup             string `json:"backup"` // backup ID, filename or S3 URI from the target's catalog
+	Target             string `json:"target"`
+	ExcludeTaskManager bool   `json:"exclude_taskmanager"`
+}
+
+// jobsAPI serves the daemon API: jobs, restores and the backup catalog.
+type jobsAPI struct {
+	queue   *jobQueue
+	tokens  []APIToken
+	backup  func(target, requestedBy string) (Job, error)
+	restore func(request restoreRequest, requestedBy string) (Job, error)
+	catalog func(target string) ([]CatalogEntry, error)
+}
+
+// authorized requires a bearer token granting scope; an empty scope is
+// checked by next.
+func (api *jobsAPI) authorized(scope string, next func(w http.ResponseWriter, r *http.Request, token *APIToken)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := authenticate(api.tokens, r)
+		if token == nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "missing or invalid bearer token"})
+			return
+		}
+		if scope != "" && !token.allows(scope) {
+			writeForbidden(w, token, scope)
+			return
+		}
+		next(w, r, token)
+	}
+}
+
+func writeForbidden(w http.ResponseWriter, token *APIToken, scope string) {
+	logrus.Warnf("API token %s was denied: it lacks scope %s", token.Name, scope)
+	writeJSON(w, http.StatusForbidden, map[string]string{"error": fmt.Sprintf("token %s lacks scope %s", token.Name, scope)})
+}
+
+// register adds the API routes to mux.
+func (api *jobsAPI) register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /jobs", api.authorized(ScopeCatalogRead, func(w http.ResponseWriter, r *http.Request, _ *APIToken) {
+		writeJSON(w, http.StatusOK, api.queue.list())
+	}))
+	mux.HandleFunc("GET /jobs/{id}", api.authorized(ScopeCatalogRead, func(w http.ResponseWriter, r *http.Request, _ *APIToken) {
+		if job, ok := api.queue.get(r.PathValue("id")); ok {
+			writeJSON(w, http.StatusOK, job)
+			return
+		}
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": errJobNotFound.Error()})
+	}))
+	mux.HandleFunc("POST /jobs", api.authorized(ScopeBackupCreate, func(w http.ResponseWriter, r *http.Request, token *APIToken) {
+		var request jobRequest
+		if !decodeRequest(w, r, &request) {
+			return
+		}
+		api.accepted(w, token, JobKindBackup)(api.backup(request.Target, token.Name))
+	}))
+	mux.HandleFunc("POST /restores", api.authorized(ScopeRestoreExecute, func(w http.ResponseWriter, r *http.Request, token *APIToken) {
+		var request restoreRequest
+		if !decodeRequest(w, r, &request) {
+			return
+		}
+		if request.Backup == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "backup is required"})
+			return
+		}
+		api.accepted(w, token, JobKindRestore)(api.restore(request, token.Name))
+	}))
+	mux.HandleFunc("POST /jobs/{id}/cancel", api.authorized("", func(w http.ResponseWriter, r *http.Request, token *APIToken) {
+		job, ok := api.queue.get(r.PathValue("id"))
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": errJobNotFound.Error()})
+			return
+		}
+		if scope := jobScope(job.Kind); !token.allows(scope) {
+			writeForbidden(w, token, scope)
+			return
+		}
+		job, err := api.queue.cancel(job.ID)
+		switch {
+		case errors.Is(err, errJobNotFound):
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		case err != nil:
+			writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		default:
+			logrus.Infof("API token %s cancelled %s job %s", token.Name, job.Kind, job.ID)
+			writeJSON(w, http.StatusAccepted, job)
+		}
+	}))
+	mux.HandleFunc("GET /catalog", api.authorized(ScopeCatalogRead, func(w http.ResponseWriter, r *http.Request, _ *APIToken) {
+		entries, err := api.catalog(r.URL.Query().Get("target"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, entries)
+	}))
+}
+
+// accepted answers a request that queued a job of kind.
+func (api *jobsAPI) accepted(w http.ResponseWriter, token *APIToken, kind string) func(Job, error) {
+	return func(job Job, err error) {
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		logrus.Infof("API token %s queued %s job %s", token.Name, kind, job.ID)
+		writeJSON(w, http.StatusAccepted, job)
+	}
+}
+
+// decodeRequest reads the optional JSON body of r into v and answers 400 if
+// it is invalid.
+func decodeRequest(w http.ResponseWriter, r *http.Request, v any) bool {
+	if r.ContentLength == 0 {
+		return true
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(v); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid request: %v", err)})
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,243 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// blockingJob returns a job body that runs until release is closed or its
+// context is cancelled, and a channel closed once it started.
+func blockingJob(release chan struct{}) (func(ctx context.Context) error, chan struct{}) {
+	started := make(chan struct{})
+	return func(ctx context.Context) error {
+		close(started)
+		select {
+		case <-release:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}, started
+}
+
+func waitForJobState(t *testing.T, queue *jobQueue, id string, want JobState) Job {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, job := range queue.list() {
+			if job.ID == id && job.State == want {
+				return job
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job %s never reached %s: %+v", id, want, queue.list())
+	return Job{}
+}
+
+func TestJobQueueCoalescesScheduledJobs(t *testing.T) {
+	queue := newJobQueue(1, 1, newDaemonState(time.Now()))
+	release := make(chan struct{})
+	defer close(release)
+	run, started := blockingJob(release)
+	if _, err := queue.submit(Job{Kind: JobKindBackup, Trigger: JobTriggerSched}, run); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	next := func() func(ctx context.Context) error {
+		run, _ := blockingJob(release)
+		return run
+	}
+	if _, err := queue.submit(Job{Kind: JobKindBackup, Trigger: JobTriggerSched}, next()); err != nil {
+		t.Errorf("scheduled job refused while the previous one runs: %v", err)
+	}
+	if _, err := queue.submit(Job{Kind: JobKindBackup, Trigger: JobTriggerSched}, next()); err == nil {
+		t.Error("second queued scheduled job accepted")
+	}
+	if _, err := queue.submit(Job{Kind: JobKindBackup, Trigger: JobTriggerAPI}, next()); err != nil {
+		t.Errorf("API job refused: %v", err)
+	}
+}
+
+func TestJobQueueLimits(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxJobs      int
+		maxPerTarget int
+		targets      []string
+		kinds        []string
+		wantRunning  []string // IDs running once the queue settled
+	}{
+		{name: "per target", maxJobs: 4, maxPerTarget: 1, targets: []string{"acme", "acme", "globex"}, wantRunning: []string{"1", "3"}},
+		{name: "global", maxJobs: 2, maxPerTarget: 2, targets: []string{"acme", "globex", "initech"}, wantRunning: []string{"1", "2"}},
+		{name: "order within a target", maxJobs: 4, maxPerTarget: 2, targets: []string{"", "", ""}, kinds: []string{JobKindBackup, JobKindVerify, JobKindBackup}, wantRunning: []string{"1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := newJobQueue(tt.maxJobs, tt.maxPerTarget, newDaemonState(time.Now()))
+			release := make(chan struct{})
+			defer queue.close()
+			defer close(release)
+			for i, target := range tt.targets {
+				kind := JobKindBackup
+				if tt.kinds != nil {
+					kind = tt.kinds[i]
+				}
+				run, _ := blockingJob(release)
+				if _, err := queue.submit(Job{Kind: kind, Target: target, Trigger: JobTriggerAPI}, run); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var running []string
+			for _, job := range queue.list() {
+				if job.State == JobRunning {
+					running = append([]string{job.ID}, running...)
+				}
+			}
+			if strings.Join(running, ",") != strings.Join(tt.wantRunning, ",") {
+				t.Errorf("running jobs = %v, want %v", running, tt.wantRunning)
+			}
+		})
+	}
+}
+
+func TestJobQueueCancel(t *testing.T) {
+	state := newDaemonState(time.Now())
+	queue := newJobQueue(1, 1, state)
+	release := make(chan struct{})
+	defer close(release)
+	first, started := blockingJob(release)
+	running, _ := queue.submit(Job{Kind: JobKindBackup, Trigger: JobTriggerAPI}, first)
+	second, _ := blockingJob(release)
+	queued, _ := queue.submit(Job{Kind: JobKindBackup, Trigger: JobTriggerAPI}, second)
+	<-started
+
+	if job, err := queue.cancel(queued.ID); err != nil || job.State != JobCancelled {
+		t.Fatalf("cancel(queued) = %+v, %v", job, err)
+	}
+	if _, err := queue.cancel(running.ID); err != nil {
+		t.Fatalf("cancel(running) error = %v", err)
+	}
+	job := waitForJobState(t, queue, running.ID, JobCancelled)
+	if !strings.Contains(job.Error, "canceled") {
+		t.Errorf("cancelled job error = %q", job.Error)
+	}
+	if _, err := queue.cancel(running.ID); err == nil {
+		t.Error("cancelling a finished job succeeded")
+	}
+	if _, err := queue.cancel("42"); !errors.Is(err, errJobNotFound) {
+		t.Errorf("cancel(unknown) error = %v", err)
+	}
+	if health, _ := state.health(time.Now()); health.PendingJobs != 0 || health.Running {
+		t.Errorf("health after cancellations = %+v", health)
+	}
+}
+
+func TestRunPhaseStopsWhenCancelled(t *testing.T) {
+	iops := NewInfrahubOpsWithExecutor(newFakeExecutor())
+	ctx, cancel := context.WithCancel(context.Background())
+	iops.ctx = ctx
+	ran := 0
+	phase := func() error { ran++; return nil }
+	if err := iops.runPhase("neo4j_backup", phase); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	err := iops.runPhase("taskmanager_backup", phase)
+	if !errors.Is(err, context.Canceled) || ran != 1 {
+		t.Errorf("runPhase() after cancel = %v, phase ran %d times", err, ran)
+	}
+}
+
+func TestJobsAPI(t *testing.T) {
+	queue := newJobQueue(1, 1, newDaemonState(time.Now()))
+	release := make(chan struct{})
+	defer close(release)
+	unknownTarget := func(target string) error {
+		if target == "unknown" {
+			return errors.New(`unknown target "unknown"`)
+		}
+		return nil
+	}
+	mux := http.NewServeMux()
+	api := &jobsAPI{
+		queue: queue,
+		tokens: []APIToken{
+			JobsToken("s3cret"),
+			NewAPIToken("operator", "op", ScopeBackupCreate, ScopeCatalogRead),
+			NewAPIToken("reader", "ro", ScopeCatalogRead),
+		},
+		backup: func(target, requestedBy string) (Job, error) {
+			if err := unknownTarget(target); err != nil {
+				return Job{}, err
+			}
+			run, _ := blockingJob(release)
+			return queue.submit(Job{Kind: JobKindBackup, Target: target, Trigger: JobTriggerAPI, RequestedBy: requestedBy}, run)
+		},
+		restore: func(request restoreRequest, requestedBy string) (Job, error) {
+			run, _ := blockingJob(release)
+			return queue.submit(Job{Kind: JobKindRestore, Target: request.Target, Backup: request.Backup, Trigger: JobTriggerAPI, RequestedBy: requestedBy}, run)
+		},
+		catalog: func(target string) ([]CatalogEntry, error) {
+			return []CatalogEntry{{BackupID: "infrahub_backup_20260101"}}, unknownTarget(target)
+		},
+	}
+	api.register(mux)
+	request := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		name, method, path, token, body string
+		wantStatus                      int
+	}{
+		{name: "no token", method: http.MethodGet, path: "/jobs", wantStatus: http.StatusUnauthorized},
+		{name: "wrong token", method: http.MethodPost, path: "/jobs", token: "guess", wantStatus: http.StatusUnauthorized},
+		{name: "submit without backup:create", method: http.MethodPost, path: "/jobs", token: "ro", wantStatus: http.StatusForbidden},
+		{name: "submit", method: http.MethodPost, path: "/jobs", token: "op", body: `{"target":"acme"}`, wantStatus: http.StatusAccepted},
+		{name: "unknown target", method: http.MethodPost, path: "/jobs", token: "s3cret", body: `{"target":"unknown"}`, wantStatus: http.StatusBadRequest},
+		{name: "restore without restore:execute", method: http.MethodPost, path: "/restores", token: "op", body: `{"backup":"latest"}`, wantStatus: http.StatusForbidden},
+		{name: "restore without backup", method: http.MethodPost, path: "/restores", token: "s3cret", wantStatus: http.StatusBadRequest},
+		{name: "restore", method: http.MethodPost, path: "/restores", token: "s3cret", body: `{"backup":"infrahub_backup_20260101","target":"acme"}`, wantStatus: http.StatusAccepted},
+		{name: "get", method: http.MethodGet, path: "/jobs/1", token: "ro", wantStatus: http.StatusOK},
+		{name: "get unknown", method: http.MethodGet, path: "/jobs/9", token: "s3cret", wantStatus: http.StatusNotFound},
+		{name: "cancel restore without restore:execute", method: http.MethodPost, path: "/jobs/2/cancel", token: "op", wantStatus: http.StatusForbidden},
+		{name: "cancel restore", method: http.MethodPost, path: "/jobs/2/cancel", token: "s3cret", wantStatus: http.StatusAccepted},
+		{name: "cancel backup without backup:create", method: http.MethodPost, path: "/jobs/1/cancel", token: "ro", wantStatus: http.StatusForbidden},
+		{name: "cancel", method: http.MethodPost, path: "/jobs/1/cancel", token: "op", wantStatus: http.StatusAccepted},
+		{name: "cancel unknown", method: http.MethodPost, path: "/jobs/9/cancel", token: "s3cret", wantStatus: http.StatusNotFound},
+		{name: "catalog", method: http.MethodGet, path: "/catalog", token: "ro", wantStatus: http.StatusOK},
+		{name: "catalog of unknown target", method: http.MethodGet, path: "/catalog?target=unknown", token: "ro", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := request(tt.method, tt.path, tt.token, tt.body); rec.Code != tt.wantStatus {
+				t.Errorf("%s %s = %d, want %d: %s", tt.method, tt.path, rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+
+	waitForJobState(t, queue, "1", JobCancelled)
+	var jobs []Job
+	if err := json.Unmarshal(request(http.MethodGet, "/jobs", "s3cret", "").Body.Bytes(), &jobs); err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 2 || jobs[1].Target != "acme" || jobs[1].State != JobCancelled || jobs[1].RequestedBy != "operator" {
+		t.Errorf("GET /jobs = %+v", jobs)
+	}
+	if jobs[0].Kind != JobKindRestore || jobs[0].State != JobCancelled || jobs[0].RequestedBy != jobsTokenName {
+		t.Errorf("restore job = %+v", jobs[0])
+	}
+}
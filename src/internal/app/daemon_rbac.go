@@ -0,0 +1,127 @@
+package app
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Scopes of daemon API tokens.
+const (
+	ScopeBackupCreate   = "backup:create"   // queue and cancel backups
+	ScopeRestoreExecute = "restore:execute" // queue and cancel restores
+	ScopeCatalogRead    = "catalog:read"    // list jobs and catalog entries
+)
+
+// apiScopes are all scopes, as granted to --jobs-token.
+var apiScopes = []string{ScopeBackupCreate, ScopeRestoreExecute, ScopeCatalogRead}
+
+// jobsTokenName names the --jobs-token in job records and logs.
+const jobsTokenName = "jobs-token"
+
+// APIToken is a bearer token of the daemon API with the scopes it grants.
+// Only the SHA-256 of the token is kept.
+type APIToken struct {
+	Name   string
+	Scopes []string
+	sha256 [sha256.Size]byte
+}
+
+// NewAPIToken returns a token named name for secret.
+func NewAPIToken(name, secret string, scopes ...string) APIToken {
+	return APIToken{Name: name, Scopes: scopes, sha256: sha256.Sum256([]byte(secret))}
+}
+
+// JobsToken returns the token of --jobs-token, which grants every scope.
+func JobsToken(secret string) APIToken {
+	return NewAPIToken(jobsTokenName, secret, apiScopes...)
+}
+
+func (t *APIToken) allows(scope string) bool {
+	return slices.Contains(t.Scopes, scope)
+}
+
+// APITokens reads the api-tokens map of the --config file: token names mapped
+// to the hex SHA-256 of the token and its scopes.
+func (iops *InfrahubOps) APITokens() ([]APIToken, error) {
+	raw := iops.settings.Get("api-tokens")
+	if raw == nil {
+		return nil, nil
+	}
+	entries, ok := raw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("invalid api-tokens in %s: expected token names mapped to sha256 and scopes", iops.settings.ConfigFileUsed())
+	}
+	tokens := make([]APIToken, 0, len(entries))
+	for name, value := range entries {
+		token, err := parseAPIToken(name, value)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].Name < tokens[j].Name })
+	return tokens, nil
+}
+
+func parseAPIToken(name string, value any) (APIToken, error) {
+	fields, ok := value.(map[string]any)
+	if !ok {
+		return APIToken{}, fmt.Errorf("invalid API token %s: expected sha256 and scopes", name)
+	}
+	settings := viper.New()
+	if err := settings.MergeConfigMap(fields); err != nil {
+		return APIToken{}, fmt.Errorf("invalid API token %s: %w", name, err)
+	}
+	if name == jobsTokenName {
+		return APIToken{}, fmt.Errorf("invalid API token name %s: reserved for --jobs-token", name)
+	}
+	token := APIToken{Name: name, Scopes: settings.GetStringSlice("scopes")}
+	hash, err := hex.DecodeString(strings.TrimSpace(settings.GetString("sha256")))
+	if err != nil || len(hash) != sha256.Size {
+		return APIToken{}, fmt.Errorf("invalid API token %s: sha256 must be the hex SHA-256 of the token", name)
+	}
+	copy(token.sha256[:], hash)
+	if len(token.Scopes) == 0 {
+		return APIToken{}, fmt.Errorf("invalid API token %s: no scopes", name)
+	}
+	for _, scope := range token.Scopes {
+		if !slices.Contains(apiScopes, scope) {
+			return APIToken{}, fmt.Errorf("invalid API token %s: unknown scope %q (supported: %s)", name, scope, strings.Join(apiScopes, ", "))
+		}
+	}
+	return token, nil
+}
+
+// authenticate returns the token presented by the bearer credentials of r, or
+// nil. Every token is compared so the response time does not tell which one
+// came close.
+func authenticate(tokens []APIToken, r *http.Request) *APIToken {
+	given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return nil
+	}
+	sum := sha256.Sum256([]byte(given))
+	var match *APIToken
+	for i := range tokens {
+		if subtle.ConstantTimeCompare(sum[:], tokens[i].sha256[:]) == 1 {
+			match = &tokens[i]
+		}
+	}
+	return match
+}
+
+// jobScope is the scope needed to queue or cancel a job of kind.
+func jobScope(kind string) string {
+	if kind == JobKindRestore {
+		return ScopeRestoreExecute
+	}
+	return ScopeBackupCreate
+}
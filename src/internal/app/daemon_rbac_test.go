@@ -0,0 +1,50 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAPITokens(t *testing.T) {
+	sum := sha256.Sum256([]byte("op"))
+	hash := hex.EncodeToString(sum[:])
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{name: "none", config: "backup-dir: /backups"},
+		{name: "valid", config: "api-tokens:\n  portal-l1:\n    sha256: " + hash + "\n    scopes: [backup:create, catalog:read]"},
+		{name: "not a map", config: "api-tokens: [portal-l1]", wantErr: "expected token names mapped"},
+		{name: "bad hash", config: "api-tokens:\n  portal-l1:\n    sha256: op\n    scopes: [catalog:read]", wantErr: "hex SHA-256"},
+		{name: "no scopes", config: "api-tokens:\n  portal-l1:\n    sha256: " + hash, wantErr: "no scopes"},
+		{name: "unknown scope", config: "api-tokens:\n  portal-l1:\n    sha256: " + hash + "\n    scopes: [restore:*]", wantErr: `unknown scope "restore:*"`},
+		{name: "reserved name", config: "api-tokens:\n  jobs-token:\n    sha256: " + hash + "\n    scopes: [catalog:read]", wantErr: "reserved"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iops := newTargetGroupsOps(t, tt.config, newFakeExecutor())
+			tokens, err := iops.APITokens()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("APITokens() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("APITokens() error = %v", err)
+			}
+			if tt.name == "valid" {
+				request := httptest.NewRequest("GET", "/jobs", nil)
+				request.Header.Set("Authorization", "Bearer op")
+				token := authenticate(tokens, request)
+				if token == nil || token.Name != "portal-l1" || !token.allows(ScopeBackupCreate) || token.allows(ScopeRestoreExecute) {
+					t.Errorf("authenticate() = %+v", token)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDaemonHealthz(t *testing.T) {
+	now := time.Now()
+	state := newDaemonState(now.Add(-time.Hour))
+	state.heartbeat = now
+	state.queued(1)
+	run := state.start(now.Add(-2 * time.Minute))
+	state.finish(run, now.Add(-time.Minute), errors.New("neo4j unavailable"))
+
+	handler := newDaemonHandler(state)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("/healthz status = %d, want 200 (a failed backup must not fail liveness)", rec.Code)
+	}
+	var health daemonHealth
+	if err := json.Unmarshal(rec.Body.Bytes(), &health); err != nil {
+		t.Fatalf("decode /healthz: %v", err)
+	}
+	if health.LastBackupStatus != "failure" || health.LastBackupError != "neo4j unavailable" || health.LastBackupAgeSeconds == nil {
+		t.Errorf("unexpected health body: %+v", health)
+	}
+
+	state.heartbeat = now.Add(-4 * daemonHeartbeatInterval)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("/healthz status with stalled scheduler = %d, want 503", rec.Code)
+	}
+}
+
+func TestDaemonMetrics(t *testing.T) {
+	now := time.Now()
+	state := newDaemonState(now)
+	state.queued(1)
+	run := state.start(now)
+	state.finish(run, now.Add(90*time.Second), nil)
+	state.queued(1)
+
+	rec := httptest.NewRecorder()
+	newDaemonHandler(state).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		"infrahub_backup_daemon_pending_jobs 1\n",
+		"infrahub_backup_daemon_running_jobs 0\n",
+		"infrahub_backup_last_run_success 1\n",
+		"infrahub_backup_last_run_duration_seconds 90\n",
+		`infrahub_backup_runs_total{status="success"} 1`,
+		`infrahub_backup_runs_total{status="failure"} 0`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/metrics missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestDaemonVerificationMetrics(t *testing.T) {
+	now := time.Now()
+	state := newDaemonState(now)
+	state.recordVerification(now, nil)
+	state.recordVerification(now, nil)
+	state.recordVerification(now, errors.New("checksum mismatch"))
+
+	rec := httptest.NewRecorder()
+	newDaemonHandler(state).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE infrahub_backup_verified_total counter\ninfrahub_backup_verified_total 2\n",
+		"# TYPE infrahub_backup_verification_failures_total counter\ninfrahub_backup_verification_failures_total 1\n",
+		"infrahub_backup_last_verification_timestamp_seconds ",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/metrics missing %q:\n%s", want, body)
+		}
+	}
+}
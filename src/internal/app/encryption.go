@@ -87,6 +87,21 @@ func LoadPublicKeyFromFile(path string) (*ecdh.PublicKey, error) {
 
 // LoadPrivateKeyFromFile reads a PEM-encoded PKCS8 EC private key and returns an ECDH private key.
 func LoadPrivateKeyFromFile(path string) (*ecdh.PrivateKey, error) {
+	ecdsaKey, err := loadECDSAPrivateKey(path)
+	if err != nil {
+		return nil, err
+	}
+
+	ecdhKey, err := ecdsaKey.ECDH()
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert ECDSA key to ECDH: %w", err)
+	}
+
+	return ecdhKey, nil
+}
+
+// loadECDSAPrivateKey reads a PEM-encoded PKCS8 EC private key, as written by keygen.
+func loadECDSAPrivateKey(path string) (*ecdsa.PrivateKey, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read private key file: %w", err)
@@ -106,13 +121,25 @@ func LoadPrivateKeyFromFile(path string) (*ecdh.PrivateKey, error) {
 	if !ok {
 		return nil, fmt.Errorf("private key is not an EC key (got %T)", key)
 	}
+	return ecdsaKey, nil
+}
 
-	ecdhKey, err := ecdsaKey.ECDH()
+// loadECDSAPublicKey reads a base64 P-256 public key, as written by keygen,
+// for checking signatures.
+func loadECDSAPublicKey(path string) (*ecdsa.PublicKey, error) {
+	raw, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to convert ECDSA key to ECDH: %w", err)
+		return nil, fmt.Errorf("failed to read verification key: %w", err)
 	}
-
-	return ecdhKey, nil
+	point, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(raw)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 verification key: %w", err)
+	}
+	publicKey, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), point)
+	if err != nil {
+		return nil, fmt.Errorf("invalid verification key: %w", err)
+	}
+	return publicKey, nil
 }
 
 // GenerateKeyPair generates a new P-256 ECDH keypair.
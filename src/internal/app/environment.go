@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"errors"
 	"io"
 	"sort"
@@ -15,6 +16,21 @@ type ExecOptions struct {
 	Env  map[string]string
 }
 
+// LogOptions selects which log lines a backend returns.
+type LogOptions struct {
+	Follow bool   // keep streaming new lines until the context is cancelled
+	Tail   int    // number of trailing lines per container; 0 or less returns all
+	Since  string // only lines newer than this duration or timestamp (e.g. 10m)
+}
+
+// LogStream is the log output of one container. Wait returns the exit status
+// of the underlying command once Reader is drained.
+type LogStream struct {
+	Name   string
+	Reader io.ReadCloser
+	Wait   func() error
+}
+
 type EnvironmentBackend interface {
 	Name() string
 	Detect() error
@@ -28,6 +44,11 @@ type EnvironmentBackend interface {
 	Start(services ...string) error
 	Stop(services ...string) error
 	IsRunning(service string) (bool, error)
+	// RunningServices reports the running state of several services with a
+	// single status query.
+	RunningServices(services ...string) (map[string]bool, error)
+	// Logs starts streaming the logs of every container of service.
+	Logs(ctx context.Context, service string, opts LogOptions) ([]LogStream, error)
 }
 
 // Shared utility functions
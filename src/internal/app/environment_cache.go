@@ -0,0 +1,207 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// defaultDetectionCacheTTL bounds how long a detected environment is reused
+// before docker compose / kubectl are queried again.
+const defaultDetectionCacheTTL = 10 * time.Minute
+
+// detectionCacheEntry records the environment resolved for one invocation scope.
+type detectionCacheEntry struct {
+	Backend    string    `json:"backend"`
+	Target     string    `json:"target"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// detectionCachePath returns the cache file location under the user cache
+// directory (~/.cache on Linux).
+func detectionCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "infrahub-ops", "detection.json"), nil
+}
+
+// detectionCacheKey scopes cache entries to the explicitly requested target so
+// a cached auto-detection never overrides --project or --k8s-namespace. The
+// cluster, the Docker host and the working directory are part of the scope,
+// so switching any of them runs a new detection.
+func (iops *InfrahubOps) detectionCacheKey() string {
+	cfg := iops.config
+	key := fmt.Sprintf("docker=%s;k8s=%s", cfg.DockerComposeProject, cfg.K8sNamespace)
+	if cfg.K8sReleaseName != "" {
+		key += ";release=" + cfg.K8sReleaseName
+	}
+	key += ";" + iops.environmentLocation("kubernetes") + ";" + iops.environmentLocation("docker")
+	if dir, err := os.Getwd(); err == nil {
+		key += ";cwd=" + dir
+	}
+	return key
+}
+
+// kubeContext returns the kubeconfig context kubectl runs against: the
+// kube-context of a configured target, or the current context.
+func (iops *InfrahubOps) kubeContext() string {
+	if executor, ok := iops.executor.(*kubeContextExecutor); ok {
+		return executor.context
+	}
+	return iops.getKubernetesBackend().currentContext()
+}
+
+// environmentLocation describes where the commands of backend run: the
+// kubeconfig and context for kubernetes, the Docker host and context for
+// docker. Caches keyed by a project or namespace add it, since the same name
+// can exist on several clusters or hosts.
+func (iops *InfrahubOps) environmentLocation(backend string) string {
+	switch backend {
+	case "kubernetes":
+		return fmt.Sprintf("kubeconfig=%s;context=%s", os.Getenv("KUBECONFIG"), iops.kubeContext())
+	case "docker":
+		return fmt.Sprintf("docker_host=%s;docker_context=%s", os.Getenv("DOCKER_HOST"), os.Getenv("DOCKER_CONTEXT"))
+	}
+	return ""
+}
+
+func readDetectionCache() map[string]detectionCacheEntry {
+	entries := map[string]detectionCacheEntry{}
+	path, err := detectionCachePath()
+	if err != nil {
+		return entries
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return entries
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		logrus.Debugf("Ignoring unreadable detection cache %s: %v", path, err)
+		return map[string]detectionCacheEntry{}
+	}
+	return entries
+}
+
+func writeDetectionCache(entries map[string]detectionCacheEntry) error {
+	path, err := detectionCachePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// lookupDetectionCache returns the cached environment for the scope key if it
+// is younger than the configured TTL.
+func (iops *InfrahubOps) lookupDetectionCache(key string) (detectionCacheEntry, bool) {
+	if iops.config.DetectCacheTTL <= 0 {
+		return detectionCacheEntry{}, false
+	}
+	entry, ok := readDetectionCache()[key]
+	if !ok || entry.Target == "" || time.Since(entry.DetectedAt) > iops.config.DetectCacheTTL {
+		return detectionCacheEntry{}, false
+	}
+	return entry, true
+}
+
+// storeDetectionCache records a successful detection for the scope captured
+// before detection ran (detection fills in the configured target).
+func (iops *InfrahubOps) storeDetectionCache(key string, backend EnvironmentBackend) {
+	if iops.config.DetectCacheTTL <= 0 {
+		return
+	}
+	entries := readDetectionCache()
+	entries[key] = detectionCacheEntry{
+		Backend:    backend.Name(),
+		Target:     backend.Info(),
+		DetectedAt: time.Now().UTC(),
+	}
+	if err := writeDetectionCache(entries); err != nil {
+		logrus.Debugf("Could not write detection cache: %v", err)
+	}
+}
+
+// ClearDetectionCache forgets the cached environment for the current scope so
+// the next command runs a full detection.
+func (iops *InfrahubOps) ClearDetectionCache() {
+	entries := readDetectionCache()
+	key := iops.detectionCacheKey()
+	if _, ok := entries[key]; !ok {
+		return
+	}
+	delete(entries, key)
+	if err := writeDetectionCache(entries); err != nil {
+		logrus.Debugf("Could not write detection cache: %v", err)
+	}
+}
+
+// verifyCachedBackend checks that the cached target still exists by running
+// the detection of its backend with the target set explicitly, which also
+// selects the release again. The configured target is left unchanged when the
+// check fails.
+func (iops *InfrahubOps) verifyCachedBackend(cached detectionCacheEntry) (EnvironmentBackend, error) {
+	var backend EnvironmentBackend
+	var target *string
+	switch cached.Backend {
+	case "docker":
+		backend, target = iops.getDockerBackend(), &iops.config.DockerComposeProject
+	case "kubernetes":
+		backend, target = iops.getKubernetesBackend(), &iops.config.K8sNamespace
+	default:
+		return nil, fmt.Errorf("unknown environment backend: %s", cached.Backend)
+	}
+	previous := *target
+	*target = cached.Target
+	if err := backend.Detect(); err != nil {
+		*target = previous
+		return nil, err
+	}
+	return backend, nil
+}
+
+// assumeBackend binds a backend to a known target without running detection.
+func (iops *InfrahubOps) assumeBackend(name, target string) (EnvironmentBackend, error) {
+	switch name {
+	case "docker":
+		backend := iops.getDockerBackend()
+		backend.project = target
+		iops.config.DockerComposeProject = target
+		return backend, nil
+	case "kubernetes":
+		backend := iops.getKubernetesBackend()
+		backend.namespace = target
+		iops.config.K8sNamespace = target
+		return backend, nil
+	default:
+		return nil, fmt.Errorf("unknown environment backend: %s", name)
+	}
+}
+
+// explicitBackend resolves the backend for --no-detect from the explicitly
+// configured target, preferring Kubernetes as backendOrder does.
+func (iops *InfrahubOps) explicitBackend() (EnvironmentBackend, error) {
+	switch {
+	case iops.config.K8sNamespace != "":
+		return iops.assumeBackend("kubernetes", iops.config.K8sNamespace)
+	case iops.config.DockerComposeProject != "":
+		return iops.assumeBackend("docker", iops.config.DockerComposeProject)
+	default:
+		return nil, fmt.Errorf("--no-detect requires --project or --k8s-namespace")
+	}
+}
@@ -0,0 +1,159 @@
+package app
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDetectionCache(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	iops := NewInfrahubOpsWithExecutor(newFakeExecutor())
+	key := iops.detectionCacheKey()
+	if _, ok := iops.lookupDetectionCache(key); ok {
+		t.Fatal("expected empty detection cache")
+	}
+
+	iops.storeDetectionCache(key, &DockerBackend{project: "infrahub-prod"})
+
+	entry, ok := iops.lookupDetectionCache(key)
+	if !ok {
+		t.Fatal("expected cached detection after store")
+	}
+	if entry.Backend != "docker" || entry.Target != "infrahub-prod" {
+		t.Errorf("cached entry = %+v, want docker/infrahub-prod", entry)
+	}
+
+	// An explicit target uses its own scope and must not reuse the auto-detected entry.
+	scoped := NewInfrahubOpsWithExecutor(newFakeExecutor())
+	scoped.config.DockerComposeProject = "infrahub-staging"
+	if _, ok := scoped.lookupDetectionCache(scoped.detectionCacheKey()); ok {
+		t.Error("explicit --project should not hit the auto-detection cache entry")
+	}
+
+	entries := readDetectionCache()
+	stale := entries[key]
+	stale.DetectedAt = time.Now().Add(-2 * defaultDetectionCacheTTL)
+	entries[key] = stale
+	if err := writeDetectionCache(entries); err != nil {
+		t.Fatalf("writeDetectionCache: %v", err)
+	}
+	if _, ok := iops.lookupDetectionCache(key); ok {
+		t.Error("expected stale entry to be ignored")
+	}
+
+	iops.config.DetectCacheTTL = 0
+	iops.storeDetectionCache(key, &DockerBackend{project: "other"})
+	if readDetectionCache()[key].Target != "infrahub-prod" {
+		t.Error("cache should not be written when the TTL is 0")
+	}
+
+	iops.config.DetectCacheTTL = defaultDetectionCacheTTL
+	iops.ClearDetectionCache()
+	if _, ok := readDetectionCache()[key]; ok {
+		t.Error("ClearDetectionCache did not remove the entry")
+	}
+}
+
+func TestDetectionCacheKeyLocation(t *testing.T) {
+	t.Setenv("KUBECONFIG", "")
+	t.Setenv("DOCKER_HOST", "")
+	t.Setenv("DOCKER_CONTEXT", "")
+	keyFor := func(context string) string {
+		fake := newFakeExecutor().on("config current-context", context+"\n", nil)
+		return NewInfrahubOpsWithExecutor(fake).detectionCacheKey()
+	}
+
+	base := keyFor("cluster-a")
+	if other := keyFor("cluster-b"); other == base {
+		t.Error("another kube context shares the detection cache entry")
+	}
+	t.Setenv("KUBECONFIG", "/etc/kube/other.yaml")
+	if other := keyFor("cluster-a"); other == base {
+		t.Error("another kubeconfig shares the detection cache entry")
+	}
+	t.Setenv("KUBECONFIG", "")
+	t.Setenv("DOCKER_HOST", "ssh://backup@host-b")
+	if other := keyFor("cluster-a"); other == base {
+		t.Error("another Docker host shares the detection cache entry")
+	}
+	t.Setenv("DOCKER_HOST", "")
+	t.Chdir(t.TempDir())
+	if other := keyFor("cluster-a"); other == base {
+		t.Error("another working directory shares the detection cache entry")
+	}
+}
+
+func TestSelectBackendVerifiesCachedTarget(t *testing.T) {
+	tests := []struct {
+		name        string
+		cached      string
+		wantProject string
+	}{
+		{name: "still running", cached: "infrahub", wantProject: "infrahub"},
+		{name: "gone", cached: "removed", wantProject: "infrahub"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_CACHE_HOME", t.TempDir())
+			fake := newFakeExecutor().
+				on("compose ls", "NAME STATUS\ninfrahub running(3)\n", nil).
+				on("-p infrahub ps", "infrahub-infrahub-server-1 running", nil).
+				on("-p removed ps", "", errors.New("no such project")).
+				on("kubectl", "", errors.New("kubectl not found"))
+			iops := NewInfrahubOpsWithExecutor(fake)
+			iops.config.K8sNamespace = ""
+			key := iops.detectionCacheKey()
+			iops.storeDetectionCache(key, &DockerBackend{project: tt.cached})
+
+			backend, err := iops.selectBackend()
+			if err != nil {
+				t.Fatalf("selectBackend() error = %v", err)
+			}
+			if backend.Info() != tt.wantProject || iops.config.DockerComposeProject != tt.wantProject {
+				t.Errorf("selectBackend() = %s, project %q; want %s", backend.Info(), iops.config.DockerComposeProject, tt.wantProject)
+			}
+			if got := fake.commands("compose ls"); len(got) == 0 {
+				t.Error("cached target used without checking it still exists")
+			}
+			if entry, _ := iops.lookupDetectionCache(key); entry.Target != tt.wantProject {
+				t.Errorf("cached target = %q, want %q", entry.Target, tt.wantProject)
+			}
+		})
+	}
+}
+
+func TestSelectBackendChecksReleaseOfCachedNamespace(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	fake := newFakeExecutor().
+		on("docker", "", errors.New("docker not found")).
+		on("jsonpath={range .items[*]}", "acme\n", nil)
+	iops := NewInfrahubOpsWithExecutor(fake)
+	iops.config.K8sNamespace = ""
+	iops.config.K8sReleaseName = "globex"
+	iops.storeDetectionCache(iops.detectionCacheKey(), &KubernetesBackend{namespace: "infrahub"})
+
+	if _, err := iops.selectBackend(); err == nil || !strings.Contains(err.Error(), "release globex not found") {
+		t.Errorf("selectBackend() error = %v, want the missing release", err)
+	}
+}
+
+func TestExplicitBackendRequiresTarget(t *testing.T) {
+	iops := NewInfrahubOps()
+	iops.config.K8sNamespace = ""
+	if _, err := iops.explicitBackend(); err == nil {
+		t.Fatal("expected error without --project or --k8s-namespace")
+	}
+
+	iops.config.DockerComposeProject = "infrahub-prod"
+	backend, err := iops.explicitBackend()
+	if err != nil {
+		t.Fatalf("explicitBackend: %v", err)
+	}
+	if backend.Name() != "docker" || backend.Info() != "infrahub-prod" {
+		t.Errorf("explicitBackend = %s/%s, want docker/infrahub-prod", backend.Name(), backend.Info())
+	}
+}
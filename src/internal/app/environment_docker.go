@@ -1,19 +1,22 @@
 package app
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"sort"
+	"strconv"
 	"strings"
 )
 
 type DockerBackend struct {
 	config   *Configuration
-	executor *CommandExecutor
+	executor CommandExecutor
 	project  string
 }
 
-func NewDockerBackend(config *Configuration, executor *CommandExecutor) *DockerBackend {
+func NewDockerBackend(config *Configuration, executor CommandExecutor) *DockerBackend {
 	return &DockerBackend{config: config, executor: executor}
 }
 
@@ -59,7 +62,16 @@ func (d *DockerBackend) Detect() error {
 		d.config.DockerComposeProject = d.project
 		return nil
 	default:
-		return fmt.Errorf("multiple docker compose projects found: %s (specify --project)", strings.Join(projects, ", "))
+		project, ok, err := pickTerminalTarget("docker compose project", projects)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("multiple docker compose projects found: %s (specify --project)", strings.Join(projects, ", "))
+		}
+		d.project = project
+		d.config.DockerComposeProject = d.project
+		return nil
 	}
 }
 
@@ -72,6 +84,51 @@ func (d *DockerBackend) composeArgs(args ...string) []string {
 	return cmd
 }
 
+// composeProjectFiles returns the project directory and compose files
+// recorded in the labels of the project's containers.
+func (d *DockerBackend) composeProjectFiles() (string, []string, error) {
+	output, err := d.executor.runCommand("docker", "ps", "-a",
+		"--filter", "label=com.docker.compose.project="+d.project,
+		"--format", `{{.Label "com.docker.compose.project.working_dir"}}|{{.Label "com.docker.compose.project.config_files"}}`)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read compose labels of project %s: %w", d.project, err)
+	}
+	for _, line := range nonEmptyLines(output) {
+		workingDir, files, _ := strings.Cut(line, "|")
+		if files != "" {
+			return workingDir, strings.Split(files, ","), nil
+		}
+	}
+	return "", nil, fmt.Errorf("no container of project %s records its compose files", d.project)
+}
+
+// composeFileArgs returns the --project-directory and -f arguments of the
+// project. Commands that read the compose configuration, such as config and
+// up, need them outside the project directory.
+func (d *DockerBackend) composeFileArgs() ([]string, error) {
+	workingDir, files, err := d.composeProjectFiles()
+	if err != nil {
+		return nil, err
+	}
+	args := []string{}
+	if workingDir != "" {
+		args = append(args, "--project-directory", workingDir)
+	}
+	for _, file := range files {
+		args = append(args, "-f", file)
+	}
+	return args, nil
+}
+
+// composeConfigArgs is composeArgs for commands that read the compose files.
+func (d *DockerBackend) composeConfigArgs(args ...string) ([]string, error) {
+	fileArgs, err := d.composeFileArgs()
+	if err != nil {
+		return nil, err
+	}
+	return d.composeArgs(append(fileArgs, args...)...), nil
+}
+
 // buildExecArgs constructs the docker compose exec arguments for a service command.
 func (d *DockerBackend) buildExecArgs(service string, command []string, opts *ExecOptions) []string {
 	args := []string{"exec", "-T"}
@@ -129,36 +186,142 @@ func (d *DockerBackend) CopyFrom(service, src, dest string) error {
 	return nil
 }
 
+// Start starts services. Services stopped with the down strategy have no
+// container left, so they are recreated with up instead.
 func (d *DockerBackend) Start(services ...string) error {
-	if len(services) == 0 {
-		return nil
+	groups, err := d.config.groupByStopStrategy(d.Name(), services)
+	if err != nil {
+		return err
 	}
-	args := append([]string{"start"}, services...)
-	cmd := d.composeArgs(args...)
-	_, err := d.executor.runCommand("docker", cmd...)
-	return err
+	if err := d.compose("start", groups[StopStrategyStop]); err != nil {
+		return err
+	}
+	return d.compose("up -d --no-deps", groups[StopStrategyDown])
 }
 
+// Stop stops services with compose stop, or removes their containers with
+// compose down for the down strategy. Volumes are kept either way.
 func (d *DockerBackend) Stop(services ...string) error {
+	groups, err := d.config.groupByStopStrategy(d.Name(), services)
+	if err != nil {
+		return err
+	}
+	if err := d.compose("stop", groups[StopStrategyStop]); err != nil {
+		return err
+	}
+	return d.compose("down", groups[StopStrategyDown])
+}
+
+// compose runs a compose subcommand, given with its flags, on services.
+func (d *DockerBackend) compose(subcommand string, services []string) error {
 	if len(services) == 0 {
 		return nil
 	}
-	args := append([]string{"stop"}, services...)
-	cmd := d.composeArgs(args...)
-	_, err := d.executor.runCommand("docker", cmd...)
+	args := append(strings.Fields(subcommand), services...)
+	_, err := d.executor.runCommand("docker", d.composeArgs(args...)...)
 	return err
 }
 
 func (d *DockerBackend) IsRunning(service string) (bool, error) {
-	cmd := d.composeArgs("ps", service)
-	output, err := d.executor.runCommand("docker", cmd...)
+	running, err := d.RunningServices(service)
 	if err != nil {
 		return false, err
 	}
-	return strings.Contains(output, "Up"), nil
+	return running[service], nil
+}
+
+// RunningServices lists every container of the project with one
+// `docker compose ps --format json` call.
+func (d *DockerBackend) RunningServices(services ...string) (map[string]bool, error) {
+	cmd := d.composeArgs("ps", "-a", "--format", "json")
+	output, err := d.executor.runCommand("docker", cmd...)
+	if err != nil {
+		return nil, err
+	}
+	states, err := parseComposePSJSON(output)
+	if err != nil {
+		return nil, err
+	}
+
+	running := make(map[string]bool, len(services))
+	for _, service := range services {
+		running[service] = states[service]
+	}
+	return running, nil
+}
+
+// Logs streams the logs of service without compose's own prefix; the caller
+// adds a uniform one.
+func (d *DockerBackend) Logs(ctx context.Context, service string, opts LogOptions) ([]LogStream, error) {
+	reader, wait, err := d.executor.runCommandPipeContext(ctx, "docker", d.composeArgs(dockerLogsArgs(service, opts)...)...)
+	if err != nil {
+		return nil, err
+	}
+	return []LogStream{{Name: service, Reader: reader, Wait: wait}}, nil
+}
+
+func dockerLogsArgs(service string, opts LogOptions) []string {
+	args := []string{"logs", "--no-log-prefix", "--no-color"}
+	if opts.Follow {
+		args = append(args, "--follow")
+	}
+	if opts.Tail > 0 {
+		args = append(args, "--tail", strconv.Itoa(opts.Tail))
+	}
+	if opts.Since != "" {
+		args = append(args, "--since", opts.Since)
+	}
+	return append(args, service)
+}
+
+// composePSEntry is the subset of `docker compose ps --format json` we use.
+type composePSEntry struct {
+	Service string `json:"Service"`
+	State   string `json:"State"`
+	Health  string `json:"Health"` // healthy, unhealthy or starting; empty without a healthcheck
+}
+
+// parseComposePSJSON maps service names to whether any of their containers is
+// running.
+func parseComposePSJSON(output string) (map[string]bool, error) {
+	entries, err := parseComposePSEntries(output)
+	if err != nil {
+		return nil, err
+	}
+	states := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		if entry.Service == "" {
+			continue
+		}
+		states[entry.Service] = states[entry.Service] || strings.EqualFold(entry.State, "running")
+	}
+	return states, nil
+}
+
+// parseComposePSEntries reads `docker compose ps --format json`. Compose
+// prints a JSON array before v2.21 and one object per line since; both are
+// accepted.
+func parseComposePSEntries(output string) ([]composePSEntry, error) {
+	output = strings.TrimSpace(output)
+	entries := []composePSEntry{}
+
+	if strings.HasPrefix(output, "[") {
+		if err := json.Unmarshal([]byte(output), &entries); err != nil {
+			return nil, fmt.Errorf("failed to parse docker compose ps output: %w", err)
+		}
+	} else {
+		for _, line := range nonEmptyLines(output) {
+			var entry composePSEntry
+			if err := json.Unmarshal([]byte(line), &entry); err != nil {
+				return nil, fmt.Errorf("failed to parse docker compose ps output: %w", err)
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
 }
 
-func ListDockerProjects(executor *CommandExecutor) ([]string, error) {
+func ListDockerProjects(executor CommandExecutor) ([]string, error) {
 	output, err := executor.runCommand("docker", "compose", "ls")
 	if err != nil {
 		return nil, fmt.Errorf("failed to list docker compose projects: %w", err)
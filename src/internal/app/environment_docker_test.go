@@ -0,0 +1,53 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseComposePSJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   map[string]bool
+	}{
+		{
+			name:   "json array (compose < 2.21)",
+			output: `[{"Service":"database","State":"running"},{"Service":"task-worker","State":"exited"}]`,
+			want:   map[string]bool{"database": true, "task-worker": false},
+		},
+		{
+			name: "one object per line",
+			output: `{"Service":"infrahub-server","State":"running","Status":"Up 2 minutes"}
+{"Service":"cache","State":"exited","Status":"Exited (0)"}`,
+			want: map[string]bool{"infrahub-server": true, "cache": false},
+		},
+		{
+			name: "any running replica marks the service running",
+			output: `{"Service":"task-worker","State":"exited"}
+{"Service":"task-worker","State":"running"}`,
+			want: map[string]bool{"task-worker": true},
+		},
+		{
+			name:   "no containers",
+			output: "",
+			want:   map[string]bool{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseComposePSJSON(tt.output)
+			if err != nil {
+				t.Fatalf("parseComposePSJSON: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseComposePSJSON() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := parseComposePSJSON("not json"); err == nil {
+		t.Error("expected error for invalid output")
+	}
+}
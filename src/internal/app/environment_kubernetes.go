@@ -1,23 +1,35 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	// infrahubPodSelector matches the pods of every Infrahub Helm release.
+	infrahubPodSelector = "app.kubernetes.io/name=infrahub"
+	// defaultInstanceLabel is the pod label Helm sets to the release name.
+	defaultInstanceLabel = "app.kubernetes.io/instance"
+)
+
 type KubernetesBackend struct {
-	config       *Configuration
-	executor     *CommandExecutor
-	namespace    string
-	podCache     map[string]string
-	replicaCache map[string]int // stores original replica counts before stopping
+	config         *Configuration
+	executor       CommandExecutor
+	namespace      string
+	mu             sync.Mutex // guards the caches for concurrent stop/start
+	podCache       map[string]string
+	containerCache map[string]string // container to exec into, by pod
+	replicaCache   map[string]int    // stores original replica counts before stopping
 }
 
-func NewKubernetesBackend(config *Configuration, executor *CommandExecutor) *KubernetesBackend {
+func NewKubernetesBackend(config *Configuration, executor CommandExecutor) *KubernetesBackend {
 	return &KubernetesBackend{
 		config:       config,
 		executor:     executor,
@@ -44,15 +56,20 @@ func (k *KubernetesBackend) Detect() error {
 		return fmt.Errorf("kubectl CLI not available: %w", ErrCLIUnavailable)
 	}
 
+	selector := infrahubPodSelector
+	if instance := k.instanceSelector(); instance != "" {
+		selector += "," + instance
+	}
+
 	if k.config.K8sNamespace != "" {
 		k.namespace = k.config.K8sNamespace
 		if _, err := k.executor.runCommand("kubectl", "get", "pods", "-n", k.namespace, "-l", "app.kubernetes.io/name=infrahub"); err != nil {
 			return fmt.Errorf("failed to verify namespace %s: %w", k.namespace, err)
 		}
-		return nil
+		return k.checkRelease()
 	}
 
-	namespaces, err := ListKubernetesNamespaces(k.executor)
+	namespaces, err := listKubernetesNamespaces(k.executor, selector)
 	if err != nil {
 		return err
 	}
@@ -63,12 +80,45 @@ func (k *KubernetesBackend) Detect() error {
 	case 1:
 		k.namespace = namespaces[0]
 		k.config.K8sNamespace = k.namespace
-		return nil
+		return k.checkRelease()
 	default:
-		return fmt.Errorf("multiple kubernetes namespaces found: %s (set INFRAHUB_K8S_NAMESPACE)", strings.Join(namespaces, ", "))
+		namespace, ok, err := pickTerminalTarget("kubernetes namespace", namespaces)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("multiple kubernetes namespaces found: %s (set INFRAHUB_K8S_NAMESPACE)", strings.Join(namespaces, ", "))
+		}
+		k.namespace = namespace
+		k.config.K8sNamespace = k.namespace
+		return k.checkRelease()
 	}
 }
 
+// checkRelease makes sure the namespace holds the release set with
+// --release-name, or a single release when none is set. Pods without the
+// instance label are not counted.
+func (k *KubernetesBackend) checkRelease() error {
+	releases, err := k.listReleases()
+	if err != nil {
+		logrus.Debugf("Could not list Helm releases in namespace %s: %v", k.namespace, err)
+		return nil
+	}
+
+	release := k.config.K8sReleaseName
+	if release == "" {
+		if len(releases) > 1 {
+			return fmt.Errorf("multiple Infrahub releases found in namespace %s: %s (set --release-name)", k.namespace, strings.Join(releases, ", "))
+		}
+		return nil
+	}
+	if !slices.Contains(releases, release) {
+		return fmt.Errorf("release %s not found in namespace %s (%s=%s matches no Infrahub pod)", release, k.namespace, k.instanceLabel(), release)
+	}
+	logrus.Infof("Using Helm release %s", release)
+	return nil
+}
+
 // buildExecArgs resolves the pod and constructs kubectl exec arguments.
 func (k *KubernetesBackend) buildExecArgs(service string, command []string, opts *ExecOptions) ([]string, error) {
 	pod, err := k.getPodForService(service)
@@ -76,7 +126,9 @@ func (k *KubernetesBackend) buildExecArgs(service string, command []string, opts
 		return nil, err
 	}
 	finalCmd := k.prepareCommand(command, opts)
-	args := []string{"exec", "-n", k.namespace, pod, "--"}
+	args := []string{"exec", "-n", k.namespaceFor(service), pod}
+	args = append(args, k.containerArgs(service, pod)...)
+	args = append(args, "--")
 	args = append(args, finalCmd...)
 	return args, nil
 }
@@ -111,7 +163,9 @@ func (k *KubernetesBackend) ExecWritePipe(service string, command []string, opts
 		return nil, err
 	}
 	finalCmd := k.prepareCommand(command, opts)
-	args := []string{"exec", "-i", "-n", k.namespace, pod, "--"}
+	args := []string{"exec", "-i", "-n", k.namespaceFor(service), pod}
+	args = append(args, k.containerArgs(service, pod)...)
+	args = append(args, "--")
 	args = append(args, finalCmd...)
 	return k.executor.runCommandWritePipe(stdin, "kubectl", args...)
 }
@@ -121,8 +175,8 @@ func (k *KubernetesBackend) CopyTo(service, src, dest string) error {
 	if err != nil {
 		return err
 	}
-	target := fmt.Sprintf("%s/%s:%s", k.namespace, pod, dest)
-	if _, err := k.executor.runCommand("kubectl", "cp", src, target); err != nil {
+	target := fmt.Sprintf("%s/%s:%s", k.namespaceFor(service), pod, dest)
+	if _, err := k.executor.runCommand("kubectl", k.copyArgs(service, pod, src, target)...); err != nil {
 		return err
 	}
 	return nil
@@ -133,43 +187,72 @@ func (k *KubernetesBackend) CopyFrom(service, src, dest string) error {
 	if err != nil {
 		return err
 	}
-	source := fmt.Sprintf("%s/%s:%s", k.namespace, pod, src)
-	if _, err := k.executor.runCommand("kubectl", "cp", source, dest); err != nil {
+	source := fmt.Sprintf("%s/%s:%s", k.namespaceFor(service), pod, src)
+	if _, err := k.executor.runCommand("kubectl", k.copyArgs(service, pod, source, dest)...); err != nil {
 		return err
 	}
 	return nil
 }
 
+// copyArgs builds the kubectl cp arguments for a file in pod.
+func (k *KubernetesBackend) copyArgs(service, pod, src, dest string) []string {
+	return append([]string{"cp", src, dest}, k.containerArgs(service, pod)...)
+}
+
+// Start scales the workloads of services back up. Pods deleted by the delete
+// strategy are recreated by their controller, so those are only waited for.
 func (k *KubernetesBackend) Start(services ...string) error {
-	for _, service := range services {
+	groups, err := k.config.groupByStopStrategy(k.Name(), services)
+	if err != nil {
+		return err
+	}
+	for _, service := range groups[StopStrategyScale] {
 		kind, resource, err := k.findWorkloadResource(service)
 		if err != nil {
 			return fmt.Errorf("failed to resolve workload for %s: %w", service, err)
 		}
-		cacheKey := fmt.Sprintf("%s/%s", kind, resource)
+		namespace := k.namespaceFor(service)
+		cacheKey := fmt.Sprintf("%s/%s/%s", namespace, kind, resource)
 		replicas := 1 // default
-		if savedCount, ok := k.replicaCache[cacheKey]; ok && savedCount > 0 {
+		k.mu.Lock()
+		savedCount, ok := k.replicaCache[cacheKey]
+		k.mu.Unlock()
+		if ok && savedCount > 0 {
 			replicas = savedCount
 			logrus.Debugf("Restoring replica count for %s: %d", cacheKey, replicas)
 		}
-		if err := k.scaleResource(kind, resource, replicas); err != nil {
+		if err := k.scaleResource(namespace, kind, resource, replicas); err != nil {
 			return fmt.Errorf("failed to scale %s (%s/%s) to %d replicas: %w", service, kind, resource, replicas, err)
 		}
 	}
-	k.podCache = map[string]string{}
+	k.resetPodCache()
+	k.waitUntilReady(services...)
 	return nil
 }
 
+// Stop scales the workloads of services to zero, or deletes their pods for
+// the delete strategy.
 func (k *KubernetesBackend) Stop(services ...string) error {
+	groups, err := k.config.groupByStopStrategy(k.Name(), services)
+	if err != nil {
+		return err
+	}
+	if err := k.deletePods(groups[StopStrategyDelete]); err != nil {
+		return err
+	}
+	services = groups[StopStrategyScale]
 	// Save current replica counts before stopping
 	for _, service := range services {
 		kind, resource, err := k.findWorkloadResource(service)
 		if err != nil {
 			continue
 		}
-		if count, err := k.getReplicaCount(kind, resource); err == nil && count > 0 {
-			cacheKey := fmt.Sprintf("%s/%s", kind, resource)
+		namespace := k.namespaceFor(service)
+		if count, err := k.getReplicaCount(namespace, kind, resource); err == nil && count > 0 {
+			cacheKey := fmt.Sprintf("%s/%s/%s", namespace, kind, resource)
+			k.mu.Lock()
 			k.replicaCache[cacheKey] = count
+			k.mu.Unlock()
 			logrus.Debugf("Saved replica count for %s: %d", cacheKey, count)
 		}
 	}
@@ -189,9 +272,41 @@ func (k *KubernetesBackend) IsRunning(service string) (bool, error) {
 	return false, nil
 }
 
+// RunningServices fetches every pod in the namespace once and resolves each
+// service with the same label selectors and name fallback as getPodStatuses.
+func (k *KubernetesBackend) RunningServices(services ...string) (map[string]bool, error) {
+	output, err := k.executor.runCommand("kubectl", "get", "pods", "-n", k.namespace, "-o", "json")
+	if err != nil {
+		return nil, err
+	}
+	pods, err := parseKubernetesPodList(output)
+	if err != nil {
+		return nil, err
+	}
+
+	running := make(map[string]bool, len(services))
+	for _, service := range services {
+		if namespace := k.namespaceFor(service); namespace != k.namespace {
+			isRunning, err := k.IsRunning(service)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read the status of %s in namespace %s: %w", service, namespace, err)
+			}
+			running[service] = isRunning
+			continue
+		}
+		for _, status := range k.matchPodStatuses(pods, service) {
+			if strings.EqualFold(status, "Running") {
+				running[service] = true
+				break
+			}
+		}
+	}
+	return running, nil
+}
+
 // getReplicaCount returns the current replica count for a workload
-func (k *KubernetesBackend) getReplicaCount(kind, resource string) (int, error) {
-	output, err := k.executor.runCommand("kubectl", "get", kind, resource, "-n", k.namespace, "-o", "jsonpath={.spec.replicas}")
+func (k *KubernetesBackend) getReplicaCount(namespace, kind, resource string) (int, error) {
+	output, err := k.executor.runCommand("kubectl", "get", kind, resource, "-n", namespace, "-o", "jsonpath={.spec.replicas}")
 	if err != nil {
 		return 0, err
 	}
@@ -202,10 +317,52 @@ func (k *KubernetesBackend) getReplicaCount(kind, resource string) (int, error)
 	return count, nil
 }
 
+// Logs streams the logs of every pod of service, one stream per pod named
+// service/pod.
+func (k *KubernetesBackend) Logs(ctx context.Context, service string, opts LogOptions) ([]LogStream, error) {
+	pods, err := k.GetAllPods(service)
+	if err != nil {
+		return nil, err
+	}
+
+	streams := make([]LogStream, 0, len(pods))
+	for _, pod := range pods {
+		reader, wait, err := k.executor.runCommandPipeContext(ctx, "kubectl", kubectlLogsArgs(k.namespaceFor(service), pod, opts)...)
+		if err != nil {
+			for _, stream := range streams {
+				stream.Reader.Close()
+				stream.Wait()
+			}
+			return nil, err
+		}
+		name := service
+		if len(pods) > 1 {
+			name = service + "/" + pod
+		}
+		streams = append(streams, LogStream{Name: name, Reader: reader, Wait: wait})
+	}
+	return streams, nil
+}
+
+func kubectlLogsArgs(namespace, pod string, opts LogOptions) []string {
+	args := []string{"logs", "-n", namespace, pod, "--all-containers"}
+	if opts.Follow {
+		args = append(args, "--follow")
+	}
+	if opts.Tail > 0 {
+		args = append(args, "--tail", strconv.Itoa(opts.Tail))
+	}
+	if opts.Since != "" {
+		args = append(args, "--since", opts.Since)
+	}
+	return args
+}
+
 func (k *KubernetesBackend) getPodStatuses(service string) ([]string, error) {
+	namespace := k.namespaceFor(service)
 	selectors := k.podSelectors(service)
 	for _, selector := range selectors {
-		output, err := k.executor.runCommand("kubectl", "get", "pods", "-n", k.namespace, "-l", selector, "-o", "jsonpath={range .items[*]}{.status.phase}{\"\\n\"}{end}")
+		output, err := k.executor.runCommand("kubectl", "get", "pods", "-n", namespace, "-l", selector, "-o", "jsonpath={range .items[*]}{.status.phase}{\"\\n\"}{end}")
 		if err != nil {
 			continue
 		}
@@ -215,7 +372,7 @@ func (k *KubernetesBackend) getPodStatuses(service string) ([]string, error) {
 		}
 	}
 	// Fallback to all pods search
-	output, err := k.executor.runCommand("kubectl", "get", "pods", "-n", k.namespace, "-o", "jsonpath={range .items[*]}{.metadata.name}{\";\"}{.status.phase}{\"\\n\"}{end}")
+	output, err := k.executor.runCommand("kubectl", "get", "pods", "-n", namespace, "-o", "jsonpath={range .items[*]}{.metadata.name}{\";\"}{.status.phase}{\"\\n\"}{end}")
 	if err != nil {
 		return nil, err
 	}
@@ -228,7 +385,7 @@ func (k *KubernetesBackend) getPodStatuses(service string) ([]string, error) {
 		if len(parts) != 2 {
 			continue
 		}
-		if strings.Contains(parts[0], service) {
+		if strings.Contains(parts[0], service) && k.inRelease(parts[0]) {
 			statuses = append(statuses, parts[1])
 		}
 	}
@@ -236,13 +393,14 @@ func (k *KubernetesBackend) getPodStatuses(service string) ([]string, error) {
 }
 
 func (k *KubernetesBackend) getPodForService(service string) (string, error) {
-	if pod, ok := k.podCache[service]; ok && pod != "" {
+	if pod := k.cachedPod(service); pod != "" {
 		return pod, nil
 	}
 
+	namespace := k.namespaceFor(service)
 	selectors := k.podSelectors(service)
 	for _, selector := range selectors {
-		output, err := k.executor.runCommand("kubectl", "get", "pods", "-n", k.namespace, "-l", selector, "-o", "jsonpath={range .items[*]}{.metadata.name}{\"\\n\"}{end}")
+		output, err := k.executor.runCommand("kubectl", "get", "pods", "-n", namespace, "-l", selector, "-o", "jsonpath={range .items[*]}{.metadata.name}{\"\\n\"}{end}")
 		if err != nil {
 			continue
 		}
@@ -250,35 +408,74 @@ func (k *KubernetesBackend) getPodForService(service string) (string, error) {
 		if len(pods) > 0 {
 			// If multiple pods found, try to find the primary (for HA clusters like CloudNativePG)
 			if len(pods) > 1 {
-				if primary := k.findPrimaryPod(pods); primary != "" {
-					k.podCache[service] = primary
+				if primary := k.findPrimaryPod(namespace, pods); primary != "" {
+					k.cachePod(service, primary)
 					return primary, nil
 				}
 			}
-			k.podCache[service] = pods[0]
+			k.cachePod(service, pods[0])
 			return pods[0], nil
 		}
 	}
 
-	output, err := k.executor.runCommand("kubectl", "get", "pods", "-n", k.namespace, "-o", "jsonpath={range .items[*]}{.metadata.name}{\"\\n\"}{end}")
+	output, err := k.executor.runCommand("kubectl", "get", "pods", "-n", namespace, "-o", "jsonpath={range .items[*]}{.metadata.name}{\"\\n\"}{end}")
 	if err != nil {
 		return "", err
 	}
 	for _, name := range nonEmptyLines(output) {
-		if strings.Contains(name, service) {
-			k.podCache[service] = name
+		if strings.Contains(name, service) && k.inRelease(name) {
+			k.cachePod(service, name)
 			return name, nil
 		}
 	}
 
-	return "", fmt.Errorf("no pods found for service %s in namespace %s", service, k.namespace)
+	return "", fmt.Errorf("no pods found for service %s in namespace %s", service, namespace)
+}
+
+func (k *KubernetesBackend) cachedPod(service string) string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.podCache[service]
+}
+
+func (k *KubernetesBackend) cachePod(service, pod string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.podCache[service] = pod
+}
+
+// resetPodCache forgets resolved pod names after workloads are scaled.
+func (k *KubernetesBackend) resetPodCache() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.podCache = map[string]string{}
+	k.containerCache = nil
+}
+
+// deletePods deletes the pods of services without waiting for them to
+// terminate.
+func (k *KubernetesBackend) deletePods(services []string) error {
+	for _, service := range services {
+		pods, err := k.GetAllPods(service)
+		if err != nil {
+			logrus.Debugf("Nothing to delete for %s: %v", service, err)
+			continue
+		}
+		args := append([]string{"delete", "pod", "-n", k.namespaceFor(service), "--wait=false"}, pods...)
+		if _, err := k.executor.runCommand("kubectl", args...); err != nil {
+			return fmt.Errorf("failed to delete the pods of %s: %w", service, err)
+		}
+	}
+	k.resetPodCache()
+	return nil
 }
 
 // GetAllPods returns all pod names for a given service
 func (k *KubernetesBackend) GetAllPods(service string) ([]string, error) {
+	namespace := k.namespaceFor(service)
 	selectors := k.podSelectors(service)
 	for _, selector := range selectors {
-		output, err := k.executor.runCommand("kubectl", "get", "pods", "-n", k.namespace, "-l", selector, "-o", "jsonpath={range .items[*]}{.metadata.name}{\"\\n\"}{end}")
+		output, err := k.executor.runCommand("kubectl", "get", "pods", "-n", namespace, "-l", selector, "-o", "jsonpath={range .items[*]}{.metadata.name}{\"\\n\"}{end}")
 		if err != nil {
 			continue
 		}
@@ -0,0 +1,81 @@
+package app
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestKubernetesDetectRelease(t *testing.T) {
+	tests := []struct {
+		name     string
+		release  string
+		releases string
+		wantErr  string
+	}{
+		{name: "single release", releases: "prod\nprod\n"},
+		{name: "several releases without a name", releases: "prod\nstaging\n", wantErr: "multiple Infrahub releases found in namespace infrahub: prod, staging"},
+		{name: "selected release", release: "staging", releases: "prod\nstaging\n"},
+		{name: "unknown release", release: "dev", releases: "prod\nstaging\n", wantErr: "release dev not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeExecutor().on("jsonpath={range .items[*]}{.metadata.labels.app\\.kubernetes\\.io/instance}", tt.releases, nil)
+			config := &Configuration{K8sNamespace: "infrahub", K8sReleaseName: tt.release, K8sInstanceLabel: defaultInstanceLabel}
+			k := NewKubernetesBackend(config, fake)
+
+			err := k.Detect()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Detect() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Detect() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKubernetesDetectNamespaceForRelease(t *testing.T) {
+	fake := newFakeExecutor().
+		on("-A -l app.kubernetes.io/name=infrahub,app.kubernetes.io/instance=staging", "team-b\n", nil).
+		on("{.metadata.labels.app", "staging\n", nil)
+	config := &Configuration{K8sReleaseName: "staging", K8sInstanceLabel: defaultInstanceLabel}
+	k := NewKubernetesBackend(config, fake)
+
+	if err := k.Detect(); err != nil {
+		t.Fatalf("Detect() error = %v", err)
+	}
+	if k.namespace != "team-b" {
+		t.Errorf("namespace = %q, want team-b", k.namespace)
+	}
+}
+
+func TestKubernetesRunningServicesInOtherNamespace(t *testing.T) {
+	pods := `{"items": [{"metadata": {"name": "infrahub-server-0", "labels": {"app.kubernetes.io/component": "infrahub-server"}}, "status": {"phase": "Running"}}]}`
+	config := &Configuration{Kubernetes: &KubernetesMapping{Services: map[string]KubernetesServiceOverride{
+		"database": {Selectors: []string{"app=neo4j"}, Namespace: "data"},
+	}}}
+
+	fake := newFakeExecutor().
+		on("get pods -n infrahub -o json", pods, nil).
+		on("get pods -n data -l app=neo4j", "Running\n", nil)
+	k := NewKubernetesBackend(config, fake)
+	k.namespace = "infrahub"
+	running, err := k.RunningServices("database")
+	if err != nil || !running["database"] {
+		t.Errorf("RunningServices() = %v, %v; want database running", running, err)
+	}
+
+	fake = newFakeExecutor().
+		on("get pods -n infrahub -o json", pods, nil).
+		on("get pods -n data", "", errors.New("forbidden"))
+	k = NewKubernetesBackend(config, fake)
+	k.namespace = "infrahub"
+	if _, err := k.RunningServices("infrahub-server", "database"); err == nil || !strings.Contains(err.Error(), "namespace data") {
+		t.Errorf("RunningServices() error = %v, want the status failure in namespace data", err)
+	}
+}
@@ -0,0 +1,112 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// infrahubLogServices are the services `environment logs` reads when no
+// --service is given. Services that are not running are skipped.
+var infrahubLogServices = []string{
+	"infrahub-server",
+	"task-worker",
+	"task-manager",
+	"task-manager-background-svc",
+	"database",
+	"task-manager-db",
+	"cache",
+	"message-queue",
+	objectStoreService,
+}
+
+// StreamLogs writes the logs of services to w, each line prefixed with the
+// service (and pod, on Kubernetes) it came from. With no services, every
+// running Infrahub service is included. With opts.Follow it returns once ctx
+// is cancelled.
+func (iops *InfrahubOps) StreamLogs(ctx context.Context, w io.Writer, services []string, opts LogOptions) error {
+	backend, err := iops.ensureBackend()
+	if err != nil {
+		return err
+	}
+
+	if len(services) == 0 {
+		running, err := iops.RunningServices(infrahubLogServices...)
+		if err != nil {
+			return fmt.Errorf("failed to list running services: %w", err)
+		}
+		for _, service := range infrahubLogServices {
+			if running[service] {
+				services = append(services, service)
+			}
+		}
+		if len(services) == 0 {
+			return fmt.Errorf("no running Infrahub services found")
+		}
+	}
+
+	var streams []LogStream
+	for _, service := range services {
+		serviceStreams, err := backend.Logs(ctx, service, opts)
+		if err != nil {
+			for _, stream := range streams {
+				stream.Reader.Close()
+				stream.Wait()
+			}
+			return fmt.Errorf("failed to read logs for %s: %w", service, err)
+		}
+		streams = append(streams, serviceStreams...)
+	}
+
+	return copyPrefixedLogs(ctx, w, streams)
+}
+
+// copyPrefixedLogs interleaves the streams line by line into w. Prefixes are
+// padded to the longest stream name so the log text lines up.
+func copyPrefixedLogs(ctx context.Context, w io.Writer, streams []LogStream) error {
+	width := 0
+	for _, stream := range streams {
+		width = max(width, len(stream.Name))
+	}
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs = make([]error, len(streams))
+	)
+	for i, stream := range streams {
+		wg.Add(1)
+		go func(i int, stream LogStream) {
+			defer wg.Done()
+			prefix := stream.Name + strings.Repeat(" ", width-len(stream.Name)) + " | "
+			scanner := bufio.NewScanner(stream.Reader)
+			scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+			for scanner.Scan() {
+				mu.Lock()
+				fmt.Fprintf(w, "%s%s\n", prefix, scanner.Text())
+				mu.Unlock()
+			}
+			scanErr := scanner.Err()
+			if scanErr != nil {
+				scanErr = fmt.Errorf("failed to read logs: %w", scanErr)
+			}
+			stream.Reader.Close()
+			if err := errors.Join(scanErr, stream.Wait()); err != nil && ctx.Err() == nil {
+				errs[i] = fmt.Errorf("%s: %w", stream.Name, err)
+			}
+		}(i, stream)
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		logrus.Debugf("Log streams ended with errors: %v", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestCopyPrefixedLogs(t *testing.T) {
+	streams := []LogStream{
+		{Name: "database", Reader: io.NopCloser(strings.NewReader("started\nready\n")), Wait: func() error { return nil }},
+		{Name: "task-worker", Reader: io.NopCloser(strings.NewReader("polling")), Wait: func() error { return nil }},
+	}
+
+	var buf bytes.Buffer
+	if err := copyPrefixedLogs(context.Background(), &buf, streams); err != nil {
+		t.Fatalf("copyPrefixedLogs() error = %v", err)
+	}
+
+	got := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	sort.Strings(got)
+	want := []string{
+		"database    | ready",
+		"database    | started",
+		"task-worker | polling",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("copyPrefixedLogs() lines = %q, want %q", got, want)
+	}
+}
+
+func TestCopyPrefixedLogsReportsFailures(t *testing.T) {
+	streams := []LogStream{
+		{Name: "cache", Reader: io.NopCloser(strings.NewReader("")), Wait: func() error { return errors.New("exit status 1") }},
+	}
+
+	err := copyPrefixedLogs(context.Background(), io.Discard, streams)
+	if err == nil || !strings.Contains(err.Error(), "cache: exit status 1") {
+		t.Fatalf("copyPrefixedLogs() error = %v, want cache failure", err)
+	}
+
+	long := strings.Repeat("x", 2*1024*1024)
+	streams = append(streams, LogStream{Name: "database", Reader: io.NopCloser(strings.NewReader("started\n" + long + "\n")), Wait: func() error { return nil }})
+	err = copyPrefixedLogs(context.Background(), io.Discard, streams)
+	if err == nil || !strings.Contains(err.Error(), "database: failed to read logs: bufio.Scanner: token too long") {
+		t.Fatalf("copyPrefixedLogs() error = %v, want the overlong line of database", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := copyPrefixedLogs(ctx, io.Discard, streams); err != nil {
+		t.Errorf("copyPrefixedLogs() after cancel error = %v, want nil", err)
+	}
+}
+
+func TestLogsArgs(t *testing.T) {
+	opts := LogOptions{Follow: true, Tail: 50, Since: "10m"}
+
+	if got, want := dockerLogsArgs("database", opts), []string{"logs", "--no-log-prefix", "--no-color", "--follow", "--tail", "50", "--since", "10m", "database"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dockerLogsArgs() = %v, want %v", got, want)
+	}
+	if got, want := kubectlLogsArgs("infrahub", "infrahub-database-0", LogOptions{}), []string{"logs", "-n", "infrahub", "infrahub-database-0", "--all-containers"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("kubectlLogsArgs() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,58 @@
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxPickAttempts bounds how often an invalid choice is asked again.
+const maxPickAttempts = 3
+
+// stdinIsTerminal reports whether the operator can answer a prompt.
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// pickTerminalTarget lets the operator choose one of several detected targets
+// when stdin is a terminal. ok is false for non-interactive runs, which keep
+// failing with the list of targets.
+func pickTerminalTarget(kind string, targets []string) (choice string, ok bool, err error) {
+	if !stdinIsTerminal() {
+		return "", false, nil
+	}
+	choice, err = chooseTarget(os.Stdin, os.Stderr, kind, targets)
+	return choice, true, err
+}
+
+// chooseTarget prints the targets as a numbered list and reads the choice, by
+// number or by name, from r.
+func chooseTarget(r io.Reader, w io.Writer, kind string, targets []string) (string, error) {
+	fmt.Fprintf(w, "Several Infrahub %ss were found:\n", kind)
+	for i, target := range targets {
+		fmt.Fprintf(w, "  %d) %s\n", i+1, target)
+	}
+
+	reader := bufio.NewReader(r)
+	for range maxPickAttempts {
+		fmt.Fprintf(w, "Select a %s [1-%d]: ", kind, len(targets))
+		line, err := reader.ReadString('\n')
+		answer := strings.TrimSpace(line)
+		if answer == "" && err != nil {
+			return "", fmt.Errorf("no %s selected", kind)
+		}
+		if n, convErr := strconv.Atoi(answer); convErr == nil && n >= 1 && n <= len(targets) {
+			return targets[n-1], nil
+		}
+		if slices.Contains(targets, answer) {
+			return answer, nil
+		}
+		fmt.Fprintf(w, "Invalid choice %q\n", answer)
+	}
+	return "", fmt.Errorf("no valid %s selected after %d attempts", kind, maxPickAttempts)
+}
@@ -0,0 +1,39 @@
+package app
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestChooseTarget(t *testing.T) {
+	targets := []string{"infrahub-dev", "infrahub-prod"}
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "by number", input: "2\n", want: "infrahub-prod"},
+		{name: "by name", input: " infrahub-dev \n", want: "infrahub-dev"},
+		{name: "retry after invalid", input: "3\nprod\n1\n", want: "infrahub-dev"},
+		{name: "no newline at end", input: "1", want: "infrahub-dev"},
+		{name: "closed stdin", input: "", wantErr: true},
+		{name: "too many invalid answers", input: "0\nx\n9\n1\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			got, err := chooseTarget(strings.NewReader(tt.input), &out, "docker compose project", targets)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("chooseTarget() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("chooseTarget() = %q, want %q", got, tt.want)
+			}
+			if !strings.Contains(out.String(), "  1) infrahub-dev\n  2) infrahub-prod\n") {
+				t.Errorf("prompt =\n%s", out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// EventsFormatJSONL is the only --events format: one JSON event per line.
+const EventsFormatJSONL = "jsonl"
+
+// eventProgressInterval limits phase_progress events to one per interval per
+// phase, besides the one reporting completion.
+const eventProgressInterval = time.Second
+
+// Types of the events written by --events.
+const (
+	EventPhaseStart    = "phase_start"
+	EventPhaseProgress = "phase_progress"
+	EventPhaseEnd      = "phase_end"
+	EventWarning       = "warning"
+	EventError         = "error"
+)
+
+// Event is one line of the --events stream.
+type Event struct {
+	Time            time.Time `json:"time"`
+	Type            string    `json:"type"`
+	Command         string    `json:"command,omitempty"`
+	Phase           string    `json:"phase,omitempty"`
+	Message         string    `json:"message,omitempty"`
+	Done            int64     `json:"done,omitempty"`
+	Total           int64     `json:"total,omitempty"`
+	DurationSeconds float64   `json:"duration_seconds,omitempty"`
+	Success         *bool     `json:"success,omitempty"`
+}
+
+// eventStream writes events once --events is set. It is also a logrus hook
+// turning warnings and errors into events.
+type eventStream struct {
+	mu      sync.Mutex
+	w       io.Writer
+	command string
+}
+
+func newEventStream() *eventStream {
+	return &eventStream{}
+}
+
+// open starts writing the events of command to w.
+func (s *eventStream) open(w io.Writer, command string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.w = w
+	s.command = command
+}
+
+// emit writes event as one line. Write errors are ignored: a closed reader
+// must not fail the command.
+func (s *eventStream) emit(event Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.w == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	event.Command = s.command
+	line, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	s.w.Write(append(line, '\n'))
+}
+
+// Levels implements logrus.Hook.
+func (s *eventStream) Levels() []logrus.Level {
+	return []logrus.Level{logrus.WarnLevel, logrus.ErrorLevel}
+}
+
+// Fire implements logrus.Hook.
+func (s *eventStream) Fire(entry *logrus.Entry) error {
+	eventType := EventWarning
+	if entry.Level == logrus.ErrorLevel {
+		eventType = EventError
+	}
+	s.emit(Event{Time: entry.Time.UTC(), Type: eventType, Message: entry.Message})
+	return nil
+}
+
+// configureEvents opens the --events stream on the --events-fd descriptor.
+func (iops *InfrahubOps) configureEvents(format string, fd int) error {
+	switch format {
+	case "":
+		return nil
+	case EventsFormatJSONL:
+	default:
+		return fmt.Errorf("invalid --events %q: only jsonl is supported", format)
+	}
+	var w io.Writer
+	switch fd {
+	case 1:
+		w = os.Stdout
+	case 2:
+		w = os.Stderr
+	default:
+		if fd < 3 {
+			return fmt.Errorf("invalid --events-fd %d: use 1, 2 or a descriptor opened by the caller", fd)
+		}
+		w = os.NewFile(uintptr(fd), "events")
+	}
+	command := ""
+	if iops.run != nil {
+		command = iops.run.command
+	}
+	iops.events.open(w, command)
+	return nil
+}
+
+// eventPhase is a step of a command reported by phase_start, phase_progress
+// and phase_end events.
+type eventPhase struct {
+	events       *eventStream
+	report       *RunReport // records the duration of a successful phase
+	name         string
+	started      time.Time
+	lastProgress time.Time
+}
+
+// startPhase emits phase_start for name.
+func (iops *InfrahubOps) startPhase(name string) *eventPhase {
+	phase := &eventPhase{events: iops.events, report: iops.report, name: name, started: time.Now()}
+	phase.events.emit(Event{Type: EventPhaseStart, Phase: name})
+	return phase
+}
+
+// runPhase runs fn as the phase name and returns its error, or the failure
+// injected once fn succeeds when --fail-at names the phase.
+func (iops *InfrahubOps) runPhase(name string, fn func() error) error {
+	if iops.ctx != nil && iops.ctx.Err() != nil {
+		return fmt.Errorf("cancelled before %s: %w", name, context.Cause(iops.ctx))
+	}
+	phase := iops.startPhase(name)
+	err := fn()
+	if err == nil {
+		err = iops.failAt(name)
+	}
+	phase.end(err)
+	return err
+}
+
+// progress emits phase_progress with done out of total units (bytes for
+// transfers), at most once per eventProgressInterval until done.
+func (p *eventPhase) progress(done, total int64) {
+	now := time.Now()
+	if (total <= 0 || done < total) && now.Sub(p.lastProgress) < eventProgressInterval {
+		return
+	}
+	p.lastProgress = now
+	p.events.emit(Event{Type: EventPhaseProgress, Phase: p.name, Done: done, Total: total})
+}
+
+// end emits phase_end with the outcome of the phase.
+func (p *eventPhase) end(err error) {
+	success := err == nil
+	duration := time.Since(p.started)
+	event := Event{Type: EventPhaseEnd, Phase: p.name, DurationSeconds: duration.Seconds(), Success: &success}
+	if err != nil {
+		event.Message = err.Error()
+	} else if p.report != nil {
+		p.report.recordPhase(p.name, duration)
+	}
+	p.events.emit(event)
+}
+
+// progressCounter counts the bytes read through it, or passed to its Read
+// as minio does with upload progress, and reports them to a phase.
+type progressCounter struct {
+	reader io.Reader
+	phase  *eventPhase
+	done   int64
+	total  int64
+}
+
+// Read implements io.Reader. Without an underlying reader it only counts.
+func (c *progressCounter) Read(p []byte) (int, error) {
+	n := len(p)
+	var err error
+	if c.reader != nil {
+		n, err = c.reader.Read(p)
+	}
+	c.done += int64(n)
+	c.phase.progress(c.done, c.total)
+	return n, err
+}
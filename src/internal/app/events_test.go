@@ -0,0 +1,112 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func decodeEvents(t *testing.T, output string) []Event {
+	t.Helper()
+	var events []Event
+	for _, line := range nonEmptyLines(output) {
+		var event Event
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("invalid event line %q: %v", line, err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestEventPhases(t *testing.T) {
+	var out bytes.Buffer
+	iops := NewInfrahubOpsWithExecutor(newFakeExecutor())
+	iops.events.open(&out, "create")
+
+	iops.runPhase("neo4j_backup", func() error { return nil })
+	upload := iops.startPhase("upload")
+	counter := &progressCounter{phase: upload, total: 300}
+	for i := 0; i < 3; i++ {
+		counter.Read(make([]byte, 100))
+	}
+	upload.end(errors.New("connection reset"))
+
+	var got []string
+	for _, event := range decodeEvents(t, out.String()) {
+		if event.Command != "create" {
+			t.Errorf("event %s has command %q", event.Type, event.Command)
+		}
+		entry := event.Type + " " + event.Phase
+		switch event.Type {
+		case EventPhaseProgress:
+			entry += " " + strings.Repeat("#", int(event.Done/100))
+		case EventPhaseEnd:
+			if *event.Success {
+				entry += " ok"
+			} else {
+				entry += " " + event.Message
+			}
+		}
+		got = append(got, entry)
+	}
+	// The second read falls within the progress interval of the first.
+	want := []string{
+		"phase_start neo4j_backup",
+		"phase_end neo4j_backup ok",
+		"phase_start upload",
+		"phase_progress upload #",
+		"phase_progress upload ###",
+		"phase_end upload connection reset",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("events:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestEventStreamHook(t *testing.T) {
+	var out bytes.Buffer
+	stream := newEventStream()
+	stream.Fire(&logrus.Entry{Level: logrus.WarnLevel, Message: "ignored before open"})
+	stream.open(&out, "restore")
+	stream.Fire(&logrus.Entry{Level: logrus.WarnLevel, Message: "disk almost full"})
+	stream.Fire(&logrus.Entry{Level: logrus.ErrorLevel, Message: "restart failed"})
+
+	events := decodeEvents(t, out.String())
+	if len(events) != 2 || events[0].Type != EventWarning || events[0].Message != "disk almost full" || events[1].Type != EventError {
+		t.Errorf("events = %+v", events)
+	}
+}
+
+func TestConfigureEvents(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		fd      int
+		wantErr string
+	}{
+		{name: "disabled", format: "", fd: 1},
+		{name: "stdout", format: "jsonl", fd: 1},
+		{name: "unknown format", format: "json", fd: 1, wantErr: "only jsonl"},
+		{name: "stdin", format: "jsonl", fd: 0, wantErr: "invalid --events-fd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iops := NewInfrahubOpsWithExecutor(newFakeExecutor())
+			err := iops.configureEvents(tt.format, tt.fd)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("configureEvents() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("configureEvents() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Fail points of --fail-at besides the phases, which fail once their work is
+// done.
+const (
+	FailAtNeo4jCopy     = "neo4j_copy"     // the Neo4j backup exists in the container but is not copied out yet
+	FailAtBeforeRestart = "before_restart" // services are about to be started again
+)
+
+// failAtPoints lists the values --fail-at accepts.
+var failAtPoints = []string{
+	"wait_for_tasks",
+	"neo4j_backup",
+	FailAtNeo4jCopy,
+	"taskmanager_backup",
+	"object_store_backup",
+	"taskmanager_restore",
+	"neo4j_restore",
+	"start_services",
+	FailAtBeforeRestart,
+}
+
+// errInjectedFailure is the error returned at the --fail-at point.
+var errInjectedFailure = errors.New("injected failure")
+
+// parseFailAt validates a --fail-at value.
+func parseFailAt(point string) (string, error) {
+	if point != "" && !slices.Contains(failAtPoints, point) {
+		return "", fmt.Errorf("invalid --fail-at %q: expected one of %s", point, strings.Join(failAtPoints, ", "))
+	}
+	return point, nil
+}
+
+// failAt returns an injected failure when --fail-at names point, so tests can
+// check the cleanup and restart that follow a failure there.
+func (iops *InfrahubOps) failAt(point string) error {
+	if iops.config.FailAt == "" || iops.config.FailAt != point {
+		return nil
+	}
+	logrus.Warnf("Injecting a failure at %s (--fail-at)", point)
+	return fmt.Errorf("%w at %s", errInjectedFailure, point)
+}
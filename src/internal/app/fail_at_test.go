@@ -0,0 +1,64 @@
+package app
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseFailAt(t *testing.T) {
+	tests := []struct {
+		point   string
+		wantErr bool
+	}{
+		{point: ""},
+		{point: "taskmanager_backup"},
+		{point: FailAtNeo4jCopy},
+		{point: FailAtBeforeRestart},
+		{point: "after_pg_dump", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.point, func(t *testing.T) {
+			if _, err := parseFailAt(tt.point); (err != nil) != tt.wantErr {
+				t.Errorf("parseFailAt(%q) error = %v, wantErr %t", tt.point, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunPhaseFailAt(t *testing.T) {
+	iops := NewInfrahubOpsWithExecutor(newFakeExecutor())
+	iops.config.FailAt = "taskmanager_backup"
+
+	var ran []string
+	for _, phase := range []string{"neo4j_backup", "taskmanager_backup"} {
+		err := iops.runPhase(phase, func() error {
+			ran = append(ran, phase)
+			return nil
+		})
+		if injected := errors.Is(err, errInjectedFailure); injected != (phase == "taskmanager_backup") {
+			t.Errorf("runPhase(%s) error = %v", phase, err)
+		}
+	}
+	// The phase does its work before failing, so what it left behind is
+	// cleaned up by the failure handling under test.
+	if len(ran) != 2 {
+		t.Errorf("phases run = %v", ran)
+	}
+}
+
+func TestFailAtNeo4jCopyCleansUp(t *testing.T) {
+	fake := newFakeExecutor()
+	iops := newFakeDockerOps(fake)
+	iops.config.FailAt = FailAtNeo4jCopy
+
+	err := iops.backupNeo4jEnterprise(t.TempDir(), "all")
+	if !errors.Is(err, errInjectedFailure) {
+		t.Fatalf("backupNeo4jEnterprise() error = %v", err)
+	}
+	if copies := fake.commands(" cp "); len(copies) != 0 {
+		t.Errorf("backup copied after the injected failure: %v", copies)
+	}
+	if removed := fake.commands("rm -rf " + neo4jTempBackupDir); len(removed) != 1 {
+		t.Errorf("temporary backup directory not removed: %v", fake.calls)
+	}
+}
@@ -0,0 +1,138 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	infrahubInfoSourceInfrahubctl = "infrahubctl"
+	infrahubInfoSourcePython      = "python"
+)
+
+// InfrahubInfo describes the running Infrahub application. Only the version
+// is known when infrahubctl is not available in infrahub-server.
+type InfrahubInfo struct {
+	Version     string `json:"version"`
+	SchemaHash  string `json:"schema_hash,omitempty"`
+	BranchCount int    `json:"branch_count,omitempty"`
+	WorkerCount int    `json:"worker_count,omitempty"`
+	Source      string `json:"source"`
+}
+
+// collectInfrahubInfo prefers `infrahubctl info --json` inside infrahub-server
+// and falls back to importing the infrahub package for the version only.
+func (iops *InfrahubOps) collectInfrahubInfo() *InfrahubInfo {
+	output, err := iops.Exec("infrahub-server", []string{"infrahubctl", "info", "--json"}, nil)
+	if err == nil {
+		info, parseErr := parseInfrahubctlInfo(output)
+		if parseErr == nil {
+			return info
+		}
+		err = parseErr
+	}
+	logrus.Debugf("infrahubctl info unavailable, falling back to the Python package version: %v", err)
+	return &InfrahubInfo{Version: iops.getInfrahubVersion(), Source: infrahubInfoSourcePython}
+}
+
+// parseInfrahubctlInfo reads the JSON printed by `infrahubctl info --json`.
+// Counts may be reported as numbers or as the listed items; the first key
+// present wins, so older and newer infrahubctl releases are both understood.
+func parseInfrahubctlInfo(output string) (*InfrahubInfo, error) {
+	// Skip anything printed before the JSON document, such as SDK warnings.
+	start := strings.IndexByte(output, '{')
+	if start < 0 {
+		return nil, fmt.Errorf("no JSON in infrahubctl output")
+	}
+	var doc map[string]any
+	if err := json.Unmarshal([]byte(output[start:]), &doc); err != nil {
+		return nil, fmt.Errorf("invalid infrahubctl info output: %w", err)
+	}
+	if server, ok := doc["server"].(map[string]any); ok {
+		for key, value := range server {
+			if _, exists := doc[key]; !exists {
+				doc[key] = value
+			}
+		}
+	}
+
+	info := &InfrahubInfo{
+		Version:     infoString(doc, "version", "infrahub_version", "server_version"),
+		SchemaHash:  infoString(doc, "schema_hash", "schema_summary_hash"),
+		BranchCount: infoCount(doc, "branch_count", "branches"),
+		WorkerCount: infoCount(doc, "worker_count", "workers"),
+		Source:      infrahubInfoSourceInfrahubctl,
+	}
+	if info.Version == "" {
+		return nil, fmt.Errorf("infrahubctl info output has no version")
+	}
+	return info, nil
+}
+
+func infoString(doc map[string]any, keys ...string) string {
+	for _, key := range keys {
+		if value, ok := doc[key].(string); ok && value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func infoCount(doc map[string]any, keys ...string) int {
+	for _, key := range keys {
+		switch value := doc[key].(type) {
+		case float64:
+			return int(value)
+		case []any:
+			return len(value)
+		case map[string]any:
+			return len(value)
+		}
+	}
+	return 0
+}
+
+// EnvironmentStatus prints the detected deployment, the running Infrahub
+// application and the state of each Infrahub service.
+func (iops *InfrahubOps) EnvironmentStatus(w io.Writer) error {
+	backend, err := iops.ensureBackend()
+	if err != nil {
+		return err
+	}
+	running, err := iops.RunningServices(infrahubLogServices...)
+	if err != nil {
+		return fmt.Errorf("failed to list running services: %w", err)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Environment:\t%s (%s)\n", backend.Name(), backend.Info())
+	if running["infrahub-server"] {
+		info := iops.collectInfrahubInfo()
+		fmt.Fprintf(tw, "Infrahub version:\t%s\n", info.Version)
+		if info.SchemaHash != "" {
+			fmt.Fprintf(tw, "Schema hash:\t%s\n", info.SchemaHash)
+		}
+		if info.Source == infrahubInfoSourceInfrahubctl {
+			fmt.Fprintf(tw, "Branches:\t%d\n", info.BranchCount)
+			fmt.Fprintf(tw, "Workers:\t%d\n", info.WorkerCount)
+		}
+		fmt.Fprintf(tw, "Reported by:\t%s\n", info.Source)
+	} else {
+		fmt.Fprintln(tw, "Infrahub version:\tunknown (infrahub-server is not running)")
+	}
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "SERVICE\tSTATE")
+	for _, service := range infrahubLogServices {
+		state := "not running"
+		if running[service] {
+			state = "running"
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", service, state)
+	}
+	return tw.Flush()
+}
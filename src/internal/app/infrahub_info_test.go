@@ -0,0 +1,87 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseInfrahubctlInfo(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    InfrahubInfo
+		wantErr bool
+	}{
+		{
+			name:   "flat counts",
+			output: `{"version":"1.5.0","schema_hash":"abc123","branch_count":4,"worker_count":2}`,
+			want:   InfrahubInfo{Version: "1.5.0", SchemaHash: "abc123", BranchCount: 4, WorkerCount: 2, Source: "infrahubctl"},
+		},
+		{
+			name:   "nested server with lists",
+			output: "warning: SDK is newer than the server\n" + `{"server":{"infrahub_version":"1.4.2","schema_summary_hash":"def"},"branches":["main","feature"],"workers":{"w1":{},"w2":{},"w3":{}}}`,
+			want:   InfrahubInfo{Version: "1.4.2", SchemaHash: "def", BranchCount: 2, WorkerCount: 3, Source: "infrahubctl"},
+		},
+		{name: "no version", output: `{"branch_count":1}`, wantErr: true},
+		{name: "not json", output: "Error: unknown option --json", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseInfrahubctlInfo(tt.output)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseInfrahubctlInfo() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && *got != tt.want {
+				t.Errorf("parseInfrahubctlInfo() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollectInfrahubInfoFallsBack(t *testing.T) {
+	tests := []struct {
+		name       string
+		ctlOutput  string
+		ctlErr     error
+		wantSource string
+		wantVer    string
+	}{
+		{name: "infrahubctl", ctlOutput: `{"version":"1.5.0"}`, wantSource: "infrahubctl", wantVer: "1.5.0"},
+		{name: "infrahubctl missing", ctlErr: errors.New("executable file not found"), wantSource: "python", wantVer: "1.4.0"},
+		{name: "old infrahubctl", ctlOutput: "Usage: infrahubctl info [OPTIONS]", wantSource: "python", wantVer: "1.4.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeExecutor().
+				on("infrahubctl info --json", tt.ctlOutput, tt.ctlErr).
+				on("import infrahub", "1.4.0\n", nil)
+			info := newFakeDockerOps(fake).collectInfrahubInfo()
+			if info.Source != tt.wantSource || info.Version != tt.wantVer {
+				t.Errorf("collectInfrahubInfo() = %+v, want %s from %s", info, tt.wantVer, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestEnvironmentStatus(t *testing.T) {
+	fake := newFakeExecutor().
+		on("ps -a --format json", `[{"Service":"infrahub-server","State":"running"},{"Service":"database","State":"running"},{"Service":"task-worker","State":"exited"}]`, nil).
+		on("infrahubctl info --json", `{"version":"1.5.0","schema_hash":"abc123","branch_count":4,"worker_count":0}`, nil)
+	var out bytes.Buffer
+	if err := newFakeDockerOps(fake).EnvironmentStatus(&out); err != nil {
+		t.Fatalf("EnvironmentStatus() error = %v", err)
+	}
+	for _, want := range []string{"1.5.0", "abc123", "Branches:", "Workers:", "infrahub-server", "task-worker"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("status output missing %q:\n%s", want, out.String())
+		}
+	}
+	for _, line := range strings.Split(out.String(), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "task-worker" && fields[1] != "not" {
+			t.Errorf("task-worker reported as %q", line)
+		}
+	}
+}
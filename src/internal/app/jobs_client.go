@@ -0,0 +1,121 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// DefaultDaemonURL is where the jobs commands reach the daemon unless
+// --daemon-url is set.
+const DefaultDaemonURL = "http://localhost:9100"
+
+// JobsClient drives the /jobs API of a running daemon.
+type JobsClient struct {
+	URL   string
+	Token string
+	HTTP  HTTPConfig // proxy and TLS settings of the connection to the daemon
+}
+
+// NewJobsClient returns a client of the daemon at url.
+func NewJobsClient(url, token string) *JobsClient {
+	return &JobsClient{URL: strings.TrimSuffix(url, "/"), Token: token}
+}
+
+// List returns the jobs known to the daemon, newest first.
+func (c *JobsClient) List() ([]Job, error) {
+	var jobs []Job
+	return jobs, c.do(http.MethodGet, "/jobs", nil, &jobs)
+}
+
+// Submit queues a backup of target, empty for the daemon's deployment.
+func (c *JobsClient) Submit(target string) (Job, error) {
+	var job Job
+	return job, c.do(http.MethodPost, "/jobs", jobRequest{Target: target}, &job)
+}
+
+// Restore queues a restore of the catalog backup ref on target, empty for
+// the daemon's deployment.
+func (c *JobsClient) Restore(ref, target string, excludeTaskManager bool) (Job, error) {
+	var job Job
+	return job, c.do(http.MethodPost, "/restores", restoreRequest{Backup: ref, Target: target, ExcludeTaskManager: excludeTaskManager}, &job)
+}
+
+// Cancel cancels a queued job or stops a running one at its next phase.
+func (c *JobsClient) Cancel(id string) (Job, error) {
+	var job Job
+	return job, c.do(http.MethodPost, "/jobs/"+id+"/cancel", nil, &job)
+}
+
+func (c *JobsClient) do(method, path string, body, out any) error {
+	if c.Token == "" {
+		return fmt.Errorf("--jobs-token is required")
+	}
+	var payload io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = bytes.NewReader(data)
+	}
+	request, err := http.NewRequest(method, c.URL+path, payload)
+	if err != nil {
+		return fmt.Errorf("invalid --daemon-url %q: %w", c.URL, err)
+	}
+	request.Header.Set("Authorization", "Bearer "+c.Token)
+	request.Header.Set("Content-Type", "application/json")
+	client, err := c.HTTP.Client(30 * time.Second)
+	if err != nil {
+		return err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("failed to reach the daemon at %s: %w", c.URL, err)
+	}
+	defer response.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(response.Body, 4<<20))
+	if err != nil {
+		return err
+	}
+	if response.StatusCode >= 300 {
+		var failure struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &failure) == nil && failure.Error != "" {
+			return fmt.Errorf("daemon: %s", failure.Error)
+		}
+		return fmt.Errorf("daemon answered %s", response.Status)
+	}
+	return json.Unmarshal(data, out)
+}
+
+// WriteJobs prints jobs as a table.
+func WriteJobs(w io.Writer, jobs []Job) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tKIND\tTARGET\tTRIGGER\tREQUESTED BY\tSTATE\tCREATED\tDURATION\tERROR")
+	for _, job := range jobs {
+		target, requestedBy, duration := job.Target, job.RequestedBy, "-"
+		if target == "" {
+			target = "-"
+		}
+		if requestedBy == "" {
+			requestedBy = "-"
+		}
+		if job.StartedAt != nil {
+			end := time.Now()
+			if job.FinishedAt != nil {
+				end = *job.FinishedAt
+			}
+			duration = end.Sub(*job.StartedAt).Round(time.Second).String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", job.ID, job.Kind, target, job.Trigger, requestedBy, job.State,
+			job.CreatedAt.Local().Format(time.DateTime), duration, job.Error)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,66 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJobsClient(t *testing.T) {
+	queue := newJobQueue(1, 1, &daemonState{})
+	defer queue.close()
+	mux := http.NewServeMux()
+	api := &jobsAPI{queue: queue, tokens: []APIToken{JobsToken("s3cret")}, backup: func(target, requestedBy string) (Job, error) {
+		return queue.submit(Job{Kind: JobKindBackup, Target: target, Trigger: JobTriggerAPI, RequestedBy: requestedBy}, func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+	}}
+	api.register(mux)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	if _, err := NewJobsClient(server.URL, "wrong").List(); err == nil || !strings.Contains(err.Error(), "bearer token") {
+		t.Errorf("List() with a wrong token error = %v", err)
+	}
+	if _, err := NewJobsClient(server.URL, "").List(); err == nil || !strings.Contains(err.Error(), "--jobs-token") {
+		t.Errorf("List() without a token error = %v", err)
+	}
+
+	client := NewJobsClient(server.URL+"/", "s3cret")
+	job, err := client.Submit("")
+	if err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	if _, err := client.Cancel(job.ID); err != nil {
+		t.Fatalf("Cancel() error = %v", err)
+	}
+	if _, err := client.Cancel("404"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Cancel() of an unknown job error = %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		jobs, err := client.List()
+		if err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+		if len(jobs) == 1 && jobs[0].State == JobCancelled {
+			var out bytes.Buffer
+			if err := WriteJobs(&out, jobs); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(out.String(), "cancelled") || !strings.Contains(out.String(), "api") {
+				t.Errorf("WriteJobs() = %q", out.String())
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("jobs = %+v, want one cancelled job", jobs)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
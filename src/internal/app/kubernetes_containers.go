@@ -0,0 +1,158 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// defaultContainerAnnotation names the container kubectl exec uses when no
+// -c flag is given.
+const defaultContainerAnnotation = "kubectl.kubernetes.io/default-container"
+
+// sidecarContainers are injected proxies and log shippers that never carry
+// the database tools.
+var sidecarContainers = []string{
+	"istio-proxy",
+	"linkerd-proxy",
+	"envoy",
+	"vault-agent",
+	"cloud-sql-proxy",
+	"fluent-bit",
+	"fluentd",
+	"filebeat",
+	"promtail",
+}
+
+// serviceContainerHints lists the container names preferred for a service,
+// in addition to the service name itself.
+var serviceContainerHints = map[string][]string{
+	"database":        {"neo4j", "database"},
+	"task-manager-db": {"postgres", "postgresql", "task-manager-db"},
+}
+
+// kubernetesPodContainers is the part of a pod spec used to pick a container.
+type kubernetesPodContainers struct {
+	Default    string   // value of the default-container annotation
+	Containers []string // names in spec order
+}
+
+// parseKubernetesPodContainers parses `kubectl get pod <name> -o json` output.
+func parseKubernetesPodContainers(output string) (kubernetesPodContainers, error) {
+	var pod struct {
+		Metadata struct {
+			Annotations map[string]string `json:"annotations"`
+		} `json:"metadata"`
+		Spec struct {
+			Containers []struct {
+				Name string `json:"name"`
+			} `json:"containers"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal([]byte(output), &pod); err != nil {
+		return kubernetesPodContainers{}, fmt.Errorf("failed to parse pod: %w", err)
+	}
+
+	result := kubernetesPodContainers{Default: pod.Metadata.Annotations[defaultContainerAnnotation]}
+	for _, container := range pod.Spec.Containers {
+		result.Containers = append(result.Containers, container.Name)
+	}
+	return result, nil
+}
+
+// isSidecarContainer reports whether name looks like an injected sidecar.
+func isSidecarContainer(name string) bool {
+	for _, sidecar := range sidecarContainers {
+		if name == sidecar || strings.HasPrefix(name, sidecar+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// chooseContainer picks the container of a multi-container pod that runs
+// service. The default-container annotation wins; otherwise sidecars are
+// skipped and a container named after the service is preferred. It returns
+// an empty string when the pod has a single container or nothing fits.
+func chooseContainer(service string, pod kubernetesPodContainers) string {
+	if len(pod.Containers) <= 1 {
+		return ""
+	}
+	if pod.Default != "" {
+		return pod.Default
+	}
+
+	candidates := []string{}
+	for _, name := range pod.Containers {
+		if !isSidecarContainer(name) {
+			candidates = append(candidates, name)
+		}
+	}
+	hints := append([]string{service}, serviceContainerHints[service]...)
+	for _, hint := range hints {
+		for _, name := range candidates {
+			if name == hint {
+				return name
+			}
+		}
+	}
+	for _, hint := range hints {
+		for _, name := range candidates {
+			if strings.Contains(name, hint) {
+				return name
+			}
+		}
+	}
+	if len(candidates) > 0 {
+		return candidates[0]
+	}
+	return ""
+}
+
+// execContainer returns the container to exec into and copy files through
+// for service in pod. A container configured for the service is used as is;
+// otherwise the pod spec is inspected once per pod.
+func (k *KubernetesBackend) execContainer(service, pod string) string {
+	if container := k.containerFor(service); container != "" {
+		return container
+	}
+
+	k.mu.Lock()
+	container, ok := k.containerCache[pod]
+	k.mu.Unlock()
+	if ok {
+		return container
+	}
+
+	output, err := k.executor.runCommand("kubectl", "get", "pod", pod, "-n", k.namespaceFor(service), "-o", "json")
+	if err == nil && strings.TrimSpace(output) != "" {
+		spec, parseErr := parseKubernetesPodContainers(output)
+		if parseErr != nil {
+			logrus.Debugf("Could not inspect containers of %s: %v", pod, parseErr)
+		} else {
+			container = chooseContainer(service, spec)
+		}
+	}
+	if container != "" {
+		logrus.Debugf("Using container %s of pod %s for %s", container, pod, service)
+	}
+
+	k.mu.Lock()
+	if k.containerCache == nil {
+		k.containerCache = map[string]string{}
+	}
+	k.containerCache[pod] = container
+	k.mu.Unlock()
+	return container
+}
+
+// containerArgs returns the kubectl -c arguments for service in pod, or nil
+// to use the pod's default container.
+func (k *KubernetesBackend) containerArgs(service, pod string) []string {
+	if container := k.execContainer(service, pod); container != "" {
+		return []string{"-c", container}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package app
+
+import (
+	"testing"
+)
+
+func TestChooseContainer(t *testing.T) {
+	tests := []struct {
+		name    string
+		service string
+		pod     kubernetesPodContainers
+		want    string
+	}{
+		{name: "single container", service: "database", pod: kubernetesPodContainers{Containers: []string{"istio-proxy"}}, want: ""},
+		{name: "annotation", service: "database", pod: kubernetesPodContainers{Default: "main", Containers: []string{"istio-proxy", "main"}}, want: "main"},
+		{name: "istio sidecar first", service: "database", pod: kubernetesPodContainers{Containers: []string{"istio-proxy", "neo4j"}}, want: "neo4j"},
+		{name: "postgres hint", service: "task-manager-db", pod: kubernetesPodContainers{Containers: []string{"metrics", "fluent-bit", "postgresql"}}, want: "postgresql"},
+		{name: "service name", service: "task-worker", pod: kubernetesPodContainers{Containers: []string{"linkerd-proxy", "infrahub-task-worker"}}, want: "infrahub-task-worker"},
+		{name: "first non-sidecar", service: "cache", pod: kubernetesPodContainers{Containers: []string{"vault-agent", "server", "exporter"}}, want: "server"},
+		{name: "only sidecars", service: "cache", pod: kubernetesPodContainers{Containers: []string{"istio-proxy", "promtail"}}, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := chooseContainer(tt.service, tt.pod); got != tt.want {
+				t.Errorf("chooseContainer() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+const multiContainerPod = `{
+  "metadata": {"name": "database-0", "annotations": {"sidecar.istio.io/status": "{}"}},
+  "spec": {"containers": [{"name": "istio-proxy"}, {"name": "neo4j"}]}
+}`
+
+func TestKubernetesExecSkipsSidecar(t *testing.T) {
+	fake := newFakeExecutor().
+		on("get pods -n infrahub -l app.kubernetes.io/component=database", "database-0\n", nil).
+		on("get pod database-0 -n infrahub -o json", multiContainerPod, nil)
+	k := NewKubernetesBackend(&Configuration{}, fake)
+	k.namespace = "infrahub"
+
+	if _, err := k.Exec("database", []string{"neo4j-admin", "--version"}, nil); err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+	if err := k.CopyFrom("database", "/backups/dump", "/tmp/dump"); err != nil {
+		t.Fatalf("CopyFrom() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"kubectl exec -n infrahub database-0 -c neo4j -- neo4j-admin --version",
+		"kubectl cp infrahub/database-0:/backups/dump /tmp/dump -c neo4j",
+	} {
+		if len(fake.commands(want)) != 1 {
+			t.Errorf("missing command %q in %v", want, fake.calls)
+		}
+	}
+	if n := len(fake.commands("get pod database-0")); n != 1 {
+		t.Errorf("pod spec fetched %d times, want 1 (cached)", n)
+	}
+}
+
+func TestKubernetesContainerFlag(t *testing.T) {
+	iops := NewInfrahubOps()
+	root := newTestRoot(iops)
+	root.SetArgs([]string{"--k8s-container", "database=neo4j", "--k8s-container", "task-manager-db=postgres", "noop"})
+	if err := root.Execute(); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	mapping := iops.Config().Kubernetes
+	if mapping.Services["database"].Container != "neo4j" || mapping.Services["task-manager-db"].Container != "postgres" {
+		t.Errorf("Kubernetes = %+v", mapping)
+	}
+
+	iops = NewInfrahubOps()
+	root = newTestRoot(iops)
+	root.SetArgs([]string{"--k8s-container", "neo4j", "noop"})
+	if err := root.Execute(); err == nil {
+		t.Error("Execute() error = nil for a value without service=")
+	}
+}
@@ -0,0 +1,223 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/sirupsen/logrus"
+)
+
+// KubernetesMapping overrides how services are found in a Kubernetes
+// deployment. It is read from the kubernetes key of the --config file:
+//
+//	kubernetes:
+//	  selectors:
+//	    - "acme.io/role={{ .Service }},acme.io/stack={{ .Release }}"
+//	  services:
+//	    database:
+//	      selectors: ["app=neo4j,role=primary"]
+//	      container: neo4j
+//	      namespace: infrahub-data
+//	      workload: statefulset/infrahub-neo4j
+//
+// Selectors are Go templates rendered with the service name, the release
+// name and the namespace.
+type KubernetesMapping struct {
+	Selectors []string                             `mapstructure:"selectors"` // replace the built-in selector patterns for every service
+	Services  map[string]KubernetesServiceOverride `mapstructure:"services"`
+}
+
+// KubernetesServiceOverride replaces the lookup of a single service.
+type KubernetesServiceOverride struct {
+	Selectors []string `mapstructure:"selectors"` // label selectors tried in order
+	Container string   `mapstructure:"container"` // container to exec into
+	Namespace string   `mapstructure:"namespace"` // namespace the service runs in
+	Workload  string   `mapstructure:"workload"`  // kind/name of the workload scaled on stop and start
+}
+
+// selectorTemplateData is the data selector templates are rendered with.
+type selectorTemplateData struct {
+	Service   string
+	Release   string
+	Namespace string
+}
+
+func renderSelector(text string, data selectorTemplateData) (string, error) {
+	tmpl, err := template.New("selector").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid selector template %q: %w", text, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("invalid selector template %q: %w", text, err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// override returns the configured override for service, if any.
+func (m *KubernetesMapping) override(service string) (KubernetesServiceOverride, bool) {
+	if m == nil {
+		return KubernetesServiceOverride{}, false
+	}
+	override, ok := m.Services[service]
+	return override, ok
+}
+
+// clone deep-copies the mapping; it returns nil when m is nil.
+func (m *KubernetesMapping) clone() *KubernetesMapping {
+	if m == nil {
+		return nil
+	}
+	copied := &KubernetesMapping{Selectors: slices.Clone(m.Selectors)}
+	if m.Services != nil {
+		copied.Services = make(map[string]KubernetesServiceOverride, len(m.Services))
+		for service, override := range m.Services {
+			override.Selectors = slices.Clone(override.Selectors)
+			copied.Services[service] = override
+		}
+	}
+	return copied
+}
+
+// withContainer returns a copy of the mapping with the container of service
+// set. m itself is left unchanged, as it may be shared with other targets.
+func (m *KubernetesMapping) withContainer(service, container string) *KubernetesMapping {
+	copied := m.clone()
+	if copied == nil {
+		copied = &KubernetesMapping{}
+	}
+	if copied.Services == nil {
+		copied.Services = map[string]KubernetesServiceOverride{}
+	}
+	override := copied.Services[service]
+	override.Container = container
+	copied.Services[service] = override
+	return copied
+}
+
+// validate renders every selector template and checks workload references.
+func (m *KubernetesMapping) validate() []error {
+	if m == nil {
+		return nil
+	}
+	var problems []error
+	data := selectorTemplateData{Service: "service", Release: "release", Namespace: "namespace"}
+	for _, selector := range m.Selectors {
+		if _, err := renderSelector(selector, data); err != nil {
+			problems = append(problems, fmt.Errorf("kubernetes.selectors: %w", err))
+		}
+	}
+
+	services := make([]string, 0, len(m.Services))
+	for service := range m.Services {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+	for _, service := range services {
+		override := m.Services[service]
+		for _, selector := range override.Selectors {
+			if _, err := renderSelector(selector, data); err != nil {
+				problems = append(problems, fmt.Errorf("kubernetes.services.%s.selectors: %w", service, err))
+			}
+		}
+		if override.Workload != "" {
+			if _, _, err := parseWorkloadRef(override.Workload); err != nil {
+				problems = append(problems, fmt.Errorf("kubernetes.services.%s.workload: %w", service, err))
+			}
+		}
+	}
+	return problems
+}
+
+// String summarises the mapping for config validate.
+func (m *KubernetesMapping) String() string {
+	if m == nil {
+		return ""
+	}
+	services := make([]string, 0, len(m.Services))
+	for service := range m.Services {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+	parts := []string{}
+	if len(m.Selectors) > 0 {
+		parts = append(parts, fmt.Sprintf("%d selector(s)", len(m.Selectors)))
+	}
+	if len(services) > 0 {
+		parts = append(parts, "services: "+strings.Join(services, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// parseWorkloadRef splits a kind/name reference such as statefulset/neo4j.
+func parseWorkloadRef(ref string) (string, string, error) {
+	kind, name, ok := strings.Cut(ref, "/")
+	kind = strings.ToLower(kind)
+	if !ok || name == "" || (kind != "deployment" && kind != "statefulset") {
+		return "", "", fmt.Errorf("invalid workload %q: expected deployment/<name> or statefulset/<name>", ref)
+	}
+	return kind, name, nil
+}
+
+// mapping returns the configured service mapping, which may be nil.
+func (k *KubernetesBackend) mapping() *KubernetesMapping {
+	if k.config == nil {
+		return nil
+	}
+	return k.config.Kubernetes
+}
+
+// namespaceFor returns the namespace service runs in.
+func (k *KubernetesBackend) namespaceFor(service string) string {
+	if override, ok := k.mapping().override(service); ok && override.Namespace != "" {
+		return override.Namespace
+	}
+	return k.namespace
+}
+
+// containerFor returns the container configured for service, or an empty
+// string when it is picked from the pod spec.
+func (k *KubernetesBackend) containerFor(service string) string {
+	if override, ok := k.mapping().override(service); ok {
+		return override.Container
+	}
+	return ""
+}
+
+// configuredSelectors renders the selectors configured for service. It
+// returns nil when the built-in patterns apply.
+func (k *KubernetesBackend) configuredSelectors(service string) []string {
+	templates := k.mapping().selectorTemplates(service)
+	if len(templates) == 0 {
+		return nil
+	}
+
+	data := selectorTemplateData{Service: service, Namespace: k.namespaceFor(service)}
+	if k.config != nil {
+		data.Release = k.config.K8sReleaseName
+	}
+	selectors := make([]string, 0, len(templates))
+	for _, text := range templates {
+		selector, err := renderSelector(text, data)
+		if err != nil {
+			logrus.Warnf("Ignoring selector for %s: %v", service, err)
+			continue
+		}
+		selectors = append(selectors, selector)
+	}
+	return selectors
+}
+
+func (m *KubernetesMapping) selectorTemplates(service string) []string {
+	if m == nil {
+		return nil
+	}
+	if override, ok := m.Services[service]; ok && len(override.Selectors) > 0 {
+		return override.Selectors
+	}
+	return m.Selectors
+}
@@ -0,0 +1,121 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestKubernetesMappingSelectors(t *testing.T) {
+	mapping := &KubernetesMapping{
+		Selectors: []string{"acme.io/role={{ .Service }},acme.io/stack={{ .Release }}"},
+		Services: map[string]KubernetesServiceOverride{
+			"database": {Selectors: []string{"app=neo4j,ns={{ .Namespace }}"}, Namespace: "data"},
+			"cache":    {Container: "redis"},
+		},
+	}
+	k := &KubernetesBackend{config: &Configuration{K8sReleaseName: "prod", Kubernetes: mapping}, namespace: "infrahub"}
+
+	tests := []struct {
+		service string
+		want    []string
+	}{
+		{service: "database", want: []string{"app=neo4j,ns=data"}},
+		{service: "cache", want: []string{"acme.io/role=cache,acme.io/stack=prod"}},
+		{service: "task-worker", want: []string{"acme.io/role=task-worker,acme.io/stack=prod"}},
+	}
+	for _, tt := range tests {
+		if got := k.podSelectors(tt.service); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("podSelectors(%q) = %v, want %v", tt.service, got, tt.want)
+		}
+	}
+
+	if got := (&KubernetesBackend{}).podSelectors("cache")[0]; got != "app.kubernetes.io/component=cache" {
+		t.Errorf("built-in podSelectors()[0] = %q", got)
+	}
+}
+
+func TestKubernetesMappingValidate(t *testing.T) {
+	mapping := &KubernetesMapping{
+		Selectors: []string{"app={{ .Service"},
+		Services: map[string]KubernetesServiceOverride{
+			"database":    {Selectors: []string{"app={{ .Pod }}"}},
+			"task-worker": {Workload: "daemonset/worker"},
+			"cache":       {Workload: "statefulset/redis"},
+		},
+	}
+	problems := mapping.validate()
+	want := []string{"kubernetes.selectors", "kubernetes.services.database.selectors", "kubernetes.services.task-worker.workload"}
+	if len(problems) != len(want) {
+		t.Fatalf("validate() = %v, want %d problems", problems, len(want))
+	}
+	for i, prefix := range want {
+		if !strings.HasPrefix(problems[i].Error(), prefix) {
+			t.Errorf("problem %d = %q, want prefix %q", i, problems[i], prefix)
+		}
+	}
+}
+
+func TestKubernetesServiceOverrides(t *testing.T) {
+	fake := newFakeExecutor().on("get pods -n data -l app=neo4j", "neo4j-0\n", nil)
+	config := &Configuration{Kubernetes: &KubernetesMapping{Services: map[string]KubernetesServiceOverride{
+		"database": {Selectors: []string{"app=neo4j"}, Container: "neo4j", Namespace: "data", Workload: "statefulset/neo4j"},
+	}}}
+	k := NewKubernetesBackend(config, fake)
+	k.namespace = "infrahub"
+
+	if _, err := k.Exec("database", []string{"neo4j-admin", "--version"}, nil); err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+	if err := k.CopyTo("database", "/tmp/dump", "/backups/dump"); err != nil {
+		t.Fatalf("CopyTo() error = %v", err)
+	}
+	if err := k.Stop("database"); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"kubectl exec -n data neo4j-0 -c neo4j -- neo4j-admin --version",
+		"kubectl cp /tmp/dump data/neo4j-0:/backups/dump -c neo4j",
+		"kubectl scale -n data statefulset/neo4j --replicas=0",
+	} {
+		if len(fake.commands(want)) != 1 {
+			t.Errorf("missing command %q in %v", want, fake.calls)
+		}
+	}
+	if len(fake.commands("get deployment")) != 0 {
+		t.Errorf("workload lookup ran despite the configured workload: %v", fake.calls)
+	}
+}
+
+func TestKubernetesMappingFromConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "infrahub-ops.yaml")
+	content := `kubernetes:
+  selectors:
+    - "acme.io/role={{ .Service }}"
+  services:
+    task-manager-db:
+      container: postgres
+      namespace: data
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	iops := NewInfrahubOps()
+	root := newTestRoot(iops)
+	root.SetArgs([]string{"--config", path, "noop"})
+	if err := root.Execute(); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	mapping := iops.Config().Kubernetes
+	if mapping == nil || len(mapping.Selectors) != 1 {
+		t.Fatalf("Kubernetes = %+v", mapping)
+	}
+	if got := mapping.Services["task-manager-db"]; got.Container != "postgres" || got.Namespace != "data" {
+		t.Errorf("task-manager-db override = %+v", got)
+	}
+}
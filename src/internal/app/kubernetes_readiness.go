@@ -0,0 +1,275 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultK8sReadyTimeout bounds the wait for a started service to have a
+	// ready pod.
+	defaultK8sReadyTimeout = 5 * time.Minute
+	// istioStatusAnnotation is set on pods that received an Istio sidecar.
+	istioStatusAnnotation = "sidecar.istio.io/status"
+)
+
+// kubernetesReadyPollInterval is how often pod readiness is checked.
+var kubernetesReadyPollInterval = 2 * time.Second
+
+// kubernetesContainerState is the status of one container of a pod.
+type kubernetesContainerState struct {
+	Name       string
+	Ready      bool
+	Running    bool
+	Terminated bool
+	Sidecar    bool
+}
+
+// kubernetesPodState is the part of a pod used for readiness gating.
+type kubernetesPodState struct {
+	Name        string
+	Terminating bool
+	OwnerKind   string
+	Phase       string
+	MeshInject  bool // the pod was admitted with an Istio sidecar
+	Containers  []kubernetesContainerState
+}
+
+// parseKubernetesPodStates parses `kubectl get pods -o json` output.
+// Native sidecars (init containers with restartPolicy Always) are listed
+// with the regular containers.
+func parseKubernetesPodStates(output string) ([]kubernetesPodState, error) {
+	type containerStatus struct {
+		Name  string `json:"name"`
+		Ready bool   `json:"ready"`
+		State struct {
+			Running    *struct{} `json:"running"`
+			Terminated *struct{} `json:"terminated"`
+		} `json:"state"`
+	}
+	var list struct {
+		Items []struct {
+			Metadata struct {
+				Name              string            `json:"name"`
+				Annotations       map[string]string `json:"annotations"`
+				DeletionTimestamp string            `json:"deletionTimestamp"`
+				OwnerReferences   []struct {
+					Kind string `json:"kind"`
+				} `json:"ownerReferences"`
+			} `json:"metadata"`
+			Spec struct {
+				InitContainers []struct {
+					Name          string `json:"name"`
+					RestartPolicy string `json:"restartPolicy"`
+				} `json:"initContainers"`
+			} `json:"spec"`
+			Status struct {
+				Phase                 string            `json:"phase"`
+				ContainerStatuses     []containerStatus `json:"containerStatuses"`
+				InitContainerStatuses []containerStatus `json:"initContainerStatuses"`
+			} `json:"status"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal([]byte(output), &list); err != nil {
+		return nil, fmt.Errorf("failed to parse pod list: %w", err)
+	}
+
+	pods := make([]kubernetesPodState, 0, len(list.Items))
+	for _, item := range list.Items {
+		pod := kubernetesPodState{
+			Name:        item.Metadata.Name,
+			Terminating: item.Metadata.DeletionTimestamp != "",
+			Phase:       item.Status.Phase,
+		}
+		_, pod.MeshInject = item.Metadata.Annotations[istioStatusAnnotation]
+		if len(item.Metadata.OwnerReferences) > 0 {
+			pod.OwnerKind = item.Metadata.OwnerReferences[0].Kind
+		}
+		state := func(status containerStatus, sidecar bool) kubernetesContainerState {
+			return kubernetesContainerState{
+				Name:       status.Name,
+				Ready:      status.Ready,
+				Running:    status.State.Running != nil,
+				Terminated: status.State.Terminated != nil,
+				Sidecar:    sidecar,
+			}
+		}
+		for _, status := range item.Status.ContainerStatuses {
+			pod.Containers = append(pod.Containers, state(status, isSidecarContainer(status.Name)))
+		}
+		nativeSidecars := map[string]bool{}
+		for _, container := range item.Spec.InitContainers {
+			if container.RestartPolicy == "Always" {
+				nativeSidecars[container.Name] = true
+			}
+		}
+		for _, status := range item.Status.InitContainerStatuses {
+			if nativeSidecars[status.Name] {
+				pod.Containers = append(pod.Containers, state(status, true))
+			}
+		}
+		pods = append(pods, pod)
+	}
+	return pods, nil
+}
+
+// sidecar returns the first running sidecar of the pod.
+func (p kubernetesPodState) sidecar() (string, bool) {
+	for _, container := range p.Containers {
+		if container.Sidecar && container.Running {
+			return container.Name, true
+		}
+	}
+	return "", false
+}
+
+// finishedJob reports whether the pod belongs to a Job whose workload
+// containers exited while a mesh sidecar keeps the pod running.
+func (p kubernetesPodState) finishedJob() bool {
+	if p.OwnerKind != "Job" {
+		return false
+	}
+	if _, ok := p.sidecar(); !ok {
+		return false
+	}
+	workloads := 0
+	for _, container := range p.Containers {
+		if container.Sidecar {
+			continue
+		}
+		workloads++
+		if !container.Terminated {
+			return false
+		}
+	}
+	return workloads > 0
+}
+
+// readiness reports whether the pod can serve the restore, with the reason
+// when it cannot. The sidecar must be ready as well: until it is, traffic
+// from and to the workload container is dropped.
+func (p kubernetesPodState) readiness() (bool, string) {
+	switch {
+	case p.Terminating:
+		return false, "terminating"
+	case p.finishedJob():
+		return false, "finished job"
+	case !strings.EqualFold(p.Phase, "Running"):
+		return false, "phase " + p.Phase
+	}
+
+	hasSidecar := false
+	for _, container := range p.Containers {
+		hasSidecar = hasSidecar || container.Sidecar
+	}
+	if p.MeshInject && !hasSidecar {
+		return false, "waiting for the sidecar to start"
+	}
+	for _, container := range p.Containers {
+		if container.Ready {
+			continue
+		}
+		if container.Sidecar {
+			return false, fmt.Sprintf("sidecar %s not ready", container.Name)
+		}
+		return false, fmt.Sprintf("container %s not ready", container.Name)
+	}
+	return true, ""
+}
+
+// readyTimeout returns how long Start waits for pods to become ready.
+func (k *KubernetesBackend) readyTimeout() time.Duration {
+	if k.config == nil {
+		return 0
+	}
+	return k.config.K8sReadyTimeout
+}
+
+// servicePodStates returns the pods of service from the first selector that
+// matches any pod.
+func (k *KubernetesBackend) servicePodStates(service string) ([]kubernetesPodState, error) {
+	namespace := k.namespaceFor(service)
+	var lastErr error
+	for _, selector := range k.podSelectors(service) {
+		output, err := k.executor.runCommand("kubectl", "get", "pods", "-n", namespace, "-l", selector, "-o", "json")
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if strings.TrimSpace(output) == "" {
+			continue
+		}
+		pods, err := parseKubernetesPodStates(output)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if len(pods) > 0 {
+			return pods, nil
+		}
+	}
+	return nil, lastErr
+}
+
+// stopFinishedJobSidecar asks the Istio sidecar of a completed Job pod to
+// exit, so the Job completes instead of holding the pod.
+func (k *KubernetesBackend) stopFinishedJobSidecar(namespace string, pod kubernetesPodState) {
+	sidecar, _ := pod.sidecar()
+	if sidecar != "istio-proxy" {
+		logrus.Debugf("Pod %s finished but sidecar %s keeps it running", pod.Name, sidecar)
+		return
+	}
+	logrus.Infof("Stopping the istio-proxy sidecar of finished job pod %s", pod.Name)
+	if _, err := k.executor.runCommand("kubectl", "exec", "-n", namespace, pod.Name, "-c", sidecar, "--", "pilot-agent", "request", "POST", "quitquitquit"); err != nil {
+		logrus.Warnf("Failed to stop the sidecar of %s: %v", pod.Name, err)
+	}
+}
+
+// waitUntilReady waits until every service has a ready pod, sidecars
+// included. Services whose pods are not ready in time are logged and
+// skipped; the caller decides whether the next step can proceed.
+func (k *KubernetesBackend) waitUntilReady(services ...string) {
+	timeout := k.readyTimeout()
+	if timeout <= 0 {
+		return
+	}
+	deadline := time.Now().Add(timeout)
+	stopped := map[string]bool{}
+
+	for _, service := range services {
+		namespace := k.namespaceFor(service)
+		reason := "no pods yet"
+		for {
+			pods, err := k.servicePodStates(service)
+			if err != nil {
+				reason = err.Error()
+			}
+			ready := false
+			for _, pod := range pods {
+				if pod.finishedJob() && !stopped[pod.Name] {
+					stopped[pod.Name] = true
+					k.stopFinishedJobSidecar(namespace, pod)
+				}
+				var podReason string
+				if ready, podReason = pod.readiness(); ready {
+					break
+				}
+				reason = fmt.Sprintf("%s: %s", pod.Name, podReason)
+			}
+			if ready {
+				logrus.Debugf("Service %s is ready", service)
+				break
+			}
+			if time.Now().After(deadline) {
+				logrus.Warnf("Service %s not ready after %s (%s); continuing", service, timeout, reason)
+				break
+			}
+			logrus.Debugf("Waiting for %s: %s", service, reason)
+			time.Sleep(kubernetesReadyPollInterval)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package app
+
+import (
+	"testing"
+	"time"
+)
+
+const meshPodList = `{"items": [
+  {
+    "metadata": {"name": "migrate-abc", "ownerReferences": [{"kind": "Job"}], "annotations": {"sidecar.istio.io/status": "{}"}},
+    "status": {"phase": "Running", "containerStatuses": [
+      {"name": "migrate", "ready": false, "state": {"terminated": {"exitCode": 0}}},
+      {"name": "istio-proxy", "ready": true, "state": {"running": {}}}
+    ]}
+  },
+  {
+    "metadata": {"name": "database-0", "annotations": {"sidecar.istio.io/status": "{}"}},
+    "status": {"phase": "Running", "containerStatuses": [
+      {"name": "neo4j", "ready": true, "state": {"running": {}}},
+      {"name": "istio-proxy", "ready": false, "state": {"running": {}}}
+    ]}
+  },
+  {
+    "metadata": {"name": "database-1", "annotations": {"sidecar.istio.io/status": "{}"}},
+    "status": {"phase": "Pending"}
+  },
+  {
+    "metadata": {"name": "database-2", "annotations": {"sidecar.istio.io/status": "{}"}},
+    "spec": {"initContainers": [{"name": "istio-proxy", "restartPolicy": "Always"}]},
+    "status": {"phase": "Running",
+      "initContainerStatuses": [{"name": "istio-proxy", "ready": true, "state": {"running": {}}}],
+      "containerStatuses": [{"name": "neo4j", "ready": true, "state": {"running": {}}}]
+    }
+  },
+  {
+    "metadata": {"name": "database-3", "annotations": {"sidecar.istio.io/status": "{}"}},
+    "status": {"phase": "Running", "containerStatuses": [{"name": "neo4j", "ready": true, "state": {"running": {}}}]}
+  }
+]}`
+
+func TestPodReadiness(t *testing.T) {
+	pods, err := parseKubernetesPodStates(meshPodList)
+	if err != nil {
+		t.Fatalf("parseKubernetesPodStates() error = %v", err)
+	}
+
+	tests := []struct {
+		pod         string
+		ready       bool
+		reason      string
+		finishedJob bool
+	}{
+		{pod: "migrate-abc", reason: "finished job", finishedJob: true},
+		{pod: "database-0", reason: "sidecar istio-proxy not ready"},
+		{pod: "database-1", reason: "phase Pending"},
+		{pod: "database-2", ready: true},
+		{pod: "database-3", reason: "waiting for the sidecar to start"},
+	}
+	for i, tt := range tests {
+		t.Run(tt.pod, func(t *testing.T) {
+			pod := pods[i]
+			if pod.Name != tt.pod {
+				t.Fatalf("pod %d = %s, want %s", i, pod.Name, tt.pod)
+			}
+			ready, reason := pod.readiness()
+			if ready != tt.ready || reason != tt.reason {
+				t.Errorf("readiness() = %v, %q, want %v, %q", ready, reason, tt.ready, tt.reason)
+			}
+			if got := pod.finishedJob(); got != tt.finishedJob {
+				t.Errorf("finishedJob() = %v, want %v", got, tt.finishedJob)
+			}
+		})
+	}
+}
+
+func TestKubernetesStartWaitsForSidecar(t *testing.T) {
+	oldInterval := kubernetesReadyPollInterval
+	kubernetesReadyPollInterval = time.Millisecond
+	defer func() { kubernetesReadyPollInterval = oldInterval }()
+
+	notReady := `{"items": [
+  {"metadata": {"name": "migrate-abc", "ownerReferences": [{"kind": "Job"}]},
+   "status": {"phase": "Running", "containerStatuses": [
+     {"name": "migrate", "state": {"terminated": {}}},
+     {"name": "istio-proxy", "ready": true, "state": {"running": {}}}]}},
+  {"metadata": {"name": "database-0"},
+   "status": {"phase": "Running", "containerStatuses": [
+     {"name": "neo4j", "ready": true, "state": {"running": {}}},
+     {"name": "istio-proxy", "ready": false, "state": {"running": {}}}]}}
+]}`
+	fake := newFakeExecutor().
+		on("get pods -n infrahub -l app.kubernetes.io/component=database -o json", notReady, nil)
+	k := NewKubernetesBackend(&Configuration{
+		K8sReadyTimeout: 50 * time.Millisecond,
+		Kubernetes:      &KubernetesMapping{Services: map[string]KubernetesServiceOverride{"database": {Workload: "statefulset/database"}}},
+	}, fake)
+	k.namespace = "infrahub"
+
+	start := time.Now()
+	if err := k.Start("database"); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if time.Since(start) < 50*time.Millisecond {
+		t.Error("Start() returned before the sidecar was ready or the timeout passed")
+	}
+	if len(fake.commands("get pods -n infrahub -l app.kubernetes.io/component=database -o json")) < 2 {
+		t.Errorf("readiness was not polled: %v", fake.calls)
+	}
+	if n := len(fake.commands("kubectl exec -n infrahub migrate-abc -c istio-proxy -- pilot-agent request POST quitquitquit")); n != 1 {
+		t.Errorf("quitquitquit sent %d times, want 1", n)
+	}
+}
+
+func TestKubernetesStartWithoutReadyTimeout(t *testing.T) {
+	fake := newFakeExecutor()
+	k := NewKubernetesBackend(&Configuration{
+		Kubernetes: &KubernetesMapping{Services: map[string]KubernetesServiceOverride{"cache": {Workload: "deployment/cache"}}},
+	}, fake)
+	k.namespace = "infrahub"
+
+	if err := k.Start("cache"); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if len(fake.commands("-o json")) != 0 {
+		t.Errorf("readiness polled with a zero timeout: %v", fake.calls)
+	}
+}
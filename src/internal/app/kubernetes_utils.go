@@ -1,6 +1,8 @@
 package app
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"sort"
 	"strings"
@@ -9,24 +11,152 @@ import (
 )
 
 func (k *KubernetesBackend) podSelectors(service string) []string {
-	return []string{
+	if selectors := k.configuredSelectors(service); len(selectors) > 0 {
+		return selectors
+	}
+	selectors := []string{
 		fmt.Sprintf("app.kubernetes.io/component=%s", service),
 		fmt.Sprintf("app=%s", service),
 		fmt.Sprintf("component=%s", service),
 		fmt.Sprintf("infrahub/service=%s", service),
 	}
+	if instance := k.instanceSelector(); instance != "" {
+		for i := range selectors {
+			selectors[i] += "," + instance
+		}
+	}
+	return selectors
+}
+
+// instanceSelector narrows pod and workload lookups to the Helm release set
+// with --release-name. It is empty when every release in the namespace counts.
+func (k *KubernetesBackend) instanceSelector() string {
+	if k.config == nil || k.config.K8sReleaseName == "" {
+		return ""
+	}
+	return k.instanceLabel() + "=" + k.config.K8sReleaseName
+}
+
+func (k *KubernetesBackend) instanceLabel() string {
+	if k.config == nil || k.config.K8sInstanceLabel == "" {
+		return defaultInstanceLabel
+	}
+	return k.config.K8sInstanceLabel
+}
+
+// inRelease reports whether a resource found by name belongs to the selected
+// release. Helm prefixes resource names with the release name.
+func (k *KubernetesBackend) inRelease(name string) bool {
+	if k.config == nil || k.config.K8sReleaseName == "" {
+		return true
+	}
+	return name == k.config.K8sReleaseName || strings.HasPrefix(name, k.config.K8sReleaseName+"-")
+}
+
+// listReleases returns the distinct Helm releases of Infrahub in the namespace.
+func (k *KubernetesBackend) listReleases() ([]string, error) {
+	label := strings.ReplaceAll(k.instanceLabel(), ".", "\\.")
+	output, err := k.executor.runCommand("kubectl", "get", "pods", "-n", k.namespace, "-l", infrahubPodSelector, "-o", "jsonpath={range .items[*]}{.metadata.labels."+label+"}{\"\\n\"}{end}")
+	if err != nil {
+		return nil, err
+	}
+	releases := unique(nonEmptyLines(output))
+	sort.Strings(releases)
+	return releases, nil
+}
+
+// kubernetesPod is the subset of a pod object used for status lookups.
+type kubernetesPod struct {
+	Name   string
+	Labels map[string]string
+	Phase  string
+}
+
+// parseKubernetesPodList parses `kubectl get pods -o json` output.
+func parseKubernetesPodList(output string) ([]kubernetesPod, error) {
+	var list struct {
+		Items []struct {
+			Metadata struct {
+				Name   string            `json:"name"`
+				Labels map[string]string `json:"labels"`
+			} `json:"metadata"`
+			Status struct {
+				Phase string `json:"phase"`
+			} `json:"status"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal([]byte(output), &list); err != nil {
+		return nil, fmt.Errorf("failed to parse pod list: %w", err)
+	}
+
+	pods := make([]kubernetesPod, 0, len(list.Items))
+	for _, item := range list.Items {
+		pods = append(pods, kubernetesPod{
+			Name:   item.Metadata.Name,
+			Labels: item.Metadata.Labels,
+			Phase:  item.Status.Phase,
+		})
+	}
+	return pods, nil
+}
+
+// matchPodStatuses returns the phases of the pods belonging to service. The
+// first selector from podSelectors that matches any pod wins; otherwise pods
+// whose name contains the service name are used.
+func (k *KubernetesBackend) matchPodStatuses(pods []kubernetesPod, service string) []string {
+	for _, selector := range k.podSelectors(service) {
+		statuses := []string{}
+		for _, pod := range pods {
+			if selectorMatchesLabels(selector, pod.Labels) {
+				statuses = append(statuses, pod.Phase)
+			}
+		}
+		if len(statuses) > 0 {
+			return statuses
+		}
+	}
+
+	statuses := []string{}
+	for _, pod := range pods {
+		if strings.Contains(pod.Name, service) && k.inRelease(pod.Name) {
+			statuses = append(statuses, pod.Phase)
+		}
+	}
+	return statuses
+}
+
+// clusterName returns the cluster of the current kubeconfig context, or an
+// empty string when it cannot be determined (e.g. in-cluster config).
+func (k *KubernetesBackend) clusterName() string {
+	output, err := k.executor.runCommand("kubectl", "config", "view", "--minify", "-o", "jsonpath={.clusters[0].name}")
+	if err != nil {
+		logrus.Debugf("Could not determine kubernetes cluster name: %v", err)
+		return ""
+	}
+	return strings.TrimSpace(output)
+}
+
+// currentContext returns the current kubeconfig context, or an empty string
+// when it cannot be determined.
+func (k *KubernetesBackend) currentContext() string {
+	output, err := k.executor.runCommand("kubectl", "config", "current-context")
+	if err != nil {
+		logrus.Debugf("Could not determine kubernetes context: %v", err)
+		return ""
+	}
+	return strings.TrimSpace(output)
 }
 
 // findPrimaryPod searches for a pod with primary role label (for HA PostgreSQL clusters like CloudNativePG)
-func (k *KubernetesBackend) findPrimaryPod(pods []string) string {
+func (k *KubernetesBackend) findPrimaryPod(namespace string, pods []string) string {
 	for _, pod := range pods {
-		output, err := k.executor.runCommand("kubectl", "get", "pod", pod, "-n", k.namespace, "-o", "jsonpath={.metadata.labels.cnpg\\.io/instanceRole}")
+		output, err := k.executor.runCommand("kubectl", "get", "pod", pod, "-n", namespace, "-o", "jsonpath={.metadata.labels.cnpg\\.io/instanceRole}")
 		if err == nil && output == "primary" {
 			logrus.Debugf("Found primary pod via cnpg.io/instanceRole: %s", pod)
 			return pod
 		}
 		// Fallback to legacy role label
-		output, err = k.executor.runCommand("kubectl", "get", "pod", pod, "-n", k.namespace, "-o", "jsonpath={.metadata.labels.role}")
+		output, err = k.executor.runCommand("kubectl", "get", "pod", pod, "-n", namespace, "-o", "jsonpath={.metadata.labels.role}")
 		if err == nil && output == "primary" {
 			logrus.Debugf("Found primary pod via role label: %s", pod)
 			return pod
@@ -35,8 +165,69 @@ func (k *KubernetesBackend) findPrimaryPod(pods []string) string {
 	return ""
 }
 
-func ListKubernetesNamespaces(executor *CommandExecutor) ([]string, error) {
-	output, err := executor.runCommand("kubectl", "get", "pods", "-A", "-l", "app.kubernetes.io/name=infrahub", "-o", "jsonpath={range .items[*]}{.metadata.namespace}{\"\\n\"}{end}")
+// kubernetesSecretValue is one decoded key of a Kubernetes secret.
+type kubernetesSecretValue struct {
+	Secret string
+	Key    string
+	Value  string
+}
+
+// secretValues returns the decoded values stored under any of keys in the
+// secrets of the namespace, in secret name order. It fails when the caller may
+// not list secrets.
+func (k *KubernetesBackend) secretValues(keys ...string) ([]kubernetesSecretValue, error) {
+	args := []string{"get", "secrets", "-n", k.namespace, "-o", "json"}
+	if instance := k.instanceSelector(); instance != "" {
+		args = append(args, "-l", instance)
+	}
+	output, err := k.executor.runCommand("kubectl", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+	return parseSecretValues(output, keys)
+}
+
+// parseSecretValues extracts the base64-decoded values of keys from
+// `kubectl get secrets -o json` output.
+func parseSecretValues(output string, keys []string) ([]kubernetesSecretValue, error) {
+	var list struct {
+		Items []struct {
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+			Data map[string]string `json:"data"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal([]byte(output), &list); err != nil {
+		return nil, fmt.Errorf("failed to parse secret list: %w", err)
+	}
+	sort.Slice(list.Items, func(i, j int) bool { return list.Items[i].Metadata.Name < list.Items[j].Metadata.Name })
+
+	values := []kubernetesSecretValue{}
+	for _, item := range list.Items {
+		for _, key := range keys {
+			encoded, ok := item.Data[key]
+			if !ok {
+				continue
+			}
+			decoded, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				logrus.Debugf("Ignoring undecodable key %s in secret %s: %v", key, item.Metadata.Name, err)
+				continue
+			}
+			values = append(values, kubernetesSecretValue{Secret: item.Metadata.Name, Key: key, Value: string(decoded)})
+		}
+	}
+	return values, nil
+}
+
+func ListKubernetesNamespaces(executor CommandExecutor) ([]string, error) {
+	return listKubernetesNamespaces(executor, infrahubPodSelector)
+}
+
+// listKubernetesNamespaces returns the namespaces with pods matching selector.
+func listKubernetesNamespaces(executor CommandExecutor, selector string) ([]string, error) {
+	output, err := executor.runCommand("kubectl", "get", "pods", "-A", "-l", selector, "-o", "jsonpath={range .items[*]}{.metadata.namespace}{\"\\n\"}{end}")
 	if err != nil {
 		// Check if this is a permission/RBAC issue
 		outputLower := strings.ToLower(output)
@@ -0,0 +1,81 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatchPodStatuses(t *testing.T) {
+	pods := []kubernetesPod{
+		{Name: "infrahub-infrahub-server-0", Labels: map[string]string{"app.kubernetes.io/component": "infrahub-server"}, Phase: "Running"},
+		{Name: "infrahub-task-worker-abc", Labels: map[string]string{"app.kubernetes.io/component": "task-worker"}, Phase: "Pending"},
+		{Name: "infrahub-cache-0", Labels: map[string]string{"app": "cache"}, Phase: "Running"},
+		{Name: "infrahub-message-queue-0", Labels: map[string]string{}, Phase: "Running"},
+	}
+	k := &KubernetesBackend{}
+
+	tests := []struct {
+		service string
+		want    []string
+	}{
+		{service: "infrahub-server", want: []string{"Running"}},
+		{service: "task-worker", want: []string{"Pending"}},
+		{service: "cache", want: []string{"Running"}},
+		{service: "message-queue", want: []string{"Running"}},
+		{service: "database", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.service, func(t *testing.T) {
+			got := k.matchPodStatuses(pods, tt.service)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("matchPodStatuses(%q) = %v, want %v", tt.service, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSecretValues(t *testing.T) {
+	output := `{"items": [
+		{"metadata": {"name": "infrahub-server"}, "data": {"INFRAHUB_DB_PASSWORD": "cm90YXRlZA=="}},
+		{"metadata": {"name": "database-auth"}, "data": {"NEO4J_AUTH": "bmVvNGovczNjcmV0", "other": "eA=="}},
+		{"metadata": {"name": "broken"}, "data": {"NEO4J_AUTH": "%%%"}}
+	]}`
+
+	got, err := parseSecretValues(output, neo4jSecretKeys)
+	if err != nil {
+		t.Fatalf("parseSecretValues() error = %v", err)
+	}
+	want := []kubernetesSecretValue{
+		{Secret: "database-auth", Key: "NEO4J_AUTH", Value: "neo4j/s3cret"},
+		{Secret: "infrahub-server", Key: "INFRAHUB_DB_PASSWORD", Value: "rotated"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseSecretValues() = %+v, want %+v", got, want)
+	}
+}
+
+func TestReleaseScopedLookups(t *testing.T) {
+	pods := []kubernetesPod{
+		{Name: "prod-infrahub-server-0", Labels: map[string]string{"app.kubernetes.io/component": "infrahub-server", "app.kubernetes.io/instance": "prod"}, Phase: "Running"},
+		{Name: "staging-infrahub-server-0", Labels: map[string]string{"app.kubernetes.io/component": "infrahub-server", "app.kubernetes.io/instance": "staging"}, Phase: "Pending"},
+		{Name: "prod-cache-0", Phase: "Running"},
+		{Name: "staging-cache-0", Phase: "Failed"},
+	}
+	k := &KubernetesBackend{config: &Configuration{K8sReleaseName: "staging"}}
+
+	if got := k.podSelectors("database")[0]; got != "app.kubernetes.io/component=database,app.kubernetes.io/instance=staging" {
+		t.Errorf("podSelectors()[0] = %q", got)
+	}
+	if got := k.matchPodStatuses(pods, "infrahub-server"); !reflect.DeepEqual(got, []string{"Pending"}) {
+		t.Errorf("matchPodStatuses(infrahub-server) = %v, want [Pending]", got)
+	}
+	if got := k.matchPodStatuses(pods, "cache"); !reflect.DeepEqual(got, []string{"Failed"}) {
+		t.Errorf("matchPodStatuses(cache) = %v, want [Failed]", got)
+	}
+
+	k.config.K8sInstanceLabel = "release"
+	if got := k.instanceSelector(); got != "release=staging" {
+		t.Errorf("instanceSelector() = %q, want release=staging", got)
+	}
+}
@@ -8,18 +8,24 @@ import (
 
 type kubernetesWorkload struct {
 	Name           string
+	Annotations    map[string]string
 	SelectorLabels map[string]string
 	TemplateLabels map[string]string
 }
 
 // findWorkloadResource locates a workload (deployment or statefulset) for a service
 func (k *KubernetesBackend) findWorkloadResource(service string) (string, string, error) {
+	if override, ok := k.mapping().override(service); ok && override.Workload != "" {
+		return parseWorkloadRef(override.Workload)
+	}
+
+	namespace := k.namespaceFor(service)
 	kinds := []string{"deployment", "statefulset"}
 	selectors := k.podSelectors(service)
 
 	for _, kind := range kinds {
 		for _, selector := range selectors {
-			output, err := k.executor.runCommand("kubectl", "get", kind, "-n", k.namespace, "-l", selector, "-o", "jsonpath={range .items[*]}{.metadata.name}{\"\\n\"}{end}")
+			output, err := k.executor.runCommand("kubectl", "get", kind, "-n", namespace, "-l", selector, "-o", "jsonpath={range .items[*]}{.metadata.name}{\"\\n\"}{end}")
 			if err != nil || output == "" {
 				continue
 			}
@@ -29,7 +35,7 @@ func (k *KubernetesBackend) findWorkloadResource(service string) (string, string
 			}
 		}
 
-		if workloads, err := k.listWorkloads(kind); err == nil {
+		if workloads, err := k.listWorkloads(namespace, kind); err == nil {
 			var candidate string
 			for _, workload := range workloads {
 				for _, selector := range selectors {
@@ -37,7 +43,7 @@ func (k *KubernetesBackend) findWorkloadResource(service string) (string, string
 						return kind, workload.Name, nil
 					}
 				}
-				if candidate == "" && strings.Contains(workload.Name, service) {
+				if candidate == "" && strings.Contains(workload.Name, service) && k.inRelease(workload.Name) {
 					candidate = workload.Name
 				}
 			}
@@ -46,12 +52,12 @@ func (k *KubernetesBackend) findWorkloadResource(service string) (string, string
 			}
 		}
 
-		output, err := k.executor.runCommand("kubectl", "get", kind, "-n", k.namespace, "-o", "jsonpath={range .items[*]}{.metadata.name}{\"\\n\"}{end}")
+		output, err := k.executor.runCommand("kubectl", "get", kind, "-n", namespace, "-o", "jsonpath={range .items[*]}{.metadata.name}{\"\\n\"}{end}")
 		if err != nil {
 			continue
 		}
 		for _, name := range nonEmptyLines(output) {
-			if strings.Contains(name, service) {
+			if strings.Contains(name, service) && k.inRelease(name) {
 				return kind, name, nil
 			}
 		}
@@ -60,8 +66,8 @@ func (k *KubernetesBackend) findWorkloadResource(service string) (string, string
 	return "", "", fmt.Errorf("no workloads found")
 }
 
-func (k *KubernetesBackend) listWorkloads(kind string) ([]kubernetesWorkload, error) {
-	output, err := k.executor.runCommand("kubectl", "get", kind, "-n", k.namespace, "-o", "json")
+func (k *KubernetesBackend) listWorkloads(namespace, kind string) ([]kubernetesWorkload, error) {
+	output, err := k.executor.runCommand("kubectl", "get", kind, "-n", namespace, "-o", "json")
 	if err != nil {
 		return nil, err
 	}
@@ -69,7 +75,8 @@ func (k *KubernetesBackend) listWorkloads(kind string) ([]kubernetesWorkload, er
 	var parsed struct {
 		Items []struct {
 			Metadata struct {
-				Name string `json:"name"`
+				Name        string            `json:"name"`
+				Annotations map[string]string `json:"annotations"`
 			} `json:"metadata"`
 			Spec struct {
 				Selector struct {
@@ -92,6 +99,7 @@ func (k *KubernetesBackend) listWorkloads(kind string) ([]kubernetesWorkload, er
 	for _, item := range parsed.Items {
 		workloads = append(workloads, kubernetesWorkload{
 			Name:           item.Metadata.Name,
+			Annotations:    item.Metadata.Annotations,
 			SelectorLabels: item.Spec.Selector.MatchLabels,
 			TemplateLabels: item.Spec.Template.Metadata.Labels,
 		})
@@ -109,15 +117,15 @@ func (k *KubernetesBackend) scaleServices(services []string, replicas int) error
 		if err != nil {
 			return fmt.Errorf("failed to resolve workload for %s: %w", service, err)
 		}
-		if err := k.scaleResource(kind, resource, replicas); err != nil {
+		if err := k.scaleResource(k.namespaceFor(service), kind, resource, replicas); err != nil {
 			return fmt.Errorf("failed to scale %s (%s/%s) to %d replicas: %w", service, kind, resource, replicas, err)
 		}
 	}
-	k.podCache = map[string]string{}
+	k.resetPodCache()
 	return nil
 }
 
-func (k *KubernetesBackend) scaleResource(kind, resource string, replicas int) error {
-	_, err := k.executor.runCommand("kubectl", "scale", "-n", k.namespace, fmt.Sprintf("%s/%s", kind, resource), fmt.Sprintf("--replicas=%d", replicas))
+func (k *KubernetesBackend) scaleResource(namespace, kind, resource string, replicas int) error {
+	_, err := k.executor.runCommand("kubectl", "scale", "-n", namespace, fmt.Sprintf("%s/%s", kind, resource), fmt.Sprintf("--replicas=%d", replicas))
 	return err
 }
@@ -0,0 +1,395 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// machineStateFilename records, in BackupDir, the backup last restored into
+// each target by --machine so a repeated restore request is a no-op.
+const machineStateFilename = "machine_state.json"
+
+// MachineRequest is the JSON document read from stdin by --machine.
+type MachineRequest struct {
+	Operation string          `json:"operation"`
+	Params    json.RawMessage `json:"params,omitempty"`
+}
+
+// MachineResult is the JSON document written to stdout by --machine. Its
+// changed, failed and msg fields follow the Ansible module conventions.
+type MachineResult struct {
+	Changed   bool     `json:"changed"`
+	Failed    bool     `json:"failed"`
+	Msg       string   `json:"msg"`
+	Operation string   `json:"operation,omitempty"`
+	Result    any      `json:"result,omitempty"`
+	Warnings  []string `json:"warnings,omitempty"`
+}
+
+// MachineOperation runs one --machine operation from its params. It reports
+// whether anything was changed and the operation-specific result.
+type MachineOperation func(iops *InfrahubOps, params json.RawMessage) (changed bool, result any, err error)
+
+// BackupMachineOperations are the --machine operations of infrahub-backup.
+var BackupMachineOperations = map[string]MachineOperation{
+	"backup":  machineBackup,
+	"restore": machineRestore,
+}
+
+// TaskManagerMachineOperations are the --machine operations of
+// infrahub-taskmanager.
+var TaskManagerMachineOperations = map[string]MachineOperation{
+	"flush": machineFlush,
+}
+
+// RunMachine reads a MachineRequest from r, runs it without prompting and
+// writes a MachineResult to w. The result is written on failure too, and the
+// error is returned so the exit status still reflects it.
+func (iops *InfrahubOps) RunMachine(r io.Reader, w io.Writer, operations map[string]MachineOperation) error {
+	// Nothing but the result may reach stdout; output written there by the
+	// operation, such as plakar's, goes to stderr instead.
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	defer func() { os.Stdout = stdout }()
+
+	iops.config.NonInteractive = true
+	mark := iops.warnings.mark()
+
+	result := MachineResult{}
+	err := iops.runMachineRequest(r, operations, &result)
+	result.Warnings = iops.warnings.since(mark)
+	if err != nil {
+		result.Failed = true
+		result.Msg = err.Error()
+	}
+
+	data, marshalErr := json.Marshal(result)
+	if marshalErr != nil {
+		return fmt.Errorf("failed to encode machine result: %w", marshalErr)
+	}
+	if _, writeErr := w.Write(append(data, '\n')); writeErr != nil {
+		return fmt.Errorf("failed to write machine result: %w", writeErr)
+	}
+	return err
+}
+
+func (iops *InfrahubOps) runMachineRequest(r io.Reader, operations map[string]MachineOperation, result *MachineResult) error {
+	if iops.settings.GetString("events") != "" && iops.settings.GetInt("events-fd") == 1 {
+		return fmt.Errorf("--machine writes its result to stdout; send --events to another descriptor with --events-fd")
+	}
+
+	var request MachineRequest
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		return fmt.Errorf("invalid machine request on stdin: %w", err)
+	}
+	result.Operation = request.Operation
+
+	operation, ok := operations[request.Operation]
+	if !ok {
+		return fmt.Errorf("unknown machine operation %q (supported: %s)", request.Operation, machineOperationNames(operations))
+	}
+	changed, value, err := operation(iops, request.Params)
+	result.Changed = changed
+	result.Result = value
+	if err != nil {
+		return err
+	}
+	result.Msg = request.Operation + " not needed"
+	if changed {
+		result.Msg = request.Operation + " completed"
+	}
+	return nil
+}
+
+func machineOperationNames(operations map[string]MachineOperation) string {
+	names := make([]string, 0, len(operations))
+	for name := range operations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// decodeMachineParams decodes params into v, rejecting unknown fields so a
+// misspelt parameter is not silently ignored.
+func decodeMachineParams(params json.RawMessage, v any) error {
+	if len(params) == 0 {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(params))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("invalid params: %w", err)
+	}
+	return nil
+}
+
+// runReported runs fn under RunWithReport and returns a copy of the report.
+func (iops *InfrahubOps) runReported(operation string, fn func() error) (RunReport, error) {
+	var report RunReport
+	err := iops.RunWithReport(operation, func() error {
+		err := fn()
+		report = *iops.report
+		return err
+	})
+	return report, err
+}
+
+type machineBackupParams struct {
+	Force              bool   `json:"force"`
+	Neo4jMetadata      string `json:"neo4j_metadata"`
+	ExcludeTaskManager bool   `json:"exclude_taskmanager"`
+	S3Upload           bool   `json:"s3_upload"`
+	S3KeepLocal        bool   `json:"s3_keep_local"`
+	Encrypt            bool   `json:"encrypt"`
+	EncryptKey         string `json:"encrypt_key"`
+	MaxAge             string `json:"max_age"` // skip the backup when the catalog has one at most this old
+}
+
+type machineBackupResult struct {
+	BackupID   string `json:"backup_id"`
+	BackupPath string `json:"backup_path,omitempty"`
+	S3URI      string `json:"s3_uri,omitempty"`
+	SizeBytes  int64  `json:"size_bytes,omitempty"`
+	CreatedAt  string `json:"created_at,omitempty"`
+}
+
+// machineBackup creates a backup unless max_age is set and the catalog in
+// BackupDir already has a backup that recent.
+func machineBackup(iops *InfrahubOps, raw json.RawMessage) (bool, any, error) {
+	params := machineBackupParams{Neo4jMetadata: "all"}
+	if err := decodeMachineParams(raw, &params); err != nil {
+		return false, nil, err
+	}
+	if params.MaxAge != "" {
+		maxAge, err := time.ParseDuration(params.MaxAge)
+		if err != nil {
+			return false, nil, fmt.Errorf("invalid max_age %q: %w", params.MaxAge, err)
+		}
+		recent, err := recentCatalogEntry(iops.config.BackupDir, maxAge, time.Now())
+		if err != nil {
+			return false, nil, err
+		}
+		if recent != nil {
+			return false, machineBackupResult{
+				BackupID:   recent.BackupID,
+				BackupPath: recent.LocalPath,
+				S3URI:      recent.S3URI,
+				SizeBytes:  recent.SizeBytes,
+				CreatedAt:  recent.CreatedAt,
+			}, nil
+		}
+	}
+	if err := iops.ValidateBackendFlags(params.S3Upload); err != nil {
+		return false, nil, err
+	}
+
+	report, err := iops.runReported("backup", func() error {
+		return iops.CreateBackup(params.Force, params.Neo4jMetadata, params.ExcludeTaskManager, params.S3Upload, params.S3KeepLocal, 0, false, params.Encrypt || params.EncryptKey != "", params.EncryptKey)
+	})
+	if err != nil {
+		return false, nil, err
+	}
+	return true, machineBackupResult{
+		BackupID:   report.BackupID,
+		BackupPath: report.BackupPath,
+		S3URI:      report.S3URI,
+		SizeBytes:  report.SizeBytes,
+	}, nil
+}
+
+// recentCatalogEntry returns the newest catalog entry created within maxAge
+// of now, or nil.
+func recentCatalogEntry(backupDir string, maxAge time.Duration, now time.Time) (*CatalogEntry, error) {
+	catalog, err := loadBackupCatalog(backupDir)
+	if err != nil {
+		return nil, err
+	}
+	var newest *CatalogEntry
+	var newestAt time.Time
+	for i := range catalog.Entries {
+		entry := &catalog.Entries[i]
+		createdAt, err := time.Parse(time.RFC3339, entry.CreatedAt)
+		if err != nil || now.Sub(createdAt) > maxAge {
+			continue
+		}
+		if newest == nil || createdAt.After(newestAt) {
+			newest, newestAt = entry, createdAt
+		}
+	}
+	return newest, nil
+}
+
+type machineRestoreParams struct {
+	Backup             string `json:"backup"` // archive path or S3 URI
+	Always             bool   `json:"always"` // restore even if this backup was the last one restored into the target
+	ExcludeTaskManager bool   `json:"exclude_taskmanager"`
+	MigrateFormat      bool   `json:"migrate_format"`
+	DecryptKey         string `json:"decrypt_key"`
+	Force              bool   `json:"force"`
+	ResetDeploymentID  bool   `json:"reset_deployment_id"`
+	MinimizeDowntime   bool   `json:"minimize_downtime"`
+}
+
+// machineRestoreRecord is the machine state entry of one target.
+type machineRestoreRecord struct {
+	Backup     string `json:"backup"`
+	BackupID   string `json:"backup_id,omitempty"`
+	RestoredAt string `json:"restored_at"`
+}
+
+type machineRestoreResult struct {
+	Target string `json:"target"`
+	machineRestoreRecord
+}
+
+// machineRestore restores params.Backup unless it is the backup last restored
+// into the detected target by --machine.
+func machineRestore(iops *InfrahubOps, raw json.RawMessage) (bool, any, error) {
+	var params machineRestoreParams
+	if err := decodeMachineParams(raw, &params); err != nil {
+		return false, nil, err
+	}
+	if params.Backup == "" {
+		return false, nil, fmt.Errorf("params.backup is required")
+	}
+	if err := iops.ValidateBackendFlags(false); err != nil {
+		return false, nil, err
+	}
+	backend, err := iops.ensureBackend()
+	if err != nil {
+		return false, nil, err
+	}
+	target := backend.Name() + "/" + backend.Info()
+
+	state, err := loadMachineState(iops.config.BackupDir)
+	if err != nil {
+		return false, nil, err
+	}
+	if last, ok := state.Restores[target]; ok && !params.Always && state.sameBackup(last, params.Backup, iops.config.BackupDir) {
+		return false, machineRestoreResult{Target: target, machineRestoreRecord: last}, nil
+	}
+
+	report, err := iops.runReported("restore", func() error {
+		return iops.RestoreBackup(params.Backup, params.ExcludeTaskManager, params.MigrateFormat, 0, params.DecryptKey, params.Force, params.ResetDeploymentID, params.MinimizeDowntime)
+	})
+	if err != nil {
+		return false, nil, err
+	}
+	record := machineRestoreRecord{Backup: params.Backup, BackupID: report.BackupID, RestoredAt: time.Now().UTC().Format(time.RFC3339)}
+	state.Restores[target] = record
+	if err := state.save(); err != nil {
+		return true, nil, err
+	}
+	return true, machineRestoreResult{Target: target, machineRestoreRecord: record}, nil
+}
+
+// machineState is the content of machineStateFilename.
+type machineState struct {
+	path     string
+	Restores map[string]machineRestoreRecord `json:"restores"`
+}
+
+func loadMachineState(backupDir string) (*machineState, error) {
+	state := &machineState{path: filepath.Join(backupDir, machineStateFilename), Restores: map[string]machineRestoreRecord{}}
+	data, err := os.ReadFile(state.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read machine state: %w", err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse machine state %s: %w", state.path, err)
+	}
+	if state.Restores == nil {
+		state.Restores = map[string]machineRestoreRecord{}
+	}
+	return state, nil
+}
+
+func (s *machineState) save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create machine state directory: %w", err)
+	}
+	data, err := json.MarshalIndent(s, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal machine state: %w", err)
+	}
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write machine state: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace machine state: %w", err)
+	}
+	return nil
+}
+
+// sameBackup reports whether backup names the archive of last, by the same
+// reference or by the backup ID the catalog knows it under.
+func (s *machineState) sameBackup(last machineRestoreRecord, backup, backupDir string) bool {
+	if last.Backup == backup {
+		return true
+	}
+	if last.BackupID == "" {
+		return false
+	}
+	catalog, err := loadBackupCatalog(backupDir)
+	if err != nil {
+		return false
+	}
+	entry := catalog.find(backup)
+	return entry != nil && entry.BackupID == last.BackupID
+}
+
+type machineFlushParams struct {
+	Kind       string `json:"kind"` // flow-runs or stale-runs
+	DaysToKeep *int   `json:"days_to_keep"`
+	BatchSize  int    `json:"batch_size"`
+	AutoBackup bool   `json:"auto_backup"` // back up the task manager database first
+}
+
+type machineFlushResult struct {
+	Kind       string `json:"kind"`
+	DaysToKeep int    `json:"days_to_keep"`
+	BatchSize  int    `json:"batch_size"`
+}
+
+// machineFlush flushes task manager runs. The cleanup does not report what it
+// removed, so the result is always changed.
+func machineFlush(iops *InfrahubOps, raw json.RawMessage) (bool, any, error) {
+	params := machineFlushParams{Kind: flowRunsConfig.commandType, BatchSize: defaultBatchSize}
+	if err := decodeMachineParams(raw, &params); err != nil {
+		return false, nil, err
+	}
+	var config flushConfig
+	switch params.Kind {
+	case flowRunsConfig.commandType:
+		config = flowRunsConfig
+	case staleRunsConfig.commandType:
+		config = staleRunsConfig
+	default:
+		return false, nil, fmt.Errorf("invalid kind %q: expected flow-runs or stale-runs", params.Kind)
+	}
+	days := config.defaultDaysToKeep
+	if params.DaysToKeep != nil {
+		days = *params.DaysToKeep
+	}
+	iops.config.FlushAutoBackup = iops.config.FlushAutoBackup || params.AutoBackup
+	if err := iops.flushTaskRuns(config, days, params.BatchSize); err != nil {
+		return false, nil, err
+	}
+	return true, machineFlushResult{Kind: params.Kind, DaysToKeep: days, BatchSize: params.BatchSize}, nil
+}
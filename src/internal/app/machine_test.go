@@ -0,0 +1,132 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func runMachineForTest(t *testing.T, iops *InfrahubOps, request string, operations map[string]MachineOperation) (MachineResult, error) {
+	t.Helper()
+	var out bytes.Buffer
+	err := iops.RunMachine(strings.NewReader(request), &out, operations)
+	var result MachineResult
+	if decodeErr := json.Unmarshal(out.Bytes(), &result); decodeErr != nil {
+		t.Fatalf("invalid machine result %q: %v", out.String(), decodeErr)
+	}
+	return result, err
+}
+
+func TestRunMachineRejectsBadRequests(t *testing.T) {
+	tests := []struct {
+		name    string
+		request string
+		wantMsg string
+	}{
+		{name: "not json", request: "backup", wantMsg: "invalid machine request"},
+		{name: "unknown field", request: `{"operation":"backup","parms":{}}`, wantMsg: "unknown field"},
+		{name: "unknown operation", request: `{"operation":"flush"}`, wantMsg: "supported: backup, restore"},
+		{name: "unknown param", request: `{"operation":"backup","params":{"max_age":"1h","s3upload":true}}`, wantMsg: "invalid params"},
+		{name: "bad max_age", request: `{"operation":"backup","params":{"max_age":"1 day"}}`, wantMsg: "invalid max_age"},
+		{name: "restore without backup", request: `{"operation":"restore","params":{}}`, wantMsg: "params.backup is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeExecutor()
+			iops := newFakeDockerOps(fake)
+			iops.config.BackupDir = t.TempDir()
+
+			result, err := runMachineForTest(t, iops, tt.request, BackupMachineOperations)
+			if err == nil {
+				t.Fatal("RunMachine() succeeded")
+			}
+			if !result.Failed || result.Changed || !strings.Contains(result.Msg, tt.wantMsg) {
+				t.Errorf("result = %+v, want failure mentioning %q", result, tt.wantMsg)
+			}
+			if len(fake.calls) != 0 {
+				t.Errorf("commands ran for a rejected request: %v", fake.calls)
+			}
+		})
+	}
+}
+
+func TestMachineBackupMaxAge(t *testing.T) {
+	backupDir := t.TempDir()
+	catalog := &BackupCatalog{path: filepath.Join(backupDir, backupCatalogFilename), Entries: []CatalogEntry{
+		{BackupID: "old", CreatedAt: time.Now().Add(-48 * time.Hour).UTC().Format(time.RFC3339)},
+		{BackupID: "recent", LocalPath: "/backups/recent.tar.gz", CreatedAt: time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339)},
+	}}
+	if err := catalog.save(); err != nil {
+		t.Fatal(err)
+	}
+	fake := newFakeExecutor()
+	iops := newFakeDockerOps(fake)
+	iops.config.BackupDir = backupDir
+
+	result, err := runMachineForTest(t, iops, `{"operation":"backup","params":{"max_age":"24h"}}`, BackupMachineOperations)
+	if err != nil {
+		t.Fatalf("RunMachine() error = %v", err)
+	}
+	if result.Changed || result.Failed || result.Msg != "backup not needed" {
+		t.Errorf("result = %+v", result)
+	}
+	if got := result.Result.(map[string]any)["backup_id"]; got != "recent" {
+		t.Errorf("backup_id = %v, want recent", got)
+	}
+	if len(fake.calls) != 0 {
+		t.Errorf("commands ran for a fresh enough backup: %v", fake.calls)
+	}
+}
+
+func TestMachineRestoreSkipsLastRestoredBackup(t *testing.T) {
+	backupDir := t.TempDir()
+	catalog := &BackupCatalog{path: filepath.Join(backupDir, backupCatalogFilename), Entries: []CatalogEntry{
+		{BackupID: "infrahub_backup_20260101", Filename: "infrahub_backup_20260101.tar.gz", S3URI: "s3://backups/infrahub_backup_20260101.tar.gz"},
+	}}
+	if err := catalog.save(); err != nil {
+		t.Fatal(err)
+	}
+	state := &machineState{path: filepath.Join(backupDir, machineStateFilename), Restores: map[string]machineRestoreRecord{
+		"docker/test": {Backup: "/backups/infrahub_backup_20260101.tar.gz", BackupID: "infrahub_backup_20260101", RestoredAt: "2026-01-02T00:00:00Z"},
+	}}
+	if err := state.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		backup string
+	}{
+		{name: "same path", backup: "/backups/infrahub_backup_20260101.tar.gz"},
+		{name: "same backup from S3", backup: "s3://backups/infrahub_backup_20260101.tar.gz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeExecutor()
+			iops := newFakeDockerOps(fake)
+			iops.config.BackupDir = backupDir
+
+			result, err := runMachineForTest(t, iops, `{"operation":"restore","params":{"backup":"`+tt.backup+`"}}`, BackupMachineOperations)
+			if err != nil {
+				t.Fatalf("RunMachine() error = %v", err)
+			}
+			if result.Changed || result.Result.(map[string]any)["target"] != "docker/test" {
+				t.Errorf("result = %+v", result)
+			}
+			if len(fake.calls) != 0 {
+				t.Errorf("commands ran for an already restored backup: %v", fake.calls)
+			}
+		})
+	}
+}
+
+func TestMachineFlushKind(t *testing.T) {
+	iops := newFakeDockerOps(newFakeExecutor())
+	result, err := runMachineForTest(t, iops, `{"operation":"flush","params":{"kind":"deployments"}}`, TaskManagerMachineOperations)
+	if err == nil || !result.Failed || !strings.Contains(result.Msg, "expected flow-runs or stale-runs") {
+		t.Errorf("result = %+v, error = %v", result, err)
+	}
+}
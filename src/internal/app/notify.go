@@ -0,0 +1,308 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// notifyTimeout bounds each notification, so an unreachable endpoint never
+// delays the end of a run noticeably.
+const notifyTimeout = 10 * time.Second
+
+// Default notification templates: the webhook receives the whole
+// notification as JSON, Slack a one-line summary.
+const (
+	defaultWebhookTemplate = `{{ json . }}`
+	defaultSlackTemplate   = `{"text": {{ json .Summary }}}`
+)
+
+// Notification is the data notification templates are rendered with: the
+// run report of a create or restore.
+type Notification struct {
+	Tool            string               `json:"tool"`
+	Operation       string               `json:"operation"`
+	Status          string               `json:"status"` // success, degraded or failure
+	Succeeded       bool                 `json:"succeeded"`
+	Summary         string               `json:"summary"`
+	Target          string               `json:"target,omitempty"`
+	Host            string               `json:"host,omitempty"`
+	StartedAt       time.Time            `json:"started_at"`
+	FinishedAt      time.Time            `json:"finished_at"`
+	DurationSeconds float64              `json:"duration_seconds"`
+	Duration        string               `json:"duration"`
+	Error           string               `json:"error,omitempty"`
+	BackupID        string               `json:"backup_id,omitempty"`
+	BackupPath      string               `json:"backup_path,omitempty"`
+	S3URI           string               `json:"s3_uri,omitempty"`
+	SizeBytes       int64                `json:"size_bytes,omitempty"`
+	Size            string               `json:"size,omitempty"`
+	Degraded        []string             `json:"degraded,omitempty"`
+	Warnings        []string             `json:"warnings,omitempty"`
+	Targets         []NotificationTarget `json:"targets,omitempty"`
+	SLO             []NotificationSLO    `json:"slo,omitempty"`
+	SLOBreached     bool                 `json:"slo_breached"`
+}
+
+// NotificationTarget is the outcome of a restore on one of several targets.
+type NotificationTarget struct {
+	Spec            string  `json:"spec"`
+	Backend         string  `json:"backend"`
+	Status          string  `json:"status"` // success or failure
+	Error           string  `json:"error,omitempty"`
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+// NotificationSLO is the evaluation of the run against --rpo or --rto.
+type NotificationSLO struct {
+	Objective     string  `json:"objective"` // rpo or rto
+	Target        string  `json:"target"`
+	Actual        string  `json:"actual"`
+	TargetSeconds float64 `json:"target_seconds"`
+	ActualSeconds float64 `json:"actual_seconds"`
+	Breached      bool    `json:"breached"`
+}
+
+// notifier is one notification channel: the template rendering its body
+// and how the body is sent.
+type notifier struct {
+	name     string
+	template *template.Template
+	jsonBody bool // the rendered body must be valid JSON
+	send     func(body []byte, data Notification) error
+}
+
+// newNotification describes report, a run against target.
+func newNotification(report *RunReport, target string) Notification {
+	n := Notification{
+		Tool:            "infrahub-backup",
+		Operation:       report.Operation,
+		Status:          report.Status(),
+		Succeeded:       report.Succeeded(),
+		Target:          target,
+		StartedAt:       report.StartedAt.UTC(),
+		FinishedAt:      report.StartedAt.Add(report.Duration).UTC(),
+		DurationSeconds: report.Duration.Round(time.Millisecond).Seconds(),
+		Duration:        report.Duration.Round(time.Second).String(),
+		BackupID:        report.BackupID,
+		BackupPath:      report.BackupPath,
+		S3URI:           report.S3URI,
+		SizeBytes:       report.SizeBytes,
+		Degraded:        report.Degraded,
+		Warnings:        report.Warnings,
+	}
+	n.Host, _ = os.Hostname()
+	if report.Err != nil {
+		n.Error = report.Err.Error()
+	}
+	if report.SizeBytes > 0 {
+		n.Size = formatBytes(report.SizeBytes)
+	}
+	for _, result := range report.Targets {
+		t := NotificationTarget{
+			Spec:            result.Target.Spec,
+			Backend:         result.Target.Backend,
+			Status:          "success",
+			DurationSeconds: result.Duration.Round(time.Millisecond).Seconds(),
+		}
+		if result.Err != nil {
+			t.Status, t.Error = "failure", result.Err.Error()
+		}
+		n.Targets = append(n.Targets, t)
+	}
+	for _, result := range report.SLO {
+		n.SLO = append(n.SLO, NotificationSLO{
+			Objective:     result.Objective,
+			Target:        result.Target.String(),
+			Actual:        result.Actual.Round(time.Second).String(),
+			TargetSeconds: result.Target.Seconds(),
+			ActualSeconds: result.Actual.Round(time.Second).Seconds(),
+			Breached:      result.Breached,
+		})
+		n.SLOBreached = n.SLOBreached || result.Breached
+	}
+	n.Summary = notificationSummary(n)
+	return n
+}
+
+// notificationSummary is the one-line summary of n.
+func notificationSummary(n Notification) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Infrahub %s", n.Operation)
+	if n.Target != "" {
+		fmt.Fprintf(&b, " of %s", n.Target)
+	}
+	switch n.Status {
+	case "failure":
+		fmt.Fprintf(&b, " failed after %s: %s", n.Duration, n.Error)
+	case "degraded":
+		fmt.Fprintf(&b, " completed in %s but %s did not stabilize", n.Duration, strings.Join(n.Degraded, ", "))
+	default:
+		fmt.Fprintf(&b, " succeeded in %s", n.Duration)
+	}
+	if n.BackupID != "" && n.Status != "failure" {
+		fmt.Fprintf(&b, " (%s", n.BackupID)
+		if n.Size != "" {
+			fmt.Fprintf(&b, ", %s", n.Size)
+		}
+		b.WriteString(")")
+	}
+	var breaches []string
+	for _, slo := range n.SLO {
+		if slo.Breached {
+			breaches = append(breaches, fmt.Sprintf("%s %s (target %s)", strings.ToUpper(slo.Objective), slo.Actual, slo.Target))
+		}
+	}
+	if len(breaches) > 0 {
+		fmt.Fprintf(&b, "; SLO breached: %s", strings.Join(breaches, ", "))
+	}
+	return b.String()
+}
+
+// notificationFuncs are available in notification templates besides the
+// text/template builtins.
+var notificationFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// loadNotificationTemplate parses the template file path, or fallback when
+// path is empty.
+func loadNotificationTemplate(name, path, fallback string) (*template.Template, error) {
+	text := fallback
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s template: %w", name, err)
+		}
+		text = string(data)
+	}
+	tmpl, err := template.New(name).Funcs(notificationFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s template: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// notifiers returns the configured notification channels.
+func (iops *InfrahubOps) notifiers() ([]notifier, error) {
+	post := func(url string) func([]byte, Notification) error {
+		return func(body []byte, _ Notification) error {
+			client, err := iops.config.HTTP.Client(notifyTimeout)
+			if err != nil {
+				return err
+			}
+			return postNotification(client, url, body)
+		}
+	}
+	channels := []struct {
+		name, template, fallback string
+		enabled, jsonBody        bool
+		send                     func([]byte, Notification) error
+	}{
+		{"webhook", iops.config.NotifyWebhookTemplate, defaultWebhookTemplate, iops.config.NotifyWebhook != "", true, post(iops.config.NotifyWebhook)},
+		{"slack", iops.config.NotifySlackTemplate, defaultSlackTemplate, iops.config.NotifySlackWebhook != "", true, post(iops.config.NotifySlackWebhook)},
+		{"email", iops.config.NotifyEmailTemplate, defaultEmailTemplate, len(iops.config.NotifyEmailTo) > 0, false, iops.sendEmailNotification},
+	}
+	var notifiers []notifier
+	for _, channel := range channels {
+		if !channel.enabled {
+			continue
+		}
+		tmpl, err := loadNotificationTemplate(channel.name, channel.template, channel.fallback)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, notifier{name: channel.name, template: tmpl, jsonBody: channel.jsonBody, send: channel.send})
+	}
+	return notifiers, nil
+}
+
+// render executes the template of n with data. A JSON body must be valid
+// JSON, so a template mistake is reported instead of sent.
+func (n notifier) render(data Notification) ([]byte, error) {
+	var body bytes.Buffer
+	if err := n.template.Execute(&body, data); err != nil {
+		return nil, fmt.Errorf("failed to render %s template: %w", n.name, err)
+	}
+	if n.jsonBody && !json.Valid(body.Bytes()) {
+		return nil, fmt.Errorf("%s template did not render valid JSON: %s", n.name, strings.TrimSpace(body.String()))
+	}
+	return body.Bytes(), nil
+}
+
+// checkNotificationTemplates renders every configured template with a sample
+// run, for config validate.
+func (iops *InfrahubOps) checkNotificationTemplates() error {
+	notifiers, err := iops.notifiers()
+	if err != nil {
+		return err
+	}
+	sample := newNotification(&RunReport{
+		Operation:  "backup",
+		StartedAt:  time.Now(),
+		Duration:   time.Minute,
+		BackupID:   "infrahub_backup_20260101_000000",
+		BackupPath: "/backups/infrahub_backup_20260101_000000.tar.gz",
+		SizeBytes:  1 << 30,
+	}, "docker/infrahub")
+	for _, n := range notifiers {
+		if _, err := n.render(sample); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notify posts report to every configured channel. Failures are logged; they
+// never change the outcome of the run.
+func (iops *InfrahubOps) notify(report *RunReport) {
+	notifiers, err := iops.notifiers()
+	if err != nil {
+		logrus.Warnf("Notifications not sent: %v", err)
+		return
+	}
+	if len(notifiers) == 0 {
+		return
+	}
+	target := ""
+	if backend, err := iops.ensureBackend(); err == nil {
+		target = backend.Info()
+	}
+	data := newNotification(report, target)
+	for _, n := range notifiers {
+		body, err := n.render(data)
+		if err == nil {
+			err = n.send(body, data)
+		}
+		if err != nil {
+			logrus.Warnf("Failed to send %s notification: %v", n.name, err)
+			continue
+		}
+		logrus.Debugf("Sent %s notification", n.name)
+	}
+}
+
+func postNotification(client *http.Client, url string, body []byte) error {
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("endpoint returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,286 @@
+package app
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strings"
+	"time"
+)
+
+// SMTP connection security, as set by --smtp-tls.
+const (
+	SMTPStartTLS = "starttls" // upgrade a plain connection, usually on port 587
+	SMTPTLS      = "tls"      // implicit TLS, usually on port 465
+	SMTPNone     = "none"     // no encryption, for relays on localhost
+)
+
+// smtpTimeout bounds the whole SMTP conversation.
+const smtpTimeout = 30 * time.Second
+
+// defaultEmailTemplate renders the plain text body of email notifications.
+const defaultEmailTemplate = `{{ .Summary }}
+
+Operation: {{ .Operation }}
+Status:    {{ .Status }}
+{{- if .Target }}
+Target:    {{ .Target }}{{ end }}
+{{- if .Host }}
+Host:      {{ .Host }}{{ end }}
+Started:   {{ .StartedAt.Format "2006-01-02 15:04:05 MST" }}
+Duration:  {{ .Duration }}
+{{- if .BackupID }}
+Backup ID: {{ .BackupID }}{{ end }}
+{{- if .BackupPath }}
+Path:      {{ .BackupPath }}{{ end }}
+{{- if .S3URI }}
+S3 URI:    {{ .S3URI }}{{ end }}
+{{- if .Size }}
+Size:      {{ .Size }}{{ end }}
+{{- if .Error }}
+
+Error:
+{{ .Error }}{{ end }}
+{{- range $i, $s := .SLO }}{{ if eq $i 0 }}
+
+Service level objectives:{{ end }}
+  {{ upper $s.Objective }}: {{ $s.Actual }} (target {{ $s.Target }}){{ if $s.Breached }} BREACHED{{ end }}{{ end }}
+{{- if .Degraded }}
+
+Services that did not stabilize: {{ join .Degraded ", " }}{{ end }}
+{{- range $i, $t := .Targets }}{{ if eq $i 0 }}
+
+Targets:{{ end }}
+  {{ $t.Spec }}: {{ $t.Status }}{{ if $t.Error }} ({{ $t.Error }}){{ end }}{{ end }}
+{{- if .Warnings }}
+
+Warnings:{{ range .Warnings }}
+  - {{ . }}{{ end }}{{ end }}
+
+The full run report is attached as JSON.
+`
+
+// SMTPConfig is the mail server email notifications are sent through.
+type SMTPConfig struct {
+	Host         string // host:port; the port defaults to 587
+	Username     string
+	Password     string
+	PasswordFile string // read when Password is empty
+	TLS          string // starttls, tls or none
+}
+
+// address returns the host and host:port of the server.
+func (c SMTPConfig) address() (string, string, error) {
+	host, port, err := net.SplitHostPort(c.Host)
+	if err != nil {
+		host, port = c.Host, "587"
+	}
+	if host == "" {
+		return "", "", fmt.Errorf("--smtp-host is required for email notifications")
+	}
+	return host, net.JoinHostPort(host, port), nil
+}
+
+// checkEmailNotifications validates the email notification settings.
+func (cfg *Configuration) checkEmailNotifications() []error {
+	if len(cfg.NotifyEmailTo) == 0 {
+		if cfg.NotifyEmailTemplate != "" {
+			return []error{fmt.Errorf("--notify-email-template requires --notify-email-to")}
+		}
+		return nil
+	}
+	var problems []error
+	if cfg.NotifyEmailFrom == "" {
+		problems = append(problems, fmt.Errorf("--notify-email-from is required for email notifications"))
+	} else if _, err := envelopeAddresses([]string{cfg.NotifyEmailFrom}); err != nil {
+		problems = append(problems, err)
+	}
+	if _, err := envelopeAddresses(cfg.NotifyEmailTo); err != nil {
+		problems = append(problems, err)
+	}
+	if _, _, err := cfg.SMTP.address(); err != nil {
+		problems = append(problems, err)
+	}
+	switch cfg.SMTP.TLS {
+	case SMTPStartTLS, SMTPTLS, SMTPNone:
+	default:
+		problems = append(problems, fmt.Errorf("invalid --smtp-tls %q: expected starttls, tls or none", cfg.SMTP.TLS))
+	}
+	return problems
+}
+
+// envelopeAddresses returns the bare addresses of addresses such as
+// "Backups <backups@example.com>".
+func envelopeAddresses(addresses []string) ([]string, error) {
+	bare := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		parsed, err := mail.ParseAddress(address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid email address %q: %w", address, err)
+		}
+		bare = append(bare, parsed.Address)
+	}
+	return bare, nil
+}
+
+// sendEmailNotification mails body, the rendered text, to --notify-email-to
+// with the notification attached as JSON.
+func (iops *InfrahubOps) sendEmailNotification(body []byte, data Notification) error {
+	cfg := iops.config
+	if problems := cfg.checkEmailNotifications(); len(problems) > 0 {
+		return problems[0]
+	}
+	report, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode run report: %w", err)
+	}
+	message, err := buildEmailMessage(cfg.NotifyEmailFrom, cfg.NotifyEmailTo, data.Summary, body,
+		fmt.Sprintf("infrahub-%s-report.json", data.Operation), report, time.Now())
+	if err != nil {
+		return err
+	}
+	password := cfg.SMTP.Password
+	if password == "" && cfg.SMTP.PasswordFile != "" {
+		content, err := os.ReadFile(cfg.SMTP.PasswordFile)
+		if err != nil {
+			return fmt.Errorf("failed to read --smtp-password-file: %w", err)
+		}
+		password = strings.TrimSpace(string(content))
+	}
+	from, err := envelopeAddresses([]string{cfg.NotifyEmailFrom})
+	if err != nil {
+		return err
+	}
+	to, err := envelopeAddresses(cfg.NotifyEmailTo)
+	if err != nil {
+		return err
+	}
+	tlsConfig, err := cfg.HTTP.TLSConfig()
+	if err != nil {
+		return err
+	}
+	return sendMail(cfg.SMTP, tlsConfig, password, from[0], to, message)
+}
+
+// buildEmailMessage returns a multipart message with text as its body and
+// attachment as a JSON file.
+func buildEmailMessage(from string, to []string, subject string, text []byte, filename string, attachment []byte, date time.Time) ([]byte, error) {
+	var message bytes.Buffer
+	writer := multipart.NewWriter(&message)
+	header := func(name, value string) { fmt.Fprintf(&message, "%s: %s\r\n", name, value) }
+	header("From", from)
+	header("To", strings.Join(to, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", subject))
+	header("Date", date.Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	header("Content-Type", `multipart/mixed; boundary="`+writer.Boundary()+`"`)
+	message.WriteString("\r\n")
+
+	part, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	qp := quotedprintable.NewWriter(part)
+	if _, err := qp.Write(text); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+
+	part, err = writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"application/json"},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": filename})},
+	})
+	if err != nil {
+		return nil, err
+	}
+	encoded := base64.StdEncoding.EncodeToString(attachment)
+	for len(encoded) > 76 {
+		fmt.Fprintf(part, "%s\r\n", encoded[:76])
+		encoded = encoded[76:]
+	}
+	fmt.Fprintf(part, "%s\r\n", encoded)
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return message.Bytes(), nil
+}
+
+// sendMail delivers message through the server of cfg, verifying its
+// certificate with the CAs of tlsConfig when not nil.
+func sendMail(cfg SMTPConfig, tlsConfig *tls.Config, password, from string, to []string, message []byte) error {
+	host, address, err := cfg.address()
+	if err != nil {
+		return err
+	}
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+	tlsConfig = tlsConfig.Clone()
+	tlsConfig.ServerName = host
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+	var conn net.Conn
+	if cfg.TLS == SMTPTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server %s: %w", address, err)
+	}
+	conn.SetDeadline(time.Now().Add(smtpTimeout))
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("SMTP server %s: %w", address, err)
+	}
+	defer client.Close()
+
+	if cfg.TLS == SMTPStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("SMTP server %s does not offer STARTTLS; set --smtp-tls none to send unencrypted", address)
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("STARTTLS with %s failed: %w", address, err)
+		}
+	}
+	if cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", cfg.Username, password, host)); err != nil {
+			return fmt.Errorf("SMTP authentication as %s failed: %w", cfg.Username, err)
+		}
+	}
+	if err := client.Mail(from); err != nil {
+		return fmt.Errorf("SMTP server refused sender %s: %w", from, err)
+	}
+	for _, recipient := range to {
+		if err := client.Rcpt(recipient); err != nil {
+			return fmt.Errorf("SMTP server refused recipient %s: %w", recipient, err)
+		}
+	}
+	data, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := data.Write(message); err != nil {
+		return err
+	}
+	if err := data.Close(); err != nil {
+		return fmt.Errorf("SMTP server refused the message: %w", err)
+	}
+	return client.Quit()
+}
@@ -0,0 +1,173 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSMTPServer accepts one message without TLS or authentication and
+// sends the envelope and data on the returned channel.
+func fakeSMTPServer(t *testing.T, extensions ...string) (string, <-chan string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+		var transcript strings.Builder
+		reply("220 fake ESMTP")
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			command := strings.TrimRight(line, "\r\n")
+			switch verb := strings.ToUpper(strings.Fields(command + " ")[0]); verb {
+			case "EHLO":
+				for _, extension := range extensions {
+					reply("250-" + extension)
+				}
+				reply("250 fake")
+			case "MAIL", "RCPT":
+				transcript.WriteString(command + "\n")
+				reply("250 ok")
+			case "DATA":
+				reply("354 go ahead")
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					transcript.WriteString(line)
+				}
+				reply("250 queued")
+				received <- transcript.String()
+			case "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("502 not implemented")
+			}
+		}
+	}()
+	return listener.Addr().String(), received
+}
+
+func TestSendEmailNotification(t *testing.T) {
+	address, received := fakeSMTPServer(t)
+	iops := newFakeDockerOps(newFakeExecutor())
+	iops.config.NotifyEmailTo = []string{"Ops <ops@example.com>", "oncall@example.com"}
+	iops.config.NotifyEmailFrom = "Infrahub backups <backups@example.com>"
+	iops.config.SMTP = SMTPConfig{Host: address, TLS: SMTPNone}
+
+	err := iops.RunWithReport("backup", func() error {
+		iops.recordArtifact("infrahub_backup_20260101_020000", "/backups/infrahub_backup_20260101_020000.tar.gz", "", 0)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var transcript string
+	select {
+	case transcript = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+	for _, want := range []string{"MAIL FROM:<backups@example.com>", "RCPT TO:<ops@example.com>", "RCPT TO:<oncall@example.com>"} {
+		if !strings.Contains(transcript, want) {
+			t.Errorf("transcript lacks %q:\n%s", want, transcript)
+		}
+	}
+
+	message, err := mail.ReadMessage(strings.NewReader(transcript[strings.Index(transcript, "From:"):]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(message.Header.Get("Subject"))
+	if !strings.HasPrefix(subject, "Infrahub backup of test succeeded") {
+		t.Errorf("Subject = %q", subject)
+	}
+	_, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := multipart.NewReader(message.Body, params["boundary"])
+	text, err := parts.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(text)
+	if !strings.Contains(string(body), "Backup ID: infrahub_backup_20260101_020000") || !strings.Contains(string(body), "attached as JSON") {
+		t.Errorf("body =\n%s", body)
+	}
+	attachment, err := parts.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attachment.FileName() != "infrahub-backup-report.json" {
+		t.Errorf("attachment name = %q", attachment.FileName())
+	}
+	encoded, _ := io.ReadAll(attachment)
+	var report Notification
+	if err := json.NewDecoder(base64.NewDecoder(base64.StdEncoding, bytes.NewReader(encoded))).Decode(&report); err != nil || report.BackupID != "infrahub_backup_20260101_020000" || report.Status != "success" {
+		t.Errorf("attached report = %+v (%v)", report, err)
+	}
+}
+
+func TestSendMailRequiresStartTLS(t *testing.T) {
+	address, _ := fakeSMTPServer(t)
+	err := sendMail(SMTPConfig{Host: address, TLS: SMTPStartTLS}, nil, "", "backups@example.com", []string{"ops@example.com"}, []byte("Subject: test\r\n\r\ntest\r\n"))
+	if err == nil || !strings.Contains(err.Error(), "does not offer STARTTLS") {
+		t.Errorf("sendMail() error = %v", err)
+	}
+}
+
+func TestCheckEmailNotifications(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Configuration
+		wantErr string
+	}{
+		{name: "disabled", cfg: Configuration{}},
+		{name: "template without recipients", cfg: Configuration{NotifyEmailTemplate: "mail.tmpl"}, wantErr: "requires --notify-email-to"},
+		{name: "valid", cfg: Configuration{NotifyEmailTo: []string{"ops@example.com"}, NotifyEmailFrom: "backups@example.com", SMTP: SMTPConfig{Host: "smtp.example.com", TLS: SMTPStartTLS}}},
+		{name: "no sender", cfg: Configuration{NotifyEmailTo: []string{"ops@example.com"}, SMTP: SMTPConfig{Host: "smtp.example.com", TLS: SMTPStartTLS}}, wantErr: "--notify-email-from is required"},
+		{name: "bad recipient", cfg: Configuration{NotifyEmailTo: []string{"ops"}, NotifyEmailFrom: "backups@example.com", SMTP: SMTPConfig{Host: "smtp.example.com", TLS: SMTPStartTLS}}, wantErr: `invalid email address "ops"`},
+		{name: "no host", cfg: Configuration{NotifyEmailTo: []string{"ops@example.com"}, NotifyEmailFrom: "backups@example.com", SMTP: SMTPConfig{TLS: SMTPStartTLS}}, wantErr: "--smtp-host is required"},
+		{name: "bad tls", cfg: Configuration{NotifyEmailTo: []string{"ops@example.com"}, NotifyEmailFrom: "backups@example.com", SMTP: SMTPConfig{Host: "smtp.example.com:465", TLS: "ssl"}}, wantErr: "invalid --smtp-tls"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := tt.cfg.checkEmailNotifications()
+			if tt.wantErr == "" {
+				if len(problems) != 0 {
+					t.Errorf("checkEmailNotifications() = %v", problems)
+				}
+				return
+			}
+			if len(problems) != 1 || !strings.Contains(problems[0].Error(), tt.wantErr) {
+				t.Errorf("checkEmailNotifications() = %v, want %q", problems, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNotificationTemplates(t *testing.T) {
+	report := &RunReport{
+		Operation: "backup",
+		StartedAt: time.Date(2026, 1, 1, 2, 0, 0, 0, time.UTC),
+		Duration:  95 * time.Second,
+		BackupID:  "infrahub_backup_20260101_020000",
+		SizeBytes: 3 << 20,
+	}
+	failed := &RunReport{Operation: "restore", Duration: time.Minute, Err: errors.New(`neo4j restore failed: "database" is offline`)}
+	slow := &RunReport{Operation: "restore", Duration: 45 * time.Minute, SLO: []SLOResult{
+		{Objective: SLORPO, Target: 24 * time.Hour, Actual: 6 * time.Hour},
+		{Objective: SLORTO, Target: 30 * time.Minute, Actual: 45 * time.Minute, Breached: true},
+	}}
+
+	tests := []struct {
+		name     string
+		template string
+		report   *RunReport
+		want     string
+		wantErr  string
+	}{
+		{
+			name:     "slack default",
+			template: defaultSlackTemplate,
+			report:   report,
+			want:     `{"text": "Infrahub backup of docker/infrahub succeeded in 1m35s (infrahub_backup_20260101_020000, 3.0 MB)"}`,
+		},
+		{
+			name:     "incident schema",
+			template: `{"summary": {{ json .Summary }}, "severity": "{{ if .Succeeded }}info{{ else }}critical{{ end }}", "component": {{ json (upper .Operation) }}}`,
+			report:   failed,
+			want:     `{"summary": "Infrahub restore of docker/infrahub failed after 1m0s: neo4j restore failed: \"database\" is offline", "severity": "critical", "component": "RESTORE"}`,
+		},
+		{
+			name:     "slo breach",
+			template: `{"text": {{ json .Summary }}, "breached": {{ .SLOBreached }}, "rto": {{ json (index .SLO 1).Actual }}}`,
+			report:   slow,
+			want:     `{"text": "Infrahub restore of docker/infrahub succeeded in 45m0s; SLO breached: RTO 45m0s (target 30m0s)", "breached": true, "rto": "45m0s"}`,
+		},
+		{
+			name:     "unknown field",
+			template: `{"id": {{ json .Ticket }}}`,
+			report:   report,
+			wantErr:  "can't evaluate field Ticket",
+		},
+		{
+			name:     "not json",
+			template: `backup {{ .Status }}`,
+			report:   report,
+			wantErr:  "did not render valid JSON",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := loadNotificationTemplate("test", "", tt.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := notifier{name: "test", template: tmpl, jsonBody: true}.render(newNotification(tt.report, "docker/infrahub"))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("render() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("render() error = %v", err)
+			}
+			if string(body) != tt.want {
+				t.Errorf("render() =\n%s\nwant\n%s", body, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunWithReportNotifies(t *testing.T) {
+	var mu sync.Mutex
+	bodies := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies[r.URL.Path] = string(data)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	writeTestFile(t, dir, "ticket.tmpl", `{"title": "{{ .Operation }} {{ .Status }}", "backup": {{ json .BackupID }}}`)
+	iops := newFakeDockerOps(newFakeExecutor())
+	iops.config.NotifyWebhook = server.URL + "/tickets"
+	iops.config.NotifyWebhookTemplate = filepath.Join(dir, "ticket.tmpl")
+	iops.config.NotifySlackWebhook = server.URL + "/slack"
+
+	err := iops.RunWithReport("backup", func() error {
+		iops.recordArtifact("infrahub_backup_20260101_020000", "/backups/infrahub_backup_20260101_020000.tar.gz", "", 0)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := bodies["/tickets"]; got != `{"title": "backup success", "backup": "infrahub_backup_20260101_020000"}` {
+		t.Errorf("webhook body = %s", got)
+	}
+	var slack struct{ Text string }
+	if err := json.Unmarshal([]byte(bodies["/slack"]), &slack); err != nil || !strings.Contains(slack.Text, "backup") {
+		t.Errorf("slack body = %s (%v)", bodies["/slack"], err)
+	}
+}
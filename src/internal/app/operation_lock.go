@@ -0,0 +1,604 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/sirupsen/logrus"
+)
+
+// Defaults of --lock-ttl and the pacing of --lock-wait.
+const (
+	defaultLockTTL      = time.Minute
+	minLockTTL          = 10 * time.Second // shortest TTL Consul accepts
+	lockRequestTimeout  = 30 * time.Second
+	lockInitialInterval = 5 * time.Second
+	lockMaxInterval     = 30 * time.Second
+)
+
+// LockConfig selects the distributed lock held while create, restore and
+// rotate-credentials change a deployment, so that operators and automation on
+// different hosts cannot run them against the same deployment at once.
+type LockConfig struct {
+	URL  string        // consul://, etcd:// or s3:// location of the lock; empty disables locking
+	Key  string        // lock name; defaults to the backend and target of the run
+	TTL  time.Duration // how long the lock outlives a holder that stops renewing it
+	Wait time.Duration // how long to wait for a held lock; 0 fails at once
+}
+
+// lockHolder describes who holds a lock. It is the value stored with it.
+type lockHolder struct {
+	Owner     string    `json:"owner"`
+	Host      string    `json:"host"`
+	PID       int       `json:"pid"`
+	Operation string    `json:"operation"`
+	Target    string    `json:"target,omitempty"`
+	Acquired  time.Time `json:"acquired_at"`
+	Expires   time.Time `json:"expires_at"`
+}
+
+func (h *lockHolder) String() string {
+	if h.Operation == "" {
+		return "another run"
+	}
+	return fmt.Sprintf("%s %s on %s (pid %d) since %s", h.Owner, h.Operation, h.Host, h.PID, h.Acquired.Local().Format(time.RFC3339))
+}
+
+// distributedLock is one lock in an external lock service.
+type distributedLock interface {
+	// acquire takes the lock for holder, or returns who holds it.
+	acquire(ctx context.Context, holder *lockHolder) (*lockHolder, error)
+	// renew extends the lock by its TTL.
+	renew(ctx context.Context, holder *lockHolder) error
+	// release gives the lock up.
+	release(ctx context.Context) error
+}
+
+// errLockHeld reports a lock still held by another run after --lock-wait.
+var errLockHeld = errors.New("lock held")
+
+// errLockLost reports a lock that expired and was taken over by another run.
+var errLockLost = errors.New("lock lost")
+
+// lockKeyUnsafe matches characters left out of default lock names.
+var lockKeyUnsafe = regexp.MustCompile(`[^A-Za-z0-9._/-]+`)
+
+// lockName returns the name of the lock of the target: --lock-key, or
+// infrahub-backup/<backend>/<target>, under the path of the lock URL.
+func (iops *InfrahubOps) lockName(location *url.URL) string {
+	key := iops.config.Lock.Key
+	if key == "" {
+		key = "infrahub-backup"
+		if iops.backend != nil {
+			key += "/" + lockKeyUnsafe.ReplaceAllString(iops.backend.Name(), "_") + "/" + lockKeyUnsafe.ReplaceAllString(iops.backend.Info(), "_")
+		}
+	}
+	prefix := strings.Trim(location.Path, "/")
+	if location.Scheme == "s3" {
+		return strings.TrimPrefix(path.Join(prefix, key)+".lock", "/")
+	}
+	return strings.TrimPrefix(path.Join(prefix, key), "/")
+}
+
+// newDistributedLock returns the lock --lock names for the target.
+func (iops *InfrahubOps) newDistributedLock() (distributedLock, string, error) {
+	location, err := parseLockURL(iops.config.Lock.URL)
+	if err != nil {
+		return nil, "", err
+	}
+	name := iops.lockName(location)
+	ttl := iops.config.Lock.TTL
+	if ttl <= 0 {
+		ttl = defaultLockTTL
+	}
+	switch location.Scheme {
+	case "s3":
+		client, err := iops.s3Client(S3Config{
+			Bucket:      location.Host,
+			Endpoint:    iops.config.S3.Endpoint,
+			Region:      iops.config.S3.Region,
+			Credentials: iops.config.S3.Credentials,
+		})
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to create S3 client: %w", err)
+		}
+		return &s3Lock{client: client, key: name, ttl: ttl}, "s3://" + location.Host + "/" + name, nil
+	}
+	httpClient, err := iops.config.HTTP.Client(lockRequestTimeout)
+	if err != nil {
+		return nil, "", err
+	}
+	base := lockEndpoint(location)
+	if strings.HasPrefix(location.Scheme, "consul") {
+		return &consulLock{http: httpClient, base: base, key: name, ttl: ttl, token: os.Getenv("CONSUL_HTTP_TOKEN")}, location.Scheme + "://" + location.Host + "/" + name, nil
+	}
+	return &etcdLock{http: httpClient, base: base, key: name, ttl: ttl}, location.Scheme + "://" + location.Host + "/" + name, nil
+}
+
+// parseLockURL validates --lock.
+func parseLockURL(raw string) (*url.URL, error) {
+	location, err := url.Parse(raw)
+	if err == nil && location.Host == "" {
+		err = errors.New("missing host")
+	}
+	if err == nil {
+		switch location.Scheme {
+		case "consul", "consul+https", "etcd", "etcd+https", "s3":
+		default:
+			err = fmt.Errorf("unsupported scheme %q", location.Scheme)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid --lock %q: expected consul://host:port, etcd://host:port or s3://bucket/prefix: %w", raw, err)
+	}
+	return location, nil
+}
+
+// lockEndpoint returns the HTTP base URL of a Consul or etcd --lock.
+func lockEndpoint(location *url.URL) string {
+	scheme := "http"
+	if strings.HasSuffix(location.Scheme, "+https") {
+		scheme = "https"
+	}
+	return scheme + "://" + location.Host
+}
+
+// checkLock validates the lock settings.
+func (cfg *Configuration) checkLock() []error {
+	var problems []error
+	if cfg.Lock.URL == "" {
+		if cfg.Lock.Key != "" {
+			problems = append(problems, fmt.Errorf("--lock-key requires --lock"))
+		}
+		return problems
+	}
+	if _, err := parseLockURL(cfg.Lock.URL); err != nil {
+		problems = append(problems, err)
+	}
+	if cfg.Lock.TTL != 0 && cfg.Lock.TTL < minLockTTL {
+		problems = append(problems, fmt.Errorf("invalid --lock-ttl %s: must be at least %s", cfg.Lock.TTL, minLockTTL))
+	}
+	if cfg.Lock.Wait < 0 {
+		problems = append(problems, fmt.Errorf("invalid --lock-wait %s: must not be negative", cfg.Lock.Wait))
+	}
+	return problems
+}
+
+// acquireOperationLock takes the --lock of the target for operation, waiting
+// up to --lock-wait while another run holds it, and renews it in the
+// background. If the lock cannot be renewed for a whole TTL, another run may
+// hold it, so the operation is cancelled at its next phase. The returned
+// function releases it. Without --lock, nothing is locked.
+func (iops *InfrahubOps) acquireOperationLock(operation string) (func(), error) {
+	if iops.config.Lock.URL == "" {
+		return func() {}, nil
+	}
+	lock, name, err := iops.newDistributedLock()
+	if err != nil {
+		return nil, err
+	}
+	ttl := iops.config.Lock.TTL
+	if ttl <= 0 {
+		ttl = defaultLockTTL
+	}
+	holder := &lockHolder{Owner: iops.recordOperator(), PID: os.Getpid(), Operation: operation, Acquired: time.Now().UTC()}
+	holder.Host, _ = os.Hostname()
+	if iops.backend != nil {
+		holder.Target = iops.backend.Info()
+	}
+
+	if err := iops.waitForLock(lock, name, holder, ttl); err != nil {
+		return nil, err
+	}
+	logrus.Infof("Acquired lock %s", name)
+
+	parent := iops.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	operationCtx, cancelOperation := context.WithCancelCause(parent)
+	previousCtx := iops.ctx
+	iops.ctx = operationCtx
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(ttl / 3)
+		defer ticker.Stop()
+		renewed := time.Now()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				holder.Expires = time.Now().UTC().Add(ttl)
+				ctx, cancel := context.WithTimeout(context.Background(), lockRequestTimeout)
+				err := lock.renew(ctx, holder)
+				cancel()
+				if err == nil {
+					renewed = time.Now()
+					continue
+				}
+				if time.Since(renewed) < ttl {
+					logrus.Warnf("Failed to renew lock %s; another run may take it once it expires: %v", name, err)
+					continue
+				}
+				logrus.Errorf("Lock %s expired without being renewed; stopping %s at its next phase: %v", name, operation, err)
+				cancelOperation(fmt.Errorf("%w: %s could not be renewed for %s: %w", errLockLost, name, ttl, err))
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(stop)
+			wg.Wait()
+			iops.ctx = previousCtx
+			cancelOperation(nil)
+			ctx, cancel := context.WithTimeout(context.Background(), lockRequestTimeout)
+			defer cancel()
+			err := lock.release(ctx)
+			switch {
+			case errors.Is(err, errLockLost):
+				logrus.Warnf("Lock %s is now held by another run; leaving it in place", name)
+			case err != nil:
+				logrus.Warnf("Failed to release lock %s; it expires after %s: %v", name, ttl, err)
+			default:
+				logrus.Infof("Released lock %s", name)
+			}
+		})
+	}, nil
+}
+
+// waitForLock acquires lock for holder, retrying until --lock-wait passes
+// while another run holds it.
+func (iops *InfrahubOps) waitForLock(lock distributedLock, name string, holder *lockHolder, ttl time.Duration) error {
+	deadline := time.Now().Add(iops.config.Lock.Wait)
+	var poll *poller
+	defer func() {
+		if poll != nil {
+			poll.done()
+		}
+	}()
+	for {
+		holder.Expires = time.Now().UTC().Add(ttl)
+		ctx, cancel := context.WithTimeout(context.Background(), lockRequestTimeout)
+		current, err := lock.acquire(ctx, holder)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to acquire lock %s: %w", name, err)
+		}
+		if current == nil {
+			return nil
+		}
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("%w: %s is held by %s; retry later or set --lock-wait", errLockHeld, name, current)
+		}
+		if poll == nil {
+			poll = iops.newPoller("lock "+name, lockInitialInterval, lockMaxInterval)
+		}
+		poll.status("held by " + current.String())
+		poll.wait(deadline)
+	}
+}
+
+// lockRequest sends a JSON request to a Consul or etcd endpoint and decodes
+// the response into out, when not nil.
+func lockRequest(ctx context.Context, client *http.Client, method, endpoint string, header http.Header, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return err
+	}
+	for name, values := range header {
+		request.Header[name] = values
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("%s %s: %s: %s", method, endpoint, response.Status, strings.TrimSpace(string(data)))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("%s %s: invalid response: %w", method, endpoint, err)
+	}
+	return nil
+}
+
+// decodeLockHolder reads the holder stored with a lock. A value written by
+// something else is reported as an unknown holder.
+func decodeLockHolder(data []byte) *lockHolder {
+	var holder lockHolder
+	if err := json.Unmarshal(data, &holder); err != nil {
+		return &lockHolder{}
+	}
+	return &holder
+}
+
+// consulLock is a Consul KV key acquired with a session. The session has the
+// delete behavior, so the key disappears with the session when its holder
+// releases it or stops renewing it.
+type consulLock struct {
+	http    *http.Client
+	base    string
+	key     string
+	ttl     time.Duration
+	token   string // CONSUL_HTTP_TOKEN
+	session string
+}
+
+func (l *consulLock) header() http.Header {
+	header := http.Header{}
+	if l.token != "" {
+		header.Set("X-Consul-Token", l.token)
+	}
+	return header
+}
+
+func (l *consulLock) acquire(ctx context.Context, holder *lockHolder) (*lockHolder, error) {
+	if l.session == "" {
+		var created struct{ ID string }
+		body := map[string]any{
+			"Name":      "infrahub-backup " + holder.Operation,
+			"TTL":       strconv.Itoa(int(l.ttl.Seconds())) + "s",
+			"Behavior":  "delete",
+			"LockDelay": "0s",
+		}
+		if err := lockRequest(ctx, l.http, http.MethodPut, l.base+"/v1/session/create", l.header(), body, &created); err != nil {
+			return nil, err
+		}
+		l.session = created.ID
+	}
+	var acquired bool
+	endpoint := l.base + "/v1/kv/" + l.key + "?acquire=" + url.QueryEscape(l.session)
+	if err := lockRequest(ctx, l.http, http.MethodPut, endpoint, l.header(), holder, &acquired); err != nil {
+		return nil, err
+	}
+	if acquired {
+		return nil, nil
+	}
+	var entries []struct{ Value []byte }
+	if err := lockRequest(ctx, l.http, http.MethodGet, l.base+"/v1/kv/"+l.key, l.header(), nil, &entries); err != nil || len(entries) == 0 {
+		// Released between the two requests; the next attempt takes it.
+		return &lockHolder{}, nil
+	}
+	return decodeLockHolder(entries[0].Value), nil
+}
+
+func (l *consulLock) renew(ctx context.Context, _ *lockHolder) error {
+	return lockRequest(ctx, l.http, http.MethodPut, l.base+"/v1/session/renew/"+l.session, l.header(), nil, nil)
+}
+
+func (l *consulLock) release(ctx context.Context) error {
+	return lockRequest(ctx, l.http, http.MethodPut, l.base+"/v1/session/destroy/"+l.session, l.header(), nil, nil)
+}
+
+// etcdLock is an etcd key created under a lease through the JSON gateway of
+// the v3 API. Revoking the lease, or letting it expire, deletes the key.
+type etcdLock struct {
+	http  *http.Client
+	base  string
+	key   string
+	ttl   time.Duration
+	token string // from ETCDCTL_USERNAME and ETCDCTL_PASSWORD
+	lease string
+}
+
+func (l *etcdLock) header(ctx context.Context) (http.Header, error) {
+	header := http.Header{}
+	username := os.Getenv("ETCDCTL_USERNAME")
+	if username == "" {
+		return header, nil
+	}
+	if l.token == "" {
+		var auth struct{ Token string }
+		body := map[string]string{"name": username, "password": os.Getenv("ETCDCTL_PASSWORD")}
+		if err := lockRequest(ctx, l.http, http.MethodPost, l.base+"/v3/auth/authenticate", nil, body, &auth); err != nil {
+			return nil, err
+		}
+		l.token = auth.Token
+	}
+	header.Set("Authorization", l.token)
+	return header, nil
+}
+
+func (l *etcdLock) acquire(ctx context.Context, holder *lockHolder) (*lockHolder, error) {
+	header, err := l.header(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if l.lease == "" {
+		var granted struct{ ID string }
+		if err := lockRequest(ctx, l.http, http.MethodPost, l.base+"/v3/lease/grant", header, map[string]any{"TTL": int(l.ttl.Seconds())}, &granted); err != nil {
+			return nil, err
+		}
+		l.lease = granted.ID
+	}
+	value, err := json.Marshal(holder)
+	if err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString([]byte(l.key))
+	txn := map[string]any{
+		"compare": []map[string]any{{"key": key, "target": "CREATE", "result": "EQUAL", "create_revision": "0"}},
+		"success": []map[string]any{{"request_put": map[string]any{"key": key, "value": value, "lease": l.lease}}},
+		"failure": []map[string]any{{"request_range": map[string]any{"key": key}}},
+	}
+	var result struct {
+		Succeeded bool
+		Responses []struct {
+			ResponseRange struct {
+				Kvs []struct{ Value []byte }
+			} `json:"response_range"`
+		}
+	}
+	if err := lockRequest(ctx, l.http, http.MethodPost, l.base+"/v3/kv/txn", header, txn, &result); err != nil {
+		return nil, err
+	}
+	if result.Succeeded {
+		return nil, nil
+	}
+	if len(result.Responses) == 0 || len(result.Responses[0].ResponseRange.Kvs) == 0 {
+		return &lockHolder{}, nil
+	}
+	return decodeLockHolder(result.Responses[0].ResponseRange.Kvs[0].Value), nil
+}
+
+func (l *etcdLock) renew(ctx context.Context, _ *lockHolder) error {
+	header, err := l.header(ctx)
+	if err != nil {
+		return err
+	}
+	var kept struct {
+		Result struct{ TTL string }
+	}
+	if err := lockRequest(ctx, l.http, http.MethodPost, l.base+"/v3/lease/keepalive", header, map[string]string{"ID": l.lease}, &kept); err != nil {
+		return err
+	}
+	if kept.Result.TTL == "" || kept.Result.TTL == "0" {
+		return fmt.Errorf("lease %s expired", l.lease)
+	}
+	return nil
+}
+
+func (l *etcdLock) release(ctx context.Context) error {
+	header, err := l.header(ctx)
+	if err != nil {
+		return err
+	}
+	return lockRequest(ctx, l.http, http.MethodPost, l.base+"/v3/lease/revoke", header, map[string]string{"ID": l.lease}, nil)
+}
+
+// s3Lock is an object created with a conditional write, which S3 and MinIO
+// refuse when the object exists. A lock whose holder stopped renewing it
+// before expires_at is taken over, also with a conditional write, so two runs
+// cannot both take it.
+type s3Lock struct {
+	client *S3Client
+	key    string
+	ttl    time.Duration
+	etag   string // of the object this run wrote
+}
+
+// isPreconditionFailed reports whether a conditional write lost to another
+// writer.
+func isPreconditionFailed(err error) bool {
+	response := minio.ToErrorResponse(err)
+	return response.StatusCode == http.StatusPreconditionFailed || response.StatusCode == http.StatusConflict
+}
+
+func (l *s3Lock) put(ctx context.Context, holder *lockHolder, opts minio.PutObjectOptions) error {
+	data, err := json.Marshal(holder)
+	if err != nil {
+		return err
+	}
+	opts.ContentType = "application/json"
+	info, err := l.client.client.PutObject(ctx, l.client.config.Bucket, l.key, bytes.NewReader(data), int64(len(data)), opts)
+	if err != nil {
+		return err
+	}
+	l.etag = info.ETag
+	return nil
+}
+
+func (l *s3Lock) acquire(ctx context.Context, holder *lockHolder) (*lockHolder, error) {
+	opts := minio.PutObjectOptions{}
+	opts.SetMatchETagExcept("*")
+	err := l.put(ctx, holder, opts)
+	if err == nil {
+		return nil, nil
+	}
+	if !isPreconditionFailed(err) {
+		return nil, err
+	}
+
+	object, err := l.client.client.GetObject(ctx, l.client.config.Bucket, l.key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+	data, err := io.ReadAll(io.LimitReader(object, 1<<20))
+	if err != nil {
+		if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
+			return &lockHolder{}, nil
+		}
+		return nil, err
+	}
+	info, err := object.Stat()
+	if err != nil {
+		return nil, err
+	}
+	current := decodeLockHolder(data)
+	if current.Expires.IsZero() || time.Now().Before(current.Expires) {
+		return current, nil
+	}
+	logrus.Warnf("Taking over lock s3://%s/%s, expired at %s: %s", l.client.config.Bucket, l.key, current.Expires.Local().Format(time.RFC3339), current)
+	opts = minio.PutObjectOptions{}
+	opts.SetMatchETag(info.ETag)
+	if err := l.put(ctx, holder, opts); err != nil {
+		if isPreconditionFailed(err) {
+			return current, nil
+		}
+		return nil, err
+	}
+	return nil, nil
+}
+
+func (l *s3Lock) renew(ctx context.Context, holder *lockHolder) error {
+	opts := minio.PutObjectOptions{}
+	opts.SetMatchETag(l.etag)
+	return l.put(ctx, holder, opts)
+}
+
+// release removes the lock object unless another run has taken it over since
+// this run last wrote it. S3 has no conditional delete, so the ETag is
+// checked right before the delete.
+func (l *s3Lock) release(ctx context.Context) error {
+	info, err := l.client.client.StatObject(ctx, l.client.config.Bucket, l.key, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
+			return nil
+		}
+		return err
+	}
+	if info.ETag != l.etag {
+		return errLockLost
+	}
+	return l.client.client.RemoveObject(ctx, l.client.config.Bucket, l.key, minio.RemoveObjectOptions{})
+}
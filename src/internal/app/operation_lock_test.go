@@ -0,0 +1,357 @@
+package app
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeConsul serves the session and KV endpoints a consulLock uses.
+type fakeConsul struct {
+	mu       sync.Mutex
+	sessions int
+	kv       map[string][]byte
+	owner    map[string]string // key to session
+}
+
+func (f *fakeConsul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.URL.Path == "/v1/session/create":
+		f.sessions++
+		json.NewEncoder(w).Encode(map[string]string{"ID": "s" + strconv.Itoa(f.sessions)})
+	case strings.HasPrefix(r.URL.Path, "/v1/session/renew/"):
+		w.Write([]byte("[]"))
+	case strings.HasPrefix(r.URL.Path, "/v1/session/destroy/"):
+		session := strings.TrimPrefix(r.URL.Path, "/v1/session/destroy/")
+		for key, owner := range f.owner {
+			if owner == session {
+				delete(f.owner, key)
+				delete(f.kv, key)
+			}
+		}
+		w.Write([]byte("true"))
+	case strings.HasPrefix(r.URL.Path, "/v1/kv/"):
+		key := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+		if r.Method == http.MethodGet {
+			if _, ok := f.kv[key]; !ok {
+				http.NotFound(w, r)
+				return
+			}
+			json.NewEncoder(w).Encode([]map[string][]byte{{"Value": f.kv[key]}})
+			return
+		}
+		session := r.URL.Query().Get("acquire")
+		if owner, ok := f.owner[key]; ok && owner != session {
+			w.Write([]byte("false"))
+			return
+		}
+		f.kv[key], _ = io.ReadAll(r.Body)
+		f.owner[key] = session
+		w.Write([]byte("true"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// fakeEtcd serves the lease and transaction endpoints an etcdLock uses.
+type fakeEtcd struct {
+	mu     sync.Mutex
+	leases int
+	kv     map[string][]byte
+	lease  map[string]string // key to lease
+}
+
+func (f *fakeEtcd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var body map[string]any
+	json.NewDecoder(r.Body).Decode(&body)
+	switch r.URL.Path {
+	case "/v3/lease/grant":
+		f.leases++
+		json.NewEncoder(w).Encode(map[string]string{"ID": strconv.Itoa(f.leases), "TTL": "60"})
+	case "/v3/lease/keepalive":
+		json.NewEncoder(w).Encode(map[string]any{"result": map[string]string{"ID": body["ID"].(string), "TTL": "60"}})
+	case "/v3/lease/revoke":
+		for key, lease := range f.lease {
+			if lease == body["ID"] {
+				delete(f.lease, key)
+				delete(f.kv, key)
+			}
+		}
+		w.Write([]byte("{}"))
+	case "/v3/kv/txn":
+		compare := body["compare"].([]any)[0].(map[string]any)
+		key := compare["key"].(string)
+		if value, ok := f.kv[key]; ok {
+			json.NewEncoder(w).Encode(map[string]any{"succeeded": false, "responses": []any{
+				map[string]any{"response_range": map[string]any{"kvs": []any{map[string][]byte{"value": value}}}},
+			}})
+			return
+		}
+		put := body["success"].([]any)[0].(map[string]any)["request_put"].(map[string]any)
+		f.kv[key], _ = base64.StdEncoding.DecodeString(put["value"].(string))
+		f.lease[key] = put["lease"].(string)
+		json.NewEncoder(w).Encode(map[string]any{"succeeded": true})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// fakeLockS3 serves conditional PUT, GET and DELETE of objects.
+type fakeLockS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	etags   map[string]string
+	puts    int
+}
+
+func (f *fakeLockS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := r.URL.Path
+	switch r.Method {
+	case http.MethodPut:
+		etag, exists := f.etags[key]
+		if (r.Header.Get("If-None-Match") == "*" && exists) ||
+			(r.Header.Get("If-Match") != "" && r.Header.Get("If-Match") != `"`+etag+`"`) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			w.Write([]byte(`<Error><Code>PreconditionFailed</Code></Error>`))
+			return
+		}
+		f.puts++
+		f.objects[key] = readS3Body(r)
+		f.etags[key] = "etag" + strconv.Itoa(f.puts)
+		w.Header().Set("ETag", `"`+f.etags[key]+`"`)
+	case http.MethodGet, http.MethodHead:
+		data, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`<Error><Code>NoSuchKey</Code></Error>`))
+			return
+		}
+		w.Header().Set("ETag", `"`+f.etags[key]+`"`)
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+	case http.MethodDelete:
+		delete(f.objects, key)
+		delete(f.etags, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// readS3Body returns the payload of a PUT, decoding the aws-chunked encoding
+// minio uses over plain HTTP.
+func readS3Body(r *http.Request) []byte {
+	data, _ := io.ReadAll(r.Body)
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return data
+	}
+	var payload []byte
+	for len(data) > 0 {
+		header, rest, _ := strings.Cut(string(data), "\r\n")
+		size, _ := strconv.ParseInt(strings.Split(header, ";")[0], 16, 64)
+		if size == 0 {
+			break
+		}
+		payload = append(payload, rest[:size]...)
+		data = []byte(rest[size+2:])
+	}
+	return payload
+}
+
+func TestOperationLock(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIATEST")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	tests := []struct {
+		name    string
+		handler http.Handler
+		scheme  string
+	}{
+		{name: "consul", handler: &fakeConsul{kv: map[string][]byte{}, owner: map[string]string{}}, scheme: "consul"},
+		{name: "etcd", handler: &fakeEtcd{kv: map[string][]byte{}, lease: map[string]string{}}, scheme: "etcd"},
+		{name: "s3", handler: &fakeLockS3{objects: map[string][]byte{}, etags: map[string]string{}}, scheme: "s3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+			newOps := func(operator string) *InfrahubOps {
+				iops := newFakeDockerOps(newFakeExecutor())
+				iops.config.RecordOperator = operator
+				iops.config.Lock.URL = tt.scheme + "://" + strings.TrimPrefix(server.URL, "http://") + "/locks"
+				if tt.scheme == "s3" {
+					iops.config.S3.Endpoint = server.URL
+					iops.config.S3.Region = "us-east-1"
+					iops.config.Lock.URL = "s3://locks/infrahub"
+				}
+				return iops
+			}
+
+			release, err := newOps("alice").acquireOperationLock("restore")
+			if err != nil {
+				t.Fatalf("acquireOperationLock() error = %v", err)
+			}
+			_, err = newOps("bob").acquireOperationLock("backup")
+			if !errors.Is(err, errLockHeld) || !strings.Contains(err.Error(), "alice restore") {
+				t.Fatalf("acquireOperationLock() while held error = %v, want held by alice", err)
+			}
+
+			// bob waits; the lock is released while he does.
+			pollSleep = func(time.Duration) { release() }
+			defer func() { pollSleep = time.Sleep }()
+			bob := newOps("bob")
+			bob.config.Lock.Wait = time.Hour
+			releaseBob, err := bob.acquireOperationLock("backup")
+			if err != nil {
+				t.Fatalf("acquireOperationLock() with --lock-wait error = %v", err)
+			}
+			releaseBob()
+			releaseBob()
+		})
+	}
+}
+
+func TestS3LockTakesOverExpiredLock(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIATEST")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	stale, _ := json.Marshal(lockHolder{Owner: "carol", Host: "old", Operation: "restore", Expires: time.Now().Add(-time.Minute)})
+	fake := &fakeLockS3{
+		objects: map[string][]byte{"/locks/infrahub-backup/docker/test.lock": stale},
+		etags:   map[string]string{"/locks/infrahub-backup/docker/test.lock": "old"},
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	iops := newFakeDockerOps(newFakeExecutor())
+	iops.config.S3.Endpoint = server.URL
+	iops.config.S3.Region = "us-east-1"
+	iops.config.Lock.URL = "s3://locks"
+	release, err := iops.acquireOperationLock("restore")
+	if err != nil {
+		t.Fatalf("acquireOperationLock() error = %v", err)
+	}
+	var holder lockHolder
+	json.Unmarshal(fake.objects["/locks/infrahub-backup/docker/test.lock"], &holder)
+	if holder.Operation != "restore" || holder.Owner == "carol" {
+		t.Errorf("lock holder = %+v, want this run", holder)
+	}
+	release()
+	if len(fake.objects) != 0 {
+		t.Errorf("objects after release = %v", fake.objects)
+	}
+}
+
+func TestS3LockLostToAnotherRun(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIATEST")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	const key = "/locks/infrahub-backup/docker/test.lock"
+	fake := &fakeLockS3{objects: map[string][]byte{}, etags: map[string]string{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	iops := newFakeDockerOps(newFakeExecutor())
+	iops.config.S3.Endpoint = server.URL
+	iops.config.S3.Region = "us-east-1"
+	iops.config.Lock.URL = "s3://locks"
+	iops.config.Lock.TTL = 300 * time.Millisecond
+	release, err := iops.acquireOperationLock("restore")
+	if err != nil {
+		t.Fatalf("acquireOperationLock() error = %v", err)
+	}
+
+	// Another run takes the lock over, so renewals fail from now on.
+	fake.mu.Lock()
+	fake.objects[key] = []byte(`{"owner":"carol"}`)
+	fake.etags[key] = "carol"
+	fake.mu.Unlock()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for iops.ctx.Err() == nil && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	err = iops.runPhase("neo4j_restore", func() error { return nil })
+	if !errors.Is(err, errLockLost) {
+		t.Errorf("runPhase() after losing the lock error = %v, want lock lost", err)
+	}
+
+	release()
+	if string(fake.objects[key]) != `{"owner":"carol"}` {
+		t.Errorf("release removed the lock of another run: %q", fake.objects[key])
+	}
+	if iops.ctx != nil {
+		t.Errorf("operation context left in place after release")
+	}
+}
+
+func TestLockName(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		key  string
+		want string
+	}{
+		{name: "default", url: "consul://consul:8500", want: "infrahub-backup/docker/test"},
+		{name: "prefix", url: "etcd://etcd:2379/ops/", want: "ops/infrahub-backup/docker/test"},
+		{name: "key", url: "consul://consul:8500", key: "prod", want: "prod"},
+		{name: "s3", url: "s3://locks/infrahub", want: "infrahub/infrahub-backup/docker/test.lock"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iops := newFakeDockerOps(newFakeExecutor())
+			iops.config.Lock.Key = tt.key
+			location, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := iops.lockName(location); got != tt.want {
+				t.Errorf("lockName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckLock(t *testing.T) {
+	tests := []struct {
+		name    string
+		lock    LockConfig
+		wantErr string
+	}{
+		{name: "disabled", lock: LockConfig{TTL: defaultLockTTL}},
+		{name: "consul", lock: LockConfig{URL: "consul+https://consul:8501", TTL: defaultLockTTL}},
+		{name: "key without lock", lock: LockConfig{Key: "prod"}, wantErr: "--lock-key requires --lock"},
+		{name: "scheme", lock: LockConfig{URL: "zookeeper://zk:2181"}, wantErr: "unsupported scheme"},
+		{name: "no host", lock: LockConfig{URL: "etcd:///locks"}, wantErr: "missing host"},
+		{name: "short ttl", lock: LockConfig{URL: "s3://locks", TTL: time.Second}, wantErr: "invalid --lock-ttl"},
+		{name: "negative wait", lock: LockConfig{URL: "s3://locks", Wait: -time.Second}, wantErr: "invalid --lock-wait"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Configuration{Lock: tt.lock}
+			problems := cfg.checkLock()
+			if tt.wantErr == "" {
+				if len(problems) > 0 {
+					t.Errorf("checkLock() = %v", problems)
+				}
+				return
+			}
+			if len(problems) != 1 || !strings.Contains(problems[0].Error(), tt.wantErr) {
+				t.Errorf("checkLock() = %v, want %q", problems, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,518 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Formats of backup and restore plans.
+const (
+	PlanFormatYAML = "yaml"
+	PlanFormatJSON = "json"
+)
+
+// planHistoryRuns is how many of the latest successful runs expected
+// durations are estimated from.
+const planHistoryRuns = 10
+
+// planTempDir stands for the temporary directory a run picks in each
+// container; planning does not probe which one is writable.
+const planTempDir = "/tmp"
+
+// Actions of plan steps.
+const (
+	PlanActionStop     = "stop"     // stop services
+	PlanActionStart    = "start"    // start services
+	PlanActionExec     = "exec"     // run a command inside a service container
+	PlanActionCopy     = "copy"     // copy files between the host and a container
+	PlanActionLocal    = "local"    // work on the host running infrahub-backup
+	PlanActionAPI      = "api"      // call the Infrahub or task manager API
+	PlanActionTransfer = "transfer" // upload to or download from S3
+)
+
+// OperationPlan is the ordered list of operations a backup or restore would
+// perform, for change requests reviewed before the run.
+type OperationPlan struct {
+	Operation       string     `json:"operation" yaml:"operation"` // backup or restore
+	GeneratedAt     time.Time  `json:"generated_at" yaml:"generated_at"`
+	Target          string     `json:"target,omitempty" yaml:"target,omitempty"`
+	Neo4jEdition    string     `json:"neo4j_edition,omitempty" yaml:"neo4j_edition,omitempty"`
+	Backup          string     `json:"backup,omitempty" yaml:"backup,omitempty"` // archive a restore reads
+	BackupID        string     `json:"backup_id,omitempty" yaml:"backup_id,omitempty"`
+	Downtime        bool       `json:"downtime" yaml:"downtime"` // whether Infrahub services are stopped
+	ExpectedSeconds float64    `json:"expected_seconds,omitempty" yaml:"expected_seconds,omitempty"`
+	HistoryRuns     int        `json:"history_runs,omitempty" yaml:"history_runs,omitempty"` // runs the estimates come from
+	Steps           []PlanStep `json:"steps" yaml:"steps"`
+
+	secrets []string // values masked in commands and environments
+}
+
+// PlanStep is one operation of a plan.
+type PlanStep struct {
+	Order           int      `json:"order" yaml:"order"`
+	Phase           string   `json:"phase,omitempty" yaml:"phase,omitempty"` // --events phase the step belongs to
+	Action          string   `json:"action" yaml:"action"`
+	Description     string   `json:"description" yaml:"description"`
+	Services        []string `json:"services,omitempty" yaml:"services,omitempty"`
+	Command         []string `json:"command,omitempty" yaml:"command,omitempty"`
+	User            string   `json:"user,omitempty" yaml:"user,omitempty"`
+	Env             []string `json:"env,omitempty" yaml:"env,omitempty"`
+	From            string   `json:"from,omitempty" yaml:"from,omitempty"`
+	To              string   `json:"to,omitempty" yaml:"to,omitempty"`
+	ExpectedSeconds float64  `json:"expected_seconds,omitempty" yaml:"expected_seconds,omitempty"` // of the whole phase, on its first step
+}
+
+// newOperationPlan starts an empty plan whose commands mask the configured
+// passwords.
+func (iops *InfrahubOps) newOperationPlan(operation string) *OperationPlan {
+	plan := &OperationPlan{Operation: operation, GeneratedAt: time.Now().UTC()}
+	for _, secret := range []string{iops.config.Neo4jPassword, iops.config.PostgresPassword} {
+		if secret != "" {
+			plan.secrets = append(plan.secrets, secret)
+		}
+	}
+	if iops.backend != nil {
+		plan.Target = iops.backend.Info()
+	}
+	return plan
+}
+
+// add appends step to the plan.
+func (p *OperationPlan) add(step PlanStep) {
+	step.Order = len(p.Steps) + 1
+	p.Steps = append(p.Steps, step)
+}
+
+// step appends a step without a command.
+func (p *OperationPlan) step(phase, action, description string, services ...string) {
+	p.add(PlanStep{Phase: phase, Action: action, Description: description, Services: services})
+}
+
+// exec appends a command run in service, masking passwords and the values of
+// secret environment variables.
+func (p *OperationPlan) exec(phase, description, service string, command []string, opts *ExecOptions) {
+	step := PlanStep{Phase: phase, Action: PlanActionExec, Description: description, Services: []string{service}}
+	for _, arg := range maskSecretFlags(command) {
+		step.Command = append(step.Command, p.mask(arg))
+	}
+	if opts != nil {
+		step.User = opts.User
+		for _, key := range slices.Sorted(maps.Keys(opts.Env)) {
+			value := p.mask(opts.Env[key])
+			if isSecretName(key) {
+				value = "****"
+			}
+			step.Env = append(step.Env, key+"="+value)
+		}
+	}
+	p.add(step)
+}
+
+// copy appends a copy between the host and service.
+func (p *OperationPlan) copy(phase, description, service, from, to string) {
+	p.add(PlanStep{Phase: phase, Action: PlanActionCopy, Description: description, Services: []string{service}, From: from, To: to})
+}
+
+// mask hides the passwords of the plan in value where commands pass them:
+// after -p or in a connection URL. Passwords are often also user or database
+// names, so other occurrences are kept.
+func (p *OperationPlan) mask(value string) string {
+	for _, secret := range p.secrets {
+		value = strings.ReplaceAll(value, "-p"+secret, "-p****")
+		value = strings.ReplaceAll(value, ":"+secret+"@", ":****@")
+	}
+	return value
+}
+
+// estimate sets the expected durations from the latest successful runs of the
+// operation recorded in the run history.
+func (p *OperationPlan) estimate(backupDir string) error {
+	entries, err := loadRunHistory(backupDir, time.Time{})
+	if err != nil {
+		return err
+	}
+	var runs []HistoryEntry
+	for i := len(entries) - 1; i >= 0 && len(runs) < planHistoryRuns; i-- {
+		if entries[i].Operation == p.Operation && entries[i].Status != "failure" {
+			runs = append(runs, entries[i])
+		}
+	}
+	if len(runs) == 0 {
+		return nil
+	}
+	p.HistoryRuns = len(runs)
+
+	totals := make([]float64, 0, len(runs))
+	phases := map[string][]float64{}
+	for _, run := range runs {
+		if run.DurationSeconds > 0 {
+			totals = append(totals, run.DurationSeconds)
+		}
+		for phase, seconds := range run.PhaseSeconds {
+			phases[phase] = append(phases[phase], seconds)
+		}
+	}
+	p.ExpectedSeconds = median(totals)
+	seen := map[string]bool{}
+	for i := range p.Steps {
+		phase := p.Steps[i].Phase
+		if phase == "" || seen[phase] {
+			continue
+		}
+		seen[phase] = true
+		p.Steps[i].ExpectedSeconds = median(phases[phase])
+	}
+	return nil
+}
+
+// median returns the median of values rounded to the second, or 0 for none.
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(values)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return float64(int64(sorted[mid] + 0.5))
+	}
+	return float64(int64((sorted[mid-1]+sorted[mid])/2 + 0.5))
+}
+
+// ValidatePlanFormat checks the --format of a plan.
+func ValidatePlanFormat(format string) error {
+	if format != PlanFormatYAML && format != PlanFormatJSON {
+		return fmt.Errorf("invalid plan format %q: expected yaml or json", format)
+	}
+	return nil
+}
+
+// WriteOperationPlan writes plan to w as YAML or JSON.
+func WriteOperationPlan(w io.Writer, plan *OperationPlan, format string) error {
+	if err := ValidatePlanFormat(format); err != nil {
+		return err
+	}
+	if format == PlanFormatJSON {
+		data, err := json.MarshalIndent(plan, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode plan: %w", err)
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(plan); err != nil {
+		return fmt.Errorf("failed to encode plan: %w", err)
+	}
+	return encoder.Close()
+}
+
+// filterSuffix describes the archive filters, e.g. " encrypted with ecies".
+func filterSuffix(filters []string) string {
+	if len(filters) == 0 {
+		return ""
+	}
+	return " encrypted with " + strings.Join(filters, ", ")
+}
+
+// PlanBackup returns the operations CreateBackup would perform with the same
+// arguments. It detects the deployment and reads its state, but changes
+// nothing.
+func (iops *InfrahubOps) PlanBackup(force bool, neo4jMetadata string, excludeTaskManager bool, s3Upload bool, s3KeepLocal bool, redact bool, encrypt bool, encryptKey string) (*OperationPlan, error) {
+	if iops.config.Backend == BackendPlakar {
+		return nil, fmt.Errorf("backup plans are not supported with the plakar backend")
+	}
+	if redact && !force {
+		return nil, fmt.Errorf("--redact is a destructive operation that replaces all attribute values in the database with random UUIDs; use --force to confirm")
+	}
+	if err := iops.checkPrerequisites(); err != nil {
+		return nil, err
+	}
+	if err := iops.DetectEnvironment(); err != nil {
+		return nil, err
+	}
+	editionInfo := NewNeo4jEditionInfo(iops.detectNeo4jEdition())
+	community := editionInfo.IsCommunity
+
+	plan := iops.newOperationPlan("backup")
+	plan.Neo4jEdition = editionInfo.Edition
+	plan.Downtime = community
+	plan.step("", PlanActionLocal, "Check the backup windows, prerequisites and configuration, and detect the deployment")
+
+	if redact {
+		plan.step("", PlanActionExec, "Replace every attribute value in the database with a random UUID (--redact)", "database")
+	}
+	if iops.config.PauseWorkPools {
+		plan.step("", PlanActionAPI, "Pause the task manager work pools so no new tasks start", "task-manager")
+	}
+	if !force {
+		plan.step("wait_for_tasks", PlanActionAPI, "Wait until no task manager flow runs are running", "task-manager")
+	}
+	plan.step("", PlanActionExec, "Export the RabbitMQ definitions, schema snapshot, Neo4j index script, branch list, and compose manifest or Helm values", "message-queue", "infrahub-server", "database")
+
+	var stopped []string
+	if community {
+		services := iops.appServicesWithDependents()
+		running, err := iops.RunningServices(services...)
+		if err != nil {
+			return nil, err
+		}
+		for _, service := range services {
+			if running[service] {
+				stopped = append(stopped, service)
+			}
+		}
+		plan.step("", PlanActionStop, "Stop the Infrahub services for the offline Community Edition dump", stopped...)
+	}
+
+	streamed := iops.config.StreamBackup
+	switch {
+	case community && streamed:
+		plan.exec("neo4j_backup", "Freeze the Neo4j process with the watchdog", "database", []string{"kill", "<neo4j pid>"}, nil)
+		dumpCmd, dumpOpts := iops.neo4jAdmin(iops.neo4jStreamDumpCommand(), nil)
+		plan.exec("neo4j_backup", "Stream the Neo4j dump into the archive", "database", iops.lowPriority("database", dumpCmd), dumpOpts)
+		plan.exec("neo4j_backup", "Resume the Neo4j process", "database", []string{"kill", "-CONT", "<neo4j pid>"}, nil)
+	case streamed:
+		plan.exec("neo4j_backup", "Create the remote backup directory", "database", []string{"mkdir", "-p", neo4jTempBackupDir}, nil)
+		backupCmd, backupOpts := iops.neo4jAdmin(iops.neo4jStreamBackupCommand(neo4jMetadata), nil)
+		plan.exec("neo4j_backup", "Run the online Neo4j Enterprise backup", "database", iops.lowPriority("database", backupCmd), backupOpts)
+		plan.exec("neo4j_backup", "Stream the Neo4j backup into the archive", "database", neo4jStreamTarCommand, nil)
+		plan.exec("neo4j_backup", "Remove the remote backup directory", "database", []string{"rm", "-rf", neo4jTempBackupDir}, nil)
+	case community:
+		plan.exec("neo4j_backup", "Freeze the Neo4j process with the watchdog", "database", []string{"kill", "<neo4j pid>"}, nil)
+		plan.exec("neo4j_backup", "Create the remote dump directory", "database", []string{"mkdir", "-p", neo4jRemoteWorkDir}, nil)
+		dumpCmd, dumpOpts := iops.neo4jAdmin(iops.neo4jDumpCommand(), nil)
+		plan.exec("neo4j_backup", "Dump the Neo4j database", "database", iops.lowPriority("database", dumpCmd), dumpOpts)
+		dumpFilename := iops.config.Neo4jDatabase + ".dump"
+		plan.copy("neo4j_backup", "Copy the Neo4j dump to the host", "database", neo4jRemoteWorkDir+"/"+dumpFilename, "database/"+dumpFilename)
+		plan.exec("neo4j_backup", "Resume the Neo4j process", "database", []string{"kill", "-CONT", "<neo4j pid>"}, nil)
+	default:
+		plan.exec("neo4j_backup", "Create the remote backup directory", "database", []string{"mkdir", "-p", neo4jTempBackupDir}, nil)
+		backupCmd, backupOpts := iops.neo4jAdmin(iops.neo4jBackupCommand(neo4jMetadata), nil)
+		plan.exec("neo4j_backup", "Run the online Neo4j Enterprise backup", "database", iops.lowPriority("database", backupCmd), backupOpts)
+		plan.copy("neo4j_backup", "Copy the Neo4j backup to the host", "database", neo4jTempBackupDir, "database")
+		plan.exec("neo4j_backup", "Remove the remote backup directory", "database", []string{"rm", "-rf", neo4jTempBackupDir}, nil)
+	}
+
+	if !excludeTaskManager && streamed {
+		opts := &ExecOptions{Env: map[string]string{"PGPASSWORD": iops.config.PostgresPassword}}
+		plan.exec("taskmanager_backup", "Stream the task manager dump into the archive", "task-manager-db", iops.lowPriority("task-manager-db", iops.pgStreamDumpArgs()), opts)
+	} else if !excludeTaskManager {
+		dumpName := prefectDumpFilename
+		if iops.config.PgJobs > 0 {
+			dumpName = prefectDumpDirName
+		}
+		dumpPath := planTempDir + "/infrahubops_" + dumpName
+		opts := &ExecOptions{Env: map[string]string{"PGPASSWORD": iops.config.PostgresPassword}}
+		plan.exec("taskmanager_backup", "Dump the task manager database", "task-manager-db", iops.lowPriority("task-manager-db", iops.pgDumpArgs(dumpPath, iops.config.PgJobs)), opts)
+		plan.copy("taskmanager_backup", "Copy the task manager dump to the host", "task-manager-db", dumpPath, dumpName)
+		plan.exec("taskmanager_backup", "Remove the remote dump", "task-manager-db", removeDumpArgs(dumpPath), nil)
+	}
+
+	objectStore, err := iops.objectStoreRunning()
+	if err != nil {
+		return nil, err
+	}
+	if objectStore {
+		configDir := planTempDir + "/infrahubops_mc"
+		dumpDir := planTempDir + "/infrahubops_object_store"
+		plan.exec("object_store_backup", "Mirror the object store buckets", objectStoreService, []string{"sh", "-c", objectStoreMirrorOutScript(configDir, dumpDir)}, nil)
+		plan.copy("object_store_backup", "Copy the object store contents to the host", objectStoreService, dumpDir, objectStoreDirName)
+		plan.exec("object_store_backup", "Remove the remote mirror", objectStoreService, []string{"rm", "-rf", configDir, dumpDir}, nil)
+	}
+
+	plan.step("", PlanActionLocal, fmt.Sprintf("Calculate the %s checksum of every file and write %s", iops.config.checksumAlgorithm(), backupMetadataFilename))
+	pipeline := defaultArchivePipeline(encrypt || encryptKey != "", s3Upload)
+	streamedToS3 := streamed && s3Upload && !s3KeepLocal
+	if !streamedToS3 {
+		plan.add(PlanStep{Phase: "archive", Action: PlanActionLocal, Description: "Write the " + pipeline.Compression + " archive" + filterSuffix(pipeline.Filters), To: iops.config.BackupDir})
+	}
+	if iops.config.VerifyRestore {
+		plan.step("", PlanActionLocal, "Rehearse a restore of the archive in throwaway containers (--verify-restore)")
+	}
+	if iops.config.SplitSize != "" {
+		plan.step("", PlanActionLocal, "Split the archive into parts of at most "+iops.config.SplitSize)
+	}
+	if streamedToS3 {
+		plan.add(PlanStep{Phase: "upload", Action: PlanActionTransfer, Description: "Stream the " + pipeline.Compression + " archive to S3 as it is written", To: strings.TrimSuffix("s3://"+iops.config.S3.Bucket+"/"+iops.config.S3.Prefix, "/")})
+	} else if s3Upload {
+		plan.add(PlanStep{Phase: "upload", Action: PlanActionTransfer, Description: "Upload the archive to S3", To: strings.TrimSuffix("s3://"+iops.config.S3.Bucket+"/"+iops.config.S3.Prefix, "/")})
+		if !s3KeepLocal {
+			plan.step("", PlanActionLocal, "Delete the local archive")
+		}
+	}
+	if community {
+		plan.step("", PlanActionStart, "Start the stopped Infrahub services again", stopped...)
+	}
+	if iops.config.PauseWorkPools {
+		plan.step("", PlanActionAPI, "Resume the paused work pools", "task-manager")
+	}
+
+	if err := plan.estimate(iops.config.BackupDir); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// PlanRestore returns the operations RestoreBackup would perform with the same
+// arguments. It reads the archive metadata and the deployment state, but
+// changes nothing.
+func (iops *InfrahubOps) PlanRestore(backupFile string, excludeTaskManager bool, restoreMigrateFormat bool, decryptKey string, resetDeploymentID bool, minimizeDowntime bool) (*OperationPlan, error) {
+	if iops.config.Backend == BackendPlakar {
+		return nil, fmt.Errorf("restore plans are not supported with the plakar backend")
+	}
+	metadata, _, err := iops.BackupContents(backupFile, decryptKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := iops.checkPrerequisites(); err != nil {
+		return nil, err
+	}
+	if err := iops.DetectEnvironment(); err != nil {
+		return nil, err
+	}
+	editionInfo := NewNeo4jEditionInfo(iops.detectNeo4jEdition())
+	neo4jEdition, err := editionInfo.ResolveRestoreEdition(metadata.Neo4jEdition)
+	if err != nil {
+		return nil, err
+	}
+	community := strings.EqualFold(neo4jEdition, neo4jEditionCommunity)
+
+	plan := iops.newOperationPlan("restore")
+	plan.Backup = backupFile
+	plan.BackupID = metadata.BackupID
+	plan.Neo4jEdition = neo4jEdition
+	plan.Downtime = true
+
+	if IsS3URI(backupFile) {
+		plan.add(PlanStep{Phase: "download", Action: PlanActionTransfer, Description: "Download the archive from S3, restoring it from the archive tier first when needed", From: backupFile})
+	}
+	plan.add(PlanStep{Phase: "extract", Action: PlanActionLocal, Description: "Decrypt and extract the archive", From: backupFile})
+	plan.step("", PlanActionLocal, "Validate the metadata and the checksum of every file")
+
+	restoreTaskManager := metadata.hasComponent("task-manager-db") && !excludeTaskManager
+	objectStore := false
+	if metadata.hasComponent(objectStoreService) {
+		if objectStore, err = iops.objectStoreRunning(); err != nil {
+			return nil, err
+		}
+	}
+	if objectStore {
+		dumpDir := planTempDir + "/infrahubops_object_store"
+		plan.copy("", "Copy the object store contents to the container", objectStoreService, objectStoreDirName, dumpDir)
+		plan.exec("", "Mirror the buckets back into the object store", objectStoreService, []string{"sh", "-c", objectStoreMirrorInScript(planTempDir+"/infrahubops_mc", dumpDir)}, nil)
+	}
+
+	stageNeo4j := func() {
+		plan.copy("neo4j_restore", "Stage the Neo4j backup in the database container", "database", filepath.ToSlash(filepath.Join("backup", "database")), neo4jTempBackupDir)
+		plan.exec("neo4j_restore", "Give the staged files to the neo4j user", "database", []string{"chown", "-R", "neo4j:neo4j", neo4jTempBackupDir}, nil)
+	}
+	restoreTaskManagerDB := func() {
+		dumpName := prefectDumpFilename
+		for name := range metadata.Checksums {
+			if strings.HasPrefix(name, prefectDumpDirName+"/") {
+				dumpName = prefectDumpDirName
+			}
+		}
+		dumpFile := planTempDir + "/infrahubops_" + dumpName
+		plan.step("taskmanager_restore", PlanActionStart, "Start the task manager database", "task-manager-db")
+		plan.copy("taskmanager_restore", "Copy the task manager dump to the container", "task-manager-db", dumpName, dumpFile)
+		restoreCmd, opts := iops.pgRestoreCommand(dumpFile, false)
+		plan.exec("taskmanager_restore", "Restore the task manager database", "task-manager-db", restoreCmd, opts)
+		plan.exec("taskmanager_restore", "Remove the remote dump", "task-manager-db", removeDumpArgs(dumpFile), nil)
+	}
+	downtime := func() {
+		plan.exec("", "Wipe the message queue data", "message-queue", []string{"find", "/var/lib/rabbitmq", "-mindepth", "1", "-delete"}, nil)
+		plan.exec("", "Wipe the cache data", "cache", []string{"find", "/data", "-mindepth", "1", "-delete"}, nil)
+		plan.step("", PlanActionStop, "Stop the Infrahub services", iops.appServicesWithDependents()...)
+	}
+	restartDependencies := func() {
+		plan.step("", PlanActionStart, "Restart the cache, message queue and task manager", "cache", "message-queue", "task-manager", "task-manager-background-svc")
+		if !iops.config.SkipMQDefinitions && metadata.hasComponent(messageQueueService) {
+			plan.step("", PlanActionExec, "Import the RabbitMQ definitions", "message-queue")
+		}
+	}
+
+	if minimizeDowntime {
+		stageNeo4j()
+		if restoreTaskManager {
+			plan.step("", PlanActionStop, "Stop the task manager stack; infrahub-server keeps serving reads", "task-worker", "task-manager", "task-manager-background-svc")
+			restoreTaskManagerDB()
+		}
+		downtime()
+	} else {
+		downtime()
+		if restoreTaskManager {
+			restoreTaskManagerDB()
+		}
+	}
+	restartDependencies()
+	if restoreTaskManager && iops.config.RunPrefectMigrations && metadata.PrefectSchemaRevision != "" {
+		plan.exec("", "Run the Prefect database migrations when the backup's schema revision differs from the target's", "task-manager", prefectMigrateCommand, nil)
+		plan.step("", PlanActionStart, "Restart the task manager on the migrated schema", "task-manager")
+	}
+	if !minimizeDowntime {
+		stageNeo4j()
+	}
+
+	opts := iops.getNeo4jExecOptions()
+	switch {
+	case community:
+		plan.exec("neo4j_restore", "Freeze the Neo4j process with the watchdog", "database", []string{"kill", "<neo4j pid>"}, nil)
+		loadCmd, loadOpts := iops.neo4jAdmin(iops.neo4jLoadCommand(), opts)
+		plan.exec("neo4j_restore", "Load the Neo4j dump", "database", loadCmd, loadOpts)
+		if restoreMigrateFormat {
+			migrateCmd, migrateOpts := iops.neo4jAdmin([]string{"neo4j-admin", "database", "migrate", "--to-format=block", iops.config.Neo4jDatabase}, opts)
+			plan.exec("neo4j_restore", "Migrate the store to the block format", "database", migrateCmd, migrateOpts)
+		}
+		plan.exec("neo4j_restore", "Resume the Neo4j process", "database", []string{"kill", "-CONT", "<neo4j pid>"}, nil)
+	case iops.isNeo4jCluster():
+		plan.step("neo4j_restore", PlanActionExec, "Drop the database, restore the backup on this server and recreate the database seeded from it across the cluster", "database")
+	default:
+		plan.exec("neo4j_restore", "Stop the Neo4j database", "database", []string{"cypher-shell", "-u", iops.config.Neo4jUsername, "-p" + iops.config.Neo4jPassword, "-d", "system", "stop database " + iops.config.Neo4jDatabase}, nil)
+		restoreCmd, restoreOpts := iops.neo4jAdmin(iops.neo4jRestoreCommand(), opts)
+		plan.exec("neo4j_restore", "Restore the Neo4j backup", "database", restoreCmd, restoreOpts)
+		if restoreMigrateFormat {
+			migrateCmd, migrateOpts := iops.neo4jAdmin([]string{"neo4j-admin", "database", "migrate", "--expand-commands", "--to-format=block", iops.config.Neo4jDatabase}, opts)
+			plan.exec("neo4j_restore", "Migrate the store to the block format", "database", migrateCmd, migrateOpts)
+		}
+		plan.step("neo4j_restore", PlanActionExec, "Restore the users and roles from the metadata script", "database")
+		plan.exec("neo4j_restore", "Start the Neo4j database", "database", []string{"cypher-shell", "-u", iops.config.Neo4jUsername, "-p" + iops.config.Neo4jPassword, "-d", "system", "start database " + iops.config.Neo4jDatabase}, nil)
+	}
+	if resetDeploymentID {
+		plan.step("", PlanActionExec, "Generate a new deployment ID (--reset-deployment-id)", "database")
+	}
+	anonymize := iops.config.Anonymize
+	if anonymize.MaskSecrets {
+		plan.step("", PlanActionExec, "Replace the stored credential passwords and account tokens with random values (--mask-secrets)", "database")
+	}
+	for _, script := range anonymize.CypherScripts {
+		cmd, opts := iops.cypherScriptCommand("/tmp/infrahubops_anonymize_" + filepath.Base(script))
+		plan.exec("", "Run the anonymization script "+script, "database", cmd, opts)
+	}
+	if restoreTaskManager {
+		for _, script := range anonymize.SQLScripts {
+			cmd, opts := iops.sqlScriptCommand("/tmp/infrahubops_anonymize_" + filepath.Base(script))
+			plan.exec("", "Run the anonymization script "+script, "task-manager-db", cmd, opts)
+		}
+	}
+	plan.step("start_services", PlanActionStart, "Start Infrahub", append([]string{"infrahub-server", "task-worker"}, iops.serviceDependencies().dependents(appServices)...)...)
+	if restoreTaskManager && len(metadata.PausedWorkPools) > 0 {
+		plan.step("", PlanActionAPI, "Resume the work pools paused by the backup", "task-manager")
+	}
+
+	if err := plan.estimate(iops.config.BackupDir); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
@@ -2,7 +2,6 @@ package app
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -31,13 +30,22 @@ func (iops *InfrahubOps) CreatePlakarBackup(force bool, neo4jMetadata string, ex
 	if err := iops.DetectEnvironment(); err != nil {
 		return err
 	}
+	releaseLock, err := iops.acquireOperationLock("backup")
+	if err != nil {
+		return err
+	}
+	defer releaseLock()
+	iops.reconcileRemoteTemp()
 
 	// Detect Neo4j edition
 	editionInfo := iops.detectNeo4jEditionInfo("backup")
 	if editionInfo.IsCommunity {
 		logrus.Warn("Neo4j Community Edition detected; Infrahub services will be stopped and restarted before the backup begins.")
-		logrus.Warn("Waiting 10 seconds to allow the user to abort... CTRL+C to cancel.")
-		time.Sleep(10 * time.Second)
+		iops.reportBackupImpact()
+		if !iops.config.NonInteractive {
+			logrus.Warn("Waiting 10 seconds to allow the user to abort... CTRL+C to cancel.")
+			time.Sleep(10 * time.Second)
+		}
 	}
 
 	// Redact attribute values if requested
@@ -50,7 +58,18 @@ func (iops *InfrahubOps) CreatePlakarBackup(force bool, neo4jMetadata string, ex
 		}
 	}
 
-	version := iops.getInfrahubVersion()
+	infrahubInfo := iops.collectInfrahubInfo()
+
+	// Pause work pools so no new tasks start while running ones drain
+	var pausedWorkPools []string
+	if iops.config.PauseWorkPools {
+		paused, resume, err := iops.pauseWorkPools()
+		if err != nil {
+			return err
+		}
+		defer resume()
+		pausedWorkPools = paused
+	}
 
 	// Check for running tasks unless --force is set
 	if !force {
@@ -77,7 +96,7 @@ func (iops *InfrahubOps) CreatePlakarBackup(force bool, neo4jMetadata string, ex
 			if len(servicesToRestart) == 0 {
 				return
 			}
-			if startErr := iops.startAppContainers(servicesToRestart); startErr != nil {
+			if startErr := iops.restartAfterBackup(servicesToRestart); startErr != nil {
 				logrus.Errorf("Failed to restart services after backup: %v", startErr)
 			}
 		}()
@@ -120,14 +139,20 @@ func (iops *InfrahubOps) CreatePlakarBackup(force bool, neo4jMetadata string, ex
 	// Generate backup metadata for the metadata component
 	metadataObj := iops.createBackupMetadata(
 		fmt.Sprintf("infrahub_backup_%s", backupID),
-		!excludeTaskManager, version, editionInfo.Edition,
+		!excludeTaskManager, infrahubInfo.Version, editionInfo.Edition,
 	)
+	metadataObj.InfrahubInfo = infrahubInfo
 	if redact {
 		metadataObj.Redacted = true
 	}
+	metadataObj.PausedWorkPools = pausedWorkPools
 	// Override components to use Plakar naming (neo4j, postgres, metadata)
 	// instead of the tarball naming (database, task-manager-db) from createBackupMetadata
 	metadataObj.Components = components
+	iops.recordNeo4jServerInfo(metadataObj)
+	if !excludeTaskManager {
+		iops.recordTaskManagerInfo(metadataObj)
+	}
 
 	// Create one snapshot per component
 	for _, component := range components {
@@ -159,10 +184,10 @@ func (iops *InfrahubOps) CreatePlakarBackup(force bool, neo4jMetadata string, ex
 			imp = NewStreamingImporter(hostname, "/prefect.dump", fi, dataFunc)
 
 		case ComponentMetadata:
-			metadataBytes, err := json.MarshalIndent(metadataObj, "", "    ")
+			metadataBytes, err := marshalBackupMetadata(metadataObj)
 			if err != nil {
 				logIncompleteBackup(completed, len(components), backupID)
-				return fmt.Errorf("failed to marshal metadata: %w", err)
+				return err
 			}
 			imp = NewMemoryImporter(hostname, "/backup_information.json", metadataBytes)
 		}
@@ -213,6 +238,7 @@ func (iops *InfrahubOps) CreatePlakarBackup(force bool, neo4jMetadata string, ex
 		"components": len(completed),
 		"repo":       iops.config.Plakar.RepoPath,
 	}).Info("Plakar streaming backup completed successfully")
+	iops.recordArtifact(backupID, iops.config.Plakar.RepoPath, "", 0)
 
 	// Sleep if requested
 	if sleepDuration > 0 {
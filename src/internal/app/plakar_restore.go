@@ -2,7 +2,6 @@ package app
 
 import (
 	"encoding/hex"
-	"encoding/json"
 	"fmt"
 	"iter"
 	"os"
@@ -36,6 +35,12 @@ func (iops *InfrahubOps) RestorePlakarBackup(excludeTaskManager bool, restoreMig
 	if err := iops.DetectEnvironment(); err != nil {
 		return err
 	}
+	releaseLock, err := iops.acquireOperationLock("restore")
+	if err != nil {
+		return err
+	}
+	defer releaseLock()
+	iops.reconcileRemoteTemp()
 
 	// Initialize Plakar context and repository
 	kctx, err := initPlakarContext(iops.config.Plakar)
@@ -137,9 +142,11 @@ func (iops *InfrahubOps) restoreBackupGroup(kctx *kcontext.KContext, repo *repos
 			Components:      group.Components,
 		}
 	} else {
-		if err := json.Unmarshal(metadataBytes, &metadata); err != nil {
+		parsed, err := parseBackupMetadata(metadataBytes)
+		if err != nil {
 			return fmt.Errorf("failed to parse backup metadata: %w", err)
 		}
+		metadata = *parsed
 	}
 
 	logrus.WithFields(logrus.Fields{
@@ -159,6 +166,11 @@ func (iops *InfrahubOps) restoreBackupGroup(kctx *kcontext.KContext, repo *repos
 	}
 	editionInfo.LogDetection("restore")
 
+	restoreMigrateFormat, err = iops.resolveNeo4jRestoreFormat(&metadata, neo4jEdition, restoreMigrateFormat)
+	if err != nil {
+		return err
+	}
+
 	// For enterprise, export neo4j snapshot and extract the tar archive for file-based restore
 	isCommunity := strings.EqualFold(neo4jEdition, neo4jEditionCommunity)
 	if neo4jSnapInfo != nil && !isCommunity {
@@ -201,13 +213,27 @@ func (iops *InfrahubOps) restoreBackupGroup(kctx *kcontext.KContext, repo *repos
 		logrus.Info("Backup does not include task manager database; skipping restore")
 	} else if prefectExists {
 		logrus.Info("Task manager database dump detected; will restore")
+		logExcludedTableData(&metadata)
+	}
+
+	migratePrefect := false
+	if shouldRestoreTaskManager && prefectExists {
+		if err := iops.verifyPostgresRestoreCompatibility(&metadata); err != nil {
+			return err
+		}
+		var err error
+		if migratePrefect, err = iops.verifyPrefectSchemaRevision(&metadata); err != nil {
+			return err
+		}
 	}
 
-	// Wipe transient data
+	// Wipe transient data, keeping the RabbitMQ definitions to import afterwards
+	mqDefinitions := iops.messageQueueDefinitionsForRestore(backupDir)
 	iops.wipeTransientData()
 
 	// Stop application containers
-	if _, err := iops.stopAppContainers(); err != nil {
+	stopped, err := iops.stopAppContainers()
+	if err != nil {
 		return err
 	}
 
@@ -224,6 +250,12 @@ func (iops *InfrahubOps) restoreBackupGroup(kctx *kcontext.KContext, repo *repos
 	if err := iops.restartDependencies(); err != nil {
 		return err
 	}
+	if migratePrefect {
+		if err := iops.runPrefectMigrations(); err != nil {
+			return err
+		}
+	}
+	iops.restoreMessageQueueDefinitions(mqDefinitions, workDir)
 
 	// Restore Neo4j
 	if neo4jSnapInfo != nil && isCommunity {
@@ -260,12 +292,18 @@ func (iops *InfrahubOps) restoreBackupGroup(kctx *kcontext.KContext, repo *repos
 			}
 		}
 	}
+	if err := iops.anonymizeRestoredData(workDir, neo4jSnapInfo != nil, shouldRestoreTaskManager && prefectExists); err != nil {
+		return err
+	}
 
 	// Restart all services
 	logrus.Info("Restarting Infrahub services...")
-	if err := iops.StartServices("infrahub-server", "task-worker"); err != nil {
+	if err := iops.restartAppServices(stopped); err != nil {
 		return fmt.Errorf("failed to restart infrahub services: %w", err)
 	}
+	if shouldRestoreTaskManager && prefectExists {
+		iops.resumeRestoredWorkPools(&metadata)
+	}
 
 	logrus.Info("Restore from Plakar backup group completed successfully")
 	logrus.Info("Infrahub should be available shortly")
@@ -361,7 +399,8 @@ func (iops *InfrahubOps) restoreSingleSnapshot(kctx *kcontext.KContext, repo *re
 		}
 		defer reader.Close()
 
-		if _, err := iops.stopAppContainers(); err != nil {
+		stopped, err := iops.stopAppContainers()
+		if err != nil {
 			return err
 		}
 		if err := iops.restartDependencies(); err != nil {
@@ -376,9 +415,12 @@ func (iops *InfrahubOps) restoreSingleSnapshot(kctx *kcontext.KContext, repo *re
 				return err
 			}
 		}
+		if err := iops.anonymizeRestoredData(os.TempDir(), true, false); err != nil {
+			return err
+		}
 
 		logrus.Info("Restarting Infrahub services...")
-		if err := iops.StartServices("infrahub-server", "task-worker"); err != nil {
+		if err := iops.restartAppServices(stopped); err != nil {
 			return fmt.Errorf("failed to restart infrahub services: %w", err)
 		}
 
@@ -414,6 +456,7 @@ func (iops *InfrahubOps) restoreSingleSnapshot(kctx *kcontext.KContext, repo *re
 		return fmt.Errorf("failed to extract plakar snapshot: %w", err)
 	}
 
+	var stopped []string
 	switch component {
 	case ComponentNeo4j:
 		// Enterprise: the exported snapshot contains neo4j-backup.tar;
@@ -426,7 +469,7 @@ func (iops *InfrahubOps) restoreSingleSnapshot(kctx *kcontext.KContext, repo *re
 		os.Remove(tarPath)
 
 		// Stop services and restore
-		if _, err := iops.stopAppContainers(); err != nil {
+		if stopped, err = iops.stopAppContainers(); err != nil {
 			return err
 		}
 		if err := iops.restartDependencies(); err != nil {
@@ -455,7 +498,7 @@ func (iops *InfrahubOps) restoreSingleSnapshot(kctx *kcontext.KContext, repo *re
 		if _, err := os.Stat(srcDump); os.IsNotExist(err) {
 			return fmt.Errorf("postgres snapshot does not contain prefect.dump")
 		}
-		if _, err := iops.stopAppContainers(); err != nil {
+		if stopped, err = iops.stopAppContainers(); err != nil {
 			return err
 		}
 		if err := iops.restorePostgreSQL(workDir); err != nil {
@@ -472,10 +515,13 @@ func (iops *InfrahubOps) restoreSingleSnapshot(kctx *kcontext.KContext, repo *re
 	default:
 		return fmt.Errorf("unknown component type in snapshot: %s", component)
 	}
+	if err := iops.anonymizeRestoredData(workDir, component == ComponentNeo4j, component == ComponentPostgres); err != nil {
+		return err
+	}
 
 	// Restart services
 	logrus.Info("Restarting Infrahub services...")
-	if err := iops.StartServices("infrahub-server", "task-worker"); err != nil {
+	if err := iops.restartAppServices(stopped); err != nil {
 		return fmt.Errorf("failed to restart infrahub services: %w", err)
 	}
 
@@ -0,0 +1,103 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// pollLogInterval is how often a wait loop repeats an unchanged status when
+// it cannot redraw a terminal status line.
+const pollLogInterval = time.Minute
+
+// pollSleep pauses a wait loop. Tests replace it.
+var pollSleep = time.Sleep
+
+// poller paces a wait loop: the interval between checks starts at initial
+// and doubles up to max, so long waits query the deployment less often. On a
+// terminal the status is a single line redrawn in place; otherwise it is
+// logged when it changes and at most once per pollLogInterval while it does
+// not.
+type poller struct {
+	what     string
+	interval time.Duration
+	max      time.Duration
+	started  time.Time
+
+	terminal   io.Writer // stderr when the status line can be redrawn
+	drawn      bool
+	last       string
+	lastLogged time.Time
+	logf       func(format string, args ...any)
+}
+
+// newPoller returns a poller for the loop waiting for what. --poll-interval
+// and --poll-max-interval override the loop's own initial and max intervals.
+func (iops *InfrahubOps) newPoller(what string, initial, maxInterval time.Duration) *poller {
+	if iops.config.PollInterval > 0 {
+		initial = iops.config.PollInterval
+	}
+	if iops.config.PollMaxInterval > 0 {
+		maxInterval = iops.config.PollMaxInterval
+	}
+	p := &poller{what: what, interval: initial, max: max(initial, maxInterval), started: time.Now(), logf: logrus.Infof}
+	if iops.statusLineAvailable() {
+		p.terminal = os.Stderr
+	}
+	return p
+}
+
+// statusLineAvailable reports whether wait loops may redraw a status line:
+// text logs on a terminal, with info messages shown.
+func (iops *InfrahubOps) statusLineAvailable() bool {
+	if iops.settings != nil && iops.settings.GetString("log-format") == "json" {
+		return false
+	}
+	if !logrus.IsLevelEnabled(logrus.InfoLevel) || logrus.StandardLogger().Out != os.Stderr {
+		return false
+	}
+	info, err := os.Stderr.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// status reports the current state of the wait.
+func (p *poller) status(message string) {
+	elapsed := time.Since(p.started).Round(time.Second)
+	if p.terminal != nil {
+		fmt.Fprintf(p.terminal, "\r\033[KWaiting for %s: %s (%s)", p.what, message, elapsed)
+		p.drawn = true
+		return
+	}
+	now := time.Now()
+	if message == p.last && now.Sub(p.lastLogged) < pollLogInterval {
+		return
+	}
+	if p.lastLogged.IsZero() {
+		p.logf("Waiting for %s: %s", p.what, message)
+	} else {
+		p.logf("Still waiting for %s after %s: %s", p.what, elapsed, message)
+	}
+	p.last = message
+	p.lastLogged = now
+}
+
+// wait sleeps until the next check, never past deadline when it is set.
+func (p *poller) wait(deadline time.Time) {
+	delay := p.interval
+	if !deadline.IsZero() {
+		delay = min(delay, max(time.Until(deadline), 0))
+	}
+	pollSleep(delay)
+	p.interval = min(p.interval*2, p.max)
+}
+
+// done clears the status line once the wait is over.
+func (p *poller) done() {
+	if p.drawn {
+		fmt.Fprint(p.terminal, "\r\033[K")
+		p.drawn = false
+	}
+}
@@ -0,0 +1,155 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubPollSleep records the delays of wait loops instead of sleeping.
+func stubPollSleep(t *testing.T) *[]time.Duration {
+	t.Helper()
+	var delays []time.Duration
+	original := pollSleep
+	pollSleep = func(d time.Duration) { delays = append(delays, d) }
+	t.Cleanup(func() { pollSleep = original })
+	return &delays
+}
+
+func TestPollerBackoff(t *testing.T) {
+	tests := []struct {
+		name             string
+		initial, maximum time.Duration
+		config           Configuration
+		want             []time.Duration
+	}{
+		{
+			name:    "doubles up to the maximum",
+			initial: time.Second, maximum: 5 * time.Second,
+			want: []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second},
+		},
+		{
+			name:    "configured intervals win",
+			initial: time.Second, maximum: 5 * time.Second,
+			config: Configuration{PollInterval: 10 * time.Second, PollMaxInterval: 15 * time.Second},
+			want:   []time.Duration{10 * time.Second, 15 * time.Second, 15 * time.Second, 15 * time.Second, 15 * time.Second},
+		},
+		{
+			name:    "maximum below the first interval",
+			initial: 5 * time.Second, maximum: 5 * time.Second,
+			config: Configuration{PollInterval: 20 * time.Second},
+			want:   []time.Duration{20 * time.Second, 20 * time.Second, 20 * time.Second, 20 * time.Second, 20 * time.Second},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delays := stubPollSleep(t)
+			iops := &InfrahubOps{config: &tt.config}
+			poll := iops.newPoller("test", tt.initial, tt.maximum)
+			for range tt.want {
+				poll.wait(time.Time{})
+			}
+			if fmt.Sprint(*delays) != fmt.Sprint(tt.want) {
+				t.Errorf("delays = %v, want %v", *delays, tt.want)
+			}
+		})
+	}
+}
+
+func TestPollerWaitStopsAtDeadline(t *testing.T) {
+	delays := stubPollSleep(t)
+	poll := (&InfrahubOps{config: &Configuration{}}).newPoller("test", time.Minute, time.Minute)
+	poll.wait(time.Now().Add(2 * time.Second))
+	poll.wait(time.Now().Add(-time.Second))
+	if len(*delays) != 2 || (*delays)[0] > 2*time.Second || (*delays)[1] != 0 {
+		t.Errorf("delays = %v, want at most 2s then 0", *delays)
+	}
+}
+
+func TestPollerStatusLogsChangesOnly(t *testing.T) {
+	poll := (&InfrahubOps{config: &Configuration{}}).newPoller("running tasks", time.Second, time.Second)
+	poll.terminal = nil
+	var logged []string
+	poll.logf = func(format string, args ...any) { logged = append(logged, fmt.Sprintf(format, args...)) }
+
+	poll.status("3 running")
+	poll.status("3 running")
+	poll.status("3 running")
+	poll.status("1 running")
+	poll.lastLogged = poll.lastLogged.Add(-2 * pollLogInterval)
+	poll.status("1 running")
+
+	if len(logged) != 3 {
+		t.Fatalf("logged = %q, want the first status, the change and one reminder", logged)
+	}
+	if logged[0] != "Waiting for running tasks: 3 running" || !strings.HasPrefix(logged[1], "Still waiting for running tasks after") || !strings.HasSuffix(logged[2], ": 1 running") {
+		t.Errorf("logged = %q", logged)
+	}
+}
+
+func TestPollerStatusLine(t *testing.T) {
+	var out bytes.Buffer
+	poll := (&InfrahubOps{config: &Configuration{}}).newPoller("running tasks", time.Second, time.Second)
+	poll.terminal = &out
+	poll.logf = func(format string, args ...any) { t.Errorf("logged on a terminal: "+format, args...) }
+
+	poll.status("3 running")
+	poll.status("2 running")
+	poll.done()
+
+	if got := out.String(); strings.Count(got, "\r\033[K") != 3 || !strings.Contains(got, "Waiting for running tasks: 2 running (0s)") || strings.Contains(got, "\n") {
+		t.Errorf("status line output = %q", got)
+	}
+}
+
+// sequenceExecutor answers successive commands containing match with the
+// next of outputs.
+type sequenceExecutor struct {
+	*fakeExecutor
+	match   string
+	outputs []string
+}
+
+func (s *sequenceExecutor) runCommand(name string, args ...string) (string, error) {
+	output, err := s.fakeExecutor.runCommand(name, args...)
+	if strings.Contains(strings.Join(args, " "), s.match) && len(s.outputs) > 0 {
+		output, s.outputs = s.outputs[0], s.outputs[1:]
+	}
+	return output, err
+}
+
+func TestWaitForProcessStopped(t *testing.T) {
+	delays := stubPollSleep(t)
+	iops := newFakeDockerOps(&sequenceExecutor{
+		fakeExecutor: newFakeExecutor(),
+		match:        "/proc/42/status",
+		outputs:      []string{"R (running)", "S (sleeping)", "T (stopped)"},
+	})
+
+	if err := iops.waitForProcessStopped("42", time.Minute); err != nil {
+		t.Fatalf("waitForProcessStopped() error = %v", err)
+	}
+	if len(*delays) != 2 || (*delays)[0] != time.Second || (*delays)[1] != 2*time.Second {
+		t.Errorf("delays = %v, want 1s then 2s", *delays)
+	}
+}
+
+func TestWaitForRunningTasksBacksOff(t *testing.T) {
+	delays := stubPollSleep(t)
+	running := `[{"id": "1", "title": "sync", "state": "RUNNING"}]`
+	iops := newFakeDockerOps(&sequenceExecutor{
+		fakeExecutor: newFakeExecutor(),
+		match:        "task list",
+		outputs:      []string{running, running, running, "[]"},
+	})
+
+	if err := iops.waitForRunningTasks(); err != nil {
+		t.Fatalf("waitForRunningTasks() error = %v", err)
+	}
+	want := []time.Duration{runningTasksPollInterval, 2 * runningTasksPollInterval, 4 * runningTasksPollInterval}
+	if fmt.Sprint(*delays) != fmt.Sprint(want) {
+		t.Errorf("delays = %v, want %v", *delays, want)
+	}
+}
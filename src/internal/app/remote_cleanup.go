@@ -0,0 +1,211 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// defaultRemoteCleanupAge is how long temporary files in the containers must
+// have gone unmodified before they are considered left by a crashed run.
+const defaultRemoteCleanupAge = 15 * time.Minute
+
+// remoteTempPatterns match the temporary files commands create inside the
+// containers: all start with infrahubops, under /tmp or, where /tmp is not
+// writable, /run.
+var remoteTempPatterns = []string{
+	"/tmp/infrahubops",
+	"/tmp/infrahubops_*",
+	"/tmp/.infrahubops_*",
+	"/run/infrahubops_*",
+	"/run/.infrahubops_*",
+}
+
+// remoteCleanupServices are the services commands exec into.
+var remoteCleanupServices = []string{"database", "task-manager-db", "task-manager", "task-worker", "infrahub-server", "cache", messageQueueService, objectStoreService}
+
+// RemoteTempFile is a temporary file or directory found in a container.
+type RemoteTempFile struct {
+	Service string `json:"service"`
+	Path    string `json:"path"`
+	Size    int64  `json:"size_bytes"`
+	Active  bool   `json:"active"` // modified within the cleanup age, so possibly in use
+	Removed bool   `json:"removed"`
+}
+
+// RemoteCleanupReport lists the temporary files found by cleanup --remote.
+type RemoteCleanupReport struct {
+	MinAge time.Duration    `json:"-"`
+	DryRun bool             `json:"dry_run"`
+	Files  []RemoteTempFile `json:"files"`
+}
+
+// remoteTempScript prints one line per temporary file: 1 when something
+// under it changed in the last minAge, else 0, then its size in KiB and its
+// path. A zero minAge reports every file as stale.
+func remoteTempScript(minAge time.Duration) string {
+	minutes := int(math.Ceil(minAge.Minutes()))
+	return fmt.Sprintf(`for p in %s; do
+  [ -e "$p" ] || continue
+  active=0
+  if [ %d -gt 0 ] && [ -n "$(find "$p" -mmin -%d 2>/dev/null | head -n 1)" ]; then active=1; fi
+  echo "$active $(du -sk "$p" 2>/dev/null | cut -f1) $p"
+done`, strings.Join(remoteTempPatterns, " "), minutes, minutes)
+}
+
+// parseRemoteTempFiles parses the output of remoteTempScript.
+func parseRemoteTempFiles(service, output string) []RemoteTempFile {
+	var files []RemoteTempFile
+	for _, line := range nonEmptyLines(output) {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || (fields[0] != "0" && fields[0] != "1") {
+			continue
+		}
+		file := RemoteTempFile{Service: service, Path: fields[len(fields)-1], Active: fields[0] == "1"}
+		if len(fields) == 3 {
+			if kib, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+				file.Size = kib * 1024
+			}
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+// findRemoteTempFiles lists the temporary files in every running service.
+// Services that cannot be inspected are skipped with a debug message.
+func (iops *InfrahubOps) findRemoteTempFiles(minAge time.Duration) ([]RemoteTempFile, error) {
+	running, err := iops.backend.RunningServices(remoteCleanupServices...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check running services: %w", err)
+	}
+	script := remoteTempScript(minAge)
+	var files []RemoteTempFile
+	for _, service := range remoteCleanupServices {
+		if !running[service] {
+			continue
+		}
+		output, err := iops.Exec(service, []string{"sh", "-c", script}, nil)
+		if err != nil {
+			logrus.Debugf("Failed to list temporary files in %s: %v", service, err)
+			continue
+		}
+		files = append(files, parseRemoteTempFiles(service, output)...)
+	}
+	return files, nil
+}
+
+// removeRemoteTempFiles removes the files that are not active and marks them
+// removed.
+func (iops *InfrahubOps) removeRemoteTempFiles(files []RemoteTempFile) error {
+	byService := map[string][]int{}
+	var services []string
+	for i, file := range files {
+		if file.Active {
+			continue
+		}
+		if _, ok := byService[file.Service]; !ok {
+			services = append(services, file.Service)
+		}
+		byService[file.Service] = append(byService[file.Service], i)
+	}
+
+	var failed []string
+	for _, service := range services {
+		command := []string{"rm", "-rf", "--"}
+		for _, i := range byService[service] {
+			command = append(command, files[i].Path)
+		}
+		if output, err := iops.Exec(service, command, nil); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v %s", service, err, strings.TrimSpace(output)))
+			continue
+		}
+		for _, i := range byService[service] {
+			files[i].Removed = true
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to remove temporary files in %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// reconcileRemoteTemp removes temporary files left in the containers by
+// earlier runs that crashed, before this run creates its own. It is disabled
+// by --remote-cleanup-age 0 and never fails the command.
+func (iops *InfrahubOps) reconcileRemoteTemp() {
+	if iops.config.RemoteCleanupAge <= 0 {
+		return
+	}
+	files, err := iops.findRemoteTempFiles(iops.config.RemoteCleanupAge)
+	if err != nil {
+		logrus.Debugf("Skipping the cleanup of stale temporary files: %v", err)
+		return
+	}
+	if err := iops.removeRemoteTempFiles(files); err != nil {
+		logrus.Warnf("Stale temporary files from an earlier run remain: %v", err)
+	}
+	for _, file := range files {
+		switch {
+		case file.Removed:
+			logrus.Infof("Removed %s (%s) left in %s by an earlier run", file.Path, formatBytes(file.Size), file.Service)
+		case file.Active:
+			logrus.Warnf("%s in %s changed in the last %s; another backup or restore may be running against this deployment", file.Path, file.Service, iops.config.RemoteCleanupAge)
+		}
+	}
+}
+
+// CleanupRemote lists, and unless dryRun removes, the temporary files left in
+// the containers. Files changed within --remote-cleanup-age are kept unless
+// all is set.
+func (iops *InfrahubOps) CleanupRemote(all, dryRun bool) (*RemoteCleanupReport, error) {
+	if err := iops.DetectEnvironment(); err != nil {
+		return nil, err
+	}
+	report := &RemoteCleanupReport{MinAge: iops.config.RemoteCleanupAge, DryRun: dryRun}
+	if all {
+		report.MinAge = 0
+	}
+	files, err := iops.findRemoteTempFiles(report.MinAge)
+	if err != nil {
+		return nil, err
+	}
+	report.Files = files
+	if dryRun {
+		return report, nil
+	}
+	return report, iops.removeRemoteTempFiles(report.Files)
+}
+
+// Write prints one line per temporary file with what was done with it.
+func (r *RemoteCleanupReport) Write(w io.Writer) {
+	if len(r.Files) == 0 {
+		fmt.Fprintln(w, "No temporary files found in the containers.")
+		return
+	}
+	files := append([]RemoteTempFile(nil), r.Files...)
+	sort.SliceStable(files, func(i, j int) bool { return files[i].Service < files[j].Service })
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SERVICE\tPATH\tSIZE\tACTION")
+	for _, file := range files {
+		action := "failed to remove"
+		switch {
+		case file.Active:
+			action = fmt.Sprintf("kept (changed in the last %s)", r.MinAge)
+		case r.DryRun:
+			action = "would remove"
+		case file.Removed:
+			action = "removed"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", file.Service, file.Path, formatBytes(file.Size), action)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,104 @@
+package app
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRemoteTempFiles(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []RemoteTempFile
+	}{
+		{name: "none", output: ""},
+		{
+			name:   "stale and active",
+			output: "0 2048 /tmp/infrahubops\n1 4 /tmp/infrahubops_prefect.dump\n",
+			want: []RemoteTempFile{
+				{Service: "database", Path: "/tmp/infrahubops", Size: 2048 * 1024},
+				{Service: "database", Path: "/tmp/infrahubops_prefect.dump", Size: 4096, Active: true},
+			},
+		},
+		{
+			name:   "size unavailable",
+			output: "0  /run/infrahubops_mc\n",
+			want:   []RemoteTempFile{{Service: "database", Path: "/run/infrahubops_mc"}},
+		},
+		{name: "shell noise", output: "sh: du: not found\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRemoteTempFiles("database", tt.output); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseRemoteTempFiles() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoteTempScript(t *testing.T) {
+	script := remoteTempScript(90 * time.Second)
+	for _, want := range []string{"/tmp/infrahubops ", "/run/infrahubops_*", "-mmin -2"} {
+		if !strings.Contains(script, want) {
+			t.Errorf("script misses %q:\n%s", want, script)
+		}
+	}
+	if script := remoteTempScript(0); !strings.Contains(script, "[ 0 -gt 0 ]") {
+		t.Errorf("script with no age still checks activity:\n%s", script)
+	}
+}
+
+func remoteCleanupFake() *fakeExecutor {
+	return newFakeExecutor().
+		on("ps -a --format json", `[{"Service":"database","State":"running"},{"Service":"task-manager-db","State":"running"},{"Service":"task-worker","State":"exited"}]`, nil).
+		on("exec -T database sh -c", "0 512 /tmp/infrahubops\n1 8 /tmp/infrahubops_restore.dump\n", nil).
+		on("exec -T task-manager-db sh -c", "0 64 /tmp/infrahubops_prefect.dump\n", nil)
+}
+
+func TestReconcileRemoteTemp(t *testing.T) {
+	fake := remoteCleanupFake()
+	iops := newFakeDockerOps(fake)
+	iops.config.RemoteCleanupAge = defaultRemoteCleanupAge
+
+	iops.reconcileRemoteTemp()
+
+	want := []string{
+		"docker compose -p test exec -T database rm -rf -- /tmp/infrahubops",
+		"docker compose -p test exec -T task-manager-db rm -rf -- /tmp/infrahubops_prefect.dump",
+	}
+	if got := fake.commands(" rm -rf"); !reflect.DeepEqual(got, want) {
+		t.Errorf("rm commands = %v, want %v", got, want)
+	}
+	if got := fake.commands("exec -T task-worker"); len(got) != 0 {
+		t.Errorf("stopped service inspected: %v", got)
+	}
+}
+
+func TestReconcileRemoteTempDisabled(t *testing.T) {
+	fake := remoteCleanupFake()
+	iops := newFakeDockerOps(fake)
+	iops.config.RemoteCleanupAge = 0
+
+	iops.reconcileRemoteTemp()
+	if len(fake.calls) != 0 {
+		t.Errorf("commands run with cleanup disabled: %v", fake.calls)
+	}
+}
+
+func TestRemoteCleanupReportWrite(t *testing.T) {
+	report := &RemoteCleanupReport{MinAge: 15 * time.Minute, Files: []RemoteTempFile{
+		{Service: "task-manager-db", Path: "/tmp/infrahubops_prefect.dump", Size: 2048, Removed: true},
+		{Service: "database", Path: "/tmp/infrahubops", Size: 1 << 20, Active: true},
+	}}
+	var out bytes.Buffer
+	report.Write(&out)
+	want := "SERVICE          PATH                           SIZE    ACTION\n" +
+		"database         /tmp/infrahubops               1.0 MB  kept (changed in the last 15m0s)\n" +
+		"task-manager-db  /tmp/infrahubops_prefect.dump  2.0 KB  removed\n"
+	if out.String() != want {
+		t.Errorf("output:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
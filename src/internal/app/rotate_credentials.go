@@ -0,0 +1,462 @@
+package app
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Database components whose password rotate-credentials changes.
+const (
+	CredentialComponentNeo4j    = "neo4j"
+	CredentialComponentPostgres = "postgres"
+)
+
+// generatedPasswordLength is the length of generated passwords. Letters and
+// digits only, so they need no escaping in env files and connection URLs.
+const generatedPasswordLength = 32
+
+const passwordAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// credentialDependents are the services reading each password, restarted in
+// this order once it changed.
+var credentialDependents = map[string][]string{
+	CredentialComponentNeo4j:    {"infrahub-server", "task-worker"},
+	CredentialComponentPostgres: {"task-manager", "task-manager-background-svc"},
+}
+
+// CredentialLocation is a setting holding the rotated password: a variable
+// of the compose env file or a key of a Kubernetes secret.
+type CredentialLocation struct {
+	Secret string `json:"secret,omitempty"`
+	Key    string `json:"key"`
+
+	value string // new value, with the password replaced
+}
+
+func (l CredentialLocation) String() string {
+	if l.Secret != "" {
+		return fmt.Sprintf("secret %s key %s", l.Secret, l.Key)
+	}
+	return l.Key
+}
+
+// CredentialRotation reports what rotate-credentials changed, or would
+// change with --dry-run.
+type CredentialRotation struct {
+	Component string               `json:"component"`
+	User      string               `json:"user"`
+	EnvFile   string               `json:"env_file,omitempty"`
+	Locations []CredentialLocation `json:"locations"`
+	Restarted []string             `json:"restarted,omitempty"`
+	DryRun    bool                 `json:"dry_run,omitempty"`
+}
+
+// Write prints the rotation report.
+func (r *CredentialRotation) Write(w io.Writer) {
+	verb := "Rotated"
+	if r.DryRun {
+		verb = "Would rotate"
+	}
+	fmt.Fprintf(w, "%s the %s password of user %s\n", verb, r.Component, r.User)
+	if r.EnvFile != "" {
+		fmt.Fprintf(w, "Env file: %s\n", r.EnvFile)
+	}
+	for _, location := range r.Locations {
+		fmt.Fprintf(w, "  - %s\n", location)
+	}
+	if len(r.Restarted) > 0 {
+		fmt.Fprintf(w, "Restarted: %s\n", strings.Join(r.Restarted, ", "))
+	}
+}
+
+// generatePassword returns a random password of generatedPasswordLength.
+func generatePassword() (string, error) {
+	password := make([]byte, generatedPasswordLength)
+	limit := big.NewInt(int64(len(passwordAlphabet)))
+	for i := range password {
+		n, err := rand.Int(rand.Reader, limit)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate password: %w", err)
+		}
+		password[i] = passwordAlphabet[n.Int64()]
+	}
+	return string(password), nil
+}
+
+// replacePassword returns value with the password of user replaced, when
+// value is the password itself, a NEO4J_AUTH style user/password pair or a
+// connection URL authenticating as user.
+func replacePassword(value, user, oldPassword, newPassword string) (string, bool) {
+	switch {
+	case value == oldPassword:
+		return newPassword, true
+	case value == user+"/"+oldPassword:
+		return user + "/" + newPassword, true
+	case strings.Contains(value, "://"+user+":"+oldPassword+"@"):
+		return strings.ReplaceAll(value, "://"+user+":"+oldPassword+"@", "://"+user+":"+newPassword+"@"), true
+	}
+	return value, false
+}
+
+// rotateEnvFile returns content with the password replaced in every variable
+// holding it, keeping comments, quotes and line order.
+func rotateEnvFile(content, user, oldPassword, newPassword string) (string, []CredentialLocation) {
+	lines := strings.Split(content, "\n")
+	var locations []CredentialLocation
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		quote := ""
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			quote = value[:1]
+			value = value[1 : len(value)-1]
+		}
+		rotated, changed := replacePassword(value, user, oldPassword, newPassword)
+		if !changed {
+			continue
+		}
+		lines[i] = key + "=" + quote + rotated + quote
+		name := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(key), "export "))
+		locations = append(locations, CredentialLocation{Key: name})
+	}
+	return strings.Join(lines, "\n"), locations
+}
+
+// rotateSecrets finds the keys of the secrets in `kubectl get secrets -o json`
+// output that hold the password, with their new values. Helm release records
+// and service account tokens are skipped.
+func rotateSecrets(output, user, oldPassword, newPassword string) ([]CredentialLocation, error) {
+	var list struct {
+		Items []struct {
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+			Type string            `json:"type"`
+			Data map[string]string `json:"data"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal([]byte(output), &list); err != nil {
+		return nil, fmt.Errorf("failed to parse secret list: %w", err)
+	}
+
+	var locations []CredentialLocation
+	for _, item := range list.Items {
+		if item.Type == "helm.sh/release.v1" || item.Type == "kubernetes.io/service-account-token" {
+			continue
+		}
+		for key, encoded := range item.Data {
+			decoded, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				continue
+			}
+			if rotated, changed := replacePassword(string(decoded), user, oldPassword, newPassword); changed {
+				locations = append(locations, CredentialLocation{Secret: item.Metadata.Name, Key: key, value: rotated})
+			}
+		}
+	}
+	sort.Slice(locations, func(i, j int) bool {
+		if locations[i].Secret != locations[j].Secret {
+			return locations[i].Secret < locations[j].Secret
+		}
+		return locations[i].Key < locations[j].Key
+	})
+	return locations, nil
+}
+
+// cypherString quotes s as a Cypher string literal.
+func cypherString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// setDatabasePassword changes the password of the configured user of
+// component from oldPassword to newPassword.
+func (iops *InfrahubOps) setDatabasePassword(component, oldPassword, newPassword string) error {
+	switch component {
+	case CredentialComponentNeo4j:
+		query := fmt.Sprintf("ALTER CURRENT USER SET PASSWORD FROM %s TO %s", cypherString(oldPassword), cypherString(newPassword))
+		if output, err := iops.Exec("database", []string{"cypher-shell", "-u", iops.config.Neo4jUsername, "-p" + oldPassword, "-d", "system", query}, nil); err != nil {
+			return fmt.Errorf("failed to change the Neo4j password: %w\nOutput: %v", err, output)
+		}
+	case CredentialComponentPostgres:
+		user := iops.config.PostgresUsername
+		sql := fmt.Sprintf(`ALTER ROLE "%s" WITH PASSWORD '%s'`, strings.ReplaceAll(user, `"`, `""`), strings.ReplaceAll(newPassword, "'", "''"))
+		opts := &ExecOptions{Env: map[string]string{"PGPASSWORD": oldPassword}}
+		if output, err := iops.Exec("task-manager-db", []string{"psql", "-h", "localhost", "-U", user, "-d", iops.config.PostgresDatabase, "-v", "ON_ERROR_STOP=1", "-c", sql}, opts); err != nil {
+			return fmt.Errorf("failed to change the PostgreSQL password: %w\nOutput: %v", err, output)
+		}
+	}
+	return nil
+}
+
+// credentialStore updates where the deployment keeps a password: the compose
+// env file or Kubernetes secrets.
+type credentialStore interface {
+	plan(user, oldPassword, newPassword string) ([]CredentialLocation, error)
+	apply(locations []CredentialLocation) error
+	restart(services []string) ([]string, error)
+}
+
+// composeCredentialStore rewrites the env file compose interpolates, then
+// recreates the dependent services so they read it again.
+type composeCredentialStore struct {
+	docker  *DockerBackend
+	envFile string
+	content string
+}
+
+func (s *composeCredentialStore) plan(user, oldPassword, newPassword string) ([]CredentialLocation, error) {
+	data, err := os.ReadFile(s.envFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read env file: %w", err)
+	}
+	var locations []CredentialLocation
+	s.content, locations = rotateEnvFile(string(data), user, oldPassword, newPassword)
+	if len(locations) == 0 {
+		return nil, fmt.Errorf("no variable in %s holds the current password; move it there from the compose file, or pass --env-file", s.envFile)
+	}
+	return locations, nil
+}
+
+func (s *composeCredentialStore) apply([]CredentialLocation) error {
+	info, err := os.Stat(s.envFile)
+	if err != nil {
+		return fmt.Errorf("failed to stat env file: %w", err)
+	}
+	temp := s.envFile + ".rotating"
+	if err := os.WriteFile(temp, []byte(s.content), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write env file: %w", err)
+	}
+	if err := os.Rename(temp, s.envFile); err != nil {
+		os.Remove(temp)
+		return fmt.Errorf("failed to replace env file: %w", err)
+	}
+	return nil
+}
+
+func (s *composeCredentialStore) restart(services []string) ([]string, error) {
+	fileArgs, err := s.docker.composeFileArgs()
+	if err != nil {
+		return nil, err
+	}
+	output, err := s.docker.executor.runCommand("docker", s.docker.composeArgs(append(fileArgs, "--env-file", s.envFile, "config", "--services")...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list compose services: %w\nOutput: %s", err, output)
+	}
+	defined := strings.Fields(output)
+	var restarted []string
+	for _, service := range services {
+		if !slices.Contains(defined, service) {
+			continue
+		}
+		logrus.Infof("Recreating %s with the new password...", service)
+		args := append(fileArgs, "--env-file", s.envFile, "up", "-d", "--no-deps", "--wait", service)
+		if output, err := s.docker.executor.runCommand("docker", s.docker.composeArgs(args...)...); err != nil {
+			return restarted, fmt.Errorf("failed to recreate %s: %w\nOutput: %s", service, err, output)
+		}
+		restarted = append(restarted, service)
+	}
+	return restarted, nil
+}
+
+// secretCredentialStore patches the Kubernetes secrets holding the password,
+// then restarts the dependent workloads so they read it again.
+type secretCredentialStore struct {
+	k8s *KubernetesBackend
+}
+
+func (s *secretCredentialStore) plan(user, oldPassword, newPassword string) ([]CredentialLocation, error) {
+	args := []string{"get", "secrets", "-n", s.k8s.namespace, "-o", "json"}
+	if instance := s.k8s.instanceSelector(); instance != "" {
+		args = append(args, "-l", instance)
+	}
+	output, err := s.k8s.executor.runCommand("kubectl", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+	locations, err := rotateSecrets(output, user, oldPassword, newPassword)
+	if err != nil {
+		return nil, err
+	}
+	if len(locations) == 0 {
+		return nil, fmt.Errorf("no secret in namespace %s holds the current password", s.k8s.namespace)
+	}
+	return locations, nil
+}
+
+func (s *secretCredentialStore) apply(locations []CredentialLocation) error {
+	patches := map[string]map[string]string{}
+	for _, location := range locations {
+		if patches[location.Secret] == nil {
+			patches[location.Secret] = map[string]string{}
+		}
+		patches[location.Secret][location.Key] = base64.StdEncoding.EncodeToString([]byte(location.value))
+	}
+	secrets := make([]string, 0, len(patches))
+	for secret := range patches {
+		secrets = append(secrets, secret)
+	}
+	sort.Strings(secrets)
+
+	for _, secret := range secrets {
+		// A patch file keeps the password off the kubectl command line.
+		data, err := json.Marshal(map[string]any{"data": patches[secret]})
+		if err != nil {
+			return err
+		}
+		patchFile, err := os.CreateTemp("", "infrahub-secret-patch-*.json")
+		if err != nil {
+			return fmt.Errorf("failed to create patch file: %w", err)
+		}
+		_, err = patchFile.Write(data)
+		if closeErr := patchFile.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			var output string
+			output, err = s.k8s.executor.runCommand("kubectl", "patch", "secret", secret, "-n", s.k8s.namespace, "--type", "merge", "--patch-file", patchFile.Name())
+			if err != nil {
+				err = fmt.Errorf("%w\nOutput: %s", err, output)
+			}
+		}
+		os.Remove(patchFile.Name())
+		if err != nil {
+			return fmt.Errorf("failed to patch secret %s: %w", secret, err)
+		}
+	}
+	return nil
+}
+
+func (s *secretCredentialStore) restart(services []string) ([]string, error) {
+	var restarted []string
+	for _, service := range services {
+		kind, name, err := s.k8s.findWorkloadResource(service)
+		if err != nil {
+			logrus.Debugf("No workload for %s: %v", service, err)
+			continue
+		}
+		namespace := s.k8s.namespaceFor(service)
+		logrus.Infof("Restarting %s/%s with the new password...", kind, name)
+		if output, err := s.k8s.executor.runCommand("kubectl", "rollout", "restart", kind+"/"+name, "-n", namespace); err != nil {
+			return restarted, fmt.Errorf("failed to restart %s/%s: %w\nOutput: %s", kind, name, err, output)
+		}
+		if output, err := s.k8s.executor.runCommand("kubectl", "rollout", "status", kind+"/"+name, "-n", namespace, "--timeout", "10m"); err != nil {
+			return restarted, fmt.Errorf("%s/%s did not become ready: %w\nOutput: %s", kind, name, err, output)
+		}
+		restarted = append(restarted, service)
+	}
+	return restarted, nil
+}
+
+// credentialStore returns where the deployment keeps its passwords. envFile
+// defaults to the .env file of the compose project directory.
+func (iops *InfrahubOps) credentialStore(envFile string) (credentialStore, string, error) {
+	backend, err := iops.ensureBackend()
+	if err != nil {
+		return nil, "", err
+	}
+	switch backend := backend.(type) {
+	case *DockerBackend:
+		if envFile == "" {
+			workingDir, _, err := backend.composeProjectFiles()
+			if err != nil {
+				return nil, "", err
+			}
+			envFile = filepath.Join(workingDir, ".env")
+		}
+		return &composeCredentialStore{docker: backend, envFile: envFile}, envFile, nil
+	case *KubernetesBackend:
+		if envFile != "" {
+			return nil, "", fmt.Errorf("--env-file only applies to docker compose deployments")
+		}
+		return &secretCredentialStore{k8s: backend}, "", nil
+	}
+	return nil, "", fmt.Errorf("credential rotation is not supported on the %s backend", backend.Name())
+}
+
+// RotateCredentials replaces the password of the Neo4j or PostgreSQL user
+// Infrahub connects as. The database is changed first, then every env file
+// variable or secret key holding the old password, then the services reading
+// it are restarted in order. When the settings cannot be updated, the
+// database password is changed back. newPassword is generated when empty.
+func (iops *InfrahubOps) RotateCredentials(component, newPassword, envFile string, dryRun bool) (*CredentialRotation, error) {
+	if _, ok := credentialDependents[component]; !ok {
+		return nil, fmt.Errorf("unknown component %q: use %s or %s", component, CredentialComponentNeo4j, CredentialComponentPostgres)
+	}
+	if err := iops.DetectEnvironment(); err != nil {
+		return nil, err
+	}
+	if !dryRun {
+		releaseLock, err := iops.acquireOperationLock("rotate-credentials")
+		if err != nil {
+			return nil, err
+		}
+		defer releaseLock()
+	}
+	store, envFile, err := iops.credentialStore(envFile)
+	if err != nil {
+		return nil, err
+	}
+
+	user, oldPassword := iops.config.Neo4jUsername, iops.config.Neo4jPassword
+	if component == CredentialComponentPostgres {
+		user, oldPassword = iops.config.PostgresUsername, iops.config.PostgresPassword
+	}
+	if newPassword == "" {
+		if newPassword, err = generatePassword(); err != nil {
+			return nil, err
+		}
+	}
+	if newPassword == oldPassword {
+		return nil, fmt.Errorf("the new password is the current one")
+	}
+
+	locations, err := store.plan(user, oldPassword, newPassword)
+	if err != nil {
+		return nil, err
+	}
+	rotation := &CredentialRotation{Component: component, User: user, EnvFile: envFile, Locations: locations, DryRun: dryRun}
+	if dryRun {
+		return rotation, nil
+	}
+
+	if err := iops.setDatabasePassword(component, oldPassword, newPassword); err != nil {
+		return nil, err
+	}
+	logrus.Infof("Changed the %s password of user %s", component, user)
+	if err := store.apply(locations); err != nil {
+		if rollbackErr := iops.setDatabasePassword(component, newPassword, oldPassword); rollbackErr != nil {
+			return nil, fmt.Errorf("%w; changing the password back also failed, the database now expects the new password: %v", err, rollbackErr)
+		}
+		return nil, fmt.Errorf("%w; the database password was changed back", err)
+	}
+
+	if component == CredentialComponentNeo4j {
+		iops.config.Neo4jPassword = newPassword
+	} else {
+		iops.config.PostgresPassword = newPassword
+	}
+	iops.storeCachedCredentials()
+
+	rotation.Restarted, err = store.restart(credentialDependents[component])
+	if err != nil {
+		return rotation, fmt.Errorf("password rotated, but restarting the services failed: %w", err)
+	}
+	return rotation, nil
+}
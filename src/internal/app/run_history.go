@@ -0,0 +1,127 @@
+package app
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// runHistoryFilename is the append-only log of runs kept in BackupDir, one
+// JSON object per line.
+const runHistoryFilename = "backup_history.jsonl"
+
+// Operations recorded in the run history besides those of RunWithReport.
+const (
+	HistoryGC      = "gc"
+	HistoryHold    = "hold"
+	HistoryRelease = "release"
+)
+
+// HistoryEntry is one run in the history log.
+type HistoryEntry struct {
+	Time            time.Time `json:"time"`
+	Operation       string    `json:"operation"`
+	Status          string    `json:"status"` // success, degraded or failure
+	Target          string    `json:"target,omitempty"`
+	DurationSeconds float64   `json:"duration_seconds,omitempty"`
+	BackupID        string    `json:"backup_id,omitempty"`
+	SizeBytes       int64     `json:"size_bytes,omitempty"`
+	Error           string    `json:"error,omitempty"`
+	Actions         []string  `json:"actions,omitempty"` // what gc, hold or release changed
+	SLOBreaches     []string  `json:"slo_breaches,omitempty"`
+
+	PhaseSeconds map[string]float64 `json:"phase_seconds,omitempty"` // duration of each successful phase
+}
+
+// appendRunHistory adds entry to the history log. The log lives next to the
+// catalog, so nothing is written while BackupDir does not exist; a failure to
+// write is only logged.
+func (iops *InfrahubOps) appendRunHistory(entry HistoryEntry) {
+	if info, err := os.Stat(iops.config.BackupDir); err != nil || !info.IsDir() {
+		return
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	entry.Time = entry.Time.UTC()
+	if entry.Target == "" && iops.backend != nil {
+		entry.Target = iops.backend.Info()
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		logrus.Warnf("Failed to record %s in the run history: %v", entry.Operation, err)
+		return
+	}
+	path := filepath.Join(iops.config.BackupDir, runHistoryFilename)
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err == nil {
+		_, err = file.Write(append(data, '\n'))
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		logrus.Warnf("Failed to record %s in the run history: %v", entry.Operation, err)
+	}
+}
+
+// recordRunHistory adds the outcome of report to the history log.
+func (iops *InfrahubOps) recordRunHistory(report *RunReport) {
+	entry := HistoryEntry{
+		Time:            report.StartedAt,
+		Operation:       report.Operation,
+		Status:          report.Status(),
+		DurationSeconds: report.Duration.Round(time.Millisecond).Seconds(),
+		BackupID:        report.BackupID,
+		SizeBytes:       report.SizeBytes,
+	}
+	if report.Err != nil {
+		entry.Error = report.Err.Error()
+	}
+	for _, breach := range report.SLOBreaches() {
+		entry.SLOBreaches = append(entry.SLOBreaches, breach.String())
+	}
+	for phase, duration := range report.Phases() {
+		if entry.PhaseSeconds == nil {
+			entry.PhaseSeconds = map[string]float64{}
+		}
+		entry.PhaseSeconds[phase] = duration.Round(time.Millisecond).Seconds()
+	}
+	iops.appendRunHistory(entry)
+}
+
+// loadRunHistory reads the entries of the history log in backupDir recorded
+// at or after since. A missing log is empty; unreadable lines are skipped.
+func loadRunHistory(backupDir string, since time.Time) ([]HistoryEntry, error) {
+	file, err := os.Open(filepath.Join(backupDir, runHistoryFilename))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read run history: %w", err)
+	}
+	defer file.Close()
+
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var entry HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			logrus.Warnf("Skipping line %d of the run history: %v", line, err)
+			continue
+		}
+		if !entry.Time.Before(since) {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read run history: %w", err)
+	}
+	return entries, nil
+}
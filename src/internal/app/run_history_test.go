@@ -0,0 +1,70 @@
+package app
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRunHistoryRecordsRuns(t *testing.T) {
+	iops := newFakeDockerOps(newFakeExecutor())
+	iops.config.BackupDir = t.TempDir()
+
+	_ = iops.RunWithReport("backup", func() error {
+		iops.recordArtifact("infrahub_backup_20260101_020000", "", "", 3<<20)
+		iops.startPhase("archive").end(nil)
+		iops.startPhase("upload").end(errors.New("timeout"))
+		return nil
+	})
+	_ = iops.RunWithReport("restore", func() error { return errors.New("neo4j restore failed") })
+
+	entries, err := loadRunHistory(iops.config.BackupDir, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("loadRunHistory() = %+v, want 2 entries", entries)
+	}
+	if got := entries[0]; got.Operation != "backup" || got.Status != "success" || got.Target != "test" ||
+		got.BackupID != "infrahub_backup_20260101_020000" || got.SizeBytes != 3<<20 {
+		t.Errorf("backup entry = %+v", got)
+	}
+	if _, ok := entries[0].PhaseSeconds["archive"]; !ok || len(entries[0].PhaseSeconds) != 1 {
+		t.Errorf("backup phase_seconds = %v, want only archive", entries[0].PhaseSeconds)
+	}
+	if got := entries[1]; got.Operation != "restore" || got.Status != "failure" || got.Error != "neo4j restore failed" {
+		t.Errorf("restore entry = %+v", got)
+	}
+
+	entries, err = loadRunHistory(iops.config.BackupDir, time.Now().Add(time.Hour))
+	if err != nil || len(entries) != 0 {
+		t.Errorf("loadRunHistory(future) = %+v, %v", entries, err)
+	}
+}
+
+func TestRunHistoryNeedsBackupDir(t *testing.T) {
+	iops := newFakeDockerOps(newFakeExecutor())
+	iops.config.BackupDir = filepath.Join(t.TempDir(), "missing")
+
+	iops.appendRunHistory(HistoryEntry{Operation: HistoryGC, Status: "success"})
+	if _, err := os.Stat(iops.config.BackupDir); !os.IsNotExist(err) {
+		t.Errorf("appendRunHistory() created the backup directory (%v)", err)
+	}
+}
+
+func TestLoadRunHistorySkipsBadLines(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, runHistoryFilename, `{"time":"2026-01-01T02:00:00Z","operation":"backup","status":"success"}
+not json
+{"time":"2026-01-02T02:00:00Z","operation":"gc","status":"success","actions":["partial /backups/x.tmp: delete file"]}
+`)
+	entries, err := loadRunHistory(dir, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[1].Operation != HistoryGC || len(entries[1].Actions) != 1 {
+		t.Errorf("loadRunHistory() = %+v", entries)
+	}
+}
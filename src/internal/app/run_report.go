@@ -0,0 +1,281 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RunReport summarises one create or restore invocation for CI consumers.
+type RunReport struct {
+	Operation  string
+	StartedAt  time.Time
+	Duration   time.Duration
+	Err        error
+	BackupID   string
+	BackupPath string
+	S3URI      string
+	SizeBytes  int64
+	Targets    []targetRestoreResult
+	Degraded   []string // services that did not stabilize after being restarted
+	Warnings   []string // warnings logged during the run
+
+	DataCapturedAt time.Time   // when the restored backup was taken; restores only
+	SLO            []SLOResult // evaluation against --rpo and --rto
+
+	phases *phaseDurations // successful phases of a RunWithReport run
+}
+
+// phaseDurations sums the durations of successful phases, which targets of
+// a group run concurrently.
+type phaseDurations struct {
+	mu        sync.Mutex
+	durations map[string]time.Duration
+}
+
+// Succeeded reports whether the run finished without error.
+func (r *RunReport) Succeeded() bool {
+	return r.Err == nil
+}
+
+// Status is success, degraded or failure.
+func (r *RunReport) Status() string {
+	switch {
+	case !r.Succeeded():
+		return "failure"
+	case len(r.Degraded) > 0:
+		return "degraded"
+	}
+	return "success"
+}
+
+// RunWithReport runs fn as operation, collecting a RunReport that artifacts
+// produced along the way are recorded into. When running inside GitHub
+// Actions the report is published as a step summary, step outputs and an
+// annotation, and it is added to the run history and sent to the configured
+// notification channels. The run is evaluated against --rpo and --rto first.
+// fn's error is returned unchanged.
+func (iops *InfrahubOps) RunWithReport(operation string, fn func() error) error {
+	report := &RunReport{Operation: operation, StartedAt: time.Now(), phases: &phaseDurations{durations: map[string]time.Duration{}}}
+	iops.report = report
+	defer func() { iops.report = nil }()
+	mark := iops.warnings.mark()
+
+	err := fn()
+	report.Duration = time.Since(report.StartedAt)
+	report.Err = err
+	iops.evaluateSLO(report)
+	if len(report.SLOBreaches()) > 0 {
+		iops.sloBreached = true
+	}
+	report.Warnings = iops.warnings.since(mark)
+
+	if githubActionsEnabled() {
+		if pubErr := publishGitHubReport(report); pubErr != nil {
+			logrus.Warnf("Failed to write GitHub Actions summary: %v", pubErr)
+		}
+	}
+	iops.recordRunHistory(report)
+	iops.notify(report)
+	return err
+}
+
+// recordPhase adds the duration of a successful phase to the report.
+func (r *RunReport) recordPhase(name string, duration time.Duration) {
+	if r.phases == nil {
+		return
+	}
+	r.phases.mu.Lock()
+	defer r.phases.mu.Unlock()
+	r.phases.durations[name] += duration
+}
+
+// Phases returns the duration of each successful phase of the run, summed
+// over the targets of a group.
+func (r *RunReport) Phases() map[string]time.Duration {
+	if r.phases == nil {
+		return nil
+	}
+	r.phases.mu.Lock()
+	defer r.phases.mu.Unlock()
+	return maps.Clone(r.phases.durations)
+}
+
+// recordArtifact stores the produced archive in the active report, if any.
+func (iops *InfrahubOps) recordArtifact(backupID, backupPath, s3URI string, sizeBytes int64) {
+	if iops.report == nil {
+		return
+	}
+	iops.report.BackupID = backupID
+	iops.report.BackupPath = backupPath
+	iops.report.S3URI = s3URI
+	iops.report.SizeBytes = sizeBytes
+}
+
+// recordDegraded stores services that did not stabilize in the active report,
+// if any.
+func (iops *InfrahubOps) recordDegraded(services []string) {
+	if iops.report == nil {
+		return
+	}
+	iops.report.Degraded = append(iops.report.Degraded, services...)
+}
+
+// recordRestoreSource stores the archive a restore reads from, and when it was
+// created, in the active report, if any.
+func (iops *InfrahubOps) recordRestoreSource(backupID, backupFile, createdAt string) {
+	if iops.report == nil {
+		return
+	}
+	if captured, err := time.Parse(time.RFC3339, createdAt); err == nil {
+		iops.report.DataCapturedAt = captured
+	}
+	if IsS3URI(backupFile) {
+		iops.recordArtifact(backupID, "", backupFile, 0)
+		return
+	}
+	iops.recordArtifact(backupID, backupFile, "", 0)
+}
+
+func githubActionsEnabled() bool {
+	return os.Getenv("GITHUB_ACTIONS") == "true"
+}
+
+// publishGitHubReport appends the markdown summary to GITHUB_STEP_SUMMARY,
+// writes step outputs to GITHUB_OUTPUT and prints a workflow annotation.
+func publishGitHubReport(report *RunReport) error {
+	if path := os.Getenv("GITHUB_STEP_SUMMARY"); path != "" {
+		if err := appendToFile(path, func(w io.Writer) { writeGitHubSummary(w, report) }); err != nil {
+			return fmt.Errorf("step summary: %w", err)
+		}
+	}
+	if path := os.Getenv("GITHUB_OUTPUT"); path != "" {
+		if err := appendToFile(path, func(w io.Writer) { writeGitHubOutputs(w, report) }); err != nil {
+			return fmt.Errorf("step outputs: %w", err)
+		}
+	}
+	writeGitHubAnnotation(os.Stdout, report)
+	return nil
+}
+
+func appendToFile(path string, write func(io.Writer)) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	write(f)
+	return f.Close()
+}
+
+// writeGitHubSummary renders the report as GitHub-flavoured markdown.
+func writeGitHubSummary(w io.Writer, report *RunReport) {
+	status := "✅ succeeded"
+	if !report.Succeeded() {
+		status = "❌ failed"
+	} else if len(report.Degraded) > 0 {
+		status = "⚠️ degraded"
+	}
+	fmt.Fprintf(w, "### Infrahub %s %s\n\n", report.Operation, status)
+	fmt.Fprintln(w, "| Field | Value |")
+	fmt.Fprintln(w, "|-------|-------|")
+	row := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(w, "| %s | %s |\n", name, markdownCell(value))
+		}
+	}
+	row("Duration", report.Duration.Round(time.Second).String())
+	row("Backup ID", report.BackupID)
+	row("Backup path", report.BackupPath)
+	row("S3 URI", report.S3URI)
+	if report.SizeBytes > 0 {
+		row("Size", formatBytes(report.SizeBytes))
+	}
+	if report.Err != nil {
+		row("Error", report.Err.Error())
+	}
+	row("Degraded services", strings.Join(report.Degraded, ", "))
+	for _, result := range report.SLO {
+		mark := "✅"
+		if result.Breached {
+			mark = "❌"
+		}
+		row(strings.ToUpper(result.Objective), fmt.Sprintf("%s %s (target %s)", mark, result.Actual.Round(time.Second), result.Target))
+	}
+	if len(report.Warnings) > 0 {
+		row("Warnings", strings.Join(report.Warnings, "<br>"))
+	}
+
+	if len(report.Targets) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "| Target | Backend | Status | Duration | Error |")
+		fmt.Fprintln(w, "|--------|---------|--------|----------|-------|")
+		for _, target := range report.Targets {
+			status, errMsg := "ok", ""
+			if target.Err != nil {
+				status, errMsg = "failed", target.Err.Error()
+			}
+			fmt.Fprintf(w, "| %s | %s | %s | %s | %s |\n",
+				markdownCell(target.Target.Spec),
+				target.Target.Backend,
+				status,
+				target.Duration.Round(time.Second),
+				markdownCell(errMsg),
+			)
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+// writeGitHubOutputs writes key=value step outputs.
+func writeGitHubOutputs(w io.Writer, report *RunReport) {
+	fmt.Fprintf(w, "status=%s\n", report.Status())
+	fmt.Fprintf(w, "duration_seconds=%d\n", int64(report.Duration.Seconds()))
+	if report.BackupID != "" {
+		fmt.Fprintf(w, "backup_id=%s\n", report.BackupID)
+	}
+	if report.BackupPath != "" {
+		fmt.Fprintf(w, "backup_path=%s\n", report.BackupPath)
+	}
+	if report.S3URI != "" {
+		fmt.Fprintf(w, "s3_uri=%s\n", report.S3URI)
+	}
+	if len(report.Degraded) > 0 {
+		fmt.Fprintf(w, "degraded_services=%s\n", strings.Join(report.Degraded, ","))
+	}
+	if len(report.Warnings) > 0 {
+		fmt.Fprintf(w, "warning_count=%d\n", len(report.Warnings))
+	}
+	if len(report.SLO) > 0 {
+		fmt.Fprintf(w, "slo_breached=%t\n", len(report.SLOBreaches()) > 0)
+	}
+}
+
+// writeGitHubAnnotation prints a workflow command so the result shows up on
+// the run page.
+func writeGitHubAnnotation(w io.Writer, report *RunReport) {
+	title := "Infrahub " + report.Operation
+	if report.Succeeded() && len(report.Degraded) > 0 {
+		fmt.Fprintf(w, "::warning title=%s::%s completed but %s did not stabilize\n", title, report.Operation, strings.Join(report.Degraded, ", "))
+		return
+	}
+	if report.Succeeded() {
+		fmt.Fprintf(w, "::notice title=%s::%s completed in %s\n", title, report.Operation, report.Duration.Round(time.Second))
+		return
+	}
+	fmt.Fprintf(w, "::error title=%s::%s\n", title, escapeWorkflowCommand(report.Err.Error()))
+}
+
+// escapeWorkflowCommand escapes data for a GitHub Actions workflow command.
+func escapeWorkflowCommand(value string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(value)
+}
+
+func markdownCell(value string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ").Replace(value)
+}
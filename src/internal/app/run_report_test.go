@@ -0,0 +1,123 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestWriteGitHubOutputs(t *testing.T) {
+	var buf bytes.Buffer
+	writeGitHubOutputs(&buf, &RunReport{
+		Operation:  "backup",
+		Duration:   95 * time.Second,
+		BackupID:   "infrahub_backup_20251016_020000",
+		BackupPath: "/backups/infrahub_backup_20251016_020000.tar.gz",
+		S3URI:      "s3://backups/infrahub/infrahub_backup_20251016_020000.tar.gz",
+	})
+
+	want := "status=success\n" +
+		"duration_seconds=95\n" +
+		"backup_id=infrahub_backup_20251016_020000\n" +
+		"backup_path=/backups/infrahub_backup_20251016_020000.tar.gz\n" +
+		"s3_uri=s3://backups/infrahub/infrahub_backup_20251016_020000.tar.gz\n"
+	if buf.String() != want {
+		t.Errorf("outputs =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestWriteGitHubAnnotation(t *testing.T) {
+	var buf bytes.Buffer
+	writeGitHubAnnotation(&buf, &RunReport{Operation: "restore", Err: errors.New("neo4j restore failed: 100%\ndone")})
+	want := "::error title=Infrahub restore::neo4j restore failed: 100%25%0Adone\n"
+	if buf.String() != want {
+		t.Errorf("annotation = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestWriteGitHubReportDegraded(t *testing.T) {
+	report := &RunReport{Operation: "backup", Duration: time.Minute, Degraded: []string{"task-worker", "infrahub-server"}}
+
+	var outputs, annotation, summary bytes.Buffer
+	writeGitHubOutputs(&outputs, report)
+	writeGitHubAnnotation(&annotation, report)
+	writeGitHubSummary(&summary, report)
+
+	if !strings.HasPrefix(outputs.String(), "status=degraded\n") || !strings.Contains(outputs.String(), "degraded_services=task-worker,infrahub-server\n") {
+		t.Errorf("outputs = %q", outputs.String())
+	}
+	if want := "::warning title=Infrahub backup::backup completed but task-worker, infrahub-server did not stabilize\n"; annotation.String() != want {
+		t.Errorf("annotation = %q, want %q", annotation.String(), want)
+	}
+	if !strings.Contains(summary.String(), "degraded") || !strings.Contains(summary.String(), "| Degraded services | task-worker, infrahub-server |") {
+		t.Errorf("summary =\n%s", summary.String())
+	}
+}
+
+func TestRunWithReportPublishesInGitHubActions(t *testing.T) {
+	dir := t.TempDir()
+	summaryPath := filepath.Join(dir, "summary.md")
+	outputPath := filepath.Join(dir, "output")
+	t.Setenv("GITHUB_ACTIONS", "true")
+	t.Setenv("GITHUB_STEP_SUMMARY", summaryPath)
+	t.Setenv("GITHUB_OUTPUT", outputPath)
+
+	iops := NewInfrahubOps()
+	wantErr := errors.New("backup | failed")
+	err := iops.RunWithReport("backup", func() error {
+		iops.recordArtifact("infrahub_backup_x", "/backups/infrahub_backup_x.tar.gz", "", 2048)
+		return wantErr
+	})
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("RunWithReport returned %v, want %v", err, wantErr)
+	}
+	if iops.report != nil {
+		t.Error("report should be cleared after the run")
+	}
+
+	summary, err := os.ReadFile(summaryPath)
+	if err != nil {
+		t.Fatalf("read summary: %v", err)
+	}
+	for _, want := range []string{"### Infrahub backup ❌ failed", "| Backup path | /backups/infrahub_backup_x.tar.gz |", `backup \| failed`} {
+		if !strings.Contains(string(summary), want) {
+			t.Errorf("summary missing %q:\n%s", want, summary)
+		}
+	}
+
+	outputs, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("read outputs: %v", err)
+	}
+	if !strings.Contains(string(outputs), "status=failure\n") {
+		t.Errorf("outputs missing failure status:\n%s", outputs)
+	}
+}
+
+func TestRunWithReportCollectsWarnings(t *testing.T) {
+	iops := NewInfrahubOps()
+	iops.warnings.Fire(&logrus.Entry{Message: "logged before the run"})
+
+	var report *RunReport
+	iops.RunWithReport("backup", func() error {
+		report = iops.report
+		iops.warnings.Fire(&logrus.Entry{Message: "failed to remove temporary files"})
+		return nil
+	})
+	if want := []string{"failed to remove temporary files"}; !slices.Equal(report.Warnings, want) {
+		t.Errorf("report warnings = %q, want %q", report.Warnings, want)
+	}
+
+	var outputs bytes.Buffer
+	writeGitHubOutputs(&outputs, report)
+	if !strings.Contains(outputs.String(), "status=success\n") || !strings.Contains(outputs.String(), "warning_count=1\n") {
+		t.Errorf("outputs = %q", outputs.String())
+	}
+}
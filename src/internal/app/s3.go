@@ -1,6 +1,7 @@
 package app
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -8,18 +9,21 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/minio/minio-go/v7"
-	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/tags"
 	"github.com/sirupsen/logrus"
 )
 
 // S3Config holds S3-related configuration
 type S3Config struct {
-	Bucket   string
-	Prefix   string
-	Endpoint string
-	Region   string
+	Bucket      string
+	Prefix      string
+	Endpoint    string
+	Region      string
+	Credentials AWSCredentialsConfig
+	HTTP        HTTPConfig // proxy and TLS settings; see InfrahubOps.s3Client
 }
 
 // S3Client wraps the minio S3 client.
@@ -33,16 +37,17 @@ type S3Config struct {
 // integrity check. This is the same client the Plakar integration-s3 backend
 // already uses successfully against GCS-style providers.
 type S3Client struct {
-	client *minio.Client
-	config *S3Config
+	client   *minio.Client
+	config   *S3Config
+	progress *eventPhase // receives the bytes transferred by Upload and Download when set
+
+	storageClass string            // storage class of Upload; empty for the bucket default
+	tags         map[string]string // object tags of Upload
 }
 
 // NewS3Client creates a new S3 client with the given configuration
 func NewS3Client(cfg *S3Config) (*S3Client, error) {
-	region := cfg.Region
-	if region == "" {
-		region = "us-east-1"
-	}
+	region := cfg.region()
 
 	// Default to AWS S3; a custom endpoint targets MinIO, GCS, or other
 	// S3-compatible storage.
@@ -66,6 +71,9 @@ func NewS3Client(cfg *S3Config) (*S3Client, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to create S3 transport: %w", err)
 	}
+	if err := cfg.HTTP.apply(transport); err != nil {
+		return nil, err
+	}
 
 	bucketLookup := minio.BucketLookupAuto
 	if pathStyle {
@@ -73,12 +81,11 @@ func NewS3Client(cfg *S3Config) (*S3Client, error) {
 	}
 
 	// Resolve credentials from the standard AWS sources (environment variables,
-	// ~/.aws/credentials, and instance/role metadata).
-	creds := credentials.NewChainCredentials([]credentials.Provider{
-		&credentials.EnvAWS{},
-		&credentials.FileAWSCredentials{},
-		&credentials.IAM{},
-	})
+	// ~/.aws/credentials, and instance/role metadata), or assume a role.
+	creds, err := s3Credentials(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve S3 credentials: %w", err)
+	}
 
 	client, err := minio.New(host, &minio.Options{
 		Creds:        creds,
@@ -97,6 +104,14 @@ func NewS3Client(cfg *S3Config) (*S3Client, error) {
 	}, nil
 }
 
+// region returns the region of the bucket, us-east-1 by default.
+func (cfg *S3Config) region() string {
+	if cfg.Region == "" {
+		return "us-east-1"
+	}
+	return cfg.Region
+}
+
 // ValidateConfig validates the S3 configuration for upload/download operations
 func (cfg *S3Config) ValidateConfig() error {
 	if cfg.Bucket == "" {
@@ -133,13 +148,16 @@ func (c *S3Client) Upload(ctx context.Context, localPath string) (string, error)
 
 	logrus.Infof("Uploading %s (%s) to s3://%s/%s",
 		filename, formatBytes(stat.Size()), c.config.Bucket, s3Key)
+	if c.storageClass != "" {
+		logrus.Infof("Using storage class %s", c.storageClass)
+	}
 
 	// minio handles multipart uploads automatically for large files.
-	_, err = c.client.PutObject(ctx, c.config.Bucket, s3Key, file, stat.Size(), minio.PutObjectOptions{
-		// GCS/Backblaze reject aws-chunked checksum trailers; Content-MD5 is the
-		// portable integrity check. Matches the integration-s3 storage backend.
-		SendContentMd5: true,
-	})
+	opts := c.putOptions()
+	if c.progress != nil {
+		opts.Progress = &progressCounter{phase: c.progress, total: stat.Size()}
+	}
+	_, err = c.client.PutObject(ctx, c.config.Bucket, s3Key, file, stat.Size(), opts)
 	if err != nil {
 		return "", fmt.Errorf("failed to upload to S3: %w", err)
 	}
@@ -150,6 +168,39 @@ func (c *S3Client) Upload(ctx context.Context, localPath string) (string, error)
 	return s3URI, nil
 }
 
+// streamUploadPartSize is the multipart part size of UploadStream; minio
+// buffers one part in memory.
+const streamUploadPartSize = 64 << 20
+
+// UploadStream uploads r, of unknown size, as filename under the prefix and
+// returns the S3 URI. The multipart upload is aborted when r fails.
+func (c *S3Client) UploadStream(ctx context.Context, filename string, r io.Reader) (string, error) {
+	s3Key := c.buildS3Key(filename)
+	logrus.Infof("Streaming %s to s3://%s/%s", filename, c.config.Bucket, s3Key)
+	opts := c.putOptions()
+	opts.PartSize = streamUploadPartSize
+	if c.progress != nil {
+		opts.Progress = &progressCounter{phase: c.progress}
+	}
+	if _, err := c.client.PutObject(ctx, c.config.Bucket, s3Key, r, -1, opts); err != nil {
+		return "", fmt.Errorf("failed to upload to S3: %w", err)
+	}
+	s3URI := fmt.Sprintf("s3://%s/%s", c.config.Bucket, s3Key)
+	logrus.Infof("Upload complete: %s", s3URI)
+	return s3URI, nil
+}
+
+// putOptions returns the options of Upload and UploadStream.
+func (c *S3Client) putOptions() minio.PutObjectOptions {
+	return minio.PutObjectOptions{
+		// GCS/Backblaze reject aws-chunked checksum trailers; Content-MD5 is the
+		// portable integrity check. Matches the integration-s3 storage backend.
+		SendContentMd5: true,
+		StorageClass:   c.storageClass,
+		UserTags:       c.tags,
+	}
+}
+
 // Download downloads a file from S3 to a local path
 func (c *S3Client) Download(ctx context.Context, s3Key, localPath string) error {
 	logrus.Infof("Downloading s3://%s/%s to %s", c.config.Bucket, s3Key, localPath)
@@ -168,7 +219,15 @@ func (c *S3Client) Download(ctx context.Context, s3Key, localPath string) error
 	}
 	defer obj.Close()
 
-	written, err := io.Copy(file, obj)
+	var source io.Reader = obj
+	if c.progress != nil {
+		counter := &progressCounter{reader: obj, phase: c.progress}
+		if info, err := obj.Stat(); err == nil {
+			counter.total = info.Size
+		}
+		source = counter
+	}
+	written, err := io.Copy(file, source)
 	if err != nil {
 		os.Remove(localPath) // Clean up partial download
 		return fmt.Errorf("failed to download from S3: %w", err)
@@ -179,6 +238,134 @@ func (c *S3Client) Download(ctx context.Context, s3Key, localPath string) error
 	return nil
 }
 
+// Open returns the object at s3Key with its size. Reads at an offset are
+// ranged GETs, so only the bytes read are transferred.
+func (c *S3Client) Open(ctx context.Context, s3Key string) (*minio.Object, int64, error) {
+	obj, err := c.client.GetObject(ctx, c.config.Bucket, s3Key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open s3://%s/%s: %w", c.config.Bucket, s3Key, err)
+	}
+	info, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		return nil, 0, fmt.Errorf("failed to open s3://%s/%s: %w", c.config.Bucket, s3Key, err)
+	}
+	return obj, info.Size, nil
+}
+
+// List returns the keys of the objects under the configured prefix.
+func (c *S3Client) List(ctx context.Context) ([]string, error) {
+	var keys []string
+	for obj := range c.client.ListObjects(ctx, c.config.Bucket, minio.ListObjectsOptions{Prefix: c.buildS3Key(""), Recursive: true}) {
+		if obj.Err != nil {
+			return nil, fmt.Errorf("failed to list s3://%s/%s: %w", c.config.Bucket, c.buildS3Key(""), obj.Err)
+		}
+		keys = append(keys, obj.Key)
+	}
+	return keys, nil
+}
+
+// IncompleteUpload is a multipart upload that was started but never
+// completed or aborted.
+type IncompleteUpload struct {
+	Key       string
+	Initiated time.Time
+}
+
+// ListIncompleteUploads returns the unfinished multipart uploads under the
+// configured prefix.
+func (c *S3Client) ListIncompleteUploads(ctx context.Context) ([]IncompleteUpload, error) {
+	var uploads []IncompleteUpload
+	for upload := range c.client.ListIncompleteUploads(ctx, c.config.Bucket, c.buildS3Key(""), true) {
+		if upload.Err != nil {
+			return nil, fmt.Errorf("failed to list incomplete uploads in s3://%s: %w", c.config.Bucket, upload.Err)
+		}
+		uploads = append(uploads, IncompleteUpload{Key: upload.Key, Initiated: upload.Initiated})
+	}
+	return uploads, nil
+}
+
+// AbortIncompleteUpload aborts the unfinished multipart uploads of s3Key and
+// frees their stored parts.
+func (c *S3Client) AbortIncompleteUpload(ctx context.Context, s3Key string) error {
+	if err := c.client.RemoveIncompleteUpload(ctx, c.config.Bucket, s3Key); err != nil {
+		return fmt.Errorf("failed to abort upload of s3://%s/%s: %w", c.config.Bucket, s3Key, err)
+	}
+	return nil
+}
+
+// s3HoldTag marks held objects in buckets without Object Lock so lifecycle
+// rules and pruning can exclude them.
+const s3HoldTag = "infrahub-hold"
+
+// SetHold places or clears an Object Lock legal hold on an object. Buckets
+// created without Object Lock reject legal holds; the hold is then recorded as
+// an object tag instead.
+func (c *S3Client) SetHold(ctx context.Context, s3Key string, held bool) error {
+	status := minio.LegalHoldDisabled
+	if held {
+		status = minio.LegalHoldEnabled
+	}
+
+	lockErr := c.client.PutObjectLegalHold(ctx, c.config.Bucket, s3Key, minio.PutObjectLegalHoldOptions{Status: &status})
+	if lockErr == nil {
+		logrus.Debugf("Set Object Lock legal hold %s on s3://%s/%s", status, c.config.Bucket, s3Key)
+		return nil
+	}
+	logrus.Warnf("Object Lock legal hold unavailable for s3://%s/%s (%v); falling back to object tag", c.config.Bucket, s3Key, lockErr)
+
+	current, err := c.client.GetObjectTagging(ctx, c.config.Bucket, s3Key, minio.GetObjectTaggingOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to read tags for s3://%s/%s: %w", c.config.Bucket, s3Key, err)
+	}
+
+	tagMap := current.ToMap()
+	if held {
+		tagMap[s3HoldTag] = "true"
+	} else {
+		delete(tagMap, s3HoldTag)
+	}
+
+	if len(tagMap) == 0 {
+		if err := c.client.RemoveObjectTagging(ctx, c.config.Bucket, s3Key, minio.RemoveObjectTaggingOptions{}); err != nil {
+			return fmt.Errorf("failed to clear tags for s3://%s/%s: %w", c.config.Bucket, s3Key, err)
+		}
+		return nil
+	}
+
+	objectTags, err := tags.MapToObjectTags(tagMap)
+	if err != nil {
+		return fmt.Errorf("invalid object tags: %w", err)
+	}
+	if err := c.client.PutObjectTagging(ctx, c.config.Bucket, s3Key, objectTags, minio.PutObjectTaggingOptions{}); err != nil {
+		return fmt.Errorf("failed to tag s3://%s/%s: %w", c.config.Bucket, s3Key, err)
+	}
+	return nil
+}
+
+// PutImmutable writes data to key unless an object already exists there. With
+// a non-zero retainUntil, the object is written under an Object Lock
+// compliance retention, which buckets without Object Lock reject.
+func (c *S3Client) PutImmutable(ctx context.Context, key string, data []byte, retainUntil time.Time) (string, error) {
+	s3Key := c.buildS3Key(key)
+	s3URI := fmt.Sprintf("s3://%s/%s", c.config.Bucket, s3Key)
+	if _, err := c.client.StatObject(ctx, c.config.Bucket, s3Key, minio.StatObjectOptions{}); err == nil {
+		return "", fmt.Errorf("%s already exists", s3URI)
+	} else if minio.ToErrorResponse(err).Code != "NoSuchKey" {
+		return "", fmt.Errorf("failed to check %s: %w", s3URI, err)
+	}
+
+	opts := minio.PutObjectOptions{ContentType: "application/json", SendContentMd5: true}
+	if !retainUntil.IsZero() {
+		opts.Mode = minio.Compliance
+		opts.RetainUntilDate = retainUntil
+	}
+	if _, err := c.client.PutObject(ctx, c.config.Bucket, s3Key, bytes.NewReader(data), int64(len(data)), opts); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", s3URI, err)
+	}
+	return s3URI, nil
+}
+
 // ParseS3URI parses an s3://bucket/key URI into bucket and key components
 // If the URI doesn't have s3:// prefix, it returns empty strings and false
 func ParseS3URI(uri string) (bucket, key string, ok bool) {
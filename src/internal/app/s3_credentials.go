@@ -0,0 +1,141 @@
+package app
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// defaultRoleSessionName names the STS sessions of --aws-role-arn in
+// CloudTrail when --aws-role-session-name is not set.
+const defaultRoleSessionName = "infrahub-backup"
+
+// stsTimeout bounds each STS request.
+const stsTimeout = 30 * time.Second
+
+// AWSCredentialsConfig selects the credentials of the S3 client. Left empty,
+// the standard chain applies: environment variables, the default profile of
+// ~/.aws/credentials, then instance or pod metadata.
+type AWSCredentialsConfig struct {
+	Profile              string // profile of the shared credentials file
+	RoleARN              string // role assumed through STS with the base credentials
+	ExternalID           string // external ID the role's trust policy requires
+	RoleSessionName      string // defaults to infrahub-backup
+	WebIdentityTokenFile string // OIDC token exchanged for RoleARN instead of base credentials
+	STSEndpoint          string // defaults to the regional AWS endpoint, or the S3 endpoint when custom
+}
+
+// s3Credentials returns the credentials of the S3 client of cfg. With a role,
+// the base credentials are read once and exchanged for temporary credentials
+// of the role, which are renewed before they expire.
+func s3Credentials(cfg *S3Config) (*credentials.Credentials, error) {
+	creds := cfg.Credentials
+	base := []credentials.Provider{
+		&credentials.EnvAWS{},
+		&credentials.FileAWSCredentials{Profile: creds.Profile},
+		&credentials.IAM{},
+	}
+	if creds.Profile != "" {
+		// An explicit profile wins over the environment.
+		base[0], base[1] = base[1], base[0]
+	}
+	if creds.RoleARN == "" {
+		return credentials.NewChainCredentials(base), nil
+	}
+
+	client, err := cfg.HTTP.Client(stsTimeout)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := creds.stsEndpoint(cfg)
+	if creds.WebIdentityTokenFile != "" {
+		return credentials.New(&credentials.STSWebIdentity{
+			Client:      client,
+			STSEndpoint: endpoint,
+			RoleARN:     creds.RoleARN,
+			GetWebIDTokenExpiry: func() (*credentials.WebIdentityToken, error) {
+				token, err := os.ReadFile(creds.WebIdentityTokenFile)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read --aws-web-identity-token-file: %w", err)
+				}
+				return &credentials.WebIdentityToken{Token: strings.TrimSpace(string(token))}, nil
+			},
+		}), nil
+	}
+
+	value, err := credentials.NewChainCredentials(base).Get()
+	if err != nil {
+		return nil, fmt.Errorf("no base credentials to assume %s: %w", creds.RoleARN, err)
+	}
+	if value.AccessKeyID == "" || value.SecretAccessKey == "" {
+		return nil, fmt.Errorf("no base credentials to assume %s: set AWS_ACCESS_KEY_ID, --aws-profile or an instance role", creds.RoleARN)
+	}
+	sessionName := creds.RoleSessionName
+	if sessionName == "" {
+		sessionName = defaultRoleSessionName
+	}
+	return credentials.New(&credentials.STSAssumeRole{
+		Client:      client,
+		STSEndpoint: endpoint,
+		Options: credentials.STSAssumeRoleOptions{
+			AccessKey:       value.AccessKeyID,
+			SecretKey:       value.SecretAccessKey,
+			SessionToken:    value.SessionToken,
+			Location:        cfg.region(),
+			RoleARN:         creds.RoleARN,
+			RoleSessionName: sessionName,
+			ExternalID:      creds.ExternalID,
+		},
+	}), nil
+}
+
+// stsEndpoint returns --aws-sts-endpoint, the custom S3 endpoint, which is
+// where MinIO serves STS, or the AWS STS endpoint of the region.
+func (c AWSCredentialsConfig) stsEndpoint(cfg *S3Config) string {
+	switch {
+	case c.STSEndpoint != "":
+		return c.STSEndpoint
+	case cfg.Endpoint != "":
+		return cfg.Endpoint
+	case cfg.Region != "":
+		return "https://sts." + cfg.Region + ".amazonaws.com"
+	}
+	return "https://sts.amazonaws.com"
+}
+
+// checkCredentials validates the credential settings.
+func (c AWSCredentialsConfig) checkCredentials() []error {
+	var problems []error
+	if c.RoleARN != "" && !strings.HasPrefix(c.RoleARN, "arn:") {
+		problems = append(problems, fmt.Errorf("invalid --aws-role-arn %q: expected arn:aws:iam::<account>:role/<name>", c.RoleARN))
+	}
+	if c.RoleARN == "" {
+		for _, option := range []struct{ flag, value string }{
+			{"--aws-external-id", c.ExternalID},
+			{"--aws-role-session-name", c.RoleSessionName},
+			{"--aws-web-identity-token-file", c.WebIdentityTokenFile},
+		} {
+			if option.value != "" {
+				problems = append(problems, fmt.Errorf("%s requires --aws-role-arn", option.flag))
+			}
+		}
+	}
+	if c.WebIdentityTokenFile != "" {
+		if c.ExternalID != "" {
+			problems = append(problems, fmt.Errorf("--aws-external-id cannot be combined with --aws-web-identity-token-file"))
+		}
+		if _, err := os.Stat(c.WebIdentityTokenFile); err != nil {
+			problems = append(problems, fmt.Errorf("invalid --aws-web-identity-token-file: %w", err))
+		}
+	}
+	if c.STSEndpoint != "" {
+		if u, err := url.Parse(c.STSEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, fmt.Errorf("invalid --aws-sts-endpoint %q: expected an http:// or https:// URL", c.STSEndpoint))
+		}
+	}
+	return problems
+}
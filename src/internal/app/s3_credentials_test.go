@@ -0,0 +1,140 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSTS answers AssumeRole and AssumeRoleWithWebIdentity with fixed
+// credentials and records the form of the last request.
+func fakeSTS(t *testing.T) (*httptest.Server, *url.Values) {
+	t.Helper()
+	form := &url.Values{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("parse form: %v", err)
+		}
+		*form = r.PostForm
+		action := form.Get("Action")
+		expiration := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+		fmt.Fprintf(w, `<%[1]sResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><%[1]sResult><Credentials>`+
+			`<AccessKeyId>ASIAROLE</AccessKeyId><SecretAccessKey>role-secret</SecretAccessKey><SessionToken>role-token</SessionToken>`+
+			`<Expiration>%[2]s</Expiration></Credentials></%[1]sResult></%[1]sResponse>`, action, expiration)
+	}))
+	t.Cleanup(server.Close)
+	return server, form
+}
+
+func TestS3CredentialsAssumeRole(t *testing.T) {
+	server, form := fakeSTS(t)
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIABASE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "base-secret")
+
+	creds, err := s3Credentials(&S3Config{Credentials: AWSCredentialsConfig{
+		RoleARN:     "arn:aws:iam::123456789012:role/infrahub-backup",
+		ExternalID:  "backup-ext",
+		STSEndpoint: server.URL,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := creds.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value.AccessKeyID != "ASIAROLE" || value.SessionToken != "role-token" {
+		t.Errorf("credentials = %+v", value)
+	}
+	for key, want := range map[string]string{
+		"Action":          "AssumeRole",
+		"RoleArn":         "arn:aws:iam::123456789012:role/infrahub-backup",
+		"ExternalId":      "backup-ext",
+		"RoleSessionName": defaultRoleSessionName,
+	} {
+		if got := form.Get(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestS3CredentialsWebIdentity(t *testing.T) {
+	server, form := fakeSTS(t)
+	dir := t.TempDir()
+	writeTestFile(t, dir, "token", "eyJhbGciOi.token\n")
+
+	creds, err := s3Credentials(&S3Config{Credentials: AWSCredentialsConfig{
+		RoleARN:              "arn:aws:iam::123456789012:role/infrahub-backup",
+		WebIdentityTokenFile: filepath.Join(dir, "token"),
+		STSEndpoint:          server.URL,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := creds.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value.AccessKeyID != "ASIAROLE" {
+		t.Errorf("credentials = %+v", value)
+	}
+	if form.Get("Action") != "AssumeRoleWithWebIdentity" || form.Get("WebIdentityToken") != "eyJhbGciOi.token" {
+		t.Errorf("form = %v", *form)
+	}
+}
+
+func TestSTSEndpoint(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  S3Config
+		want string
+	}{
+		{name: "global", cfg: S3Config{}, want: "https://sts.amazonaws.com"},
+		{name: "regional", cfg: S3Config{Region: "eu-west-1"}, want: "https://sts.eu-west-1.amazonaws.com"},
+		{name: "custom S3 endpoint", cfg: S3Config{Endpoint: "https://minio.example.com:9000", Region: "eu-west-1"}, want: "https://minio.example.com:9000"},
+		{name: "explicit", cfg: S3Config{Region: "eu-west-1", Credentials: AWSCredentialsConfig{STSEndpoint: "https://sts.example.com"}}, want: "https://sts.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.Credentials.stsEndpoint(&tt.cfg); got != tt.want {
+				t.Errorf("stsEndpoint() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckCredentials(t *testing.T) {
+	role := "arn:aws:iam::123456789012:role/infrahub-backup"
+	tests := []struct {
+		name    string
+		cfg     AWSCredentialsConfig
+		wantErr string
+	}{
+		{name: "default chain", cfg: AWSCredentialsConfig{}},
+		{name: "profile", cfg: AWSCredentialsConfig{Profile: "backup"}},
+		{name: "role", cfg: AWSCredentialsConfig{RoleARN: role, ExternalID: "ext"}},
+		{name: "bad role", cfg: AWSCredentialsConfig{RoleARN: "infrahub-backup"}, wantErr: "invalid --aws-role-arn"},
+		{name: "external id without role", cfg: AWSCredentialsConfig{ExternalID: "ext"}, wantErr: "--aws-external-id requires --aws-role-arn"},
+		{name: "missing token file", cfg: AWSCredentialsConfig{RoleARN: role, WebIdentityTokenFile: "/nonexistent/token"}, wantErr: "invalid --aws-web-identity-token-file"},
+		{name: "bad sts endpoint", cfg: AWSCredentialsConfig{RoleARN: role, STSEndpoint: "sts.example.com"}, wantErr: "invalid --aws-sts-endpoint"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := tt.cfg.checkCredentials()
+			if tt.wantErr == "" {
+				if len(problems) != 0 {
+					t.Errorf("checkCredentials() = %v", problems)
+				}
+				return
+			}
+			if len(problems) != 1 || !strings.Contains(problems[0].Error(), tt.wantErr) {
+				t.Errorf("checkCredentials() = %v, want %q", problems, tt.wantErr)
+			}
+		})
+	}
+}
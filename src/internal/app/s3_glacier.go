@@ -0,0 +1,157 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/sirupsen/logrus"
+)
+
+// Defaults of --glacier-tier, --glacier-days and --glacier-timeout. Standard
+// retrievals take up to 5 hours from Glacier and 12 from Deep Archive.
+const (
+	defaultGlacierTier    = "standard"
+	defaultGlacierDays    = 1
+	defaultGlacierTimeout = 24 * time.Hour
+)
+
+// Intervals between checks of a running Glacier restore.
+const (
+	glacierInitialInterval = time.Minute
+	glacierMaxInterval     = 15 * time.Minute
+)
+
+// s3RequestTimeout bounds the S3 requests that start and check a restore.
+const s3RequestTimeout = 2 * time.Minute
+
+// glacierTiers maps --glacier-tier to the retrieval tier of the request.
+var glacierTiers = map[string]minio.TierType{
+	"expedited": minio.TierExpedited,
+	"standard":  minio.TierStandard,
+	"bulk":      minio.TierBulk,
+}
+
+// archivedStorageClasses cannot be read before a restore request.
+var archivedStorageClasses = map[string]bool{
+	"GLACIER":      true,
+	"DEEP_ARCHIVE": true,
+}
+
+// glacierState is whether an object can be downloaded.
+type glacierState int
+
+const (
+	glacierReadable  glacierState = iota // not archived, or a restored copy is available
+	glacierArchived                      // archived and no restore requested
+	glacierRestoring                     // a restore is in progress
+)
+
+// glacierState returns whether key can be downloaded, and its storage class.
+func (c *S3Client) glacierState(ctx context.Context, key string) (glacierState, string, error) {
+	info, err := c.client.StatObject(ctx, c.config.Bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return glacierReadable, "", fmt.Errorf("failed to read s3://%s/%s: %w", c.config.Bucket, key, err)
+	}
+	// StatObject leaves StorageClass empty; S3 sends it as a header, and
+	// omits it for STANDARD.
+	class := info.Metadata.Get("X-Amz-Storage-Class")
+	switch {
+	case !archivedStorageClasses[class]:
+		return glacierReadable, class, nil
+	case info.Restore == nil:
+		return glacierArchived, class, nil
+	case info.Restore.OngoingRestore:
+		return glacierRestoring, class, nil
+	}
+	return glacierReadable, class, nil
+}
+
+// requestRestore asks S3 to make a copy of the archived key readable for
+// days, retrieved with tier.
+func (c *S3Client) requestRestore(ctx context.Context, key string, tier minio.TierType, days int) error {
+	request := minio.RestoreRequest{}
+	request.SetDays(days)
+	request.SetGlacierJobParameters(minio.GlacierJobParameters{Tier: tier})
+	err := c.client.RestoreObject(ctx, c.config.Bucket, key, "", request)
+	if err == nil {
+		return nil
+	}
+	// S3 answers a new restore with 202 Accepted, which minio-go reports as an
+	// error without a code.
+	if response := minio.ToErrorResponse(err); response.StatusCode != http.StatusAccepted && response.Code != "RestoreAlreadyInProgress" {
+		return fmt.Errorf("failed to request the restore of s3://%s/%s from the archive tier: %w", c.config.Bucket, key, err)
+	}
+	return nil
+}
+
+// thawS3Objects makes the archived objects among keys readable: it requests
+// their restore with --glacier-tier, then waits until every restored copy is
+// available or --glacier-timeout passes. Objects in other storage classes are
+// left alone.
+func (iops *InfrahubOps) thawS3Objects(client *S3Client, keys []string) error {
+	cfg := iops.config
+	tier, ok := glacierTiers[strings.ToLower(cfg.GlacierTier)]
+	if !ok {
+		return fmt.Errorf("invalid --glacier-tier %q: expected expedited, standard or bulk", cfg.GlacierTier)
+	}
+	days := cfg.GlacierDays
+	if days <= 0 {
+		days = defaultGlacierDays
+	}
+	timeout := cfg.GlacierTimeout
+	if timeout <= 0 {
+		timeout = defaultGlacierTimeout
+	}
+
+	var pending []string
+	for _, key := range keys {
+		ctx, cancel := context.WithTimeout(context.Background(), s3RequestTimeout)
+		state, class, err := client.glacierState(ctx, key)
+		if err == nil && state == glacierArchived {
+			logrus.Infof("s3://%s/%s is in %s; requesting a %s restore for %d day(s)", client.config.Bucket, key, class, tier, days)
+			err = client.requestRestore(ctx, key, tier, days)
+		}
+		cancel()
+		if err != nil {
+			return err
+		}
+		if state != glacierReadable {
+			pending = append(pending, key)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	started := time.Now()
+	deadline := started.Add(timeout)
+	poll := iops.newPoller(fmt.Sprintf("%d object(s) to be restored from the archive tier", len(pending)), glacierInitialInterval, glacierMaxInterval)
+	defer poll.done()
+	for {
+		poll.status(fmt.Sprintf("%d of %d restored", len(keys)-len(pending), len(keys)))
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("s3://%s/%s is still being restored from the archive tier after the --glacier-timeout of %s; run the restore again later", client.config.Bucket, pending[0], timeout)
+		}
+		poll.wait(deadline)
+		var still []string
+		for _, key := range pending {
+			ctx, cancel := context.WithTimeout(context.Background(), s3RequestTimeout)
+			state, _, err := client.glacierState(ctx, key)
+			cancel()
+			if err != nil {
+				return err
+			}
+			if state != glacierReadable {
+				still = append(still, key)
+			}
+		}
+		if pending = still; len(pending) == 0 {
+			logrus.Infof("Restored from the archive tier after %s", time.Since(started).Round(time.Second))
+			return nil
+		}
+	}
+}
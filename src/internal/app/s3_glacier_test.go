@@ -0,0 +1,95 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeGlacierS3 serves HEAD requests for objects in storageClass. A restore
+// request starts a restore that completes after two more HEAD requests.
+type fakeGlacierS3 struct {
+	storageClass string
+	mu           sync.Mutex
+	restores     map[string]int // HEAD requests left until the restore completes
+	requests     []string
+}
+
+func (f *fakeGlacierS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := strings.TrimPrefix(r.URL.Path, "/backups/")
+	switch {
+	case r.Method == http.MethodPost && r.URL.Query().Has("restore"):
+		f.requests = append(f.requests, key)
+		f.restores[key] = 2
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodHead:
+		w.Header().Set("Content-Length", "4")
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.Header().Set("ETag", `"abcd"`)
+		w.Header().Set("x-amz-storage-class", f.storageClass)
+		if left, ok := f.restores[key]; ok {
+			if left > 0 {
+				f.restores[key] = left - 1
+				w.Header().Set("x-amz-restore", `ongoing-request="true"`)
+			} else {
+				w.Header().Set("x-amz-restore", `ongoing-request="false", expiry-date="`+time.Now().Add(24*time.Hour).UTC().Format(http.TimeFormat)+`"`)
+			}
+		}
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func TestThawS3Objects(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIATEST")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	pollSleep = func(time.Duration) {}
+	defer func() { pollSleep = time.Sleep }()
+
+	tests := []struct {
+		name         string
+		storageClass string
+		tier         string
+		timeout      time.Duration
+		wantRequests int
+		wantErr      string
+	}{
+		{name: "standard class", storageClass: "STANDARD", tier: "standard", wantRequests: 0},
+		{name: "instant retrieval", storageClass: "GLACIER_IR", tier: "standard", wantRequests: 0},
+		{name: "glacier", storageClass: "GLACIER", tier: "bulk", wantRequests: 2},
+		{name: "deep archive", storageClass: "DEEP_ARCHIVE", tier: "Standard", wantRequests: 2},
+		{name: "timeout", storageClass: "GLACIER", tier: "standard", timeout: time.Nanosecond, wantRequests: 2, wantErr: "--glacier-timeout"},
+		{name: "bad tier", storageClass: "GLACIER", tier: "fast", wantErr: "invalid --glacier-tier"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeGlacierS3{storageClass: tt.storageClass, restores: map[string]int{}}
+			server := httptest.NewServer(fake)
+			defer server.Close()
+
+			iops := newFakeDockerOps(newFakeExecutor())
+			iops.config.GlacierTier = tt.tier
+			iops.config.GlacierTimeout = tt.timeout
+			client, err := iops.s3Client(S3Config{Bucket: "backups", Endpoint: server.URL, Region: "us-east-1"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = iops.thawS3Objects(client, []string{"infrahub_backup_20260101_020000.tar.gz.001", "infrahub_backup_20260101_020000.tar.gz.002"})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("thawS3Objects() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("thawS3Objects() error = %v", err)
+			}
+			if len(fake.requests) != tt.wantRequests {
+				t.Errorf("restore requests = %v, want %d", fake.requests, tt.wantRequests)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package app
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/minio/minio-go/v7/pkg/tags"
+)
+
+// s3RetentionClassTag is the object tag carrying --retention-class, for
+// lifecycle rules that transition or expire backups of one class.
+const s3RetentionClassTag = "retention-class"
+
+// storageClassPattern matches S3 storage class names such as STANDARD_IA,
+// GLACIER_IR or DEEP_ARCHIVE, and those of S3-compatible providers.
+var storageClassPattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// s3StorageClass returns the storage class uploads of the retention class
+// use, from --s3-storage-class entries that are either a class, the default,
+// or class=<retention class>, e.g. "STANDARD_IA" and "monthly=GLACIER_IR".
+// Empty leaves the bucket default.
+func s3StorageClass(entries []string, retentionClass string) (string, error) {
+	var fallback, matched string
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		name, class, scoped := strings.Cut(entry, "=")
+		if !scoped {
+			name, class = "", entry
+		}
+		name, class = strings.TrimSpace(name), strings.ToUpper(strings.TrimSpace(class))
+		if !storageClassPattern.MatchString(class) || (scoped && name == "") {
+			return "", fmt.Errorf("invalid --s3-storage-class %q: expected a storage class such as STANDARD_IA, or <retention-class>=<storage-class>", entry)
+		}
+		switch {
+		case !scoped:
+			if fallback != "" {
+				return "", fmt.Errorf("--s3-storage-class sets a default twice: %s and %s", fallback, class)
+			}
+			fallback = class
+		case name == retentionClass:
+			matched = class
+		}
+	}
+	if matched != "" {
+		return matched, nil
+	}
+	return fallback, nil
+}
+
+// s3ObjectTags returns the tags uploads carry: the key=value pairs of
+// --s3-tag and, with --retention-class, the retention-class tag.
+func s3ObjectTags(entries []string, retentionClass string) (map[string]string, error) {
+	tagMap := map[string]string{}
+	for _, entry := range entries {
+		key, value, ok := strings.Cut(entry, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid --s3-tag %q: expected key=value", entry)
+		}
+		tagMap[key] = strings.TrimSpace(value)
+	}
+	if retentionClass != "" {
+		tagMap[s3RetentionClassTag] = retentionClass
+	}
+	if len(tagMap) == 0 {
+		return nil, nil
+	}
+	if _, err := tags.NewTags(tagMap, true); err != nil {
+		return nil, fmt.Errorf("invalid --s3-tag: %w", err)
+	}
+	return tagMap, nil
+}
+
+// checkS3Lifecycle validates the storage class and tag settings.
+func (cfg *Configuration) checkS3Lifecycle() []error {
+	var problems []error
+	if _, err := s3StorageClass(cfg.S3StorageClasses, cfg.RetentionClass); err != nil {
+		problems = append(problems, err)
+	}
+	if _, err := s3ObjectTags(cfg.S3Tags, cfg.RetentionClass); err != nil {
+		problems = append(problems, err)
+	}
+	if len(cfg.S3StorageClasses)+len(cfg.S3Tags) > 0 && cfg.Backend == BackendPlakar {
+		problems = append(problems, fmt.Errorf("--s3-storage-class and --s3-tag are not supported with the plakar backend"))
+	}
+	return problems
+}
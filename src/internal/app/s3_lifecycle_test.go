@@ -0,0 +1,72 @@
+package app
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestS3StorageClass(t *testing.T) {
+	entries := []string{"STANDARD_IA", "monthly=GLACIER_IR", "yearly=deep_archive"}
+	tests := []struct {
+		name           string
+		entries        []string
+		retentionClass string
+		want           string
+		wantErr        string
+	}{
+		{name: "unset", entries: nil, retentionClass: "monthly", want: ""},
+		{name: "default", entries: entries, retentionClass: "", want: "STANDARD_IA"},
+		{name: "unlisted class", entries: entries, retentionClass: "daily", want: "STANDARD_IA"},
+		{name: "monthly", entries: entries, retentionClass: "monthly", want: "GLACIER_IR"},
+		{name: "lower case", entries: entries, retentionClass: "yearly", want: "DEEP_ARCHIVE"},
+		{name: "class only", entries: []string{"monthly=GLACIER_IR"}, retentionClass: "daily", want: ""},
+		{name: "two defaults", entries: []string{"STANDARD_IA", "GLACIER_IR"}, wantErr: "sets a default twice"},
+		{name: "invalid", entries: []string{"monthly=glacier ir"}, wantErr: "invalid --s3-storage-class"},
+		{name: "no retention class", entries: []string{"=GLACIER_IR"}, wantErr: "invalid --s3-storage-class"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s3StorageClass(tt.entries, tt.retentionClass)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("s3StorageClass() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("s3StorageClass() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestS3ObjectTags(t *testing.T) {
+	tests := []struct {
+		name           string
+		entries        []string
+		retentionClass string
+		want           map[string]string
+		wantErr        string
+	}{
+		{name: "none", want: nil},
+		{name: "tags", entries: []string{"env=prod", "team = netops"}, want: map[string]string{"env": "prod", "team": "netops"}},
+		{name: "retention class", entries: []string{"env=prod"}, retentionClass: "monthly", want: map[string]string{"env": "prod", "retention-class": "monthly"}},
+		{name: "no value", entries: []string{"env"}, wantErr: "expected key=value"},
+		{name: "too many", entries: strings.Split("a=1,b=2,c=3,d=4,e=5,f=6,g=7,h=8,i=9,j=10,k=11", ","), wantErr: "invalid --s3-tag"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s3ObjectTags(tt.entries, tt.retentionClass)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("s3ObjectTags() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("s3ObjectTags() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+import asyncio
+import json
+import sys
+
+from prefect.client.orchestration import get_client
+
+# Usage: deployments.py list
+#        deployments.py pause [flow/deployment | deployment ...]
+#        deployments.py resume flow/deployment | deployment ...
+#
+# list prints every deployment as a JSON list of objects. pause without names
+# pauses every deployment that is not already paused. pause and resume print
+# the names they changed as a JSON list, so the caller only resumes what it
+# paused.
+
+
+async def read_deployments(client) -> list[dict]:
+    flows = {flow.id: flow.name for flow in await client.read_flows()}
+    deployments = []
+    for deployment in await client.read_deployments():
+        schedules = [
+            str(getattr(item.schedule, "cron", None) or getattr(item.schedule, "interval", None) or item.schedule)
+            for item in getattr(deployment, "schedules", None) or []
+            if getattr(item, "active", True)
+        ]
+        deployments.append(
+            {
+                "id": str(deployment.id),
+                "name": f"{flows.get(deployment.flow_id, '?')}/{deployment.name}",
+                "paused": bool(getattr(deployment, "paused", False)),
+                "schedules": schedules,
+            }
+        )
+    return sorted(deployments, key=lambda deployment: deployment["name"])
+
+
+async def set_paused(client, deployment: dict, paused: bool) -> bool:
+    if deployment["paused"] == paused:
+        return False
+    if hasattr(client, "pause_deployment"):
+        if paused:
+            await client.pause_deployment(deployment["id"])
+        else:
+            await client.resume_deployment(deployment["id"])
+    else:
+        await client.set_deployment_paused_state(deployment["id"], paused)
+    return True
+
+
+def select(deployments: list[dict], names: list[str]) -> list[dict]:
+    selected = []
+    for name in names:
+        matches = [
+            deployment
+            for deployment in deployments
+            if deployment["name"] == name or deployment["name"].split("/", 1)[1] == name
+        ]
+        if not matches:
+            sys.exit(f"deployment {name} not found")
+        if len(matches) > 1:
+            sys.exit(f"deployment name {name} is ambiguous; use flow/deployment")
+        selected.append(matches[0])
+    return selected
+
+
+async def main(action: str, names: list[str]):
+    async with get_client() as client:
+        deployments = await read_deployments(client)
+        if action == "list":
+            return deployments
+        selected = select(deployments, names) if names else deployments
+        changed = []
+        for deployment in selected:
+            if await set_paused(client, deployment, action == "pause"):
+                changed.append(deployment["name"])
+        return changed
+
+
+if len(sys.argv) < 2 or sys.argv[1] not in ("list", "pause", "resume"):
+    sys.exit("usage: deployments.py list|pause|resume [flow/deployment ...]")
+if sys.argv[1] == "resume" and len(sys.argv) < 3:
+    sys.exit("resume needs the deployments to resume")
+print(json.dumps(asyncio.run(main(sys.argv[1], sys.argv[2:]))))
@@ -0,0 +1,81 @@
+import asyncio
+import json
+import sys
+
+from prefect.client.orchestration import get_client
+from prefect.client.schemas.filters import (
+    FlowRunFilter,
+    FlowRunFilterState,
+    FlowRunFilterStateType,
+)
+from prefect.client.schemas.objects import StateType
+
+from infrahub_sdk import InfrahubClientSync
+
+
+def isoformat(value):
+    return value.isoformat() if hasattr(value, "isoformat") else value
+
+
+async def interrupted_flow_runs(limit: int):
+    """Flow runs that are running or about to run."""
+    async with get_client() as client:
+        flow_runs = await client.read_flow_runs(
+            flow_run_filter=FlowRunFilter(
+                state=FlowRunFilterState(
+                    type=FlowRunFilterStateType(
+                        any_=[StateType.RUNNING, StateType.PENDING]
+                    )
+                )
+            ),
+            limit=limit,
+        )
+
+    runs = []
+    for flow_run in flow_runs:
+        created_by = getattr(flow_run, "created_by", None)
+        runs.append(
+            {
+                "name": flow_run.name,
+                "state": flow_run.state_type.value if flow_run.state_type else "",
+                "owner": getattr(created_by, "display_value", None) or "",
+                "created_at": isoformat(flow_run.start_time or flow_run.created),
+            }
+        )
+    return runs
+
+
+def open_proposed_changes():
+    """Proposed changes still open, with their creator when known."""
+    client = InfrahubClientSync()
+    changes = []
+    for proposed_change in client.filters(
+        kind="CoreProposedChange",
+        state__value="open",
+        prefetch_relationships=True,
+        property=True,
+    ):
+        owner = ""
+        created_by = getattr(proposed_change, "created_by", None)
+        if created_by is not None and getattr(created_by, "peer", None) is not None:
+            owner = created_by.peer.display_label or ""
+        changes.append(
+            {
+                "name": proposed_change.name.value,
+                "state": "open",
+                "owner": owner,
+                "created_at": isoformat(proposed_change.name.updated_at),
+            }
+        )
+    return changes
+
+
+limit = int(sys.argv[1]) if len(sys.argv) > 1 else 200
+print(
+    json.dumps(
+        {
+            "flow_runs": asyncio.run(interrupted_flow_runs(limit)),
+            "proposed_changes": open_proposed_changes(),
+        }
+    )
+)
@@ -0,0 +1,13 @@
+// Fallback for the restore_metadata.cypher that neo4j-admin database restore
+// writes, used when the database container has none. It registers the
+// database and grants the built-in roles their usual privileges on it.
+// Run against the system database with the database parameter set.
+CREATE DATABASE $database IF NOT EXISTS;
+GRANT ACCESS ON DATABASE $database TO PUBLIC;
+GRANT MATCH {*} ON GRAPH $database TO reader;
+GRANT ACCESS ON DATABASE $database TO reader;
+GRANT WRITE ON GRAPH $database TO editor;
+GRANT NAME MANAGEMENT ON DATABASE $database TO publisher;
+GRANT INDEX MANAGEMENT ON DATABASE $database TO architect;
+GRANT CONSTRAINT MANAGEMENT ON DATABASE $database TO architect;
+GRANT ALL DATABASE PRIVILEGES ON DATABASE $database TO admin;
@@ -0,0 +1,49 @@
+import asyncio
+import json
+import sys
+
+from prefect.client.orchestration import get_client
+from prefect.client.schemas.actions import WorkPoolUpdate
+
+# Usage: work_pools.py pause [pool | pool/queue ...]
+#        work_pools.py resume pool | pool/queue ...
+#
+# pause without names pauses every work pool that is not already paused.
+# Both print the names they changed as a JSON list, so the caller only resumes
+# what it paused.
+
+
+async def set_paused(client, name: str, paused: bool) -> bool:
+    pool_name, _, queue_name = name.partition("/")
+    if queue_name:
+        queue = await client.read_work_queue_by_name(
+            name=queue_name, work_pool_name=pool_name
+        )
+        if queue.is_paused == paused:
+            return False
+        await client.update_work_queue(id=queue.id, is_paused=paused)
+        return True
+
+    pool = await client.read_work_pool(work_pool_name=pool_name)
+    if pool.is_paused == paused:
+        return False
+    await client.update_work_pool(
+        work_pool_name=pool_name, work_pool=WorkPoolUpdate(is_paused=paused)
+    )
+    return True
+
+
+async def main(action: str, names: list[str]) -> list[str]:
+    changed = []
+    async with get_client() as client:
+        if action == "pause" and not names:
+            names = [pool.name for pool in await client.read_work_pools()]
+        for name in names:
+            if await set_paused(client, name, action == "pause"):
+                changed.append(name)
+    return changed
+
+
+if len(sys.argv) < 2 or sys.argv[1] not in ("pause", "resume"):
+    sys.exit("usage: work_pools.py pause|resume [pool | pool/queue ...]")
+print(json.dumps(asyncio.run(main(sys.argv[1], sys.argv[2:]))))
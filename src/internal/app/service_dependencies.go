@@ -0,0 +1,312 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// dependsOnAnnotation lists, on a Kubernetes deployment or statefulset, the
+// services its workload needs running first, separated by commas.
+const dependsOnAnnotation = "infrahub-backup/depends-on"
+
+// Wait for a Docker service with a healthcheck to report healthy before the
+// services that need it healthy are started.
+const (
+	serviceHealthyTimeout  = 5 * time.Minute
+	healthyInitialInterval = 2 * time.Second
+	healthyMaxInterval     = 15 * time.Second
+)
+
+// serviceGraph holds the dependencies between services declared by the
+// deployment: compose depends_on, or the dependsOnAnnotation of Kubernetes
+// workloads. An empty graph means the deployment declares none, and the
+// services follow appServiceGroups.
+type serviceGraph struct {
+	// requires maps a service to the services it depends on, and whether it
+	// needs each of them healthy rather than only started.
+	requires map[string]map[string]bool
+}
+
+// depend records that service depends on dependency.
+func (g *serviceGraph) depend(service, dependency string, healthy bool) {
+	if g.requires == nil {
+		g.requires = map[string]map[string]bool{}
+	}
+	if g.requires[service] == nil {
+		g.requires[service] = map[string]bool{}
+	}
+	g.requires[service][dependency] = g.requires[service][dependency] || healthy
+}
+
+// declared reports whether the deployment declares any dependency.
+func (g *serviceGraph) declared() bool {
+	return g != nil && len(g.requires) > 0
+}
+
+// reaches reports whether service depends on target, directly or through
+// other services.
+func (g *serviceGraph) reaches(service, target string) bool {
+	seen := map[string]bool{}
+	pending := []string{service}
+	for len(pending) > 0 {
+		current := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		for dependency := range g.requires[current] {
+			if dependency == target {
+				return true
+			}
+			if !seen[dependency] {
+				seen[dependency] = true
+				pending = append(pending, dependency)
+			}
+		}
+	}
+	return false
+}
+
+// dependents returns the services outside services that depend on any of
+// them, directly or through other services, in name order. The data services
+// are never among them, so they are never stopped with the application.
+func (g *serviceGraph) dependents(services []string) []string {
+	if !g.declared() {
+		return nil
+	}
+	var found []string
+	for service := range g.requires {
+		if slices.Contains(services, service) || slices.Contains(bootstrapDataServices, service) {
+			continue
+		}
+		for _, target := range services {
+			if g.reaches(service, target) {
+				found = append(found, service)
+				break
+			}
+		}
+	}
+	sort.Strings(found)
+	return found
+}
+
+// layers arranges services into start-order groups: each service comes after
+// every listed service it depends on, directly or through unlisted services.
+// It fails when the dependencies form a cycle.
+func (g *serviceGraph) layers(services []string) ([][]string, error) {
+	services = unique(services)
+	for i, service := range services {
+		for _, other := range services[i+1:] {
+			if g.reaches(service, other) && g.reaches(other, service) {
+				return nil, fmt.Errorf("%s and %s depend on each other", service, other)
+			}
+		}
+	}
+
+	level := map[string]int{}
+	var visit func(service string) int
+	visit = func(service string) int {
+		if l, ok := level[service]; ok {
+			return l
+		}
+		l := 0
+		for _, other := range services {
+			if other != service && g.reaches(service, other) {
+				l = max(l, visit(other)+1)
+			}
+		}
+		level[service] = l
+		return l
+	}
+
+	groups := [][]string{}
+	for _, service := range services {
+		l := visit(service)
+		for len(groups) <= l {
+			groups = append(groups, []string{})
+		}
+		groups[l] = append(groups[l], service)
+	}
+	for _, group := range groups {
+		sort.Strings(group)
+	}
+	return groups, nil
+}
+
+// needsHealthy returns the services of earlier that a service of later needs
+// healthy before it starts.
+func (g *serviceGraph) needsHealthy(earlier, later []string) []string {
+	var needed []string
+	for _, service := range earlier {
+		for _, dependent := range later {
+			if g.requires[dependent][service] {
+				needed = append(needed, service)
+				break
+			}
+		}
+	}
+	return needed
+}
+
+// serviceDependencies returns the dependencies the deployment declares
+// between its services. They are read on first use; a deployment whose
+// definitions cannot be read gets an empty graph.
+func (iops *InfrahubOps) serviceDependencies() *serviceGraph {
+	if iops.dependencies != nil {
+		return iops.dependencies
+	}
+	graph := &serviceGraph{}
+	backend, err := iops.ensureBackend()
+	if err == nil {
+		switch b := backend.(type) {
+		case *DockerBackend:
+			graph, err = iops.composeDependencies(b)
+		case *KubernetesBackend:
+			graph, err = b.workloadDependencies()
+		}
+	}
+	if err != nil {
+		logrus.Debugf("Could not read the service dependencies, using the default order: %v", err)
+		graph = &serviceGraph{}
+	}
+	iops.dependencies = graph
+	return graph
+}
+
+// composeDependencies reads the depends_on entries of the resolved compose
+// configuration of the project.
+func (iops *InfrahubOps) composeDependencies(docker *DockerBackend) (*serviceGraph, error) {
+	args, err := docker.composeConfigArgs("config", "--format", "json")
+	if err != nil {
+		return nil, err
+	}
+	output, err := iops.readCommandOutput("docker", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the compose configuration: %w", err)
+	}
+	return parseComposeDependencies(output)
+}
+
+// parseComposeDependencies reads the depends_on entries of `docker compose
+// config --format json`. Compose writes them as a map of conditions, and
+// the short list form is accepted as well. A service_healthy condition
+// requires the dependency to be healthy.
+func parseComposeDependencies(output []byte) (*serviceGraph, error) {
+	var config struct {
+		Services map[string]struct {
+			DependsOn json.RawMessage `json:"depends_on"`
+		} `json:"services"`
+	}
+	if err := json.Unmarshal(output, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse the compose configuration: %w", err)
+	}
+	graph := &serviceGraph{}
+	for service, definition := range config.Services {
+		if len(definition.DependsOn) == 0 {
+			continue
+		}
+		var names []string
+		if err := json.Unmarshal(definition.DependsOn, &names); err == nil {
+			for _, name := range names {
+				graph.depend(service, name, false)
+			}
+			continue
+		}
+		var conditions map[string]struct {
+			Condition string `json:"condition"`
+		}
+		if err := json.Unmarshal(definition.DependsOn, &conditions); err != nil {
+			return nil, fmt.Errorf("failed to parse depends_on of %s: %w", service, err)
+		}
+		for name, dependency := range conditions {
+			graph.depend(service, name, dependency.Condition == "service_healthy")
+		}
+	}
+	return graph, nil
+}
+
+// workloadDependencies reads the dependsOnAnnotation of the deployments and
+// statefulsets of the namespace. Workloads of the known Infrahub services are
+// named after their service; other workloads keep their own name.
+func (k *KubernetesBackend) workloadDependencies() (*serviceGraph, error) {
+	services := map[string]string{}
+	for _, service := range unique(append(slices.Clone(appServices), bootstrapDataServices...)) {
+		if _, name, err := k.findWorkloadResource(service); err == nil {
+			services[name] = service
+		}
+	}
+	graph := &serviceGraph{}
+	for _, kind := range []string{"deployment", "statefulset"} {
+		workloads, err := k.listWorkloads(k.namespace, kind)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %ss: %w", kind, err)
+		}
+		for _, workload := range workloads {
+			service := workload.Name
+			if known, ok := services[workload.Name]; ok {
+				service = known
+			}
+			for _, name := range strings.Split(workload.Annotations[dependsOnAnnotation], ",") {
+				if name = strings.TrimSpace(name); name != "" {
+					// Start waits for the pods of each service to be ready.
+					graph.depend(service, name, true)
+				}
+			}
+		}
+	}
+	return graph, nil
+}
+
+// waitUntilHealthy waits until the Docker services report healthy. Services
+// without a healthcheck count as healthy, and a service still unhealthy after
+// serviceHealthyTimeout is logged and left to its dependents. Kubernetes
+// services are ready once Start returns.
+func (iops *InfrahubOps) waitUntilHealthy(services []string) {
+	if len(services) == 0 {
+		return
+	}
+	docker, ok := iops.backend.(*DockerBackend)
+	if !ok {
+		return
+	}
+	deadline := time.Now().Add(serviceHealthyTimeout)
+	var poll *poller
+	defer func() {
+		if poll != nil {
+			poll.done()
+		}
+	}()
+	for {
+		output, err := docker.executor.runCommand("docker", docker.composeArgs("ps", "-a", "--format", "json")...)
+		if err != nil {
+			logrus.Warnf("Could not check the health of %s: %v", strings.Join(services, ", "), err)
+			return
+		}
+		entries, err := parseComposePSEntries(output)
+		if err != nil {
+			logrus.Warnf("Could not check the health of %s: %v", strings.Join(services, ", "), err)
+			return
+		}
+		var waiting []string
+		for _, entry := range entries {
+			if slices.Contains(services, entry.Service) && entry.Health != "" && entry.Health != "healthy" && !slices.Contains(waiting, entry.Service) {
+				waiting = append(waiting, entry.Service)
+			}
+		}
+		if len(waiting) == 0 {
+			return
+		}
+		if !time.Now().Before(deadline) {
+			logrus.Warnf("%s not healthy after %s; starting the services that depend on it anyway", strings.Join(waiting, ", "), serviceHealthyTimeout)
+			return
+		}
+		if poll == nil {
+			poll = iops.newPoller(strings.Join(services, ", ")+" to be healthy", healthyInitialInterval, healthyMaxInterval)
+		}
+		poll.status("waiting for " + strings.Join(waiting, ", "))
+		poll.wait(deadline)
+	}
+}
@@ -0,0 +1,160 @@
+package app
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseComposeDependencies(t *testing.T) {
+	graph, err := parseComposeDependencies([]byte(`{"services":{
+"infrahub-server":{"depends_on":{"database":{"condition":"service_healthy","required":true},"cache":{"condition":"service_started"}}},
+"auth-proxy":{"depends_on":["infrahub-server"]},
+"database":{}
+}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]map[string]bool{
+		"infrahub-server": {"database": true, "cache": false},
+		"auth-proxy":      {"infrahub-server": false},
+	}
+	if !reflect.DeepEqual(graph.requires, want) {
+		t.Errorf("requires = %v, want %v", graph.requires, want)
+	}
+
+	if _, err := parseComposeDependencies([]byte(`{"services":{"x":{"depends_on":42}}}`)); err == nil {
+		t.Error("parseComposeDependencies() with an invalid depends_on succeeded")
+	}
+}
+
+func TestOrderServiceGroupsWithDependencies(t *testing.T) {
+	graph := &serviceGraph{}
+	graph.depend("task-manager", "cache", true)
+	graph.depend("infrahub-server", "database", true)
+	graph.depend("database", "message-queue", false)
+	graph.depend("task-worker", "infrahub-server", true)
+	graph.depend("auth-proxy", "infrahub-server", false)
+
+	cyclic := &serviceGraph{}
+	cyclic.depend("infrahub-server", "task-worker", false)
+	cyclic.depend("task-worker", "infrahub-server", false)
+
+	tests := []struct {
+		name     string
+		graph    *serviceGraph
+		services []string
+		want     [][]string
+	}{
+		{
+			name:     "dependencies through unlisted services",
+			graph:    graph,
+			services: []string{"task-worker", "infrahub-server", "cache", "message-queue", "task-manager", "auth-proxy"},
+			want:     [][]string{{"cache", "message-queue"}, {"infrahub-server", "task-manager"}, {"auth-proxy", "task-worker"}},
+		},
+		{
+			name:     "independent services start together",
+			graph:    graph,
+			services: []string{"auth-proxy", "cache"},
+			want:     [][]string{{"auth-proxy", "cache"}},
+		},
+		{
+			name:     "cycle falls back to the default order",
+			graph:    cyclic,
+			services: []string{"task-worker", "infrahub-server", "cache"},
+			want:     [][]string{{"cache"}, {"infrahub-server", "task-worker"}},
+		},
+		{
+			name:     "no declared dependencies",
+			graph:    &serviceGraph{},
+			services: []string{"task-worker", "cache"},
+			want:     [][]string{{"cache"}, {"task-worker"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := orderServiceGroups(tt.services, tt.graph); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("orderServiceGroups(%v) = %v, want %v", tt.services, got, tt.want)
+			}
+		})
+	}
+
+	if got, want := graph.dependents(appServices), []string{"auth-proxy"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dependents() = %v, want %v", got, want)
+	}
+}
+
+func TestStopAndRestartWithComposeDependencies(t *testing.T) {
+	fake := newFakeExecutor().
+		on("label=com.docker.compose.project=test", "/srv/infrahub|/srv/infrahub/docker-compose.yml\n", nil).
+		on("config --format json", `{"services":{
+"infrahub-server":{"depends_on":{"cache":{"condition":"service_healthy"}}},
+"task-worker":{"depends_on":{"infrahub-server":{"condition":"service_healthy"}}},
+"auth-proxy":{"depends_on":{"infrahub-server":{"condition":"service_healthy"}}}
+}}`, nil).
+		on("ps -a --format json", `[
+{"Service":"infrahub-server","State":"running","Health":"healthy"},
+{"Service":"task-worker","State":"running"},
+{"Service":"cache","State":"running"},
+{"Service":"auth-proxy","State":"running"}
+]`, nil)
+	iops := newFakeDockerOps(fake)
+
+	stopped, err := iops.stopAppContainers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"docker compose -p test stop auth-proxy",
+		"docker compose -p test stop task-worker",
+		"docker compose -p test stop infrahub-server",
+		"docker compose -p test stop cache",
+	}
+	// Services of one group stop concurrently.
+	got := fake.commands(" stop ")
+	if len(got) == len(want) {
+		slices.Sort(got[:2])
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("stop commands = %v, want %v", got, want)
+	}
+
+	if err := iops.restartAppServices(stopped); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{
+		"docker compose -p test start infrahub-server",
+		"docker compose -p test start auth-proxy",
+		"docker compose -p test start task-worker",
+	}
+	got = fake.commands(" start ")
+	if len(got) == len(want) {
+		slices.Sort(got[1:])
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("start commands = %v, want %v", got, want)
+	}
+	if got := fake.commands("config --format json"); len(got) != 1 {
+		t.Errorf("compose configuration read %d times, want once", len(got))
+	}
+}
+
+func TestWaitUntilHealthy(t *testing.T) {
+	fake := newFakeExecutor().on("ps -a --format json", `[{"Service":"infrahub-server","State":"running","Health":"starting"},{"Service":"cache","State":"running"}]`, nil)
+	iops := newFakeDockerOps(fake)
+	checks := 0
+	pollSleep = func(time.Duration) {
+		checks++
+		fake.mu.Lock()
+		fake.responses[0].output = strings.Replace(fake.responses[0].output, "starting", "healthy", 1)
+		fake.mu.Unlock()
+	}
+	defer func() { pollSleep = time.Sleep }()
+
+	iops.waitUntilHealthy([]string{"infrahub-server", "cache"})
+	if checks != 1 {
+		t.Errorf("waited %d times, want once", checks)
+	}
+}
@@ -0,0 +1,104 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Ways of stopping a service, chosen with --stop-strategy.
+const (
+	StopStrategyStop   = "stop"   // docker compose stop
+	StopStrategyDown   = "down"   // docker compose down: containers are removed, volumes kept
+	StopStrategyScale  = "scale"  // Kubernetes: scale the workload to zero replicas
+	StopStrategyDelete = "delete" // Kubernetes: delete the pods and let the controller recreate them
+)
+
+// stopStrategyAll is the StopStrategies key for services without their own
+// entry.
+const stopStrategyAll = "*"
+
+// stopStrategies lists the strategies each backend supports; the first one is
+// its default.
+var stopStrategies = map[string][]string{
+	"docker":     {StopStrategyStop, StopStrategyDown},
+	"kubernetes": {StopStrategyScale, StopStrategyDelete},
+}
+
+// parseStopStrategies parses --stop-strategy entries: a strategy for every
+// service, or service=strategy for one.
+func parseStopStrategies(entries []string) (map[string]string, error) {
+	strategies := map[string]string{}
+	for _, entry := range entries {
+		service, strategy, ok := strings.Cut(entry, "=")
+		if !ok {
+			service, strategy = stopStrategyAll, entry
+		}
+		if service == "" || !isStopStrategy(strategy) {
+			return nil, fmt.Errorf("invalid --stop-strategy %q: expected [service=]stop|down|scale|delete", entry)
+		}
+		strategies[service] = strategy
+	}
+	return strategies, nil
+}
+
+func isStopStrategy(strategy string) bool {
+	for _, supported := range stopStrategies {
+		for _, name := range supported {
+			if name == strategy {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// stopStrategy returns how backend stops service: its own entry, else the
+// one for every service, else the backend default.
+func (cfg *Configuration) stopStrategy(backend, service string) (string, error) {
+	supported := stopStrategies[backend]
+	if cfg == nil {
+		return supported[0], nil
+	}
+	strategy, ok := cfg.StopStrategies[service]
+	if !ok {
+		strategy, ok = cfg.StopStrategies[stopStrategyAll]
+	}
+	if !ok {
+		return supported[0], nil
+	}
+	for _, name := range supported {
+		if name == strategy {
+			return strategy, nil
+		}
+	}
+	return "", fmt.Errorf("stop strategy %q of %s is not supported by the %s backend (use %s)", strategy, service, backend, strings.Join(supported, " or "))
+}
+
+// groupByStopStrategy splits services by the strategy backend uses for each,
+// keeping their order within each group.
+func (cfg *Configuration) groupByStopStrategy(backend string, services []string) (map[string][]string, error) {
+	groups := map[string][]string{}
+	for _, service := range services {
+		strategy, err := cfg.stopStrategy(backend, service)
+		if err != nil {
+			return nil, err
+		}
+		groups[strategy] = append(groups[strategy], service)
+	}
+	return groups, nil
+}
+
+// formatStopStrategies renders StopStrategies as --stop-strategy entries.
+func formatStopStrategies(strategies map[string]string) string {
+	var entries []string
+	for service, strategy := range strategies {
+		if service == stopStrategyAll {
+			entries = append(entries, strategy)
+			continue
+		}
+		entries = append(entries, service+"="+strategy)
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ",")
+}
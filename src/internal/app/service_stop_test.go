@@ -0,0 +1,86 @@
+package app
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseStopStrategies(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "every service", entries: []string{"down"}, want: map[string]string{"*": "down"}},
+		{name: "per service", entries: []string{"scale", "task-worker=delete"}, want: map[string]string{"*": "scale", "task-worker": "delete"}},
+		{name: "unknown strategy", entries: []string{"kill"}, wantErr: true},
+		{name: "missing service", entries: []string{"=down"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseStopStrategies(tt.entries)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseStopStrategies() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseStopStrategies() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStopStrategyBackendSupport(t *testing.T) {
+	cfg := &Configuration{StopStrategies: map[string]string{"task-worker": "delete"}}
+	if strategy, err := cfg.stopStrategy("kubernetes", "task-worker"); err != nil || strategy != StopStrategyDelete {
+		t.Errorf("stopStrategy(kubernetes) = %q, %v", strategy, err)
+	}
+	if strategy, err := cfg.stopStrategy("kubernetes", "infrahub-server"); err != nil || strategy != StopStrategyScale {
+		t.Errorf("stopStrategy(kubernetes) default = %q, %v", strategy, err)
+	}
+	if _, err := cfg.stopStrategy("docker", "task-worker"); err == nil || !strings.Contains(err.Error(), "not supported by the docker backend") {
+		t.Errorf("stopStrategy(docker) error = %v", err)
+	}
+}
+
+func TestDockerStopStrategies(t *testing.T) {
+	fake := newFakeExecutor()
+	iops := newFakeDockerOps(fake)
+	iops.config.StopStrategies = map[string]string{"*": "down", "task-worker": "stop"}
+
+	if err := iops.StopServices("infrahub-server", "task-worker"); err != nil {
+		t.Fatalf("StopServices() error = %v", err)
+	}
+	if err := iops.StartServices("infrahub-server", "task-worker"); err != nil {
+		t.Fatalf("StartServices() error = %v", err)
+	}
+	want := []string{
+		"docker compose -p test stop task-worker",
+		"docker compose -p test down infrahub-server",
+		"docker compose -p test start task-worker",
+		"docker compose -p test up -d --no-deps infrahub-server",
+	}
+	if !reflect.DeepEqual(fake.calls, want) {
+		t.Errorf("commands = %v, want %v", fake.calls, want)
+	}
+}
+
+func TestKubernetesDeleteStrategy(t *testing.T) {
+	fake := newFakeExecutor().
+		on("get pods -n infrahub -l", "task-worker-7d9-abc\ntask-worker-7d9-def\n", nil)
+	config := &Configuration{K8sNamespace: "infrahub", StopStrategies: map[string]string{"task-worker": "delete"}}
+	k := NewKubernetesBackend(config, fake)
+	k.namespace = "infrahub"
+
+	if err := k.Stop("task-worker"); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	want := []string{"kubectl delete pod -n infrahub --wait=false task-worker-7d9-abc task-worker-7d9-def"}
+	if got := fake.commands("delete pod"); !reflect.DeepEqual(got, want) {
+		t.Errorf("delete commands = %v, want %v", got, want)
+	}
+	if got := fake.commands(" scale "); len(got) != 0 {
+		t.Errorf("scaled with the delete strategy: %v", got)
+	}
+}
@@ -0,0 +1,106 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Service level objectives a run is evaluated against.
+const (
+	SLORPO = "rpo" // age of the data a backup captures or a restore brings back
+	SLORTO = "rto" // duration of a restore
+)
+
+// SLOResult is the evaluation of one objective for a run.
+type SLOResult struct {
+	Objective string        // rpo or rto
+	Target    time.Duration // --rpo or --rto
+	Actual    time.Duration
+	Breached  bool
+}
+
+// String describes the result, e.g. "RTO 45m0s exceeds 30m0s".
+func (r SLOResult) String() string {
+	verb := "within"
+	if r.Breached {
+		verb = "exceeds"
+	}
+	return fmt.Sprintf("%s %s %s %s", strings.ToUpper(r.Objective), r.Actual.Round(time.Second), verb, r.Target)
+}
+
+// SLOBreaches returns the objectives the run missed.
+func (r *RunReport) SLOBreaches() []SLOResult {
+	var breaches []SLOResult
+	for _, result := range r.SLO {
+		if result.Breached {
+			breaches = append(breaches, result)
+		}
+	}
+	return breaches
+}
+
+// evaluateSLO compares report with --rpo and --rto, records the results in
+// it and logs a warning for every breach.
+//
+// For a backup, the RPO is measured as the time since the previous successful
+// backup of the same target in the run history: the data a failure right
+// before this backup would have lost. A failed backup is measured up to now.
+// For a restore, the RPO is the age of the restored data when the restore
+// started, and the RTO the duration of the restore.
+func (iops *InfrahubOps) evaluateSLO(report *RunReport) {
+	cfg := iops.config
+	evaluate := func(objective string, target, actual time.Duration) {
+		result := SLOResult{Objective: objective, Target: target, Actual: actual, Breached: actual > target}
+		report.SLO = append(report.SLO, result)
+		if result.Breached {
+			logrus.Warnf("SLO breach: %s", result)
+		}
+	}
+
+	switch report.Operation {
+	case "backup":
+		if cfg.RPO <= 0 {
+			return
+		}
+		previous, ok := iops.lastSuccessfulBackup()
+		if !ok {
+			return
+		}
+		end := report.StartedAt
+		if !report.Succeeded() {
+			end = report.StartedAt.Add(report.Duration)
+		}
+		evaluate(SLORPO, cfg.RPO, end.Sub(previous))
+	case "restore":
+		if cfg.RPO > 0 && !report.DataCapturedAt.IsZero() {
+			evaluate(SLORPO, cfg.RPO, report.StartedAt.Sub(report.DataCapturedAt))
+		}
+		if cfg.RTO > 0 {
+			evaluate(SLORTO, cfg.RTO, report.Duration)
+		}
+	}
+}
+
+// lastSuccessfulBackup returns when the latest successful backup of the
+// current target in the run history started.
+func (iops *InfrahubOps) lastSuccessfulBackup() (time.Time, bool) {
+	history, err := loadRunHistory(iops.config.BackupDir, time.Time{})
+	if err != nil {
+		logrus.Warnf("Cannot evaluate the RPO: %v", err)
+		return time.Time{}, false
+	}
+	target := ""
+	if iops.backend != nil {
+		target = iops.backend.Info()
+	}
+	var last time.Time
+	for _, entry := range history {
+		if entry.Operation == "backup" && entry.Status != "failure" && entry.Target == target && entry.Time.After(last) {
+			last = entry.Time
+		}
+	}
+	return last, !last.IsZero()
+}
@@ -0,0 +1,107 @@
+package app
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEvaluateSLO(t *testing.T) {
+	started := time.Date(2026, 1, 2, 2, 0, 0, 0, time.UTC)
+	history := `{"time":"2026-01-01T02:00:00Z","operation":"backup","status":"success","target":"test"}
+{"time":"2026-01-01T14:00:00Z","operation":"backup","status":"failure","target":"test"}
+{"time":"2026-01-01T20:00:00Z","operation":"backup","status":"success","target":"other"}
+`
+	tests := []struct {
+		name    string
+		rpo     time.Duration
+		rto     time.Duration
+		history string
+		report  RunReport
+		want    []string
+	}{
+		{
+			name:    "backup within rpo",
+			rpo:     25 * time.Hour,
+			history: history,
+			report:  RunReport{Operation: "backup", StartedAt: started, Duration: time.Hour},
+			want:    []string{"RPO 24h0m0s within 25h0m0s"},
+		},
+		{
+			name:    "backup after rpo",
+			rpo:     12 * time.Hour,
+			history: history,
+			report:  RunReport{Operation: "backup", StartedAt: started, Duration: time.Hour},
+			want:    []string{"RPO 24h0m0s exceeds 12h0m0s"},
+		},
+		{
+			name:    "failed backup counts until it ends",
+			rpo:     24 * time.Hour,
+			history: history,
+			report:  RunReport{Operation: "backup", StartedAt: started, Duration: time.Hour, Err: errors.New("boom")},
+			want:    []string{"RPO 25h0m0s exceeds 24h0m0s"},
+		},
+		{
+			name:   "first backup",
+			rpo:    time.Hour,
+			report: RunReport{Operation: "backup", StartedAt: started},
+		},
+		{
+			name:   "restore",
+			rpo:    12 * time.Hour,
+			rto:    30 * time.Minute,
+			report: RunReport{Operation: "restore", StartedAt: started, Duration: 45 * time.Minute, DataCapturedAt: started.Add(-6 * time.Hour)},
+			want:   []string{"RPO 6h0m0s within 12h0m0s", "RTO 45m0s exceeds 30m0s"},
+		},
+		{
+			name:   "restore of unknown age",
+			rpo:    12 * time.Hour,
+			report: RunReport{Operation: "restore", StartedAt: started, Duration: time.Minute},
+		},
+		{
+			name:   "disabled",
+			report: RunReport{Operation: "restore", StartedAt: started, Duration: 45 * time.Minute, DataCapturedAt: started.Add(-6 * time.Hour)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iops := newFakeDockerOps(newFakeExecutor())
+			iops.config.BackupDir = t.TempDir()
+			iops.config.RPO, iops.config.RTO = tt.rpo, tt.rto
+			if tt.history != "" {
+				writeTestFile(t, iops.config.BackupDir, runHistoryFilename, tt.history)
+			}
+			report := tt.report
+			iops.evaluateSLO(&report)
+			var got []string
+			for _, result := range report.SLO {
+				got = append(got, result.String())
+			}
+			if strings.Join(got, "; ") != strings.Join(tt.want, "; ") {
+				t.Errorf("evaluateSLO() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunWithReportFlagsSLOBreach(t *testing.T) {
+	iops := newFakeDockerOps(newFakeExecutor())
+	iops.config.BackupDir = t.TempDir()
+	iops.config.RTO = time.Nanosecond
+
+	err := iops.RunWithReport("restore", func() error {
+		time.Sleep(time.Millisecond)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !iops.sloBreached {
+		t.Error("sloBreached = false after a restore slower than --rto")
+	}
+	entries, err := loadRunHistory(iops.config.BackupDir, time.Time{})
+	if err != nil || len(entries) != 1 || len(entries[0].SLOBreaches) != 1 || !strings.HasPrefix(entries[0].SLOBreaches[0], "RTO ") {
+		t.Errorf("run history = %+v (%v)", entries, err)
+	}
+}
@@ -0,0 +1,287 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// TargetGroupAll selects every configured target.
+const TargetGroupAll = "all"
+
+// ConfiguredTarget is one deployment defined under the targets key of the
+// --config file. Its settings override the shared ones: the other keys of the
+// file, INFRAHUB_* variables and flags.
+type ConfiguredTarget struct {
+	Name        string
+	Groups      []string
+	KubeContext string       // kubeconfig context of the target's cluster; empty uses the current one
+	settings    *viper.Viper // overrides, keyed by flag name
+}
+
+// ConfiguredTargets returns the targets of the --config file in name order.
+func (iops *InfrahubOps) ConfiguredTargets() ([]ConfiguredTarget, error) {
+	raw := iops.settings.Get("targets")
+	if raw == nil {
+		return nil, nil
+	}
+	entries, ok := raw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("invalid targets in %s: expected target names mapped to settings", iops.settings.ConfigFileUsed())
+	}
+	targets := make([]ConfiguredTarget, 0, len(entries))
+	for name, value := range entries {
+		target, err := parseConfiguredTarget(name, value)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Name < targets[j].Name })
+	return targets, nil
+}
+
+func parseConfiguredTarget(name string, value any) (ConfiguredTarget, error) {
+	overrides, ok := value.(map[string]any)
+	if !ok {
+		return ConfiguredTarget{}, fmt.Errorf("invalid target %s: expected a map of settings", name)
+	}
+	settings := viper.New()
+	if err := settings.MergeConfigMap(overrides); err != nil {
+		return ConfiguredTarget{}, fmt.Errorf("invalid target %s: %w", name, err)
+	}
+	for _, key := range []string{"config", "targets", "no-pin"} {
+		if settings.IsSet(key) {
+			return ConfiguredTarget{}, fmt.Errorf("invalid target %s: %s cannot be set per target", name, key)
+		}
+	}
+	if settings.IsSet("project") == settings.IsSet("k8s-namespace") {
+		return ConfiguredTarget{}, fmt.Errorf("invalid target %s: set either project or k8s-namespace", name)
+	}
+	if name == TargetGroupAll {
+		return ConfiguredTarget{}, fmt.Errorf("invalid target name %s: reserved for --target-group", name)
+	}
+	return ConfiguredTarget{
+		Name:        name,
+		Groups:      settings.GetStringSlice("groups"),
+		KubeContext: settings.GetString("kube-context"),
+		settings:    settings,
+	}, nil
+}
+
+// TargetGroup returns the configured targets in group, or every target for
+// the group all.
+func (iops *InfrahubOps) TargetGroup(group string) ([]ConfiguredTarget, error) {
+	targets, err := iops.ConfiguredTargets()
+	if err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("--target-group %s: no targets defined; add a targets key to the --config file", group)
+	}
+	var members []ConfiguredTarget
+	groups := map[string]bool{}
+	for _, target := range targets {
+		for _, name := range target.Groups {
+			groups[name] = true
+		}
+		if group == TargetGroupAll || slices.Contains(target.Groups, group) {
+			members = append(members, target)
+		}
+	}
+	if len(members) == 0 {
+		known := make([]string, 0, len(groups))
+		for name := range groups {
+			known = append(known, name)
+		}
+		sort.Strings(known)
+		return nil, fmt.Errorf("--target-group %s matches no target (groups: %s)", group, strings.Join(append(known, TargetGroupAll), ", "))
+	}
+	return members, nil
+}
+
+// scopedCopy returns an InfrahubOps with its own copy of the configuration
+// that shares the executor, settings, warnings, events and context of iops,
+// so it can be bound to another deployment and run concurrently.
+func (iops *InfrahubOps) scopedCopy() *InfrahubOps {
+	return &InfrahubOps{
+		config:   iops.config.clone(),
+		executor: iops.executor,
+		settings: iops.settings,
+		warnings: iops.warnings,
+		events:   iops.events,
+		ctx:      iops.ctx,
+	}
+}
+
+// detachDeployment clears the deployment the configuration points at before
+// it is bound to another target.
+func (cfg *Configuration) detachDeployment() {
+	cfg.DockerComposeProject = ""
+	cfg.K8sNamespace = ""
+	cfg.K8sReleaseName = ""
+	cfg.TargetPin = nil
+}
+
+// forConfiguredTarget returns an independent InfrahubOps for target: a copy
+// of the shared configuration with the target's settings applied over it.
+// Backup names only carry a timestamp, so unless the target sets its own, the
+// backup directory and S3 prefix get a subdirectory named after the target.
+func (iops *InfrahubOps) forConfiguredTarget(target ConfiguredTarget) (*InfrahubOps, error) {
+	scoped := iops.scopedCopy()
+	scoped.config.detachDeployment()
+	scoped.config.BackupDir = filepath.Join(scoped.config.BackupDir, target.Name)
+	scoped.config.S3.Prefix = path.Join(scoped.config.S3.Prefix, target.Name)
+	if target.KubeContext != "" {
+		scoped.executor = &kubeContextExecutor{CommandExecutor: scoped.executor, context: target.KubeContext}
+	}
+	scoped.settings = target.settings
+	if err := scoped.applyConfigSettings(); err != nil {
+		return nil, fmt.Errorf("target %s: %w", target.Name, err)
+	}
+	return scoped, nil
+}
+
+// targetGroupResult is the outcome of an operation on one configured target.
+type targetGroupResult struct {
+	Target   ConfiguredTarget
+	Backend  string // docker or kubernetes
+	Duration time.Duration
+	Artifact string // backup written, local path or S3 URI
+	Err      error
+}
+
+// RunTargetGroup runs fn for every target of group, at most concurrency at a
+// time. Every target runs to completion and a consolidated report is printed;
+// the returned error lists the targets that failed.
+func (iops *InfrahubOps) RunTargetGroup(operation, group string, concurrency int, fn func(target *InfrahubOps) error) error {
+	targets, err := iops.TargetGroup(group)
+	if err != nil {
+		return err
+	}
+	concurrency = max(concurrency, 1)
+	logrus.Infof("Running %s on %d targets of group %s, %d at a time", operation, len(targets), group, concurrency)
+
+	results := make([]targetGroupResult, len(targets))
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			results[i] = iops.runConfiguredTarget(operation, target, fn)
+		}()
+	}
+	wg.Wait()
+
+	writeTargetGroupReport(os.Stdout, results)
+	var failed []string
+	for _, result := range results {
+		if result.Err != nil {
+			failed = append(failed, result.Target.Name)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%s failed for %d of %d targets: %s", operation, len(failed), len(results), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func (iops *InfrahubOps) runConfiguredTarget(operation string, target ConfiguredTarget, fn func(target *InfrahubOps) error) targetGroupResult {
+	log := logrus.WithField("target", target.Name)
+	result := targetGroupResult{Target: target}
+	started := time.Now()
+	scoped, err := iops.forConfiguredTarget(target)
+	if err == nil {
+		result.Backend = "docker"
+		if scoped.config.K8sNamespace != "" {
+			result.Backend = "kubernetes"
+		}
+		scoped.report = &RunReport{Operation: operation, StartedAt: started}
+		log.Infof("Starting %s", operation)
+		err = fn(scoped)
+		result.Artifact = scoped.report.S3URI
+		if result.Artifact == "" {
+			result.Artifact = scoped.report.BackupPath
+		}
+	}
+	result.Duration = time.Since(started)
+	result.Err = err
+	if err != nil {
+		log.Errorf("%s failed: %v", operation, err)
+	} else {
+		log.Infof("%s completed", operation)
+	}
+	return result
+}
+
+// writeTargetGroupReport prints one line per target with its outcome.
+func writeTargetGroupReport(w io.Writer, results []targetGroupResult) {
+	fmt.Fprintf(w, "%-30s  %-10s  %-8s  %-10s  %s\n", "TARGET", "BACKEND", "STATUS", "DURATION", "BACKUP / ERROR")
+	for _, result := range results {
+		status, detail := "ok", result.Artifact
+		if result.Err != nil {
+			status, detail = "failed", result.Err.Error()
+		}
+		fmt.Fprintf(w, "%-30s  %-10s  %-8s  %-10s  %s\n",
+			result.Target.Name,
+			result.Backend,
+			status,
+			result.Duration.Round(time.Second),
+			detail,
+		)
+	}
+}
+
+// kubeContextExecutor runs kubectl and helm against a kubeconfig context.
+type kubeContextExecutor struct {
+	CommandExecutor
+	context string
+}
+
+func (e *kubeContextExecutor) args(name string, args []string) []string {
+	switch name {
+	case "kubectl":
+		return append([]string{"--context", e.context}, args...)
+	case "helm":
+		return append([]string{"--kube-context", e.context}, args...)
+	}
+	return args
+}
+
+func (e *kubeContextExecutor) runCommand(name string, args ...string) (string, error) {
+	return e.CommandExecutor.runCommand(name, e.args(name, args)...)
+}
+
+func (e *kubeContextExecutor) runCommandQuiet(name string, args ...string) error {
+	return e.CommandExecutor.runCommandQuiet(name, e.args(name, args)...)
+}
+
+func (e *kubeContextExecutor) runCommandPipe(name string, args ...string) (io.ReadCloser, func() error, error) {
+	return e.CommandExecutor.runCommandPipe(name, e.args(name, args)...)
+}
+
+func (e *kubeContextExecutor) runCommandPipeContext(ctx context.Context, name string, args ...string) (io.ReadCloser, func() error, error) {
+	return e.CommandExecutor.runCommandPipeContext(ctx, name, e.args(name, args)...)
+}
+
+func (e *kubeContextExecutor) runCommandWritePipe(stdin io.Reader, name string, args ...string) (func() error, error) {
+	return e.CommandExecutor.runCommandWritePipe(stdin, name, e.args(name, args)...)
+}
+
+func (e *kubeContextExecutor) runCommandWithStream(name string, args ...string) (string, error) {
+	return e.CommandExecutor.runCommandWithStream(name, e.args(name, args)...)
+}
@@ -0,0 +1,231 @@
+package app
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const targetGroupsConfig = `
+backup-dir: /backups
+s3-bucket: msp-backups
+s3-prefix: infrahub
+targets:
+  acme:
+    project: acme-infrahub
+    groups: [production]
+  globex:
+    k8s-namespace: infrahub
+    release-name: globex
+    kube-context: eu-cluster
+    groups: [production, eu]
+    s3-prefix: customers/globex
+    backup-dir: /srv/globex
+  initech:
+    project: initech
+    groups: [staging]
+`
+
+func newTargetGroupsOps(t *testing.T, config string, fake *fakeExecutor) *InfrahubOps {
+	t.Helper()
+	iops := NewInfrahubOpsWithExecutor(fake)
+	dir := t.TempDir()
+	writeTestFile(t, dir, "infrahub-ops.yaml", config)
+	iops.settings.SetConfigFile(filepath.Join(dir, "infrahub-ops.yaml"))
+	if err := iops.settings.MergeInConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if err := iops.applyConfigSettings(); err != nil {
+		t.Fatal(err)
+	}
+	return iops
+}
+
+func TestConfiguredTargetsInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{name: "not a map", config: "targets: [acme]", wantErr: "expected target names mapped to settings"},
+		{name: "target not a map", config: "targets:\n  acme: acme-infrahub", wantErr: "invalid target acme"},
+		{name: "no deployment", config: "targets:\n  acme:\n    groups: [production]", wantErr: "set either project or k8s-namespace"},
+		{name: "both deployments", config: "targets:\n  acme:\n    project: a\n    k8s-namespace: b", wantErr: "set either project or k8s-namespace"},
+		{name: "nested config", config: "targets:\n  acme:\n    project: a\n    config: other.yaml", wantErr: "config cannot be set per target"},
+		{name: "reserved name", config: "targets:\n  all:\n    project: a", wantErr: "reserved"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iops := newTargetGroupsOps(t, tt.config, newFakeExecutor())
+			if _, err := iops.ConfiguredTargets(); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ConfiguredTargets() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTargetGroup(t *testing.T) {
+	iops := newTargetGroupsOps(t, targetGroupsConfig, newFakeExecutor())
+	tests := []struct {
+		group   string
+		want    []string
+		wantErr string
+	}{
+		{group: "production", want: []string{"acme", "globex"}},
+		{group: "eu", want: []string{"globex"}},
+		{group: "all", want: []string{"acme", "globex", "initech"}},
+		{group: "dev", wantErr: "groups: eu, production, staging, all"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.group, func(t *testing.T) {
+			targets, err := iops.TargetGroup(tt.group)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("TargetGroup() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, target := range targets {
+				names = append(names, target.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("TargetGroup() = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestForConfiguredTargetInheritsSharedSettings(t *testing.T) {
+	iops := newTargetGroupsOps(t, targetGroupsConfig, newFakeExecutor())
+	iops.config.DockerComposeProject = "detected"
+	targets, err := iops.ConfiguredTargets()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		target                                 ConfiguredTarget
+		project, namespace, release            string
+		backupDir, bucket, prefix, kubeContext string
+	}{
+		{target: targets[0], project: "acme-infrahub", backupDir: filepath.Join("/backups", "acme"), bucket: "msp-backups", prefix: "infrahub/acme"},
+		{target: targets[1], namespace: "infrahub", release: "globex", backupDir: "/srv/globex", bucket: "msp-backups", prefix: "customers/globex", kubeContext: "eu-cluster"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target.Name, func(t *testing.T) {
+			scoped, err := iops.forConfiguredTarget(tt.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg := scoped.config
+			if cfg.DockerComposeProject != tt.project || cfg.K8sNamespace != tt.namespace || cfg.K8sReleaseName != tt.release {
+				t.Errorf("deployment = %q/%q/%q, want %q/%q/%q", cfg.DockerComposeProject, cfg.K8sNamespace, cfg.K8sReleaseName, tt.project, tt.namespace, tt.release)
+			}
+			if cfg.BackupDir != tt.backupDir || cfg.S3.Bucket != tt.bucket || cfg.S3.Prefix != tt.prefix {
+				t.Errorf("backup-dir, bucket, prefix = %q, %q, %q; want %q, %q, %q", cfg.BackupDir, cfg.S3.Bucket, cfg.S3.Prefix, tt.backupDir, tt.bucket, tt.prefix)
+			}
+			executor, ok := scoped.executor.(*kubeContextExecutor)
+			if (tt.kubeContext != "") != ok || (ok && executor.context != tt.kubeContext) {
+				t.Errorf("executor = %#v, want kube context %q", scoped.executor, tt.kubeContext)
+			}
+		})
+	}
+	if iops.config.S3.Prefix != "infrahub" || iops.config.BackupDir != "/backups" {
+		t.Errorf("shared configuration changed: prefix %q, backup-dir %q", iops.config.S3.Prefix, iops.config.BackupDir)
+	}
+}
+
+func TestForConfiguredTargetKeepsContainersApart(t *testing.T) {
+	config := `
+kubernetes:
+  services:
+    database:
+      container: neo4j
+targets:
+  acme:
+    k8s-namespace: acme
+    k8s-container: [task-worker=worker-acme]
+  globex:
+    k8s-namespace: globex
+    k8s-container: [task-worker=worker-globex]
+`
+	iops := newTargetGroupsOps(t, config, newFakeExecutor())
+	targets, err := iops.ConfiguredTargets()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scoped := make([]*InfrahubOps, len(targets))
+	errs := make([]error, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			scoped[i], errs[i] = iops.forConfiguredTarget(target)
+		}()
+	}
+	wg.Wait()
+
+	for i, target := range targets {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		mapping := scoped[i].config.Kubernetes
+		if got, want := mapping.Services["task-worker"].Container, "worker-"+target.Name; got != want {
+			t.Errorf("%s task-worker container = %q, want %q", target.Name, got, want)
+		}
+		if got := mapping.Services["database"].Container; got != "neo4j" {
+			t.Errorf("%s database container = %q, want neo4j", target.Name, got)
+		}
+	}
+	if _, ok := iops.config.Kubernetes.override("task-worker"); ok {
+		t.Errorf("shared kubernetes mapping changed: %+v", iops.config.Kubernetes.Services)
+	}
+}
+
+func TestRunTargetGroupBoundsConcurrency(t *testing.T) {
+	iops := newTargetGroupsOps(t, targetGroupsConfig, newFakeExecutor())
+	var mu sync.Mutex
+	running, peak := 0, 0
+	err := iops.RunTargetGroup("backup", "all", 2, func(target *InfrahubOps) error {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		if target.config.DockerComposeProject == "initech" {
+			return errors.New("database unreachable")
+		}
+		target.recordArtifact("id", filepath.Join(target.config.BackupDir, "backup.tar.gz"), "", 1)
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "backup failed for 1 of 3 targets: initech") {
+		t.Errorf("RunTargetGroup() error = %v", err)
+	}
+	if peak != 2 {
+		t.Errorf("peak concurrency = %d, want 2", peak)
+	}
+}
+
+func TestKubeContextExecutor(t *testing.T) {
+	fake := newFakeExecutor()
+	executor := &kubeContextExecutor{CommandExecutor: fake, context: "eu-cluster"}
+	executor.runCommand("kubectl", "get", "pods")
+	executor.runCommandQuiet("helm", "status", "infrahub")
+	executor.runCommand("docker", "ps")
+	want := []string{"kubectl --context eu-cluster get pods", "helm --kube-context eu-cluster status infrahub", "docker ps"}
+	if strings.Join(fake.calls, "\n") != strings.Join(want, "\n") {
+		t.Errorf("calls = %q, want %q", fake.calls, want)
+	}
+}
@@ -0,0 +1,74 @@
+package app
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// targetPinFilename is read from the working directory so commands run from a
+// deployment's directory always operate on that deployment.
+const targetPinFilename = ".infrahub-ops.yaml"
+
+// TargetPin is the deployment a .infrahub-ops.yaml file pins commands to. The
+// file's other keys are default flag values, as in the --config file.
+type TargetPin struct {
+	Path      string
+	Project   string // docker compose project
+	Namespace string // kubernetes namespace
+	Context   string // kubeconfig context that must be current
+}
+
+// readTargetPin merges the pin file of dir into settings below the values of
+// --config. It returns nil when dir has no pin file.
+func readTargetPin(settings *viper.Viper, dir string) (*TargetPin, error) {
+	path := filepath.Join(dir, targetPinFilename)
+	if !fileExists(path) {
+		return nil, nil
+	}
+
+	file := viper.New()
+	file.SetConfigFile(path)
+	if err := file.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	pin := &TargetPin{
+		Path:      path,
+		Project:   file.GetString("project"),
+		Namespace: file.GetString("k8s-namespace"),
+		Context:   file.GetString("k8s-context"),
+	}
+	if pin.Project != "" && (pin.Namespace != "" || pin.Context != "") {
+		return nil, fmt.Errorf("%s pins both a docker project and a kubernetes target; keep only one", path)
+	}
+	if err := settings.MergeConfigMap(file.AllSettings()); err != nil {
+		return nil, fmt.Errorf("failed to apply %s: %w", path, err)
+	}
+	return pin, nil
+}
+
+// check refuses a backend other than the pinned target. Auto-detection, a
+// cached detection or a --project or --k8s-namespace flag can all select
+// another deployment; --no-pin ignores the pin file.
+func (p *TargetPin) check(backend EnvironmentBackend, currentContext func() string) error {
+	if p == nil {
+		return nil
+	}
+	target := fmt.Sprintf("%s %s", backend.Name(), backend.Info())
+	switch {
+	case p.Project != "" && (backend.Name() != "docker" || backend.Info() != p.Project):
+		return fmt.Errorf("%s pins docker project %s, but the target is %s; pass --no-pin to ignore the pin file", p.Path, p.Project, target)
+	case p.Namespace != "" && (backend.Name() != "kubernetes" || backend.Info() != p.Namespace):
+		return fmt.Errorf("%s pins kubernetes namespace %s, but the target is %s; pass --no-pin to ignore the pin file", p.Path, p.Namespace, target)
+	case p.Context != "" && backend.Name() != "kubernetes":
+		return fmt.Errorf("%s pins kubernetes context %s, but the target is %s; pass --no-pin to ignore the pin file", p.Path, p.Context, target)
+	case p.Context != "":
+		if current := currentContext(); current != p.Context {
+			return fmt.Errorf("%s pins kubernetes context %s, but the current context is %q; switch with kubectl config use-context %s", p.Path, p.Context, current, p.Context)
+		}
+	}
+	logrus.Infof("Target %s matches %s", target, p.Path)
+	return nil
+}
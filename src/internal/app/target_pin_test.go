@@ -0,0 +1,122 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadTargetPin(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    *TargetPin
+		wantErr bool
+	}{
+		{name: "no pin file"},
+		{
+			name:    "docker project",
+			content: "project: infrahub-prod\nbackup-dir: /srv/backups\n",
+			want:    &TargetPin{Project: "infrahub-prod"},
+		},
+		{
+			name:    "kubernetes namespace and context",
+			content: "k8s-namespace: infrahub\nk8s-context: prod-eu\n",
+			want:    &TargetPin{Namespace: "infrahub", Context: "prod-eu"},
+		},
+		{name: "both targets", content: "project: infrahub-prod\nk8s-namespace: infrahub\n", wantErr: true},
+		{name: "invalid yaml", content: "project: [", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.content != "" {
+				writeTestFile(t, dir, targetPinFilename, tt.content)
+			}
+			iops := NewInfrahubOps()
+			pin, err := readTargetPin(iops.settings, dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readTargetPin() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.want == nil {
+				if pin != nil {
+					t.Errorf("readTargetPin() = %+v, want nil", pin)
+				}
+				return
+			}
+			tt.want.Path = filepath.Join(dir, targetPinFilename)
+			if *pin != *tt.want {
+				t.Errorf("readTargetPin() = %+v, want %+v", *pin, *tt.want)
+			}
+		})
+	}
+}
+
+func TestApplySettingsTargetPin(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, targetPinFilename, "project: infrahub-prod\nbackup-dir: /srv/pinned\npg-jobs: 2\n")
+	configPath := filepath.Join(dir, "override.yaml")
+	if err := os.WriteFile(configPath, []byte("backup-dir: /srv/config\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+
+	iops := NewInfrahubOps()
+	iops.settings.Set("config", configPath)
+	if err := iops.applySettings(); err != nil {
+		t.Fatalf("applySettings() error = %v", err)
+	}
+	cfg := iops.config
+	if cfg.TargetPin == nil || cfg.DockerComposeProject != "infrahub-prod" || cfg.PgJobs != 2 {
+		t.Errorf("pin not applied: project=%q pg-jobs=%d pin=%+v", cfg.DockerComposeProject, cfg.PgJobs, cfg.TargetPin)
+	}
+	if cfg.BackupDir != "/srv/config" {
+		t.Errorf("backup dir = %s, want the --config value to override the pin file", cfg.BackupDir)
+	}
+
+	ignored := NewInfrahubOps()
+	ignored.settings.Set("no-pin", true)
+	if err := ignored.applySettings(); err != nil {
+		t.Fatalf("applySettings() error = %v", err)
+	}
+	if ignored.config.TargetPin != nil || ignored.config.DockerComposeProject == "infrahub-prod" {
+		t.Errorf("--no-pin still applied the pin file: %+v", ignored.config)
+	}
+}
+
+func TestTargetPinCheck(t *testing.T) {
+	docker := &DockerBackend{project: "infrahub-prod"}
+	kubernetes := &KubernetesBackend{namespace: "infrahub"}
+	context := func() string { return "prod-eu" }
+
+	tests := []struct {
+		name    string
+		pin     *TargetPin
+		backend EnvironmentBackend
+		wantErr string
+	}{
+		{name: "no pin", backend: docker},
+		{name: "pinned project", pin: &TargetPin{Project: "infrahub-prod"}, backend: docker},
+		{name: "other project", pin: &TargetPin{Project: "infrahub-dev"}, backend: docker, wantErr: "pins docker project infrahub-dev, but the target is docker infrahub-prod"},
+		{name: "project pinned on kubernetes", pin: &TargetPin{Project: "infrahub-prod"}, backend: kubernetes, wantErr: "but the target is kubernetes infrahub"},
+		{name: "pinned namespace and context", pin: &TargetPin{Namespace: "infrahub", Context: "prod-eu"}, backend: kubernetes},
+		{name: "other namespace", pin: &TargetPin{Namespace: "infrahub-staging"}, backend: kubernetes, wantErr: "pins kubernetes namespace infrahub-staging"},
+		{name: "other context", pin: &TargetPin{Context: "prod-us"}, backend: kubernetes, wantErr: `current context is "prod-eu"`},
+		{name: "context pinned on docker", pin: &TargetPin{Context: "prod-eu"}, backend: docker, wantErr: "pins kubernetes context prod-eu"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.pin.check(tt.backend, context)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("check() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("check() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
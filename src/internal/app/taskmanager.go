@@ -53,6 +53,11 @@ func (iops *InfrahubOps) flushTaskRuns(config flushConfig, daysToKeep, batchSize
 	if err := iops.DetectEnvironment(); err != nil {
 		return err
 	}
+	releaseLock, err := iops.acquireOperationLock("taskmanager-flush")
+	if err != nil {
+		return err
+	}
+	defer releaseLock()
 
 	if daysToKeep < 0 {
 		daysToKeep = config.defaultDaysToKeep
@@ -69,6 +74,12 @@ func (iops *InfrahubOps) flushTaskRuns(config flushConfig, daysToKeep, batchSize
 		batchSize = maxLimit
 	}
 
+	if iops.config.FlushAutoBackup {
+		if _, err := iops.backupTaskManagerBeforeFlush(config.commandType); err != nil {
+			return fmt.Errorf("backup before flush failed, nothing was flushed: %w", err)
+		}
+	}
+
 	logrus.Infof("Flushing Prefect flow runs older than %d days (batch size %d)...", daysToKeep, batchSize)
 
 	primaryCmd := []string{"infrahub", "tasks", "flush", config.commandType, "--days-to-keep", strconv.Itoa(daysToKeep), "--batch-size", strconv.Itoa(batchSize)}
@@ -0,0 +1,177 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// taskManagerAutoBackupClass is the retention class of the backups taken
+	// by flush --auto-backup.
+	taskManagerAutoBackupClass = "pre-flush"
+
+	// DefaultFlushAutoBackupKeep is how many pre-flush backups are kept.
+	DefaultFlushAutoBackupKeep = 3
+)
+
+// backupTaskManagerBeforeFlush writes an archive holding only the task
+// manager database, records it in the catalog under
+// taskManagerAutoBackupClass and deletes the pre-flush backups beyond
+// FlushAutoBackupKeep. It returns the path of the archive.
+func (iops *InfrahubOps) backupTaskManagerBeforeFlush(operation string) (string, error) {
+	logrus.Infof("Backing up the task manager database before flushing %s...", operation)
+
+	workDir, err := os.MkdirTemp("", "infrahub_backup_*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+	backupDir := filepath.Join(workDir, "backup")
+	if err := os.MkdirAll(backupDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	if err := os.MkdirAll(iops.config.BackupDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create backup parent directory: %w", err)
+	}
+
+	if err := iops.backupTaskManagerDB(backupDir); err != nil {
+		return "", err
+	}
+
+	backupID := strings.TrimSuffix(iops.generateBackupFilename(), ".tar.gz")
+	metadata := iops.createBackupMetadata(backupID, true, iops.collectInfrahubInfo().Version, "")
+	metadata.Components = []string{"task-manager-db"}
+	iops.recordTaskManagerInfo(metadata)
+	if metadata.Checksums, err = taskManagerDumpChecksums(backupDir, iops.config.checksumAlgorithm()); err != nil {
+		return "", err
+	}
+	pipeline := defaultArchivePipeline(false, false)
+	metadata.Archive = pipeline.Info()
+
+	metadataBytes, err := marshalBackupMetadata(metadata)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(backupDir, backupMetadataFilename), metadataBytes, 0644); err != nil {
+		return "", fmt.Errorf("failed to write metadata: %w", err)
+	}
+	backupPath, _, err := pipeline.WriteWithStats(workDir, "backup/", filepath.Join(iops.config.BackupDir, backupID), ArchiveOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	var size int64
+	if info, err := os.Stat(backupPath); err == nil {
+		size = info.Size()
+	}
+	if err := iops.recordClassifiedBackup(backupID, backupPath, "", size, taskManagerAutoBackupClass); err != nil {
+		logrus.Warnf("Failed to record pre-flush backup in catalog: %v", err)
+	} else if err := iops.prunePreFlushBackups(); err != nil {
+		logrus.Warnf("Failed to delete old pre-flush backups: %v", err)
+	}
+	logrus.Infof("Task manager database backed up to %s; restore it to undo the flush", backupPath)
+	return backupPath, nil
+}
+
+// taskManagerDumpChecksums returns the checksums of the task manager dump,
+// in the file or directory format.
+func taskManagerDumpChecksums(backupDir, algorithm string) (map[string]string, error) {
+	files := map[string]string{}
+	if err := collectFile(filepath.Join(backupDir, prefectDumpFilename), prefectDumpFilename, files); err != nil {
+		return nil, err
+	}
+	if dumpDir := filepath.Join(backupDir, prefectDumpDirName); fileExists(dumpDir) {
+		if err := collectDirectoryFiles(backupDir, dumpDir, files); err != nil {
+			return nil, fmt.Errorf("failed to calculate Prefect DB dump checksums: %w", err)
+		}
+	}
+	checksums := make(map[string]string, len(files))
+	for name, path := range files {
+		sum, err := calculateChecksum(path, algorithm)
+		if err != nil {
+			return nil, fmt.Errorf("failed to calculate checksum of %s: %w", name, err)
+		}
+		checksums[name] = sum
+	}
+	return checksums, nil
+}
+
+// prunePreFlushBackups deletes the local pre-flush backups beyond the newest
+// FlushAutoBackupKeep, keeping held ones.
+func (iops *InfrahubOps) prunePreFlushBackups() error {
+	keep := iops.config.FlushAutoBackupKeep
+	if keep <= 0 {
+		keep = DefaultFlushAutoBackupKeep
+	}
+	catalog, err := loadBackupCatalog(iops.config.BackupDir)
+	if err != nil {
+		return err
+	}
+	var candidates []CatalogEntry
+	for _, entry := range catalog.Entries {
+		if entry.RetentionClass == taskManagerAutoBackupClass && !entry.Held && entry.LocalPath != "" {
+			candidates = append(candidates, entry)
+		}
+	}
+	if len(candidates) <= keep {
+		return nil
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].CreatedAt > candidates[j].CreatedAt })
+	for _, entry := range candidates[keep:] {
+		if err := removeArchiveFiles(entry.LocalPath); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", entry.LocalPath, err)
+		}
+		forgetCatalogCopy(catalog, entry.BackupID, entry.LocalPath)
+		logrus.Infof("Deleted pre-flush backup %s", entry.BackupID)
+	}
+	return catalog.save()
+}
+
+// restoreTaskManagerOnly restores an archive holding only the task manager
+// database, such as one taken by flush --auto-backup. Only the task manager
+// stack is stopped; the graph database and infrahub-server keep running.
+func (iops *InfrahubOps) restoreTaskManagerOnly(workDir string, metadata *BackupMetadata, excludeTaskManager bool) error {
+	if excludeTaskManager || !metadata.hasComponent("task-manager-db") {
+		return fmt.Errorf("backup %s has no graph database, and no task manager database to restore", metadata.BackupID)
+	}
+	if !hasTaskManagerDump(filepath.Join(workDir, "backup")) {
+		return fmt.Errorf("backup metadata includes task manager database but %s is missing", prefectDumpFilename)
+	}
+	if err := iops.verifyPostgresRestoreCompatibility(metadata); err != nil {
+		return err
+	}
+	migratePrefect, err := iops.verifyPrefectSchemaRevision(metadata)
+	if err != nil {
+		return err
+	}
+	logrus.Info("Backup holds only the task manager database; the graph database is left as it is")
+	logExcludedTableData(metadata)
+
+	stopped, err := iops.stopRunningServices([]string{"task-worker", "task-manager", "task-manager-background-svc"})
+	if err != nil {
+		return err
+	}
+	if err := iops.runPhase("taskmanager_restore", func() error { return iops.restorePostgreSQL(workDir) }); err != nil {
+		return err
+	}
+	if err := iops.anonymizeRestoredData(workDir, false, true); err != nil {
+		return err
+	}
+	if err := iops.runPhase("start_services", func() error { return iops.startServicesInOrder(stopped) }); err != nil {
+		return fmt.Errorf("failed to restart the task manager: %w", err)
+	}
+	if migratePrefect {
+		if err := iops.runPrefectMigrations(); err != nil {
+			return err
+		}
+	}
+	iops.resumeRestoredWorkPools(metadata)
+
+	logrus.Info("Task manager database restore completed successfully")
+	return nil
+}
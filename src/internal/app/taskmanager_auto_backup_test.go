@@ -0,0 +1,129 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBackupTaskManagerBeforeFlush(t *testing.T) {
+	fake := newFakeExecutor().on("alembic_version", "a49711513ad4\n", nil)
+	iops := newFakeDockerOps(fake)
+	iops.config.BackupDir = t.TempDir()
+
+	path, err := iops.backupTaskManagerBeforeFlush("flow-runs")
+	if err != nil {
+		t.Fatalf("backupTaskManagerBeforeFlush() error = %v", err)
+	}
+	if got := fake.commands("pg_dump"); len(got) != 1 {
+		t.Errorf("dump commands = %v, want one", got)
+	}
+	if got := fake.commands("neo4j-admin"); len(got) != 0 {
+		t.Errorf("graph database backed up: %v", got)
+	}
+
+	data, err := readArchiveMember(path, "backup/"+backupMetadataFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var metadata BackupMetadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(metadata.Components, []string{"task-manager-db"}) {
+		t.Errorf("components = %v, want only task-manager-db", metadata.Components)
+	}
+	if metadata.PrefectSchemaRevision != "a49711513ad4" {
+		t.Errorf("Prefect schema revision = %q, want a49711513ad4", metadata.PrefectSchemaRevision)
+	}
+
+	catalog, err := loadBackupCatalog(iops.config.BackupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := catalog.find(path)
+	if entry == nil || entry.RetentionClass != taskManagerAutoBackupClass {
+		t.Errorf("catalog entry = %+v, want retention class %s", entry, taskManagerAutoBackupClass)
+	}
+}
+
+func TestPrunePreFlushBackups(t *testing.T) {
+	tests := []struct {
+		name        string
+		keep        int
+		held        string
+		wantDeleted []string
+	}{
+		{name: "default keep", wantDeleted: []string{"b1"}},
+		{name: "keep two", keep: 2, wantDeleted: []string{"b1", "b2"}},
+		{name: "held kept", keep: 2, held: "b1", wantDeleted: []string{"b2"}},
+		{name: "keep all", keep: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iops := newFakeDockerOps(newFakeExecutor())
+			iops.config.BackupDir = t.TempDir()
+			iops.config.FlushAutoBackupKeep = tt.keep
+
+			catalog, err := loadBackupCatalog(iops.config.BackupDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids := []string{"b1", "b2", "b3", "b4", "manual"}
+			for i, id := range ids {
+				path := filepath.Join(iops.config.BackupDir, id+".tar.gz")
+				if err := os.WriteFile(path, []byte("archive"), 0644); err != nil {
+					t.Fatal(err)
+				}
+				entry := CatalogEntry{BackupID: id, Filename: id + ".tar.gz", LocalPath: path, CreatedAt: fmt.Sprintf("2026-10-%02dT00:00:00Z", i+1), Held: id == tt.held}
+				if id != "manual" {
+					entry.RetentionClass = taskManagerAutoBackupClass
+				}
+				catalog.upsert(entry)
+			}
+			if err := catalog.save(); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := iops.prunePreFlushBackups(); err != nil {
+				t.Fatalf("prunePreFlushBackups() error = %v", err)
+			}
+			var deleted []string
+			for _, id := range ids {
+				if !fileExists(filepath.Join(iops.config.BackupDir, id+".tar.gz")) {
+					deleted = append(deleted, id)
+				}
+			}
+			if !reflect.DeepEqual(deleted, tt.wantDeleted) {
+				t.Errorf("deleted = %v, want %v", deleted, tt.wantDeleted)
+			}
+		})
+	}
+}
+
+func TestRestoreTaskManagerOnly(t *testing.T) {
+	workDir := t.TempDir()
+	writeTestFile(t, workDir, filepath.Join("backup", prefectDumpFilename), "dump")
+	fake := newFakeExecutor()
+	iops := newFakeDockerOps(fake)
+	metadata := &BackupMetadata{BackupID: "pre-flush", Components: []string{"task-manager-db"}}
+
+	if err := iops.restoreTaskManagerOnly(workDir, metadata, false); err != nil {
+		t.Fatalf("restoreTaskManagerOnly() error = %v", err)
+	}
+	if got := fake.commands("pg_restore"); len(got) != 1 {
+		t.Errorf("restore commands = %v, want one", got)
+	}
+	for _, service := range []string{"database", "infrahub-server"} {
+		if got := fake.commands("stop " + service); len(got) != 0 {
+			t.Errorf("%s stopped: %v", service, got)
+		}
+	}
+
+	if err := iops.restoreTaskManagerOnly(workDir, metadata, true); err == nil {
+		t.Error("restoreTaskManagerOnly() with the task manager excluded succeeded")
+	}
+}
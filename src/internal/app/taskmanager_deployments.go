@@ -0,0 +1,104 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sirupsen/logrus"
+)
+
+// PrefectDeployment is a task manager deployment, named flow/deployment.
+type PrefectDeployment struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Paused    bool     `json:"paused"`
+	Schedules []string `json:"schedules"`
+}
+
+// runDeploymentScript runs deployments.py in the task worker and returns the
+// JSON document printed on the last line of its output.
+func (iops *InfrahubOps) runDeploymentScript(action string, names []string) ([]byte, error) {
+	scriptContent, err := readEmbeddedScript("deployments.py")
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve deployments.py: %w", err)
+	}
+
+	scriptPath := "/tmp/infrahubops_deployments.py"
+	command := append([]string{"python", "-u", scriptPath, action}, names...)
+	execOpts := iops.buildTaskWorkerExecOpts(&ExecOptions{})
+	output, err := iops.executeScriptWithOpts("task-worker", string(scriptContent), scriptPath, execOpts, command...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to %s deployments: %w", action, err)
+	}
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return []byte(strings.TrimSpace(lines[len(lines)-1])), nil
+}
+
+// ListDeployments returns the task manager deployments sorted by name.
+func (iops *InfrahubOps) ListDeployments() ([]PrefectDeployment, error) {
+	output, err := iops.runDeploymentScript("list", nil)
+	if err != nil {
+		return nil, err
+	}
+	deployments := []PrefectDeployment{}
+	if err := json.Unmarshal(output, &deployments); err != nil {
+		return nil, fmt.Errorf("could not parse deployments: %w\n%s", err, output)
+	}
+	return deployments, nil
+}
+
+// PauseDeployments pauses the named deployments, or every deployment when
+// none are named, so their schedules stop creating flow runs. It returns the
+// deployments it paused; already paused ones are left out.
+func (iops *InfrahubOps) PauseDeployments(names []string) ([]string, error) {
+	return iops.setDeploymentsPaused("pause", names)
+}
+
+// ResumeDeployments resumes the named deployments and returns the ones that
+// were paused.
+func (iops *InfrahubOps) ResumeDeployments(names []string) ([]string, error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("name the deployments to resume")
+	}
+	return iops.setDeploymentsPaused("resume", names)
+}
+
+func (iops *InfrahubOps) setDeploymentsPaused(action string, names []string) ([]string, error) {
+	output, err := iops.runDeploymentScript(action, names)
+	if err != nil {
+		return nil, err
+	}
+	changed := []string{}
+	if err := json.Unmarshal(output, &changed); err != nil {
+		return nil, fmt.Errorf("could not parse json: %w\n%s", err, output)
+	}
+
+	verb := map[string]string{"pause": "Paused", "resume": "Resumed"}[action]
+	if len(changed) == 0 {
+		logrus.Infof("No deployments to %s", action)
+	} else {
+		logrus.Infof("%s deployments: %s", verb, strings.Join(changed, ", "))
+	}
+	return changed, nil
+}
+
+// WriteDeployments prints deployments as a table.
+func WriteDeployments(w io.Writer, deployments []PrefectDeployment) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DEPLOYMENT\tSTATE\tSCHEDULES")
+	for _, deployment := range deployments {
+		state := "active"
+		if deployment.Paused {
+			state = "paused"
+		}
+		schedules := strings.Join(deployment.Schedules, "; ")
+		if schedules == "" {
+			schedules = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", deployment.Name, state, schedules)
+	}
+	return tw.Flush()
+}
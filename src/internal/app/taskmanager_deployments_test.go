@@ -0,0 +1,93 @@
+package app
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestListDeployments(t *testing.T) {
+	fake := newFakeExecutor().on("deployments.py list", "Loading...\n"+
+		`[{"id":"1","name":"git-sync/git-repositories-sync","paused":false,"schedules":["*/1 * * * *"]},`+
+		`{"id":"2","name":"artifacts/generate","paused":true,"schedules":[]}]`, nil)
+
+	deployments, err := newFakeDockerOps(fake).ListDeployments()
+	if err != nil {
+		t.Fatalf("ListDeployments() error = %v", err)
+	}
+	want := []PrefectDeployment{
+		{ID: "1", Name: "git-sync/git-repositories-sync", Schedules: []string{"*/1 * * * *"}},
+		{ID: "2", Name: "artifacts/generate", Paused: true, Schedules: []string{}},
+	}
+	if !reflect.DeepEqual(deployments, want) {
+		t.Errorf("ListDeployments() = %+v, want %+v", deployments, want)
+	}
+
+	var out bytes.Buffer
+	if err := WriteDeployments(&out, deployments); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"git-sync/git-repositories-sync  active  */1 * * * *",
+		"artifacts/generate              paused  -",
+	} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("output misses %q:\n%s", line, out.String())
+		}
+	}
+}
+
+func TestPauseAndResumeDeployments(t *testing.T) {
+	tests := []struct {
+		name    string
+		run     func(*InfrahubOps) ([]string, error)
+		command string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "pause all",
+			run:     func(iops *InfrahubOps) ([]string, error) { return iops.PauseDeployments(nil) },
+			command: "deployments.py pause",
+			want:    []string{"git-sync/git-repositories-sync"},
+		},
+		{
+			name: "resume named",
+			run: func(iops *InfrahubOps) ([]string, error) {
+				return iops.ResumeDeployments([]string{"git-repositories-sync"})
+			},
+			command: "deployments.py resume git-repositories-sync",
+			want:    []string{"git-sync/git-repositories-sync"},
+		},
+		{
+			name:    "resume needs names",
+			run:     func(iops *InfrahubOps) ([]string, error) { return iops.ResumeDeployments(nil) },
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeExecutor().on("deployments.py", `["git-sync/git-repositories-sync"]`, nil)
+			got, err := tt.run(newFakeDockerOps(fake))
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				if len(fake.commands("deployments.py")) != 0 {
+					t.Errorf("script ran: %v", fake.calls)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("changed = %v, want %v", got, tt.want)
+			}
+			if len(fake.commands(tt.command)) != 1 {
+				t.Errorf("command %q missing: %v", tt.command, fake.calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package app
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFlushTaskRunsTakesLock(t *testing.T) {
+	server := httptest.NewServer(&fakeConsul{kv: map[string][]byte{}, owner: map[string]string{}})
+	defer server.Close()
+	newOps := func(operator string, fake *fakeExecutor) *InfrahubOps {
+		iops := newFakeDockerOps(fake)
+		iops.config.RecordOperator = operator
+		iops.config.Lock.URL = "consul://" + strings.TrimPrefix(server.URL, "http://") + "/locks"
+		return iops
+	}
+
+	release, err := newOps("alice", newFakeExecutor()).acquireOperationLock("restore")
+	if err != nil {
+		t.Fatalf("acquireOperationLock() error = %v", err)
+	}
+	defer release()
+
+	fake := newFakeExecutor()
+	for name, flush := range map[string]func(*InfrahubOps) error{
+		"flow-runs":  func(iops *InfrahubOps) error { return iops.FlushFlowRuns(30, 100) },
+		"stale-runs": func(iops *InfrahubOps) error { return iops.FlushStaleRuns(2, 100) },
+	} {
+		if err := flush(newOps("bob", fake)); !errors.Is(err, errLockHeld) {
+			t.Errorf("flush %s while locked error = %v, want held lock", name, err)
+		}
+	}
+	if got := fake.commands("task-worker"); len(got) != 0 {
+		t.Errorf("task manager touched while locked: %v", got)
+	}
+}
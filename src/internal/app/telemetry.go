@@ -0,0 +1,114 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// telemetryTimeout bounds the usage report sent when a command exits, so an
+// unreachable endpoint never delays the exit noticeably.
+const telemetryTimeout = 3 * time.Second
+
+// TelemetryEvent is the anonymous usage report of one command. It carries no
+// host names, targets, paths, credentials or error messages.
+type TelemetryEvent struct {
+	Tool            string  `json:"tool"`
+	ToolVersion     string  `json:"tool_version"`
+	Command         string  `json:"command"`
+	Backend         string  `json:"backend,omitempty"`
+	ArchiveBackend  string  `json:"archive_backend"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	Success         bool    `json:"success"`
+	ExitCode        int     `json:"exit_code"`
+	WarningCount    int     `json:"warning_count"`
+	OS              string  `json:"os"`
+	Arch            string  `json:"arch"`
+}
+
+// commandRun identifies the command being run, for the usage report.
+type commandRun struct {
+	tool    string
+	command string
+	started time.Time
+}
+
+// startCommand records which command runs, from the root command's
+// PersistentPreRunE.
+func (iops *InfrahubOps) startCommand(cmd *cobra.Command) {
+	tool := cmd.Root().Name()
+	command := strings.TrimPrefix(strings.TrimPrefix(cmd.CommandPath(), tool), " ")
+	iops.run = &commandRun{tool: tool, command: command, started: time.Now()}
+}
+
+// telemetryEnabled reports whether a usage report is sent: only when an
+// endpoint is configured, and never when DO_NOT_TRACK is set.
+func (iops *InfrahubOps) telemetryEnabled() bool {
+	if iops.config.TelemetryEndpoint == "" || iops.run == nil {
+		return false
+	}
+	switch strings.ToLower(os.Getenv("DO_NOT_TRACK")) {
+	case "", "0", "false":
+		return true
+	}
+	return false
+}
+
+// telemetryEvent describes the finished command.
+func (iops *InfrahubOps) telemetryEvent(exitCode int) TelemetryEvent {
+	event := TelemetryEvent{
+		Tool:            iops.run.tool,
+		ToolVersion:     BuildRevision(),
+		Command:         iops.run.command,
+		ArchiveBackend:  string(iops.config.Backend),
+		DurationSeconds: time.Since(iops.run.started).Round(time.Millisecond).Seconds(),
+		Success:         exitCode == ExitSuccess,
+		ExitCode:        exitCode,
+		WarningCount:    iops.warnings.count(),
+		OS:              runtime.GOOS,
+		Arch:            runtime.GOARCH,
+	}
+	if iops.backend != nil {
+		event.Backend = iops.backend.Name()
+	}
+	return event
+}
+
+// sendTelemetry posts the usage report of the finished command when the
+// operator opted in. Failures are only logged at debug level.
+func (iops *InfrahubOps) sendTelemetry(exitCode int) {
+	if !iops.telemetryEnabled() {
+		return
+	}
+	client, err := iops.config.HTTP.Client(telemetryTimeout)
+	if err == nil {
+		err = postTelemetry(client, iops.config.TelemetryEndpoint, iops.telemetryEvent(exitCode))
+	}
+	if err != nil {
+		logrus.Debugf("Failed to send usage report: %v", err)
+	}
+}
+
+func postTelemetry(client *http.Client, url string, event TelemetryEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("telemetry endpoint returned %s", resp.Status)
+	}
+	return nil
+}
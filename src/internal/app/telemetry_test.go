@@ -0,0 +1,93 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestFinishSendsTelemetry(t *testing.T) {
+	tests := []struct {
+		name       string
+		endpoint   bool
+		doNotTrack string
+		err        error
+		wantSent   bool
+		wantExit   int
+	}{
+		{name: "not opted in", err: nil, wantExit: ExitSuccess},
+		{name: "success", endpoint: true, wantSent: true, wantExit: ExitSuccess},
+		{name: "failure", endpoint: true, err: errors.New("backup failed on host db1"), wantSent: true, wantExit: ExitFailure},
+		{name: "do not track", endpoint: true, doNotTrack: "1", wantExit: ExitSuccess},
+		{name: "do not track disabled", endpoint: true, doNotTrack: "0", wantSent: true, wantExit: ExitSuccess},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DO_NOT_TRACK", tt.doNotTrack)
+			var received []byte
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received, _ = io.ReadAll(r.Body)
+			}))
+			defer server.Close()
+
+			iops := newFakeDockerOps(newFakeExecutor())
+			if tt.endpoint {
+				iops.config.TelemetryEndpoint = server.URL
+			}
+			root := &cobra.Command{Use: "infrahub-backup"}
+			create := &cobra.Command{Use: "create"}
+			root.AddCommand(create)
+			iops.startCommand(create)
+
+			if code := iops.Finish(io.Discard, tt.err); code != tt.wantExit {
+				t.Errorf("Finish() = %d, want %d", code, tt.wantExit)
+			}
+			if !tt.wantSent {
+				if received != nil {
+					t.Errorf("usage report sent: %s", received)
+				}
+				return
+			}
+
+			var event TelemetryEvent
+			if err := json.Unmarshal(received, &event); err != nil {
+				t.Fatalf("usage report %q: %v", received, err)
+			}
+			if event.Tool != "infrahub-backup" || event.Command != "create" || event.Backend != "docker" || event.ArchiveBackend != "tarball" {
+				t.Errorf("event = %+v", event)
+			}
+			if event.Success != (tt.err == nil) || event.ExitCode != tt.wantExit {
+				t.Errorf("event outcome = %t/%d, want exit %d", event.Success, event.ExitCode, tt.wantExit)
+			}
+
+			var fields map[string]any
+			_ = json.Unmarshal(received, &fields)
+			allowed := []string{"tool", "tool_version", "command", "backend", "archive_backend", "duration_seconds", "success", "exit_code", "warning_count", "os", "arch"}
+			for key := range fields {
+				if !slices.Contains(allowed, key) {
+					t.Errorf("usage report carries %q", key)
+				}
+			}
+		})
+	}
+}
+
+func TestStartCommandNestedPath(t *testing.T) {
+	root := &cobra.Command{Use: "infrahub-backup"}
+	environment := &cobra.Command{Use: "environment"}
+	detect := &cobra.Command{Use: "detect"}
+	root.AddCommand(environment)
+	environment.AddCommand(detect)
+
+	iops := NewInfrahubOps()
+	iops.startCommand(detect)
+	if iops.run.tool != "infrahub-backup" || iops.run.command != "environment detect" {
+		t.Errorf("run = %+v", iops.run)
+	}
+}
@@ -0,0 +1,260 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Intervals between load checks while copies are paused, and the default of
+// --throttle-max-pause.
+const (
+	throttleInitialInterval = 5 * time.Second
+	throttleMaxInterval     = 30 * time.Second
+	defaultThrottleMaxPause = 30 * time.Minute
+)
+
+// throttleSampleTTL is how long a load sample is reused, so parallel chunk
+// copies do not each run docker stats or kubectl top.
+const throttleSampleTTL = 5 * time.Second
+
+// loadSample is the load of a container at one point in time.
+type loadSample struct {
+	cpuPercent float64 // percent of one CPU core
+	ioBytes    int64   // block IO since the container started; -1 when unknown
+	at         time.Time
+}
+
+// loadThrottle pauses the copy phases of an online backup while the service
+// it watches is busier than --throttle-cpu or --throttle-io.
+type loadThrottle struct {
+	service  string
+	maxCPU   float64 // percent of one CPU core; 0 disables
+	maxIO    int64   // bytes per second; 0 disables
+	maxPause time.Duration
+	sample   func() (loadSample, error)
+	poller   func(what string) *poller
+
+	mu       sync.Mutex
+	last     loadSample
+	previous loadSample // the sample before last, for the IO rate
+	failed   bool       // sampling failed once; later failures are not logged
+	paused   time.Duration
+}
+
+// newLoadThrottle returns a throttle watching service, or nil when neither
+// --throttle-cpu nor --throttle-io is set.
+func (iops *InfrahubOps) newLoadThrottle(service string) *loadThrottle {
+	cfg := iops.config
+	if cfg.ThrottleCPU <= 0 && cfg.ThrottleIO <= 0 {
+		return nil
+	}
+	maxPause := cfg.ThrottleMaxPause
+	if maxPause <= 0 {
+		maxPause = defaultThrottleMaxPause
+	}
+	return &loadThrottle{
+		service:  service,
+		maxCPU:   cfg.ThrottleCPU,
+		maxIO:    cfg.ThrottleIO,
+		maxPause: maxPause,
+		sample:   func() (loadSample, error) { return iops.sampleLoad(service) },
+		poller: func(what string) *poller {
+			return iops.newPoller(what, throttleInitialInterval, throttleMaxInterval)
+		},
+	}
+}
+
+// wait returns once the service is idle enough for phase to go on, or after
+// --throttle-max-pause so a backup under constant load still completes. A
+// nil throttle never waits.
+func (t *loadThrottle) wait(phase string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	reason := t.busy()
+	if reason == "" {
+		return
+	}
+	logrus.Infof("Pausing %s: %s", phase, reason)
+	started := time.Now()
+	deadline := started.Add(t.maxPause)
+	poll := t.poller(fmt.Sprintf("%s load to drop before %s", t.service, phase))
+	for reason != "" {
+		if !time.Now().Before(deadline) {
+			logrus.Warnf("Resuming %s after the --throttle-max-pause of %s although %s is still busy: %s", phase, t.maxPause, t.service, reason)
+			break
+		}
+		poll.status(reason)
+		poll.wait(deadline)
+		reason = t.busy()
+	}
+	poll.done()
+	paused := time.Since(started)
+	t.paused += paused
+	if reason == "" {
+		logrus.Infof("Resuming %s after a pause of %s", phase, paused.Round(time.Second))
+	}
+}
+
+// busy samples the load and returns why it is too high, or "" when it is
+// not. Load that cannot be read never pauses the backup.
+func (t *loadThrottle) busy() string {
+	if time.Since(t.last.at) >= throttleSampleTTL {
+		sample, err := t.sample()
+		if err != nil {
+			if !t.failed {
+				logrus.Warnf("Cannot read the load of %s; copies are not throttled: %v", t.service, err)
+				t.failed = true
+			}
+			return ""
+		}
+		t.previous, t.last = t.last, sample
+	}
+
+	var reasons []string
+	if t.maxCPU > 0 && t.last.cpuPercent > t.maxCPU {
+		reasons = append(reasons, fmt.Sprintf("CPU at %.0f%%, above --throttle-cpu %.0f%%", t.last.cpuPercent, t.maxCPU))
+	}
+	elapsed := t.last.at.Sub(t.previous.at).Seconds()
+	if t.maxIO > 0 && !t.previous.at.IsZero() && elapsed > 0 && t.previous.ioBytes >= 0 && t.last.ioBytes >= t.previous.ioBytes {
+		if rate := int64(float64(t.last.ioBytes-t.previous.ioBytes) / elapsed); rate > t.maxIO {
+			reasons = append(reasons, fmt.Sprintf("block IO at %s/s, above --throttle-io %s/s", formatBytes(rate), formatBytes(t.maxIO)))
+		}
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// stopThrottle removes the throttle of the running backup and reports how
+// long it paused the copies.
+func (iops *InfrahubOps) stopThrottle() {
+	if iops.throttle != nil && iops.throttle.paused > 0 {
+		logrus.Infof("Copies were paused for %s in total because %s was busy", iops.throttle.paused.Round(time.Second), iops.throttle.service)
+	}
+	iops.throttle = nil
+}
+
+// sampleLoad reads the CPU and block IO of service with docker stats, or its
+// CPU with kubectl top, which does not report IO.
+func (iops *InfrahubOps) sampleLoad(service string) (loadSample, error) {
+	backend, err := iops.ensureBackend()
+	if err != nil {
+		return loadSample{}, err
+	}
+	switch b := backend.(type) {
+	case *DockerBackend:
+		output, err := b.executor.runCommand("docker", b.composeArgs("ps", "-q", service)...)
+		if err != nil {
+			return loadSample{}, err
+		}
+		ids := nonEmptyLines(output)
+		if len(ids) == 0 {
+			return loadSample{}, fmt.Errorf("no container for service %s", service)
+		}
+		output, err = b.executor.runCommand("docker", "stats", "--no-stream", "--format", "{{.CPUPerc}}\t{{.BlockIO}}", ids[0])
+		if err != nil {
+			return loadSample{}, err
+		}
+		return parseDockerStats(output)
+	case *KubernetesBackend:
+		pod, err := b.getPodForService(service)
+		if err != nil {
+			return loadSample{}, err
+		}
+		output, err := b.executor.runCommand("kubectl", "top", "pod", pod, "-n", b.namespaceFor(service), "--no-headers")
+		if err != nil {
+			return loadSample{}, err
+		}
+		return parseKubectlTop(output)
+	}
+	return loadSample{}, fmt.Errorf("load monitoring is not supported with the %s backend", backend.Name())
+}
+
+// parseDockerStats reads "<cpu>%\t<read> / <write>" as printed by docker
+// stats, e.g. "87.12%\t1.2GB / 340MB".
+func parseDockerStats(output string) (loadSample, error) {
+	cpu, blockIO, ok := strings.Cut(strings.TrimSpace(output), "\t")
+	if !ok {
+		return loadSample{}, fmt.Errorf("unexpected docker stats output %q", output)
+	}
+	percent, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(cpu), "%"), 64)
+	if err != nil {
+		return loadSample{}, fmt.Errorf("unexpected docker stats CPU %q", cpu)
+	}
+	sample := loadSample{cpuPercent: percent, ioBytes: -1, at: time.Now()}
+	read, write, ok := strings.Cut(blockIO, "/")
+	if !ok {
+		return sample, nil
+	}
+	var total int64
+	for _, value := range []string{read, write} {
+		value = strings.TrimSpace(value)
+		if value == "0B" {
+			continue
+		}
+		size, err := parseByteSize(value)
+		if err != nil {
+			return sample, nil
+		}
+		total += size
+	}
+	sample.ioBytes = total
+	return sample, nil
+}
+
+// parseKubectlTop reads the CPU column of kubectl top pod --no-headers, in
+// cores or millicores, e.g. "infrahub-database-0   1250m   3012Mi".
+func parseKubectlTop(output string) (loadSample, error) {
+	fields := strings.Fields(output)
+	if len(fields) < 2 {
+		return loadSample{}, fmt.Errorf("unexpected kubectl top output %q", output)
+	}
+	cpu := fields[1]
+	var cores float64
+	var err error
+	if millicores, found := strings.CutSuffix(cpu, "m"); found {
+		cores, err = strconv.ParseFloat(millicores, 64)
+		cores /= 1000
+	} else {
+		cores, err = strconv.ParseFloat(cpu, 64)
+	}
+	if err != nil {
+		return loadSample{}, fmt.Errorf("unexpected kubectl top CPU %q", cpu)
+	}
+	return loadSample{cpuPercent: cores * 100, ioBytes: -1, at: time.Now()}, nil
+}
+
+// copyDirThrottled copies the files of the directory src out of service into
+// dest one at a time, waiting for the throttle before each, so an online
+// backup can pause between files and, with --copy-chunk-size, between chunks.
+func (iops *InfrahubOps) copyDirThrottled(service, src, dest string) error {
+	output, err := iops.Exec(service, []string{"find", src, "-type", "f"}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to list %s: %w", src, err)
+	}
+	for _, file := range nonEmptyLines(output) {
+		rel := strings.TrimPrefix(strings.TrimPrefix(file, src), "/")
+		if rel == "" || rel == file {
+			continue
+		}
+		local := filepath.Join(dest, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(local), 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", filepath.Dir(local), err)
+		}
+		iops.throttle.wait("copying " + path.Base(file))
+		if err := iops.CopyFileFrom(service, file, local); err != nil {
+			return err
+		}
+	}
+	return nil
+}
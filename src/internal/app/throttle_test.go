@@ -0,0 +1,123 @@
+package app
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLoadSamples(t *testing.T) {
+	tests := []struct {
+		name    string
+		parse   func(string) (loadSample, error)
+		output  string
+		wantCPU float64
+		wantIO  int64
+		wantErr bool
+	}{
+		{name: "docker", parse: parseDockerStats, output: "87.5%\t1GB / 512MB\n", wantCPU: 87.5, wantIO: 3 << 29},
+		{name: "docker without io", parse: parseDockerStats, output: "3.25%\t0B / 0B", wantCPU: 3.25, wantIO: 0},
+		{name: "docker unknown io", parse: parseDockerStats, output: "150%\t--", wantCPU: 150, wantIO: -1},
+		{name: "docker garbage", parse: parseDockerStats, output: "no such container", wantErr: true},
+		{name: "kubectl millicores", parse: parseKubectlTop, output: "infrahub-database-0   1250m   3012Mi\n", wantCPU: 125, wantIO: -1},
+		{name: "kubectl cores", parse: parseKubectlTop, output: "infrahub-database-0 2 3012Mi", wantCPU: 200, wantIO: -1},
+		{name: "kubectl garbage", parse: parseKubectlTop, output: "error: Metrics API not available", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sample, err := tt.parse(tt.output)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parse(%q) error = %v, wantErr %v", tt.output, err, tt.wantErr)
+			}
+			if err == nil && (sample.cpuPercent != tt.wantCPU || sample.ioBytes != tt.wantIO) {
+				t.Errorf("parse(%q) = %.2f%% %d, want %.2f%% %d", tt.output, sample.cpuPercent, sample.ioBytes, tt.wantCPU, tt.wantIO)
+			}
+		})
+	}
+}
+
+func TestLoadThrottleWait(t *testing.T) {
+	original := pollSleep
+	pollSleep = func(time.Duration) {}
+	t.Cleanup(func() { pollSleep = original })
+
+	// Samples are dated in the past so none is reused.
+	base := time.Now().Add(-time.Hour)
+	cpu := func(percent float64) loadSample { return loadSample{cpuPercent: percent, ioBytes: -1, at: base} }
+	io := func(second int, bytes int64) loadSample {
+		return loadSample{ioBytes: bytes, at: base.Add(time.Duration(second) * time.Second)}
+	}
+	tests := []struct {
+		name        string
+		maxCPU      float64
+		maxIO       int64
+		maxPause    time.Duration
+		samples     []loadSample
+		err         error
+		wantSamples int
+		wantPaused  bool
+	}{
+		{name: "idle", maxCPU: 80, samples: []loadSample{cpu(20)}, wantSamples: 1},
+		{name: "busy then idle", maxCPU: 80, samples: []loadSample{cpu(90), cpu(95), cpu(20)}, wantSamples: 3, wantPaused: true},
+		{name: "io burst", maxIO: 50 << 20, samples: []loadSample{io(0, 0), io(1, 200<<20), io(2, 210<<20)}, wantSamples: 3, wantPaused: true},
+		{name: "max pause", maxCPU: 80, maxPause: time.Nanosecond, samples: []loadSample{cpu(90), cpu(90)}, wantSamples: 1, wantPaused: true},
+		{name: "unreadable load", maxCPU: 80, err: errors.New("metrics API not available"), wantSamples: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sampled := 0
+			throttle := &loadThrottle{
+				service:  "database",
+				maxCPU:   tt.maxCPU,
+				maxIO:    tt.maxIO,
+				maxPause: tt.maxPause,
+				sample: func() (loadSample, error) {
+					sampled++
+					if tt.err != nil {
+						return loadSample{}, tt.err
+					}
+					return tt.samples[min(sampled, len(tt.samples))-1], nil
+				},
+				poller: func(what string) *poller {
+					return &poller{what: what, interval: time.Millisecond, max: time.Millisecond, started: time.Now(), logf: t.Logf}
+				},
+			}
+			if tt.maxPause == 0 {
+				throttle.maxPause = time.Minute
+			}
+			// The first IO sample only sets the baseline.
+			if tt.maxIO > 0 {
+				throttle.busy()
+			}
+			throttle.wait("copying neo4j.backup")
+			if sampled != tt.wantSamples {
+				t.Errorf("sampled %d times, want %d", sampled, tt.wantSamples)
+			}
+			if paused := throttle.paused > 0; paused != tt.wantPaused {
+				t.Errorf("paused = %s, want paused %v", throttle.paused, tt.wantPaused)
+			}
+		})
+	}
+
+	var nilThrottle *loadThrottle
+	nilThrottle.wait("copying")
+}
+
+func TestSampleLoadDocker(t *testing.T) {
+	fake := newFakeExecutor().
+		on("ps -q database", "0123abcd\n", nil).
+		on("stats --no-stream", "91.02%\t2GB / 1GB\n", nil)
+	iops := newFakeDockerOps(fake)
+
+	sample, err := iops.sampleLoad("database")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sample.cpuPercent != 91.02 || sample.ioBytes != 3<<30 {
+		t.Errorf("sampleLoad() = %+v", sample)
+	}
+	if got := fake.calls[len(fake.calls)-1]; !strings.HasSuffix(got, " 0123abcd") {
+		t.Errorf("docker stats call = %q", got)
+	}
+}
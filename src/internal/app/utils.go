@@ -2,7 +2,6 @@ package app
 
 import (
 	"archive/tar"
-	"compress/gzip"
 	"crypto/sha256"
 	"fmt"
 	"io"
@@ -73,19 +72,33 @@ func calculateSHA256(filePath string) (string, error) {
 	return fmt.Sprintf("%x", hasher.Sum(nil)), nil
 }
 
-func createTarball(filename, sourceDir, pathInTar string) error {
-	file, err := os.Create(filename)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	gw := gzip.NewWriter(file)
-	defer gw.Close()
+// writeTar writes sourceDir/pathInTar as a tar stream to w, with entry names
+// relative to sourceDir.
+func writeTar(w io.Writer, sourceDir, pathInTar string) error {
+	return writeTarObserved(w, sourceDir, pathInTar, nil)
+}
 
-	tw := tar.NewWriter(gw)
+// writeTarObserved is writeTar calling onEntry, when set, with the name of
+// each entry before it is written.
+func writeTarObserved(w io.Writer, sourceDir, pathInTar string, onEntry func(name string)) error {
+	tw := tar.NewWriter(w)
 	defer tw.Close()
 
+	var observe func(header *tar.Header) error
+	if onEntry != nil {
+		observe = func(header *tar.Header) error {
+			onEntry(header.Name)
+			return nil
+		}
+	}
+	return writeTarEntries(tw, sourceDir, pathInTar, observe)
+}
+
+// writeTarEntries writes sourceDir/pathInTar to tw without closing it. When
+// onEntry is set, the previous entry is flushed, padding included, before
+// onEntry is called with the header of the next one.
+func writeTarEntries(tw *tar.Writer, sourceDir, pathInTar string, onEntry func(header *tar.Header) error) error {
+	links := hardLinkTracker{}
 	return filepath.Walk(filepath.Join(sourceDir, pathInTar), func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -101,12 +114,21 @@ func createTarball(filename, sourceDir, pathInTar string) error {
 			return err
 		}
 		header.Name = filepath.ToSlash(relPath)
+		links.observe(header, info)
+		if onEntry != nil {
+			if err := tw.Flush(); err != nil {
+				return err
+			}
+			if err := onEntry(header); err != nil {
+				return err
+			}
+		}
 
 		if err := tw.WriteHeader(header); err != nil {
 			return err
 		}
 
-		if info.IsDir() {
+		if info.IsDir() || header.Typeflag == tar.TypeLink {
 			return nil
 		}
 
@@ -121,23 +143,13 @@ func createTarball(filename, sourceDir, pathInTar string) error {
 	})
 }
 
-func extractTarball(filename, destDir string) error {
-	file, err := os.Open(filename)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	gr, err := gzip.NewReader(file)
-	if err != nil {
-		return err
-	}
-	defer gr.Close()
-
-	tr := tar.NewReader(gr)
+// extractTar extracts a tar stream into destDir, rejecting entries that would
+// escape it.
+func extractTar(r io.Reader, destDir string) error {
+	tr := tar.NewReader(r)
 
 	// Ensure destination directory is absolute for security checks
-	destDir, err = filepath.Abs(destDir)
+	destDir, err := filepath.Abs(destDir)
 	if err != nil {
 		return fmt.Errorf("failed to get absolute path for destination: %w", err)
 	}
@@ -151,33 +163,28 @@ func extractTarball(filename, destDir string) error {
 			return err
 		}
 
+		// Chunks of a streamed dump are joined into the dump
+		name := header.Name
+		chunk := header.PAXRecords[paxStreamTarget] != "" && header.Typeflag == tar.TypeReg
+		if chunk {
+			name = header.PAXRecords[paxStreamTarget]
+		}
+
 		// Prevent Zip Slip vulnerability: validate that the target path is within destDir
-		target := filepath.Join(destDir, header.Name)
+		target := filepath.Join(destDir, name)
 		target = filepath.Clean(target)
 		if !isPathWithinDirectory(target, destDir) {
-			return fmt.Errorf("illegal file path in archive: %s (attempts to escape destination directory)", header.Name)
+			return fmt.Errorf("illegal file path in archive: %s (attempts to escape destination directory)", name)
 		}
 
-		switch header.Typeflag {
-		case tar.TypeDir:
-			if err := os.MkdirAll(target, 0755); err != nil {
-				return err
-			}
-		case tar.TypeReg:
-			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
-				return err
-			}
-
-			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY, os.FileMode(header.Mode))
-			if err != nil {
+		if chunk {
+			if err := extractStreamChunk(tr, target, header); err != nil {
 				return err
 			}
-
-			if _, err := io.Copy(f, tr); err != nil {
-				f.Close()
-				return err
-			}
-			f.Close()
+			continue
+		}
+		if err := extractTarEntry(tr, header, target, header.Linkname, destDir); err != nil {
+			return err
 		}
 	}
 
@@ -209,16 +216,9 @@ func extractUncompressedTar(filename, destDir string, stripComponents int) error
 			return err
 		}
 
-		name := header.Name
-		if stripComponents > 0 {
-			parts := strings.SplitN(name, "/", stripComponents+1)
-			if len(parts) <= stripComponents {
-				continue // entry is entirely within the stripped prefix
-			}
-			name = parts[stripComponents]
-			if name == "" {
-				continue
-			}
+		name, ok := stripTarPath(header.Name, stripComponents)
+		if !ok {
+			continue // entry is entirely within the stripped prefix
 		}
 
 		target := filepath.Join(destDir, name)
@@ -227,32 +227,28 @@ func extractUncompressedTar(filename, destDir string, stripComponents int) error
 			return fmt.Errorf("illegal file path in archive: %s (attempts to escape destination directory)", header.Name)
 		}
 
-		switch header.Typeflag {
-		case tar.TypeDir:
-			if err := os.MkdirAll(target, 0755); err != nil {
-				return err
-			}
-		case tar.TypeReg:
-			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
-				return err
-			}
-
-			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY, os.FileMode(header.Mode))
-			if err != nil {
-				return err
-			}
-
-			if _, err := io.Copy(f, tr); err != nil {
-				f.Close()
-				return err
-			}
-			f.Close()
+		linkName, _ := stripTarPath(header.Linkname, stripComponents)
+		if err := extractTarEntry(tr, header, target, linkName, destDir); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
+// stripTarPath removes the first stripComponents components of name; it
+// reports false when nothing is left.
+func stripTarPath(name string, stripComponents int) (string, bool) {
+	if stripComponents <= 0 {
+		return name, true
+	}
+	parts := strings.SplitN(name, "/", stripComponents+1)
+	if len(parts) <= stripComponents || parts[stripComponents] == "" {
+		return "", false
+	}
+	return parts[stripComponents], true
+}
+
 // isPathWithinDirectory checks if path is within dir (prevents directory traversal attacks)
 func isPathWithinDirectory(path, dir string) bool {
 	rel, err := filepath.Rel(dir, path)
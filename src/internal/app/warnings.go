@@ -0,0 +1,145 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Process exit codes.
+const (
+	ExitSuccess = 0
+	ExitFailure = 1
+	// ExitWarnings is returned when the command succeeded but logged warnings
+	// and --warnings-as-errors is set.
+	ExitWarnings = 2
+	// ExitSLOBreach is returned when the command succeeded but missed --rpo
+	// or --rto and --enforce-slo is set.
+	ExitSLOBreach = 3
+)
+
+// maxCollectedWarnings bounds the memory a long-running daemon spends on
+// warnings; later ones are counted but not kept.
+const maxCollectedWarnings = 1000
+
+// warningCollector is a logrus hook keeping every warning logged during the
+// process, so a run that otherwise succeeds can still report them.
+type warningCollector struct {
+	mu       sync.Mutex
+	messages []string
+	total    int
+}
+
+func newWarningCollector() *warningCollector {
+	return &warningCollector{}
+}
+
+// Levels implements logrus.Hook.
+func (c *warningCollector) Levels() []logrus.Level {
+	return []logrus.Level{logrus.WarnLevel}
+}
+
+// Fire implements logrus.Hook.
+func (c *warningCollector) Fire(entry *logrus.Entry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.total++
+	if len(c.messages) < maxCollectedWarnings {
+		c.messages = append(c.messages, entry.Message)
+	}
+	return nil
+}
+
+// mark returns a position from which since lists the later warnings.
+func (c *warningCollector) mark() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.total
+}
+
+// since returns the kept warnings logged after mark.
+func (c *warningCollector) since(mark int) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if mark >= len(c.messages) {
+		return nil
+	}
+	return append([]string(nil), c.messages[mark:]...)
+}
+
+// count returns how many warnings were logged, kept or not.
+func (c *warningCollector) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.total
+}
+
+// writeWarningSummary prints the warnings once each, with the number of times
+// repeated ones occurred.
+func writeWarningSummary(w io.Writer, warnings []string, total int) {
+	counts := map[string]int{}
+	var unique []string
+	for _, message := range warnings {
+		if counts[message] == 0 {
+			unique = append(unique, message)
+		}
+		counts[message]++
+	}
+
+	noun := "warnings"
+	if total == 1 {
+		noun = "warning"
+	}
+	fmt.Fprintf(w, "Completed with %d %s:\n", total, noun)
+	for _, message := range unique {
+		if counts[message] > 1 {
+			fmt.Fprintf(w, "  - %s (%d times)\n", message, counts[message])
+		} else {
+			fmt.Fprintf(w, "  - %s\n", message)
+		}
+	}
+	if dropped := total - len(warnings); dropped > 0 {
+		fmt.Fprintf(w, "  ... and %d more\n", dropped)
+	}
+}
+
+// Finish emits the command error as an event, reports the warnings logged by
+// the process, sends the usage report
+// when telemetry is enabled, and returns the exit code. err is the error
+// returned by the command, if any. The summary goes to w, or to the log as a
+// single entry when --log-format is json.
+func (iops *InfrahubOps) Finish(w io.Writer, err error) int {
+	if err != nil {
+		iops.events.emit(Event{Type: EventError, Message: err.Error()})
+	}
+	code := iops.reportWarnings(w, err)
+	iops.sendTelemetry(code)
+	return code
+}
+
+// reportWarnings prints the warning summary and returns the exit code.
+func (iops *InfrahubOps) reportWarnings(w io.Writer, err error) int {
+	total := iops.warnings.count()
+	if total > 0 {
+		warnings := iops.warnings.since(0)
+		if iops.settings.GetString("log-format") == "json" {
+			logrus.WithFields(logrus.Fields{"warnings": warnings, "warning_count": total}).Info("Completed with warnings")
+		} else {
+			writeWarningSummary(w, warnings, total)
+		}
+	}
+
+	switch {
+	case err != nil:
+		return ExitFailure
+	case iops.sloBreached && iops.config.EnforceSLO:
+		logrus.Error("Failing because the run missed its service level objectives and --enforce-slo is set")
+		return ExitSLOBreach
+	case total > 0 && iops.config.WarningsAsErrors:
+		logrus.Errorf("Failing because %d warnings were logged and --warnings-as-errors is set", total)
+		return ExitWarnings
+	}
+	return ExitSuccess
+}
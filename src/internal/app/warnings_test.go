@@ -0,0 +1,108 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestWarningCollector(t *testing.T) {
+	collector := newWarningCollector()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	logger.AddHook(collector)
+
+	logger.Warn("before")
+	mark := collector.mark()
+	logger.Info("not collected")
+	logger.Warnf("cleanup failed: %s", "busy")
+	logger.Error("not collected either")
+
+	if got := collector.since(mark); !slices.Equal(got, []string{"cleanup failed: busy"}) {
+		t.Errorf("since(mark) = %q", got)
+	}
+	if got := collector.since(0); len(got) != 2 || collector.count() != 2 {
+		t.Errorf("since(0) = %q, count() = %d", got, collector.count())
+	}
+}
+
+func TestWriteWarningSummary(t *testing.T) {
+	tests := []struct {
+		name     string
+		warnings []string
+		total    int
+		want     string
+	}{
+		{
+			name:     "single",
+			warnings: []string{"Failed to remove temporary files"},
+			total:    1,
+			want:     "Completed with 1 warning:\n  - Failed to remove temporary files\n",
+		},
+		{
+			name:     "repeated",
+			warnings: []string{"cache wipe failed", "slow disk", "cache wipe failed"},
+			total:    3,
+			want:     "Completed with 3 warnings:\n  - cache wipe failed (2 times)\n  - slow disk\n",
+		},
+		{
+			name:     "over the limit",
+			warnings: []string{"kept"},
+			total:    4,
+			want:     "Completed with 4 warnings:\n  - kept\n  ... and 3 more\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			writeWarningSummary(&out, tt.warnings, tt.total)
+			if out.String() != tt.want {
+				t.Errorf("summary =\n%s\nwant\n%s", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestFinish(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		warnings       int
+		asErrors       bool
+		sloBreached    bool
+		enforceSLO     bool
+		wantCode       int
+		wantSummarised bool
+	}{
+		{name: "clean", wantCode: ExitSuccess},
+		{name: "warnings", warnings: 2, wantCode: ExitSuccess, wantSummarised: true},
+		{name: "warnings as errors", warnings: 1, asErrors: true, wantCode: ExitWarnings, wantSummarised: true},
+		{name: "strict without warnings", asErrors: true, wantCode: ExitSuccess},
+		{name: "failure", err: errors.New("boom"), warnings: 1, asErrors: true, wantCode: ExitFailure, wantSummarised: true},
+		{name: "slo breach", warnings: 1, sloBreached: true, wantCode: ExitSuccess, wantSummarised: true},
+		{name: "slo breach enforced", warnings: 1, sloBreached: true, enforceSLO: true, asErrors: true, wantCode: ExitSLOBreach, wantSummarised: true},
+		{name: "failure with slo breach", err: errors.New("boom"), sloBreached: true, enforceSLO: true, wantCode: ExitFailure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iops := NewInfrahubOps()
+			iops.config.WarningsAsErrors = tt.asErrors
+			iops.config.EnforceSLO = tt.enforceSLO
+			iops.sloBreached = tt.sloBreached
+			for range tt.warnings {
+				iops.warnings.Fire(&logrus.Entry{Message: "disk almost full"})
+			}
+			var out bytes.Buffer
+			if code := iops.Finish(&out, tt.err); code != tt.wantCode {
+				t.Errorf("Finish() = %d, want %d", code, tt.wantCode)
+			}
+			if summarised := out.Len() > 0; summarised != tt.wantSummarised {
+				t.Errorf("summary printed = %v:\n%s", summarised, out.String())
+			}
+		})
+	}
+}
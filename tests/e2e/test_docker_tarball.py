@@ -1,10 +1,13 @@
 """E2E tests: Docker Compose + local tarball backup/restore."""
 
+import re
+
 import pytest
 from infrahub_sdk.testing.docker import TestInfrahubDockerClient
 
 from tests.helpers.utils import (
     find_latest_backup,
+    infrahub_tag_exists,
     modify_infrahub_data,
     run_backup,
     run_restore,
@@ -66,3 +69,51 @@ class TestDockerTarball(TestInfrahubDockerClient):
 
         # 6. Verify the tag is back
         await verify_infrahub_data(url, ADMIN_TOKEN, seed)
+
+    async def test_restore_rehearsal(
+        self, infrahub_compose, infrahub_port, backup_binary, tmp_path
+    ):
+        """Rehearse a restore in throwaway containers without touching the stack."""
+        url = f"http://localhost:{infrahub_port}"
+        project = infrahub_compose.project_name
+        backup_dir = str(tmp_path / "backups")
+
+        # 1. Seed test data and back it up
+        seed = await seed_infrahub_data(url, ADMIN_TOKEN)
+        run_backup(
+            backup_binary,
+            [
+                "--project",
+                project,
+                "--backup-dir",
+                backup_dir,
+                "create",
+                "--force",
+            ],
+        )
+        backup_file = find_latest_backup(backup_dir)
+        await wait_for_http(f"{url}/api/config", timeout=180.0, interval=5.0)
+
+        # 2. Modify live data; the rehearsal must leave it as is
+        await modify_infrahub_data(url, ADMIN_TOKEN, seed)
+
+        # 3. Rehearse the restore
+        result = run_restore(
+            backup_binary,
+            [
+                "restore",
+                str(backup_file),
+                "--rehearse",
+            ],
+        )
+
+        # 4. The rehearsal loaded both databases and reported success
+        logs = result.stdout + result.stderr
+        backup_id = backup_file.name.removesuffix(".tar.gz")
+        assert f"Restore rehearsal of {backup_id} succeeded" in logs, logs
+        nodes = re.search(r"Neo4j backup loaded in rehearsal container.*nodes=(\d+)", logs)
+        assert nodes and int(nodes.group(1)) > 0, logs
+        assert "Task manager dump loaded in rehearsal container" in logs, logs
+
+        # 5. The live stack still has the modified data
+        assert not await infrahub_tag_exists(url, ADMIN_TOKEN, seed["tag_name"])
@@ -80,6 +80,18 @@ async def modify_infrahub_data(infrahub_url: str, token: str, data: dict) -> Non
     )
 
 
+async def infrahub_tag_exists(infrahub_url: str, token: str, tag_name: str) -> bool:
+    """Return True if a BuiltinTag with the given name exists."""
+    from infrahub_sdk import Config as InfrahubConfig
+    from infrahub_sdk import InfrahubClient
+
+    config = InfrahubConfig(address=infrahub_url, api_token=token)
+    client = InfrahubClient(config=config)
+
+    tags = await client.all(kind="BuiltinTag")
+    return any(t.name.value == tag_name for t in tags)
+
+
 def run_backup(
     binary: str,
     extra_args: list[str],
@@ -5,9 +5,12 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 	"unsafe"
 
 	"golang.org/x/sys/unix"
@@ -16,21 +19,80 @@ import (
 var (
 	pidFile   = flag.String("pid-file", "/var/lib/neo4j/run/neo4j.pid", "Path to the neo4j pid file")
 	readyFile = flag.String("ready-file", "", "Optional path to write once watcher is initialized")
+	selfTest  = flag.Bool("self-test", false, "Exercise inotify and signalling on a scratch file, print the platform and exit")
 )
 
 func main() {
 	flag.Parse()
 
+	if *selfTest {
+		if err := runSelfTest(); err != nil {
+			log.Fatalf("self-test failed: %v", err)
+		}
+		fmt.Printf("ok %s/%s\n", runtime.GOOS, runtime.GOARCH)
+		return
+	}
+
 	pid, err := readPID(*pidFile)
 	if err != nil {
 		log.Fatalf("failed to read pid: %v", err)
 	}
 
-	if err := watchForDelete(*pidFile, pid, *readyFile); err != nil {
+	err = watchForDelete(*pidFile, *readyFile, func() error {
+		if err := syscall.Kill(pid, syscall.SIGSTOP); err != nil {
+			return fmt.Errorf("failed to SIGSTOP pid %d: %w", pid, err)
+		}
+		return nil
+	})
+	if err != nil {
 		log.Fatalf("watcher error: %v", err)
 	}
 }
 
+// runSelfTest watches a scratch pid file holding our own pid, deletes it and
+// checks that the deletion is seen and that the pid can be signalled (with
+// signal 0, so nothing is stopped).
+func runSelfTest() error {
+	dir, err := os.MkdirTemp("", "neo4j_watchdog_selftest_*")
+	if err != nil {
+		return fmt.Errorf("create scratch dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "self.pid")
+	if err := os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())), 0644); err != nil {
+		return fmt.Errorf("write scratch pid file: %w", err)
+	}
+	pid, err := readPID(path)
+	if err != nil {
+		return err
+	}
+
+	ready := filepath.Join(dir, "ready")
+	go func() {
+		for {
+			if _, err := os.Stat(ready); err == nil {
+				_ = os.Remove(path)
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- watchForDelete(path, ready, func() error {
+			return syscall.Kill(pid, 0)
+		})
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(10 * time.Second):
+		return fmt.Errorf("deletion of %s was not observed", path)
+	}
+}
+
 func readPID(path string) (int, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -44,7 +106,9 @@ func readPID(path string) (int, error) {
 	return pid, nil
 }
 
-func watchForDelete(path string, pid int, readyFile string) error {
+// watchForDelete blocks until path is deleted or moved and then calls
+// onDelete. readyFile, if set, is written once the watch is in place.
+func watchForDelete(path string, readyFile string, onDelete func() error) error {
 	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC)
 	if err != nil {
 		return fmt.Errorf("inotify init: %w", err)
@@ -81,10 +145,7 @@ func watchForDelete(path string, pid int, readyFile string) error {
 			raw := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
 			mask := uint32(raw.Mask)
 			if mask&(unix.IN_DELETE_SELF|unix.IN_MOVE_SELF) != 0 {
-				if err := syscall.Kill(pid, syscall.SIGSTOP); err != nil {
-					return fmt.Errorf("failed to SIGSTOP pid %d: %w", pid, err)
-				}
-				return nil
+				return onDelete()
 			}
 			offset += unix.SizeofInotifyEvent + int(raw.Len)
 		}